@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"nsa/internal/bench"
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/mongodb"
+)
+
+// runBench 执行 nsa bench 压测模式：向压测topic发布合成消息，输出端到端延迟分布和执行器饱和情况
+func runBench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	configFile := flags.String("config", "config.json", "配置文件")
+	nsqd := flags.String("nsqd", "", "发布消息的nsqd TCP地址，默认为 nsq.nsqd_addresses 的第一个")
+	topic := flags.String("topic", "nsa_bench", "压测topic，需要已配置启用的工作流")
+	rate := flags.Int("rate", 100, "每秒发布的消息数")
+	duration := flags.Duration("duration", 30*time.Second, "发布持续时间")
+	size := flags.Int("size", 256, "消息中填充数据的字节数")
+	wait := flags.Duration("wait", time.Minute, "发布结束后等待实例结束的最长时间")
+	output := flags.String("output", "text", "输出格式：text 或 json")
+	flags.Parse(args)
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	// 压测统计按 message.data.bench_run 和 sent_at 查询实例，启用存储加密后消息数据为密文
+	if len(cfg.Encryption.Keys) > 0 {
+		log.Fatalf("nsa bench does not support storage encryption: benchmark statistics query message.data, which is encrypted when encryption.keys is configured")
+	}
+	logger := logger.New(cfg.Logging)
+
+	if *nsqd == "" && len(cfg.NSQ.NSQDAddresses) > 0 {
+		*nsqd = cfg.NSQ.NSQDAddresses[0]
+	}
+
+	mongoClient, err := mongodb.NewClient(cfg.MongoDB)
+	if err != nil {
+		logger.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer mongoClient.Disconnect()
+
+	// 中断时停止发布，统计已发布的消息
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	runner := bench.NewRunner(bench.Options{
+		NSQDAddress: *nsqd,
+		Topic:       *topic,
+		Rate:        *rate,
+		Duration:    *duration,
+		PayloadSize: *size,
+		Wait:        *wait,
+	}, logger, mongoClient)
+	report, err := runner.Run(ctx)
+	if err != nil {
+		logger.Fatalf("Benchmark failed: %v", err)
+	}
+
+	if *output == "json" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	printReport(os.Stdout, report)
+}
+
+// printReport 以文本格式输出压测结果
+func printReport(w io.Writer, report *bench.Report) {
+	fmt.Fprintf(w, "run:          %s (topic %s)\n", report.RunID, report.Topic)
+	fmt.Fprintf(w, "published:    %d (%.1f msg/s, %d errors)\n", report.Published, report.PublishRate, report.PublishErrors)
+	fmt.Fprintf(w, "finished:     %d completed, %d failed, %d missing\n", report.Completed, report.Failed, report.Missing)
+	fmt.Fprintf(w, "throughput:   %.1f msg/s\n", report.Throughput)
+	fmt.Fprintf(w, "max running:  %d\n", report.MaxRunning)
+	fmt.Fprintf(w, "max backlog:  %d\n", report.MaxBacklog)
+	fmt.Fprintf(w, "\n%-12s %8s %10s %10s %10s %10s %10s %10s\n", "latency(ms)", "count", "min", "mean", "p50", "p90", "p99", "max")
+	for _, row := range []struct {
+		name string
+		dist bench.Distribution
+	}{
+		{"end-to-end", report.EndToEnd},
+		{"queue wait", report.QueueWait},
+		{"execution", report.Execution},
+	} {
+		d := row.dist
+		fmt.Fprintf(w, "%-12s %8d %10.1f %10.1f %10.1f %10.1f %10.1f %10.1f\n", row.name, d.Count, d.Min, d.Mean, d.P50, d.P90, d.P99, d.Max)
+	}
+}
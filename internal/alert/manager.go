@@ -0,0 +1,468 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"nsa/internal/cluster"
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+	"nsa/internal/secrets"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 告警规则和告警历史集合名称
+const (
+	RuleCollection    = "alert_rules"
+	HistoryCollection = "alert_history"
+)
+
+// 告警规则类型
+const (
+	TypeFailures = "failures"
+	TypeDuration = "duration"
+)
+
+// evaluatorLease 多个节点中只有持有该租约的节点评估告警规则
+const evaluatorLease = "alert-evaluator"
+
+// evaluateTimeout 评估一条规则并发送告警的超时时间
+const evaluateTimeout = time.Minute
+
+// maxAlertsPerEvaluation duration 规则一次评估最多告警的实例数，其余实例在下一次评估时告警
+const maxAlertsPerEvaluation = 20
+
+// failedStatuses 计入 failures 规则的实例状态，与健康报告的失败口径相同
+var failedStatuses = bson.A{"failed", "interrupted"}
+
+// endedStatuses 有结束时间的实例状态
+var endedStatuses = bson.A{"completed", "failed", "interrupted", "cancelled"}
+
+// Manager 告警规则管理器，持续评估启用的规则，满足条件时记录告警并发送到规则的通知渠道
+type Manager struct {
+	cfg       config.AlertsConfig
+	logger    logger.Logger
+	mongoDB   *mongodb.Client
+	node      *cluster.Node
+	notifiers map[string]Notifier
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewManager 创建告警规则管理器，注册 email、slack（通过 sender 发送）和 webhook 通知渠道
+func NewManager(cfg config.AlertsConfig, logger logger.Logger, mongoClient *mongodb.Client, secretStore *secrets.Store, node *cluster.Node, sender Sender) *Manager {
+	m := &Manager{
+		cfg:       cfg,
+		logger:    logger,
+		mongoDB:   mongoClient,
+		node:      node,
+		notifiers: make(map[string]Notifier),
+	}
+	m.RegisterNotifier(ChannelEmail, &emailNotifier{sender: sender})
+	m.RegisterNotifier(ChannelSlack, &slackNotifier{sender: sender, secrets: secretStore})
+	m.RegisterNotifier(ChannelWebhook, newWebhookNotifier(secretStore))
+	return m
+}
+
+// RegisterNotifier 注册通知渠道，同名渠道被替换；在 Start 之前调用
+func (m *Manager) RegisterNotifier(channelType string, notifier Notifier) {
+	m.notifiers[channelType] = notifier
+}
+
+// rules 返回告警规则集合
+func (m *Manager) rules() *mongo.Collection {
+	return m.mongoDB.GetDatabase().Collection(RuleCollection)
+}
+
+// history 返回告警历史集合
+func (m *Manager) history() *mongo.Collection {
+	return m.mongoDB.GetDatabase().Collection(HistoryCollection)
+}
+
+// EnsureIndexes 创建规则名称唯一索引和告警历史索引
+//
+// (rule_id, instance_id) 唯一索引只包含有实例的告警，保证 duration 规则每个实例只告警一次。
+func (m *Manager) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := m.rules().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+	_, err := m.history().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "rule_id", Value: 1}, {Key: "instance_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"instance_id": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "fired_at", Value: -1}}},
+		{Keys: bson.D{{Key: "workflow_id", Value: 1}, {Key: "fired_at", Value: -1}}},
+	})
+	return err
+}
+
+// Validate 校验告警规则和通知渠道，webhook、slack 渠道的密钥必须已存在
+func (m *Manager) Validate(ctx context.Context, rule *models.AlertRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if rule.WorkflowID != "" {
+		if _, err := primitive.ObjectIDFromHex(rule.WorkflowID); err != nil {
+			return fmt.Errorf("invalid workflow_id %q", rule.WorkflowID)
+		}
+	}
+	switch rule.Type {
+	case TypeFailures:
+		if rule.Threshold < 1 {
+			return fmt.Errorf("threshold must be at least 1 for %s rules", TypeFailures)
+		}
+		if rule.Window < 1 {
+			return fmt.Errorf("window must be at least 1 minute for %s rules", TypeFailures)
+		}
+		if rule.Cooldown < 0 {
+			return fmt.Errorf("cooldown must not be negative")
+		}
+	case TypeDuration:
+		if rule.MaxDuration < 1 {
+			return fmt.Errorf("max_duration must be at least 1 second for %s rules", TypeDuration)
+		}
+	default:
+		return fmt.Errorf("type must be %s or %s, got %q", TypeFailures, TypeDuration, rule.Type)
+	}
+
+	if len(rule.Channels) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+	for i := range rule.Channels {
+		channel := &rule.Channels[i]
+		notifier, ok := m.notifiers[channel.Type]
+		if !ok {
+			return fmt.Errorf("channels[%d]: unsupported channel type %q", i, channel.Type)
+		}
+		if err := notifier.Validate(ctx, channel); err != nil {
+			return fmt.Errorf("channels[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Start 启动后台评估
+func (m *Manager) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		// 多个节点上只有一个评估规则，避免重复告警
+		m.node.Hold(ctx, evaluatorLease, m.run)
+	}()
+}
+
+// Stop 停止后台评估，等待正在发送的告警完成
+func (m *Manager) Stop() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+	m.cancel = nil
+}
+
+// run 定期评估启用的规则，直到ctx取消
+func (m *Manager) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(m.cfg.EvalInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		m.evaluateAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluateAll 依次评估所有启用的规则
+func (m *Manager) evaluateAll(ctx context.Context) {
+	cursor, err := m.rules().Find(ctx, bson.M{"enabled": true})
+	if err != nil {
+		if ctx.Err() == nil {
+			m.logger.Errorf("Failed to find alert rules: %v", err)
+		}
+		return
+	}
+	var rules []models.AlertRule
+	if err := cursor.All(ctx, &rules); err != nil {
+		m.logger.Errorf("Failed to decode alert rules: %v", err)
+		return
+	}
+
+	for i := range rules {
+		if ctx.Err() != nil {
+			return
+		}
+		m.evaluate(ctx, &rules[i], time.Now())
+	}
+}
+
+// evaluate 评估一条规则并发送满足条件的告警，记录评估时间和错误
+func (m *Manager) evaluate(ctx context.Context, rule *models.AlertRule, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, evaluateTimeout)
+	defer cancel()
+
+	var err error
+	switch rule.Type {
+	case TypeFailures:
+		err = m.evaluateFailures(ctx, rule, now)
+	case TypeDuration:
+		err = m.evaluateDuration(ctx, rule, now)
+	default:
+		err = fmt.Errorf("unsupported rule type %q", rule.Type)
+	}
+	if err != nil {
+		m.logger.Errorf("Failed to evaluate alert rule %s: %v", rule.Name, err)
+	}
+
+	status := bson.M{"last_evaluated_at": now, "last_error": ""}
+	if err != nil {
+		status["last_error"] = err.Error()
+	}
+	if _, updateErr := m.rules().UpdateOne(ctx, bson.M{"_id": rule.ID}, bson.M{"$set": status}); updateErr != nil {
+		m.logger.Errorf("Failed to update alert rule %s: %v", rule.Name, updateErr)
+	}
+}
+
+// evaluateFailures Window 分钟内结束的失败实例数达到阈值且不在冷却期内时告警
+//
+// 先以条件更新 last_fired_at 认领告警，更新成功的节点才发送，避免租约切换时重复告警。
+func (m *Manager) evaluateFailures(ctx context.Context, rule *models.AlertRule, now time.Time) error {
+	cooldown := rule.Cooldown
+	if cooldown == 0 {
+		cooldown = rule.Window
+	}
+	if !rule.LastFiredAt.IsZero() && now.Sub(rule.LastFiredAt) < time.Duration(cooldown)*time.Minute {
+		return nil
+	}
+
+	filter := bson.M{
+		"status":   bson.M{"$in": failedStatuses},
+		"end_time": bson.M{"$gte": now.Add(-time.Duration(rule.Window) * time.Minute)},
+	}
+	if rule.WorkflowID != "" {
+		filter["workflow_id"] = rule.WorkflowID
+	}
+	count, err := m.mongoDB.GetDatabase().Collection("workflow_instances").CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count < int64(rule.Threshold) {
+		return nil
+	}
+
+	claim := bson.M{"_id": rule.ID, "last_fired_at": rule.LastFiredAt}
+	if rule.LastFiredAt.IsZero() {
+		claim["last_fired_at"] = bson.M{"$exists": false}
+	}
+	claimed, err := m.rules().UpdateOne(ctx, claim, bson.M{"$set": bson.M{"last_fired_at": now}})
+	if err != nil {
+		return err
+	}
+	if claimed.ModifiedCount == 0 {
+		return nil
+	}
+
+	alert := &models.Alert{
+		WorkflowID: rule.WorkflowID,
+		Value:      float64(count),
+		Threshold:  float64(rule.Threshold),
+	}
+	scope := "all workflows"
+	if rule.WorkflowID != "" {
+		alert.Workflow = workflowNames{}.get(ctx, m.mongoDB, rule.WorkflowID)
+		scope = "workflow " + alert.Workflow
+	}
+	alert.Message = fmt.Sprintf("%d instances of %s failed in the last %d minutes (threshold %d)",
+		count, scope, rule.Window, rule.Threshold)
+	return m.fire(ctx, rule, alert, now)
+}
+
+// evaluateDuration 执行中的实例已执行时间、或上一次评估后结束的实例执行时间超过 MaxDuration 时，每个实例告警一次
+func (m *Manager) evaluateDuration(ctx context.Context, rule *models.AlertRule, now time.Time) error {
+	limit := time.Duration(rule.MaxDuration) * time.Second
+	// 规则创建或修改前结束的实例不告警
+	since := rule.LastEvaluatedAt
+	if since.IsZero() || since.Before(rule.UpdatedAt) {
+		since = rule.UpdatedAt
+	}
+
+	match := bson.M{"$or": bson.A{
+		bson.M{"status": "running", "start_time": bson.M{"$lte": now.Add(-limit)}},
+		bson.M{
+			"status":   bson.M{"$in": endedStatuses},
+			"end_time": bson.M{"$gte": since},
+			"$expr":    bson.M{"$gt": bson.A{bson.M{"$subtract": bson.A{"$end_time", "$start_time"}}, limit.Milliseconds()}},
+		},
+	}}
+	if rule.WorkflowID != "" {
+		match["workflow_id"] = rule.WorkflowID
+	}
+	ruleID := rule.ID.Hex()
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$sort": bson.M{"start_time": 1}},
+		// 排除已经告警的实例
+		bson.M{"$lookup": bson.M{
+			"from": HistoryCollection,
+			"let":  bson.M{"instance_id": "$_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$and": bson.A{
+					bson.M{"$eq": bson.A{"$rule_id", ruleID}},
+					bson.M{"$eq": bson.A{"$instance_id", "$$instance_id"}},
+				}}}},
+				bson.M{"$limit": 1},
+				bson.M{"$project": bson.M{"_id": 1}},
+			},
+			"as": "alerted",
+		}},
+		bson.M{"$match": bson.M{"alerted": bson.M{"$size": 0}}},
+		bson.M{"$limit": maxAlertsPerEvaluation},
+		bson.M{"$project": bson.M{"workflow_id": 1, "status": 1, "start_time": 1, "end_time": 1}},
+	}
+
+	cursor, err := m.mongoDB.GetDatabase().Collection("workflow_instances").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var instances []struct {
+		ID         string    `bson:"_id"`
+		WorkflowID string    `bson:"workflow_id"`
+		Status     string    `bson:"status"`
+		StartTime  time.Time `bson:"start_time"`
+		EndTime    time.Time `bson:"end_time"`
+	}
+	if err := cursor.All(ctx, &instances); err != nil {
+		return err
+	}
+
+	names := workflowNames{}
+	var errs []error
+	for _, instance := range instances {
+		verb := "ran"
+		end := instance.EndTime
+		if instance.Status == "running" {
+			verb, end = "has been running", now
+		}
+		duration := end.Sub(instance.StartTime)
+		workflow := names.get(ctx, m.mongoDB, instance.WorkflowID)
+		alert := &models.Alert{
+			WorkflowID: instance.WorkflowID,
+			Workflow:   workflow,
+			InstanceID: instance.ID,
+			Value:      duration.Seconds(),
+			Threshold:  float64(rule.MaxDuration),
+			Message: fmt.Sprintf("Instance %s of workflow %s %s for %s (limit %s)",
+				instance.ID, workflow, verb, duration.Round(time.Second), limit),
+		}
+		if err := m.fire(ctx, rule, alert, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fire 记录告警并发送到规则的所有通知渠道，一个渠道失败时仍然发送其他渠道
+//
+// 告警已存在（同一规则和实例，其他节点已经告警）时不再发送。
+func (m *Manager) fire(ctx context.Context, rule *models.AlertRule, alert *models.Alert, now time.Time) error {
+	alert.RuleID = rule.ID.Hex()
+	alert.RuleName = rule.Name
+	alert.Type = rule.Type
+	alert.FiredAt = now
+	alert.Notifications = []models.AlertNotification{}
+
+	result, err := m.history().InsertOne(ctx, alert)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save alert: %v", err)
+	}
+	alert.ID = result.InsertedID.(primitive.ObjectID)
+	m.logger.Warnf("Alert %s fired: %s", rule.Name, alert.Message)
+
+	err = m.notify(ctx, rule.Channels, alert)
+	if _, updateErr := m.history().UpdateOne(ctx, bson.M{"_id": alert.ID}, bson.M{"$set": bson.M{"notifications": alert.Notifications}}); updateErr != nil {
+		m.logger.Errorf("Failed to update alert %s: %v", alert.ID.Hex(), updateErr)
+	}
+	return err
+}
+
+// notify 发送告警到各通知渠道，发送结果记录在 alert.Notifications 中
+func (m *Manager) notify(ctx context.Context, channels []models.AlertChannel, alert *models.Alert) error {
+	var errs []error
+	for i := range channels {
+		channel := &channels[i]
+		notification := models.AlertNotification{Channel: channel.Type}
+		err := fmt.Errorf("unsupported channel type %q", channel.Type)
+		if notifier, ok := m.notifiers[channel.Type]; ok {
+			err = notifier.Send(ctx, channel, alert)
+		}
+		if err != nil {
+			notification.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %v", channel.Type, err))
+		}
+		alert.Notifications = append(alert.Notifications, notification)
+	}
+	return errors.Join(errs...)
+}
+
+// Test 发送一条测试告警到规则的所有通知渠道，不记录告警历史，返回各渠道的发送结果
+func (m *Manager) Test(ctx context.Context, rule *models.AlertRule) []models.AlertNotification {
+	alert := &models.Alert{
+		RuleID:        rule.ID.Hex(),
+		RuleName:      rule.Name,
+		Type:          rule.Type,
+		WorkflowID:    rule.WorkflowID,
+		Message:       fmt.Sprintf("Test notification of alert rule %s", rule.Name),
+		Notifications: []models.AlertNotification{},
+		FiredAt:       time.Now(),
+	}
+	if rule.WorkflowID != "" {
+		alert.Workflow = workflowNames{}.get(ctx, m.mongoDB, rule.WorkflowID)
+	}
+	m.notify(ctx, rule.Channels, alert)
+	return alert.Notifications
+}
+
+// workflowNames 一次评估中读取过的工作流名称
+type workflowNames map[string]string
+
+// get 返回工作流名称，工作流不存在或读取失败时返回ID
+func (names workflowNames) get(ctx context.Context, mongoClient *mongodb.Client, id string) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	name := id
+	if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+		var workflow models.WorkflowConfig
+		opts := options.FindOne().SetProjection(bson.M{"name": 1})
+		if err := mongoClient.GetCollection().FindOne(ctx, bson.M{"_id": objectID}, opts).Decode(&workflow); err == nil {
+			name = workflow.Name
+		}
+	}
+	names[id] = name
+	return name
+}
@@ -0,0 +1,160 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+	"nsa/internal/secrets"
+)
+
+// 通知渠道类型
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+)
+
+// Notifier 告警通知渠道，通过 Manager.RegisterNotifier 注册
+type Notifier interface {
+	// Validate 校验规则中该类型渠道的配置
+	Validate(ctx context.Context, channel *models.AlertChannel) error
+	// Send 发送告警
+	Send(ctx context.Context, channel *models.AlertChannel, alert *models.Alert) error
+}
+
+// Sender 发送邮件和聊天 Webhook 通知，由报告调度器实现，邮件使用 reports.smtp
+type Sender interface {
+	EmailEnabled() bool
+	Notify(ctx context.Context, emails []string, webhookSecret, subject, text string) error
+}
+
+// subject 告警通知的标题
+func subject(alert *models.Alert) string {
+	return fmt.Sprintf("[NSA] Alert %s: %s", alert.RuleName, alert.Message)
+}
+
+// text 告警通知的正文
+func text(alert *models.Alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rule: %s (%s)\n", alert.RuleName, alert.Type)
+	if alert.WorkflowID != "" {
+		fmt.Fprintf(&b, "Workflow: %s (%s)\n", alert.Workflow, alert.WorkflowID)
+	}
+	if alert.InstanceID != "" {
+		fmt.Fprintf(&b, "Instance: %s\n", alert.InstanceID)
+	}
+	fmt.Fprintf(&b, "Fired at: %s\n\n%s", alert.FiredAt.UTC().Format(time.RFC3339), alert.Message)
+	return b.String()
+}
+
+// emailNotifier 发送纯文本邮件
+type emailNotifier struct {
+	sender Sender
+}
+
+func (n *emailNotifier) Validate(ctx context.Context, channel *models.AlertChannel) error {
+	if len(channel.Emails) == 0 {
+		return fmt.Errorf("emails is required for %s channels", ChannelEmail)
+	}
+	if !n.sender.EmailEnabled() {
+		return fmt.Errorf("%s channels require reports.smtp to be configured", ChannelEmail)
+	}
+	for _, email := range channel.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return fmt.Errorf("invalid email address %q", email)
+		}
+	}
+	return nil
+}
+
+func (n *emailNotifier) Send(ctx context.Context, channel *models.AlertChannel, alert *models.Alert) error {
+	return n.sender.Notify(ctx, channel.Emails, "", subject(alert), text(alert))
+}
+
+// slackNotifier 以 {"text": ...} 发送到密钥中保存的 Slack（或 Mattermost 等兼容）Incoming Webhook 地址
+type slackNotifier struct {
+	sender  Sender
+	secrets *secrets.Store
+}
+
+func (n *slackNotifier) Validate(ctx context.Context, channel *models.AlertChannel) error {
+	return validateSecret(ctx, n.secrets, channel)
+}
+
+func (n *slackNotifier) Send(ctx context.Context, channel *models.AlertChannel, alert *models.Alert) error {
+	return n.sender.Notify(ctx, nil, channel.Secret, subject(alert), text(alert))
+}
+
+// webhookNotifier 以告警的JSON发送到密钥中保存的 Webhook 地址
+type webhookNotifier struct {
+	secrets    *secrets.Store
+	httpClient *http.Client
+}
+
+// newWebhookNotifier 创建 Webhook 通知渠道
+func newWebhookNotifier(secretStore *secrets.Store) *webhookNotifier {
+	return &webhookNotifier{
+		secrets:    secretStore,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (n *webhookNotifier) Validate(ctx context.Context, channel *models.AlertChannel) error {
+	return validateSecret(ctx, n.secrets, channel)
+}
+
+func (n *webhookNotifier) Send(ctx context.Context, channel *models.AlertChannel, alert *models.Alert) error {
+	url, err := webhookURL(ctx, n.secrets, channel.Secret)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		// 错误信息中的地址可能包含令牌
+		return fmt.Errorf("request failed: %v", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// validateSecret 校验渠道的密钥已存在且为 http 或 https 地址
+func validateSecret(ctx context.Context, secretStore *secrets.Store, channel *models.AlertChannel) error {
+	if channel.Secret == "" {
+		return fmt.Errorf("secret is required for %s channels", channel.Type)
+	}
+	_, err := webhookURL(ctx, secretStore, channel.Secret)
+	return err
+}
+
+// webhookURL 读取密钥中保存的 Webhook 地址
+func webhookURL(ctx context.Context, secretStore *secrets.Store, name string) (string, error) {
+	url, err := secretStore.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "", fmt.Errorf("secret %s must contain an http or https URL", name)
+	}
+	return url, nil
+}
@@ -0,0 +1,325 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nsa/internal/logger"
+	"nsa/internal/mongodb"
+
+	"github.com/nsqio/go-nsq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Options 压测参数
+type Options struct {
+	NSQDAddress string        // 发布消息的nsqd TCP地址
+	Topic       string        // 压测topic，需要已配置启用的工作流
+	Rate        int           // 每秒发布的消息数
+	Duration    time.Duration // 发布持续时间
+	PayloadSize int           // 消息中填充数据的字节数
+	Wait        time.Duration // 发布结束后等待实例结束的最长时间
+}
+
+// Distribution 延迟分布(毫秒)
+type Distribution struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// Report 压测结果
+type Report struct {
+	RunID         string  `json:"run_id"`
+	Topic         string  `json:"topic"`
+	Published     int     `json:"published"`
+	PublishErrors int     `json:"publish_errors"`
+	Completed     int     `json:"completed"`
+	Failed        int     `json:"failed"`
+	Missing       int     `json:"missing"` // 等待超时仍未结束的消息数
+	PublishRate   float64 `json:"publish_rate"`
+	// Throughput 实例结束的速率(每秒)，从第一条消息发布到最后一个实例结束
+	Throughput float64 `json:"throughput"`
+	// EndToEnd 从发布消息到实例结束
+	EndToEnd Distribution `json:"end_to_end"`
+	// QueueWait 从发布消息到实例开始执行，持续增长说明执行器已饱和，消息在NSQ中积压
+	QueueWait Distribution `json:"queue_wait"`
+	// Execution 实例执行时间
+	Execution Distribution `json:"execution"`
+	// MaxRunning 采样到的同时执行中的实例数最大值
+	MaxRunning int64 `json:"max_running"`
+	// MaxBacklog 采样到的已发布但未开始执行的消息数最大值
+	MaxBacklog int64 `json:"max_backlog"`
+}
+
+// Runner 压测执行器，按固定速率向压测topic发布合成消息，通过工作流实例记录统计端到端延迟
+//
+// 需要有正在运行的服务消费压测topic；压测消息带有 bench_run 字段，工作流可以按需使用。
+type Runner struct {
+	opts    Options
+	logger  logger.Logger
+	mongoDB *mongodb.Client
+}
+
+// NewRunner 创建压测执行器
+func NewRunner(opts Options, logger logger.Logger, mongoClient *mongodb.Client) *Runner {
+	return &Runner{opts: opts, logger: logger, mongoDB: mongoClient}
+}
+
+// benchInstance 压测实例的时间记录
+type benchInstance struct {
+	Status    string    `bson:"status"`
+	StartTime time.Time `bson:"start_time"`
+	EndTime   time.Time `bson:"end_time"`
+	Message   struct {
+		Data struct {
+			SentAt float64 `bson:"sent_at"` // 消息体按JSON解析，数字保存为double
+		} `bson:"data"`
+	} `bson:"message"`
+}
+
+// Run 执行压测：发布消息并采样执行器状态，发布结束后等待实例结束，返回统计结果
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	if err := r.validate(ctx); err != nil {
+		return nil, err
+	}
+
+	producer, err := nsq.NewProducer(r.opts.NSQDAddress, nsq.NewConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NSQ producer: %v", err)
+	}
+	producer.SetLogger(nil, nsq.LogLevelError)
+	defer producer.Stop()
+	if err := producer.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to connect to nsqd %s: %v", r.opts.NSQDAddress, err)
+	}
+
+	report := &Report{RunID: primitive.NewObjectID().Hex(), Topic: r.opts.Topic}
+	r.logger.Infof("Benchmark %s: publishing %d msg/s to %s for %s", report.RunID, r.opts.Rate, r.opts.Topic, r.opts.Duration)
+
+	sampleCtx, stopSampling := context.WithCancel(ctx)
+	var published int64
+	var mu sync.Mutex
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		r.sample(sampleCtx, report, func() int64 {
+			mu.Lock()
+			defer mu.Unlock()
+			return published
+		})
+	}()
+
+	start := time.Now()
+	payload := strings.Repeat("x", r.opts.PayloadSize)
+	interval := time.Second / time.Duration(r.opts.Rate)
+	ticker := time.NewTicker(interval)
+	deadline := time.NewTimer(r.opts.Duration)
+publish:
+	for seq := 0; ; seq++ {
+		select {
+		case <-ctx.Done():
+			break publish
+		case <-deadline.C:
+			break publish
+		case <-ticker.C:
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"bench_run": report.RunID,
+			"seq":       seq,
+			"sent_at":   time.Now().UnixNano(),
+			"payload":   payload,
+		})
+		if err := producer.Publish(r.opts.Topic, body); err != nil {
+			report.PublishErrors++
+			continue
+		}
+		mu.Lock()
+		published++
+		mu.Unlock()
+	}
+	ticker.Stop()
+	deadline.Stop()
+	report.Published = int(published)
+	report.PublishRate = float64(report.Published) / time.Since(start).Seconds()
+	r.logger.Infof("Benchmark %s: published %d messages (%d errors), waiting for instances", report.RunID, report.Published, report.PublishErrors)
+
+	instances, err := r.wait(ctx, report.RunID, report.Published)
+	stopSampling()
+	<-sampled
+	if err != nil {
+		return nil, err
+	}
+
+	var endToEnd, queueWait, execution []float64
+	var lastEnd time.Time
+	for _, instance := range instances {
+		if instance.Status == "completed" {
+			report.Completed++
+		} else {
+			report.Failed++
+		}
+		sentAt := time.Unix(0, int64(instance.Message.Data.SentAt))
+		endToEnd = append(endToEnd, milliseconds(instance.EndTime.Sub(sentAt)))
+		queueWait = append(queueWait, milliseconds(instance.StartTime.Sub(sentAt)))
+		execution = append(execution, milliseconds(instance.EndTime.Sub(instance.StartTime)))
+		if instance.EndTime.After(lastEnd) {
+			lastEnd = instance.EndTime
+		}
+	}
+	report.Missing = report.Published - len(instances)
+	if len(instances) > 0 {
+		report.Throughput = float64(len(instances)) / lastEnd.Sub(start).Seconds()
+	}
+	report.EndToEnd = distribution(endToEnd)
+	report.QueueWait = distribution(queueWait)
+	report.Execution = distribution(execution)
+	return report, nil
+}
+
+// validate 检查参数和压测topic的工作流
+func (r *Runner) validate(ctx context.Context) error {
+	if r.opts.NSQDAddress == "" {
+		return fmt.Errorf("nsqd address is required")
+	}
+	if r.opts.Topic == "" {
+		return fmt.Errorf("topic is required")
+	}
+	if r.opts.Rate <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	if r.opts.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+
+	ctxDB, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	count, err := r.mongoDB.GetCollection().CountDocuments(ctxDB, bson.M{"topic": r.opts.Topic, "enabled": true})
+	if err != nil {
+		return fmt.Errorf("failed to find workflows for topic %s: %v", r.opts.Topic, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("no enabled workflow consumes topic %s", r.opts.Topic)
+	}
+	return nil
+}
+
+// sample 每秒采样执行中的实例数和积压的消息数
+func (r *Runner) sample(ctx context.Context, report *Report, published func() int64) {
+	collection := r.mongoDB.GetDatabase().Collection("workflow_instances")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctxDB, cancel := context.WithTimeout(ctx, 5*time.Second)
+		running, err := collection.CountDocuments(ctxDB, bson.M{"message.data.bench_run": report.RunID, "status": "running"})
+		if err != nil {
+			cancel()
+			continue
+		}
+		started, err := collection.CountDocuments(ctxDB, bson.M{"message.data.bench_run": report.RunID})
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		if running > report.MaxRunning {
+			report.MaxRunning = running
+		}
+		if backlog := published() - started; backlog > report.MaxBacklog {
+			report.MaxBacklog = backlog
+		}
+	}
+}
+
+// wait 等待压测消息的实例结束，超过等待时间时返回已结束的实例
+func (r *Runner) wait(ctx context.Context, runID string, published int) ([]benchInstance, error) {
+	collection := r.mongoDB.GetDatabase().Collection("workflow_instances")
+	filter := bson.M{
+		"message.data.bench_run": runID,
+		"status":                 bson.M{"$in": bson.A{"completed", "failed", "interrupted", "cancelled"}},
+	}
+	projection := options.Find().SetProjection(bson.M{"status": 1, "start_time": 1, "end_time": 1, "message.data.sent_at": 1})
+
+	deadline := time.Now().Add(r.opts.Wait)
+	for {
+		ctxDB, cancel := context.WithTimeout(ctx, 30*time.Second)
+		finished, err := collection.CountDocuments(ctxDB, filter)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count benchmark instances: %v", err)
+		}
+		if int(finished) >= published || time.Now().After(deadline) || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cursor, err := collection.Find(ctxDB, filter, projection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load benchmark instances: %v", err)
+	}
+	var instances []benchInstance
+	if err := cursor.All(ctxDB, &instances); err != nil {
+		return nil, fmt.Errorf("failed to decode benchmark instances: %v", err)
+	}
+	return instances, nil
+}
+
+// distribution 计算延迟分布
+func distribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return Distribution{
+		Count: len(values),
+		Min:   values[0],
+		Mean:  sum / float64(len(values)),
+		P50:   percentile(values, 0.50),
+		P90:   percentile(values, 0.90),
+		P99:   percentile(values, 0.99),
+		Max:   values[len(values)-1],
+	}
+}
+
+// percentile 返回已排序数据的分位数（最近秩法）
+func percentile(sorted []float64, p float64) float64 {
+	index := int(float64(len(sorted))*p+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+// milliseconds 将时长转换为毫秒
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
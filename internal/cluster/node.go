@@ -0,0 +1,452 @@
+package cluster
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NodeInfo 节点的心跳记录
+type NodeInfo struct {
+	ID          string    `bson:"_id" json:"id"`
+	Hostname    string    `bson:"hostname" json:"hostname"`
+	PID         int       `bson:"pid" json:"pid"`
+	StartedAt   time.Time `bson:"started_at" json:"started_at"`
+	HeartbeatAt time.Time `bson:"heartbeat_at" json:"heartbeat_at"`
+	Role        string    `bson:"role,omitempty" json:"role,omitempty"` // 节点配置的部署角色
+	Alive       bool      `bson:"-" json:"alive"`
+}
+
+// Lease 租约，同一时间只有一个节点持有
+type Lease struct {
+	Name      string    `bson:"_id" json:"name"`
+	Owner     string    `bson:"owner" json:"owner"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+}
+
+// Node 当前服务节点，通过 nodes 集合的心跳和 leases 集合的租约与其他节点协调
+//
+// 未启用集群时所有租约直接视为已持有，不写入MongoDB。
+type Node struct {
+	id      string
+	role    string
+	enabled bool
+	ttl     time.Duration
+	logger  logger.Logger
+	mongoDB *mongodb.Client
+
+	mu        sync.Mutex
+	onDown    func(ctx context.Context, nodeID string)
+	onMembers func()
+	members   []string // 心跳未超时的节点，按ID排序，始终包含当前节点
+	started   time.Time
+	stop      chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewNode 创建当前服务节点
+func NewNode(cfg config.ClusterConfig, logger logger.Logger, mongoClient *mongodb.Client) *Node {
+	return &Node{
+		id:      cfg.NodeID,
+		role:    cfg.Role,
+		enabled: cfg.Enabled,
+		ttl:     time.Duration(cfg.LeaseTTL) * time.Second,
+		logger:  logger,
+		mongoDB: mongoClient,
+		started: time.Now(),
+	}
+}
+
+// ID 返回节点标识
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Enabled 返回是否启用集群模式
+func (n *Node) Enabled() bool {
+	return n.enabled
+}
+
+// OnNodeDown 设置其他节点心跳超时时的处理函数，在持有该节点的接管租约时调用，处理完成后删除节点记录
+func (n *Node) OnNodeDown(fn func(ctx context.Context, nodeID string)) {
+	n.mu.Lock()
+	n.onDown = fn
+	n.mu.Unlock()
+}
+
+// OnMembersChanged 设置存活节点变化（节点加入或心跳超时）时的处理函数
+func (n *Node) OnMembersChanged(fn func()) {
+	n.mu.Lock()
+	n.onMembers = fn
+	n.mu.Unlock()
+}
+
+// Start 写入心跳并启动后台任务：每 ttl/3 续约心跳，每个 ttl 刷新存活节点并检查心跳超时的节点
+func (n *Node) Start() error {
+	if !n.enabled {
+		return nil
+	}
+	if err := n.heartbeat(context.Background()); err != nil {
+		return err
+	}
+	n.refreshMembers(context.Background())
+	n.logger.Infof("Cluster node %s started", n.id)
+
+	n.stop = make(chan struct{})
+	n.done = make(chan struct{})
+	go func() {
+		defer close(n.done)
+
+		heartbeat := time.NewTicker(n.ttl / 3)
+		defer heartbeat.Stop()
+		check := time.NewTicker(n.ttl)
+		defer check.Stop()
+
+		for {
+			select {
+			case <-n.stop:
+				return
+			case <-heartbeat.C:
+				if err := n.heartbeat(context.Background()); err != nil {
+					n.logger.Errorf("Cluster heartbeat failed: %v", err)
+				}
+			case <-check.C:
+				n.refreshMembers(context.Background())
+				n.takeOverDeadNodes(context.Background())
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止心跳并释放持有的租约
+//
+// 节点记录保留：同一个节点重启后恢复自己的实例，不再启动时由其他节点在心跳超时后接管。
+func (n *Node) Stop() {
+	if !n.enabled || n.stop == nil {
+		return
+	}
+	n.stopOnce.Do(func() {
+		close(n.stop)
+		<-n.done
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := n.leases().DeleteMany(ctx, bson.M{"owner": n.id}); err != nil {
+			n.logger.Errorf("Failed to release leases: %v", err)
+		}
+	})
+}
+
+// Nodes 返回所有节点的心跳记录，心跳未超时的节点 Alive 为 true
+func (n *Node) Nodes(ctx context.Context) ([]NodeInfo, error) {
+	if !n.enabled {
+		hostname, _ := os.Hostname()
+		return []NodeInfo{{ID: n.id, Hostname: hostname, PID: os.Getpid(), StartedAt: n.started, HeartbeatAt: time.Now(), Alive: true}}, nil
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := n.nodes().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []NodeInfo{}
+	if err := cursor.All(ctx, &nodes); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(-n.ttl)
+	for i := range nodes {
+		nodes[i].Alive = nodes[i].HeartbeatAt.After(deadline)
+	}
+	return nodes, nil
+}
+
+// Owns 判断当前节点是否负责指定的键，未启用集群时负责所有键
+//
+// 使用 rendezvous 哈希在存活节点中选出得分最高的节点：节点加入或离开时只有该节点负责的键会移动。
+func (n *Node) Owns(key string) bool {
+	if !n.enabled {
+		return true
+	}
+	n.mu.Lock()
+	members := n.members
+	n.mu.Unlock()
+	if len(members) == 0 {
+		return true
+	}
+
+	owner, best := "", uint64(0)
+	for _, member := range members {
+		if score := rendezvousScore(member, key); owner == "" || score > best {
+			owner, best = member, score
+		}
+	}
+	return owner == n.id
+}
+
+// rendezvousScore 返回节点对键的哈希得分
+func rendezvousScore(member, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(member))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	// fnv 的低位分布不均，再混合一次
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	return x
+}
+
+// Acquire 获取或续约租约，其他节点持有未过期的租约时返回false
+func (n *Node) Acquire(ctx context.Context, name string) (bool, error) {
+	if !n.enabled {
+		return true, nil
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"owner": n.id},
+			bson.M{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": n.id, "expires_at": now.Add(n.ttl)}}
+	_, err := n.leases().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// 租约存在且由其他节点持有，upsert 插入同一个 _id 失败
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release 释放当前节点持有的租约
+func (n *Node) Release(ctx context.Context, name string) error {
+	if !n.enabled {
+		return nil
+	}
+	_, err := n.leases().DeleteOne(ctx, bson.M{"_id": name, "owner": n.id})
+	return err
+}
+
+// Hold 持有租约期间执行fn，阻塞直到ctx取消
+//
+// 未获得租约时每 ttl/3 重试；持有期间定期续约，续约失败（租约被其他节点获得或数据库不可用超过有效期）时
+// 取消传给fn的ctx，fn返回后重新竞争租约。
+func (n *Node) Hold(ctx context.Context, name string, fn func(ctx context.Context)) {
+	if !n.enabled {
+		fn(ctx)
+		return
+	}
+
+	interval := n.ttl / 3
+	for {
+		acquired, err := n.Acquire(ctx, name)
+		if err != nil && ctx.Err() == nil {
+			n.logger.Errorf("Failed to acquire lease %s: %v", name, err)
+		}
+		if acquired {
+			n.logger.Infof("Acquired lease %s", name)
+			n.holding(ctx, name, interval, fn)
+			n.logger.Infof("Released lease %s", name)
+
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := n.Release(releaseCtx, name); err != nil {
+				n.logger.Errorf("Failed to release lease %s: %v", name, err)
+			}
+			cancel()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// holding 执行fn并定期续约，续约失败时取消fn的ctx，等待fn返回
+func (n *Node) holding(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context)) {
+	leaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		fn(leaseCtx)
+	}()
+
+	renew := time.NewTicker(interval)
+	defer renew.Stop()
+	expiresAt := time.Now().Add(n.ttl)
+	for {
+		select {
+		case <-finished:
+			return
+		case <-renew.C:
+			acquired, err := n.Acquire(leaseCtx, name)
+			if acquired {
+				expiresAt = time.Now().Add(n.ttl)
+				continue
+			}
+			if err != nil && time.Now().Before(expiresAt.Add(-interval)) {
+				// 数据库暂时不可用，租约到期前继续持有
+				n.logger.Warnf("Failed to renew lease %s: %v", name, err)
+				continue
+			}
+			n.logger.Warnf("Lost lease %s", name)
+			cancel()
+			<-finished
+			return
+		}
+	}
+}
+
+// heartbeat 写入当前节点的心跳
+func (n *Node) heartbeat(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hostname, _ := os.Hostname()
+	update := bson.M{
+		"$set": bson.M{
+			"hostname":     hostname,
+			"pid":          os.Getpid(),
+			"started_at":   n.started,
+			"heartbeat_at": time.Now(),
+			"role":         n.role,
+		},
+	}
+	_, err := n.nodes().UpdateOne(ctx, bson.M{"_id": n.id}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// refreshMembers 刷新存活节点列表，发生变化时调用 onMembers
+func (n *Node) refreshMembers(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cursor, err := n.nodes().Find(ctx, bson.M{"heartbeat_at": bson.M{"$gte": time.Now().Add(-n.ttl)}})
+	if err != nil {
+		n.logger.Errorf("Failed to find cluster members: %v", err)
+		return
+	}
+	var alive []NodeInfo
+	if err := cursor.All(ctx, &alive); err != nil {
+		n.logger.Errorf("Failed to decode cluster members: %v", err)
+		return
+	}
+	members := []string{n.id}
+	for _, node := range alive {
+		if node.ID != n.id {
+			members = append(members, node.ID)
+		}
+	}
+	sort.Strings(members)
+
+	n.mu.Lock()
+	changed := !equalMembers(n.members, members)
+	previous := n.members
+	n.members = members
+	onMembers := n.onMembers
+	n.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if previous != nil {
+		n.logger.Infof("Cluster members changed: %v -> %v", previous, members)
+	}
+	if onMembers != nil {
+		onMembers()
+	}
+}
+
+// equalMembers 比较两个有序的节点列表
+func equalMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// takeOverDeadNodes 接管心跳超时的节点：持有接管租约时调用 onDown，完成后删除节点记录
+func (n *Node) takeOverDeadNodes(ctx context.Context) {
+	n.mu.Lock()
+	onDown := n.onDown
+	n.mu.Unlock()
+	if onDown == nil {
+		return
+	}
+
+	findCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	filter := bson.M{"_id": bson.M{"$ne": n.id}, "heartbeat_at": bson.M{"$lt": time.Now().Add(-n.ttl)}}
+	cursor, err := n.nodes().Find(findCtx, filter)
+	if err != nil {
+		n.logger.Errorf("Failed to find dead cluster nodes: %v", err)
+		return
+	}
+	var dead []NodeInfo
+	if err := cursor.All(findCtx, &dead); err != nil {
+		n.logger.Errorf("Failed to decode cluster nodes: %v", err)
+		return
+	}
+
+	for _, node := range dead {
+		lease := TakeoverLease(node.ID)
+		acquired, err := n.Acquire(ctx, lease)
+		if err != nil || !acquired {
+			continue
+		}
+
+		n.logger.Warnf("Cluster node %s is down (last heartbeat %s), taking over", node.ID, node.HeartbeatAt.Format(time.RFC3339))
+		onDown(ctx, node.ID)
+
+		cleanupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		// 节点在接管期间恢复心跳时保留记录
+		if _, err := n.nodes().DeleteOne(cleanupCtx, bson.M{"_id": node.ID, "heartbeat_at": node.HeartbeatAt}); err != nil {
+			n.logger.Errorf("Failed to remove cluster node %s: %v", node.ID, err)
+		}
+		if err := n.Release(cleanupCtx, lease); err != nil {
+			n.logger.Errorf("Failed to release lease %s: %v", lease, err)
+		}
+		cancel()
+	}
+}
+
+// TakeoverLease 返回接管节点实例时持有的租约名称，节点启动恢复自己的实例时也需要持有
+func TakeoverLease(nodeID string) string {
+	return "takeover:" + nodeID
+}
+
+// nodes 返回节点心跳集合
+func (n *Node) nodes() *mongo.Collection {
+	return n.mongoDB.GetDatabase().Collection("nodes")
+}
+
+// leases 返回租约集合
+func (n *Node) leases() *mongo.Collection {
+	return n.mongoDB.GetDatabase().Collection("leases")
+}
@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 部署角色的状态
+const (
+	// StateActive 消费NSQ消息，运行触发器、工作队列、报告和数据清理
+	StateActive = "active"
+	// StateStandby 备用部署，只提供管理接口，不消费消息也不运行后台任务
+	StateStandby = "standby"
+	// StatePromoting 正在启动消费者和后台任务
+	StatePromoting = "promoting"
+)
+
+// Transition 部署角色的状态转换
+type Transition struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Trigger string `json:"trigger"`
+}
+
+// Transitions 部署角色的状态机；active 不会自动回到 standby，降级需要修改 cluster.role 后重启
+var Transitions = []Transition{
+	{From: StateStandby, To: StatePromoting, Trigger: "POST /api/v1/system/cluster/promote"},
+	{From: StatePromoting, To: StateActive, Trigger: "consumers and schedules started"},
+	{From: StatePromoting, To: StateStandby, Trigger: "promotion failed"},
+}
+
+// ErrNotStandby 只有 standby 状态可以提升
+var ErrNotStandby = errors.New("deployment is not in standby")
+
+// RoleStatus 部署角色的状态
+type RoleStatus struct {
+	// Role 配置的部署角色
+	Role  string    `json:"role"`
+	State string    `json:"state"`
+	Since time.Time `json:"since"`
+	// PromotedBy 执行提升的用户，未提升时为空
+	PromotedBy string     `json:"promoted_by,omitempty"`
+	PromotedAt *time.Time `json:"promoted_at,omitempty"`
+	// LastError 最近一次提升失败的错误
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Role 当前部署的角色
+//
+// 状态只保存在进程内存中：提升后的 standby 部署重启时回到配置的角色，故障切换完成后需要将 cluster.role 改为 active。
+type Role struct {
+	mu       sync.Mutex
+	status   RoleStatus
+	activate func() error
+}
+
+// NewRole 按配置的角色创建部署角色，activate 启动消费者和后台任务，在提升时调用
+func NewRole(role string, activate func() error) *Role {
+	state := StateActive
+	if role == StateStandby {
+		state = StateStandby
+	}
+	return &Role{
+		status:   RoleStatus{Role: role, State: state, Since: time.Now()},
+		activate: activate,
+	}
+}
+
+// Active 返回当前部署是否为 active
+func (r *Role) Active() bool {
+	return r.Status().State == StateActive
+}
+
+// Status 返回部署角色的状态
+func (r *Role) Status() RoleStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Promote 将 standby 部署提升为 active：启动消费者和后台任务，失败时回到 standby
+func (r *Role) Promote(by string) error {
+	r.mu.Lock()
+	if r.status.State != StateStandby {
+		state := r.status.State
+		r.mu.Unlock()
+		return fmt.Errorf("%w: current state is %s", ErrNotStandby, state)
+	}
+	r.setState(StatePromoting)
+	r.mu.Unlock()
+
+	err := r.activate()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.status.LastError = err.Error()
+		r.setState(StateStandby)
+		return err
+	}
+	now := time.Now()
+	r.status.LastError = ""
+	r.status.PromotedBy = by
+	r.status.PromotedAt = &now
+	r.setState(StateActive)
+	return nil
+}
+
+// setState 切换状态，调用方持有锁
+func (r *Role) setState(state string) {
+	r.status.State = state
+	r.status.Since = time.Now()
+}
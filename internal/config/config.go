@@ -1,23 +1,66 @@
 package config
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
+// minJWTSecretLength JWT签名密钥最小长度
+const minJWTSecretLength = 32
+
+// minIngestTokenLength 事件接入令牌最小长度
+const minIngestTokenLength = 16
+
 // Config 应用配置结构
 type Config struct {
-	Server  ServerConfig  `json:"server"`
-	MongoDB MongoDBConfig `json:"mongodb"`
-	Logging LoggingConfig `json:"logging"`
-	Admin   AdminConfig   `json:"admin"`
-	NSQ     NSQConfig     `json:"nsq"`
+	Server    ServerConfig    `json:"server"`
+	MongoDB   MongoDBConfig   `json:"mongodb"`
+	Logging   LoggingConfig   `json:"logging"`
+	Admin     AdminConfig     `json:"admin"`
+	NSQ       NSQConfig       `json:"nsq"`
+	Retention RetentionConfig `json:"retention"`
+	Files     FilesConfig     `json:"files"`
+	Command   CommandConfig   `json:"command"`
+	Actions   ActionsConfig   `json:"actions"`
+	Ingest    IngestConfig    `json:"ingest"`
+	SNMPTrap  SNMPTrapConfig  `json:"snmp_trap"`
+	Syslog    SyslogConfig    `json:"syslog"`
+	Cluster   ClusterConfig   `json:"cluster"`
+	Chaos     ChaosConfig     `json:"chaos"`
+	// CircuitBreaker 数据源和HTTP目标的熔断配置
+	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+	// WorkQueue NSQ消息与执行器之间的持久化工作队列
+	WorkQueue WorkQueueConfig `json:"work_queue"`
+	// DataSourceHealth 数据源连接健康检查
+	DataSourceHealth DataSourceHealthConfig `json:"datasource_health"`
+	// HTTPClient HTTP节点共享的连接池
+	HTTPClient HTTPClientConfig `json:"http_client"`
+	// InstanceExport 结束的实例摘要导出到NSQ或HTTP端点，供数据仓库接入
+	InstanceExport InstanceExportConfig `json:"instance_export"`
+	// JS JS Function 节点的运行时池
+	JS JSConfig `json:"js"`
+	// Reports 定时发送的工作流健康报告
+	Reports ReportsConfig `json:"reports"`
+	// Alerts 工作流失败和执行时间的告警规则评估
+	Alerts AlertsConfig `json:"alerts"`
+	// Encryption MongoDB中存储的消息数据、执行日志和实例数据的加密配置
+	Encryption EncryptionConfig `json:"encryption"`
+
+	file string // 加载配置的文件路径，用于重新加载
 }
 
 // ServerConfig HTTP服务器配置
 type ServerConfig struct {
 	Port int    `json:"port"`
 	Mode string `json:"mode"`
+	// GRPCPort gRPC管理接口的端口，为0时不启用
+	GRPCPort int `json:"grpc_port"`
 }
 
 // MongoDBConfig MongoDB配置
@@ -25,6 +68,8 @@ type MongoDBConfig struct {
 	DSN        string `json:"dsn"`
 	Database   string `json:"database"`
 	Collection string `json:"collection"`
+	// RetryAttempts 短暂错误（网络错误、主节点切换）时元数据读写的最大执行次数，默认8，为1时不重试
+	RetryAttempts int `json:"retry_attempts"`
 }
 
 // LoggingConfig 日志配置
@@ -32,6 +77,17 @@ type LoggingConfig struct {
 	Level     string          `json:"level"`
 	LocalLogs LocalLogsConfig `json:"local_logs"`
 	Graylog   GraylogConfig   `json:"graylog"`
+	// Security 安全事件日志，与应用日志分开输出
+	Security SecurityLogConfig `json:"security"`
+}
+
+// SecurityLogConfig 安全事件日志配置
+type SecurityLogConfig struct {
+	Enabled bool `json:"enabled"`
+	// Path 安全事件日志文件路径（JSON行），为空时输出到标准输出
+	Path string `json:"path"`
+	// Graylog 安全事件单独发送的Graylog地址，未启用时发送到 logging.graylog
+	Graylog GraylogConfig `json:"graylog"`
 }
 
 // LocalLogsConfig 本地日志配置
@@ -53,29 +109,849 @@ type AdminConfig struct {
 	Username   string `json:"username"`
 	Password   string `json:"password"`
 	JWTSecret  string `json:"jwt_secret"`
+	// JWTKeys 有序的签名密钥列表，第一个用于签发新令牌，其余只用于验证，用于平滑轮换密钥
+	JWTKeys []JWTKey `json:"jwt_keys"`
+	// 访问令牌有效期(秒)，默认2小时
+	AccessTokenTTL int `json:"access_token_ttl"`
+	// 刷新令牌有效期(秒)，默认7天
+	RefreshTokenTTL int        `json:"refresh_token_ttl"`
+	OIDC            OIDCConfig `json:"oidc"`
+	// ExecutionDataRoles 可以查看执行数据（任务输入输出、实例变量和结果）的角色，为空时所有角色都可以查看
+	ExecutionDataRoles []string `json:"execution_data_roles"`
+	// Timezone API响应中时间的默认时区（IANA名称，如 Asia/Shanghai），默认UTC；用户和请求可以单独指定
+	Timezone string `json:"timezone"`
+}
+
+// JWTKey JWT签名密钥，ID写入令牌的 kid 头
+type JWTKey struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// SigningKeys 返回验证令牌的密钥，第一个为签名密钥
+//
+// jwt_secret 作为ID为空的密钥排在 jwt_keys 之后，用于验证没有 kid 头的令牌；未配置 jwt_keys 时用它签名。
+func (a AdminConfig) SigningKeys() []JWTKey {
+	keys := append([]JWTKey{}, a.JWTKeys...)
+	if a.JWTSecret != "" {
+		keys = append(keys, JWTKey{Secret: a.JWTSecret})
+	}
+	return keys
+}
+
+// OIDCConfig OIDC单点登录配置
+type OIDCConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"` // 回调地址，如 https://nsa.example.com/auth/oidc/callback
+	Scopes       []string `json:"scopes"`
+	GroupsClaim  string   `json:"groups_claim"` // 组信息所在的claim，默认groups
+	// RoleMapping IdP组到NSA角色的映射，如 {"nsa-admins": "admin"}
+	RoleMapping map[string]string `json:"role_mapping"`
+	// DefaultRole 未匹配任何组时的角色，为空则拒绝登录
+	DefaultRole string `json:"default_role"`
+	// PostLoginRedirect 登录成功后跳转的前端地址，令牌通过URL fragment传递；为空则直接返回JSON
+	PostLoginRedirect string `json:"post_login_redirect"`
 }
 
 // NSQConfig NSQ配置
 type NSQConfig struct {
 	LookupdAddresses []string `json:"lookupd_addresses"`
 	NSQDAddresses    []string `json:"nsqd_addresses"`
+	// NSQDHTTPAddresses nsqd HTTP接口地址（host:port 或 http(s):// 地址），用于查询channel积压；为空时通过nsqlookupd发现
+	NSQDHTTPAddresses []string `json:"nsqd_http_addresses"`
+	// DirectConnect 为true时消费者直接连接 nsqd_addresses 中的所有nsqd，不通过nsqlookupd发现，此时 lookupd_addresses 可以为空
+	DirectConnect bool `json:"direct_connect"`
+	// AuthSecret nsqd认证密钥（nsqd 配置了 --auth-http-address 时需要），同时作为查询nsqlookupd的 Bearer 令牌
+	AuthSecret string `json:"auth_secret"`
+	// Compression 连接压缩：snappy 或 deflate，为空时不压缩，需要nsqd开启对应的压缩支持
+	Compression string `json:"compression"`
+	// DeflateLevel deflate 压缩级别(1-9)，默认6
+	DeflateLevel int `json:"deflate_level"`
+	// TLS 连接nsqd的TLS配置
+	TLS NSQTLSConfig `json:"tls"`
+	// Concurrency 每个消费者同时执行的实例数上限，默认100；启用工作队列时由 work_queue.workers 控制
+	Concurrency int `json:"concurrency"`
+	// QueueTimeout 消息等待执行的最长时间(秒)，超过后重新入队，默认300；不应超过nsqd的 --max-msg-timeout
+	QueueTimeout int `json:"queue_timeout"`
+	// MaxMessageSize 消息体的最大字节数，默认1048576（与nsqd的 --max-msg-size 默认值相同）；超过的消息不执行
+	MaxMessageSize int `json:"max_message_size"`
+	// DeadLetterTopic 超过 max_message_size 或失败重新入队后放弃的消息原样发布到的topic（通过 nsqd_addresses 的第一个地址），为空时丢弃并记录日志
+	DeadLetterTopic string `json:"dead_letter_topic"`
+	// MaxPayloadSize 消息通过 payload_ref 引用的外部数据的最大字节数，默认8388608
+	MaxPayloadSize int `json:"max_payload_size"`
+	// ReconcileInterval 按启用的工作流定期协调消费者和触发器的间隔(秒)，默认30；MongoDB支持 change stream 时工作流变化后立即协调
+	ReconcileInterval int `json:"reconcile_interval"`
+}
+
+// NSQTLSConfig 连接nsqd的TLS配置
+type NSQTLSConfig struct {
+	Enabled bool `json:"enabled"`
+	// CAFile 验证nsqd证书的CA证书文件，为空时使用系统根证书
+	CAFile string `json:"ca_file"`
+	// CertFile、KeyFile 客户端证书和私钥，nsqd 要求客户端证书（--tls-client-auth-policy）时需要
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// InsecureSkipVerify 不验证nsqd证书，仅用于测试环境
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// RetentionConfig 数据保留策略配置
+type RetentionConfig struct {
+	ExecutionLogs   RetentionPolicy `json:"execution_logs"`
+	Instances       RetentionPolicy `json:"workflow_instances"`
+	InstanceHistory RetentionPolicy `json:"instance_history"`
+	AlertHistory    RetentionPolicy `json:"alert_history"`
+	// 后台清理间隔(秒)，默认1小时
+	PurgeInterval int `json:"purge_interval"`
+}
+
+// RetentionPolicy 单个集合的保留策略，均为0表示永久保留
+type RetentionPolicy struct {
+	Days         int   `json:"days"`          // 保留天数，通过TTL索引过期
+	MaxDocuments int64 `json:"max_documents"` // 最多保留的文档数，超出部分由后台任务删除最旧的记录
+}
+
+// FilesConfig 文件节点配置
+type FilesConfig struct {
+	// BaseDir 文件节点可访问的根目录，节点中的路径均相对于该目录；为空时禁用文件节点
+	BaseDir string `json:"base_dir"`
+	// MaxReadSize 单次读取的最大字节数，默认10MB
+	MaxReadSize int64 `json:"max_read_size"`
+}
+
+// CommandConfig 命令节点配置
+type CommandConfig struct {
+	// Enabled 是否启用命令节点，默认禁用
+	Enabled bool `json:"enabled"`
+	// AllowedCommands 允许执行的程序，绝对路径或在PATH中查找的程序名，必须与节点的 command 参数完全一致
+	AllowedCommands []string `json:"allowed_commands"`
+	// AllowedEnv 节点可以通过 env 参数设置的环境变量名，不在列表中的变量被拒绝；
+	// 影响程序查找和加载的变量（见 DeniedCommandEnv）不能加入列表
+	AllowedEnv []string `json:"allowed_env"`
+	// WorkDir 命令的工作目录，为空时使用服务的当前目录
+	WorkDir string `json:"work_dir"`
+	// MaxOutputSize stdout和stderr各自保留的最大字节数，默认1MB
+	MaxOutputSize int `json:"max_output_size"`
+}
+
+// ActionsConfig 动作类型开关，按部署或项目禁用有风险的动作（如 CommandAction、SSHAction）
+//
+// 保存工作流和执行任务时检查；项目规则在全局规则之上进一步限制，不能重新允许全局禁止的动作。
+type ActionsConfig struct {
+	// Allowed 允许使用的动作类型，为空时允许所有动作
+	Allowed []string `json:"allowed"`
+	// Denied 禁止使用的动作类型，优先于 Allowed
+	Denied []string `json:"denied"`
+	// Projects 按项目的规则
+	Projects []ProjectActionsConfig `json:"projects"`
+}
+
+// ProjectActionsConfig 项目的动作类型开关
+type ProjectActionsConfig struct {
+	Project string   `json:"project"`
+	Allowed []string `json:"allowed"`
+	Denied  []string `json:"denied"`
+}
+
+// IngestConfig 事件接入配置（监控系统Webhook）
+type IngestConfig struct {
+	// Token 调用 /ingest 接口使用的Bearer令牌，为空时禁用事件接入
+	Token string `json:"token"`
+}
+
+// SNMPTrapConfig SNMP Trap接收配置
+type SNMPTrapConfig struct {
+	// Enabled 是否启用Trap接收，默认禁用
+	Enabled bool `json:"enabled"`
+	// ListenAddress UDP监听地址，默认 0.0.0.0:9162（监听162端口需要root权限或CAP_NET_BIND_SERVICE）
+	ListenAddress string `json:"listen_address"`
+	// Communities 接受的SNMPv1/v2c community，为空时接受任意community
+	Communities []string `json:"communities"`
+	// OIDs OID到名称的映射，用于解码Trap类型和变量绑定，补充内置的SNMPv2-MIB、IF-MIB定义
+	OIDs map[string]string `json:"oids"`
+}
+
+// SyslogConfig Syslog接收配置
+type SyslogConfig struct {
+	// Enabled 是否启用Syslog接收，默认禁用
+	Enabled bool `json:"enabled"`
+	// ListenAddress 监听地址，默认 0.0.0.0:5514
+	ListenAddress string `json:"listen_address"`
+	// Protocols 监听的协议，udp、tcp，默认两者都监听
+	Protocols []string `json:"protocols"`
+	// MaxMessageSize 单条消息的最大字节数，默认64KB
+	MaxMessageSize int `json:"max_message_size"`
+}
+
+// ClusterConfig 集群配置，多个服务副本共用同一个MongoDB库
+type ClusterConfig struct {
+	// Enabled 是否启用集群模式，默认禁用（单个服务进程）
+	Enabled bool `json:"enabled"`
+	// NodeID 节点标识，默认为主机名；重启后保持不变时节点恢复自己未完成的实例
+	NodeID string `json:"node_id"`
+	// LeaseTTL 心跳和租约的有效期(秒)，默认30；节点超过该时间没有心跳时由其他节点接管其实例和租约
+	LeaseTTL int `json:"lease_ttl"`
+	// PartitionConsumers 按 topic/channel 在存活节点间分配NSQ消费者，每个消费者只在一个节点上运行，默认禁用（所有节点消费所有topic）
+	PartitionConsumers bool `json:"partition_consumers"`
+	// Role 部署角色，active（默认）或 standby；standby 为异地备用部署，读取复制的MongoDB数据但不消费消息、不运行触发器和定时任务，通过提升接口切换为 active
+	Role string `json:"role"`
+}
+
+// ChaosConfig 故障注入配置
+type ChaosConfig struct {
+	// Enabled 是否启用故障注入接口，默认禁用；仅用于非生产环境验证重试、失败处理和告警
+	Enabled bool `json:"enabled"`
+}
+
+// CircuitBreakerConfig 熔断配置，按数据源和HTTP主机分别统计
+type CircuitBreakerConfig struct {
+	// Enabled 是否启用熔断，默认禁用
+	Enabled bool `json:"enabled"`
+	// FailureThreshold 连续失败多少次后熔断，默认5
+	FailureThreshold int `json:"failure_threshold"`
+	// OpenTimeout 熔断后多久(秒)进入半开状态放行探测请求，默认30
+	OpenTimeout int `json:"open_timeout"`
+	// HalfOpenProbes 半开状态同时放行的探测请求数，默认1
+	HalfOpenProbes int `json:"half_open_probes"`
+}
+
+// WorkQueueConfig 工作队列配置
+type WorkQueueConfig struct {
+	// Enabled 是否启用工作队列，默认禁用（NSQ消息直接执行）
+	Enabled bool `json:"enabled"`
+	// Workers 每个节点同时执行的实例数上限，默认32
+	Workers int `json:"workers"`
+	// MaxAttempts 启动执行失败（如数据库不可用）时的最大尝试次数，默认5，超过后标记为 failed
+	MaxAttempts int `json:"max_attempts"`
+	// RetryDelay 第一次重试前的等待时间(秒)，之后每次翻倍，默认5
+	RetryDelay int `json:"retry_delay"`
+}
+
+// DataSourceHealthConfig 数据源连接健康检查配置
+type DataSourceHealthConfig struct {
+	// Interval 检查SQL和MongoDB连接的间隔(秒)，默认30，为负数时禁用
+	Interval int `json:"interval"`
+	// MaxBackoff 连接不可用时重新连接的最长间隔(秒)，默认300
+	MaxBackoff int `json:"max_backoff"`
+}
+
+// HTTPClientConfig HTTP节点共享连接池配置
+type HTTPClientConfig struct {
+	// MaxIdleConns 所有主机的空闲连接总数上限，默认100
+	MaxIdleConns int `json:"max_idle_conns"`
+	// MaxIdleConnsPerHost 每个主机保留的空闲连接数，默认10
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+	// MaxConnsPerHost 每个主机的连接总数上限（包括使用中的），默认0不限制
+	MaxConnsPerHost int `json:"max_conns_per_host"`
+	// IdleConnTimeout 空闲连接保留时间(秒)，默认90
+	IdleConnTimeout int `json:"idle_conn_timeout"`
+	// KeepAlive TCP keep-alive探测间隔(秒)，默认30，为负数时禁用
+	KeepAlive int `json:"keep_alive"`
+	// DisableHTTP2 禁用HTTP/2，默认对HTTPS目标协商HTTP/2
+	DisableHTTP2 bool `json:"disable_http2"`
+	// MaxTransports 按代理和TLS参数区分的连接池数量上限，超过时关闭最久未使用的，默认64
+	MaxTransports int `json:"max_transports"`
+}
+
+// JSConfig JS Function 节点的运行时池配置
+type JSConfig struct {
+	// PoolSize 运行时数量，即同时执行的JS节点数上限，默认4
+	PoolSize int `json:"pool_size"`
+	// MemoryLimit 每个运行时的内存上限(MB)，默认64
+	MemoryLimit int `json:"memory_limit"`
+	// MaxExecutions 运行时执行多少次后重建，释放累积的内存，默认1000
+	MaxExecutions int `json:"max_executions"`
+}
+
+// ReportsConfig 工作流健康报告配置
+type ReportsConfig struct {
+	// CheckInterval 检查到期报告的间隔(秒)，默认60
+	CheckInterval int `json:"check_interval"`
+	// SMTP 发送邮件报告的服务器，未配置 host 时报告只能发送到聊天 Webhook
+	SMTP SMTPConfig `json:"smtp"`
+}
+
+// AlertsConfig 告警规则评估配置，邮件告警使用 reports.smtp 发送
+type AlertsConfig struct {
+	// EvalInterval 评估告警规则的间隔(秒)，默认30
+	EvalInterval int `json:"eval_interval"`
+}
+
+// SMTPConfig SMTP服务器配置
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"` // 默认587
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// From 发件人地址
+	From string `json:"from"`
+	// TLS 使用隐式TLS连接（通常为465端口），否则服务器支持时使用STARTTLS
+	TLS bool `json:"tls"`
+}
+
+// EncryptionConfig 存储加密配置，未配置密钥时明文存储
+type EncryptionConfig struct {
+	// Keys 有序的主密钥列表，第一个用于加密新数据，其余只用于解密，用于轮换密钥
+	Keys []EncryptionKey `json:"keys"`
+}
+
+// EncryptionKey 主密钥，ID随密文保存，用于解密时选择密钥
+type EncryptionKey struct {
+	ID string `json:"id"`
+	// Key base64编码的32字节密钥（AES-256），可以用 openssl rand -base64 32 生成
+	Key string `json:"key"`
+}
+
+// Decode 解码密钥
+func (k EncryptionKey) Decode() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(k.Key)
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64")
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// InstanceExportConfig 实例摘要导出配置
+type InstanceExportConfig struct {
+	Enabled bool `json:"enabled"`
+	// NSQTopic 发布实例摘要的topic，为空时不发布到NSQ
+	NSQTopic string `json:"nsq_topic"`
+	// NSQDAddress 发布使用的nsqd地址，为空时使用 nsq.nsqd_addresses 的第一个地址
+	NSQDAddress string `json:"nsqd_address"`
+	// URL 以POST接收实例摘要（JSON）的HTTP端点，为空时不发送
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Timeout HTTP请求超时(秒)，默认10
+	Timeout int `json:"timeout"`
+	// MaxAttempts 每条摘要的最大发送次数，默认3
+	MaxAttempts int `json:"max_attempts"`
+	// BufferSize 待发送摘要的缓冲数量，缓冲满时丢弃新摘要，默认1000
+	BufferSize int `json:"buffer_size"`
+	// Fields 摘要中附带的关键字段，键为字段名，值为模板路径（如 nsq.order_id、output.calc.total）
+	Fields map[string]string `json:"fields"`
 }
 
 // Load 从文件加载配置
+//
+// 配置文件不存在时仅使用环境变量。加载顺序：配置文件、NSA_* 环境变量覆盖、默认值，最后校验。
 func Load(filename string) (*Config, error) {
+	config := Config{file: filename}
+
 	data, err := os.ReadFile(filename)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+		}
+	}
+
+	if err := applyEnv(&config); err != nil {
 		return nil, err
 	}
+	config.applyDefaults()
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
 	return &config, nil
 }
 
+// applyDefaults 填充未配置字段的默认值
+func (c *Config) applyDefaults() {
+	if c.Server.Port == 0 {
+		c.Server.Port = 8080
+	}
+	if c.Server.Mode == "" {
+		c.Server.Mode = "release"
+	}
+	if c.MongoDB.Database == "" {
+		c.MongoDB.Database = "nsa"
+	}
+	if c.MongoDB.Collection == "" {
+		c.MongoDB.Collection = "configs"
+	}
+	if c.MongoDB.RetryAttempts == 0 {
+		c.MongoDB.RetryAttempts = 8
+	}
+	if c.Logging.Level == "" {
+		c.Logging.Level = "info"
+	}
+	if c.Logging.LocalLogs.Path == "" {
+		c.Logging.LocalLogs.Path = "./logs"
+	}
+	if c.Logging.Graylog.Port == 0 {
+		c.Logging.Graylog.Port = 12201
+	}
+	if c.Logging.Security.Graylog.Port == 0 {
+		c.Logging.Security.Graylog.Port = 12201
+	}
+	if c.NSQ.DeflateLevel == 0 {
+		c.NSQ.DeflateLevel = 6
+	}
+	if c.NSQ.Concurrency == 0 {
+		c.NSQ.Concurrency = 100
+	}
+	if c.NSQ.QueueTimeout == 0 {
+		c.NSQ.QueueTimeout = 300
+	}
+	if c.NSQ.MaxMessageSize == 0 {
+		c.NSQ.MaxMessageSize = 1 << 20
+	}
+	if c.NSQ.MaxPayloadSize == 0 {
+		c.NSQ.MaxPayloadSize = 8 << 20
+	}
+	if c.NSQ.ReconcileInterval == 0 {
+		c.NSQ.ReconcileInterval = 30
+	}
+	if c.Files.MaxReadSize == 0 {
+		c.Files.MaxReadSize = 10 * 1024 * 1024
+	}
+	if c.Command.MaxOutputSize == 0 {
+		c.Command.MaxOutputSize = 1024 * 1024
+	}
+	if c.SNMPTrap.ListenAddress == "" {
+		c.SNMPTrap.ListenAddress = "0.0.0.0:9162"
+	}
+	if c.Syslog.ListenAddress == "" {
+		c.Syslog.ListenAddress = "0.0.0.0:5514"
+	}
+	if len(c.Syslog.Protocols) == 0 {
+		c.Syslog.Protocols = []string{"udp", "tcp"}
+	}
+	if c.Syslog.MaxMessageSize == 0 {
+		c.Syslog.MaxMessageSize = 64 * 1024
+	}
+	if c.Cluster.NodeID == "" {
+		c.Cluster.NodeID, _ = os.Hostname()
+	}
+	if c.Cluster.LeaseTTL == 0 {
+		c.Cluster.LeaseTTL = 30
+	}
+	if c.Cluster.Role == "" {
+		c.Cluster.Role = "active"
+	}
+	if c.CircuitBreaker.FailureThreshold == 0 {
+		c.CircuitBreaker.FailureThreshold = 5
+	}
+	if c.CircuitBreaker.OpenTimeout == 0 {
+		c.CircuitBreaker.OpenTimeout = 30
+	}
+	if c.CircuitBreaker.HalfOpenProbes == 0 {
+		c.CircuitBreaker.HalfOpenProbes = 1
+	}
+	if c.WorkQueue.Workers == 0 {
+		c.WorkQueue.Workers = 32
+	}
+	if c.WorkQueue.MaxAttempts == 0 {
+		c.WorkQueue.MaxAttempts = 5
+	}
+	if c.WorkQueue.RetryDelay == 0 {
+		c.WorkQueue.RetryDelay = 5
+	}
+	if c.DataSourceHealth.Interval == 0 {
+		c.DataSourceHealth.Interval = 30
+	}
+	if c.DataSourceHealth.MaxBackoff == 0 {
+		c.DataSourceHealth.MaxBackoff = 300
+	}
+	if c.HTTPClient.MaxIdleConns == 0 {
+		c.HTTPClient.MaxIdleConns = 100
+	}
+	if c.HTTPClient.MaxIdleConnsPerHost == 0 {
+		c.HTTPClient.MaxIdleConnsPerHost = 10
+	}
+	if c.HTTPClient.IdleConnTimeout == 0 {
+		c.HTTPClient.IdleConnTimeout = 90
+	}
+	if c.HTTPClient.KeepAlive == 0 {
+		c.HTTPClient.KeepAlive = 30
+	}
+	if c.HTTPClient.MaxTransports == 0 {
+		c.HTTPClient.MaxTransports = 64
+	}
+	if c.InstanceExport.Timeout == 0 {
+		c.InstanceExport.Timeout = 10
+	}
+	if c.InstanceExport.MaxAttempts == 0 {
+		c.InstanceExport.MaxAttempts = 3
+	}
+	if c.InstanceExport.BufferSize == 0 {
+		c.InstanceExport.BufferSize = 1000
+	}
+	if c.JS.PoolSize == 0 {
+		c.JS.PoolSize = 4
+	}
+	if c.JS.MemoryLimit == 0 {
+		c.JS.MemoryLimit = 64
+	}
+	if c.JS.MaxExecutions == 0 {
+		c.JS.MaxExecutions = 1000
+	}
+	if c.Reports.CheckInterval == 0 {
+		c.Reports.CheckInterval = 60
+	}
+	if c.Reports.SMTP.Port == 0 {
+		c.Reports.SMTP.Port = 587
+	}
+	if c.Alerts.EvalInterval == 0 {
+		c.Alerts.EvalInterval = 30
+	}
+}
+
+// ValidationError 配置校验错误，包含全部不合法的字段
+type ValidationError struct {
+	Problems []string
+}
+
+// Error 实现error接口
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		addf("server.port must be between 1 and 65535 (NSA_SERVER_PORT), got %d", c.Server.Port)
+	}
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		addf("server.mode must be debug, release or test (NSA_SERVER_MODE), got %q", c.Server.Mode)
+	}
+	if c.Server.GRPCPort < 0 || c.Server.GRPCPort > 65535 {
+		addf("server.grpc_port must be between 0 and 65535 (NSA_SERVER_GRPC_PORT), got %d", c.Server.GRPCPort)
+	} else if c.Server.GRPCPort != 0 && c.Server.GRPCPort == c.Server.Port {
+		addf("server.grpc_port must differ from server.port (NSA_SERVER_GRPC_PORT), both are %d", c.Server.Port)
+	}
+
+	if c.MongoDB.DSN == "" {
+		addf("mongodb.dsn is required (NSA_MONGODB_DSN)")
+	} else if !strings.HasPrefix(c.MongoDB.DSN, "mongodb://") && !strings.HasPrefix(c.MongoDB.DSN, "mongodb+srv://") {
+		addf("mongodb.dsn must start with mongodb:// or mongodb+srv:// (NSA_MONGODB_DSN)")
+	}
+	if c.MongoDB.RetryAttempts < 1 {
+		addf("mongodb.retry_attempts must be at least 1 (NSA_MONGODB_RETRY_ATTEMPTS)")
+	}
+
+	switch strings.ToLower(c.Logging.Level) {
+	case "panic", "fatal", "error", "warn", "warning", "info", "debug", "trace":
+	default:
+		addf("logging.level %q is not a valid level (NSA_LOGGING_LEVEL)", c.Logging.Level)
+	}
+	if c.Logging.Graylog.Enabled && c.Logging.Graylog.Host == "" {
+		addf("logging.graylog.host is required when graylog is enabled (NSA_LOGGING_GRAYLOG_HOST)")
+	}
+	if c.Logging.Security.Graylog.Enabled && c.Logging.Security.Graylog.Host == "" {
+		addf("logging.security.graylog.host is required when security graylog is enabled (NSA_LOGGING_SECURITY_GRAYLOG_HOST)")
+	}
+
+	if len(c.Admin.JWTKeys) == 0 || c.Admin.JWTSecret != "" {
+		if len(c.Admin.JWTSecret) < minJWTSecretLength {
+			addf("admin.jwt_secret must be at least %d characters (NSA_ADMIN_JWT_SECRET)", minJWTSecretLength)
+		}
+	}
+	keyIDs := make(map[string]bool)
+	for i, key := range c.Admin.JWTKeys {
+		switch {
+		case key.ID == "":
+			addf("admin.jwt_keys[%d].id is required", i)
+		case keyIDs[key.ID]:
+			addf("admin.jwt_keys[%d].id %q is duplicated", i, key.ID)
+		}
+		keyIDs[key.ID] = true
+		if len(key.Secret) < minJWTSecretLength {
+			addf("admin.jwt_keys[%d].secret must be at least %d characters", i, minJWTSecretLength)
+		}
+	}
+	if c.Admin.Username != "" && c.Admin.Password == "" {
+		addf("admin.password is required when admin.username is set (NSA_ADMIN_PASSWORD)")
+	}
+	if c.Admin.Timezone != "" {
+		if _, err := time.LoadLocation(c.Admin.Timezone); err != nil {
+			addf("admin.timezone %q is not a valid IANA time zone (NSA_ADMIN_TIMEZONE)", c.Admin.Timezone)
+		}
+	}
+	if c.Admin.AccessTokenTTL < 0 || c.Admin.RefreshTokenTTL < 0 {
+		addf("admin.access_token_ttl and admin.refresh_token_ttl must not be negative")
+	}
+	if oidc := c.Admin.OIDC; oidc.Enabled {
+		if oidc.Issuer == "" || oidc.ClientID == "" || oidc.RedirectURL == "" {
+			addf("admin.oidc.issuer, client_id and redirect_url are required when OIDC is enabled")
+		}
+	}
+
+	if c.NSQ.DirectConnect {
+		if len(c.NSQ.NSQDAddresses) == 0 {
+			addf("nsq.nsqd_addresses is required when nsq.direct_connect is true (NSA_NSQ_NSQD_ADDRESSES)")
+		}
+	} else if len(c.NSQ.LookupdAddresses) == 0 {
+		addf("nsq.lookupd_addresses is required (NSA_NSQ_LOOKUPD_ADDRESSES)")
+	}
+	for _, addr := range append(append([]string{}, c.NSQ.LookupdAddresses...), c.NSQ.NSQDAddresses...) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addf("nsq address %q must be host:port", addr)
+		}
+	}
+	for _, addr := range c.NSQ.NSQDHTTPAddresses {
+		if u, err := url.Parse(addr); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addf("nsq.nsqd_http_addresses: %q must be host:port or an http(s) URL", addr)
+		}
+	}
+	if c.NSQ.Concurrency < 1 {
+		addf("nsq.concurrency must be at least 1 (NSA_NSQ_CONCURRENCY), got %d", c.NSQ.Concurrency)
+	}
+	if c.NSQ.QueueTimeout < 1 {
+		addf("nsq.queue_timeout must be at least 1 (NSA_NSQ_QUEUE_TIMEOUT), got %d", c.NSQ.QueueTimeout)
+	}
+	if c.NSQ.MaxMessageSize < 1 {
+		addf("nsq.max_message_size must be at least 1 (NSA_NSQ_MAX_MESSAGE_SIZE), got %d", c.NSQ.MaxMessageSize)
+	}
+	if c.NSQ.MaxPayloadSize < 1 {
+		addf("nsq.max_payload_size must be at least 1 (NSA_NSQ_MAX_PAYLOAD_SIZE), got %d", c.NSQ.MaxPayloadSize)
+	}
+	if c.NSQ.ReconcileInterval < 1 {
+		addf("nsq.reconcile_interval must be at least 1 (NSA_NSQ_RECONCILE_INTERVAL), got %d", c.NSQ.ReconcileInterval)
+	}
+	if c.NSQ.DeadLetterTopic != "" && len(c.NSQ.NSQDAddresses) == 0 {
+		addf("nsq.nsqd_addresses is required when nsq.dead_letter_topic is set (NSA_NSQ_NSQD_ADDRESSES)")
+	}
+	if c.NSQ.DeflateLevel < 1 || c.NSQ.DeflateLevel > 9 {
+		addf("nsq.deflate_level must be between 1 and 9 (NSA_NSQ_DEFLATE_LEVEL), got %d", c.NSQ.DeflateLevel)
+	} else if _, err := c.NSQ.ClientConfig(); err != nil {
+		addf("nsq: %v", err)
+	}
+
+	if c.Retention.ExecutionLogs.Days < 0 || c.Retention.ExecutionLogs.MaxDocuments < 0 {
+		addf("retention.execution_logs values must not be negative")
+	}
+	if c.Retention.Instances.Days < 0 || c.Retention.Instances.MaxDocuments < 0 {
+		addf("retention.workflow_instances values must not be negative")
+	}
+	if c.Retention.InstanceHistory.Days < 0 || c.Retention.InstanceHistory.MaxDocuments < 0 {
+		addf("retention.instance_history values must not be negative")
+	}
+	if c.Retention.AlertHistory.Days < 0 || c.Retention.AlertHistory.MaxDocuments < 0 {
+		addf("retention.alert_history values must not be negative")
+	}
+	if c.Retention.PurgeInterval < 0 {
+		addf("retention.purge_interval must not be negative")
+	}
+
+	if c.Files.MaxReadSize < 0 {
+		addf("files.max_read_size must not be negative")
+	}
+	if c.Files.BaseDir != "" {
+		if info, err := os.Stat(c.Files.BaseDir); err != nil || !info.IsDir() {
+			addf("files.base_dir %q must be an existing directory (NSA_FILES_BASE_DIR)", c.Files.BaseDir)
+		}
+	}
+
+	if c.Command.Enabled && len(c.Command.AllowedCommands) == 0 {
+		addf("command.allowed_commands is required when command.enabled is true (NSA_COMMAND_ALLOWED_COMMANDS)")
+	}
+	for _, name := range c.Command.AllowedEnv {
+		if DeniedCommandEnv(name) {
+			addf("command.allowed_env must not contain %s, it controls how programs are found or loaded", name)
+		}
+	}
+	if c.Command.MaxOutputSize < 0 {
+		addf("command.max_output_size must not be negative")
+	}
+	if c.Command.WorkDir != "" {
+		if info, err := os.Stat(c.Command.WorkDir); err != nil || !info.IsDir() {
+			addf("command.work_dir %q must be an existing directory (NSA_COMMAND_WORK_DIR)", c.Command.WorkDir)
+		}
+	}
+
+	projects := make(map[string]bool, len(c.Actions.Projects))
+	for i, rules := range c.Actions.Projects {
+		if rules.Project == "" {
+			addf("actions.projects[%d].project is required (NSA_ACTIONS_PROJECTS)", i)
+		} else if projects[rules.Project] {
+			addf("actions.projects[%d]: duplicate project %q", i, rules.Project)
+		}
+		projects[rules.Project] = true
+	}
+
+	if c.Ingest.Token != "" && len(c.Ingest.Token) < minIngestTokenLength {
+		addf("ingest.token must be at least %d characters (NSA_INGEST_TOKEN)", minIngestTokenLength)
+	}
+
+	if c.SNMPTrap.Enabled {
+		if _, _, err := net.SplitHostPort(c.SNMPTrap.ListenAddress); err != nil {
+			addf("snmp_trap.listen_address %q must be host:port (NSA_SNMP_TRAP_LISTEN_ADDRESS)", c.SNMPTrap.ListenAddress)
+		}
+	}
+	for oid := range c.SNMPTrap.OIDs {
+		if !isNumericOID(oid) {
+			addf("snmp_trap.oids key %q must be a numeric OID", oid)
+		}
+	}
+
+	if c.Syslog.Enabled {
+		if _, _, err := net.SplitHostPort(c.Syslog.ListenAddress); err != nil {
+			addf("syslog.listen_address %q must be host:port (NSA_SYSLOG_LISTEN_ADDRESS)", c.Syslog.ListenAddress)
+		}
+	}
+	for _, protocol := range c.Syslog.Protocols {
+		if protocol != "udp" && protocol != "tcp" {
+			addf("syslog.protocols must contain only udp or tcp, got %q", protocol)
+		}
+	}
+	if c.Syslog.MaxMessageSize < 0 {
+		addf("syslog.max_message_size must not be negative")
+	}
+
+	if c.Cluster.Enabled && c.Cluster.NodeID == "" {
+		addf("cluster.node_id is required when cluster is enabled (NSA_CLUSTER_NODE_ID)")
+	}
+	if c.Cluster.LeaseTTL < 3 {
+		addf("cluster.lease_ttl must be at least 3 seconds (NSA_CLUSTER_LEASE_TTL)")
+	}
+	if c.Cluster.Role != "active" && c.Cluster.Role != "standby" {
+		addf("cluster.role must be active or standby, got %q (NSA_CLUSTER_ROLE)", c.Cluster.Role)
+	}
+	if c.CircuitBreaker.FailureThreshold < 1 {
+		addf("circuit_breaker.failure_threshold must be at least 1")
+	}
+	if c.CircuitBreaker.OpenTimeout < 1 {
+		addf("circuit_breaker.open_timeout must be at least 1 second")
+	}
+	if c.CircuitBreaker.HalfOpenProbes < 1 {
+		addf("circuit_breaker.half_open_probes must be at least 1")
+	}
+	if c.WorkQueue.Workers < 1 {
+		addf("work_queue.workers must be at least 1")
+	}
+	if c.WorkQueue.MaxAttempts < 1 {
+		addf("work_queue.max_attempts must be at least 1")
+	}
+	if c.WorkQueue.RetryDelay < 0 {
+		addf("work_queue.retry_delay must not be negative")
+	}
+	if c.DataSourceHealth.Interval > 0 && c.DataSourceHealth.MaxBackoff < c.DataSourceHealth.Interval {
+		addf("datasource_health.max_backoff must be at least datasource_health.interval")
+	}
+	if c.HTTPClient.MaxIdleConns < 0 || c.HTTPClient.MaxIdleConnsPerHost < 0 || c.HTTPClient.MaxConnsPerHost < 0 {
+		addf("http_client.max_idle_conns, max_idle_conns_per_host and max_conns_per_host must not be negative")
+	}
+	if c.HTTPClient.IdleConnTimeout < 0 {
+		addf("http_client.idle_conn_timeout must not be negative")
+	}
+	if c.HTTPClient.MaxTransports < 1 {
+		addf("http_client.max_transports must be at least 1")
+	}
+	if export := c.InstanceExport; export.Enabled {
+		if export.NSQTopic == "" && export.URL == "" {
+			addf("instance_export.nsq_topic or instance_export.url is required when instance export is enabled")
+		}
+		if export.URL != "" && !strings.HasPrefix(export.URL, "http://") && !strings.HasPrefix(export.URL, "https://") {
+			addf("instance_export.url %q must be an http or https URL (NSA_INSTANCE_EXPORT_URL)", export.URL)
+		}
+		if export.NSQTopic != "" && export.NSQDAddress == "" && len(c.NSQ.NSQDAddresses) == 0 {
+			addf("instance_export.nsqd_address or nsq.nsqd_addresses is required to publish to nsq_topic")
+		}
+		if export.NSQDAddress != "" {
+			if _, _, err := net.SplitHostPort(export.NSQDAddress); err != nil {
+				addf("instance_export.nsqd_address %q must be host:port", export.NSQDAddress)
+			}
+		}
+		if export.Timeout < 0 || export.MaxAttempts < 1 || export.BufferSize < 1 {
+			addf("instance_export.timeout must not be negative, max_attempts and buffer_size must be at least 1")
+		}
+	}
+	if c.JS.PoolSize < 1 || c.JS.MemoryLimit < 1 || c.JS.MaxExecutions < 1 {
+		addf("js.pool_size, js.memory_limit and js.max_executions must be at least 1")
+	}
+	if c.Reports.CheckInterval < 1 {
+		addf("reports.check_interval must be at least 1 second")
+	}
+	if smtp := c.Reports.SMTP; smtp.Host != "" {
+		if smtp.From == "" {
+			addf("reports.smtp.from is required when reports.smtp.host is set (NSA_REPORTS_SMTP_FROM)")
+		}
+		if smtp.Port < 1 || smtp.Port > 65535 {
+			addf("reports.smtp.port must be between 1 and 65535 (NSA_REPORTS_SMTP_PORT), got %d", smtp.Port)
+		}
+	}
+	if c.Alerts.EvalInterval < 1 {
+		addf("alerts.eval_interval must be at least 1 second")
+	}
+	encryptionKeyIDs := make(map[string]bool)
+	for i, key := range c.Encryption.Keys {
+		switch {
+		case key.ID == "":
+			addf("encryption.keys[%d].id is required", i)
+		case encryptionKeyIDs[key.ID]:
+			addf("encryption.keys[%d].id %q is duplicated", i, key.ID)
+		}
+		encryptionKeyIDs[key.ID] = true
+		if _, err := key.Decode(); err != nil {
+			addf("encryption.keys[%d]: %v (NSA_ENCRYPTION_KEYS)", i, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// isNumericOID 判断是否为数字形式的OID（如 1.3.6.1.4.1.9，允许前导点）
+func isNumericOID(oid string) bool {
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return len(parts) > 1
+}
+
+// deniedCommandEnv 命令节点不能设置的环境变量：影响程序查找、配置文件位置和解释器启动
+var deniedCommandEnv = map[string]bool{
+	"PATH": true, "HOME": true, "IFS": true, "ENV": true, "BASH_ENV": true, "SHELLOPTS": true, "BASHOPTS": true,
+	"PS4": true, "PROMPT_COMMAND": true, "XDG_CONFIG_HOME": true, "GCONV_PATH": true, "LOCPATH": true,
+	"NLSPATH": true, "HOSTALIASES": true, "RESOLV_HOST_CONF": true, "TMPDIR": true,
+	"PYTHONPATH": true, "PYTHONHOME": true, "PYTHONSTARTUP": true, "PERL5LIB": true, "PERL5OPT": true,
+	"PERLLIB": true, "RUBYLIB": true, "RUBYOPT": true, "NODE_OPTIONS": true, "NODE_PATH": true,
+	"JAVA_TOOL_OPTIONS": true, "_JAVA_OPTIONS": true, "GIT_CONFIG_GLOBAL": true, "GIT_SSH_COMMAND": true,
+}
+
+// DeniedCommandEnv 判断环境变量是否禁止由命令节点设置
+//
+// 这些变量（如 LD_PRELOAD、PATH）可以让白名单中的程序加载或执行任意代码，
+// 例如预加载文件节点写入的共享库，白名单按程序名精确匹配的限制因此失效。
+func DeniedCommandEnv(name string) bool {
+	upper := strings.ToUpper(name)
+	if strings.HasPrefix(upper, "LD_") || strings.HasPrefix(upper, "DYLD_") {
+		return true
+	}
+	return deniedCommandEnv[upper]
+}
+
+// File 返回加载配置的文件路径
+func (c *Config) File() string {
+	return c.file
+}
+
 // Save 保存配置到文件
 func (c *Config) Save(filename string) error {
 	data, err := json.MarshalIndent(c, "", "  ")
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix 环境变量前缀
+const envPrefix = "NSA"
+
+// applyEnv 使用 NSA_* 环境变量覆盖配置
+//
+// 变量名由JSON字段路径转换而来，例如 mongodb.dsn 对应 NSA_MONGODB_DSN，
+// admin.oidc.client_secret 对应 NSA_ADMIN_OIDC_CLIENT_SECRET。
+// 数组使用逗号分隔，对象数组使用JSON，映射使用 key=value 并以逗号分隔。
+func applyEnv(config *Config) error {
+	return applyEnvValue(reflect.ValueOf(config).Elem(), envPrefix)
+}
+
+// applyEnvValue 递归处理结构体字段
+func applyEnvValue(value reflect.Value, prefix string) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Struct {
+			if err := applyEnvValue(fieldValue, key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(fieldValue, strings.TrimSpace(raw)); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// setEnvValue 将环境变量字符串转换为字段类型
+func setEnvValue(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Slice:
+		// 对象数组使用JSON
+		if field.Type().Elem().Kind() == reflect.Struct {
+			items := reflect.New(field.Type())
+			if err := json.Unmarshal([]byte(raw), items.Interface()); err != nil {
+				return fmt.Errorf("expected a JSON array: %v", err)
+			}
+			field.Set(items.Elem())
+			return nil
+		}
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		items := map[string]string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value pairs, got %q", item)
+			}
+			items[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// ClientConfig 按连接配置（TLS、认证、压缩）创建 go-nsq 配置，消费者和生产者的其他参数由调用方设置
+func (c *NSQConfig) ClientConfig() (*nsq.Config, error) {
+	nsqConfig := nsq.NewConfig()
+	nsqConfig.AuthSecret = c.AuthSecret
+
+	switch c.Compression {
+	case "":
+	case "snappy":
+		nsqConfig.Snappy = true
+	case "deflate":
+		nsqConfig.Deflate = true
+		nsqConfig.DeflateLevel = c.DeflateLevel
+	default:
+		return nil, fmt.Errorf("compression must be snappy or deflate, got %q", c.Compression)
+	}
+
+	if c.TLS.Enabled {
+		tlsConfig, err := c.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		nsqConfig.TlsV1 = true
+		nsqConfig.TlsConfig = tlsConfig
+	}
+
+	if err := nsqConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return nsqConfig, nil
+}
+
+// tlsConfig 读取证书文件创建TLS配置
+func (c *NSQTLSConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls.ca_file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls.ca_file %q contains no PEM certificates", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls.cert_file and tls.key_file: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,206 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// BigQueryClient BigQuery客户端，使用官方SDK（cloud.google.com/go/bigquery）和服务账号认证
+type BigQueryClient struct {
+	client  *bigquery.Client
+	dataset string
+}
+
+// newBigQueryClient 根据数据源配置创建客户端
+//
+// password为服务账号JSON，database为默认数据集，host可覆盖API地址，
+// options支持 project_id（默认使用服务账号所属项目）和 location。
+func newBigQueryClient(ds *models.DataSource) (*BigQueryClient, error) {
+	var account struct {
+		ProjectID string `json:"project_id"`
+	}
+	if err := json.Unmarshal([]byte(ds.Password), &account); err != nil {
+		return nil, fmt.Errorf("invalid service account JSON: %v", err)
+	}
+	projectID := ds.Options["project_id"]
+	if projectID == "" {
+		projectID = account.ProjectID
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("project_id is required for bigquery datasource")
+	}
+
+	opts := []option.ClientOption{option.WithCredentialsJSON([]byte(ds.Password))}
+	if ds.Host != "" {
+		opts = append(opts, option.WithEndpoint(ds.Host))
+	}
+	client, err := bigquery.NewClient(context.Background(), projectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bigquery client: %v", err)
+	}
+	client.Location = ds.Options["location"]
+
+	return &BigQueryClient{client: client, dataset: ds.Database}, nil
+}
+
+// Submit 创建查询作业，返回作业ID（包含位置时格式为 location:jobId）
+func (c *BigQueryClient) Submit(ctx context.Context, query string, params []interface{}) (string, error) {
+	q := c.client.Query(query)
+	q.DefaultDatasetID = c.dataset
+	for _, param := range params {
+		q.Parameters = append(q.Parameters, bigquery.QueryParameter{Value: bigQueryParameter(param)})
+	}
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return "", err
+	}
+	if job.Location() != "" {
+		return job.Location() + ":" + job.ID(), nil
+	}
+	return job.ID(), nil
+}
+
+// Fetch 读取一页查询结果
+func (c *BigQueryClient) Fetch(ctx context.Context, queryID, pageToken string, pageSize int) (*WarehousePage, error) {
+	location, jobID := c.client.Location, queryID
+	if index := strings.LastIndex(queryID, ":"); index >= 0 {
+		location, jobID = queryID[:index], queryID[index+1:]
+	}
+
+	job, err := c.client.JobFromIDLocation(ctx, jobID, location)
+	if err != nil {
+		return nil, err
+	}
+	status := job.LastStatus()
+	if !status.Done() {
+		return &WarehousePage{QueryID: queryID, Status: QueryRunning, Columns: []string{}, Rows: []map[string]interface{}{}}, nil
+	}
+	if err := status.Err(); err != nil {
+		return nil, err
+	}
+
+	it, err := job.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	it.PageInfo().Token = pageToken
+	it.PageInfo().MaxSize = pageSize
+
+	// 读取第一行时请求一页，缓冲的行读完即为一页
+	var values [][]bigquery.Value
+	for {
+		var row []bigquery.Value
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, row)
+		if it.PageInfo().Remaining() == 0 {
+			break
+		}
+	}
+
+	page := &WarehousePage{
+		QueryID:       queryID,
+		Status:        QueryDone,
+		Columns:       make([]string, len(it.Schema)),
+		Rows:          make([]map[string]interface{}, 0, len(values)),
+		TotalRows:     int64(it.TotalRows),
+		NextPageToken: it.PageInfo().Token,
+	}
+	for i, field := range it.Schema {
+		page.Columns[i] = field.Name
+	}
+	for _, value := range values {
+		page.Rows = append(page.Rows, bigQueryRecord(it.Schema, value))
+	}
+	page.Count = len(page.Rows)
+	return page, nil
+}
+
+// Close 关闭客户端
+func (c *BigQueryClient) Close() error {
+	return c.client.Close()
+}
+
+// bigQueryParameter 将参数转换为位置查询参数的值，整数值的浮点数按INT64传递，null按STRING类型传递
+func bigQueryParameter(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return bigquery.NullString{}
+	case bool, string, int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// bigQueryRecord 按字段定义将一行（或嵌套记录）转换为对象
+func bigQueryRecord(schema bigquery.Schema, values []bigquery.Value) map[string]interface{} {
+	record := make(map[string]interface{}, len(schema))
+	for i, field := range schema {
+		if i >= len(values) {
+			break
+		}
+		record[field.Name] = bigQueryValue(field, values[i])
+	}
+	return record
+}
+
+// bigQueryValue 按字段类型转换结果值，支持重复字段和嵌套记录
+func bigQueryValue(field *bigquery.FieldSchema, value bigquery.Value) interface{} {
+	if value == nil {
+		return nil
+	}
+	if field.Repeated {
+		items, _ := value.([]bigquery.Value)
+		single := *field
+		single.Repeated = false
+		result := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			result = append(result, bigQueryValue(&single, item))
+		}
+		return result
+	}
+
+	switch v := value.(type) {
+	case []bigquery.Value:
+		return bigQueryRecord(field.Schema, v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case civil.Date, civil.Time, civil.DateTime, *bigquery.IntervalValue:
+		return fmt.Sprint(v)
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	case string:
+		if field.Type == bigquery.JSONFieldType {
+			var data interface{}
+			if err := json.Unmarshal([]byte(v), &data); err == nil {
+				return data
+			}
+		}
+	}
+	return value
+}
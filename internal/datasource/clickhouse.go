@@ -0,0 +1,203 @@
+package datasource
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// clickhouseTimeout 单个ClickHouse请求的超时时间
+const clickhouseTimeout = 5 * time.Minute
+
+// clickhouseParamRe SQL中的服务端参数引用 {name:Type}
+var clickhouseParamRe = regexp.MustCompile(`\{\s*[A-Za-z_][A-Za-z0-9_]*\s*:[^{}]+\}`)
+
+// clickhouseConnector 基于 clickhouse-go HTTP 协议的连接器
+//
+// 参数只作为服务端参数（HTTP请求的 param_<name>）传递，不在客户端拼接到SQL中：
+// 按位置传递的参数依次命名为 p1、p2…，sql.Named 传递的参数使用其名称，SQL中以 {name:Type} 引用。
+// ClickHouse不支持事务。
+type clickhouseConnector struct {
+	driver.Connector
+}
+
+// clickhouseConn 转换参数后调用 clickhouse-go 的连接
+type clickhouseConn struct {
+	conn driver.Conn
+}
+
+// clickhouseStmt 预处理语句，只保存查询文本
+type clickhouseStmt struct {
+	conn  *clickhouseConn
+	query string
+}
+
+// openClickHouse 根据数据源配置创建连接池，options中的配置作为ClickHouse设置传递
+func openClickHouse(ds *models.DataSource) *sql.DB {
+	opts := &clickhouse.Options{
+		Protocol: clickhouse.HTTP,
+		Addr:     []string{fmt.Sprintf("%s:%d", ds.Host, ds.Port)},
+		Auth: clickhouse.Auth{
+			Database: ds.Database,
+			Username: ds.Username,
+			Password: ds.Password,
+		},
+		Settings:    clickhouse.Settings{},
+		DialTimeout: 10 * time.Second,
+		ReadTimeout: clickhouseTimeout,
+	}
+	if ds.SSL {
+		opts.TLS = &tls.Config{}
+	}
+	for key, value := range ds.Options {
+		opts.Settings[key] = value
+	}
+	return sql.OpenDB(&clickhouseConnector{Connector: clickhouse.Connector(opts)})
+}
+
+// Connect 创建连接
+func (c *clickhouseConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &clickhouseConn{conn: conn}, nil
+}
+
+// Prepare 创建预处理语句
+func (c *clickhouseConn) Prepare(query string) (driver.Stmt, error) {
+	return &clickhouseStmt{conn: c, query: query}, nil
+}
+
+// Close 关闭连接
+func (c *clickhouseConn) Close() error {
+	return c.conn.Close()
+}
+
+// Begin ClickHouse不支持事务
+func (c *clickhouseConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("clickhouse does not support transactions")
+}
+
+// Ping 检查连接和认证
+func (c *clickhouseConn) Ping(ctx context.Context) error {
+	return c.conn.(driver.Pinger).Ping(ctx)
+}
+
+// ResetSession 连接放回连接池前重置状态
+func (c *clickhouseConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// QueryContext 以服务端参数执行查询
+func (c *clickhouseConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, err := clickhouseParams(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return c.conn.(driver.QueryerContext).QueryContext(ctx, query, nil)
+}
+
+// ExecContext 以服务端参数执行写入语句，HTTP协议不返回影响的行数
+func (c *clickhouseConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ctx, err := clickhouseParams(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return c.conn.(driver.ExecerContext).ExecContext(ctx, query, nil)
+}
+
+// Close 关闭语句
+func (s *clickhouseStmt) Close() error {
+	return nil
+}
+
+// NumInput 参数个数由 {name:Type} 引用决定，返回-1跳过database/sql的检查
+func (s *clickhouseStmt) NumInput() int {
+	return -1
+}
+
+// Exec 执行写入语句
+func (s *clickhouseStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+// Query 执行查询
+func (s *clickhouseStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+// namedValues 将位置参数转换为NamedValue
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// clickhouseParams 将参数转换为服务端参数并设置到查询上下文中
+func clickhouseParams(ctx context.Context, query string, args []driver.NamedValue) (context.Context, error) {
+	if len(args) == 0 {
+		return ctx, nil
+	}
+	if !clickhouseParamRe.MatchString(query) {
+		return nil, fmt.Errorf("clickhouse params must be referenced as {p1:Type} or {name:Type} in the query")
+	}
+
+	params := make(clickhouse.Parameters, len(args))
+	for _, arg := range args {
+		name := arg.Name
+		if name == "" {
+			name = "p" + strconv.Itoa(arg.Ordinal)
+		}
+		value, err := clickhouseParamValue(arg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clickhouse param %s: %v", name, err)
+		}
+		params[name] = value
+	}
+	return clickhouse.Context(ctx, clickhouse.WithParameters(params)), nil
+}
+
+// clickhouseEscaper 服务端参数值按TabSeparated格式转义
+var clickhouseEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// clickhouseParamValue 将参数转换为服务端参数的文本格式，由ClickHouse按 {name:Type} 中的类型解析
+func clickhouseParamValue(value driver.Value) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return `\N`, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("unsupported value %v", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return clickhouseEscaper.Replace(v), nil
+	case []byte:
+		return clickhouseEscaper.Replace(string(v)), nil
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05.999999999"), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
@@ -0,0 +1,263 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// consulWaitTime 阻塞查询的最长等待时间
+const consulWaitTime = 5 * time.Minute
+
+// ConsulClient Consul KV HTTP API客户端
+type ConsulClient struct {
+	baseURL    string
+	token      string
+	datacenter string
+	httpClient *http.Client
+}
+
+// consulEntry KV接口返回的条目，Value为base64编码
+type consulEntry struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// newConsulClient 根据数据源配置创建客户端
+//
+// host为agent地址，port默认8500，password为ACL令牌，options支持 datacenter。
+func newConsulClient(ds *models.DataSource) (*ConsulClient, error) {
+	if ds.Host == "" {
+		return nil, fmt.Errorf("host is required for consul datasource")
+	}
+
+	scheme := "http"
+	if ds.SSL {
+		scheme = "https"
+	}
+	port := ds.Port
+	if port == 0 {
+		port = 8500
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(ds.Host, "https://"), "http://"), "/")
+
+	return &ConsulClient{
+		baseURL:    fmt.Sprintf("%s://%s:%d", scheme, host, port),
+		token:      ds.Password,
+		datacenter: ds.Options["datacenter"],
+		// 阻塞查询最长等待 consulWaitTime，超时需大于该值
+		httpClient: &http.Client{Timeout: consulWaitTime + time.Minute},
+	}, nil
+}
+
+// Ping 检查agent是否可用且集群已选出leader
+func (c *ConsulClient) Ping(ctx context.Context) error {
+	status, _, data, err := c.request(ctx, http.MethodGet, "/v1/status/leader", nil, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound || strings.Trim(string(data), "\" \n") == "" {
+		return fmt.Errorf("consul cluster has no leader")
+	}
+	return nil
+}
+
+// Get 读取单个键
+func (c *ConsulClient) Get(ctx context.Context, key string) (*KVPair, error) {
+	entries, _, err := c.getEntries(ctx, key, false, nil)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// List 读取前缀下的所有键
+func (c *ConsulClient) List(ctx context.Context, prefix string) ([]KVPair, error) {
+	entries, _, err := c.getEntries(ctx, prefix, true, nil)
+	return entries, err
+}
+
+// Put 写入键，Consul不返回新版本，写入后重新读取
+func (c *ConsulClient) Put(ctx context.Context, key string, value []byte) (uint64, error) {
+	_, _, data, err := c.request(ctx, http.MethodPut, c.kvPath(key), nil, value)
+	if err != nil {
+		return 0, err
+	}
+	if strings.TrimSpace(string(data)) != "true" {
+		return 0, fmt.Errorf("consul rejected write to %s", key)
+	}
+
+	pair, err := c.Get(ctx, key)
+	if err != nil || pair == nil {
+		return 0, err
+	}
+	return pair.Revision, nil
+}
+
+// Delete 删除键
+func (c *ConsulClient) Delete(ctx context.Context, key string, prefix bool) error {
+	query := url.Values{}
+	if prefix {
+		query.Set("recurse", "true")
+	}
+	_, _, _, err := c.request(ctx, http.MethodDelete, c.kvPath(key), query, nil)
+	return err
+}
+
+// Watch 基于阻塞查询监听键
+func (c *ConsulClient) Watch(key string, prefix bool) KVWatch {
+	return &consulWatch{client: c, key: strings.TrimPrefix(key, "/"), prefix: prefix}
+}
+
+// getEntries 读取键，query为额外的查询参数（阻塞查询的index、wait），同时返回 X-Consul-Index
+func (c *ConsulClient) getEntries(ctx context.Context, key string, recurse bool, query url.Values) ([]KVPair, uint64, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if recurse {
+		query.Set("recurse", "true")
+	}
+
+	status, header, data, err := c.request(ctx, http.MethodGet, c.kvPath(key), query, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	index, _ := strconv.ParseUint(header.Get("X-Consul-Index"), 10, 64)
+	if status == http.StatusNotFound {
+		return nil, index, nil
+	}
+
+	var entries []consulEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode consul response: %v", err)
+	}
+	pairs := make([]KVPair, 0, len(entries))
+	for _, entry := range entries {
+		pairs = append(pairs, KVPair{Key: entry.Key, Value: entry.Value, Revision: entry.ModifyIndex})
+	}
+	sortKVPairs(pairs)
+	return pairs, index, nil
+}
+
+// kvPath 返回键的请求路径
+func (c *ConsulClient) kvPath(key string) string {
+	return "/v1/kv/" + uriEncode(strings.TrimPrefix(key, "/"), true)
+}
+
+// request 发送请求，404作为正常结果返回，其他>=400的状态码返回错误
+func (c *ConsulClient) request(ctx context.Context, method, path string, query url.Values, body []byte) (int, http.Header, []byte, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		message := strings.TrimSpace(string(data))
+		if len(message) > 1024 {
+			message = message[:1024]
+		}
+		return 0, nil, nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, message)
+	}
+	return resp.StatusCode, resp.Header, data, nil
+}
+
+// consulWatch 基于阻塞查询（index + wait）的监听，通过比较前后两次结果得到变更事件
+type consulWatch struct {
+	client      *ConsulClient
+	key         string
+	prefix      bool
+	index       uint64
+	known       map[string]KVPair
+	initialized bool
+}
+
+// Next 阻塞直到键发生变化
+func (w *consulWatch) Next(ctx context.Context) ([]KVEvent, error) {
+	for {
+		var query url.Values
+		if w.initialized {
+			query = url.Values{}
+			query.Set("index", strconv.FormatUint(w.index, 10))
+			query.Set("wait", consulWaitTime.String())
+		}
+		pairs, index, err := w.client.getEntries(ctx, w.key, w.prefix, query)
+		if err != nil {
+			return nil, err
+		}
+
+		current := make(map[string]KVPair, len(pairs))
+		for _, pair := range pairs {
+			// 非前缀监听时 recurse 未开启，但仍只保留完全匹配的键
+			if w.prefix || pair.Key == w.key {
+				current[pair.Key] = pair
+			}
+		}
+
+		// 索引回退（如从快照恢复）时按Consul的建议从0重新开始
+		if index < w.index {
+			index = 0
+		}
+		w.index = index
+		if !w.initialized {
+			w.known = current
+			w.initialized = true
+			continue
+		}
+
+		var events []KVEvent
+		for key, pair := range current {
+			if old, ok := w.known[key]; !ok || old.Revision != pair.Revision {
+				events = append(events, KVEvent{Type: KVEventPut, KVPair: pair})
+			}
+		}
+		for key := range w.known {
+			if _, ok := current[key]; !ok {
+				events = append(events, KVEvent{Type: KVEventDelete, KVPair: KVPair{Key: key, Revision: index}})
+			}
+		}
+		w.known = current
+
+		if len(events) > 0 {
+			sort.Slice(events, func(i, j int) bool { return events[i].Key < events[j].Key })
+			return events, nil
+		}
+	}
+}
+
+// Close 阻塞查询随请求结束，无需关闭
+func (w *consulWatch) Close() {}
@@ -0,0 +1,250 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// ESClient Elasticsearch/OpenSearch REST API客户端
+type ESClient struct {
+	baseURL      string
+	username     string
+	password     string
+	apiKey       string
+	defaultIndex string
+	httpClient   *http.Client
+}
+
+// ESBulkItem 批量写入的单个文档
+type ESBulkItem struct {
+	Op       string // index、create、update、delete
+	Index    string
+	ID       string
+	Document interface{}
+}
+
+// newESClient 根据数据源配置创建客户端
+//
+// host为节点地址，port默认9200，database为默认索引；username/password为基本认证的用户名和密码，
+// username为空时password作为API Key（base64编码的 id:api_key）。
+func newESClient(ds *models.DataSource) (*ESClient, error) {
+	if ds.Host == "" {
+		return nil, fmt.Errorf("host is required for elasticsearch datasource")
+	}
+
+	scheme := "http"
+	if ds.SSL {
+		scheme = "https"
+	}
+	port := ds.Port
+	if port == 0 {
+		port = 9200
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(ds.Host, "https://"), "http://"), "/")
+
+	client := &ESClient{
+		baseURL:      fmt.Sprintf("%s://%s:%d", scheme, host, port),
+		defaultIndex: ds.Database,
+		httpClient:   &http.Client{Timeout: 5 * time.Minute},
+	}
+	if ds.Username != "" {
+		client.username = ds.Username
+		client.password = ds.Password
+	} else {
+		client.apiKey = ds.Password
+	}
+	return client, nil
+}
+
+// Index 返回实际使用的索引，未指定时使用数据源的默认索引
+func (c *ESClient) Index(index string) (string, error) {
+	if index == "" {
+		index = c.defaultIndex
+	}
+	if index == "" {
+		return "", fmt.Errorf("index is required")
+	}
+	return index, nil
+}
+
+// Ping 读取集群信息，检查地址和凭据是否可用
+func (c *ESClient) Ping(ctx context.Context) error {
+	_, err := c.request(ctx, http.MethodGet, "/", nil, nil, "")
+	return err
+}
+
+// IndexDocument 写入单个文档，id为空时由服务端生成，opType为 index 或 create
+func (c *ESClient) IndexDocument(ctx context.Context, index, id string, document interface{}, opType, refresh string) (map[string]interface{}, error) {
+	body, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %v", err)
+	}
+	query := url.Values{}
+	if opType != "" {
+		query.Set("op_type", opType)
+	}
+	if refresh != "" {
+		query.Set("refresh", refresh)
+	}
+
+	method, path := http.MethodPost, "/"+url.PathEscape(index)+"/_doc"
+	if id != "" {
+		method, path = http.MethodPut, path+"/"+url.PathEscape(id)
+	}
+	return c.requestJSON(ctx, method, path, query, body, "application/json")
+}
+
+// Search 执行搜索，body为查询DSL
+func (c *ESClient) Search(ctx context.Context, index string, body map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search body: %v", err)
+	}
+	return c.requestJSON(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_search", nil, data, "application/json")
+}
+
+// Bulk 批量写入，index为文档未指定索引时使用的索引
+func (c *ESClient) Bulk(ctx context.Context, index string, items []ESBulkItem, refresh string) (map[string]interface{}, error) {
+	var buf bytes.Buffer
+	for i, item := range items {
+		op := item.Op
+		if op == "" {
+			op = "index"
+		}
+		meta := map[string]interface{}{}
+		if item.Index != "" {
+			meta["_index"] = item.Index
+		}
+		if item.ID != "" {
+			meta["_id"] = item.ID
+		}
+		action, _ := json.Marshal(map[string]interface{}{op: meta})
+		buf.Write(action)
+		buf.WriteByte('\n')
+
+		switch op {
+		case "delete":
+			continue
+		case "update":
+			item.Document = map[string]interface{}{"doc": item.Document}
+		}
+		document, err := json.Marshal(item.Document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal document %d: %v", i, err)
+		}
+		buf.Write(document)
+		buf.WriteByte('\n')
+	}
+
+	query := url.Values{}
+	if refresh != "" {
+		query.Set("refresh", refresh)
+	}
+	path := "/_bulk"
+	if index != "" {
+		path = "/" + url.PathEscape(index) + "/_bulk"
+	}
+	return c.requestJSON(ctx, http.MethodPost, path, query, buf.Bytes(), "application/x-ndjson")
+}
+
+// DeleteByQuery 删除匹配查询的文档，conflicts为 proceed 时跳过版本冲突
+func (c *ESClient) DeleteByQuery(ctx context.Context, index string, queryDSL interface{}, conflicts string, refresh bool) (map[string]interface{}, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": queryDSL})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %v", err)
+	}
+	query := url.Values{}
+	if conflicts != "" {
+		query.Set("conflicts", conflicts)
+	}
+	if refresh {
+		query.Set("refresh", "true")
+	}
+	return c.requestJSON(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_delete_by_query", query, body, "application/json")
+}
+
+// requestJSON 发送请求并解析JSON响应
+func (c *ESClient) requestJSON(ctx context.Context, method, path string, query url.Values, body []byte, contentType string) (map[string]interface{}, error) {
+	data, err := c.request(ctx, method, path, query, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch response: %s", strings.TrimSpace(string(data)))
+	}
+	return result, nil
+}
+
+// request 发送请求，状态码>=400时返回错误
+func (c *ESClient) request(ctx context.Context, method, path string, query url.Values, body []byte, contentType string) ([]byte, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	} else if c.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, esErrorMessage(data))
+	}
+	return data, nil
+}
+
+// esErrorMessage 从错误响应中提取错误类型和原因
+func esErrorMessage(data []byte) string {
+	var result struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err == nil && len(result.Error) > 0 {
+		var detail struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		}
+		if err := json.Unmarshal(result.Error, &detail); err == nil && detail.Reason != "" {
+			return detail.Type + ": " + detail.Reason
+		}
+		var message string
+		if err := json.Unmarshal(result.Error, &message); err == nil && message != "" {
+			return message
+		}
+	}
+	message := strings.TrimSpace(string(data))
+	if len(message) > 1024 {
+		message = message[:1024]
+	}
+	return message
+}
@@ -0,0 +1,379 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// EtcdClient etcd v3 gRPC网关（JSON）客户端
+type EtcdClient struct {
+	baseURL      string
+	username     string
+	password     string
+	httpClient   *http.Client
+	streamClient *http.Client // watch为长连接，不设置整体超时
+
+	mu    sync.Mutex
+	token string
+}
+
+// etcdHeader 响应头，int64字段在网关中编码为字符串
+type etcdHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+// etcdKV 键值，key和value为base64编码
+type etcdKV struct {
+	Key         []byte `json:"key"`
+	Value       []byte `json:"value"`
+	ModRevision int64  `json:"mod_revision,string"`
+}
+
+// etcdRangeRequest range/deleterange/watch请求中的键范围
+type etcdRangeRequest struct {
+	Key           []byte `json:"key"`
+	RangeEnd      []byte `json:"range_end,omitempty"`
+	StartRevision int64  `json:"start_revision,string,omitempty"`
+}
+
+// newEtcdClient 根据数据源配置创建客户端
+//
+// host为节点地址，port默认2379，username/password为启用认证时的用户名和密码。
+func newEtcdClient(ds *models.DataSource) (*EtcdClient, error) {
+	if ds.Host == "" {
+		return nil, fmt.Errorf("host is required for etcd datasource")
+	}
+
+	scheme := "http"
+	if ds.SSL {
+		scheme = "https"
+	}
+	port := ds.Port
+	if port == 0 {
+		port = 2379
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(ds.Host, "https://"), "http://"), "/")
+
+	return &EtcdClient{
+		baseURL:      fmt.Sprintf("%s://%s:%d", scheme, host, port),
+		username:     ds.Username,
+		password:     ds.Password,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+		streamClient: &http.Client{},
+	}, nil
+}
+
+// Ping 检查节点状态，启用认证时同时验证用户名和密码
+func (c *EtcdClient) Ping(ctx context.Context) error {
+	if c.username != "" {
+		if _, err := c.authenticate(ctx); err != nil {
+			return err
+		}
+	}
+	return c.call(ctx, "/v3/maintenance/status", map[string]interface{}{}, nil)
+}
+
+// Get 读取单个键
+func (c *EtcdClient) Get(ctx context.Context, key string) (*KVPair, error) {
+	pairs, _, err := c.rangeKeys(ctx, etcdKeyRange(key, false))
+	if err != nil || len(pairs) == 0 {
+		return nil, err
+	}
+	return &pairs[0], nil
+}
+
+// List 读取前缀下的所有键
+func (c *EtcdClient) List(ctx context.Context, prefix string) ([]KVPair, error) {
+	pairs, _, err := c.rangeKeys(ctx, etcdKeyRange(prefix, true))
+	return pairs, err
+}
+
+// Put 写入键
+func (c *EtcdClient) Put(ctx context.Context, key string, value []byte) (uint64, error) {
+	var resp struct {
+		Header etcdHeader `json:"header"`
+	}
+	body := map[string]interface{}{"key": []byte(key), "value": value}
+	if err := c.call(ctx, "/v3/kv/put", body, &resp); err != nil {
+		return 0, err
+	}
+	return uint64(resp.Header.Revision), nil
+}
+
+// Delete 删除键
+func (c *EtcdClient) Delete(ctx context.Context, key string, prefix bool) error {
+	return c.call(ctx, "/v3/kv/deleterange", etcdKeyRange(key, prefix), nil)
+}
+
+// Watch 基于watch流监听键
+func (c *EtcdClient) Watch(key string, prefix bool) KVWatch {
+	return &etcdWatch{client: c, request: etcdKeyRange(key, prefix)}
+}
+
+// rangeKeys 读取键范围，同时返回当前版本
+func (c *EtcdClient) rangeKeys(ctx context.Context, req etcdRangeRequest) ([]KVPair, int64, error) {
+	var resp struct {
+		Header etcdHeader `json:"header"`
+		KVs    []etcdKV   `json:"kvs"`
+	}
+	if err := c.call(ctx, "/v3/kv/range", req, &resp); err != nil {
+		return nil, 0, err
+	}
+
+	pairs := make([]KVPair, 0, len(resp.KVs))
+	for _, kv := range resp.KVs {
+		pairs = append(pairs, kv.pair())
+	}
+	sortKVPairs(pairs)
+	return pairs, resp.Header.Revision, nil
+}
+
+// call 发送JSON请求并解析响应
+func (c *EtcdClient) call(ctx context.Context, path string, body, out interface{}) error {
+	resp, err := c.post(ctx, c.httpClient, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode etcd response: %v", err)
+	}
+	return nil
+}
+
+// post 发送请求，令牌过期（401）时重新认证并重试一次
+func (c *EtcdClient) post(ctx context.Context, httpClient *http.Client, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if c.username != "" {
+			token, err := c.currentToken(ctx, attempt > 0)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", token)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute request: %v", err)
+		}
+		if resp.StatusCode < 400 {
+			return resp, nil
+		}
+
+		message := etcdErrorMessage(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized && c.username != "" && attempt == 0 {
+			continue
+		}
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, message)
+	}
+}
+
+// currentToken 返回缓存的认证令牌，refresh为true时重新认证
+func (c *EtcdClient) currentToken(ctx context.Context, refresh bool) (string, error) {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token != "" && !refresh {
+		return token, nil
+	}
+	return c.authenticate(ctx)
+}
+
+// authenticate 使用用户名和密码获取令牌
+func (c *EtcdClient) authenticate(ctx context.Context) (string, error) {
+	data, err := json.Marshal(map[string]string{"name": c.username, "password": c.password})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v3/auth/authenticate", bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("etcd authentication failed with status %d: %s", resp.StatusCode, etcdErrorMessage(resp.Body))
+	}
+
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode etcd response: %v", err)
+	}
+	if result.Token == "" {
+		return "", fmt.Errorf("etcd authentication returned no token")
+	}
+
+	c.mu.Lock()
+	c.token = result.Token
+	c.mu.Unlock()
+	return result.Token, nil
+}
+
+// pair 转换为KVPair
+func (kv etcdKV) pair() KVPair {
+	return KVPair{Key: string(kv.Key), Value: kv.Value, Revision: uint64(kv.ModRevision)}
+}
+
+// etcdKeyRange 返回单个键或前缀对应的键范围
+//
+// 前缀范围的结束键为前缀最后一个非0xff字节加1；空前缀使用 "\x00" 到 "\x00"，表示所有键。
+func etcdKeyRange(key string, prefix bool) etcdRangeRequest {
+	if !prefix {
+		return etcdRangeRequest{Key: []byte(key)}
+	}
+	if key == "" {
+		return etcdRangeRequest{Key: []byte{0}, RangeEnd: []byte{0}}
+	}
+	end := []byte(key)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return etcdRangeRequest{Key: []byte(key), RangeEnd: end[:i+1]}
+		}
+	}
+	return etcdRangeRequest{Key: []byte(key), RangeEnd: []byte{0}}
+}
+
+// etcdErrorMessage 读取网关错误响应中的message
+func etcdErrorMessage(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 4096))
+	var resp struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if json.Unmarshal(data, &resp) == nil {
+		if resp.Message != "" {
+			return resp.Message
+		}
+		if resp.Error != "" {
+			return resp.Error
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// etcdWatch 基于 /v3/watch 流的监听，流断开后从最后处理的版本之后恢复
+type etcdWatch struct {
+	client   *EtcdClient
+	request  etcdRangeRequest
+	revision int64
+	body     io.ReadCloser
+	decoder  *json.Decoder
+}
+
+// etcdWatchResponse watch流中的一条消息
+type etcdWatchResponse struct {
+	Result *struct {
+		Header          etcdHeader `json:"header"`
+		Canceled        bool       `json:"canceled"`
+		CancelReason    string     `json:"cancel_reason"`
+		CompactRevision int64      `json:"compact_revision,string"`
+		Events          []struct {
+			Type string `json:"type"` // PUT为默认值时省略
+			KV   etcdKV `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Next 阻塞直到键发生变化，流绑定到打开它时的ctx
+func (w *etcdWatch) Next(ctx context.Context) ([]KVEvent, error) {
+	if w.revision == 0 {
+		_, revision, err := w.client.rangeKeys(ctx, w.request)
+		if err != nil {
+			return nil, err
+		}
+		w.revision = revision
+	}
+
+	for {
+		if w.decoder == nil {
+			create := w.request
+			create.StartRevision = w.revision + 1
+			resp, err := w.client.post(ctx, w.client.streamClient, "/v3/watch", map[string]interface{}{"create_request": create})
+			if err != nil {
+				return nil, err
+			}
+			w.body = resp.Body
+			w.decoder = json.NewDecoder(resp.Body)
+		}
+
+		var msg etcdWatchResponse
+		if err := w.decoder.Decode(&msg); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("etcd watch stream closed: %v", err)
+		}
+		if msg.Error != nil {
+			w.Close()
+			return nil, fmt.Errorf("etcd watch failed: %s", msg.Error.Message)
+		}
+		if msg.Result == nil {
+			continue
+		}
+		if msg.Result.Canceled {
+			w.Close()
+			// 起始版本已被压缩，无法补齐中间的变更，重新建立基线
+			if msg.Result.CompactRevision > 0 {
+				w.revision = 0
+			}
+			return nil, fmt.Errorf("etcd watch canceled: %s", msg.Result.CancelReason)
+		}
+
+		events := make([]KVEvent, 0, len(msg.Result.Events))
+		for _, event := range msg.Result.Events {
+			eventType := KVEventPut
+			if event.Type == "DELETE" {
+				eventType = KVEventDelete
+			}
+			events = append(events, KVEvent{Type: eventType, KVPair: event.KV.pair()})
+			if event.KV.ModRevision > w.revision {
+				w.revision = event.KV.ModRevision
+			}
+		}
+		if len(events) > 0 {
+			return events, nil
+		}
+	}
+}
+
+// Close 关闭watch流
+func (w *etcdWatch) Close() {
+	if w.body != nil {
+		w.body.Close()
+	}
+	w.body = nil
+	w.decoder = nil
+}
@@ -0,0 +1,314 @@
+package datasource
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/models"
+)
+
+// pingTimeout 单次连接检查的超时时间
+const pingTimeout = 5 * time.Second
+
+// 数据源健康状态
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Health 数据源连接健康状态
+type Health struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Status string `json:"status"` // healthy 或 unhealthy
+	// Connected 是否持有可用的连接，首次连接失败或重新连接失败时为false
+	Connected bool `json:"connected"`
+	// Checked 是否定期检查连接，只检查SQL和MongoDB数据源，其他类型只在未连接时重新连接
+	Checked     bool      `json:"checked"`
+	LastCheck   time.Time `json:"last_check,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LatencyMs   float64   `json:"latency_ms"`
+	LastError   string    `json:"last_error,omitempty"`
+	// Failures 连续失败次数（检查失败和重新连接失败）
+	Failures int `json:"failures"`
+	// Reconnects 成功重新连接的次数
+	Reconnects int       `json:"reconnects"`
+	NextRetry  time.Time `json:"next_retry,omitempty"`
+	// retries 连续重新连接失败的次数，用于计算退避时间
+	retries int
+}
+
+// healthChecker 定期检查连接的goroutine
+type healthChecker struct {
+	cfg    config.DataSourceHealthConfig
+	logger logger.Logger
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// pingable 判断数据源类型是否定期检查连接
+func pingable(dsType string) bool {
+	switch dsType {
+	case "mysql", "postgresql", "sqlserver", "oracle", "sqlite", "clickhouse", "mongodb":
+		return true
+	}
+	return false
+}
+
+// recordConnect 记录创建连接的结果，调用时需持有写锁
+func (m *Manager) recordConnect(ds *models.DataSource, err error) {
+	now := time.Now()
+	h := &Health{Name: ds.Name, Type: ds.Type, Checked: pingable(ds.Type), LastCheck: now}
+	if old, ok := m.health[ds.Name]; ok {
+		h.Reconnects = old.Reconnects
+	}
+	if err != nil {
+		h.Status = HealthUnhealthy
+		h.LastError = err.Error()
+		h.Failures = 1
+		h.retries = 1
+		h.NextRetry = now.Add(m.retryBackoff(h.retries))
+	} else {
+		h.Status = HealthHealthy
+		h.Connected = true
+		h.LastSuccess = now
+	}
+	m.health[ds.Name] = h
+}
+
+// retryBackoff 返回第n次重新连接失败后的等待时间：检查间隔按失败次数翻倍，不超过 max_backoff
+func (m *Manager) retryBackoff(n int) time.Duration {
+	interval, maxBackoff := 30*time.Second, 300*time.Second
+	if m.checker != nil {
+		interval = time.Duration(m.checker.cfg.Interval) * time.Second
+		maxBackoff = time.Duration(m.checker.cfg.MaxBackoff) * time.Second
+	}
+	backoff := interval
+	for i := 1; i < n && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// StartHealthChecks 启动连接健康检查：定期检查SQL和MongoDB连接，连接不可用时按退避间隔重新连接
+func (m *Manager) StartHealthChecks(cfg config.DataSourceHealthConfig, logger logger.Logger) {
+	if cfg.Interval <= 0 {
+		return
+	}
+	m.mu.Lock()
+	if m.checker != nil {
+		m.mu.Unlock()
+		return
+	}
+	checker := &healthChecker{cfg: cfg, logger: logger, stop: make(chan struct{}), done: make(chan struct{})}
+	m.checker = checker
+	m.mu.Unlock()
+
+	go func() {
+		defer close(checker.done)
+		ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-checker.stop:
+				return
+			case <-ticker.C:
+				m.checkAll()
+			}
+		}
+	}()
+	logger.Infof("Datasource health checks started, interval %ds", cfg.Interval)
+}
+
+// stopHealthChecks 停止健康检查并等待正在进行的检查结束
+func (m *Manager) stopHealthChecks() {
+	m.mu.Lock()
+	checker := m.checker
+	m.checker = nil
+	m.mu.Unlock()
+	if checker != nil {
+		close(checker.stop)
+		<-checker.done
+	}
+}
+
+// checkAll 并发检查所有数据源
+func (m *Manager) checkAll() {
+	var wg sync.WaitGroup
+	for _, ds := range m.ListDataSources() {
+		wg.Add(1)
+		go func(ds *models.DataSource) {
+			defer wg.Done()
+			m.check(ds)
+		}(ds)
+	}
+	wg.Wait()
+}
+
+// check 检查单个数据源：已连接时检查连接，未连接或检查失败时到达重试时间后重新连接
+func (m *Manager) check(ds *models.DataSource) {
+	m.mu.RLock()
+	h, ok := m.health[ds.Name]
+	connected := ok && h.Connected
+	checker := m.checker
+	m.mu.RUnlock()
+	if !ok || checker == nil {
+		return
+	}
+
+	if connected {
+		if !pingable(ds.Type) {
+			return
+		}
+		start := time.Now()
+		err := m.ping(ds.Name)
+		latency := time.Since(start)
+		if m.recordPing(ds, err, latency) {
+			checker.logger.Warnf("Datasource %s is unhealthy: %v", ds.Name, err)
+		}
+		if err == nil {
+			return
+		}
+	}
+
+	m.mu.RLock()
+	due := m.health[ds.Name] == h && !time.Now().Before(h.NextRetry)
+	m.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	err := m.reconnect(ds)
+	if err != nil {
+		var retryAt time.Time
+		m.mu.Lock()
+		if m.health[ds.Name] == h {
+			h.Status = HealthUnhealthy
+			h.Connected = false
+			h.LastCheck = time.Now()
+			h.LastError = err.Error()
+			h.Failures++
+			h.retries++
+			h.NextRetry = h.LastCheck.Add(m.retryBackoff(h.retries))
+			retryAt = h.NextRetry
+		}
+		m.mu.Unlock()
+		checker.logger.Errorf("Failed to reconnect datasource %s (retry at %s): %v", ds.Name, retryAt.Format(time.RFC3339), err)
+		return
+	}
+	checker.logger.Infof("Datasource %s reconnected", ds.Name)
+}
+
+// ping 使用现有连接检查数据源
+func (m *Manager) ping(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	m.mu.RLock()
+	db := m.sqlDBs[name]
+	client := m.mongoDBs[name]
+	m.mu.RUnlock()
+	if db != nil {
+		return db.PingContext(ctx)
+	}
+	if client != nil {
+		return client.Ping(ctx, nil)
+	}
+	return nil
+}
+
+// recordPing 记录检查结果，返回数据源是否由健康变为不健康
+func (m *Manager) recordPing(ds *models.DataSource, err error, latency time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.health[ds.Name]
+	if !ok {
+		return false
+	}
+	h.LastCheck = time.Now()
+	h.LatencyMs = float64(latency) / float64(time.Millisecond)
+	if err == nil {
+		h.Status = HealthHealthy
+		h.LastSuccess = h.LastCheck
+		h.LastError = ""
+		h.Failures = 0
+		h.retries = 0
+		h.NextRetry = time.Time{}
+		return false
+	}
+
+	wasHealthy := h.Status == HealthHealthy
+	h.Status = HealthUnhealthy
+	h.LastError = err.Error()
+	h.Failures++
+	if wasHealthy {
+		// 第一次检查失败时立即重新连接
+		h.NextRetry = h.LastCheck
+	}
+	return wasHealthy
+}
+
+// reconnect 创建新连接并替换旧连接，创建期间不持有锁，不影响正在使用旧连接的任务
+func (m *Manager) reconnect(ds *models.DataSource) error {
+	conn := NewManager()
+	if err := conn.AddDataSource(ds); err != nil {
+		conn.Close()
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dataSources[ds.Name] != ds {
+		// 重新连接期间数据源已被修改或删除
+		conn.Close()
+		return nil
+	}
+	m.closeConnections(ds.Name)
+	m.takeConnections(conn, ds.Name)
+
+	h := m.health[ds.Name]
+	now := time.Now()
+	h.Status = HealthHealthy
+	h.Connected = true
+	h.LastCheck = now
+	h.LastSuccess = now
+	h.LastError = ""
+	h.Failures = 0
+	h.retries = 0
+	h.NextRetry = time.Time{}
+	h.Reconnects++
+	return nil
+}
+
+// Health 返回数据源的健康状态
+func (m *Manager) Health(name string) (Health, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	h, ok := m.health[name]
+	if !ok {
+		return Health{}, false
+	}
+	return *h, true
+}
+
+// HealthAll 返回所有数据源的健康状态，按名称排序
+func (m *Manager) HealthAll() []Health {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]Health, 0, len(m.health))
+	for _, h := range m.health {
+		result = append(result, *h)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,68 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+// 键变更事件类型
+const (
+	KVEventPut    = "put"
+	KVEventDelete = "delete"
+)
+
+// KVStore 键值存储客户端（Consul、etcd）
+type KVStore interface {
+	// Ping 检查服务是否可用
+	Ping(ctx context.Context) error
+	// Get 读取单个键，键不存在时返回 nil
+	Get(ctx context.Context, key string) (*KVPair, error)
+	// List 读取前缀下的所有键，按键排序
+	List(ctx context.Context, prefix string) ([]KVPair, error)
+	// Put 写入键，返回写入后的版本
+	Put(ctx context.Context, key string, value []byte) (uint64, error)
+	// Delete 删除键，prefix为true时删除前缀下的所有键
+	Delete(ctx context.Context, key string, prefix bool) error
+	// Watch 创建监听，prefix为true时监听前缀下的所有键
+	Watch(key string, prefix bool) KVWatch
+}
+
+// KVWatch 键监听
+type KVWatch interface {
+	// Next 阻塞直到监听的键发生变化并返回变更事件
+	//
+	// 首次调用时记录当前状态作为基线，已有的键不会作为事件返回；返回错误后可以继续调用，监听会从上次的版本恢复。
+	Next(ctx context.Context) ([]KVEvent, error)
+	// Close 关闭监听
+	Close()
+}
+
+// KVPair 键值对
+type KVPair struct {
+	Key      string
+	Value    []byte
+	Revision uint64 // 最后修改的版本（Consul ModifyIndex，etcd mod_revision）
+}
+
+// DecodeValue 返回键的值，decodeJSON为true且值为合法JSON时返回解析后的对象，否则返回字符串
+func (p KVPair) DecodeValue(decodeJSON bool) interface{} {
+	if decodeJSON {
+		var decoded interface{}
+		if err := json.Unmarshal(p.Value, &decoded); err == nil {
+			return decoded
+		}
+	}
+	return string(p.Value)
+}
+
+// KVEvent 键变更事件
+type KVEvent struct {
+	Type string // put 或 delete
+	KVPair
+}
+
+// sortKVPairs 按键排序
+func sortKVPairs(pairs []KVPair) {
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+}
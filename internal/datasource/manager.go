@@ -1,6 +1,7 @@
 package datasource
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"sync"
@@ -21,7 +22,14 @@ type Manager struct {
 	mu          sync.RWMutex
 	sqlDBs      map[string]*sql.DB
 	mongoDBs    map[string]*mongo.Client
+	s3Clients   map[string]*S3Client
+	warehouses  map[string]WarehouseClient
+	kvStores    map[string]KVStore
+	esClients   map[string]*ESClient
 	dataSources map[string]*models.DataSource
+	health      map[string]*Health
+	checker     *healthChecker
+	aliases     map[string]models.DataSourceAlias
 }
 
 // NewManager 创建新的数据源管理器
@@ -29,7 +37,13 @@ func NewManager() *Manager {
 	return &Manager{
 		sqlDBs:      make(map[string]*sql.DB),
 		mongoDBs:    make(map[string]*mongo.Client),
+		s3Clients:   make(map[string]*S3Client),
+		warehouses:  make(map[string]WarehouseClient),
+		kvStores:    make(map[string]KVStore),
+		esClients:   make(map[string]*ESClient),
 		dataSources: make(map[string]*models.DataSource),
+		health:      make(map[string]*Health),
+		aliases:     make(map[string]models.DataSourceAlias),
 	}
 }
 
@@ -42,14 +56,101 @@ func (m *Manager) AddDataSource(ds *models.DataSource) error {
 	m.dataSources[ds.Name] = ds
 
 	// 根据类型创建连接
+	var err error
 	switch ds.Type {
-	case "mysql", "postgresql", "sqlserver", "oracle":
-		return m.createSQLConnection(ds)
+	case "mysql", "postgresql", "sqlserver", "oracle", "sqlite", "clickhouse":
+		err = m.createSQLConnection(ds)
 	case "mongodb":
-		return m.createMongoConnection(ds)
+		err = m.createMongoConnection(ds)
+	case "s3":
+		err = m.createS3Client(ds)
+	case "snowflake", "bigquery":
+		err = m.createWarehouseClient(ds)
+	case "consul", "etcd":
+		err = m.createKVStore(ds)
+	case "elasticsearch":
+		err = m.createESClient(ds)
 	default:
 		return fmt.Errorf("unsupported database type: %s", ds.Type)
 	}
+
+	// 连接失败时保留配置，由健康检查按退避间隔重新连接
+	m.recordConnect(ds, err)
+	return err
+}
+
+// LoadDataSources 并发连接多个数据源，用于服务启动时加载已保存的数据源，返回连接失败的数据源及错误
+//
+// 连接失败的数据源同样注册，由健康检查按退避间隔重新连接；已注册的同名数据源不会被替换。
+func (m *Manager) LoadDataSources(dataSources []*models.DataSource) map[string]error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	for _, ds := range dataSources {
+		wg.Add(1)
+		go func(ds *models.DataSource) {
+			defer wg.Done()
+			// 在独立的管理器中创建连接，避免连接期间持有锁
+			conn := NewManager()
+			err := conn.AddDataSource(ds)
+			if !m.adopt(conn, ds, err) {
+				conn.Close()
+			}
+			if err != nil {
+				mu.Lock()
+				errs[ds.Name] = err
+				mu.Unlock()
+			}
+		}(ds)
+	}
+	wg.Wait()
+	return errs
+}
+
+// TestConnection 在独立的管理器中创建并关闭数据源连接，不影响已注册的同名数据源
+func (m *Manager) TestConnection(ds *models.DataSource) error {
+	conn := NewManager()
+	defer conn.Close()
+	return conn.AddDataSource(ds)
+}
+
+// adopt 注册在独立管理器中创建的数据源连接，数据源类型不支持或同名数据源已存在时返回false
+func (m *Manager) adopt(conn *Manager, ds *models.DataSource, err error) bool {
+	if _, ok := conn.health[ds.Name]; !ok {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.dataSources[ds.Name]; exists {
+		return false
+	}
+	m.dataSources[ds.Name] = ds
+	m.takeConnections(conn, ds.Name)
+	m.recordConnect(ds, err)
+	return true
+}
+
+// takeConnections 将另一个管理器中数据源的连接移动到当前管理器，调用时需持有写锁
+func (m *Manager) takeConnections(conn *Manager, name string) {
+	if db, ok := conn.sqlDBs[name]; ok {
+		m.sqlDBs[name] = db
+	}
+	if client, ok := conn.mongoDBs[name]; ok {
+		m.mongoDBs[name] = client
+	}
+	if client, ok := conn.s3Clients[name]; ok {
+		m.s3Clients[name] = client
+	}
+	if client, ok := conn.warehouses[name]; ok {
+		m.warehouses[name] = client
+	}
+	if store, ok := conn.kvStores[name]; ok {
+		m.kvStores[name] = store
+	}
+	if client, ok := conn.esClients[name]; ok {
+		m.esClients[name] = client
+	}
 }
 
 // GetSQLDB 获取SQL数据库连接
@@ -57,30 +158,145 @@ func (m *Manager) GetSQLDB(name string) (*sql.DB, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	db, exists := m.sqlDBs[name]
+	db, exists := m.sqlDBs[m.resolve(name, "")]
 	if !exists {
 		return nil, fmt.Errorf("datasource %s not found", name)
 	}
 	return db, nil
 }
 
+// GetDataSourceType 获取数据源类型，名称可以是别名
+func (m *Manager) GetDataSourceType(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ds, exists := m.dataSources[m.resolve(name, "")]
+	if !exists {
+		return "", fmt.Errorf("datasource %s not found", name)
+	}
+	return ds.Type, nil
+}
+
+// GetMaskedColumns 获取数据源声明的脱敏列，同时返回别名解析后的数据源名称
+func (m *Manager) GetMaskedColumns(name string) (string, []string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	resolved := m.resolve(name, "")
+	ds, exists := m.dataSources[resolved]
+	if !exists {
+		return "", nil, fmt.Errorf("datasource %s not found", name)
+	}
+	return resolved, ds.MaskedColumns, nil
+}
+
 // GetMongoDB 获取MongoDB连接
 func (m *Manager) GetMongoDB(name string) (*mongo.Client, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	client, exists := m.mongoDBs[name]
+	client, exists := m.mongoDBs[m.resolve(name, "")]
 	if !exists {
 		return nil, fmt.Errorf("datasource %s not found", name)
 	}
 	return client, nil
 }
 
+// GetS3Client 获取对象存储客户端
+func (m *Manager) GetS3Client(name string) (*S3Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, exists := m.s3Clients[m.resolve(name, "")]
+	if !exists {
+		return nil, fmt.Errorf("datasource %s not found", name)
+	}
+	return client, nil
+}
+
+// GetWarehouse 获取数据仓库客户端
+func (m *Manager) GetWarehouse(name string) (WarehouseClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, exists := m.warehouses[m.resolve(name, "")]
+	if !exists {
+		return nil, fmt.Errorf("datasource %s not found", name)
+	}
+	return client, nil
+}
+
+// GetKVStore 获取键值存储客户端
+func (m *Manager) GetKVStore(name string) (KVStore, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	store, exists := m.kvStores[m.resolve(name, "")]
+	if !exists {
+		return nil, fmt.Errorf("datasource %s not found", name)
+	}
+	return store, nil
+}
+
+// GetESClient 获取Elasticsearch客户端
+func (m *Manager) GetESClient(name string) (*ESClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, exists := m.esClients[m.resolve(name, "")]
+	if !exists {
+		return nil, fmt.Errorf("datasource %s not found", name)
+	}
+	return client, nil
+}
+
+// SetAliases 替换数据源别名
+func (m *Manager) SetAliases(aliases []models.DataSourceAlias) {
+	byName := make(map[string]models.DataSourceAlias, len(aliases))
+	for _, alias := range aliases {
+		byName[alias.Name] = alias
+	}
+
+	m.mu.Lock()
+	m.aliases = byName
+	m.mu.Unlock()
+}
+
+// Resolve 返回名称在项目下对应的数据源名称：名称是别名时返回映射的数据源，否则返回名称本身
+//
+// 数据源名称优先于同名的别名。
+func (m *Manager) Resolve(name, project string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.resolve(name, project)
+}
+
+// resolve 解析别名，调用时需持有锁
+func (m *Manager) resolve(name, project string) string {
+	if _, exists := m.dataSources[name]; exists {
+		return name
+	}
+	if alias, ok := m.aliases[name]; ok {
+		return alias.Target(project)
+	}
+	return name
+}
+
 // RemoveDataSource 移除数据源
 func (m *Manager) RemoveDataSource(name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.closeConnections(name)
+
+	// 删除配置
+	delete(m.dataSources, name)
+	delete(m.health, name)
+	return nil
+}
+
+// closeConnections 关闭并移除数据源的连接，调用时需持有写锁
+func (m *Manager) closeConnections(name string) {
 	// 关闭SQL连接
 	if db, exists := m.sqlDBs[name]; exists {
 		db.Close()
@@ -93,9 +309,16 @@ func (m *Manager) RemoveDataSource(name string) error {
 		delete(m.mongoDBs, name)
 	}
 
-	// 删除配置
-	delete(m.dataSources, name)
-	return nil
+	// 关闭数据仓库客户端
+	if client, exists := m.warehouses[name]; exists {
+		client.Close()
+		delete(m.warehouses, name)
+	}
+
+	// 对象存储、键值存储和Elasticsearch客户端无需关闭
+	delete(m.s3Clients, name)
+	delete(m.kvStores, name)
+	delete(m.esClients, name)
 }
 
 // ListDataSources 列出所有数据源
@@ -110,8 +333,10 @@ func (m *Manager) ListDataSources() []*models.DataSource {
 	return result
 }
 
-// Close 关闭所有连接
+// Close 停止健康检查并关闭所有连接
 func (m *Manager) Close() {
+	m.stopHealthChecks()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -124,11 +349,16 @@ func (m *Manager) Close() {
 	for _, client := range m.mongoDBs {
 		client.Disconnect(nil)
 	}
+
+	// 关闭所有数据仓库客户端
+	for _, client := range m.warehouses {
+		client.Close()
+	}
 }
 
 // createSQLConnection 创建SQL数据库连接
 func (m *Manager) createSQLConnection(ds *models.DataSource) error {
-	var dsn string
+	driverName, dsn := ds.Type, ""
 
 	switch ds.Type {
 	case "mysql":
@@ -147,13 +377,26 @@ func (m *Manager) createSQLConnection(ds *models.DataSource) error {
 	case "oracle":
 		dsn = fmt.Sprintf("%s/%s@%s:%d/%s",
 			ds.Username, ds.Password, ds.Host, ds.Port, ds.Database)
+	case "sqlite":
+		// database为数据库文件路径
+		if ds.Database == "" {
+			return fmt.Errorf("database file path is required for sqlite datasource")
+		}
+		dsn = fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)", ds.Database)
+	case "clickhouse":
+		// 使用 openClickHouse 创建的连接器，参数作为服务端参数传递
 	default:
 		return fmt.Errorf("unsupported SQL database type: %s", ds.Type)
 	}
 
-	db, err := sql.Open(ds.Type, dsn)
-	if err != nil {
-		return err
+	var db *sql.DB
+	if ds.Type == "clickhouse" {
+		db = openClickHouse(ds)
+	} else {
+		var err error
+		if db, err = sql.Open(driverName, dsn); err != nil {
+			return err
+		}
 	}
 
 	// 配置连接池
@@ -191,3 +434,88 @@ func (m *Manager) createMongoConnection(ds *models.DataSource) error {
 	m.mongoDBs[ds.Name] = client
 	return nil
 }
+
+// createS3Client 创建对象存储客户端
+func (m *Manager) createS3Client(ds *models.DataSource) error {
+	client, err := newS3Client(ds)
+	if err != nil {
+		return err
+	}
+
+	// 测试连接
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		return err
+	}
+
+	m.s3Clients[ds.Name] = client
+	return nil
+}
+
+// createWarehouseClient 创建数据仓库客户端
+func (m *Manager) createWarehouseClient(ds *models.DataSource) error {
+	var client WarehouseClient
+	var err error
+	switch ds.Type {
+	case "snowflake":
+		client, err = newSnowflakeClient(ds)
+	case "bigquery":
+		client, err = newBigQueryClient(ds)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 测试连接
+	if err := pingWarehouse(client); err != nil {
+		client.Close()
+		return err
+	}
+
+	m.warehouses[ds.Name] = client
+	return nil
+}
+
+// createKVStore 创建键值存储客户端
+func (m *Manager) createKVStore(ds *models.DataSource) error {
+	var store KVStore
+	var err error
+	switch ds.Type {
+	case "consul":
+		store, err = newConsulClient(ds)
+	case "etcd":
+		store, err = newEtcdClient(ds)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 测试连接
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := store.Ping(ctx); err != nil {
+		return err
+	}
+
+	m.kvStores[ds.Name] = store
+	return nil
+}
+
+// createESClient 创建Elasticsearch客户端
+func (m *Manager) createESClient(ds *models.DataSource) error {
+	client, err := newESClient(ds)
+	if err != nil {
+		return err
+	}
+
+	// 测试连接
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		return err
+	}
+
+	m.esClients[ds.Name] = client
+	return nil
+}
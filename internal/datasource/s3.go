@@ -0,0 +1,423 @@
+package datasource
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// defaultS3Region 未配置区域时使用的默认区域
+const defaultS3Region = "us-east-1"
+
+// unsignedPayload 预签名URL不对请求体签名
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Client S3兼容对象存储客户端（AWS S3、MinIO等），使用 Signature V4 签名
+type S3Client struct {
+	endpoint      string // scheme://host[:port]
+	host          string
+	region        string
+	accessKey     string
+	secretKey     string
+	defaultBucket string
+	virtualHost   bool // AWS使用虚拟主机风格，其余使用路径风格
+	httpClient    *http.Client
+}
+
+// S3Object 对象信息
+type S3Object struct {
+	Key          string    `xml:"Key" json:"key"`
+	Size         int64     `xml:"Size" json:"size"`
+	ETag         string    `xml:"ETag" json:"etag"`
+	LastModified time.Time `xml:"LastModified" json:"last_modified"`
+	StorageClass string    `xml:"StorageClass" json:"storage_class,omitempty"`
+}
+
+// S3ListResult 列举结果
+type S3ListResult struct {
+	Objects               []S3Object `json:"objects"`
+	Prefixes              []string   `json:"prefixes"`
+	IsTruncated           bool       `json:"is_truncated"`
+	NextContinuationToken string     `json:"next_continuation_token,omitempty"`
+}
+
+// S3GetResult 下载结果
+type S3GetResult struct {
+	Body         []byte
+	ContentType  string
+	ETag         string
+	LastModified string
+	Metadata     map[string]string
+}
+
+// newS3Client 根据数据源配置创建客户端
+//
+// host为服务地址，port为0时使用协议默认端口，database为默认存储桶，
+// username/password为Access Key ID和Secret Access Key。
+func newS3Client(ds *models.DataSource) (*S3Client, error) {
+	if ds.Username == "" || ds.Password == "" {
+		return nil, fmt.Errorf("access key (username) and secret key (password) are required for s3 datasource")
+	}
+
+	scheme := "http"
+	if ds.SSL {
+		scheme = "https"
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(ds.Host, "https://"), "http://"), "/")
+	if ds.Port != 0 {
+		host = fmt.Sprintf("%s:%d", host, ds.Port)
+	}
+	region := ds.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	return &S3Client{
+		endpoint:      scheme + "://" + host,
+		host:          host,
+		region:        region,
+		accessKey:     ds.Username,
+		secretKey:     ds.Password,
+		defaultBucket: ds.Database,
+		virtualHost:   strings.HasSuffix(strings.Split(host, ":")[0], "amazonaws.com"),
+		httpClient:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Bucket 返回实际使用的存储桶，未指定时使用数据源的默认存储桶
+func (c *S3Client) Bucket(bucket string) (string, error) {
+	if bucket == "" {
+		bucket = c.defaultBucket
+	}
+	if bucket == "" {
+		return "", fmt.Errorf("bucket is required")
+	}
+	return bucket, nil
+}
+
+// Ping 检查凭据和默认存储桶是否可用
+func (c *S3Client) Ping(ctx context.Context) error {
+	if c.defaultBucket == "" {
+		resp, err := c.do(ctx, http.MethodGet, "", "", nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	resp, err := c.do(ctx, http.MethodHead, c.defaultBucket, "", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PutObject 上传对象，返回ETag
+func (c *S3Client) PutObject(ctx context.Context, bucket, key string, body []byte, contentType string, metadata map[string]string) (string, error) {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	for k, v := range metadata {
+		header.Set("X-Amz-Meta-"+k, v)
+	}
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, nil, header, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// GetObject 下载对象，超过maxSize时返回错误
+func (c *S3Client) GetObject(ctx context.Context, bucket, key string, maxSize int64) (*S3GetResult, error) {
+	resp, err := c.do(ctx, http.MethodGet, bucket, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("object %s is %d bytes, exceeds limit of %d bytes", key, resp.ContentLength, maxSize)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %v", err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("object %s exceeds limit of %d bytes", key, maxSize)
+	}
+
+	result := &S3GetResult{
+		Body:         body,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         strings.Trim(resp.Header.Get("ETag"), `"`),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Metadata:     make(map[string]string),
+	}
+	for name, values := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") && len(values) > 0 {
+			result.Metadata[strings.ToLower(name[len("x-amz-meta-"):])] = values[0]
+		}
+	}
+	return result, nil
+}
+
+// DeleteObject 删除对象
+func (c *S3Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, bucket, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListObjects 列举对象（ListObjectsV2）
+func (c *S3Client) ListObjects(ctx context.Context, bucket, prefix, delimiter, continuationToken string, maxKeys int) (*S3ListResult, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if continuationToken != "" {
+		query.Set("continuation-token", continuationToken)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, bucket, "", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Contents       []S3Object `xml:"Contents"`
+		CommonPrefixes []struct {
+			Prefix string `xml:"Prefix"`
+		} `xml:"CommonPrefixes"`
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid list response: %v", err)
+	}
+
+	result := &S3ListResult{
+		Objects:               body.Contents,
+		Prefixes:              make([]string, 0, len(body.CommonPrefixes)),
+		IsTruncated:           body.IsTruncated,
+		NextContinuationToken: body.NextContinuationToken,
+	}
+	if result.Objects == nil {
+		result.Objects = []S3Object{}
+	}
+	for i := range result.Objects {
+		result.Objects[i].ETag = strings.Trim(result.Objects[i].ETag, `"`)
+	}
+	for _, p := range body.CommonPrefixes {
+		result.Prefixes = append(result.Prefixes, p.Prefix)
+	}
+	return result, nil
+}
+
+// PresignURL 生成预签名URL
+func (c *S3Client) PresignURL(method, bucket, key string, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > 7*24*time.Hour {
+		return "", fmt.Errorf("expires must be between 1 second and 7 days")
+	}
+
+	now := time.Now().UTC()
+	rawURL, host, path := c.objectURL(bucket, key)
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", c.accessKey+"/"+c.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	header := http.Header{}
+	header.Set("Host", host)
+	signature := c.signature(method, path, query, header, unsignedPayload, now)
+	query.Set("X-Amz-Signature", signature)
+
+	return rawURL + "?" + canonicalQuery(query), nil
+}
+
+// do 发送签名请求，状态码>=300时返回包含S3错误信息的错误
+func (c *S3Client) do(ctx context.Context, method, bucket, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	now := time.Now().UTC()
+	rawURL, host, path := c.objectURL(bucket, key)
+	if len(query) > 0 {
+		rawURL += "?" + canonicalQuery(query)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = strings.NewReader(string(body))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	payloadHash := sha256.Sum256(body)
+	payload := hex.EncodeToString(payloadHash[:])
+	req.Header.Set("Host", host)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+
+	signed := signedHeaders(req.Header)
+	signature := c.signature(method, path, query, req.Header, payload, now)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, c.scope(now), strings.Join(signed, ";"), signature))
+	req.Header.Del("Host")
+	req.Host = host
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %v", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var s3Err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if xml.Unmarshal(data, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("s3 error (%d): %s: %s", resp.StatusCode, s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("s3 error (%d)", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// objectURL 返回请求URL、Host和规范路径
+func (c *S3Client) objectURL(bucket, key string) (string, string, string) {
+	host := c.host
+	path := "/"
+	if bucket != "" {
+		if c.virtualHost {
+			host = bucket + "." + c.host
+		} else {
+			path += uriEncode(bucket, false) + "/"
+		}
+	}
+	if key != "" {
+		path += uriEncode(key, true)
+	}
+	scheme := strings.SplitN(c.endpoint, "://", 2)[0]
+	return scheme + "://" + host + path, host, path
+}
+
+// scope 返回签名范围 date/region/s3/aws4_request
+func (c *S3Client) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + c.region + "/s3/aws4_request"
+}
+
+// signature 计算 Signature V4 签名
+func (c *S3Client) signature(method, path string, query url.Values, header http.Header, payloadHash string, now time.Time) string {
+	signed := signedHeaders(header)
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(header.Get(name)) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		c.scope(now),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	hmacSHA256 := func(key []byte, data string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return mac.Sum(nil)
+	}
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signedHeaders 返回参与签名的请求头（host、content-type和x-amz-*），已排序
+func signedHeaders(header http.Header) []string {
+	var names []string
+	for name := range header {
+		lower := strings.ToLower(name)
+		if lower == "host" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// canonicalQuery 按键排序并按S3规则编码查询参数
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 按 Signature V4 规则编码，仅保留非保留字符，keepSlash时保留路径分隔符
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for _, ch := range []byte(s) {
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && keepSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
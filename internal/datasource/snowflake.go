@@ -0,0 +1,253 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// defaultSnowflakePageSize 未指定每页行数时的默认值
+const defaultSnowflakePageSize = 1000
+
+// SnowflakeClient Snowflake客户端，使用官方驱动（gosnowflake）和密钥对认证
+type SnowflakeClient struct {
+	db *sql.DB
+}
+
+// newSnowflakeClient 根据数据源配置创建客户端
+//
+// host为账户地址（如 xy12345.ap-southeast-1.snowflakecomputing.com），username为用户名，
+// password为PEM格式的RSA私钥，database为默认数据库，options支持 account、schema、warehouse、role。
+func newSnowflakeClient(ds *models.DataSource) (*SnowflakeClient, error) {
+	if ds.Username == "" || ds.Password == "" {
+		return nil, fmt.Errorf("user (username) and private key (password) are required for snowflake datasource")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(ds.Password))
+	if err != nil {
+		return nil, fmt.Errorf("invalid snowflake private key: %v", err)
+	}
+
+	host := strings.TrimSuffix(strings.TrimPrefix(ds.Host, "https://"), "/")
+
+	// 账户标识不含区域和云平台部分
+	account := ds.Options["account"]
+	if account == "" {
+		account = host
+	}
+	account = strings.Split(account, ".")[0]
+
+	config := sf.Config{
+		Account:       account,
+		User:          ds.Username,
+		Authenticator: sf.AuthTypeJwt,
+		PrivateKey:    key,
+		Host:          host,
+		Port:          ds.Port,
+		Database:      ds.Database,
+		Schema:        ds.Options["schema"],
+		Warehouse:     ds.Options["warehouse"],
+		Role:          ds.Options["role"],
+	}
+	return &SnowflakeClient{db: sql.OpenDB(sf.NewConnector(sf.SnowflakeDriver{}, config))}, nil
+}
+
+// Submit 异步提交查询，返回查询ID
+func (c *SnowflakeClient) Submit(ctx context.Context, query string, params []interface{}) (string, error) {
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to snowflake: %v", err)
+	}
+	defer conn.Close()
+
+	args := make([]driver.NamedValue, len(params))
+	for i, param := range params {
+		args[i] = driver.NamedValue{Ordinal: i + 1, Value: snowflakeBinding(param)}
+	}
+
+	// 异步模式下驱动在后台轮询结果，取得查询ID后取消轮询，服务端的查询继续执行
+	asyncCtx, cancel := context.WithCancel(sf.WithAsyncMode(ctx))
+	defer cancel()
+
+	var queryID string
+	err = conn.Raw(func(driverConn interface{}) error {
+		rows, err := driverConn.(driver.QueryerContext).QueryContext(asyncCtx, query, args)
+		if err != nil {
+			return err
+		}
+		queryID = rows.(sf.SnowflakeRows).GetQueryID()
+		cancel()
+		rows.Close()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if queryID == "" {
+		return "", fmt.Errorf("snowflake did not return a query ID")
+	}
+	return queryID, nil
+}
+
+// Fetch 读取一页结果，pageToken为起始行号
+//
+// 每次读取都会重新打开结果集并跳过之前的行，适合分页数不多的结果。
+func (c *SnowflakeClient) Fetch(ctx context.Context, queryID, pageToken string, pageSize int) (*WarehousePage, error) {
+	offset := 0
+	if pageToken != "" {
+		n, err := strconv.Atoi(pageToken)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid page token %s", pageToken)
+		}
+		offset = n
+	}
+	if pageSize <= 0 {
+		pageSize = defaultSnowflakePageSize
+	}
+
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to snowflake: %v", err)
+	}
+	defer conn.Close()
+
+	var status *sf.SnowflakeQueryStatus
+	err = conn.Raw(func(driverConn interface{}) error {
+		var err error
+		status, err = driverConn.(sf.SnowflakeConnection).GetQueryStatus(ctx, queryID)
+		return err
+	})
+	// 刚提交的查询可能还没有状态（ErrQueryStatus 且不含服务端错误码），按运行中处理
+	var sfErr *sf.SnowflakeError
+	if errors.As(err, &sfErr) && (sfErr.Number == sf.ErrQueryIsRunning || sfErr.Number == sf.ErrQueryStatus && len(sfErr.MessageArgs) == 0) {
+		return &WarehousePage{QueryID: queryID, Status: QueryRunning, Columns: []string{}, Rows: []map[string]interface{}{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(sf.WithFetchResultByID(ctx, queryID), "")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	page := &WarehousePage{
+		QueryID:   queryID,
+		Status:    QueryDone,
+		Columns:   make([]string, len(columnTypes)),
+		Rows:      []map[string]interface{}{},
+		TotalRows: status.ProducedRows,
+	}
+	for i, column := range columnTypes {
+		page.Columns[i] = column.Name()
+	}
+
+	values := make([]interface{}, len(columnTypes))
+	pointers := make([]interface{}, len(columnTypes))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for index := 0; rows.Next(); index++ {
+		if index < offset {
+			continue
+		}
+		if index == offset+pageSize {
+			page.NextPageToken = strconv.Itoa(index)
+			break
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columnTypes))
+		for i, column := range columnTypes {
+			row[column.Name()] = snowflakeValue(column.DatabaseTypeName(), values[i])
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	page.Count = len(page.Rows)
+	return page, nil
+}
+
+// Close 关闭连接池
+func (c *SnowflakeClient) Close() error {
+	return c.db.Close()
+}
+
+// snowflakeBinding 将参数转换为驱动支持的绑定值，整数值的浮点数按整数绑定
+func snowflakeBinding(value interface{}) driver.Value {
+	switch v := value.(type) {
+	case nil, bool, string, int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// snowflakeValue 按列类型转换驱动返回的值
+func snowflakeValue(columnType string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		switch columnType {
+		case "DATE":
+			return v.Format("2006-01-02")
+		case "TIME":
+			return v.Format("15:04:05.999999999")
+		}
+		return v.Format(time.RFC3339Nano)
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64()
+		}
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	case string:
+		switch columnType {
+		case "FIXED":
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n
+			}
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		case "REAL":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		case "BOOLEAN":
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		case "VARIANT", "OBJECT", "ARRAY":
+			var data interface{}
+			if err := json.Unmarshal([]byte(v), &data); err == nil {
+				return data
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,4 @@
+package datasource
+
+// SQLite驱动（纯Go实现，不依赖cgo），注册为 sqlite
+import _ "modernc.org/sqlite"
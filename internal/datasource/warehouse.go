@@ -0,0 +1,68 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 查询状态
+const (
+	QueryRunning = "running"
+	QueryDone    = "done"
+)
+
+// WarehouseClient 数据仓库客户端
+//
+// 查询以异步方式提交，返回查询ID；结果按页读取，查询未完成时返回 running 状态。
+type WarehouseClient interface {
+	// Submit 提交查询，params为按位置绑定的参数（SQL中使用 ?）
+	Submit(ctx context.Context, query string, params []interface{}) (string, error)
+	// Fetch 读取一页结果，pageToken为空时读取第一页，pageSize为0时使用服务端默认值
+	Fetch(ctx context.Context, queryID, pageToken string, pageSize int) (*WarehousePage, error)
+	// Close 关闭客户端
+	Close() error
+}
+
+// WarehousePage 一页查询结果
+type WarehousePage struct {
+	QueryID       string                   `json:"query_id"`
+	Status        string                   `json:"status"`
+	Columns       []string                 `json:"columns"`
+	Rows          []map[string]interface{} `json:"rows"`
+	Count         int                      `json:"count"`
+	TotalRows     int64                    `json:"total_rows"`
+	NextPageToken string                   `json:"next_page_token,omitempty"`
+}
+
+// WaitQuery 轮询查询直到完成，返回第一页结果
+func WaitQuery(ctx context.Context, client WarehouseClient, queryID string, pageSize int, interval time.Duration) (*WarehousePage, error) {
+	for {
+		page, err := client.Fetch(ctx, queryID, "", pageSize)
+		if err != nil {
+			return nil, err
+		}
+		if page.Status == QueryDone {
+			return page, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("query %s did not finish in time: %v", queryID, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pingWarehouse 执行 SELECT 1 验证连接和凭据
+func pingWarehouse(client WarehouseClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	queryID, err := client.Submit(ctx, "SELECT 1", nil)
+	if err != nil {
+		return err
+	}
+	_, err = WaitQuery(ctx, client, queryID, 1, time.Second)
+	return err
+}
@@ -0,0 +1,318 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// dataKeySize 数据密钥长度（AES-256）
+const dataKeySize = 32
+
+// Keyring 主密钥集合，实现 models.PayloadCipher
+//
+// 每次加密生成随机的数据密钥加密数据，再用当前主密钥加密数据密钥；轮换主密钥后旧密钥仍可解密已有数据。
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// NewKeyring 按配置创建主密钥集合，第一个密钥用于加密；未配置密钥时返回nil
+func NewKeyring(cfg config.EncryptionConfig) (*Keyring, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, nil
+	}
+	k := &Keyring{active: cfg.Keys[0].ID, keys: make(map[string]cipher.AEAD, len(cfg.Keys))}
+	for _, key := range cfg.Keys {
+		raw, err := key.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s: %v", key.ID, err)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s: %v", key.ID, err)
+		}
+		k.keys[key.ID] = aead
+	}
+	return k, nil
+}
+
+// ActiveKeyID 返回加密新数据的主密钥ID
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Seal 加密数据
+func (k *Keyring) Seal(plaintext []byte) (*models.EncryptedPayload, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomNonce(aead)
+	if err != nil {
+		return nil, err
+	}
+
+	master := k.keys[k.active]
+	keyNonce, err := randomNonce(master)
+	if err != nil {
+		return nil, err
+	}
+	return &models.EncryptedPayload{
+		KeyID:      k.active,
+		DataKey:    master.Seal(keyNonce, keyNonce, dataKey, []byte(k.active)),
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	}, nil
+}
+
+// Open 解密数据
+func (k *Keyring) Open(payload *models.EncryptedPayload) ([]byte, error) {
+	master, ok := k.keys[payload.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %s is not configured", payload.KeyID)
+	}
+	size := master.NonceSize()
+	if len(payload.DataKey) < size {
+		return nil, fmt.Errorf("invalid data key")
+	}
+	dataKey, err := master.Open(nil, payload.DataKey[:size], payload.DataKey[size:], []byte(payload.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with key %s", payload.KeyID)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, payload.Nonce, payload.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload")
+	}
+	return plaintext, nil
+}
+
+// newAEAD 创建AES-GCM
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// randomNonce 生成随机nonce
+func randomNonce(aead cipher.AEAD) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// encryptedFields 加密存储的集合和字段：messages 为保存 models.NSQMessage 的字段，workflow_instances 的变量中也保存了触发消息；
+// sealed 为通过 models.SealFields 整体加密到 encrypted 字段的字段
+var encryptedFields = []struct {
+	collection string
+	messages   []string
+	sealed     []string
+}{
+	{collection: "workflow_instances", messages: []string{"message", "vars.nsq_message"}, sealed: []string{"vars", "results"}},
+	{collection: "execution_logs", sealed: models.ExecutionLogSealedFields},
+	{collection: "work_queue", messages: []string{"message"}},
+	{collection: "keyed_queue", messages: []string{"message"}},
+}
+
+// CollectionResult 单个集合的重新加密结果
+type CollectionResult struct {
+	Collection string `json:"collection"`
+	Matched    int64  `json:"matched"`         // 明文或用旧密钥加密的文档数
+	Rewritten  int64  `json:"rewritten"`       // 用当前密钥重新加密的文档数
+	Skipped    int64  `json:"skipped"`         // 读取后被其他写入修改或已删除而没有重写的文档数，重新执行时再检查
+	Failed     int64  `json:"failed"`          // 解密或写入失败的文档数
+	Error      string `json:"error,omitempty"` // 第一个失败的原因
+}
+
+// Result 重新加密结果
+type Result struct {
+	KeyID       string             `json:"key_id"`
+	Collections []CollectionResult `json:"collections"`
+	StartedAt   time.Time          `json:"started_at"`
+	Duration    int64              `json:"duration"` // 执行时间(毫秒)
+}
+
+// Reencrypt 用当前主密钥重新加密明文存储或用旧密钥加密的消息、执行日志和实例数据
+//
+// 启用加密前写入的数据为明文，轮换主密钥后旧数据仍用旧密钥加密；全部重新加密后才能从配置中删除旧密钥。
+// 需要先通过 models.SetPayloadCipher 设置 k，读写数据时才会解密和加密。
+func (k *Keyring) Reencrypt(ctx context.Context, mongoClient *mongodb.Client) (*Result, error) {
+	result := &Result{KeyID: k.active, Collections: []CollectionResult{}, StartedAt: time.Now()}
+	db := mongoClient.GetDatabase()
+
+	for _, ef := range encryptedFields {
+		cr := CollectionResult{Collection: ef.collection}
+		collection := db.Collection(ef.collection)
+
+		pending := bson.A{}
+		projection := bson.M{}
+		for _, field := range ef.messages {
+			pending = append(pending, bson.M{
+				field:                       bson.M{"$type": "object"},
+				field + ".encrypted.key_id": bson.M{"$ne": k.active},
+			})
+			projection[strings.Split(field, ".")[0]] = 1
+		}
+		if len(ef.sealed) > 0 {
+			plain := bson.A{}
+			for _, field := range ef.sealed {
+				plain = append(plain, bson.M{field: bson.M{"$exists": true}})
+				projection[field] = 1
+			}
+			pending = append(pending,
+				bson.M{models.SealedField + ".key_id": bson.M{"$exists": true, "$ne": k.active}},
+				bson.M{models.SealedField: bson.M{"$exists": false}, "$or": plain},
+			)
+			projection[models.SealedField] = 1
+		}
+		cursor, err := collection.Find(ctx, bson.M{"$or": pending}, options.Find().SetProjection(projection))
+		if err != nil {
+			return nil, fmt.Errorf("failed to find encrypted documents in %s: %v", ef.collection, err)
+		}
+
+		for cursor.Next(ctx) {
+			cr.Matched++
+			rewritten, err := k.rewrite(ctx, collection, cursor.Current, ef.messages, ef.sealed)
+			switch {
+			case err != nil:
+				if cr.Failed == 0 {
+					cr.Error = err.Error()
+				}
+				cr.Failed++
+			case rewritten:
+				cr.Rewritten++
+			default:
+				cr.Skipped++
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encrypted documents in %s: %v", ef.collection, err)
+		}
+		result.Collections = append(result.Collections, cr)
+	}
+
+	result.Duration = time.Since(result.StartedAt).Milliseconds()
+	return result, nil
+}
+
+// rewrite 解密文档中的消息和加密字段并用当前主密钥重新写入
+//
+// 整体加密的字段只在文档没有被其他写入更新时替换，执行中的实例被保存时已使用当前主密钥；
+// 文档已被修改或删除时没有写入，返回 false。
+func (k *Keyring) rewrite(ctx context.Context, collection *mongo.Collection, doc bson.Raw, messages, sealed []string) (bool, error) {
+	filter := bson.M{"_id": doc.Lookup("_id")}
+	if len(sealed) > 0 {
+		if nonce, err := doc.LookupErr(models.SealedField, "nonce"); err == nil {
+			filter[models.SealedField+".nonce"] = nonce
+		} else {
+			filter[models.SealedField] = bson.M{"$exists": false}
+		}
+	}
+
+	opened, err := models.OpenFields(doc)
+	if err != nil {
+		return false, err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(opened, &fields); err != nil {
+		return false, err
+	}
+	for _, field := range messages {
+		if err := resealMessage(fields, strings.Split(field, ".")); err != nil {
+			return false, err
+		}
+	}
+	plain, err := bson.Marshal(fields)
+	if err != nil {
+		return false, err
+	}
+	resealed := bson.Raw(plain)
+	if len(sealed) > 0 {
+		if resealed, err = models.SealFields(plain, sealed...); err != nil {
+			return false, err
+		}
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	for _, field := range messages {
+		root := strings.Split(field, ".")[0]
+		if value, err := resealed.LookupErr(root); err == nil && !containsString(sealed, root) {
+			set[root] = value
+		}
+	}
+	if value, err := resealed.LookupErr(models.SealedField); err == nil {
+		set[models.SealedField] = value
+		for _, field := range sealed {
+			unset[field] = ""
+		}
+	}
+	if len(set) == 0 {
+		return false, nil
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// resealMessage 用当前主密钥重新加密文档中 path 处的消息，字段不存在时忽略
+func resealMessage(doc bson.M, path []string) error {
+	sub, ok := doc[path[0]].(bson.M)
+	if !ok {
+		return nil
+	}
+	if len(path) > 1 {
+		return resealMessage(sub, path[1:])
+	}
+	raw, err := bson.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	var message models.NSQMessage
+	if err := bson.Unmarshal(raw, &message); err != nil {
+		return err
+	}
+	doc[path[0]] = &message
+	return nil
+}
+
+// containsString 判断字符串是否在列表中
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
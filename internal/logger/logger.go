@@ -23,6 +23,7 @@ type Logger interface {
 	Errorf(format string, args ...interface{})
 	Fatal(args ...interface{})
 	Fatalf(format string, args ...interface{})
+	SetLevel(level string) error
 }
 
 // LoggerImpl 日志实现
@@ -125,6 +126,16 @@ func (l *LoggerImpl) Fatalf(format string, args ...interface{}) {
 	l.logger.Fatalf(format, args...)
 }
 
+// SetLevel 运行时调整日志级别
+func (l *LoggerImpl) SetLevel(level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.logger.SetLevel(parsed)
+	return nil
+}
+
 // GraylogHook Graylog钩子
 type GraylogHook struct {
 	writer gelf.Writer
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"nsa/internal/config"
+
+	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/sirupsen/logrus"
+)
+
+// 安全事件结果
+const (
+	SecuritySuccess = "success"
+	SecurityFailure = "failure"
+)
+
+// SecurityLogger 安全事件日志（登录、认证失败、权限拒绝、令牌使用）
+//
+// 与应用日志分开输出，每条事件带 log_stream=security、event 和 outcome 字段，SIEM 可以直接按字段接入，
+// 不需要解析应用日志。未启用时所有方法为空操作。
+type SecurityLogger struct {
+	logger *logrus.Logger // 未启用时为nil
+}
+
+// NewSecurity 创建安全事件日志
+func NewSecurity(cfg config.LoggingConfig) *SecurityLogger {
+	if !cfg.Security.Enabled {
+		return &SecurityLogger{}
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	logger.SetFormatter(&logrus.JSONFormatter{
+		TimestampFormat: time.RFC3339Nano,
+	})
+	logger.SetOutput(os.Stdout)
+
+	if path := cfg.Security.Path; path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			logger.Errorf("Failed to create security log directory: %v", err)
+		} else if file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+			logger.Errorf("Failed to open security log file: %v", err)
+		} else {
+			logger.SetOutput(file)
+		}
+	}
+
+	// 未单独配置Graylog时与应用日志发送到同一个输入，通过 log_stream 字段路由到单独的 stream
+	graylog := cfg.Security.Graylog
+	if !graylog.Enabled {
+		graylog = cfg.Graylog
+	}
+	if graylog.Enabled {
+		gelfWriter, err := gelf.NewUDPWriter(fmt.Sprintf("%s:%d", graylog.Host, graylog.Port))
+		if err != nil {
+			logger.Errorf("Failed to create security Graylog writer: %v", err)
+		} else {
+			logger.AddHook(&GraylogHook{writer: gelfWriter})
+		}
+	}
+
+	return &SecurityLogger{logger: logger}
+}
+
+// Event 记录一条安全事件，outcome 为 SecuritySuccess 或 SecurityFailure，失败事件以警告级别输出
+func (s *SecurityLogger) Event(event, outcome string, fields map[string]interface{}) {
+	if s == nil || s.logger == nil {
+		return
+	}
+
+	entry := s.logger.WithFields(logrus.Fields(fields)).WithFields(logrus.Fields{
+		"log_stream": "security",
+		"event":      event,
+		"outcome":    outcome,
+	})
+	if outcome == SecurityFailure {
+		entry.Warn(event)
+		return
+	}
+	entry.Info(event)
+}
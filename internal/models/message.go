@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// EncryptedPayload 加密存储的数据：数据密钥加密数据，主密钥加密数据密钥（信封加密）
+type EncryptedPayload struct {
+	// KeyID 加密数据密钥的主密钥ID
+	KeyID string `bson:"key_id" json:"key_id"`
+	// DataKey 主密钥加密的数据密钥（nonce在前）
+	DataKey    []byte `bson:"data_key" json:"-"`
+	Nonce      []byte `bson:"nonce" json:"-"`
+	Ciphertext []byte `bson:"ciphertext" json:"-"`
+}
+
+// PayloadCipher 消息数据的加密器
+type PayloadCipher interface {
+	Seal(plaintext []byte) (*EncryptedPayload, error)
+	Open(payload *EncryptedPayload) ([]byte, error)
+}
+
+// payloadCipher 存储消息时使用的加密器，为nil时明文存储
+var payloadCipher PayloadCipher
+
+// SetPayloadCipher 设置消息数据的加密器，需要在读写MongoDB之前调用
+//
+// 设置后 NSQMessage 写入MongoDB时加密消息体和消息数据，topic、channel 等元数据保持明文以便查询；
+// 执行日志和工作流实例通过 SealFields 加密任务输入输出、变量和结果。读取时自动解密，未设置加密器时读取加密的数据返回错误。
+func SetPayloadCipher(cipher PayloadCipher) {
+	payloadCipher = cipher
+}
+
+// storedMessage NSQMessage 的存储格式
+type storedMessage struct {
+	Topic     string                 `bson:"topic"`
+	Channel   string                 `bson:"channel"`
+	Body      []byte                 `bson:"body"`
+	Timestamp time.Time              `bson:"timestamp"`
+	Attempts  uint16                 `bson:"attempts"`
+	ID        string                 `bson:"id"`
+	Data      map[string]interface{} `bson:"data"`
+	// Encrypted 加密的 body 和 data，此时 Body、Data 为空
+	Encrypted *EncryptedPayload `bson:"encrypted,omitempty"`
+}
+
+// messageContent 加密的消息内容
+type messageContent struct {
+	Body []byte                 `bson:"body"`
+	Data map[string]interface{} `bson:"data"`
+}
+
+// MarshalBSON 实现 bson.Marshaler，设置了加密器时加密消息体和消息数据
+func (m *NSQMessage) MarshalBSON() ([]byte, error) {
+	stored := storedMessage{
+		Topic:     m.Topic,
+		Channel:   m.Channel,
+		Body:      m.Body,
+		Timestamp: m.Timestamp,
+		Attempts:  m.Attempts,
+		ID:        m.ID,
+		Data:      m.Data,
+	}
+	if cipher := payloadCipher; cipher != nil {
+		content, err := bson.Marshal(messageContent{Body: m.Body, Data: m.Data})
+		if err != nil {
+			return nil, err
+		}
+		if stored.Encrypted, err = cipher.Seal(content); err != nil {
+			return nil, fmt.Errorf("failed to encrypt message: %v", err)
+		}
+		stored.Body, stored.Data = nil, nil
+	}
+	return bson.Marshal(stored)
+}
+
+// UnmarshalBSON 实现 bson.Unmarshaler，解密加密存储的消息
+func (m *NSQMessage) UnmarshalBSON(data []byte) error {
+	var stored storedMessage
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	*m = NSQMessage{
+		Topic:     stored.Topic,
+		Channel:   stored.Channel,
+		Body:      stored.Body,
+		Timestamp: stored.Timestamp,
+		Attempts:  stored.Attempts,
+		ID:        stored.ID,
+		Data:      stored.Data,
+	}
+	if stored.Encrypted == nil {
+		return nil
+	}
+
+	cipher := payloadCipher
+	if cipher == nil {
+		return fmt.Errorf("message is encrypted with key %s but no encryption keys are configured", stored.Encrypted.KeyID)
+	}
+	plaintext, err := cipher.Open(stored.Encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt message: %v", err)
+	}
+	var content messageContent
+	if err := bson.Unmarshal(plaintext, &content); err != nil {
+		return err
+	}
+	m.Body, m.Data = content.Body, content.Data
+	return nil
+}
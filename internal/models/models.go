@@ -15,8 +15,77 @@ type WorkflowConfig struct {
 	Channel     string             `bson:"channel" json:"channel"`
 	Enabled     bool               `bson:"enabled" json:"enabled"`
 	DAG         DAGConfig          `bson:"dag" json:"dag"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	Triggers    []TriggerConfig    `bson:"triggers,omitempty" json:"triggers,omitempty"`
+	// ConcurrencyKey 并发键模板（如 "{{nsq.order_id}}"），键相同的实例按到达顺序串行执行，不同键之间并行
+	ConcurrencyKey string `bson:"concurrency_key" json:"concurrency_key,omitempty"`
+	// OnRestart 服务重启时未完成实例的处理方式：resume（默认）从检查点继续执行，interrupt 标记为 interrupted
+	OnRestart string `bson:"on_restart" json:"on_restart,omitempty"`
+	// RateLimit NSQ消息触发的执行速率上限，超过时消息延迟后重新入队
+	RateLimit *RateLimit `bson:"rate_limit" json:"rate_limit,omitempty"`
+	// ExecutionWindow 允许执行的时间窗口，窗口外新建的实例延迟到下一个窗口开始时执行或跳过，为空时不限制
+	ExecutionWindow *ExecutionWindow `bson:"execution_window" json:"execution_window,omitempty"`
+	// Requeue NSQ消息触发的实例失败时重新入队消息，为空时实例创建后即确认消息
+	Requeue *RequeuePolicy `bson:"requeue" json:"requeue,omitempty"`
+	// Budget 每日执行预算，超过时自动禁用工作流并通知负责人，为空时不限制
+	Budget *ExecutionBudget `bson:"budget" json:"budget,omitempty"`
+	// BudgetExceeded 超过执行预算被自动禁用的记录，重新启用时清除
+	BudgetExceeded *BudgetExceeded `bson:"budget_exceeded,omitempty" json:"budget_exceeded,omitempty"`
+	// Owner 负责人用户名，创建时为当前用户，只能通过转移接口修改
+	Owner string `bson:"owner" json:"owner,omitempty"`
+	// Project 所属项目，用于按项目查询和批量转移
+	Project string `bson:"project" json:"project,omitempty"`
+	// Unmask 可以查看脱敏列原值的数据源名称，"*" 表示所有数据源，只有admin可以修改
+	Unmask    []string  `bson:"unmask" json:"unmask,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// RateLimit 执行速率限制，每个 period 最多 limit 次，允许 limit 次的突发
+type RateLimit struct {
+	Limit  int    `bson:"limit" json:"limit"`
+	Period string `bson:"period" json:"period"` // second 或 minute
+}
+
+// Interval 返回两次执行之间的平均间隔
+func (r *RateLimit) Interval() time.Duration {
+	period := time.Second
+	if r.Period == "minute" {
+		period = time.Minute
+	}
+	return period / time.Duration(r.Limit)
+}
+
+// ExecutionWindow 执行窗口，在任一时间段内都可以执行
+type ExecutionWindow struct {
+	Periods []WindowPeriod `bson:"periods" json:"periods"`
+	// Timezone IANA 时区，默认 UTC
+	Timezone string `bson:"timezone" json:"timezone,omitempty"`
+	// Policy 窗口外的执行：defer（默认）延迟到下一个窗口开始时执行，skip 跳过
+	Policy string `bson:"policy" json:"policy,omitempty"`
+}
+
+// WindowPeriod 每周重复的时间段，End 不晚于 Start 时跨越午夜到次日结束
+type WindowPeriod struct {
+	// Days 星期名称（mon-sun）或范围（mon-fri），为空表示每天；跨越午夜的时间段按开始的日期匹配
+	Days  []string `bson:"days" json:"days,omitempty"`
+	Start string   `bson:"start" json:"start"` // HH:MM
+	End   string   `bson:"end" json:"end"`     // HH:MM
+}
+
+// RequeuePolicy 实例失败时NSQ消息的重新入队策略
+//
+// 消息在实例结束后才确认：可以重试的失败延迟后重新入队，永久失败或达到最大投递次数时放弃（配置了死信topic时发布到死信topic）。
+type RequeuePolicy struct {
+	// Delay 第一次重新入队的延迟(秒)，之后每次翻倍，最长10分钟，默认10
+	Delay int `bson:"delay" json:"delay,omitempty"`
+	// PermanentErrors 正则表达式，实例的错误信息匹配任一表达式时为永久失败，不再重新入队
+	PermanentErrors []string `bson:"permanent_errors" json:"permanent_errors,omitempty"`
+}
+
+// TriggerConfig 触发器配置，NSQ消息之外的工作流触发方式
+type TriggerConfig struct {
+	Type   string                 `bson:"type" json:"type"` // kv_watch、cron 等
+	Params map[string]interface{} `bson:"params" json:"params"`
 }
 
 // DAGConfig DAG配置
@@ -44,6 +113,8 @@ type TaskConfig struct {
 	Params     map[string]interface{} `bson:"params" json:"params"`
 	Retry      RetryConfig            `bson:"retry" json:"retry"`
 	Timeout    int                    `bson:"timeout" json:"timeout"` // 超时时间(秒)
+	// Condition 执行条件表达式，结果为 false 时跳过任务
+	Condition string `bson:"condition,omitempty" json:"condition,omitempty"`
 }
 
 // RetryConfig 重试配置
@@ -57,35 +128,275 @@ type RetryConfig struct {
 type DataSource struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name        string             `bson:"name" json:"name"`
-	Type        string             `bson:"type" json:"type"` // mysql, postgresql, sqlserver, oracle, mongodb
+	Type        string             `bson:"type" json:"type"` // mysql, postgresql, sqlserver, oracle, sqlite, clickhouse, mongodb, s3, snowflake, bigquery, consul, etcd, elasticsearch
 	Host        string             `bson:"host" json:"host"`
 	Port        int                `bson:"port" json:"port"`
 	Database    string             `bson:"database" json:"database"`
 	Username    string             `bson:"username" json:"username"`
 	Password    string             `bson:"password" json:"password"`
 	SSL         bool               `bson:"ssl" json:"ssl"`
+	Region      string             `bson:"region,omitempty" json:"region,omitempty"`   // 对象存储区域（s3）
+	Options     map[string]string  `bson:"options,omitempty" json:"options,omitempty"` // 类型相关的附加配置，如 warehouse、role、project_id、datacenter
 	MaxIdle     int                `bson:"max_idle" json:"max_idle"`
 	MaxOpen     int                `bson:"max_open" json:"max_open"`
 	MaxLifetime int                `bson:"max_lifetime" json:"max_lifetime"` // 连接最大生存时间(秒)
+	// MaskedColumns 需要脱敏的列名（不区分大小写），DB节点的查询结果中这些列的值被替换，工作流授权后可以查看原值
+	MaskedColumns []string  `bson:"masked_columns" json:"masked_columns,omitempty"`
+	CreatedAt     time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// DataSourceAlias 数据源别名，工作流通过别名引用数据源，各环境将同名别名映射到本环境的数据源，导出的工作流无需修改
+type DataSourceAlias struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	DataSource  string             `bson:"datasource" json:"datasource"`                 // 默认映射的数据源名称
+	Projects    map[string]string  `bson:"projects,omitempty" json:"projects,omitempty"` // 项目到数据源名称的映射，优先于默认映射
+	Description string             `bson:"description" json:"description"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Target 返回别名在项目下映射的数据源名称
+func (a *DataSourceAlias) Target(project string) string {
+	if target, ok := a.Projects[project]; ok && project != "" {
+		return target
+	}
+	return a.DataSource
+}
+
+// Secret 密钥（API Key、Token等）
+type Secret struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Value       string             `bson:"value" json:"value"`
+	Description string             `bson:"description" json:"description"`
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
+// Script 可复用的JS脚本模块，JS Function 节点通过 require(name) 引用
+type Script struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Code        string             `bson:"code" json:"code"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// SavedView 保存的列表过滤条件，按名称引用以复用同一视图
+type SavedView struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	// Resource 视图适用的列表：instances 或 logs
+	Resource string `bson:"resource" json:"resource"`
+	// Filters 列表的查询参数，如 status、workflow_id、since
+	Filters map[string]string `bson:"filters" json:"filters"`
+	// Shared 为true时所有用户可见，否则只有创建者可见
+	Shared    bool      `bson:"shared" json:"shared"`
+	Owner     string    `bson:"owner" json:"owner"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// ExecutionBudget 每日执行预算，当天的执行次数或成本超过上限时自动禁用工作流
+//
+// 用于防止上游生产者异常时大量消息触发执行。
+type ExecutionBudget struct {
+	// Limit 每天的执行次数（未设置 Cost 时）或成本上限
+	Limit float64 `bson:"limit" json:"limit"`
+	// Cost 单次执行成本的表达式（变量与任务条件相同，如 nsq.rows / 1000），为空时每次执行计为1
+	Cost string `bson:"cost" json:"cost,omitempty"`
+	// Timezone 计算每天起止时间的 IANA 时区，默认 UTC
+	Timezone string `bson:"timezone" json:"timezone,omitempty"`
+	// Emails 自动禁用时通知的邮箱，需要配置 reports.smtp
+	Emails []string `bson:"emails" json:"emails,omitempty"`
+	// WebhookSecret 自动禁用时通知的聊天 Webhook 地址的密钥名称，以 {"text": ...} 发送
+	WebhookSecret string `bson:"webhook_secret" json:"webhook_secret,omitempty"`
+}
+
+// BudgetExceeded 工作流超过执行预算被自动禁用的记录
+type BudgetExceeded struct {
+	Day   string    `bson:"day" json:"day"` // 按预算时区的日期 YYYY-MM-DD
+	Used  float64   `bson:"used" json:"used"`
+	Limit float64   `bson:"limit" json:"limit"`
+	At    time.Time `bson:"at" json:"at"`
+}
+
+// ReportSchedule 定时发送的工作流健康报告，按项目统计执行数、失败率、主要错误和最慢的工作流
+type ReportSchedule struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name string             `bson:"name" json:"name"`
+	// Project 统计的项目，为空表示所有工作流
+	Project string `bson:"project" json:"project"`
+	// Period daily 或 weekly，报告统计发送前的一天或一周
+	Period string `bson:"period" json:"period"`
+	// Hour 发送的整点（0-23），Weekday 每周报告发送的星期（0-6，0为周日），均按 Timezone 计算
+	Hour     int    `bson:"hour" json:"hour"`
+	Weekday  int    `bson:"weekday" json:"weekday"`
+	Timezone string `bson:"timezone" json:"timezone,omitempty"`
+	// Emails 收件人，需要配置 reports.smtp
+	Emails []string `bson:"emails" json:"emails,omitempty"`
+	// WebhookSecret 保存聊天 Webhook 地址的密钥名称，报告以 {"text": ...} 发送（Slack、Mattermost 兼容）
+	WebhookSecret string    `bson:"webhook_secret" json:"webhook_secret,omitempty"`
+	Enabled       bool      `bson:"enabled" json:"enabled"`
+	CreatedBy     string    `bson:"created_by" json:"created_by"`
+	NextRunAt     time.Time `bson:"next_run_at" json:"next_run_at"`
+	LastRunAt     time.Time `bson:"last_run_at,omitempty" json:"last_run_at,omitempty"`
+	// LastError 最近一次发送的错误，发送成功时清空
+	LastError string    `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// AlertRule 告警规则，由后台持续评估，满足条件时通过通知渠道发送告警
+type AlertRule struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description,omitempty"`
+	// Type failures（一段时间内的失败实例数）或 duration（实例执行时间）
+	Type string `bson:"type" json:"type"`
+	// WorkflowID 规则针对的工作流，为空表示所有工作流
+	WorkflowID string `bson:"workflow_id" json:"workflow_id,omitempty"`
+	// Threshold failures 规则中 Window 分钟内结束的失败（failed、interrupted）实例数达到该值时告警
+	Threshold int `bson:"threshold" json:"threshold,omitempty"`
+	Window    int `bson:"window" json:"window,omitempty"`
+	// MaxDuration duration 规则中实例执行时间超过该值(秒)时告警，每个实例只告警一次
+	MaxDuration int `bson:"max_duration" json:"max_duration,omitempty"`
+	// Cooldown failures 规则再次告警的最短间隔(分钟)，默认等于 Window
+	Cooldown int            `bson:"cooldown" json:"cooldown,omitempty"`
+	Channels []AlertChannel `bson:"channels" json:"channels"`
+	Enabled  bool           `bson:"enabled" json:"enabled"`
+	// CreatedBy 只读
+	CreatedBy string `bson:"created_by" json:"created_by"`
+	// LastEvaluatedAt、LastFiredAt、LastError 只读，LastError 为最近一次评估或通知的错误
+	LastEvaluatedAt time.Time `bson:"last_evaluated_at,omitempty" json:"last_evaluated_at,omitempty"`
+	LastFiredAt     time.Time `bson:"last_fired_at,omitempty" json:"last_fired_at,omitempty"`
+	LastError       string    `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt       time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// AlertChannel 告警的通知渠道
+type AlertChannel struct {
+	// Type email、webhook 或 slack
+	Type string `bson:"type" json:"type"`
+	// Emails email 渠道的收件人，需要配置 reports.smtp
+	Emails []string `bson:"emails" json:"emails,omitempty"`
+	// Secret webhook、slack 渠道保存地址的密钥名称
+	Secret string `bson:"secret" json:"secret,omitempty"`
+}
+
+// Alert 告警规则触发的一次告警
+type Alert struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RuleID   string             `bson:"rule_id" json:"rule_id"`
+	RuleName string             `bson:"rule_name" json:"rule_name"`
+	Type     string             `bson:"type" json:"type"`
+	// WorkflowID failures 规则针对所有工作流时为空
+	WorkflowID string `bson:"workflow_id" json:"workflow_id,omitempty"`
+	Workflow   string `bson:"workflow" json:"workflow,omitempty"`
+	// InstanceID duration 规则中超时的实例
+	InstanceID string `bson:"instance_id,omitempty" json:"instance_id,omitempty"`
+	// Value 失败实例数或实例执行时间(秒)，Threshold 为规则的阈值
+	Value     float64 `bson:"value" json:"value"`
+	Threshold float64 `bson:"threshold" json:"threshold"`
+	Message   string  `bson:"message" json:"message"`
+	// Notifications 各通知渠道的发送结果
+	Notifications []AlertNotification `bson:"notifications" json:"notifications"`
+	FiredAt       time.Time           `bson:"fired_at" json:"fired_at"`
+}
+
+// AlertNotification 告警在一个通知渠道的发送结果
+type AlertNotification struct {
+	Channel string `bson:"channel" json:"channel"`
+	Error   string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// CronMissedRun 服务停止或触发器切换节点期间错过的 cron 触发时间及其补偿决定
+type CronMissedRun struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WorkflowID string             `bson:"workflow_id" json:"workflow_id"`
+	Workflow   string             `bson:"workflow" json:"workflow"`
+	// Trigger 触发器在工作流 triggers 中的序号
+	Trigger     int       `bson:"trigger" json:"trigger"`
+	Schedule    string    `bson:"schedule" json:"schedule"`
+	ScheduledAt time.Time `bson:"scheduled_at" json:"scheduled_at"`
+	// Policy 补偿策略：skip、run_once、run_all_missed
+	Policy string `bson:"policy" json:"policy"`
+	// Decision run 表示补偿执行，skipped 表示跳过，Reason 为跳过的原因
+	Decision  string    `bson:"decision" json:"decision"`
+	Reason    string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	Node      string    `bson:"node" json:"node"`
+	DecidedAt time.Time `bson:"decided_at" json:"decided_at"`
+}
+
+// 用户角色
+const (
+	RoleAdmin  = "admin"  // 管理员：全部权限，包括用户管理
+	RoleEditor = "editor" // 编辑者：可读写工作流、数据源等配置
+	RoleViewer = "viewer" // 只读用户：只能查看工作流和日志
+)
+
+// User 管理用户
+type User struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username  string             `bson:"username" json:"username"`
+	Password  string             `bson:"password" json:"password,omitempty"` // bcrypt哈希
+	Role      string             `bson:"role" json:"role"`                   // admin, editor, viewer
+	Source    string             `bson:"source" json:"source"`               // local, oidc
+	Enabled   bool               `bson:"enabled" json:"enabled"`
+	Timezone  string             `bson:"timezone,omitempty" json:"timezone,omitempty"` // API响应中时间的时区，为空时使用 admin.timezone
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	// OIDCIssuer、OIDCSubject 关联的OIDC身份（ID Token的iss和sub），OIDC登录按此查找用户
+	OIDCIssuer  string `bson:"oidc_issuer,omitempty" json:"oidc_issuer,omitempty"`
+	OIDCSubject string `bson:"oidc_subject,omitempty" json:"oidc_subject,omitempty"`
+}
+
 // ExecutionLog 执行日志
 type ExecutionLog struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	WorkflowID primitive.ObjectID `bson:"workflow_id" json:"workflow_id"`
-	InstanceID string             `bson:"instance_id" json:"instance_id"`
-	TaskID     string             `bson:"task_id" json:"task_id"`
-	Status     string             `bson:"status" json:"status"` // pending, running, success, failed, skipped
-	Message    string             `bson:"message" json:"message"`
-	Input      interface{}        `bson:"input" json:"input"`
-	Output     interface{}        `bson:"output" json:"output"`
-	Error      string             `bson:"error" json:"error"`
-	StartTime  time.Time          `bson:"start_time" json:"start_time"`
-	EndTime    time.Time          `bson:"end_time" json:"end_time"`
-	Duration   int64              `bson:"duration" json:"duration"` // 执行时间(毫秒)
-	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ID         primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	WorkflowID primitive.ObjectID     `bson:"workflow_id" json:"workflow_id"`
+	InstanceID string                 `bson:"instance_id" json:"instance_id"`
+	TaskID     string                 `bson:"task_id" json:"task_id"`
+	Status     string                 `bson:"status" json:"status"` // pending, running, success, failed, skipped
+	Message    string                 `bson:"message" json:"message"`
+	Input      interface{}            `bson:"input" json:"input"`
+	Output     interface{}            `bson:"output" json:"output"`
+	Error      string                 `bson:"error" json:"error"`
+	StartTime  time.Time              `bson:"start_time" json:"start_time"`
+	EndTime    time.Time              `bson:"end_time" json:"end_time"`
+	Duration   int64                  `bson:"duration" json:"duration"` // 执行时间(毫秒)
+	Attempts   int                    `bson:"attempts" json:"attempts"`
+	Metadata   map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"` // 动作附加信息，如LLM的token用量
+	// Console JS脚本的控制台输出，每行带级别前缀；任务失败（包括超时）时保留已输出的内容
+	Console []string `bson:"console,omitempty" json:"console,omitempty"`
+	// ConsoleTruncated 控制台输出超过上限被截断
+	ConsoleTruncated bool      `bson:"console_truncated,omitempty" json:"console_truncated,omitempty"`
+	CreatedAt        time.Time `bson:"created_at" json:"created_at"`
+}
+
+// AuditLog 审计日志（管理接口的变更记录）
+type AuditLog struct {
+	ID           primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Actor        string                 `bson:"actor" json:"actor"`
+	Role         string                 `bson:"role" json:"role"`
+	IP           string                 `bson:"ip" json:"ip"`
+	Action       string                 `bson:"action" json:"action"`               // create, update, delete, enable, disable, reload, approve, reject
+	ResourceType string                 `bson:"resource_type" json:"resource_type"` // workflow, datasource, user, secret, approval
+	ResourceID   string                 `bson:"resource_id" json:"resource_id"`
+	ResourceName string                 `bson:"resource_name" json:"resource_name"`
+	Changes      map[string]AuditChange `bson:"changes" json:"changes"`
+	CreatedAt    time.Time              `bson:"created_at" json:"created_at"`
+}
+
+// AuditChange 字段变更前后的值
+type AuditChange struct {
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
 }
 
 // NSQMessage NSQ消息结构
@@ -110,3 +421,30 @@ type WorkflowInstance struct {
 	EndTime    time.Time              `json:"end_time"`
 	CreatedAt  time.Time              `json:"created_at"`
 }
+
+// 审批状态
+const (
+	ApprovalPending   = "pending"   // 等待审批
+	ApprovalApproved  = "approved"  // 已批准，工作流继续执行
+	ApprovalRejected  = "rejected"  // 已拒绝，审批任务失败
+	ApprovalExpired   = "expired"   // 超时未审批，审批任务失败
+	ApprovalCancelled = "cancelled" // 工作流取消等待（如循环中其他迭代失败）
+)
+
+// Approval 人工审批请求，由审批节点创建，工作流实例在审批完成前暂停
+type Approval struct {
+	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	WorkflowID  string                 `bson:"workflow_id" json:"workflow_id"`
+	InstanceID  string                 `bson:"instance_id" json:"instance_id"`
+	TaskID      string                 `bson:"task_id" json:"task_id"`
+	Title       string                 `bson:"title" json:"title"`
+	Description string                 `bson:"description" json:"description"`
+	Data        map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`           // 供审批人参考的上下文数据
+	Approvers   []string               `bson:"approvers,omitempty" json:"approvers,omitempty"` // 允许审批的用户名，为空时编辑者和管理员均可审批
+	Status      string                 `bson:"status" json:"status"`                           // pending, approved, rejected, expired, cancelled
+	Comment     string                 `bson:"comment" json:"comment"`
+	DecidedBy   string                 `bson:"decided_by" json:"decided_by"`
+	DecidedAt   time.Time              `bson:"decided_at" json:"decided_at"`
+	ExpiresAt   time.Time              `bson:"expires_at" json:"expires_at"`
+	CreatedAt   time.Time              `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SealedField 文档中加密存储 fields 的字段名
+const SealedField = "encrypted"
+
+// SealFields 设置了加密器时将BSON文档中的 fields 整体加密到 encrypted 字段，其他字段保持明文以便查询；
+// 未设置加密器时原样返回
+func SealFields(doc bson.Raw, fields ...string) (bson.Raw, error) {
+	cipher := payloadCipher
+	if cipher == nil {
+		return doc, nil
+	}
+
+	elements, err := doc.Elements()
+	if err != nil {
+		return nil, err
+	}
+	plain, content := bson.D{}, bson.D{}
+	for _, element := range elements {
+		e := bson.E{Key: element.Key(), Value: element.Value()}
+		if containsField(fields, e.Key) {
+			content = append(content, e)
+		} else if e.Key != SealedField {
+			plain = append(plain, e)
+		}
+	}
+
+	plaintext, err := bson.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := cipher.Seal(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt document: %v", err)
+	}
+	return bson.Marshal(append(plain, bson.E{Key: SealedField, Value: payload}))
+}
+
+// OpenFields 解密 SealFields 加密的字段并还原到文档中，文档没有加密时原样返回
+func OpenFields(doc bson.Raw) (bson.Raw, error) {
+	value, err := doc.LookupErr(SealedField)
+	if err != nil {
+		return doc, nil
+	}
+	if _, ok := value.DocumentOK(); !ok {
+		return doc, nil
+	}
+	var payload EncryptedPayload
+	if err := value.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+
+	cipher := payloadCipher
+	if cipher == nil {
+		return nil, fmt.Errorf("document is encrypted with key %s but no encryption keys are configured", payload.KeyID)
+	}
+	plaintext, err := cipher.Open(&payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt document: %v", err)
+	}
+	content, err := bson.Raw(plaintext).Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	elements, err := doc.Elements()
+	if err != nil {
+		return nil, err
+	}
+	opened := bson.D{}
+	for _, element := range elements {
+		if element.Key() != SealedField {
+			opened = append(opened, bson.E{Key: element.Key(), Value: element.Value()})
+		}
+	}
+	for _, element := range content {
+		opened = append(opened, bson.E{Key: element.Key(), Value: element.Value()})
+	}
+	return bson.Marshal(opened)
+}
+
+// containsField 判断字段名是否在列表中
+func containsField(fields []string, name string) bool {
+	for _, field := range fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecutionLogSealedFields 执行日志中加密存储的字段：任务输入、输出和控制台输出
+var ExecutionLogSealedFields = []string{"input", "output", "console"}
+
+// plainExecutionLog 不加密的 ExecutionLog，避免 MarshalBSON 递归
+type plainExecutionLog ExecutionLog
+
+// MarshalBSON 实现 bson.Marshaler，设置了加密器时加密任务输入、输出和控制台输出
+func (l *ExecutionLog) MarshalBSON() ([]byte, error) {
+	doc, err := bson.Marshal((*plainExecutionLog)(l))
+	if err != nil {
+		return nil, err
+	}
+	return SealFields(doc, ExecutionLogSealedFields...)
+}
+
+// UnmarshalBSON 实现 bson.Unmarshaler，解密加密存储的字段
+func (l *ExecutionLog) UnmarshalBSON(data []byte) error {
+	doc, err := OpenFields(data)
+	if err != nil {
+		return err
+	}
+	*l = ExecutionLog{}
+	return bson.Unmarshal(doc, (*plainExecutionLog)(l))
+}
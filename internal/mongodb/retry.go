@@ -0,0 +1,83 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// retryInitialBackoff 第一次重试前的等待时间，之后每次翻倍
+	retryInitialBackoff = 200 * time.Millisecond
+	// retryMaxBackoff 两次重试之间的最长等待时间
+	retryMaxBackoff = 5 * time.Second
+)
+
+// transientErrorCodes 主节点切换、节点关闭等短暂不可用时服务端返回的错误码
+var transientErrorCodes = []int{
+	6,     // HostUnreachable
+	7,     // HostNotFound
+	89,    // NetworkTimeout
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	262,   // ExceededTimeLimit
+	9001,  // SocketException
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// IsTransient 判断错误是否为短暂错误（网络错误、超时、主节点切换），重试可能成功
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	if serverErr.HasErrorLabel("RetryableWriteError") || serverErr.HasErrorLabel("TransientTransactionError") {
+		return true
+	}
+	for _, code := range transientErrorCodes {
+		if serverErr.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retry 执行操作，遇到短暂错误时按指数退避重试，最多执行 mongodb.retry_attempts 次
+//
+// 每次执行使用单独的超时时间，操作必须是幂等的（查询、按_id替换或更新）。
+// ctx 被取消或非短暂错误时立即返回。
+func (c *Client) Retry(ctx context.Context, timeout time.Duration, op func(ctx context.Context) error) error {
+	backoff := retryInitialBackoff
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := op(attemptCtx)
+		cancel()
+
+		if err == nil || attempt >= c.config.RetryAttempts || !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+	}
+}
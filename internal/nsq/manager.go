@@ -3,18 +3,38 @@ package nsq
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"nsa/internal/config"
 	"nsa/internal/logger"
 	"nsa/internal/models"
 	"nsa/internal/workflow"
+	"nsa/internal/workqueue"
 
 	"github.com/nsqio/go-nsq"
 )
 
+// maxAttempts 消息的最大投递次数，超过后不再处理
+const maxAttempts = 5
+
+const (
+	// touchInterval 等待执行期间延长消息超时的间隔，小于消息超时（60秒）
+	touchInterval = 20 * time.Second
+	// busyRequeueDelay 等待执行超时后重新入队的延迟
+	busyRequeueDelay = 10 * time.Second
+	// statsTimeout 查询单个nsqd或nsqlookupd HTTP接口的超时
+	statsTimeout = 5 * time.Second
+	// maxOutcomeWait 配置了失败重新入队的工作流等待实例结果的最长时间，小于nsqd默认的 --max-msg-timeout（15分钟）
+	maxOutcomeWait = 10 * time.Minute
+)
+
 // Manager NSQ管理器
 type Manager struct {
 	config    config.NSQConfig
@@ -24,35 +44,129 @@ type Manager struct {
 	executor  *workflow.Executor
 	ctx       context.Context
 	cancel    context.CancelFunc
+	// owns 分区模式下判断当前节点是否负责 topic/channel，为nil时负责所有消费者
+	owns func(topic, channel string) bool
+	// workflows 最近一次加载的工作流配置，分区变化时重新计算需要的消费者；只在协调goroutine中访问
+	workflows []*models.WorkflowConfig
+	// failed 需要但启动失败的消费者及错误，下一次协调时重试
+	failed map[string]string
+	// commands 修改消费者的操作，由协调goroutine串行执行；消费者集合只在该goroutine中修改
+	commands chan func()
+	// done 协调goroutine停止所有消费者并退出后关闭
+	done    chan struct{}
+	limiter *rateLimiter
+	queue   *workqueue.Queue
+	// deadLetter 发布超过 max_message_size 的消息的生产者，未配置 dead_letter_topic 时为nil
+	deadLetter *nsq.Producer
+	// statsClient 查询nsqd、nsqlookupd HTTP接口的客户端
+	statsClient *http.Client
 }
 
 // Consumer NSQ消费者
 type Consumer struct {
-	consumer *nsq.Consumer
-	topic    string
-	channel  string
-	handler  *MessageHandler
+	consumer    *nsq.Consumer
+	topic       string
+	channel     string
+	handler     *MessageHandler
+	stop        chan struct{} // 停止消费者时关闭，结束等待执行的消息
+	maxInFlight int           // 恢复消费时使用的 max_in_flight
+	paused      bool          // 暂停时 max_in_flight 为0，nsqd 不再投递新消息
 }
 
 // MessageHandler 消息处理器
 type MessageHandler struct {
 	logger   logger.Logger
 	executor *workflow.Executor
+	limiter  *rateLimiter
+	queue    *workqueue.Queue
 	topic    string
 	channel  string
+	// throttled 因工作流限流而延迟重新入队的消息数
+	throttled int64
+	// slots 执行槽位，容量为 nsq.concurrency，实例的执行goroutine返回时释放；启用工作队列时为nil
+	slots        chan struct{}
+	queueTimeout time.Duration
+	stop         <-chan struct{}
+	// waiting 等待执行槽位的消息数，busy 因等待超时而重新入队的消息数
+	waiting int64
+	busy    int64
+	// maxMessageSize 消息体的最大字节数，超过的消息发布到死信topic或丢弃
+	maxMessageSize  int
+	deadLetter      *nsq.Producer
+	deadLetterTopic string
+	// oversized 超过大小上限的消息数，deadLettered 其中发布到死信topic的消息数
+	oversized    int64
+	deadLettered int64
+	// failedRequeued 实例失败后重新入队的消息数，gaveUp 实例永久失败或达到最大投递次数后放弃的消息数
+	failedRequeued int64
+	gaveUp         int64
 }
 
 // NewManager 创建新的NSQ管理器
 func NewManager(cfg config.NSQConfig, logger logger.Logger) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Manager{
+	m := &Manager{
 		config:    cfg,
 		logger:    logger,
 		consumers: make(map[string]*Consumer),
+		limiter:   newRateLimiter(),
 		ctx:       ctx,
 		cancel:    cancel,
+		failed:    make(map[string]string),
+		commands:  make(chan func()),
+		done:      make(chan struct{}),
+	}
+
+	// 查询积压统计时使用与nsqd连接相同的TLS配置（https:// 地址）
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if clientConfig, err := cfg.ClientConfig(); err == nil && cfg.TLS.Enabled {
+		transport.TLSClientConfig = clientConfig.TlsConfig
 	}
+	m.statsClient = &http.Client{Timeout: statsTimeout, Transport: transport}
+
+	// 死信生产者在第一次发布时连接nsqd
+	if cfg.DeadLetterTopic != "" {
+		producerConfig, err := cfg.ClientConfig()
+		if err == nil {
+			m.deadLetter, err = nsq.NewProducer(cfg.NSQDAddresses[0], producerConfig)
+		}
+		if err != nil {
+			logger.Errorf("Failed to create NSQ dead letter producer, oversized messages will be dropped: %v", err)
+		}
+	}
+
+	go m.run()
+	return m
+}
+
+// errStopped 管理器已停止，不再修改消费者
+var errStopped = errors.New("NSQ manager is stopped")
+
+// run 协调goroutine：串行执行修改消费者的操作，管理器停止时停止所有消费者
+func (m *Manager) run() {
+	defer close(m.done)
+	for {
+		select {
+		case command := <-m.commands:
+			command()
+		case <-m.ctx.Done():
+			m.stopAll()
+			return
+		}
+	}
+}
+
+// do 在协调goroutine中执行操作并等待完成，管理器已停止时返回 errStopped
+func (m *Manager) do(command func()) error {
+	finished := make(chan struct{})
+	select {
+	case m.commands <- func() { defer close(finished); command() }:
+	case <-m.ctx.Done():
+		return errStopped
+	}
+	<-finished
+	return nil
 }
 
 // SetExecutor 设置工作流执行器
@@ -60,18 +174,18 @@ func (m *Manager) SetExecutor(executor *workflow.Executor) {
 	m.executor = executor
 }
 
-// AddConsumer 添加消费者
-func (m *Manager) AddConsumer(topic, channel string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// SetWorkQueue 设置工作队列，消息写入队列后即确认，由队列控制执行并发；需要在加载消费者之前设置
+func (m *Manager) SetWorkQueue(queue *workqueue.Queue) {
+	m.queue = queue
+}
 
-	key := fmt.Sprintf("%s:%s", topic, channel)
-	if _, exists := m.consumers[key]; exists {
-		return fmt.Errorf("consumer for topic %s channel %s already exists", topic, channel)
+// startConsumer 创建并连接消费者，只在协调goroutine中调用
+func (m *Manager) startConsumer(topic, channel string) (*Consumer, error) {
+	// 创建NSQ配置，TLS、认证和压缩按 nsq 配置设置
+	nsqConfig, err := m.config.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid NSQ config: %v", err)
 	}
-
-	// 创建NSQ配置
-	nsqConfig := nsq.NewConfig()
 	nsqConfig.DefaultRequeueDelay = 0
 	nsqConfig.MaxBackoffDuration = time.Minute
 	nsqConfig.MaxInFlight = 1000
@@ -79,62 +193,85 @@ func (m *Manager) AddConsumer(topic, channel string) error {
 	nsqConfig.ReadTimeout = 60 * time.Second
 	nsqConfig.WriteTimeout = time.Second
 	nsqConfig.MsgTimeout = 60 * time.Second
+	nsqConfig.MaxAttempts = maxAttempts
+	// 未启用工作队列时，每个执行槽位对应一个处理goroutine和一条未确认的消息，
+	// 等待槽位的消息都在处理goroutine中定期延长超时，不会在客户端缓冲中超时
+	handlers := 1
+	if m.queue == nil {
+		handlers = m.config.Concurrency
+		nsqConfig.MaxInFlight = m.config.Concurrency
+	}
 
 	// 创建消费者
 	consumer, err := nsq.NewConsumer(topic, channel, nsqConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create NSQ consumer: %v", err)
+		return nil, fmt.Errorf("failed to create NSQ consumer: %v", err)
 	}
 
 	// 创建消息处理器
+	stop := make(chan struct{})
 	handler := &MessageHandler{
-		logger:   m.logger,
-		executor: m.executor,
-		topic:    topic,
-		channel:  channel,
+		logger:       m.logger,
+		executor:     m.executor,
+		limiter:      m.limiter,
+		queue:        m.queue,
+		topic:        topic,
+		channel:      channel,
+		queueTimeout: time.Duration(m.config.QueueTimeout) * time.Second,
+		stop:         stop,
+
+		maxMessageSize:  m.config.MaxMessageSize,
+		deadLetter:      m.deadLetter,
+		deadLetterTopic: m.config.DeadLetterTopic,
+	}
+	if m.queue == nil {
+		handler.slots = make(chan struct{}, m.config.Concurrency)
 	}
 
 	// 设置处理器
-	consumer.AddHandler(handler)
+	consumer.AddConcurrentHandlers(handler, handlers)
 
-	// 连接到NSQ
-	if err := consumer.ConnectToNSQLookupds(m.config.LookupdAddresses); err != nil {
+	// 连接到NSQ：直连模式连接配置的所有nsqd，否则通过nsqlookupd发现
+	if m.config.DirectConnect {
+		if err := consumer.ConnectToNSQDs(m.config.NSQDAddresses); err != nil {
+			consumer.Stop()
+			return nil, fmt.Errorf("failed to connect to nsqd: %v", err)
+		}
+	} else if err := consumer.ConnectToNSQLookupds(m.config.LookupdAddresses); err != nil {
 		consumer.Stop()
-		return fmt.Errorf("failed to connect to NSQ lookupd: %v", err)
-	}
-
-	// 保存消费者
-	m.consumers[key] = &Consumer{
-		consumer: consumer,
-		topic:    topic,
-		channel:  channel,
-		handler:  handler,
+		return nil, fmt.Errorf("failed to connect to NSQ lookupd: %v", err)
 	}
 
 	m.logger.Infof("NSQ consumer added for topic: %s, channel: %s", topic, channel)
-	return nil
+	return &Consumer{
+		consumer:    consumer,
+		topic:       topic,
+		channel:     channel,
+		handler:     handler,
+		stop:        stop,
+		maxInFlight: nsqConfig.MaxInFlight,
+	}, nil
 }
 
-// RemoveConsumer 移除消费者
-func (m *Manager) RemoveConsumer(topic, channel string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	key := fmt.Sprintf("%s:%s", topic, channel)
-	consumer, exists := m.consumers[key]
-	if !exists {
-		return fmt.Errorf("consumer for topic %s channel %s not found", topic, channel)
-	}
-
-	// 停止消费者
+// stopConsumer 停止消费者，等待处理中的消息返回
+func (m *Manager) stopConsumer(consumer *Consumer) {
+	close(consumer.stop)
 	consumer.consumer.Stop()
 	<-consumer.consumer.StopChan
+	m.logger.Infof("NSQ consumer removed for topic: %s, channel: %s", consumer.topic, consumer.channel)
+}
 
-	// 删除消费者
-	delete(m.consumers, key)
+// stopAll 停止所有消费者，只在协调goroutine中调用
+func (m *Manager) stopAll() {
+	m.mu.Lock()
+	consumers := m.consumers
+	m.consumers = make(map[string]*Consumer)
+	m.mu.Unlock()
 
-	m.logger.Infof("NSQ consumer removed for topic: %s, channel: %s", topic, channel)
-	return nil
+	for key, consumer := range consumers {
+		m.logger.Infof("Stopping consumer: %s", key)
+		m.stopConsumer(consumer)
+	}
 }
 
 // ListConsumers 列出所有消费者
@@ -149,25 +286,17 @@ func (m *Manager) ListConsumers() []string {
 	return consumers
 }
 
-// Stop 停止所有消费者
+// Stop 停止所有消费者，等待正在执行的重新加载完成
 func (m *Manager) Stop() {
 	m.logger.Info("Stopping NSQ manager...")
 
-	// 取消上下文
+	// 取消上下文，协调goroutine停止所有消费者后退出
 	m.cancel()
+	<-m.done
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// 停止所有消费者
-	for key, consumer := range m.consumers {
-		m.logger.Infof("Stopping consumer: %s", key)
-		consumer.consumer.Stop()
-		<-consumer.consumer.StopChan
+	if m.deadLetter != nil {
+		m.deadLetter.Stop()
 	}
-
-	// 清空消费者映射
-	m.consumers = make(map[string]*Consumer)
 	m.logger.Info("NSQ manager stopped")
 }
 
@@ -177,6 +306,10 @@ func (h *MessageHandler) HandleMessage(message *nsq.Message) error {
 	h.logger.Infof("Received NSQ message from topic: %s, channel: %s, attempts: %d",
 		h.topic, h.channel, message.Attempts)
 
+	if len(message.Body) > h.maxMessageSize {
+		return h.rejectOversized(message)
+	}
+
 	// 解析消息
 	nsqMessage, err := h.parseMessage(message)
 	if err != nil {
@@ -192,12 +325,53 @@ func (h *MessageHandler) HandleMessage(message *nsq.Message) error {
 		return err
 	}
 
-	// 执行工作流
+	// 工作流限流，超过速率时延迟重新入队；即将达到最大投递次数的消息直接执行，避免被丢弃
+	if workflowConfig.RateLimit != nil {
+		delay, allowed := h.limiter.reserve(workflowConfig.ID.Hex(), *workflowConfig.RateLimit, string(message.ID[:]))
+		if !allowed {
+			if message.Attempts < maxAttempts {
+				atomic.AddInt64(&h.throttled, 1)
+				h.logger.Debugf("Workflow %s rate limited, requeueing message in %v", workflowConfig.ID.Hex(), delay)
+				message.DisableAutoResponse()
+				message.RequeueWithoutBackoff(delay)
+				return nil
+			}
+			h.logger.Warnf("Workflow %s rate limited, executing message at its last attempt", workflowConfig.ID.Hex())
+		}
+	}
+
 	ctx := context.Background()
-	if err := h.executor.Execute(ctx, workflowConfig, nsqMessage); err != nil {
+	if h.queue != nil {
+		// 写入工作队列，写入失败时由NSQ重新投递
+		if err := h.queue.Enqueue(ctx, workflowConfig, nsqMessage); err != nil {
+			h.logger.Errorf("Failed to enqueue message: %v", err)
+			return err
+		}
+		h.logger.Infof("NSQ message enqueued in %v", time.Since(start))
+		return nil
+	}
+
+	// 等待执行槽位，达到并发上限时消息保持未确认，由NSQ暂停投递新消息
+	message.DisableAutoResponse()
+	if !h.acquire(message) {
+		atomic.AddInt64(&h.busy, 1)
+		h.logger.Warnf("No execution slot for workflow %s within %v, requeueing message", workflowConfig.ID.Hex(), h.queueTimeout)
+		message.RequeueWithoutBackoff(busyRequeueDelay)
+		return nil
+	}
+
+	if workflowConfig.Requeue != nil {
+		return h.awaitOutcome(ctx, message, workflowConfig, nsqMessage)
+	}
+
+	// 执行工作流，执行goroutine返回（实例结束、延迟或进入并发键队列）时释放槽位
+	if err := h.executor.ExecuteAsync(ctx, workflowConfig, nsqMessage, h.release); err != nil {
+		h.release()
 		h.logger.Errorf("Failed to execute workflow: %v", err)
+		message.Requeue(-1)
 		return err
 	}
+	message.Finish()
 
 	duration := time.Since(start)
 	h.logger.Infof("NSQ message processed successfully in %v", duration)
@@ -205,6 +379,137 @@ func (h *MessageHandler) HandleMessage(message *nsq.Message) error {
 	return nil
 }
 
+// rejectOversized 处理超过大小上限的消息：配置了死信topic时原样发布后确认，否则记录日志后丢弃
+//
+// 发布到死信topic失败时返回错误，由NSQ重新投递。
+func (h *MessageHandler) rejectOversized(message *nsq.Message) error {
+	atomic.AddInt64(&h.oversized, 1)
+	reason := fmt.Sprintf("%d bytes exceeds nsq.max_message_size of %d bytes", len(message.Body), h.maxMessageSize)
+	return h.deadLetterMessage(message, reason)
+}
+
+// deadLetterMessage 将放弃的消息原样发布到死信topic，未配置死信topic时记录日志后丢弃
+func (h *MessageHandler) deadLetterMessage(message *nsq.Message, reason string) error {
+	if h.deadLetter == nil {
+		h.logger.Warnf("Dropping NSQ message %s from topic %s channel %s: %s",
+			string(message.ID[:]), h.topic, h.channel, reason)
+		return nil
+	}
+	if err := h.deadLetter.Publish(h.deadLetterTopic, message.Body); err != nil {
+		h.logger.Errorf("Failed to publish message to dead letter topic %s: %v", h.deadLetterTopic, err)
+		return err
+	}
+	atomic.AddInt64(&h.deadLettered, 1)
+	h.logger.Warnf("Moved NSQ message %s from topic %s channel %s to dead letter topic %s: %s",
+		string(message.ID[:]), h.topic, h.channel, h.deadLetterTopic, reason)
+	return nil
+}
+
+// awaitOutcome 执行工作流并等待执行goroutine返回，按实例的结果确认、重新入队或放弃消息
+//
+// 等待期间定期延长消息超时。超过 maxOutcomeWait 或消费者停止时确认消息，实例继续执行，结果不再影响消息。
+func (h *MessageHandler) awaitOutcome(ctx context.Context, message *nsq.Message, workflowConfig *models.WorkflowConfig, nsqMessage *models.NSQMessage) error {
+	outcomes := make(chan workflow.Outcome, 1)
+	err := h.executor.ExecuteWithOutcome(ctx, workflowConfig, nsqMessage, func(outcome workflow.Outcome) {
+		h.release()
+		outcomes <- outcome
+	})
+	if err != nil {
+		h.release()
+		h.logger.Errorf("Failed to execute workflow: %v", err)
+		message.Requeue(-1)
+		return err
+	}
+
+	touch := time.NewTicker(touchInterval)
+	defer touch.Stop()
+	timeout := time.NewTimer(maxOutcomeWait)
+	defer timeout.Stop()
+	for {
+		select {
+		case outcome := <-outcomes:
+			h.settle(message, workflowConfig, outcome)
+			return nil
+		case <-touch.C:
+			message.Touch()
+		case <-timeout.C:
+			h.logger.Warnf("Workflow %s did not finish within %v, finishing NSQ message without waiting for the result", workflowConfig.ID.Hex(), maxOutcomeWait)
+			message.Finish()
+			return nil
+		case <-h.stop:
+			message.Finish()
+			return nil
+		}
+	}
+}
+
+// settle 按实例结果处理消息：成功时确认；可以重试的失败延迟后重新入队（NSQ退避）；
+// 永久失败或已是最后一次投递时放弃，配置了死信topic时发布到死信topic
+func (h *MessageHandler) settle(message *nsq.Message, workflowConfig *models.WorkflowConfig, outcome workflow.Outcome) {
+	if outcome.Err == nil {
+		message.Finish()
+		return
+	}
+	if !outcome.Permanent && message.Attempts < maxAttempts {
+		delay := workflow.RequeueDelay(workflowConfig.Requeue, message.Attempts)
+		atomic.AddInt64(&h.failedRequeued, 1)
+		h.logger.Warnf("Workflow instance %s failed (attempt %d/%d), requeueing message in %v: %v",
+			outcome.InstanceID, message.Attempts, maxAttempts, delay, outcome.Err)
+		message.Requeue(delay)
+		return
+	}
+
+	atomic.AddInt64(&h.gaveUp, 1)
+	reason := fmt.Sprintf("workflow instance %s failed permanently: %v", outcome.InstanceID, outcome.Err)
+	if !outcome.Permanent {
+		reason = fmt.Sprintf("workflow instance %s failed after %d attempts: %v", outcome.InstanceID, message.Attempts, outcome.Err)
+	}
+	if err := h.deadLetterMessage(message, reason); err != nil {
+		h.logger.Errorf("Dropping NSQ message %s: %s", string(message.ID[:]), reason)
+	}
+	message.Finish()
+}
+
+// acquire 获取执行槽位，等待期间定期延长消息超时
+//
+// 等待超过 queueTimeout 或消费者停止时返回false，由调用方重新入队；已是最后一次投递的消息继续等待，避免被丢弃。
+func (h *MessageHandler) acquire(message *nsq.Message) bool {
+	select {
+	case h.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	atomic.AddInt64(&h.waiting, 1)
+	defer atomic.AddInt64(&h.waiting, -1)
+
+	touch := time.NewTicker(touchInterval)
+	defer touch.Stop()
+	timeout := time.NewTimer(h.queueTimeout)
+	defer timeout.Stop()
+	expired := timeout.C
+	for {
+		select {
+		case h.slots <- struct{}{}:
+			return true
+		case <-touch.C:
+			message.Touch()
+		case <-expired:
+			if message.Attempts < maxAttempts {
+				return false
+			}
+			expired = nil
+		case <-h.stop:
+			return false
+		}
+	}
+}
+
+// release 释放执行槽位
+func (h *MessageHandler) release() {
+	<-h.slots
+}
+
 // parseMessage 解析NSQ消息
 func (h *MessageHandler) parseMessage(message *nsq.Message) (*models.NSQMessage, error) {
 	nsqMessage := &models.NSQMessage{
@@ -214,20 +519,20 @@ func (h *MessageHandler) parseMessage(message *nsq.Message) (*models.NSQMessage,
 		Timestamp: time.Unix(0, message.Timestamp),
 		Attempts:  message.Attempts,
 		ID:        string(message.ID[:]),
-		Data:      make(map[string]interface{}),
 	}
 
-	// 尝试解析JSON消息体
+	// 尝试解析JSON消息体，直接解码到消息的 Data，不再额外分配空map
 	if len(message.Body) > 0 {
-		var data map[string]interface{}
-		if err := json.Unmarshal(message.Body, &data); err != nil {
+		if err := json.Unmarshal(message.Body, &nsqMessage.Data); err != nil {
 			// 如果不是JSON，将原始数据作为字符串存储
-			nsqMessage.Data["raw"] = string(message.Body)
+			nsqMessage.Data = map[string]interface{}{"raw": string(message.Body)}
 			h.logger.Warnf("Failed to parse message body as JSON, storing as raw string: %v", err)
-		} else {
-			nsqMessage.Data = data
 		}
 	}
+	if nsqMessage.Data == nil {
+		// 空消息体或JSON null
+		nsqMessage.Data = make(map[string]interface{})
+	}
 
 	return nsqMessage, nil
 }
@@ -247,54 +552,298 @@ func (m *Manager) GetConsumerStats() map[string]interface{} {
 			"messages_received": consumerStats.MessagesReceived,
 			"messages_finished": consumerStats.MessagesFinished,
 			"messages_requeued": consumerStats.MessagesRequeued,
+			"throttled":         atomic.LoadInt64(&consumer.handler.throttled),
+			"executing":         len(consumer.handler.slots),
+			"waiting":           atomic.LoadInt64(&consumer.handler.waiting),
+			"busy_requeued":     atomic.LoadInt64(&consumer.handler.busy),
+			"oversized":         atomic.LoadInt64(&consumer.handler.oversized),
+			"dead_lettered":     atomic.LoadInt64(&consumer.handler.deadLettered),
+			"failed_requeued":   atomic.LoadInt64(&consumer.handler.failedRequeued),
+			"gave_up":           atomic.LoadInt64(&consumer.handler.gaveUp),
+			"paused":            consumer.paused,
 		}
 	}
 
 	return stats
 }
 
-// ReloadConsumers 重新加载消费者（根据数据库配置）
-func (m *Manager) ReloadConsumers(workflowConfigs []*models.WorkflowConfig) error {
-	m.logger.Info("Reloading NSQ consumers...")
+// SetLookupdAddresses 更新nsqlookupd地址
+//
+// 已有消费者先连接新地址再断开移除的地址，消息消费不中断。返回地址是否发生变化，管理器已停止时返回 false。
+func (m *Manager) SetLookupdAddresses(addresses []string) bool {
+	var changed bool
+	if err := m.do(func() { changed = m.setLookupdAddresses(addresses) }); err != nil {
+		return false
+	}
+	return changed
+}
+
+// setLookupdAddresses 更新nsqlookupd地址，只在协调goroutine中调用
+func (m *Manager) setLookupdAddresses(addresses []string) bool {
+	current := make(map[string]bool, len(m.config.LookupdAddresses))
+	for _, addr := range m.config.LookupdAddresses {
+		current[addr] = true
+	}
+	wanted := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		wanted[addr] = true
+	}
 
-	// 获取当前需要的消费者
-	requiredConsumers := make(map[string]bool)
-	for _, config := range workflowConfigs {
-		if config.Enabled {
-			key := fmt.Sprintf("%s:%s", config.Topic, config.Channel)
-			requiredConsumers[key] = true
+	var added, removed []string
+	for _, addr := range addresses {
+		if !current[addr] {
+			added = append(added, addr)
 		}
 	}
+	for _, addr := range m.config.LookupdAddresses {
+		if !wanted[addr] {
+			removed = append(removed, addr)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return false
+	}
 
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.config.LookupdAddresses = append([]string{}, addresses...)
+	m.mu.Unlock()
+	// 直连nsqd的消费者不使用nsqlookupd
+	if m.config.DirectConnect {
+		m.logger.Infof("NSQ lookupd addresses updated (unused with direct_connect): %v", addresses)
+		return true
+	}
+	for key, consumer := range m.consumers {
+		for _, addr := range added {
+			if err := consumer.consumer.ConnectToNSQLookupd(addr); err != nil {
+				m.logger.Errorf("Failed to connect consumer %s to NSQ lookupd %s: %v", key, addr, err)
+			}
+		}
+		for _, addr := range removed {
+			if err := consumer.consumer.DisconnectFromNSQLookupd(addr); err != nil {
+				m.logger.Errorf("Failed to disconnect consumer %s from NSQ lookupd %s: %v", key, addr, err)
+			}
+		}
+	}
+
+	m.logger.Infof("NSQ lookupd addresses updated: %v", addresses)
+	return true
+}
 
-	// 移除不需要的消费者
+// SetPartitioner 设置分区函数，只为当前节点负责的 topic/channel 创建消费者
+func (m *Manager) SetPartitioner(owns func(topic, channel string) bool) {
+	m.mu.Lock()
+	m.owns = owns
+	m.mu.Unlock()
+}
+
+// Rebalance 集群节点变化后按分区函数重新计算需要的消费者，使用最近一次加载的工作流配置
+func (m *Manager) Rebalance() error {
+	return m.do(func() {
+		if m.workflows != nil {
+			m.reconcile(m.workflows)
+		}
+	})
+}
+
+// ReloadDiff 重新加载消费者的变化，消费者以 topic:channel 表示
+type ReloadDiff struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Unchanged []string `json:"unchanged"`
+	// Skipped 启用的工作流需要、但按分区由其他节点负责的消费者
+	Skipped []string `json:"skipped"`
+	// Errors 启动失败的消费者及错误，这些消费者不在 Added 中，下一次重新加载时重试
+	Errors map[string]string `json:"errors"`
+}
+
+// PlanReload 计算按工作流配置重新加载消费者的变化，不修改消费者
+func (m *Manager) PlanReload(workflowConfigs []*models.WorkflowConfig) *ReloadDiff {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.planReload(workflowConfigs)
+}
+
+// planReload 计算消费者的变化，调用时需持有锁
+func (m *Manager) planReload(workflowConfigs []*models.WorkflowConfig) *ReloadDiff {
+	diff := &ReloadDiff{Added: []string{}, Removed: []string{}, Unchanged: []string{}, Skipped: []string{}, Errors: map[string]string{}}
+
+	required := make(map[string]bool)
+	skipped := make(map[string]bool)
+	for _, config := range workflowConfigs {
+		if !config.Enabled {
+			continue
+		}
+		key := fmt.Sprintf("%s:%s", config.Topic, config.Channel)
+		if m.owns == nil || m.owns(config.Topic, config.Channel) {
+			required[key] = true
+		} else {
+			skipped[key] = true
+		}
+	}
+
+	for key := range required {
+		if _, exists := m.consumers[key]; exists {
+			diff.Unchanged = append(diff.Unchanged, key)
+		} else {
+			diff.Added = append(diff.Added, key)
+		}
+	}
 	for key := range m.consumers {
-		if !requiredConsumers[key] {
-			consumer := m.consumers[key]
-			consumer.consumer.Stop()
-			<-consumer.consumer.StopChan
-			delete(m.consumers, key)
-			m.logger.Infof("Removed consumer: %s", key)
+		if !required[key] {
+			diff.Removed = append(diff.Removed, key)
 		}
 	}
+	for key := range skipped {
+		diff.Skipped = append(diff.Skipped, key)
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Unchanged)
+	sort.Strings(diff.Skipped)
+	return diff
+}
 
-	// 添加新的消费者
-	for _, config := range workflowConfigs {
-		if config.Enabled {
-			key := fmt.Sprintf("%s:%s", config.Topic, config.Channel)
-			if _, exists := m.consumers[key]; !exists {
-				// 临时解锁以调用AddConsumer
-				m.mu.Unlock()
-				if err := m.AddConsumer(config.Topic, config.Channel); err != nil {
-					m.logger.Errorf("Failed to add consumer %s: %v", key, err)
-				}
-				m.mu.Lock()
-			}
+// ReloadConsumers 重新加载消费者（根据数据库配置），返回消费者的变化
+//
+// 设置了分区函数时只保留当前节点负责的消费者。重新加载、分区变化和停止由协调goroutine串行执行，
+// 相同的配置重复加载不产生变化，上次启动失败的消费者会重新启动。
+func (m *Manager) ReloadConsumers(workflowConfigs []*models.WorkflowConfig) (*ReloadDiff, error) {
+	var diff *ReloadDiff
+	if err := m.do(func() { diff = m.reconcile(workflowConfigs) }); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// reconcile 将消费者调整为工作流配置需要的状态，只在协调goroutine中调用
+//
+// 先停止不再需要的消费者，再启动缺少的消费者，已有的消费者保持不变；启动失败的消费者记录在 failed 中。
+func (m *Manager) reconcile(workflowConfigs []*models.WorkflowConfig) *ReloadDiff {
+	m.workflows = workflowConfigs
+
+	m.mu.Lock()
+	diff := m.planReload(workflowConfigs)
+	removed := make([]*Consumer, 0, len(diff.Removed))
+	for _, key := range diff.Removed {
+		removed = append(removed, m.consumers[key])
+		delete(m.consumers, key)
+	}
+	m.failed = make(map[string]string)
+	m.mu.Unlock()
+
+	// 停止消费者时等待处理中的消息返回，不持有锁，不阻塞统计和健康检查
+	for _, consumer := range removed {
+		m.stopConsumer(consumer)
+	}
+
+	added := diff.Added[:0]
+	for _, key := range diff.Added {
+		topic, channel, _ := strings.Cut(key, ":")
+		consumer, err := m.startConsumer(topic, channel)
+		if err != nil {
+			m.logger.Errorf("Failed to add consumer %s: %v", key, err)
+			diff.Errors[key] = err.Error()
+			m.mu.Lock()
+			m.failed[key] = err.Error()
+			m.mu.Unlock()
+			continue
 		}
+		m.mu.Lock()
+		m.consumers[key] = consumer
+		m.mu.Unlock()
+		added = append(added, key)
 	}
+	diff.Added = added
 
-	m.logger.Infof("NSQ consumers reloaded, active consumers: %d", len(m.consumers))
-	return nil
+	// 定期协调时消费者通常没有变化，只在变化时记录日志
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Errors) == 0 {
+		return diff
+	}
+	m.mu.RLock()
+	active := len(m.consumers)
+	m.mu.RUnlock()
+	m.logger.Infof("NSQ consumers reloaded, added: %d, removed: %d, failed: %d, active consumers: %d",
+		len(diff.Added), len(diff.Removed), len(diff.Errors), active)
+	return diff
+}
+
+// ErrConsumerNotFound 当前节点没有运行该消费者
+var ErrConsumerNotFound = errors.New("NSQ consumer not found")
+
+// PauseConsumer 暂停消费者：max_in_flight 设为0，nsqd 不再投递新消息，已收到的消息继续处理
+//
+// 暂停状态只保存在当前节点的消费者上，不修改工作流配置；消费者被移除（工作流禁用、分区变化）或服务重启后恢复消费。
+// 返回暂停前是否已暂停。
+func (m *Manager) PauseConsumer(topic, channel string) (bool, error) {
+	return m.setPaused(topic, channel, true)
+}
+
+// ResumeConsumer 恢复暂停的消费者，返回恢复前是否处于暂停状态
+func (m *Manager) ResumeConsumer(topic, channel string) (bool, error) {
+	return m.setPaused(topic, channel, false)
+}
+
+// setPaused 在协调goroutine中修改消费者的暂停状态，与重新加载串行执行
+func (m *Manager) setPaused(topic, channel string, paused bool) (bool, error) {
+	key := fmt.Sprintf("%s:%s", topic, channel)
+	var (
+		was   bool
+		found bool
+	)
+	err := m.do(func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		consumer, exists := m.consumers[key]
+		if !exists {
+			return
+		}
+		found = true
+		was = consumer.paused
+		if was == paused {
+			return
+		}
+		if paused {
+			consumer.consumer.ChangeMaxInFlight(0)
+			m.logger.Infof("NSQ consumer paused for topic: %s, channel: %s", topic, channel)
+		} else {
+			consumer.consumer.ChangeMaxInFlight(consumer.maxInFlight)
+			m.logger.Infof("NSQ consumer resumed for topic: %s, channel: %s", topic, channel)
+		}
+		consumer.paused = paused
+	})
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, ErrConsumerNotFound
+	}
+	return was, nil
+}
+
+// PausedConsumers 返回当前节点暂停的消费者
+func (m *Manager) PausedConsumers() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	paused := []string{}
+	for key, consumer := range m.consumers {
+		if consumer.paused {
+			paused = append(paused, key)
+		}
+	}
+	sort.Strings(paused)
+	return paused
+}
+
+// FailedConsumers 返回最近一次重新加载时需要但启动失败的消费者及错误
+func (m *Manager) FailedConsumers() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	failed := make(map[string]string, len(m.failed))
+	for key, err := range m.failed {
+		failed[key] = err
+	}
+	return failed
 }
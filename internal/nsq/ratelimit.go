@@ -0,0 +1,90 @@
+package nsq
+
+import (
+	"sync"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// maxThrottleDelay 限流时重新入队的最长延迟，nsqd 默认的 max-req-timeout 为1小时
+const maxThrottleDelay = 10 * time.Minute
+
+// workflowLimit 单个工作流的限流状态（GCRA：按理论到达时间计算，允许 limit 次突发）
+type workflowLimit struct {
+	config models.RateLimit
+	tat    time.Time // 理论到达时间，下一次执行的最早时间加上突发容量
+}
+
+// rateLimiter 工作流执行速率限制器，状态只保存在当前节点
+//
+// 超过速率的消息预留之后的执行时间并按该时间延迟重新入队，重新投递时直接执行，不再与新消息竞争，
+// 因此每条消息最多重新入队一次（预留超过 maxThrottleDelay 时除外）。
+type rateLimiter struct {
+	mu           sync.Mutex
+	limits       map[string]*workflowLimit
+	reservations map[string]time.Time // 消息ID到预留的执行时间
+}
+
+// newRateLimiter 创建速率限制器
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		limits:       make(map[string]*workflowLimit),
+		reservations: make(map[string]time.Time),
+	}
+}
+
+// reserve 判断工作流是否可以立即执行消息；不能时返回消息重新入队的延迟
+func (l *rateLimiter) reserve(workflowID string, config models.RateLimit, messageID string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if reserved, ok := l.reservations[messageID]; ok {
+		delete(l.reservations, messageID)
+		// nsqd 按秒级精度重新投递，预留时间之前很短的时间内到达也直接执行
+		if !now.Before(reserved.Add(-time.Second)) {
+			return 0, true
+		}
+	}
+
+	limit, ok := l.limits[workflowID]
+	if !ok || limit.config != config {
+		// 新工作流或限流配置已修改，重新计算
+		limit = &workflowLimit{config: config}
+		l.limits[workflowID] = limit
+	}
+
+	interval := config.Interval()
+	burst := time.Duration(config.Limit) * interval
+	if limit.tat.Before(now) {
+		limit.tat = now
+	}
+	allowAt := limit.tat.Add(interval - burst)
+	if !now.Before(allowAt) {
+		limit.tat = limit.tat.Add(interval)
+		return 0, true
+	}
+
+	delay := allowAt.Sub(now)
+	if delay > maxThrottleDelay {
+		// 积压过多，不预留，稍后重新竞争
+		return maxThrottleDelay, false
+	}
+	limit.tat = limit.tat.Add(interval)
+	l.reservations[messageID] = allowAt
+	l.purge(now)
+	return delay, false
+}
+
+// purge 删除过期的预留（消息被投递到其他节点或已超时），预留数量较多时才执行
+func (l *rateLimiter) purge(now time.Time) {
+	if len(l.reservations) < 1024 {
+		return
+	}
+	for id, reserved := range l.reservations {
+		if now.Sub(reserved) > time.Minute {
+			delete(l.reservations, id)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package nsq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxStatsResponseSize nsqd 统计和 nsqlookupd 节点列表响应的最大字节数
+const maxStatsResponseSize = 32 << 20
+
+// ChannelStats channel 在 nsqd 上的积压统计，多个nsqd上的同名channel相加
+type ChannelStats struct {
+	// TopicDepth topic 中尚未分发到channel的消息数
+	TopicDepth int64 `json:"topic_depth"`
+	// Depth channel 中等待投递的消息数（内存和磁盘）
+	Depth    int64  `json:"depth"`
+	InFlight int64  `json:"in_flight"`
+	Deferred int64  `json:"deferred"`
+	Requeued uint64 `json:"requeued"`
+	TimedOut uint64 `json:"timed_out"`
+	Messages uint64 `json:"messages"`
+	Clients  int    `json:"clients"`
+	// Paused channel 在任一nsqd上被暂停（nsqadmin 或 /channel/pause）
+	Paused bool `json:"paused"`
+	// Nodes 存在该channel的nsqd HTTP地址
+	Nodes []string `json:"nodes"`
+}
+
+// ServerStats 从nsqd HTTP接口查询的当前节点消费者的积压统计
+type ServerStats struct {
+	// Channels 按 topic:channel 索引，nsqd 上不存在的channel没有记录
+	Channels map[string]*ChannelStats
+	// Errors 查询失败的nsqd或nsqlookupd地址及错误，此时统计不完整
+	Errors map[string]string
+}
+
+// nsqdStats nsqd /stats?format=json 的响应
+type nsqdStats struct {
+	Topics []struct {
+		TopicName string `json:"topic_name"`
+		Depth     int64  `json:"depth"`
+		Paused    bool   `json:"paused"`
+		Channels  []struct {
+			ChannelName   string            `json:"channel_name"`
+			Depth         int64             `json:"depth"`
+			InFlightCount int64             `json:"in_flight_count"`
+			DeferredCount int64             `json:"deferred_count"`
+			MessageCount  uint64            `json:"message_count"`
+			RequeueCount  uint64            `json:"requeue_count"`
+			TimeoutCount  uint64            `json:"timeout_count"`
+			ClientCount   int               `json:"client_count"`
+			Clients       []json.RawMessage `json:"clients"`
+			Paused        bool              `json:"paused"`
+		} `json:"channels"`
+	} `json:"topics"`
+}
+
+// lookupdNodes nsqlookupd /nodes 的响应
+type lookupdNodes struct {
+	Producers []struct {
+		BroadcastAddress string `json:"broadcast_address"`
+		HTTPPort         int    `json:"http_port"`
+	} `json:"producers"`
+}
+
+// ServerStats 查询nsqd HTTP接口，返回当前节点消费者在nsqd上的积压（depth、in-flight、deferred、requeue 等）
+//
+// nsqd 地址使用 nsqd_http_addresses，未配置时通过 nsqlookupd 的 /nodes 发现；各nsqd并发查询，单个地址失败不影响其他地址。
+func (m *Manager) ServerStats(ctx context.Context) *ServerStats {
+	stats := &ServerStats{Channels: make(map[string]*ChannelStats), Errors: make(map[string]string)}
+
+	wanted := make(map[string]bool)
+	for _, key := range m.ListConsumers() {
+		wanted[key] = true
+	}
+	if len(wanted) == 0 {
+		return stats
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, addr := range m.nsqdHTTPAddresses(ctx, stats) {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			var result nsqdStats
+			err := m.getNSQJSON(ctx, addr, "/stats?format=json", false, &result)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				stats.Errors[addr] = err.Error()
+				return
+			}
+			for _, topic := range result.Topics {
+				for _, channel := range topic.Channels {
+					key := topic.TopicName + ":" + channel.ChannelName
+					if !wanted[key] {
+						continue
+					}
+					entry, ok := stats.Channels[key]
+					if !ok {
+						entry = &ChannelStats{Nodes: []string{}}
+						stats.Channels[key] = entry
+					}
+					entry.TopicDepth += topic.Depth
+					entry.Depth += channel.Depth
+					entry.InFlight += channel.InFlightCount
+					entry.Deferred += channel.DeferredCount
+					entry.Requeued += channel.RequeueCount
+					entry.TimedOut += channel.TimeoutCount
+					entry.Messages += channel.MessageCount
+					entry.Clients += max(channel.ClientCount, len(channel.Clients))
+					entry.Paused = entry.Paused || channel.Paused || topic.Paused
+					entry.Nodes = append(entry.Nodes, addr)
+				}
+			}
+		}(addr)
+	}
+	wg.Wait()
+	return stats
+}
+
+// nsqdHTTPAddresses 返回nsqd HTTP地址，查询nsqlookupd失败的地址记录在 stats.Errors 中
+func (m *Manager) nsqdHTTPAddresses(ctx context.Context, stats *ServerStats) []string {
+	if len(m.config.NSQDHTTPAddresses) > 0 {
+		return m.config.NSQDHTTPAddresses
+	}
+
+	m.mu.RLock()
+	lookupds := append([]string{}, m.config.LookupdAddresses...)
+	m.mu.RUnlock()
+	if len(lookupds) == 0 {
+		stats.Errors["nsqd_http_addresses"] = "no nsqlookupd to discover nsqd from, set nsq.nsqd_http_addresses"
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var addresses []string
+	for _, lookupd := range lookupds {
+		var nodes lookupdNodes
+		if err := m.getNSQJSON(ctx, lookupd, "/nodes", true, &nodes); err != nil {
+			stats.Errors[lookupd] = err.Error()
+			continue
+		}
+		for _, producer := range nodes.Producers {
+			addr := net.JoinHostPort(producer.BroadcastAddress, strconv.Itoa(producer.HTTPPort))
+			if !seen[addr] {
+				seen[addr] = true
+				addresses = append(addresses, addr)
+			}
+		}
+	}
+	return addresses
+}
+
+// getNSQJSON 请求nsqd或nsqlookupd的HTTP接口并解析JSON，兼容旧版本包装在 data 中的响应
+func (m *Manager) getNSQJSON(ctx context.Context, addr, path string, lookupd bool, v interface{}) error {
+	base := addr
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.nsq; version=1.0")
+	if lookupd && m.config.AuthSecret != "" {
+		req.Header.Set("Authorization", "Bearer "+m.config.AuthSecret)
+	}
+
+	resp, err := m.statsClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxStatsResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var wrapped struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err == nil && len(wrapped.Data) > 0 && wrapped.Data[0] == '{' {
+		body = wrapped.Data
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+	"nsa/internal/nsq"
+	"nsa/internal/trigger"
+	"nsa/internal/workflow"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 监听工作流变化的方式
+const (
+	ModeChangeStream = "change_stream"
+	ModePeriodic     = "periodic"
+)
+
+const (
+	// debounce 收到变化后等待的时间，合并批量修改产生的多次变化
+	debounce = 500 * time.Millisecond
+	// reopenDelay change stream 中断后重新打开的延迟
+	reopenDelay = 5 * time.Second
+)
+
+// Status 协调状态
+type Status struct {
+	Mode            string    `json:"mode"`
+	Reconciles      int64     `json:"reconciles"`
+	LastReconcileAt time.Time `json:"last_reconcile_at,omitempty"`
+	// LastError 最近一次协调的错误，成功时清空
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Watcher 使NSQ消费者和工作流触发器与数据库中启用的工作流保持一致
+//
+// 优先通过MongoDB change stream监听工作流集合，MongoDB不支持时（单节点部署）只按间隔协调；两种方式下都按间隔
+// 定期协调，补偿丢失的变化。协调在一个goroutine中串行执行，多次变化合并为一次协调。
+type Watcher struct {
+	logger     logger.Logger
+	mongoDB    *mongodb.Client
+	executor   *workflow.Executor
+	nsqManager *nsq.Manager
+	triggers   *trigger.Manager
+	interval   time.Duration
+	// pending 待执行的协调，缓冲为1，协调前的多次请求合并为一次
+	pending chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	status  Status
+}
+
+// NewWatcher 创建工作流变化监听器
+func NewWatcher(cfg config.NSQConfig, logger logger.Logger, mongoClient *mongodb.Client, executor *workflow.Executor, nsqManager *nsq.Manager, triggers *trigger.Manager) *Watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Watcher{
+		logger:     logger,
+		mongoDB:    mongoClient,
+		executor:   executor,
+		nsqManager: nsqManager,
+		triggers:   triggers,
+		interval:   time.Duration(cfg.ReconcileInterval) * time.Second,
+		pending:    make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+		status:     Status{Mode: ModePeriodic},
+	}
+}
+
+// Start 立即协调一次，然后开始监听工作流变化和定期协调
+func (w *Watcher) Start() {
+	w.Trigger()
+	w.wg.Add(2)
+	go w.run()
+	go w.watch()
+}
+
+// Stop 停止监听，等待正在执行的协调完成
+func (w *Watcher) Stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+// Trigger 请求协调，不等待完成；协调开始前的多次请求合并为一次
+func (w *Watcher) Trigger() {
+	select {
+	case w.pending <- struct{}{}:
+	default:
+	}
+}
+
+// Status 返回协调状态
+func (w *Watcher) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// run 串行执行协调
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.pending:
+			select {
+			case <-time.After(debounce):
+			case <-w.ctx.Done():
+				return
+			}
+		case <-ticker.C:
+		case <-w.ctx.Done():
+			return
+		}
+		// 等待期间的变化在本次协调中读取
+		select {
+		case <-w.pending:
+		default:
+		}
+		w.reconcile()
+	}
+}
+
+// watch 通过 change stream 监听工作流集合，中断后重新打开；MongoDB不支持 change stream 时退出，只定期协调
+func (w *Watcher) watch() {
+	defer w.wg.Done()
+
+	for {
+		stream, err := w.mongoDB.GetCollection().Watch(w.ctx, mongo.Pipeline{})
+		if err != nil {
+			if w.ctx.Err() == nil {
+				w.logger.Warnf("MongoDB change streams unavailable, reconciling NSQ consumers every %s: %v", w.interval, err)
+			}
+			return
+		}
+		w.setMode(ModeChangeStream)
+		w.logger.Info("Watching workflow changes with MongoDB change stream")
+
+		for stream.Next(w.ctx) {
+			w.Trigger()
+		}
+		err = stream.Err()
+		stream.Close(context.Background())
+		w.setMode(ModePeriodic)
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		// 中断期间的变化可能丢失
+		w.logger.Errorf("MongoDB change stream on workflows closed, reopening in %s: %v", reopenDelay, err)
+		w.Trigger()
+		select {
+		case <-time.After(reopenDelay):
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// setMode 记录监听方式
+func (w *Watcher) setMode(mode string) {
+	w.mu.Lock()
+	w.status.Mode = mode
+	w.mu.Unlock()
+}
+
+// reconcile 清空工作流配置缓存，按启用的工作流重新加载NSQ消费者和工作流触发器
+func (w *Watcher) reconcile() {
+	err := w.load()
+
+	w.mu.Lock()
+	w.status.Reconciles++
+	w.status.LastReconcileAt = time.Now()
+	w.status.LastError = ""
+	if err != nil {
+		w.status.LastError = err.Error()
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		w.logger.Errorf("Failed to reconcile NSQ consumers: %v", err)
+	}
+}
+
+// load 读取启用的工作流并应用
+func (w *Watcher) load() error {
+	w.executor.InvalidateWorkflowConfigs()
+
+	ctx, cancel := context.WithTimeout(w.ctx, 10*time.Second)
+	defer cancel()
+
+	cursor, err := w.mongoDB.GetCollection().Find(ctx, bson.M{"enabled": true})
+	if err != nil {
+		return fmt.Errorf("failed to find enabled workflows: %v", err)
+	}
+	var workflows []*models.WorkflowConfig
+	if err := cursor.All(ctx, &workflows); err != nil {
+		return fmt.Errorf("failed to decode workflows: %v", err)
+	}
+
+	diff, err := w.nsqManager.ReloadConsumers(workflows)
+	if err != nil {
+		return err
+	}
+	w.triggers.Reload(workflows)
+	if len(diff.Errors) > 0 {
+		return fmt.Errorf("%d consumers failed to start", len(diff.Errors))
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 概况的时间范围和各排行的数量
+const (
+	dashboardRange  = 24 * time.Hour
+	topFailing      = 5
+	slowestTasks    = 5
+	longestInstance = 10
+)
+
+// activeStatuses 未结束的实例状态
+var activeStatuses = bson.A{"running", "delayed"}
+
+// FailingWorkflow 失败实例最多的工作流
+type FailingWorkflow struct {
+	WorkflowID  string  `json:"workflow_id"`
+	Workflow    string  `json:"workflow"`
+	Executions  int64   `json:"executions"`
+	Failed      int64   `json:"failed"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// TaskTiming 平均执行时间最长的任务，跳过的任务不计入
+type TaskTiming struct {
+	WorkflowID  string `json:"workflow_id"`
+	Workflow    string `json:"workflow"`
+	TaskID      string `json:"task_id"`
+	Executions  int64  `json:"executions"`
+	AvgDuration int64  `json:"avg_duration"` // 平均执行时间(毫秒)
+	MaxDuration int64  `json:"max_duration"` // 最长执行时间(毫秒)
+}
+
+// ActiveInstance 执行中或延迟的实例
+type ActiveInstance struct {
+	ID         string    `bson:"_id" json:"id"`
+	WorkflowID string    `bson:"workflow_id" json:"workflow_id"`
+	Workflow   string    `bson:"-" json:"workflow"`
+	Status     string    `bson:"status" json:"status"`
+	Node       string    `bson:"node" json:"node,omitempty"`
+	StartTime  time.Time `bson:"start_time" json:"start_time"`
+	ResumeAt   time.Time `bson:"resume_at" json:"resume_at,omitempty"`
+	// Duration 已执行时间(毫秒)
+	Duration int64 `bson:"-" json:"duration"`
+}
+
+// ActiveInstances 未结束实例的数量和开始最早的实例，不限于最近24小时开始的实例
+type ActiveInstances struct {
+	Total    int64            `json:"total"`
+	Statuses map[string]int64 `json:"statuses"`
+	Nodes    map[string]int64 `json:"nodes"`
+	// Longest 按开始时间排列的最早开始的实例
+	Longest []ActiveInstance `json:"longest"`
+}
+
+// Dashboard 所有工作流最近24小时的执行概况
+type Dashboard struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Hourly 每小时开始的实例，共24个整点小时，最后一个为当前小时
+	Hourly       []TrendBucket     `json:"hourly"`
+	TopFailing   []FailingWorkflow `json:"top_failing_workflows"`
+	SlowestTasks []TaskTiming      `json:"slowest_tasks"`
+	Active       ActiveInstances   `json:"active_instances"`
+}
+
+// GenerateDashboard 统计所有工作流在 now 之前24小时内的执行和当前未结束的实例
+func GenerateDashboard(ctx context.Context, mongoClient *mongodb.Client, now time.Time) (*Dashboard, error) {
+	to := now.UTC()
+	dashboard := &Dashboard{
+		From:         to.Truncate(time.Hour).Add(-dashboardRange + time.Hour),
+		To:           to,
+		Hourly:       []TrendBucket{},
+		TopFailing:   []FailingWorkflow{},
+		SlowestTasks: []TaskTiming{},
+		Active: ActiveInstances{
+			Statuses: map[string]int64{},
+			Nodes:    map[string]int64{},
+			Longest:  []ActiveInstance{},
+		},
+	}
+
+	names, err := workflowNames(ctx, mongoClient, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workflows: %v", err)
+	}
+	if err := dashboard.summarizeExecutions(ctx, mongoClient, names); err != nil {
+		return nil, fmt.Errorf("failed to summarize instances: %v", err)
+	}
+	if err := dashboard.summarizeSlowestTasks(ctx, mongoClient, names); err != nil {
+		return nil, fmt.Errorf("failed to summarize tasks: %v", err)
+	}
+	if err := dashboard.summarizeActive(ctx, mongoClient, names); err != nil {
+		return nil, fmt.Errorf("failed to summarize active instances: %v", err)
+	}
+	return dashboard, nil
+}
+
+// summarizeExecutions 按小时统计期间开始的实例，以及失败实例最多的工作流
+func (d *Dashboard) summarizeExecutions(ctx context.Context, mongoClient *mongodb.Client, names map[string]string) error {
+	duration := bson.M{"$subtract": bson.A{"$end_time", "$start_time"}}
+	finished := bson.M{"$in": bson.A{"$status", finishedStatuses}}
+	succeeded := bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", "completed"}}, 1, 0}}
+	failed := bson.M{"$cond": bson.A{bson.M{"$in": bson.A{"$status", bson.A{"failed", "interrupted"}}}, 1, 0}}
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"start_time": bson.M{"$gte": d.From, "$lt": d.To}}},
+		bson.M{"$facet": bson.M{
+			"hourly": bson.A{
+				bson.M{"$group": bson.M{
+					"_id": bson.M{"$floor": bson.M{"$divide": bson.A{
+						bson.M{"$subtract": bson.A{"$start_time", d.From}}, time.Hour.Milliseconds(),
+					}}},
+					"executions": bson.M{"$sum": 1},
+					"succeeded":  bson.M{"$sum": succeeded},
+					"failed":     bson.M{"$sum": failed},
+					"avg":        bson.M{"$avg": bson.M{"$cond": bson.A{finished, duration, nil}}},
+				}},
+			},
+			"failing": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":        "$workflow_id",
+					"executions": bson.M{"$sum": 1},
+					"succeeded":  bson.M{"$sum": succeeded},
+					"failed":     bson.M{"$sum": failed},
+				}},
+				bson.M{"$match": bson.M{"failed": bson.M{"$gt": 0}}},
+				bson.M{"$sort": bson.D{{Key: "failed", Value: -1}, {Key: "_id", Value: 1}}},
+				bson.M{"$limit": topFailing},
+			},
+		}},
+	}
+
+	cursor, err := mongoClient.GetDatabase().Collection("workflow_instances").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var results []struct {
+		Hourly []struct {
+			Index      float64 `bson:"_id"`
+			Executions int64   `bson:"executions"`
+			Succeeded  int64   `bson:"succeeded"`
+			Failed     int64   `bson:"failed"`
+			Avg        float64 `bson:"avg"`
+		} `bson:"hourly"`
+		Failing []struct {
+			WorkflowID string `bson:"_id"`
+			Executions int64  `bson:"executions"`
+			Succeeded  int64  `bson:"succeeded"`
+			Failed     int64  `bson:"failed"`
+		} `bson:"failing"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+
+	// 没有实例的小时也返回，趋势是连续的
+	for start := d.From; start.Before(d.To); start = start.Add(time.Hour) {
+		d.Hourly = append(d.Hourly, TrendBucket{Start: start})
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	for _, hour := range results[0].Hourly {
+		i := int(hour.Index)
+		if i < 0 || i >= len(d.Hourly) {
+			continue
+		}
+		d.Hourly[i].Executions = hour.Executions
+		d.Hourly[i].Succeeded = hour.Succeeded
+		d.Hourly[i].Failed = hour.Failed
+		d.Hourly[i].FailureRate = failureRate(hour.Succeeded, hour.Failed)
+		d.Hourly[i].AvgDuration = int64(hour.Avg)
+	}
+	for _, workflow := range results[0].Failing {
+		d.TopFailing = append(d.TopFailing, FailingWorkflow{
+			WorkflowID:  workflow.WorkflowID,
+			Workflow:    workflowName(names, workflow.WorkflowID),
+			Executions:  workflow.Executions,
+			Failed:      workflow.Failed,
+			FailureRate: failureRate(workflow.Succeeded, workflow.Failed),
+		})
+	}
+	return nil
+}
+
+// summarizeSlowestTasks 按工作流和任务统计期间的执行日志，取平均执行时间最长的任务
+func (d *Dashboard) summarizeSlowestTasks(ctx context.Context, mongoClient *mongodb.Client, names map[string]string) error {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"created_at": bson.M{"$gte": d.From, "$lt": d.To},
+			"status":     bson.M{"$ne": "skipped"},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":        bson.D{{Key: "workflow_id", Value: "$workflow_id"}, {Key: "task_id", Value: "$task_id"}},
+			"executions": bson.M{"$sum": 1},
+			"avg":        bson.M{"$avg": "$duration"},
+			"max":        bson.M{"$max": "$duration"},
+		}},
+		bson.M{"$sort": bson.D{{Key: "avg", Value: -1}, {Key: "_id.task_id", Value: 1}}},
+		bson.M{"$limit": slowestTasks},
+	}
+
+	cursor, err := mongoClient.GetDatabase().Collection("execution_logs").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var results []struct {
+		ID struct {
+			WorkflowID primitive.ObjectID `bson:"workflow_id"`
+			TaskID     string             `bson:"task_id"`
+		} `bson:"_id"`
+		Executions int64   `bson:"executions"`
+		Avg        float64 `bson:"avg"`
+		Max        int64   `bson:"max"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		workflowID := result.ID.WorkflowID.Hex()
+		d.SlowestTasks = append(d.SlowestTasks, TaskTiming{
+			WorkflowID:  workflowID,
+			Workflow:    workflowName(names, workflowID),
+			TaskID:      result.ID.TaskID,
+			Executions:  result.Executions,
+			AvgDuration: int64(result.Avg),
+			MaxDuration: result.Max,
+		})
+	}
+	return nil
+}
+
+// summarizeActive 统计执行中和延迟的实例按状态、节点的数量，以及开始最早的实例
+func (d *Dashboard) summarizeActive(ctx context.Context, mongoClient *mongodb.Client, names map[string]string) error {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"status": bson.M{"$in": activeStatuses}}},
+		bson.M{"$facet": bson.M{
+			"statuses": bson.A{
+				bson.M{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+			},
+			"nodes": bson.A{
+				bson.M{"$group": bson.M{"_id": "$node", "count": bson.M{"$sum": 1}}},
+			},
+			"longest": bson.A{
+				bson.M{"$sort": bson.M{"start_time": 1}},
+				bson.M{"$limit": longestInstance},
+				bson.M{"$project": bson.M{"workflow_id": 1, "status": 1, "node": 1, "start_time": 1, "resume_at": 1}},
+			},
+		}},
+	}
+
+	cursor, err := mongoClient.GetDatabase().Collection("workflow_instances").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	type count struct {
+		Key   string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	var results []struct {
+		Statuses []count          `bson:"statuses"`
+		Nodes    []count          `bson:"nodes"`
+		Longest  []ActiveInstance `bson:"longest"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	for _, status := range results[0].Statuses {
+		d.Active.Statuses[status.Key] = status.Count
+		d.Active.Total += status.Count
+	}
+	for _, node := range results[0].Nodes {
+		d.Active.Nodes[node.Key] = node.Count
+	}
+	for _, instance := range results[0].Longest {
+		instance.Workflow = workflowName(names, instance.WorkflowID)
+		instance.Duration = d.To.Sub(instance.StartTime).Milliseconds()
+		d.Active.Longest = append(d.Active.Longest, instance)
+	}
+	return nil
+}
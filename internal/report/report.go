@@ -0,0 +1,302 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 报告中主要错误和最慢工作流的数量
+const (
+	topErrors       = 5
+	slowestWorkflow = 5
+	// maxErrorLength 错误信息按前200个字符分组和显示
+	maxErrorLength = 200
+)
+
+// ErrorCount 出现次数最多的任务错误
+type ErrorCount struct {
+	Error    string `json:"error"`
+	Count    int64  `json:"count"`
+	Workflow string `json:"workflow"` // 最早出现该错误的工作流名称
+	TaskID   string `json:"task_id"`
+}
+
+// WorkflowTiming 工作流已完成实例的执行时间
+type WorkflowTiming struct {
+	WorkflowID  string `json:"workflow_id"`
+	Workflow    string `json:"workflow"`
+	Executions  int64  `json:"executions"`
+	AvgDuration int64  `json:"avg_duration"` // 平均执行时间(毫秒)
+	MaxDuration int64  `json:"max_duration"` // 最长执行时间(毫秒)
+}
+
+// Summary 一段时间内的工作流执行汇总
+type Summary struct {
+	Project string    `json:"project"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	// Executions 期间开始的实例数，Statuses 为各状态的实例数
+	Executions int64            `json:"executions"`
+	Statuses   map[string]int64 `json:"statuses"`
+	Succeeded  int64            `json:"succeeded"`
+	// Failed 失败（failed）和中断（interrupted）的实例数
+	Failed int64 `json:"failed"`
+	// FailureRate 失败实例占已成功和失败实例的比例
+	FailureRate float64          `json:"failure_rate"`
+	TopErrors   []ErrorCount     `json:"top_errors"`
+	Slowest     []WorkflowTiming `json:"slowest_workflows"`
+}
+
+// Generate 统计项目在 [from, to) 期间开始的实例和失败的任务，project 为空时统计所有工作流
+func Generate(ctx context.Context, mongoClient *mongodb.Client, project string, from, to time.Time) (*Summary, error) {
+	summary := &Summary{
+		Project:   project,
+		From:      from,
+		To:        to,
+		Statuses:  map[string]int64{},
+		TopErrors: []ErrorCount{},
+		Slowest:   []WorkflowTiming{},
+	}
+
+	names, err := workflowNames(ctx, mongoClient, project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workflows: %v", err)
+	}
+	if project != "" && len(names) == 0 {
+		return summary, nil
+	}
+
+	if err := summarizeInstances(ctx, mongoClient, summary, names); err != nil {
+		return nil, fmt.Errorf("failed to summarize instances: %v", err)
+	}
+	if err := summarizeErrors(ctx, mongoClient, summary, names); err != nil {
+		return nil, fmt.Errorf("failed to summarize errors: %v", err)
+	}
+	return summary, nil
+}
+
+// workflowNames 返回项目中工作流ID到名称的映射，project 为空时返回所有工作流
+func workflowNames(ctx context.Context, mongoClient *mongodb.Client, project string) (map[string]string, error) {
+	filter := bson.M{}
+	if project != "" {
+		filter["project"] = project
+	}
+	cursor, err := mongoClient.GetCollection().Find(ctx, filter, options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var workflows []models.WorkflowConfig
+	if err := cursor.All(ctx, &workflows); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(workflows))
+	for _, workflow := range workflows {
+		names[workflow.ID.Hex()] = workflow.Name
+	}
+	return names, nil
+}
+
+// summarizeInstances 统计各状态的实例数和已完成实例执行时间最长的工作流
+func summarizeInstances(ctx context.Context, mongoClient *mongodb.Client, summary *Summary, names map[string]string) error {
+	match := bson.M{"start_time": bson.M{"$gte": summary.From, "$lt": summary.To}}
+	if summary.Project != "" {
+		ids := make([]string, 0, len(names))
+		for id := range names {
+			ids = append(ids, id)
+		}
+		match["workflow_id"] = bson.M{"$in": ids}
+	}
+
+	duration := bson.M{"$subtract": bson.A{"$end_time", "$start_time"}}
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$facet": bson.M{
+			"statuses": bson.A{
+				bson.M{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+			},
+			"slowest": bson.A{
+				bson.M{"$match": bson.M{"status": "completed"}},
+				bson.M{"$group": bson.M{
+					"_id":        "$workflow_id",
+					"executions": bson.M{"$sum": 1},
+					"avg":        bson.M{"$avg": duration},
+					"max":        bson.M{"$max": duration},
+				}},
+				bson.M{"$sort": bson.M{"avg": -1}},
+				bson.M{"$limit": slowestWorkflow},
+			},
+		}},
+	}
+
+	cursor, err := mongoClient.GetDatabase().Collection("workflow_instances").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var results []struct {
+		Statuses []struct {
+			Status string `bson:"_id"`
+			Count  int64  `bson:"count"`
+		} `bson:"statuses"`
+		Slowest []struct {
+			WorkflowID string  `bson:"_id"`
+			Executions int64   `bson:"executions"`
+			Avg        float64 `bson:"avg"`
+			Max        float64 `bson:"max"`
+		} `bson:"slowest"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	for _, status := range results[0].Statuses {
+		summary.Statuses[status.Status] = status.Count
+		summary.Executions += status.Count
+		switch status.Status {
+		case "completed":
+			summary.Succeeded += status.Count
+		case "failed", "interrupted":
+			summary.Failed += status.Count
+		}
+	}
+	if finished := summary.Succeeded + summary.Failed; finished > 0 {
+		summary.FailureRate = float64(summary.Failed) / float64(finished)
+	}
+
+	for _, slow := range results[0].Slowest {
+		summary.Slowest = append(summary.Slowest, WorkflowTiming{
+			WorkflowID:  slow.WorkflowID,
+			Workflow:    workflowName(names, slow.WorkflowID),
+			Executions:  slow.Executions,
+			AvgDuration: int64(slow.Avg),
+			MaxDuration: int64(slow.Max),
+		})
+	}
+	return nil
+}
+
+// summarizeErrors 按错误信息统计失败的任务，取出现次数最多的错误
+func summarizeErrors(ctx context.Context, mongoClient *mongodb.Client, summary *Summary, names map[string]string) error {
+	match := bson.M{
+		"created_at": bson.M{"$gte": summary.From, "$lt": summary.To},
+		"status":     "failed",
+	}
+	if summary.Project != "" {
+		ids := make([]primitive.ObjectID, 0, len(names))
+		for id := range names {
+			if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+				ids = append(ids, objectID)
+			}
+		}
+		match["workflow_id"] = bson.M{"$in": ids}
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$sort": bson.M{"created_at": 1}},
+		bson.M{"$group": bson.M{
+			"_id":         bson.M{"$substrCP": bson.A{"$error", 0, maxErrorLength}},
+			"count":       bson.M{"$sum": 1},
+			"workflow_id": bson.M{"$first": "$workflow_id"},
+			"task_id":     bson.M{"$first": "$task_id"},
+		}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": topErrors},
+	}
+
+	cursor, err := mongoClient.GetDatabase().Collection("execution_logs").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var results []struct {
+		Error      string             `bson:"_id"`
+		Count      int64              `bson:"count"`
+		WorkflowID primitive.ObjectID `bson:"workflow_id"`
+		TaskID     string             `bson:"task_id"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		summary.TopErrors = append(summary.TopErrors, ErrorCount{
+			Error:    result.Error,
+			Count:    result.Count,
+			Workflow: workflowName(names, result.WorkflowID.Hex()),
+			TaskID:   result.TaskID,
+		})
+	}
+	return nil
+}
+
+// workflowName 返回工作流名称，已删除的工作流返回ID
+func workflowName(names map[string]string, id string) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return id
+}
+
+// Subject 返回报告的标题
+func (s *Summary) Subject(period string) string {
+	project := s.Project
+	if project == "" {
+		project = "all projects"
+	}
+	return fmt.Sprintf("[NSA] %s workflow health report: %s", strings.ToUpper(period[:1])+period[1:], project)
+}
+
+// Text 将报告渲染为纯文本，时间按 loc 显示
+func (s *Summary) Text(loc *time.Location) string {
+	var b strings.Builder
+	project := s.Project
+	if project == "" {
+		project = "all projects"
+	}
+	const layout = "2006-01-02 15:04"
+	fmt.Fprintf(&b, "Workflow health report: %s\n", project)
+	fmt.Fprintf(&b, "Period: %s - %s (%s)\n\n", s.From.In(loc).Format(layout), s.To.In(loc).Format(layout), loc)
+
+	fmt.Fprintf(&b, "Executions: %d\n", s.Executions)
+	fmt.Fprintf(&b, "Succeeded: %d\n", s.Succeeded)
+	fmt.Fprintf(&b, "Failed: %d (failure rate %.1f%%)\n", s.Failed, s.FailureRate*100)
+	if other := s.Executions - s.Succeeded - s.Failed; other > 0 {
+		fmt.Fprintf(&b, "Other (running, delayed, cancelled, resolved): %d\n", other)
+	}
+
+	if len(s.TopErrors) > 0 {
+		b.WriteString("\nTop errors:\n")
+		for i, e := range s.TopErrors {
+			fmt.Fprintf(&b, "%d. [%d] %s / %s: %s\n", i+1, e.Count, e.Workflow, e.TaskID, e.Error)
+		}
+	}
+	if len(s.Slowest) > 0 {
+		b.WriteString("\nSlowest workflows (completed instances):\n")
+		for i, w := range s.Slowest {
+			fmt.Fprintf(&b, "%d. %s: avg %s, max %s, %d executions\n", i+1, w.Workflow,
+				formatDuration(w.AvgDuration), formatDuration(w.MaxDuration), w.Executions)
+		}
+	}
+	return b.String()
+}
+
+// formatDuration 将毫秒格式化为便于阅读的时长
+func formatDuration(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	if d < time.Second {
+		return d.String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
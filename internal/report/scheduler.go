@@ -0,0 +1,394 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"nsa/internal/cluster"
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+	"nsa/internal/secrets"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 报告周期
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+)
+
+// Collection 报告计划集合名称
+const Collection = "report_schedules"
+
+// schedulerLease 多个节点中只有持有该租约的节点发送报告
+const schedulerLease = "report-scheduler"
+
+// sendTimeout 生成和发送一份报告的超时时间
+const sendTimeout = 2 * time.Minute
+
+// Scheduler 按计划生成工作流健康报告并发送到邮件和聊天 Webhook
+type Scheduler struct {
+	cfg        config.ReportsConfig
+	logger     logger.Logger
+	mongoDB    *mongodb.Client
+	secrets    *secrets.Store
+	node       *cluster.Node
+	httpClient *http.Client
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// NewScheduler 创建报告调度器
+func NewScheduler(cfg config.ReportsConfig, logger logger.Logger, mongoClient *mongodb.Client, secretStore *secrets.Store, node *cluster.Node) *Scheduler {
+	return &Scheduler{
+		cfg:        cfg,
+		logger:     logger,
+		mongoDB:    mongoClient,
+		secrets:    secretStore,
+		node:       node,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// collection 返回报告计划集合
+func (s *Scheduler) collection() *mongo.Collection {
+	return s.mongoDB.GetDatabase().Collection(Collection)
+}
+
+// EnsureIndexes 创建名称唯一索引和到期时间索引
+func (s *Scheduler) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "enabled", Value: 1}, {Key: "next_run_at", Value: 1}}},
+	})
+	return err
+}
+
+// EmailEnabled 是否配置了发送邮件的SMTP服务器
+func (s *Scheduler) EmailEnabled() bool {
+	return s.cfg.SMTP.Host != ""
+}
+
+// Validate 校验报告计划
+func (s *Scheduler) Validate(schedule *models.ReportSchedule) error {
+	if schedule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if schedule.Period != PeriodDaily && schedule.Period != PeriodWeekly {
+		return fmt.Errorf("period must be %s or %s, got %q", PeriodDaily, PeriodWeekly, schedule.Period)
+	}
+	if schedule.Hour < 0 || schedule.Hour > 23 {
+		return fmt.Errorf("hour must be between 0 and 23, got %d", schedule.Hour)
+	}
+	if schedule.Weekday < 0 || schedule.Weekday > 6 {
+		return fmt.Errorf("weekday must be between 0 (Sunday) and 6, got %d", schedule.Weekday)
+	}
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			return fmt.Errorf("timezone %q is not a valid IANA time zone", schedule.Timezone)
+		}
+	}
+	if len(schedule.Emails) == 0 && schedule.WebhookSecret == "" {
+		return fmt.Errorf("emails or webhook_secret is required")
+	}
+	if len(schedule.Emails) > 0 && !s.EmailEnabled() {
+		return fmt.Errorf("emails require reports.smtp to be configured")
+	}
+	for _, email := range schedule.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return fmt.Errorf("invalid email address %q", email)
+		}
+	}
+	return nil
+}
+
+// Location 返回报告计划的时区，未设置时为UTC
+func Location(schedule *models.ReportSchedule) *time.Location {
+	if schedule.Timezone != "" {
+		if loc, err := time.LoadLocation(schedule.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// NextRun 返回 after 之后的下一次发送时间
+func NextRun(schedule *models.ReportSchedule, after time.Time) time.Time {
+	loc := Location(schedule)
+	t := after.In(loc)
+	next := time.Date(t.Year(), t.Month(), t.Day(), schedule.Hour, 0, 0, 0, loc)
+	days := 1
+	if schedule.Period == PeriodWeekly {
+		days = 7
+		next = next.AddDate(0, 0, (schedule.Weekday-int(next.Weekday())+7)%7)
+	}
+	for !next.After(after) {
+		next = next.AddDate(0, 0, days)
+	}
+	return next
+}
+
+// Window 返回截止到 to 的一个报告周期
+func Window(schedule *models.ReportSchedule, to time.Time) (time.Time, time.Time) {
+	if schedule.Period == PeriodWeekly {
+		return to.AddDate(0, 0, -7), to
+	}
+	return to.AddDate(0, 0, -1), to
+}
+
+// Start 启动后台调度
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		// 多个节点上只有一个发送报告，避免重复发送
+		s.node.Hold(ctx, schedulerLease, s.run)
+	}()
+}
+
+// Stop 停止后台调度，等待正在发送的报告完成
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.cancel = nil
+}
+
+// run 定期检查到期的报告，直到ctx取消
+func (s *Scheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.CheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue 发送所有到期的报告
+//
+// 先以条件更新将 next_run_at 推进到下一次，更新成功的节点才发送，避免租约切换时重复发送；
+// 服务停止期间错过多次时只发送最近一个周期的报告。
+func (s *Scheduler) runDue(ctx context.Context) {
+	now := time.Now()
+	cursor, err := s.collection().Find(ctx, bson.M{"enabled": true, "next_run_at": bson.M{"$lte": now}})
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Errorf("Failed to find due reports: %v", err)
+		}
+		return
+	}
+	var schedules []models.ReportSchedule
+	if err := cursor.All(ctx, &schedules); err != nil {
+		s.logger.Errorf("Failed to decode due reports: %v", err)
+		return
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		next := NextRun(schedule, now)
+		claimed, err := s.collection().UpdateOne(ctx,
+			bson.M{"_id": schedule.ID, "next_run_at": schedule.NextRunAt},
+			bson.M{"$set": bson.M{"next_run_at": next}})
+		if err != nil {
+			s.logger.Errorf("Failed to schedule report %s: %v", schedule.Name, err)
+			continue
+		}
+		if claimed.ModifiedCount == 0 {
+			continue
+		}
+
+		// 最近一次应发送的时间为下一次发送时间的前一个周期
+		last, _ := Window(schedule, next)
+		from, to := Window(schedule, last)
+		if _, err := s.Send(ctx, schedule, from, to); err != nil {
+			s.logger.Errorf("Failed to send report %s: %v", schedule.Name, err)
+			continue
+		}
+		s.logger.Infof("Sent report %s", schedule.Name)
+	}
+}
+
+// Send 生成 [from, to) 期间的报告并发送到计划的所有渠道，记录发送时间和错误
+func (s *Scheduler) Send(ctx context.Context, schedule *models.ReportSchedule, from, to time.Time) (*Summary, error) {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	summary, err := Generate(ctx, s.mongoDB, schedule.Project, from, to)
+	if err == nil {
+		err = s.deliver(ctx, schedule, summary)
+	}
+
+	status := bson.M{"last_run_at": time.Now(), "last_error": ""}
+	if err != nil {
+		status["last_error"] = err.Error()
+	}
+	if _, updateErr := s.collection().UpdateOne(ctx, bson.M{"_id": schedule.ID}, bson.M{"$set": status}); updateErr != nil {
+		s.logger.Errorf("Failed to update report %s: %v", schedule.Name, updateErr)
+	}
+	return summary, err
+}
+
+// deliver 发送到邮件和 Webhook，一个渠道失败时仍然发送其他渠道
+func (s *Scheduler) deliver(ctx context.Context, schedule *models.ReportSchedule, summary *Summary) error {
+	return s.Notify(ctx, schedule.Emails, schedule.WebhookSecret, summary.Subject(schedule.Period), summary.Text(Location(schedule)))
+}
+
+// Notify 发送通知到邮箱和密钥中保存的 Webhook 地址，一个渠道失败时仍然发送其他渠道
+func (s *Scheduler) Notify(ctx context.Context, emails []string, webhookSecret, subject, text string) error {
+	var errs []error
+	if len(emails) > 0 {
+		if err := s.sendEmail(emails, subject, text); err != nil {
+			errs = append(errs, fmt.Errorf("email: %v", err))
+		}
+	}
+	if webhookSecret != "" {
+		if err := s.postWebhook(ctx, webhookSecret, subject+"\n\n"+text); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %v", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postWebhook 以 {"text": ...} 发送到密钥中保存的 Webhook 地址
+func (s *Scheduler) postWebhook(ctx context.Context, secretName, text string) error {
+	url, err := s.secrets.Get(ctx, secretName)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("secret %s must contain an http or https URL", secretName)
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		// 错误信息中的地址可能包含令牌
+		return fmt.Errorf("request failed: %v", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendEmail 通过配置的SMTP服务器发送纯文本邮件
+func (s *Scheduler) sendEmail(to []string, subject, text string) error {
+	cfg := s.cfg.SMTP
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid reports.smtp.from: %v", err)
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	if cfg.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: cfg.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !cfg.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	// PlainAuth 只在TLS连接或本机服务器上发送密码
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return fmt.Errorf("invalid email address %q", recipient)
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMessage(cfg.From, to, subject, text)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage 构建UTF-8纯文本邮件
+func buildMessage(from string, to []string, subject, text string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	return b.Bytes()
+}
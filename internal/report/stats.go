@@ -0,0 +1,331 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"nsa/internal/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxTrendBuckets 趋势分桶的最大数量
+const MaxTrendBuckets = 1000
+
+// finishedStatuses 有执行时间的实例状态
+var finishedStatuses = bson.A{"completed", "failed"}
+
+// DurationStats 已结束实例（completed、failed）的执行时间(毫秒)，百分位按最近秩计算
+type DurationStats struct {
+	Count int64 `json:"count"`
+	Avg   int64 `json:"avg"`
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	P50   int64 `json:"p50"`
+	P95   int64 `json:"p95"`
+	P99   int64 `json:"p99"`
+}
+
+// TaskStats 任务的执行统计，来自执行日志
+type TaskStats struct {
+	TaskID     string `json:"task_id"`
+	Executions int64  `json:"executions"`
+	Succeeded  int64  `json:"succeeded"`
+	Failed     int64  `json:"failed"`
+	Skipped    int64  `json:"skipped"`
+	// FailureRate 失败占成功和失败的比例，跳过的任务不计入
+	FailureRate float64 `json:"failure_rate"`
+	AvgDuration int64   `json:"avg_duration"` // 平均执行时间(毫秒)，跳过的任务不计入
+	MaxDuration int64   `json:"max_duration"`
+}
+
+// TrendBucket 一个时间段内开始的实例
+type TrendBucket struct {
+	Start       time.Time `json:"start"`
+	Executions  int64     `json:"executions"`
+	Succeeded   int64     `json:"succeeded"`
+	Failed      int64     `json:"failed"`
+	FailureRate float64   `json:"failure_rate"`
+	AvgDuration int64     `json:"avg_duration"` // 已结束实例的平均执行时间(毫秒)
+}
+
+// WorkflowStats 工作流在一段时间内的执行统计
+type WorkflowStats struct {
+	WorkflowID string    `json:"workflow_id"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	// Bucket 趋势分桶的时长(秒)
+	Bucket int64 `json:"bucket"`
+	// Executions 期间开始的实例数，Statuses 为各状态的实例数
+	Executions int64            `json:"executions"`
+	Statuses   map[string]int64 `json:"statuses"`
+	Succeeded  int64            `json:"succeeded"`
+	// Failed 失败（failed）和中断（interrupted）的实例数
+	Failed      int64         `json:"failed"`
+	FailureRate float64       `json:"failure_rate"`
+	Durations   DurationStats `json:"durations"`
+	// Tasks 按失败率从高到低排列
+	Tasks []TaskStats   `json:"tasks"`
+	Trend []TrendBucket `json:"trend"`
+}
+
+// GenerateWorkflowStats 统计工作流在 [from, to) 期间开始的实例和执行的任务，趋势按 bucket 分段
+//
+// 实例按 workflow_instances 的 (workflow_id, start_time) 索引过滤，任务按 execution_logs 的 (workflow_id, created_at) 索引过滤。
+func GenerateWorkflowStats(ctx context.Context, mongoClient *mongodb.Client, workflowID primitive.ObjectID, from, to time.Time, bucket time.Duration) (*WorkflowStats, error) {
+	stats := &WorkflowStats{
+		WorkflowID: workflowID.Hex(),
+		From:       from,
+		To:         to,
+		Bucket:     int64(bucket / time.Second),
+		Statuses:   map[string]int64{},
+		Tasks:      []TaskStats{},
+		Trend:      []TrendBucket{},
+	}
+
+	if err := stats.summarizeInstances(ctx, mongoClient, bucket); err != nil {
+		return nil, fmt.Errorf("failed to summarize instances: %v", err)
+	}
+	if err := stats.percentiles(ctx, mongoClient); err != nil {
+		return nil, fmt.Errorf("failed to compute duration percentiles: %v", err)
+	}
+	if err := stats.summarizeTasks(ctx, mongoClient, workflowID); err != nil {
+		return nil, fmt.Errorf("failed to summarize tasks: %v", err)
+	}
+	return stats, nil
+}
+
+// instanceMatch 返回期间内开始的实例的过滤条件
+func (s *WorkflowStats) instanceMatch() bson.M {
+	return bson.M{
+		"workflow_id": s.WorkflowID,
+		"start_time":  bson.M{"$gte": s.From, "$lt": s.To},
+	}
+}
+
+// summarizeInstances 统计各状态的实例数、执行时间和趋势
+func (s *WorkflowStats) summarizeInstances(ctx context.Context, mongoClient *mongodb.Client, bucket time.Duration) error {
+	duration := bson.M{"$subtract": bson.A{"$end_time", "$start_time"}}
+	finished := bson.M{"$in": bson.A{"$status", finishedStatuses}}
+	pipeline := bson.A{
+		bson.M{"$match": s.instanceMatch()},
+		bson.M{"$facet": bson.M{
+			"statuses": bson.A{
+				bson.M{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+			},
+			"durations": bson.A{
+				bson.M{"$match": bson.M{"status": bson.M{"$in": finishedStatuses}}},
+				bson.M{"$group": bson.M{
+					"_id":   nil,
+					"count": bson.M{"$sum": 1},
+					"avg":   bson.M{"$avg": duration},
+					"min":   bson.M{"$min": duration},
+					"max":   bson.M{"$max": duration},
+				}},
+			},
+			"trend": bson.A{
+				bson.M{"$group": bson.M{
+					"_id": bson.M{"$floor": bson.M{"$divide": bson.A{
+						bson.M{"$subtract": bson.A{"$start_time", s.From}}, bucket.Milliseconds(),
+					}}},
+					"executions": bson.M{"$sum": 1},
+					"succeeded":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", "completed"}}, 1, 0}}},
+					"failed":     bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$in": bson.A{"$status", bson.A{"failed", "interrupted"}}}, 1, 0}}},
+					"avg":        bson.M{"$avg": bson.M{"$cond": bson.A{finished, duration, nil}}},
+				}},
+			},
+		}},
+	}
+
+	cursor, err := mongoClient.GetDatabase().Collection("workflow_instances").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var results []struct {
+		Statuses []struct {
+			Status string `bson:"_id"`
+			Count  int64  `bson:"count"`
+		} `bson:"statuses"`
+		Durations []struct {
+			Count int64   `bson:"count"`
+			Avg   float64 `bson:"avg"`
+			Min   float64 `bson:"min"`
+			Max   float64 `bson:"max"`
+		} `bson:"durations"`
+		Trend []struct {
+			Index      float64 `bson:"_id"`
+			Executions int64   `bson:"executions"`
+			Succeeded  int64   `bson:"succeeded"`
+			Failed     int64   `bson:"failed"`
+			Avg        float64 `bson:"avg"`
+		} `bson:"trend"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+
+	// 没有实例的时间段也返回，趋势是连续的
+	buckets := int(math.Ceil(float64(s.To.Sub(s.From)) / float64(bucket)))
+	for i := 0; i < buckets; i++ {
+		s.Trend = append(s.Trend, TrendBucket{Start: s.From.Add(time.Duration(i) * bucket)})
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	for _, status := range results[0].Statuses {
+		s.Statuses[status.Status] = status.Count
+		s.Executions += status.Count
+		switch status.Status {
+		case "completed":
+			s.Succeeded += status.Count
+		case "failed", "interrupted":
+			s.Failed += status.Count
+		}
+	}
+	s.FailureRate = failureRate(s.Succeeded, s.Failed)
+
+	if len(results[0].Durations) > 0 {
+		d := results[0].Durations[0]
+		s.Durations = DurationStats{Count: d.Count, Avg: int64(d.Avg), Min: int64(d.Min), Max: int64(d.Max)}
+	}
+
+	for _, trend := range results[0].Trend {
+		i := int(trend.Index)
+		if i < 0 || i >= len(s.Trend) {
+			continue
+		}
+		s.Trend[i].Executions = trend.Executions
+		s.Trend[i].Succeeded = trend.Succeeded
+		s.Trend[i].Failed = trend.Failed
+		s.Trend[i].FailureRate = failureRate(trend.Succeeded, trend.Failed)
+		s.Trend[i].AvgDuration = int64(trend.Avg)
+	}
+	return nil
+}
+
+// percentiles 按执行时间排序已结束的实例，取 p50、p95、p99 位置的值
+//
+// 不使用 MongoDB 7.0 的 $percentile，兼容更早的版本；排序数据量大时允许使用磁盘。
+func (s *WorkflowStats) percentiles(ctx context.Context, mongoClient *mongodb.Client) error {
+	count := s.Durations.Count
+	if count == 0 {
+		return nil
+	}
+
+	match := s.instanceMatch()
+	match["status"] = bson.M{"$in": finishedStatuses}
+	facets := bson.M{}
+	for name, p := range map[string]float64{"p50": 0.50, "p95": 0.95, "p99": 0.99} {
+		// 最近秩：第 ceil(p*n) 个值
+		rank := int64(math.Ceil(p*float64(count))) - 1
+		facets[name] = bson.A{bson.M{"$skip": max(rank, 0)}, bson.M{"$limit": 1}}
+	}
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$project": bson.M{"duration": bson.M{"$subtract": bson.A{"$end_time", "$start_time"}}}},
+		bson.M{"$sort": bson.M{"duration": 1}},
+		bson.M{"$facet": facets},
+	}
+
+	cursor, err := mongoClient.GetDatabase().Collection("workflow_instances").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	type value struct {
+		Duration int64 `bson:"duration"`
+	}
+	var results []struct {
+		P50 []value `bson:"p50"`
+		P95 []value `bson:"p95"`
+		P99 []value `bson:"p99"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	// 两次查询之间结束的实例可能使排名超出范围，此时使用最大值
+	pick := func(values []value) int64 {
+		if len(values) == 0 {
+			return s.Durations.Max
+		}
+		return values[0].Duration
+	}
+	s.Durations.P50 = pick(results[0].P50)
+	s.Durations.P95 = pick(results[0].P95)
+	s.Durations.P99 = pick(results[0].P99)
+	return nil
+}
+
+// summarizeTasks 按任务统计执行日志，取各任务的成功、失败、跳过次数和执行时间
+func (s *WorkflowStats) summarizeTasks(ctx context.Context, mongoClient *mongodb.Client, workflowID primitive.ObjectID) error {
+	executed := bson.M{"$ne": bson.A{"$status", "skipped"}}
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"workflow_id": workflowID,
+			"created_at":  bson.M{"$gte": s.From, "$lt": s.To},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":        "$task_id",
+			"executions": bson.M{"$sum": 1},
+			"succeeded":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", "success"}}, 1, 0}}},
+			"failed":     bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", "failed"}}, 1, 0}}},
+			"skipped":    bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", "skipped"}}, 1, 0}}},
+			"avg":        bson.M{"$avg": bson.M{"$cond": bson.A{executed, "$duration", nil}}},
+			"max":        bson.M{"$max": bson.M{"$cond": bson.A{executed, "$duration", nil}}},
+		}},
+	}
+
+	cursor, err := mongoClient.GetDatabase().Collection("execution_logs").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var results []struct {
+		TaskID     string  `bson:"_id"`
+		Executions int64   `bson:"executions"`
+		Succeeded  int64   `bson:"succeeded"`
+		Failed     int64   `bson:"failed"`
+		Skipped    int64   `bson:"skipped"`
+		Avg        float64 `bson:"avg"`
+		Max        int64   `bson:"max"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		s.Tasks = append(s.Tasks, TaskStats{
+			TaskID:      result.TaskID,
+			Executions:  result.Executions,
+			Succeeded:   result.Succeeded,
+			Failed:      result.Failed,
+			Skipped:     result.Skipped,
+			FailureRate: failureRate(result.Succeeded, result.Failed),
+			AvgDuration: int64(result.Avg),
+			MaxDuration: result.Max,
+		})
+	}
+	sort.Slice(s.Tasks, func(i, j int) bool {
+		if s.Tasks[i].FailureRate != s.Tasks[j].FailureRate {
+			return s.Tasks[i].FailureRate > s.Tasks[j].FailureRate
+		}
+		return s.Tasks[i].TaskID < s.Tasks[j].TaskID
+	})
+	return nil
+}
+
+// failureRate 失败占成功和失败的比例
+func failureRate(succeeded, failed int64) float64 {
+	if finished := succeeded + failed; finished > 0 {
+		return float64(failed) / float64(finished)
+	}
+	return 0
+}
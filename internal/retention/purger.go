@@ -0,0 +1,283 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ttlIndexName TTL索引名称
+const ttlIndexName = "retention_ttl"
+
+// collectionPolicy 集合及其保留策略
+type collectionPolicy struct {
+	collection string
+	timeField  string // 用于判断新旧的时间字段
+	policy     config.RetentionPolicy
+}
+
+// CollectionResult 单个集合的清理结果
+type CollectionResult struct {
+	Collection string `json:"collection"`
+	Expired    int64  `json:"expired"` // 超过保留天数的文档数
+	Excess     int64  `json:"excess"`  // 超过最大文档数的文档数
+	Deleted    int64  `json:"deleted"`
+}
+
+// Result 清理结果
+type Result struct {
+	DryRun      bool               `json:"dry_run"`
+	Collections []CollectionResult `json:"collections"`
+	StartedAt   time.Time          `json:"started_at"`
+	Duration    int64              `json:"duration"` // 执行时间(毫秒)
+}
+
+// Purger 执行日志与工作流实例的保留策略清理器
+type Purger struct {
+	logger   logger.Logger
+	mongoDB  *mongodb.Client
+	policies []collectionPolicy
+	interval time.Duration
+	mu       sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewPurger 创建清理器
+func NewPurger(cfg config.RetentionConfig, logger logger.Logger, mongoClient *mongodb.Client) *Purger {
+	interval := time.Duration(cfg.PurgeInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &Purger{
+		logger:  logger,
+		mongoDB: mongoClient,
+		policies: []collectionPolicy{
+			{collection: "execution_logs", timeField: "created_at", policy: cfg.ExecutionLogs},
+			{collection: "workflow_instances", timeField: "start_time", policy: cfg.Instances},
+			{collection: "instance_history", timeField: "time", policy: cfg.InstanceHistory},
+			{collection: "alert_history", timeField: "fired_at", policy: cfg.AlertHistory},
+		},
+		interval: interval,
+	}
+}
+
+// EnsureIndexes 根据保留天数创建、更新或删除TTL索引
+func (p *Purger) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, cp := range p.policies {
+		if err := p.ensureTTLIndex(ctx, cp); err != nil {
+			return fmt.Errorf("failed to ensure TTL index on %s: %v", cp.collection, err)
+		}
+	}
+	return nil
+}
+
+// ensureTTLIndex 同步单个集合的TTL索引
+func (p *Purger) ensureTTLIndex(ctx context.Context, cp collectionPolicy) error {
+	db := p.mongoDB.GetDatabase()
+	collection := db.Collection(cp.collection)
+
+	existing, err := p.findTTLIndex(ctx, collection)
+	if err != nil {
+		return err
+	}
+
+	// 未配置保留天数时删除已有TTL索引
+	if cp.policy.Days <= 0 {
+		if existing != nil {
+			_, err := collection.Indexes().DropOne(ctx, ttlIndexName)
+			return err
+		}
+		return nil
+	}
+
+	expireAfter := int32(cp.policy.Days * 24 * 3600)
+	if existing == nil {
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: cp.timeField, Value: 1}},
+			Options: options.Index().SetName(ttlIndexName).SetExpireAfterSeconds(expireAfter),
+		})
+		return err
+	}
+
+	// 保留天数变化时通过collMod修改过期时间
+	if indexExpireAfter(existing) != int64(expireAfter) {
+		return db.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: cp.collection},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: ttlIndexName},
+				{Key: "expireAfterSeconds", Value: expireAfter},
+			}},
+		}).Err()
+	}
+	return nil
+}
+
+// findTTLIndex 查找保留策略创建的TTL索引
+func (p *Purger) findTTLIndex(ctx context.Context, collection *mongo.Collection) (bson.M, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		if index["name"] == ttlIndexName {
+			return index, nil
+		}
+	}
+	return nil, nil
+}
+
+// indexExpireAfter 读取索引的过期时间（不同版本的MongoDB可能返回int32/int64/double）
+func indexExpireAfter(index bson.M) int64 {
+	switch v := index["expireAfterSeconds"].(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return -1
+}
+
+// Start 启动后台清理任务
+func (p *Purger) Start() {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				result, err := p.Purge(context.Background(), false)
+				if err != nil {
+					p.logger.Errorf("Retention purge failed: %v", err)
+					continue
+				}
+				for _, c := range result.Collections {
+					if c.Deleted > 0 {
+						p.logger.Infof("Retention purge deleted %d documents from %s", c.Deleted, c.Collection)
+					}
+				}
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理任务
+func (p *Purger) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.stop = nil
+}
+
+// Purge 按保留策略清理数据，dryRun为true时只统计不删除
+//
+// 超过保留天数的文档通常已由TTL索引删除，这里一并处理以覆盖TTL后台任务的延迟。
+func (p *Purger) Purge(ctx context.Context, dryRun bool) (*Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := &Result{
+		DryRun:      dryRun,
+		Collections: []CollectionResult{},
+		StartedAt:   time.Now(),
+	}
+
+	for _, cp := range p.policies {
+		if cp.policy.Days <= 0 && cp.policy.MaxDocuments <= 0 {
+			continue
+		}
+
+		cr, err := p.purgeCollection(ctx, cp, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge %s: %v", cp.collection, err)
+		}
+		result.Collections = append(result.Collections, *cr)
+	}
+
+	result.Duration = time.Since(result.StartedAt).Milliseconds()
+	return result, nil
+}
+
+// purgeCollection 清理单个集合
+func (p *Purger) purgeCollection(ctx context.Context, cp collectionPolicy, dryRun bool) (*CollectionResult, error) {
+	collection := p.mongoDB.GetDatabase().Collection(cp.collection)
+	cr := &CollectionResult{Collection: cp.collection}
+
+	var conditions []bson.M
+
+	// 按保留天数
+	if cp.policy.Days > 0 {
+		expired := bson.M{cp.timeField: bson.M{"$lt": time.Now().AddDate(0, 0, -cp.policy.Days)}}
+		count, err := collection.CountDocuments(ctx, expired)
+		if err != nil {
+			return nil, err
+		}
+		cr.Expired = count
+		conditions = append(conditions, expired)
+	}
+
+	// 按最大文档数：找到第max_documents新的文档，比它更旧的都删除
+	if cp.policy.MaxDocuments > 0 {
+		opts := options.FindOne().
+			SetSort(bson.D{{Key: cp.timeField, Value: -1}}).
+			SetSkip(cp.policy.MaxDocuments - 1).
+			SetProjection(bson.M{cp.timeField: 1})
+
+		var boundary bson.M
+		err := collection.FindOne(ctx, bson.M{}, opts).Decode(&boundary)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		if err == nil {
+			excess := bson.M{cp.timeField: bson.M{"$lt": boundary[cp.timeField]}}
+			count, err := collection.CountDocuments(ctx, excess)
+			if err != nil {
+				return nil, err
+			}
+			cr.Excess = count
+			conditions = append(conditions, excess)
+		}
+	}
+
+	if dryRun || len(conditions) == 0 {
+		return cr, nil
+	}
+
+	deleted, err := collection.DeleteMany(ctx, bson.M{"$or": conditions})
+	if err != nil {
+		return nil, err
+	}
+	cr.Deleted = deleted.DeletedCount
+	return cr, nil
+}
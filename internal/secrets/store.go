@@ -0,0 +1,45 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Store 密钥存储，供动作按名称读取API Key等敏感配置
+type Store struct {
+	mongoDB *mongodb.Client
+}
+
+// NewStore 创建新的密钥存储
+func NewStore(mongoClient *mongodb.Client) *Store {
+	return &Store{mongoDB: mongoClient}
+}
+
+// Collection 返回密钥集合
+func (s *Store) Collection() *mongo.Collection {
+	return s.mongoDB.GetDatabase().Collection("secrets")
+}
+
+// Get 根据名称获取密钥值
+func (s *Store) Get(ctx context.Context, name string) (string, error) {
+	ctxDB, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var secret models.Secret
+	err := s.Collection().FindOne(ctxDB, bson.M{"name": name}).Decode(&secret)
+	if err == mongo.ErrNoDocuments {
+		return "", fmt.Errorf("secret %s not found", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load secret %s: %v", name, err)
+	}
+
+	return secret.Value, nil
+}
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nsa/internal/alert"
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ListAlertRules 获取告警规则列表，支持按 workflow_id、type 过滤
+func ListAlertRules(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		for _, field := range []string{"workflow_id", "type"} {
+			if value := c.Query(field); value != "" {
+				filter[field] = value
+			}
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(alert.RuleCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := collection.Find(ctxDB, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find alert rules: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find alert rules",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		rules := []models.AlertRule{}
+		if err := cursor.All(ctxDB, &rules); err != nil {
+			ctx.Logger.Errorf("Failed to decode alert rules: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode alert rules",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    rules,
+		})
+	}
+}
+
+// GetAlertRule 获取单个告警规则
+func GetAlertRule(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := ctx.alertRuleByID(c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    rule,
+		})
+	}
+}
+
+// alertRuleByID 按路径参数读取告警规则
+func (ctx *Context) alertRuleByID(c *gin.Context) (*models.AlertRule, bool) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "Invalid alert rule ID",
+		})
+		return nil, false
+	}
+
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var rule models.AlertRule
+	if err := ctx.MongoClient.GetDatabase().Collection(alert.RuleCollection).FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&rule); err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "Alert rule not found",
+		})
+		return nil, false
+	}
+	return &rule, true
+}
+
+// validateAlertRule 校验告警规则；校验失败时写入400响应
+func (ctx *Context) validateAlertRule(c *gin.Context, rule *models.AlertRule) bool {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ctx.Alerts.Validate(ctxDB, rule); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// CreateAlertRule 创建告警规则
+func CreateAlertRule(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rule models.AlertRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+		if !ctx.validateAlertRule(c, &rule) {
+			return
+		}
+
+		now := time.Now()
+		rule.ID = primitive.NilObjectID
+		rule.CreatedBy = c.GetString("username")
+		rule.LastEvaluatedAt = time.Time{}
+		rule.LastFiredAt = time.Time{}
+		rule.LastError = ""
+		rule.CreatedAt = now
+		rule.UpdatedAt = now
+
+		collection := ctx.MongoClient.GetDatabase().Collection(alert.RuleCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := collection.InsertOne(ctxDB, rule)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Alert rule with same name already exists",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to create alert rule: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to create alert rule",
+			})
+			return
+		}
+
+		rule.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.recordAudit(c, auditCreate, "alert_rule", rule.ID.Hex(), rule.Name, nil, rule)
+
+		ctx.Logger.Infof("Alert rule created: %s (%s)", rule.Name, rule.Type)
+		c.JSON(http.StatusCreated, Response{
+			Code:    201,
+			Message: "Alert rule created successfully",
+			Data:    rule,
+		})
+	}
+}
+
+// UpdateAlertRule 更新告警规则，修改前结束的实例不再按新规则告警
+func UpdateAlertRule(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		original, ok := ctx.alertRuleByID(c)
+		if !ok {
+			return
+		}
+
+		var req models.AlertRule
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		updated := *original
+		updated.Name = req.Name
+		updated.Description = req.Description
+		updated.Type = req.Type
+		updated.WorkflowID = req.WorkflowID
+		updated.Threshold = req.Threshold
+		updated.Window = req.Window
+		updated.MaxDuration = req.MaxDuration
+		updated.Cooldown = req.Cooldown
+		updated.Channels = req.Channels
+		updated.Enabled = req.Enabled
+		updated.UpdatedAt = time.Now()
+		if !ctx.validateAlertRule(c, &updated) {
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(alert.RuleCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		set := bson.M{
+			"name":         updated.Name,
+			"description":  updated.Description,
+			"type":         updated.Type,
+			"workflow_id":  updated.WorkflowID,
+			"threshold":    updated.Threshold,
+			"window":       updated.Window,
+			"max_duration": updated.MaxDuration,
+			"cooldown":     updated.Cooldown,
+			"channels":     updated.Channels,
+			"enabled":      updated.Enabled,
+			"updated_at":   updated.UpdatedAt,
+		}
+		_, err := collection.UpdateOne(ctxDB, bson.M{"_id": original.ID}, bson.M{"$set": set})
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Alert rule with same name already exists",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to update alert rule: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update alert rule",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditUpdate, "alert_rule", original.ID.Hex(), updated.Name, original, updated)
+
+		ctx.Logger.Infof("Alert rule updated: %s", updated.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Alert rule updated successfully",
+			Data:    updated,
+		})
+	}
+}
+
+// DeleteAlertRule 删除告警规则，已触发的告警保留在告警历史中
+func DeleteAlertRule(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := ctx.alertRuleByID(c)
+		if !ok {
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(alert.RuleCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := collection.DeleteOne(ctxDB, bson.M{"_id": rule.ID}); err != nil {
+			ctx.Logger.Errorf("Failed to delete alert rule: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to delete alert rule",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "alert_rule", rule.ID.Hex(), rule.Name, rule, nil)
+
+		ctx.Logger.Infof("Alert rule deleted: %s", rule.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Alert rule deleted successfully",
+		})
+	}
+}
+
+// TestAlertRule 发送一条测试告警到规则的所有通知渠道，返回各渠道的发送结果，不记录告警历史
+func TestAlertRule(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := ctx.alertRuleByID(c)
+		if !ok {
+			return
+		}
+
+		ctxNotify, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		notifications := ctx.Alerts.Test(ctxNotify, rule)
+		ctx.recordAudit(c, auditSend, "alert_rule", rule.ID.Hex(), rule.Name, nil, nil)
+
+		for _, notification := range notifications {
+			if notification.Error != "" {
+				c.JSON(http.StatusBadGateway, Response{
+					Code:    502,
+					Message: "Failed to send test alert",
+					Data:    notifications,
+				})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Test alert sent successfully",
+			Data:    notifications,
+		})
+	}
+}
+
+// ListAlerts 获取告警历史，按触发时间倒序，支持 rule_id、workflow_id、instance_id、type 过滤和 from、to 时间范围
+func ListAlerts(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PaginationRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid query parameters",
+			})
+			return
+		}
+
+		// 设置默认值
+		if req.Page <= 0 {
+			req.Page = 1
+		}
+		if req.PageSize <= 0 {
+			req.PageSize = 50
+		}
+
+		// 构建查询条件
+		filter := bson.M{}
+		for _, field := range []string{"rule_id", "workflow_id", "instance_id", "type"} {
+			if value := c.Query(field); value != "" {
+				filter[field] = value
+			}
+		}
+
+		timeRange := bson.M{}
+		if from := c.Query("from"); from != "" {
+			t, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid from time, expected RFC3339",
+				})
+				return
+			}
+			timeRange["$gte"] = t
+		}
+		if to := c.Query("to"); to != "" {
+			t, err := time.Parse(time.RFC3339, to)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid to time, expected RFC3339",
+				})
+				return
+			}
+			timeRange["$lte"] = t
+		}
+		if len(timeRange) > 0 {
+			filter["fired_at"] = timeRange
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(alert.HistoryCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// 获取总数
+		total, err := collection.CountDocuments(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to count alerts: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to count alerts",
+			})
+			return
+		}
+
+		// 查询数据
+		opts := options.Find()
+		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
+		opts.SetLimit(int64(req.PageSize))
+		opts.SetSort(bson.D{{Key: "fired_at", Value: -1}})
+
+		cursor, err := collection.Find(ctxDB, filter, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find alerts: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find alerts",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		alerts := []models.Alert{}
+		if err := cursor.All(ctxDB, &alerts); err != nil {
+			ctx.Logger.Errorf("Failed to decode alerts: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode alerts",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: PaginationResponse{
+				Total:    total,
+				Page:     req.Page,
+				PageSize: req.PageSize,
+				Data:     alerts,
+			},
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"nsa/internal/datasource"
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// aliasCollection 数据源别名集合名称
+const aliasCollection = "datasource_aliases"
+
+// LoadDataSourceAliases 从数据库加载数据源别名并替换数据源管理器中的别名
+func LoadDataSourceAliases(mongoClient *mongodb.Client, dataSourceMgr *datasource.Manager) error {
+	var aliases []models.DataSourceAlias
+	err := mongoClient.Retry(context.Background(), 10*time.Second, func(ctx context.Context) error {
+		cursor, err := mongoClient.GetDatabase().Collection(aliasCollection).Find(ctx, bson.M{})
+		if err != nil {
+			return err
+		}
+		aliases = nil
+		return cursor.All(ctx, &aliases)
+	})
+	if err != nil {
+		return err
+	}
+	dataSourceMgr.SetAliases(aliases)
+	return nil
+}
+
+// reloadDataSourceAliases 别名修改后重新加载当前节点的别名
+func (ctx *Context) reloadDataSourceAliases() {
+	if err := LoadDataSourceAliases(ctx.MongoClient, ctx.DataSourceMgr); err != nil {
+		ctx.Logger.Errorf("Failed to reload datasource aliases: %v", err)
+	}
+}
+
+// validateAlias 检查别名名称不与数据源重名，映射的数据源都存在
+func (ctx *Context) validateAlias(ctxDB context.Context, alias *models.DataSourceAlias) (int, error) {
+	if alias.Name == "" || alias.DataSource == "" {
+		return http.StatusBadRequest, fmt.Errorf("name and datasource are required")
+	}
+
+	names := []string{alias.Name, alias.DataSource}
+	for project, target := range alias.Projects {
+		if project == "" || target == "" {
+			return http.StatusBadRequest, fmt.Errorf("project mappings must have non-empty project and datasource")
+		}
+		names = append(names, target)
+	}
+	types, err := ctx.physicalDataSourceTypes(ctxDB, names)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to find datasources")
+	}
+	if _, exists := types[alias.Name]; exists {
+		return http.StatusConflict, fmt.Errorf("datasource with same name as alias already exists")
+	}
+
+	// 同一个别名映射的数据源类型必须相同，否则工作流在不同项目下使用的动作参数不一致
+	targets := append([]string{alias.DataSource}, sortedValues(alias.Projects)...)
+	for _, target := range targets {
+		if _, exists := types[target]; !exists {
+			return http.StatusBadRequest, fmt.Errorf("datasource %s not found", target)
+		}
+		if types[target] != types[alias.DataSource] {
+			return http.StatusBadRequest, fmt.Errorf("datasource %s is %s but %s is %s", target, types[target], alias.DataSource, types[alias.DataSource])
+		}
+	}
+	return 0, nil
+}
+
+// sortedValues 返回排序后的映射值
+func sortedValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// ListDataSourceAliases 获取数据源别名列表
+func ListDataSourceAliases(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collection := ctx.MongoClient.GetDatabase().Collection(aliasCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+		cursor, err := collection.Find(ctxDB, bson.M{}, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find datasource aliases: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find datasource aliases",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		aliases := []models.DataSourceAlias{}
+		if err := cursor.All(ctxDB, &aliases); err != nil {
+			ctx.Logger.Errorf("Failed to decode datasource aliases: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode datasource aliases",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    aliases,
+		})
+	}
+}
+
+// CreateDataSourceAlias 创建数据源别名
+func CreateDataSourceAlias(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var alias models.DataSourceAlias
+		if err := c.ShouldBindJSON(&alias); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(aliasCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if status, err := ctx.validateAlias(ctxDB, &alias); err != nil {
+			c.JSON(status, Response{
+				Code:    status,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		existingCount, err := collection.CountDocuments(ctxDB, bson.M{"name": alias.Name})
+		if err != nil {
+			ctx.Logger.Errorf("Failed to check existing datasource alias: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to check existing datasource alias",
+			})
+			return
+		}
+		if existingCount > 0 {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Datasource alias with same name already exists",
+			})
+			return
+		}
+
+		alias.ID = primitive.NilObjectID
+		alias.CreatedAt = time.Now()
+		alias.UpdatedAt = time.Now()
+		result, err := collection.InsertOne(ctxDB, alias)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to create datasource alias: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to create datasource alias",
+			})
+			return
+		}
+
+		alias.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.recordAudit(c, auditCreate, "datasource_alias", alias.ID.Hex(), alias.Name, nil, alias)
+		ctx.reloadDataSourceAliases()
+
+		ctx.Logger.Infof("Datasource alias created: %s -> %s", alias.Name, alias.DataSource)
+		c.JSON(http.StatusCreated, Response{
+			Code:    201,
+			Message: "Datasource alias created successfully",
+			Data:    alias,
+		})
+	}
+}
+
+// UpdateDataSourceAlias 更新数据源别名的映射，别名名称不能修改
+func UpdateDataSourceAlias(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid datasource alias ID",
+			})
+			return
+		}
+
+		var req models.DataSourceAlias
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(aliasCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var original models.DataSourceAlias
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&original); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Datasource alias not found",
+			})
+			return
+		}
+
+		updated := original
+		updated.DataSource = req.DataSource
+		updated.Projects = req.Projects
+		updated.Description = req.Description
+		updated.UpdatedAt = time.Now()
+		if status, err := ctx.validateAlias(ctxDB, &updated); err != nil {
+			c.JSON(status, Response{
+				Code:    status,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		set := bson.M{
+			"datasource":  updated.DataSource,
+			"projects":    updated.Projects,
+			"description": updated.Description,
+			"updated_at":  updated.UpdatedAt,
+		}
+		if _, err := collection.UpdateOne(ctxDB, bson.M{"_id": objectID}, bson.M{"$set": set}); err != nil {
+			ctx.Logger.Errorf("Failed to update datasource alias: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update datasource alias",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditUpdate, "datasource_alias", original.ID.Hex(), original.Name, original, updated)
+		ctx.reloadDataSourceAliases()
+
+		ctx.Logger.Infof("Datasource alias updated: %s -> %s", updated.Name, updated.DataSource)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Datasource alias updated successfully",
+			Data:    updated,
+		})
+	}
+}
+
+// DeleteDataSourceAlias 删除数据源别名
+func DeleteDataSourceAlias(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid datasource alias ID",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(aliasCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var alias models.DataSourceAlias
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&alias); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Datasource alias not found",
+			})
+			return
+		}
+
+		if _, err := collection.DeleteOne(ctxDB, bson.M{"_id": objectID}); err != nil {
+			ctx.Logger.Errorf("Failed to delete datasource alias: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to delete datasource alias",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "datasource_alias", alias.ID.Hex(), alias.Name, alias, nil)
+		ctx.reloadDataSourceAliases()
+
+		ctx.Logger.Infof("Datasource alias deleted: %s", alias.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Datasource alias deleted successfully",
+		})
+	}
+}
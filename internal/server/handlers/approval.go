@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ApprovalDecisionRequest 审批请求
+type ApprovalDecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// InitApprovals 创建审批索引
+func InitApprovals(ctx *Context) error {
+	collection := ctx.MongoClient.GetDatabase().Collection("approvals")
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctxDB, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "instance_id", Value: 1}}},
+	})
+	return err
+}
+
+// ListApprovals 获取审批列表，支持按状态、工作流和实例过滤
+func ListApprovals(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PaginationRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid query parameters",
+			})
+			return
+		}
+
+		// 设置默认值
+		if req.Page <= 0 {
+			req.Page = 1
+		}
+		if req.PageSize <= 0 {
+			req.PageSize = 20
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("approvals")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// 构建查询条件
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if workflowID := c.Query("workflow_id"); workflowID != "" {
+			filter["workflow_id"] = workflowID
+		}
+		if instanceID := c.Query("instance_id"); instanceID != "" {
+			filter["instance_id"] = instanceID
+		}
+
+		total, err := collection.CountDocuments(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to count approvals: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to count approvals",
+			})
+			return
+		}
+
+		opts := options.Find()
+		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
+		opts.SetLimit(int64(req.PageSize))
+		opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+		cursor, err := collection.Find(ctxDB, filter, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find approvals: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find approvals",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		approvals := []models.Approval{}
+		if err := cursor.All(ctxDB, &approvals); err != nil {
+			ctx.Logger.Errorf("Failed to decode approvals: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode approvals",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: PaginationResponse{
+				Total:    total,
+				Page:     req.Page,
+				PageSize: req.PageSize,
+				Data:     approvals,
+			},
+		})
+	}
+}
+
+// GetApproval 获取审批详情
+func GetApproval(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid approval ID",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("approvals")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var approval models.Approval
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&approval); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Approval not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    approval,
+		})
+	}
+}
+
+// ApproveApproval 批准审批，等待中的工作流实例继续执行
+func ApproveApproval(ctx *Context) gin.HandlerFunc {
+	return decideApproval(ctx, models.ApprovalApproved)
+}
+
+// RejectApproval 拒绝审批，等待中的审批任务失败
+func RejectApproval(ctx *Context) gin.HandlerFunc {
+	return decideApproval(ctx, models.ApprovalRejected)
+}
+
+// decideApproval 记录审批结果，只有仍在等待的审批可以处理
+//
+// 审批配置了 approvers 时只有列出的用户和管理员可以处理。
+func decideApproval(ctx *Context, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid approval ID",
+			})
+			return
+		}
+
+		var req ApprovalDecisionRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid request format",
+				})
+				return
+			}
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("approvals")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var original models.Approval
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&original); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Approval not found",
+			})
+			return
+		}
+
+		username, _ := c.Get("username")
+		role, _ := c.Get("role")
+		actor, _ := username.(string)
+		if len(original.Approvers) > 0 && role != models.RoleAdmin && !slices.Contains(original.Approvers, actor) {
+			c.JSON(http.StatusForbidden, Response{
+				Code:    403,
+				Message: "You are not an approver of this request",
+			})
+			return
+		}
+
+		// 只更新仍在等待且未过期的审批，避免与超时或其他审批人并发处理
+		var approval models.Approval
+		err = collection.FindOneAndUpdate(ctxDB,
+			bson.M{"_id": objectID, "status": models.ApprovalPending, "expires_at": bson.M{"$gt": time.Now()}},
+			bson.M{"$set": bson.M{
+				"status":     status,
+				"comment":    req.Comment,
+				"decided_by": actor,
+				"decided_at": time.Now(),
+			}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&approval)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Approval is no longer pending",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to update approval: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update approval",
+			})
+			return
+		}
+
+		action := auditApprove
+		if status == models.ApprovalRejected {
+			action = auditReject
+		}
+		ctx.recordAudit(c, action, "approval", id, approval.Title, original, approval)
+
+		ctx.Logger.Infof("Approval %s %s by %s", id, status, actor)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    approval,
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 审计动作
+const (
+	auditCreate  = "create"
+	auditUpdate  = "update"
+	auditDelete  = "delete"
+	auditEnable  = "enable"
+	auditDisable = "disable"
+	auditReload  = "reload"
+	auditApprove = "approve"
+	auditReject  = "reject"
+	auditSend    = "send"
+	auditPromote = "promote"
+	auditPause   = "pause"
+	auditResume  = "resume"
+	auditTrigger = "trigger"
+)
+
+// auditIgnoredFields 不参与差异比较的字段
+var auditIgnoredFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// auditSensitiveFields 敏感字段，变更时只记录掩码
+var auditSensitiveFields = []string{"password", "secret", "token", "value"}
+
+// InitAuditLogs 创建审计日志索引
+func InitAuditLogs(ctx *Context) error {
+	collection := ctx.MongoClient.GetDatabase().Collection("audit_logs")
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctxDB, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "resource_type", Value: 1}, {Key: "resource_id", Value: 1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}}},
+	})
+	return err
+}
+
+// recordAudit 记录一次管理接口变更
+//
+// before/after 为变更前后的资源对象，创建时 before 为 nil，删除时 after 为 nil。
+// 写入失败只记录错误日志，不影响接口返回。
+func (ctx *Context) recordAudit(c *gin.Context, action, resourceType, resourceID, resourceName string, before, after interface{}) {
+	actor, _ := c.Get("username")
+	role, _ := c.Get("role")
+	actorName, _ := actor.(string)
+	roleName, _ := role.(string)
+
+	ctx.saveAudit(models.AuditLog{
+		Actor:        actorName,
+		Role:         roleName,
+		IP:           c.ClientIP(),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		ResourceName: resourceName,
+		Changes:      auditDiff(auditSnapshot(before), auditSnapshot(after)),
+		CreatedAt:    time.Now(),
+	})
+}
+
+// saveAudit 保存审计日志，用于管理接口之外的变更（如自动禁用工作流）
+func (ctx *Context) saveAudit(entry models.AuditLog) {
+	collection := ctx.MongoClient.GetDatabase().Collection("audit_logs")
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := collection.InsertOne(ctxDB, entry); err != nil {
+		ctx.Logger.Errorf("Failed to save audit log for %s %s %s: %v", entry.Action, entry.ResourceType, entry.ResourceID, err)
+	}
+}
+
+// auditSnapshot 将资源对象转换为按JSON字段名索引的map
+func auditSnapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+	for field := range auditIgnoredFields {
+		delete(snapshot, field)
+	}
+	return snapshot
+}
+
+// auditDiff 比较变更前后的字段，敏感字段以掩码记录
+func auditDiff(before, after map[string]interface{}) map[string]models.AuditChange {
+	changes := make(map[string]models.AuditChange)
+
+	fields := make(map[string]bool)
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+
+	for field := range fields {
+		oldValue, newValue := before[field], after[field]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if isSensitiveField(field) {
+			oldValue, newValue = maskAuditValue(oldValue), maskAuditValue(newValue)
+		} else {
+			oldValue, newValue = maskSensitive(oldValue), maskSensitive(newValue)
+		}
+		changes[field] = models.AuditChange{Before: oldValue, After: newValue}
+	}
+	return changes
+}
+
+// isSensitiveField 判断字段名是否敏感
+func isSensitiveField(field string) bool {
+	field = strings.ToLower(field)
+	for _, keyword := range auditSensitiveFields {
+		if strings.Contains(field, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSensitive 递归掩码嵌套对象中的敏感字段（如任务参数中的密码）
+func maskSensitive(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			// 嵌套对象中的 value 常为普通数据，只按名称明确的敏感字段掩码
+			if key != "value" && isSensitiveField(key) {
+				result[key] = maskAuditValue(item)
+			} else {
+				result[key] = maskSensitive(item)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = maskSensitive(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// maskAuditValue 掩码敏感值，保留是否为空的信息
+func maskAuditValue(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return "****"
+}
+
+// ListAuditLogs 获取审计日志列表
+func ListAuditLogs(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PaginationRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid query parameters",
+			})
+			return
+		}
+
+		// 设置默认值
+		if req.Page <= 0 {
+			req.Page = 1
+		}
+		if req.PageSize <= 0 {
+			req.PageSize = 50
+		}
+
+		// 构建查询条件
+		filter := bson.M{}
+		for _, field := range []string{"actor", "action", "resource_type", "resource_id", "ip"} {
+			if value := c.Query(field); value != "" {
+				filter[field] = value
+			}
+		}
+
+		timeRange := bson.M{}
+		if from := c.Query("from"); from != "" {
+			t, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid from time, expected RFC3339",
+				})
+				return
+			}
+			timeRange["$gte"] = t
+		}
+		if to := c.Query("to"); to != "" {
+			t, err := time.Parse(time.RFC3339, to)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid to time, expected RFC3339",
+				})
+				return
+			}
+			timeRange["$lte"] = t
+		}
+		if len(timeRange) > 0 {
+			filter["created_at"] = timeRange
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("audit_logs")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// 获取总数
+		total, err := collection.CountDocuments(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to count audit logs: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to count audit logs",
+			})
+			return
+		}
+
+		// 查询数据
+		opts := options.Find()
+		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
+		opts.SetLimit(int64(req.PageSize))
+		opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+		cursor, err := collection.Find(ctxDB, filter, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find audit logs: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find audit logs",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		var logs []models.AuditLog
+		if err := cursor.All(ctxDB, &logs); err != nil {
+			ctx.Logger.Errorf("Failed to decode audit logs: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode audit logs",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: PaginationResponse{
+				Total:    total,
+				Page:     req.Page,
+				PageSize: req.PageSize,
+				Data:     logs,
+			},
+		})
+	}
+}
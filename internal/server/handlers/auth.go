@@ -1,19 +1,42 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"nsa/internal/logger"
+	"nsa/internal/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// 令牌类型
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// 令牌默认有效期
+const (
+	defaultAccessTokenTTL  = 2 * time.Hour
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+)
+
 // JWTClaims JWT声明
 type JWTClaims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	TokenType string `json:"token_type"`
+	SessionID string `json:"sid,omitempty"` // 登录会话ID，刷新后不变
+	Timezone  string `json:"tz,omitempty"`  // 用户设置的时区，修改后刷新令牌时生效
 	jwt.RegisteredClaims
 }
 
@@ -30,7 +53,13 @@ func Login(ctx *Context) gin.HandlerFunc {
 		}
 
 		// 验证用户名和密码
-		if !validateCredentials(ctx, req.Username, req.Password) {
+		user, ok := validateCredentials(ctx, req.Username, req.Password)
+		if !ok {
+			ctx.securityEvent(c, securityLogin, logger.SecurityFailure, map[string]interface{}{
+				"username":    req.Username,
+				"auth_method": "password",
+				"reason":      "invalid_credentials",
+			})
 			c.JSON(http.StatusUnauthorized, Response{
 				Code:    401,
 				Message: "Invalid username or password",
@@ -39,7 +68,7 @@ func Login(ctx *Context) gin.HandlerFunc {
 		}
 
 		// 生成JWT令牌
-		token, expiresAt, err := generateJWT(ctx, req.Username)
+		response, err := issueTokens(ctx, c, user, "")
 		if err != nil {
 			ctx.Logger.Errorf("Failed to generate JWT: %v", err)
 			c.JSON(http.StatusInternalServerError, Response{
@@ -49,28 +78,123 @@ func Login(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
-		response := LoginResponse{
-			Token:     token,
-			ExpiresAt: expiresAt,
-			User: User{
-				Username: req.Username,
-				Role:     "admin",
-			},
+		ctx.Logger.Infof("User %s logged in successfully", user.Username)
+		ctx.securityEvent(c, securityLogin, logger.SecuritySuccess, map[string]interface{}{
+			"username":    user.Username,
+			"role":        user.Role,
+			"auth_method": "password",
+			"session_id":  response.SessionID,
+		})
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Login successful",
+			Data:    response,
+		})
+	}
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌对
+func RefreshToken(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		claims, err := validateJWT(ctx, req.RefreshToken)
+		if reason := rejectReason(ctx, claims, err, tokenTypeRefresh); reason != "" {
+			ctx.securityEvent(c, securityRefresh, logger.SecurityFailure, tokenEventFields(claims, reason))
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "Invalid or expired refresh token",
+			})
+			return
+		}
+
+		// 重新读取用户，角色变更或禁用立即生效
+		user, err := findUser(ctx, claims.Username)
+		if err != nil || !user.Enabled {
+			ctx.securityEvent(c, securityRefresh, logger.SecurityFailure, tokenEventFields(claims, "user_disabled"))
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "User not found or disabled",
+			})
+			return
+		}
+
+		// 刷新令牌只能使用一次，并发使用同一刷新令牌时只有一个请求能吊销成功
+		if err := ctx.Revocations.Revoke(claims.ID, claims.Username, claims.ExpiresAt.Time); err == errTokenRevoked {
+			ctx.securityEvent(c, securityRefresh, logger.SecurityFailure, tokenEventFields(claims, "revoked_token"))
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "Invalid or expired refresh token",
+			})
+			return
+		} else if err != nil {
+			ctx.Logger.Errorf("Failed to revoke refresh token: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to refresh token",
+			})
+			return
+		}
+
+		response, err := issueTokens(ctx, c, user, claims.SessionID)
+		if err == errSessionRevoked {
+			ctx.securityEvent(c, securityRefresh, logger.SecurityFailure, tokenEventFields(claims, "session_revoked"))
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "Session has been revoked",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to generate JWT: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to generate token",
+			})
+			return
 		}
 
-		ctx.Logger.Infof("User %s logged in successfully", req.Username)
+		ctx.securityEvent(c, securityRefresh, logger.SecuritySuccess, tokenEventFields(claims, ""))
 		c.JSON(http.StatusOK, Response{
 			Code:    200,
-			Message: "Login successful",
+			Message: "Token refreshed successfully",
 			Data:    response,
 		})
 	}
 }
 
-// Logout 用户登出
+// Logout 用户登出，吊销当前访问令牌和刷新令牌
 func Logout(ctx *Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 在实际应用中，这里可以将token加入黑名单
+		// 吊销访问令牌
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if claims, err := validateJWT(ctx, tokenString); err == nil {
+			if err := ctx.Revocations.Revoke(claims.ID, claims.Username, claims.ExpiresAt.Time); err != nil && err != errTokenRevoked {
+				ctx.Logger.Errorf("Failed to revoke access token: %v", err)
+			}
+			if err := ctx.Sessions.End(claims.SessionID); err != nil {
+				ctx.Logger.Errorf("Failed to end session: %v", err)
+			}
+			ctx.securityEvent(c, securityLogout, logger.SecuritySuccess, tokenEventFields(claims, ""))
+		}
+
+		// 吊销刷新令牌
+		var req LogoutRequest
+		if err := c.ShouldBindJSON(&req); err == nil && req.RefreshToken != "" {
+			if claims, err := validateJWT(ctx, req.RefreshToken); err == nil && claims.TokenType == tokenTypeRefresh {
+				if err := ctx.Revocations.Revoke(claims.ID, claims.Username, claims.ExpiresAt.Time); err != nil && err != errTokenRevoked {
+					ctx.Logger.Errorf("Failed to revoke refresh token: %v", err)
+				}
+			}
+		}
+
 		c.JSON(http.StatusOK, Response{
 			Code:    200,
 			Message: "Logout successful",
@@ -91,9 +215,11 @@ func GetCurrentUser(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
+		role, _ := c.Get("role")
 		user := User{
 			Username: username.(string),
-			Role:     "admin",
+			Role:     role.(string),
+			Timezone: c.GetString("timezone"),
 		}
 
 		c.JSON(http.StatusOK, Response{
@@ -104,12 +230,79 @@ func GetCurrentUser(ctx *Context) gin.HandlerFunc {
 	}
 }
 
+// PreferencesRequest 当前用户的偏好设置
+type PreferencesRequest struct {
+	// Timezone API响应中时间的时区，空字符串表示使用 admin.timezone
+	Timezone *string `json:"timezone"`
+}
+
+// UpdateCurrentUser 修改当前用户的偏好设置，写入令牌的设置在刷新令牌后生效
+func UpdateCurrentUser(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PreferencesRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Timezone == nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+		if !validTimezone(*req.Timezone) {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid timezone",
+			})
+			return
+		}
+
+		user, err := findUser(ctx, c.GetString("username"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "User not found",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("users")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		set := bson.M{"timezone": *req.Timezone, "updated_at": time.Now()}
+		if _, err := collection.UpdateOne(ctxDB, bson.M{"_id": user.ID}, bson.M{"$set": set}); err != nil {
+			ctx.Logger.Errorf("Failed to update user preferences: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update preferences",
+			})
+			return
+		}
+
+		before := *user
+		before.Password = ""
+		user.Password = ""
+		user.Timezone = *req.Timezone
+		ctx.recordAudit(c, auditUpdate, "user", user.ID.Hex(), user.Username, before, *user)
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Preferences updated, refresh the token to apply",
+			Data: User{
+				Username: user.Username,
+				Role:     user.Role,
+				Timezone: user.Timezone,
+			},
+		})
+	}
+}
+
 // AuthMiddleware 认证中间件
 func AuthMiddleware(ctx *Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取Authorization头
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			ctx.securityEvent(c, securityTokenRejected, logger.SecurityFailure, map[string]interface{}{"reason": "missing_token"})
 			c.JSON(http.StatusUnauthorized, Response{
 				Code:    401,
 				Message: "Authorization header required",
@@ -121,6 +314,7 @@ func AuthMiddleware(ctx *Context) gin.HandlerFunc {
 		// 检查Bearer前缀
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
+			ctx.securityEvent(c, securityTokenRejected, logger.SecurityFailure, map[string]interface{}{"reason": "invalid_format"})
 			c.JSON(http.StatusUnauthorized, Response{
 				Code:    401,
 				Message: "Invalid authorization format",
@@ -129,9 +323,10 @@ func AuthMiddleware(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
-		// 验证JWT令牌
+		// 验证JWT令牌（刷新令牌不能用于访问接口）
 		claims, err := validateJWT(ctx, tokenString)
-		if err != nil {
+		if reason := rejectReason(ctx, claims, err, tokenTypeAccess); reason != "" {
+			ctx.securityEvent(c, securityTokenRejected, logger.SecurityFailure, tokenEventFields(claims, reason))
 			c.JSON(http.StatusUnauthorized, Response{
 				Code:    401,
 				Message: "Invalid or expired token",
@@ -143,45 +338,193 @@ func AuthMiddleware(ctx *Context) gin.HandlerFunc {
 		// 将用户信息存储到上下文中
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		if claims.Timezone != "" {
+			c.Set("timezone", claims.Timezone)
+		}
+		if claims.SessionID != "" {
+			c.Set("session_id", claims.SessionID)
+			ctx.Sessions.Touch(claims.SessionID)
+		}
 
 		c.Next()
 	}
 }
 
+// StreamAuthMiddleware 流式接口认证中间件
+//
+// 浏览器的WebSocket/EventSource无法设置请求头，允许通过 ticket 查询参数传递 POST /auth/stream-ticket
+// 签发的一次性票据；仍然兼容 access_token 查询参数，访问日志中隐去这两个参数的值。
+func StreamAuthMiddleware(ctx *Context) gin.HandlerFunc {
+	auth := AuthMiddleware(ctx)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if ticket := c.Query("ticket"); ticket != "" {
+				if !redeemStreamTicket(ctx, c, ticket) {
+					c.JSON(http.StatusUnauthorized, Response{
+						Code:    401,
+						Message: "Invalid or expired ticket",
+					})
+					c.Abort()
+					return
+				}
+				c.Next()
+				return
+			}
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		auth(c)
+	}
+}
+
+// RequireRole 角色校验中间件，仅允许指定角色访问
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, Response{
+			Code:    403,
+			Message: "Permission denied",
+		})
+		c.Abort()
+	}
+}
+
+// WriteAccessMiddleware 写操作校验中间件，只读用户只能执行GET请求
+func WriteAccessMiddleware() gin.HandlerFunc {
+	requireWriter := RequireRole(models.RoleAdmin, models.RoleEditor)
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		requireWriter(c)
+	}
+}
+
+// rejectReason 返回令牌被拒绝的原因，令牌有效时返回空字符串
+func rejectReason(ctx *Context, claims *JWTClaims, err error, tokenType string) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired_token"
+	case err != nil:
+		return "invalid_token"
+	case claims.TokenType != tokenType:
+		return "wrong_token_type"
+	case ctx.Revocations.IsRevoked(claims.ID):
+		return "revoked_token"
+	}
+	return ""
+}
+
+// tokenEventFields 安全事件中的令牌信息，令牌无法解析时claims为nil
+func tokenEventFields(claims *JWTClaims, reason string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if claims != nil {
+		fields["username"] = claims.Username
+		fields["session_id"] = claims.SessionID
+		fields["token_id"] = claims.ID
+	}
+	if reason != "" {
+		fields["reason"] = reason
+	}
+	return fields
+}
+
 // validateCredentials 验证用户凭据
-func validateCredentials(ctx *Context, username, password string) bool {
-	// 简单的硬编码验证，实际应用中应该从数据库验证
-	if username != ctx.Config.Admin.Username {
-		return false
+func validateCredentials(ctx *Context, username, password string) (*models.User, bool) {
+	user, err := findUser(ctx, username)
+	if err != nil || !user.Enabled {
+		return nil, false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+// issueTokens 为用户签发访问令牌和刷新令牌并记录到会话，sessionID为空时创建新会话
+//
+// 会话已被吊销时返回 errSessionRevoked。
+func issueTokens(ctx *Context, c *gin.Context, user *models.User, sessionID string) (*LoginResponse, error) {
+	admin := ctx.adminConfig()
+	accessTTL := defaultAccessTokenTTL
+	if admin.AccessTokenTTL > 0 {
+		accessTTL = time.Duration(admin.AccessTokenTTL) * time.Second
+	}
+	refreshTTL := defaultRefreshTokenTTL
+	if admin.RefreshTokenTTL > 0 {
+		refreshTTL = time.Duration(admin.RefreshTokenTTL) * time.Second
+	}
+
+	if sessionID == "" {
+		sessionID = primitive.NewObjectID().Hex()
+	}
+	session := &Session{
+		ID:         sessionID,
+		Username:   user.Username,
+		AccessJTI:  primitive.NewObjectID().Hex(),
+		RefreshJTI: primitive.NewObjectID().Hex(),
+		Device:     c.Request.UserAgent(),
+		IP:         c.ClientIP(),
 	}
 
-	// 如果配置中的密码是明文，直接比较
-	// 在生产环境中，应该使用哈希密码
-	if password == ctx.Config.Admin.Password {
-		return true
+	token, expiresAt, err := generateJWT(ctx, user, tokenTypeAccess, session.AccessJTI, sessionID, accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, refreshExpiresAt, err := generateJWT(ctx, user, tokenTypeRefresh, session.RefreshJTI, sessionID, refreshTTL)
+	if err != nil {
+		return nil, err
 	}
 
-	// 尝试bcrypt验证（如果配置中存储的是哈希密码）
-	err := bcrypt.CompareHashAndPassword([]byte(ctx.Config.Admin.Password), []byte(password))
-	return err == nil
+	session.AccessExpiresAt = time.Unix(expiresAt, 0)
+	session.ExpiresAt = time.Unix(refreshExpiresAt, 0)
+	if err := ctx.Sessions.Record(session); err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:            token,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+		SessionID:        sessionID,
+		User: User{
+			Username: user.Username,
+			Role:     user.Role,
+			Timezone: user.Timezone,
+		},
+	}, nil
 }
 
 // generateJWT 生成JWT令牌
-func generateJWT(ctx *Context, username string) (string, int64, error) {
-	expiresAt := time.Now().Add(24 * time.Hour)
+func generateJWT(ctx *Context, user *models.User, tokenType, jti, sessionID string, ttl time.Duration) (string, int64, error) {
+	expiresAt := time.Now().Add(ttl)
 
 	claims := JWTClaims{
-		Username: username,
-		Role:     "admin",
+		Username:  user.Username,
+		Role:      user.Role,
+		TokenType: tokenType,
+		SessionID: sessionID,
+		Timezone:  user.Timezone,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "nsa-service",
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(ctx.Config.Admin.JWTSecret))
+	tokenString, err := signToken(ctx, claims)
 	if err != nil {
 		return "", 0, err
 	}
@@ -189,11 +532,35 @@ func generateJWT(ctx *Context, username string) (string, int64, error) {
 	return tokenString, expiresAt.Unix(), nil
 }
 
+// signToken 使用当前签名密钥签名，密钥有ID时写入 kid 头
+func signToken(ctx *Context, claims jwt.Claims) (string, error) {
+	key := ctx.jwtKeys()[0]
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString([]byte(key.Secret))
+}
+
+// tokenKey 按令牌的 kid 头查找验证密钥，没有 kid 头的令牌使用 jwt_secret 验证
+//
+// 轮换密钥时把新密钥加到 jwt_keys 最前面，旧密钥保留到它签发的令牌全部过期后再删除。
+func (ctx *Context) tokenKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range ctx.jwtKeys() {
+		if key.ID == kid {
+			return []byte(key.Secret), nil
+		}
+	}
+	if kid == "" {
+		return nil, fmt.Errorf("token has no key id")
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
 // validateJWT 验证JWT令牌
 func validateJWT(ctx *Context, tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(ctx.Config.Admin.JWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, ctx.tokenKey, jwt.WithValidMethods([]string{"HS256"}))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// InitBudgets 创建执行预算用量索引，设置工作流超过执行预算被自动禁用后的处理
+func InitBudgets(ctx *Context) error {
+	ctx.Executor.OnBudgetExceeded(ctx.budgetExceeded)
+	return ctx.Executor.EnsureBudgetIndexes()
+}
+
+// budgetExceeded 工作流超过执行预算被自动禁用后协调消费者和触发器，记录审计日志并通知负责人
+func (ctx *Context) budgetExceeded(workflowConfig *models.WorkflowConfig, exceeded *models.BudgetExceeded) {
+	ctx.Watcher.Trigger()
+
+	ctx.saveAudit(models.AuditLog{
+		Actor:        "system",
+		Action:       auditDisable,
+		ResourceType: "workflow",
+		ResourceID:   workflowConfig.ID.Hex(),
+		ResourceName: workflowConfig.Name,
+		Changes: map[string]models.AuditChange{
+			"enabled": {Before: true, After: false},
+		},
+		CreatedAt: exceeded.At,
+	})
+
+	budget := workflowConfig.Budget
+	if len(budget.Emails) == 0 && budget.WebhookSecret == "" {
+		return
+	}
+	subject := fmt.Sprintf("[NSA] Workflow %s disabled: daily execution budget exceeded", workflowConfig.Name)
+	text := fmt.Sprintf("Workflow %s (topic %s, channel %s, owner %s) used %g of its daily execution budget of %g on %s and was disabled at %s.\n"+
+		"Messages arriving while it is disabled are not executed. Enable the workflow to resume; enabling it resets today's usage.",
+		workflowConfig.Name, workflowConfig.Topic, workflowConfig.Channel, workflowConfig.Owner,
+		exceeded.Used, exceeded.Limit, exceeded.Day, exceeded.At.UTC().Format(time.RFC3339))
+
+	ctxNotify, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := ctx.Reports.Notify(ctxNotify, budget.Emails, budget.WebhookSecret, subject, text); err != nil {
+		ctx.Logger.Errorf("Failed to notify owners of workflow %s about exceeded execution budget: %v", workflowConfig.Name, err)
+	}
+}
+
+// resetBudget 清除工作流当天的执行预算用量
+func (ctx *Context) resetBudget(workflowID string) {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ctx.Executor.ResetBudget(ctxDB, workflowID); err != nil {
+		ctx.Logger.Errorf("Failed to reset execution budget of workflow %s: %v", workflowID, err)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"nsa/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bulkCollection 批量操作集合名称
+const bulkCollection = "bulk_operations"
+
+// BulkOperationRequest 批量实例操作请求
+type BulkOperationRequest struct {
+	Operation   string              `json:"operation"`
+	Filter      workflow.BulkFilter `json:"filter"`
+	Reason      string              `json:"reason"`
+	Concurrency int                 `json:"concurrency"`
+	// DryRun 只返回匹配的实例数，不执行
+	DryRun bool `json:"dry_run"`
+}
+
+// CreateBulkOperation 按条件批量重试、取消或处理实例，操作在后台执行，返回202和操作记录
+func CreateBulkOperation(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BulkOperationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		op := &workflow.BulkOperation{
+			Operation:   req.Operation,
+			Filter:      req.Filter,
+			Reason:      req.Reason,
+			Concurrency: req.Concurrency,
+			CreatedBy:   c.GetString("username"),
+		}
+		if err := op.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if req.DryRun {
+			count, err := ctx.Executor.CountBulkInstances(ctxDB, op)
+			if err != nil {
+				ctx.Logger.Errorf("Failed to count workflow instances: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to count workflow instances",
+				})
+				return
+			}
+			c.JSON(http.StatusOK, Response{
+				Code:    200,
+				Message: "Success",
+				Data:    map[string]int64{"matched": count},
+			})
+			return
+		}
+
+		if err := ctx.Executor.StartBulkOperation(ctxDB, op); err != nil {
+			if errors.Is(err, workflow.ErrBulkTooManyInstances) {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: err.Error(),
+				})
+				return
+			}
+			ctx.Logger.Errorf("Failed to start bulk %s: %v", op.Operation, err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to start bulk operation",
+			})
+			return
+		}
+		ctx.recordAudit(c, auditCreate, "bulk_operation", op.ID.Hex(), op.Operation, nil, op)
+
+		c.JSON(http.StatusAccepted, Response{
+			Code:    202,
+			Message: "Bulk operation started",
+			Data:    op,
+		})
+	}
+}
+
+// ListBulkOperations 获取批量操作列表，按创建时间倒序
+func ListBulkOperations(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PaginationRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid query parameters",
+			})
+			return
+		}
+		if req.Page <= 0 {
+			req.Page = 1
+		}
+		if req.PageSize <= 0 {
+			req.PageSize = 20
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(bulkCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if operation := c.Query("operation"); operation != "" {
+			filter["operation"] = operation
+		}
+
+		total, err := collection.CountDocuments(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to count bulk operations: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to count bulk operations",
+			})
+			return
+		}
+
+		opts := options.Find()
+		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
+		opts.SetLimit(int64(req.PageSize))
+		opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+		opts.SetProjection(bson.M{"errors": 0})
+		cursor, err := collection.Find(ctxDB, filter, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find bulk operations: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find bulk operations",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		operations := []workflow.BulkOperation{}
+		if err := cursor.All(ctxDB, &operations); err != nil {
+			ctx.Logger.Errorf("Failed to decode bulk operations: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode bulk operations",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: PaginationResponse{
+				Total:    total,
+				Page:     req.Page,
+				PageSize: req.PageSize,
+				Data:     operations,
+			},
+		})
+	}
+}
+
+// GetBulkOperation 获取批量操作的进度和失败明细
+func GetBulkOperation(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid bulk operation ID",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(bulkCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var op workflow.BulkOperation
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&op); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Bulk operation not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    op,
+		})
+	}
+}
@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+	"nsa/internal/trigger"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+// bundleVersion 工作流导出格式版本
+const bundleVersion = 1
+
+// maxBundleSize 导入文件大小上限
+const maxBundleSize = 4 << 20
+
+// WorkflowBundle 可移植的工作流导出包
+//
+// 不包含ID、时间戳和任何凭据，数据源和密钥只记录名称，用于在不同环境间迁移工作流。
+type WorkflowBundle struct {
+	Version     int               `json:"version"`
+	ExportedAt  time.Time         `json:"exported_at"`
+	Workflow    BundleWorkflow    `json:"workflow"`
+	DataSources []BundleReference `json:"datasources,omitempty"`
+	Secrets     []BundleReference `json:"secrets,omitempty"`
+}
+
+// BundleWorkflow 导出包中的工作流定义
+type BundleWorkflow struct {
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description"`
+	Topic           string                  `json:"topic"`
+	Channel         string                  `json:"channel"`
+	Enabled         bool                    `json:"enabled"`
+	DAG             models.DAGConfig        `json:"dag"`
+	Triggers        []models.TriggerConfig  `json:"triggers,omitempty"`
+	ConcurrencyKey  string                  `json:"concurrency_key,omitempty"`
+	OnRestart       string                  `json:"on_restart,omitempty"`
+	RateLimit       *models.RateLimit       `json:"rate_limit,omitempty"`
+	ExecutionWindow *models.ExecutionWindow `json:"execution_window,omitempty"`
+	Requeue         *models.RequeuePolicy   `json:"requeue,omitempty"`
+	Budget          *models.ExecutionBudget `json:"budget,omitempty"`
+}
+
+// BundleReference 工作流引用的数据源或密钥（占位符，目标环境需自行配置）
+type BundleReference struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"` // 数据源类型
+}
+
+// ImportResult 工作流导入结果
+type ImportResult struct {
+	Workflow           models.WorkflowConfig `json:"workflow"`
+	Overwritten        bool                  `json:"overwritten"`
+	MissingDataSources []string              `json:"missing_datasources"`
+	MissingSecrets     []string              `json:"missing_secrets"`
+}
+
+// ExportWorkflow 导出工作流
+//
+// 查询参数 format 为 yaml 或 json（默认），include_dependencies=true 时附带引用的数据源和密钥名称。
+func ExportWorkflow(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid workflow ID",
+			})
+			return
+		}
+
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "yaml" {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Format must be json or yaml",
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var workflow models.WorkflowConfig
+		if err := ctx.MongoClient.GetCollection().FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&workflow); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Workflow not found",
+			})
+			return
+		}
+
+		bundle := WorkflowBundle{
+			Version:    bundleVersion,
+			ExportedAt: time.Now().UTC(),
+			Workflow: BundleWorkflow{
+				Name:            workflow.Name,
+				Description:     workflow.Description,
+				Topic:           workflow.Topic,
+				Channel:         workflow.Channel,
+				Enabled:         workflow.Enabled,
+				DAG:             workflow.DAG,
+				Triggers:        workflow.Triggers,
+				ConcurrencyKey:  workflow.ConcurrencyKey,
+				OnRestart:       workflow.OnRestart,
+				RateLimit:       workflow.RateLimit,
+				ExecutionWindow: workflow.ExecutionWindow,
+				Requeue:         workflow.Requeue,
+				Budget:          workflow.Budget,
+			},
+		}
+
+		if c.Query("include_dependencies") == "true" {
+			datasourceNames, secretNames := workflowReferences(&workflow)
+
+			types, err := ctx.datasourceTypes(ctxDB, datasourceNames)
+			if err != nil {
+				ctx.Logger.Errorf("Failed to find datasources: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to find datasources",
+				})
+				return
+			}
+			for _, name := range datasourceNames {
+				bundle.DataSources = append(bundle.DataSources, BundleReference{Name: name, Type: types[name]})
+			}
+			for _, name := range secretNames {
+				bundle.Secrets = append(bundle.Secrets, BundleReference{Name: name})
+			}
+		}
+
+		data, err := encodeBundle(&bundle, format)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to encode workflow bundle: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to encode workflow bundle",
+			})
+			return
+		}
+
+		contentType := "application/json"
+		if format == "yaml" {
+			contentType = "application/yaml"
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, bundleFileName(workflow.Name), format))
+		c.Data(http.StatusOK, contentType, data)
+	}
+}
+
+// ImportWorkflow 导入工作流
+//
+// 请求体为导出的YAML或JSON。topic和channel已存在时返回409，overwrite=true 时覆盖已有工作流。
+// 目标环境缺少的数据源和密钥不会阻止导入，会在结果中列出。
+func ImportWorkflow(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBundleSize+1))
+		if err != nil || len(data) > maxBundleSize {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid or oversized bundle",
+			})
+			return
+		}
+
+		bundle, err := decodeBundle(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("Invalid bundle format: %v", err),
+			})
+			return
+		}
+		if bundle.Version != bundleVersion {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("Unsupported bundle version: %d", bundle.Version),
+			})
+			return
+		}
+
+		workflow := models.WorkflowConfig{
+			Name:            bundle.Workflow.Name,
+			Description:     bundle.Workflow.Description,
+			Topic:           bundle.Workflow.Topic,
+			Channel:         bundle.Workflow.Channel,
+			Enabled:         bundle.Workflow.Enabled,
+			DAG:             bundle.Workflow.DAG,
+			Triggers:        bundle.Workflow.Triggers,
+			ConcurrencyKey:  bundle.Workflow.ConcurrencyKey,
+			OnRestart:       bundle.Workflow.OnRestart,
+			RateLimit:       bundle.Workflow.RateLimit,
+			ExecutionWindow: bundle.Workflow.ExecutionWindow,
+			Requeue:         bundle.Workflow.Requeue,
+			Budget:          bundle.Workflow.Budget,
+		}
+		if workflow.Name == "" || workflow.Topic == "" || workflow.Channel == "" {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Name, topic, and channel are required",
+			})
+			return
+		}
+		if err := trigger.Validate(workflow.Triggers); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateOnRestart(workflow.OnRestart); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateRateLimit(workflow.RateLimit); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateExecutionWindow(workflow.ExecutionWindow); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateRequeuePolicy(workflow.Requeue); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := ctx.validateExecutionBudget(workflow.Budget); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateTaskConditions(workflow.DAG.Tasks); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetCollection()
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// 检查依赖
+		result := ImportResult{MissingDataSources: []string{}, MissingSecrets: []string{}}
+		datasourceNames, secretNames := workflowReferences(&workflow)
+		types, err := ctx.datasourceTypes(ctxDB, datasourceNames)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find datasources: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find datasources",
+			})
+			return
+		}
+		for _, name := range datasourceNames {
+			if _, ok := types[name]; !ok {
+				result.MissingDataSources = append(result.MissingDataSources, name)
+			}
+		}
+		for _, name := range secretNames {
+			count, err := ctx.Secrets.Collection().CountDocuments(ctxDB, bson.M{"name": name})
+			if err != nil {
+				ctx.Logger.Errorf("Failed to check secret: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to check secrets",
+				})
+				return
+			}
+			if count == 0 {
+				result.MissingSecrets = append(result.MissingSecrets, name)
+			}
+		}
+
+		// 检查topic和channel组合是否已存在
+		var existing models.WorkflowConfig
+		err = collection.FindOne(ctxDB, bson.M{"topic": workflow.Topic, "channel": workflow.Channel}).Decode(&existing)
+		exists := err == nil
+		if exists && c.Query("overwrite") != "true" {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Workflow with same topic and channel already exists",
+				Data:    gin.H{"id": existing.ID.Hex()},
+			})
+			return
+		}
+
+		project := ""
+		if exists {
+			project = existing.Project
+		}
+		if err := ctx.Executor.CheckActions(project, workflow.DAG.Tasks); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		now := time.Now()
+		workflow.UpdatedAt = now
+		if exists {
+			workflow.ID = existing.ID
+			workflow.CreatedAt = existing.CreatedAt
+			workflow.Owner = existing.Owner
+			workflow.Project = existing.Project
+			workflow.Unmask = existing.Unmask
+			if _, err := collection.ReplaceOne(ctxDB, bson.M{"_id": existing.ID}, workflow); err != nil {
+				ctx.Logger.Errorf("Failed to import workflow: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to import workflow",
+				})
+				return
+			}
+			ctx.recordAudit(c, auditUpdate, "workflow", workflow.ID.Hex(), workflow.Name, existing, workflow)
+		} else {
+			workflow.CreatedAt = now
+			workflow.Owner = c.GetString("username")
+			inserted, err := collection.InsertOne(ctxDB, workflow)
+			if err != nil {
+				ctx.Logger.Errorf("Failed to import workflow: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to import workflow",
+				})
+				return
+			}
+			workflow.ID = inserted.InsertedID.(primitive.ObjectID)
+			ctx.recordAudit(c, auditCreate, "workflow", workflow.ID.Hex(), workflow.Name, nil, workflow)
+		}
+
+		if workflow.Enabled || (exists && existing.Enabled) {
+			ctx.Watcher.Trigger()
+		}
+
+		result.Workflow = workflow
+		result.Overwritten = exists
+
+		ctx.Logger.Infof("Workflow imported: %s", workflow.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Workflow imported successfully",
+			Data:    result,
+		})
+	}
+}
+
+// datasourceTypes 查询数据源或别名对应的类型，别名按默认映射的数据源计算，不存在的名称不在结果中
+func (ctx *Context) datasourceTypes(ctxDB context.Context, names []string) (map[string]string, error) {
+	types, err := ctx.physicalDataSourceTypes(ctxDB, names)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := ctx.MongoClient.GetDatabase().Collection(aliasCollection).Find(ctxDB, bson.M{"name": bson.M{"$in": names}})
+	if err != nil {
+		return nil, err
+	}
+	var aliases []models.DataSourceAlias
+	if err := cursor.All(ctxDB, &aliases); err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, alias := range aliases {
+		targets = append(targets, alias.DataSource)
+	}
+	targetTypes, err := ctx.physicalDataSourceTypes(ctxDB, targets)
+	if err != nil {
+		return nil, err
+	}
+	for _, alias := range aliases {
+		if _, exists := types[alias.Name]; !exists {
+			if dsType, ok := targetTypes[alias.DataSource]; ok {
+				types[alias.Name] = dsType
+			}
+		}
+	}
+	return types, nil
+}
+
+// physicalDataSourceTypes 查询数据源名称对应的类型，不解析别名，不存在的名称不在结果中
+func (ctx *Context) physicalDataSourceTypes(ctxDB context.Context, names []string) (map[string]string, error) {
+	types := make(map[string]string, len(names))
+	if len(names) == 0 {
+		return types, nil
+	}
+
+	cursor, err := ctx.MongoClient.GetDatabase().Collection("datasources").Find(ctxDB, bson.M{"name": bson.M{"$in": names}})
+	if err != nil {
+		return nil, err
+	}
+	var datasources []models.DataSource
+	if err := cursor.All(ctxDB, &datasources); err != nil {
+		return nil, err
+	}
+	for _, ds := range datasources {
+		types[ds.Name] = ds.Type
+	}
+	return types, nil
+}
+
+// workflowReferences 收集任务和触发器参数中引用的数据源（datasource）和密钥（*_secret）名称
+func workflowReferences(workflow *models.WorkflowConfig) ([]string, []string) {
+	datasources := make(map[string]bool)
+	secrets := make(map[string]bool)
+
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, item := range v {
+				if name, ok := item.(string); ok && name != "" && !strings.Contains(name, "{{") {
+					if key == "datasource" {
+						datasources[name] = true
+					} else if strings.HasSuffix(key, "_secret") {
+						secrets[name] = true
+					}
+				}
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	for _, task := range workflow.DAG.Tasks {
+		walk(task.Params)
+	}
+	for _, trigger := range workflow.Triggers {
+		walk(trigger.Params)
+	}
+
+	return sortedKeys(datasources), sortedKeys(secrets)
+}
+
+// sortedKeys 返回排序后的集合元素
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeBundle 序列化导出包
+//
+// YAML先经过JSON转换，使字段名与API中的JSON字段保持一致。
+func encodeBundle(bundle *WorkflowBundle, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil || format == "json" {
+		return data, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// decodeBundle 解析YAML或JSON格式的导出包（JSON是YAML的子集）
+func decodeBundle(data []byte) (*WorkflowBundle, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle WorkflowBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// bundleFileName 由工作流名称生成导出文件名
+func bundleFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" {
+		return "workflow"
+	}
+	return name
+}
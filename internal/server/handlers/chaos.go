@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"nsa/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chaosDisabled 未启用故障注入时返回404
+func chaosDisabled(c *gin.Context, err error) bool {
+	if !errors.Is(err, workflow.ErrChaosDisabled) {
+		return false
+	}
+	c.JSON(http.StatusNotFound, Response{
+		Code:    404,
+		Message: "Fault injection is disabled (chaos.enabled)",
+	})
+	return true
+}
+
+// ListFaults 列出当前节点的故障注入规则
+func ListFaults(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		faults, err := ctx.Executor.Faults()
+		if chaosDisabled(c, err) {
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    faults,
+		})
+	}
+}
+
+// AddFault 添加故障注入规则
+func AddFault(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var fault workflow.Fault
+		if err := c.ShouldBindJSON(&fault); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		fault, err := ctx.Executor.AddFault(fault)
+		if chaosDisabled(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditCreate, "fault", fault.ID, fault.Action, nil, fault)
+
+		c.JSON(http.StatusCreated, Response{
+			Code:    201,
+			Message: "Fault injection rule added",
+			Data:    fault,
+		})
+	}
+}
+
+// DeleteFault 删除故障注入规则
+func DeleteFault(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		removed, err := ctx.Executor.RemoveFault(id)
+		if chaosDisabled(c, err) {
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Fault injection rule not found",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "fault", id, "", nil, nil)
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Fault injection rule deleted",
+		})
+	}
+}
+
+// ClearFaults 删除所有故障注入规则
+func ClearFaults(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if chaosDisabled(c, ctx.Executor.ClearFaults()) {
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "fault", "", "all", nil, nil)
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Fault injection rules cleared",
+		})
+	}
+}
@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"runtime"
+	"strings"
 	"time"
 
+	"nsa/internal/cluster"
 	"nsa/internal/models"
+	"nsa/internal/nsq"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -34,11 +39,17 @@ func HealthCheck(ctx *Context) gin.HandlerFunc {
 			"status":    "healthy",
 			"timestamp": time.Now(),
 			"version":   "1.0.0",
+			// 部署角色的状态，standby 部署在提升前没有消费者
+			"deployment": ctx.Role.Status().State,
 			"services": map[string]interface{}{
 				"mongodb": mongoStatus,
 				"nsq": map[string]interface{}{
 					"consumers_count": len(nsqConsumers),
 					"consumers":       nsqConsumers,
+					// 需要但启动失败的消费者，下一次重新加载时重试
+					"failed_consumers": ctx.NSQManager.FailedConsumers(),
+					// 通过接口暂停的消费者
+					"paused_consumers": ctx.NSQManager.PausedConsumers(),
 				},
 			},
 		}
@@ -76,7 +87,7 @@ func GetSystemInfo(ctx *Context) gin.HandlerFunc {
 				"sys":         bToMb(m.Sys),
 				"gc_runs":     m.NumGC,
 			},
-			"uptime": time.Since(startTime).String(),
+			"uptime": time.Since(startTime).Milliseconds(), // 毫秒
 		}
 
 		c.JSON(http.StatusOK, Response{
@@ -108,11 +119,31 @@ func GetMetrics(ctx *Context) gin.HandlerFunc {
 		}
 
 		metrics := map[string]interface{}{
-			"timestamp":     time.Now(),
-			"nsq_consumers": nsqStats,
-			"workflows":     workflowStats,
-			"executions":    executionStats,
-			"data_sources":  len(ctx.DataSourceMgr.ListDataSources()),
+			"timestamp":          time.Now(),
+			"nsq_consumers":      nsqStats,
+			"workflows":          workflowStats,
+			"executions":         executionStats,
+			"data_sources":       len(ctx.DataSourceMgr.ListDataSources()),
+			"data_source_health": ctx.DataSourceMgr.HealthAll(),
+			"keyed_queues":       ctx.Executor.QueueStats(),
+			"delayed":            ctx.Executor.DelayedInstances(),
+			"window_skipped":     ctx.Executor.WindowSkipped(),
+			"budget_skipped":     ctx.Executor.BudgetSkipped(),
+			"consumer_reconcile": ctx.Watcher.Status(),
+			"circuit_breakers":   ctx.Executor.CircuitBreakers(),
+			"node":               ctx.Node.ID(),
+		}
+
+		if ctx.WorkQueue != nil {
+			ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			queueStats, err := ctx.WorkQueue.Stats(ctxDB)
+			cancel()
+			if err != nil {
+				ctx.Logger.Errorf("Failed to get work queue stats: %v", err)
+				metrics["work_queue"] = map[string]interface{}{"error": "Failed to get stats"}
+			} else {
+				metrics["work_queue"] = queueStats
+			}
 		}
 
 		c.JSON(http.StatusOK, Response{
@@ -123,6 +154,194 @@ func GetMetrics(ctx *Context) gin.HandlerFunc {
 	}
 }
 
+// ListNodes 列出集群节点及其心跳状态
+func ListNodes(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		nodes, err := ctx.Node.Nodes(ctxDB)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to list cluster nodes: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to list cluster nodes",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: map[string]interface{}{
+				"enabled":             ctx.Node.Enabled(),
+				"current":             ctx.Node.ID(),
+				"nodes":               nodes,
+				"partition_consumers": ctx.Node.Enabled() && ctx.Config.Cluster.PartitionConsumers,
+				"consumers":           ctx.NSQManager.ListConsumers(),
+			},
+		})
+	}
+}
+
+// GetClusterStatus 获取部署角色（active/standby）、状态机和集群节点
+func GetClusterStatus(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		nodes, err := ctx.Node.Nodes(ctxDB)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to list cluster nodes: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to list cluster nodes",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: map[string]interface{}{
+				"deployment":  ctx.Role.Status(),
+				"transitions": cluster.Transitions,
+				"enabled":     ctx.Node.Enabled(),
+				"current":     ctx.Node.ID(),
+				"nodes":       nodes,
+				"consumers":   ctx.NSQManager.ListConsumers(),
+			},
+		})
+	}
+}
+
+// PromoteDeployment 将 standby 部署提升为 active，启动NSQ消费者、触发器和定时任务
+//
+// 启用集群时，如果还有配置为 active 的存活节点（原主地区仍在写入心跳），除非指定 force=true 否则拒绝提升，避免两个地区同时消费消息；
+// 同一地区已提升的 standby 节点不影响提升。
+func PromoteDeployment(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ctx.Node.Enabled() && c.Query("force") != "true" {
+			ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			nodes, err := ctx.Node.Nodes(ctxDB)
+			cancel()
+			if err != nil {
+				ctx.Logger.Errorf("Failed to list cluster nodes: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to list cluster nodes",
+				})
+				return
+			}
+			var alive []string
+			for _, node := range nodes {
+				if node.Alive && node.Role != cluster.StateStandby && node.ID != ctx.Node.ID() {
+					alive = append(alive, node.ID)
+				}
+			}
+			if len(alive) > 0 {
+				c.JSON(http.StatusConflict, Response{
+					Code:    409,
+					Message: fmt.Sprintf("Nodes %s are still alive, stop them or use force=true", strings.Join(alive, ", ")),
+					Data:    map[string]interface{}{"alive": alive},
+				})
+				return
+			}
+		}
+
+		before := ctx.Role.Status()
+		username := c.GetString("username")
+		if err := ctx.Role.Promote(username); err != nil {
+			if errors.Is(err, cluster.ErrNotStandby) {
+				c.JSON(http.StatusConflict, Response{
+					Code:    409,
+					Message: err.Error(),
+				})
+				return
+			}
+			ctx.Logger.Errorf("Failed to promote deployment: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: fmt.Sprintf("Failed to promote deployment: %v", err),
+			})
+			return
+		}
+
+		after := ctx.Role.Status()
+		ctx.recordAudit(c, auditPromote, "deployment", ctx.Node.ID(), ctx.Node.ID(), before, after)
+		ctx.Logger.Infof("Deployment promoted to active by %s", username)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Deployment promoted to active",
+			Data:    after,
+		})
+	}
+}
+
+// RunRetentionCleanup 按保留策略手动清理执行日志和工作流实例
+func RunRetentionCleanup(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dryRun := c.Query("dry_run") == "true"
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		result, err := ctx.Purger.Purge(ctxDB, dryRun)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to run retention cleanup: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to run retention cleanup",
+			})
+			return
+		}
+
+		if !dryRun {
+			username, _ := c.Get("username")
+			ctx.Logger.Infof("Retention cleanup triggered by %v", username)
+		}
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    result,
+		})
+	}
+}
+
+// ReencryptMessages 用当前加密密钥重新加密明文存储或用旧密钥加密的消息
+func ReencryptMessages(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ctx.Keyring == nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Encryption is not enabled, configure encryption.keys",
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+
+		result, err := ctx.Keyring.Reencrypt(ctxDB, ctx.MongoClient)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to re-encrypt messages: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to re-encrypt messages",
+			})
+			return
+		}
+
+		username, _ := c.Get("username")
+		ctx.Logger.Infof("Message re-encryption with key %s triggered by %v", result.KeyID, username)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    result,
+		})
+	}
+}
+
 // ListExecutionLogs 获取执行日志列表
 func ListExecutionLogs(ctx *Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -143,23 +362,39 @@ func ListExecutionLogs(ctx *Context) gin.HandlerFunc {
 			req.PageSize = 50
 		}
 
+		query, ok := ctx.listQueryFor(c, "logs")
+		if !ok {
+			return
+		}
+		since, err := query.since()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
 		collection := ctx.MongoClient.GetDatabase().Collection("execution_logs")
 		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
 		// 构建查询条件
 		filter := bson.M{}
-		if workflowID := c.Query("workflow_id"); workflowID != "" {
+		if workflowID := query.get("workflow_id"); workflowID != "" {
 			if objectID, err := primitive.ObjectIDFromHex(workflowID); err == nil {
 				filter["workflow_id"] = objectID
 			}
 		}
-		if instanceID := c.Query("instance_id"); instanceID != "" {
+		if instanceID := query.get("instance_id"); instanceID != "" {
 			filter["instance_id"] = instanceID
 		}
-		if status := c.Query("status"); status != "" {
+		if status := query.get("status"); status != "" {
 			filter["status"] = status
 		}
+		if !since.IsZero() {
+			filter["created_at"] = bson.M{"$gte": since}
+		}
 
 		// 获取总数
 		total, err := collection.CountDocuments(ctxDB, filter)
@@ -176,7 +411,7 @@ func ListExecutionLogs(ctx *Context) gin.HandlerFunc {
 		opts := options.Find()
 		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
 		opts.SetLimit(int64(req.PageSize))
-		opts.SetSort(bson.D{{"created_at", -1}})
+		opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
 
 		cursor, err := collection.Find(ctxDB, filter, opts)
 		if err != nil {
@@ -199,6 +434,10 @@ func ListExecutionLogs(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
+		if !ctx.canViewExecutionData(c.GetString("role")) {
+			redactExecutionLogs(logs)
+		}
+
 		response := PaginationResponse{
 			Total:    total,
 			Page:     req.Page,
@@ -242,6 +481,10 @@ func GetExecutionLog(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
+		if !ctx.canViewExecutionData(c.GetString("role")) {
+			log = redactExecutionLog(log)
+		}
+
 		c.JSON(http.StatusOK, Response{
 			Code:    200,
 			Message: "Success",
@@ -263,11 +506,30 @@ func ListNSQConsumers(ctx *Context) gin.HandlerFunc {
 	}
 }
 
-// GetNSQStats 获取NSQ统计信息
+// GetNSQStats 获取NSQ统计信息：客户端计数器，以及从nsqd查询的channel积压（nsqd）；server=false 时只返回客户端计数器
 func GetNSQStats(ctx *Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		stats := ctx.NSQManager.GetConsumerStats()
 
+		if c.Query("server") != "false" {
+			ctxStats, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+			server := ctx.NSQManager.ServerStats(ctxStats)
+			cancel()
+			for addr, err := range server.Errors {
+				ctx.Logger.Warnf("Failed to query NSQ stats from %s: %v", addr, err)
+			}
+			for key, entry := range stats {
+				consumer, ok := entry.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				consumer["nsqd"] = server.Channels[key]
+				if len(server.Errors) > 0 {
+					consumer["nsqd_errors"] = server.Errors
+				}
+			}
+		}
+
 		c.JSON(http.StatusOK, Response{
 			Code:    200,
 			Message: "Success",
@@ -276,6 +538,63 @@ func GetNSQStats(ctx *Context) gin.HandlerFunc {
 	}
 }
 
+// PauseNSQConsumer 暂停当前节点的NSQ消费者，停止接收新消息但不修改工作流配置
+func PauseNSQConsumer(ctx *Context) gin.HandlerFunc {
+	return ctx.setNSQConsumerPaused(true)
+}
+
+// ResumeNSQConsumer 恢复当前节点暂停的NSQ消费者
+func ResumeNSQConsumer(ctx *Context) gin.HandlerFunc {
+	return ctx.setNSQConsumerPaused(false)
+}
+
+// setNSQConsumerPaused 修改消费者的暂停状态，状态变化时记录审计日志
+func (ctx *Context) setNSQConsumerPaused(paused bool) gin.HandlerFunc {
+	action, message := auditResume, "NSQ consumer resumed"
+	if paused {
+		action, message = auditPause, "NSQ consumer paused"
+	}
+	return func(c *gin.Context) {
+		topic, channel := c.Param("topic"), c.Param("channel")
+		key := topic + ":" + channel
+
+		change := ctx.NSQManager.ResumeConsumer
+		if paused {
+			change = ctx.NSQManager.PauseConsumer
+		}
+		was, err := change(topic, channel)
+		if errors.Is(err, nsq.ErrConsumerNotFound) {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: fmt.Sprintf("NSQ consumer %s is not running on this node", key),
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to change NSQ consumer %s: %v", key, err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to change NSQ consumer",
+			})
+			return
+		}
+
+		if was != paused {
+			ctx.recordAudit(c, action, "nsq_consumer", key, key,
+				map[string]interface{}{"paused": was}, map[string]interface{}{"paused": paused})
+		}
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: message,
+			Data: map[string]interface{}{
+				"consumer": key,
+				"paused":   paused,
+				"node":     ctx.Node.ID(),
+			},
+		})
+	}
+}
+
 // ReloadNSQConsumers 重新加载NSQ消费者
 func ReloadNSQConsumers(ctx *Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -305,8 +624,29 @@ func ReloadNSQConsumers(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
+		// 预览模式只计算消费者的变化
+		if c.Query("dry_run") == "true" {
+			c.JSON(http.StatusOK, Response{
+				Code:    200,
+				Message: "Dry run, no consumers changed",
+				Data:    ctx.NSQManager.PlanReload(workflows),
+			})
+			return
+		}
+
+		// standby 部署在提升前不启动消费者
+		if !ctx.Role.Active() {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Deployment is in standby, promote it before loading NSQ consumers",
+			})
+			return
+		}
+
 		// 重新加载消费者
-		if err := ctx.NSQManager.ReloadConsumers(workflows); err != nil {
+		ctx.Executor.InvalidateWorkflowConfigs()
+		diff, err := ctx.NSQManager.ReloadConsumers(workflows)
+		if err != nil {
 			ctx.Logger.Errorf("Failed to reload NSQ consumers: %v", err)
 			c.JSON(http.StatusInternalServerError, Response{
 				Code:    500,
@@ -315,10 +655,17 @@ func ReloadNSQConsumers(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
-		ctx.Logger.Info("NSQ consumers reloaded successfully")
+		ctx.Triggers.Reload(workflows)
+
+		message := "NSQ consumers reloaded successfully"
+		if len(diff.Errors) > 0 {
+			message = "NSQ consumers reloaded with errors"
+		}
+		ctx.Logger.Info(message)
 		c.JSON(http.StatusOK, Response{
 			Code:    200,
-			Message: "NSQ consumers reloaded successfully",
+			Message: message,
+			Data:    diff,
 		})
 	}
 }
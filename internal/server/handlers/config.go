@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"nsa/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigReloadResult 配置重新加载结果
+type ConfigReloadResult struct {
+	Applied         []string `json:"applied"`          // 已生效的配置项
+	RestartRequired []string `json:"restart_required"` // 已修改但需要重启才能生效的配置项
+}
+
+// reloadableConfig 可在运行时重新加载的配置项，用于审计记录
+type reloadableConfig struct {
+	LogLevel         string             `json:"log_level"`
+	LookupdAddresses []string           `json:"lookupd_addresses"`
+	Admin            config.AdminConfig `json:"admin"`
+}
+
+// ReloadConfigFile 重新读取配置文件并应用可热更新的配置项
+//
+// 支持热更新日志级别、nsqlookupd地址、管理配置（JWT密钥及密钥列表、令牌有效期）和事件接入令牌；
+// 其他配置项的修改只会在结果中提示需要重启。新配置校验失败时保持原配置不变。
+func (ctx *Context) ReloadConfigFile() (*ConfigReloadResult, error) {
+	next, err := config.Load(ctx.Config.File())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.configMu.Lock()
+	defer ctx.configMu.Unlock()
+
+	current := ctx.Config
+	result := &ConfigReloadResult{Applied: []string{}, RestartRequired: []string{}}
+
+	if next.Logging.Level != current.Logging.Level {
+		if err := ctx.Logger.SetLevel(next.Logging.Level); err != nil {
+			return nil, fmt.Errorf("failed to set log level: %v", err)
+		}
+		current.Logging.Level = next.Logging.Level
+		result.Applied = append(result.Applied, "logging.level")
+	}
+
+	if ctx.NSQManager.SetLookupdAddresses(next.NSQ.LookupdAddresses) {
+		current.NSQ.LookupdAddresses = next.NSQ.LookupdAddresses
+		result.Applied = append(result.Applied, "nsq.lookupd_addresses")
+	}
+
+	admin := &current.Admin
+	if next.Admin.JWTSecret != admin.JWTSecret {
+		admin.JWTSecret = next.Admin.JWTSecret
+		result.Applied = append(result.Applied, "admin.jwt_secret")
+	}
+	if !reflect.DeepEqual(next.Admin.JWTKeys, admin.JWTKeys) {
+		admin.JWTKeys = next.Admin.JWTKeys
+		result.Applied = append(result.Applied, "admin.jwt_keys")
+	}
+	if !reflect.DeepEqual(next.Admin.ExecutionDataRoles, admin.ExecutionDataRoles) {
+		admin.ExecutionDataRoles = next.Admin.ExecutionDataRoles
+		result.Applied = append(result.Applied, "admin.execution_data_roles")
+	}
+	if next.Admin.AccessTokenTTL != admin.AccessTokenTTL {
+		admin.AccessTokenTTL = next.Admin.AccessTokenTTL
+		result.Applied = append(result.Applied, "admin.access_token_ttl")
+	}
+	if next.Admin.RefreshTokenTTL != admin.RefreshTokenTTL {
+		admin.RefreshTokenTTL = next.Admin.RefreshTokenTTL
+		result.Applied = append(result.Applied, "admin.refresh_token_ttl")
+	}
+
+	if next.Ingest.Token != current.Ingest.Token {
+		current.Ingest.Token = next.Ingest.Token
+		result.Applied = append(result.Applied, "ingest.token")
+	}
+
+	// 以下配置在启动时使用，修改后需要重启
+	restartFields := []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"server", current.Server, next.Server},
+		{"mongodb", current.MongoDB, next.MongoDB},
+		{"logging.local_logs", current.Logging.LocalLogs, next.Logging.LocalLogs},
+		{"logging.graylog", current.Logging.Graylog, next.Logging.Graylog},
+		{"logging.security", current.Logging.Security, next.Logging.Security},
+		{"admin.gui_enabled", current.Admin.GUIEnabled, next.Admin.GUIEnabled},
+		{"admin.oidc", current.Admin.OIDC, next.Admin.OIDC},
+		{"nsq.nsqd_addresses", current.NSQ.NSQDAddresses, next.NSQ.NSQDAddresses},
+		{"nsq.direct_connect", current.NSQ.DirectConnect, next.NSQ.DirectConnect},
+		{"nsq.auth_secret", current.NSQ.AuthSecret, next.NSQ.AuthSecret},
+		{"nsq.compression", current.NSQ.Compression, next.NSQ.Compression},
+		{"nsq.deflate_level", current.NSQ.DeflateLevel, next.NSQ.DeflateLevel},
+		{"nsq.tls", current.NSQ.TLS, next.NSQ.TLS},
+		{"nsq.concurrency", current.NSQ.Concurrency, next.NSQ.Concurrency},
+		{"nsq.queue_timeout", current.NSQ.QueueTimeout, next.NSQ.QueueTimeout},
+		{"nsq.max_message_size", current.NSQ.MaxMessageSize, next.NSQ.MaxMessageSize},
+		{"nsq.dead_letter_topic", current.NSQ.DeadLetterTopic, next.NSQ.DeadLetterTopic},
+		{"nsq.max_payload_size", current.NSQ.MaxPayloadSize, next.NSQ.MaxPayloadSize},
+		{"nsq.reconcile_interval", current.NSQ.ReconcileInterval, next.NSQ.ReconcileInterval},
+		{"retention", current.Retention, next.Retention},
+		{"files", current.Files, next.Files},
+		{"command", current.Command, next.Command},
+		{"actions", current.Actions, next.Actions},
+		{"snmp_trap", current.SNMPTrap, next.SNMPTrap},
+		{"syslog", current.Syslog, next.Syslog},
+		{"cluster", current.Cluster, next.Cluster},
+		{"chaos", current.Chaos, next.Chaos},
+		{"circuit_breaker", current.CircuitBreaker, next.CircuitBreaker},
+		{"work_queue", current.WorkQueue, next.WorkQueue},
+		{"datasource_health", current.DataSourceHealth, next.DataSourceHealth},
+		{"http_client", current.HTTPClient, next.HTTPClient},
+		{"instance_export", current.InstanceExport, next.InstanceExport},
+		{"js", current.JS, next.JS},
+		{"reports", current.Reports, next.Reports},
+		{"encryption.keys", current.Encryption.Keys, next.Encryption.Keys},
+	}
+	for _, field := range restartFields {
+		if !reflect.DeepEqual(field.before, field.after) {
+			result.RestartRequired = append(result.RestartRequired, field.name)
+		}
+	}
+
+	ctx.Logger.Infof("Configuration reloaded, applied: [%s], restart required: [%s]",
+		strings.Join(result.Applied, ", "), strings.Join(result.RestartRequired, ", "))
+	return result, nil
+}
+
+// snapshotReloadable 返回当前可热更新配置项的快照
+func (ctx *Context) snapshotReloadable() reloadableConfig {
+	ctx.configMu.RLock()
+	defer ctx.configMu.RUnlock()
+	return reloadableConfig{
+		LogLevel:         ctx.Config.Logging.Level,
+		LookupdAddresses: append([]string{}, ctx.Config.NSQ.LookupdAddresses...),
+		Admin:            ctx.Config.Admin,
+	}
+}
+
+// ReloadConfig 重新加载配置文件
+func ReloadConfig(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		before := ctx.snapshotReloadable()
+
+		result, err := ctx.ReloadConfigFile()
+		if err != nil {
+			ctx.Logger.Errorf("Failed to reload configuration: %v", err)
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: fmt.Sprintf("Failed to reload configuration: %v", err),
+			})
+			return
+		}
+
+		if len(result.Applied) > 0 {
+			ctx.recordAudit(c, auditReload, "config", "", ctx.Config.File(), before, ctx.snapshotReloadable())
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Configuration reloaded successfully",
+			Data:    result,
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListConnectors 获取已注册的连接器及其操作定义
+func ListConnectors(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    ctx.Executor.Connectors().List(),
+		})
+	}
+}
@@ -1,22 +1,69 @@
 package handlers
 
 import (
+	"sync"
+
+	"nsa/internal/alert"
+	"nsa/internal/cluster"
 	"nsa/internal/config"
 	"nsa/internal/datasource"
+	"nsa/internal/encryption"
 	"nsa/internal/logger"
 	"nsa/internal/mongodb"
 	"nsa/internal/nsq"
+	"nsa/internal/reconcile"
+	"nsa/internal/report"
+	"nsa/internal/retention"
+	"nsa/internal/secrets"
+	"nsa/internal/trigger"
 	"nsa/internal/workflow"
+	"nsa/internal/workqueue"
 )
 
 // Context 处理器上下文
 type Context struct {
 	Config        *config.Config
 	Logger        logger.Logger
+	Security      *logger.SecurityLogger
 	MongoClient   *mongodb.Client
 	NSQManager    *nsq.Manager
 	DataSourceMgr *datasource.Manager
+	Secrets       *secrets.Store
 	Executor      *workflow.Executor
+	Revocations   *RevocationList
+	Sessions      *SessionStore
+	StreamTickets *TicketStore
+	OIDC          *OIDCProvider
+	Purger        *retention.Purger
+	Reports       *report.Scheduler
+	Alerts        *alert.Manager
+	Triggers      *trigger.Manager
+	Watcher       *reconcile.Watcher
+	Node          *cluster.Node
+	Role          *cluster.Role
+	WorkQueue     *workqueue.Queue    // 未启用工作队列时为nil
+	Keyring       *encryption.Keyring // 未配置加密密钥时为nil
+
+	configMu sync.RWMutex // 保护重新加载时可变的配置项
+}
+
+// adminConfig 返回当前管理配置的副本
+func (ctx *Context) adminConfig() config.AdminConfig {
+	ctx.configMu.RLock()
+	defer ctx.configMu.RUnlock()
+	return ctx.Config.Admin
+}
+
+// ingestToken 返回当前事件接入令牌
+func (ctx *Context) ingestToken() string {
+	ctx.configMu.RLock()
+	defer ctx.configMu.RUnlock()
+	return ctx.Config.Ingest.Token
+}
+
+// jwtKeys 返回当前JWT密钥，第一个为签名密钥
+func (ctx *Context) jwtKeys() []config.JWTKey {
+	return ctx.adminConfig().SigningKeys()
 }
 
 // Response 统一响应结构
@@ -48,13 +95,27 @@ type LoginRequest struct {
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Token     string `json:"token"`
-	ExpiresAt int64  `json:"expires_at"`
-	User      User   `json:"user"`
+	Token            string `json:"token"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+	SessionID        string `json:"session_id"`
+	User             User   `json:"user"`
+}
+
+// RefreshRequest 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 登出请求
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // User 用户信息
 type User struct {
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	Timezone string `json:"timezone,omitempty"`
 }
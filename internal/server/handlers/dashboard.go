@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"nsa/internal/datasource"
+	"nsa/internal/nsq"
+	"nsa/internal/report"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConsumerLag 当前节点消费者在nsqd上的积压
+type ConsumerLag struct {
+	Topic   string `json:"topic"`
+	Channel string `json:"channel"`
+	// Lag 尚未投递给消费者的消息数：topic 中尚未分发到channel的消息和channel中等待投递的消息
+	Lag      int64 `json:"lag"`
+	InFlight int64 `json:"in_flight"`
+	Deferred int64 `json:"deferred"`
+	// Paused 消费者在当前节点被暂停，或channel在nsqd上被暂停
+	Paused bool `json:"paused"`
+	// Reported nsqd 上是否存在该channel，为false时积压未知
+	Reported bool `json:"reported"`
+}
+
+// Dashboard 管理首页的概况
+type Dashboard struct {
+	*report.Dashboard
+	Node      string        `json:"node"`
+	Consumers []ConsumerLag `json:"consumers"`
+	// NSQErrors 查询失败的nsqd或nsqlookupd地址及错误，此时消费者积压不完整
+	NSQErrors   map[string]string   `json:"nsq_errors,omitempty"`
+	DataSources []datasource.Health `json:"datasources"`
+}
+
+// GetDashboard 获取管理首页的概况：最近24小时每小时的执行数、失败最多的工作流、最慢的任务、
+// 当前节点消费者的积压、数据源健康状态和未结束的实例
+func GetDashboard(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 查询nsqd和统计实例同时进行，nsqd 不可达时最多等待查询超时
+		server := make(chan *nsq.ServerStats, 1)
+		go func() {
+			ctxStats, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+			defer cancel()
+			server <- ctx.NSQManager.ServerStats(ctxStats)
+		}()
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		summary, err := report.GenerateDashboard(ctxDB, ctx.MongoClient, time.Now())
+		if err != nil {
+			ctx.Logger.Errorf("Failed to generate dashboard: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to generate dashboard",
+			})
+			return
+		}
+
+		stats := <-server
+		dashboard := Dashboard{
+			Dashboard:   summary,
+			Node:        ctx.Config.Cluster.NodeID,
+			Consumers:   ctx.consumerLags(stats),
+			DataSources: ctx.DataSourceMgr.HealthAll(),
+		}
+		if len(stats.Errors) > 0 {
+			dashboard.NSQErrors = stats.Errors
+		}
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    dashboard,
+		})
+	}
+}
+
+// consumerLags 合并当前节点消费者的暂停状态和nsqd上的积压，按积压从多到少排列
+func (ctx *Context) consumerLags(server *nsq.ServerStats) []ConsumerLag {
+	for addr, err := range server.Errors {
+		ctx.Logger.Warnf("Failed to query NSQ stats from %s: %v", addr, err)
+	}
+
+	lags := []ConsumerLag{}
+	for key, entry := range ctx.NSQManager.GetConsumerStats() {
+		topic, channel, _ := strings.Cut(key, ":")
+		lag := ConsumerLag{Topic: topic, Channel: channel}
+		if consumer, ok := entry.(map[string]interface{}); ok {
+			lag.Paused, _ = consumer["paused"].(bool)
+		}
+		if stats, ok := server.Channels[key]; ok {
+			lag.Lag = stats.TopicDepth + stats.Depth
+			lag.InFlight = stats.InFlight
+			lag.Deferred = stats.Deferred
+			lag.Paused = lag.Paused || stats.Paused
+			lag.Reported = true
+		}
+		lags = append(lags, lag)
+	}
+	sort.Slice(lags, func(i, j int) bool {
+		if lags[i].Lag != lags[j].Lag {
+			return lags[i].Lag > lags[j].Lag
+		}
+		return lags[i].Topic+":"+lags[i].Channel < lags[j].Topic+":"+lags[j].Channel
+	})
+	return lags
+}
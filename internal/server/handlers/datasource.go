@@ -61,7 +61,7 @@ func ListDataSources(ctx *Context) gin.HandlerFunc {
 		opts := options.Find()
 		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
 		opts.SetLimit(int64(req.PageSize))
-		opts.SetSort(bson.D{{"created_at", -1}})
+		opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
 
 		cursor, err := collection.Find(ctxDB, filter, opts)
 		if err != nil {
@@ -156,7 +156,8 @@ func CreateDataSource(ctx *Context) gin.HandlerFunc {
 		}
 
 		// 验证必填字段
-		if datasource.Name == "" || datasource.Type == "" || datasource.Host == "" {
+		// BigQuery 使用默认服务地址，无需 host
+		if datasource.Name == "" || datasource.Type == "" || (datasource.Host == "" && datasource.Type != "bigquery" && datasource.Type != "sqlite") {
 			c.JSON(http.StatusBadRequest, Response{
 				Code:    400,
 				Message: "Name, type, and host are required",
@@ -165,7 +166,7 @@ func CreateDataSource(ctx *Context) gin.HandlerFunc {
 		}
 
 		// 验证数据库类型
-		validTypes := []string{"mysql", "postgresql", "sqlserver", "oracle", "mongodb"}
+		validTypes := []string{"mysql", "postgresql", "sqlserver", "oracle", "sqlite", "clickhouse", "mongodb", "s3", "snowflake", "bigquery", "consul", "etcd", "elasticsearch"}
 		validType := false
 		for _, vt := range validTypes {
 			if datasource.Type == vt {
@@ -192,6 +193,11 @@ func CreateDataSource(ctx *Context) gin.HandlerFunc {
 				datasource.Port = 1433
 			case "oracle":
 				datasource.Port = 1521
+			case "clickhouse":
+				datasource.Port = 8123
+				if datasource.SSL {
+					datasource.Port = 8443
+				}
 			case "mongodb":
 				datasource.Port = 27017
 			}
@@ -234,6 +240,23 @@ func CreateDataSource(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
+		aliasCount, err := ctx.MongoClient.GetDatabase().Collection(aliasCollection).CountDocuments(ctxDB, bson.M{"name": datasource.Name})
+		if err != nil {
+			ctx.Logger.Errorf("Failed to check existing datasource alias: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to check existing datasource",
+			})
+			return
+		}
+		if aliasCount > 0 {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Datasource alias with same name already exists",
+			})
+			return
+		}
+
 		// 插入数据库
 		result, err := collection.InsertOne(ctxDB, datasource)
 		if err != nil {
@@ -246,6 +269,7 @@ func CreateDataSource(ctx *Context) gin.HandlerFunc {
 		}
 
 		datasource.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.recordAudit(c, auditCreate, "datasource", datasource.ID.Hex(), datasource.Name, nil, datasource)
 
 		// 添加到数据源管理器
 		if err := ctx.DataSourceMgr.AddDataSource(&datasource); err != nil {
@@ -336,6 +360,7 @@ func UpdateDataSource(ctx *Context) gin.HandlerFunc {
 
 		// 添加新的连接
 		datasource.ID = objectID
+		ctx.recordAudit(c, auditUpdate, "datasource", id, datasource.Name, originalDS, datasource)
 		if err := ctx.DataSourceMgr.AddDataSource(&datasource); err != nil {
 			ctx.Logger.Errorf("Failed to update datasource in manager: %v", err)
 		}
@@ -399,6 +424,8 @@ func DeleteDataSource(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
+		ctx.recordAudit(c, auditDelete, "datasource", id, datasource.Name, datasource, nil)
+
 		// 从数据源管理器中移除
 		ctx.DataSourceMgr.RemoveDataSource(datasource.Name)
 
@@ -437,9 +464,9 @@ func TestDataSource(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
-		// 测试连接
+		// 测试连接，不影响已加载的数据源
 		start := time.Now()
-		err = ctx.DataSourceMgr.AddDataSource(&datasource)
+		err = ctx.DataSourceMgr.TestConnection(&datasource)
 		duration := time.Since(start)
 
 		if err != nil {
@@ -450,23 +477,64 @@ func TestDataSource(ctx *Context) gin.HandlerFunc {
 				Data: map[string]interface{}{
 					"success":  false,
 					"error":    err.Error(),
-					"duration": duration.String(),
+					"duration": duration.Milliseconds(),
 				},
 			})
 			return
 		}
 
-		// 移除测试连接
-		ctx.DataSourceMgr.RemoveDataSource(datasource.Name)
-
 		ctx.Logger.Infof("Datasource connection test successful: %s", datasource.Name)
 		c.JSON(http.StatusOK, Response{
 			Code:    200,
 			Message: "Connection test completed",
 			Data: map[string]interface{}{
 				"success":  true,
-				"duration": duration.String(),
+				"duration": duration.Milliseconds(),
 			},
 		})
 	}
 }
+
+// GetDataSourceHealth 获取数据源连接的健康状态
+func GetDataSourceHealth(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid datasource ID",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("datasources")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var datasource models.DataSource
+		err = collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&datasource)
+		if err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Datasource not found",
+			})
+			return
+		}
+
+		health, ok := ctx.DataSourceMgr.Health(datasource.Name)
+		if !ok {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Datasource is not loaded on this node",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    health,
+		})
+	}
+}
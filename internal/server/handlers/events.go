@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"nsa/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket连接参数
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsPongTimeout  = 60 * time.Second
+)
+
+// wsUpgrader WebSocket升级器（与CORS策略一致，允许任意来源）
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// eventFilter 事件过滤条件
+type eventFilter struct {
+	workflowID string
+	instanceID string
+	types      map[string]bool
+}
+
+// newEventFilter 从查询参数构建过滤条件
+func newEventFilter(c *gin.Context) *eventFilter {
+	filter := &eventFilter{
+		workflowID: c.Query("workflow_id"),
+		instanceID: c.Query("instance_id"),
+	}
+	if types := c.Query("types"); types != "" {
+		filter.types = make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			filter.types[strings.TrimSpace(t)] = true
+		}
+	}
+	return filter
+}
+
+// match 判断事件是否满足过滤条件
+func (f *eventFilter) match(event workflow.Event) bool {
+	if f.workflowID != "" && event.WorkflowID != f.workflowID {
+		return false
+	}
+	if f.instanceID != "" && event.InstanceID != f.instanceID {
+		return false
+	}
+	if f.types != nil && !f.types[event.Type] {
+		return false
+	}
+	return true
+}
+
+// StreamExecutionEvents 通过WebSocket推送实时执行事件
+//
+// 支持 workflow_id、instance_id、types（逗号分隔）查询参数过滤。
+func StreamExecutionEvents(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := newEventFilter(c)
+		redact := !ctx.canViewExecutionData(c.GetString("role"))
+
+		conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to upgrade websocket: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		events, unsubscribe := ctx.Executor.Events().Subscribe()
+		defer unsubscribe()
+
+		ctx.Logger.Infof("Execution event stream opened by %s", c.GetString("username"))
+
+		// 读取客户端消息以处理pong和关闭帧
+		closed := make(chan struct{})
+		conn.SetReadLimit(4096)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if !filter.match(event) {
+					continue
+				}
+				if redact {
+					event = redactEvent(event)
+				}
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteJSON(event); err != nil {
+					return
+				}
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			case <-closed:
+				ctx.Logger.Infof("Execution event stream closed by %s", c.GetString("username"))
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"nsa/internal/workflow"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// adminRoute gRPC方法对应的REST接口
+type adminRoute struct {
+	method string
+	// path 接口路径，{id} 替换为请求的 id 字段
+	path string
+	// body 作为请求体的字段，* 表示整个请求；为空时请求的其余字段作为查询参数
+	body string
+	// list 分页响应中列表在输出消息中的字段名，REST响应中为 data
+	list string
+}
+
+// adminRoutes gRPC管理接口一元方法对应的REST接口，与 proto/nsa/admin/v1/admin.proto 中的注释一致
+var adminRoutes = map[string]adminRoute{
+	"ListWorkflows":   {method: http.MethodGet, path: "/api/v1/workflows", list: "workflows"},
+	"GetWorkflow":     {method: http.MethodGet, path: "/api/v1/workflows/{id}"},
+	"CreateWorkflow":  {method: http.MethodPost, path: "/api/v1/workflows", body: "workflow"},
+	"UpdateWorkflow":  {method: http.MethodPut, path: "/api/v1/workflows/{id}", body: "workflow"},
+	"DeleteWorkflow":  {method: http.MethodDelete, path: "/api/v1/workflows/{id}"},
+	"TriggerWorkflow": {method: http.MethodPost, path: "/api/v1/workflows/{id}/trigger", body: "*"},
+	"ListInstances":   {method: http.MethodGet, path: "/api/v1/instances", list: "instances"},
+	"GetInstance":     {method: http.MethodGet, path: "/api/v1/instances/{id}"},
+}
+
+// 请求体使用与REST接口相同的字段名；REST响应中gRPC定义以外的字段忽略
+var (
+	grpcMarshal   = protojson.MarshalOptions{UseProtoNames: true}
+	grpcUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// GRPCServer gRPC管理接口
+//
+// 一元方法转换为对REST路由的内部请求，认证、权限、校验和审计日志与REST接口完全相同；
+// metadata 中的 authorization 作为 Authorization 请求头转发。
+type GRPCServer struct {
+	ctx     *Context
+	router  http.Handler
+	server  *grpc.Server
+	closing chan struct{}
+}
+
+// NewGRPCServer 创建gRPC管理接口，router 为REST接口的路由
+func NewGRPCServer(ctx *Context, router http.Handler) (*GRPCServer, error) {
+	service, err := adminService()
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin service descriptor: %v", err)
+	}
+
+	s := &GRPCServer{
+		ctx:     ctx,
+		router:  router,
+		server:  grpc.NewServer(),
+		closing: make(chan struct{}),
+	}
+
+	desc := grpc.ServiceDesc{
+		ServiceName: adminServiceName,
+		HandlerType: (*interface{})(nil),
+		Metadata:    service.ParentFile().Path(),
+	}
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if md.IsStreamingServer() {
+			desc.Streams = append(desc.Streams, grpc.StreamDesc{
+				StreamName:    string(md.Name()),
+				Handler:       s.watchEvents(md),
+				ServerStreams: true,
+			})
+			continue
+		}
+		route, ok := adminRoutes[string(md.Name())]
+		if !ok {
+			return nil, fmt.Errorf("no REST route for gRPC method %s", md.Name())
+		}
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: string(md.Name()),
+			Handler:    s.unary(md, route),
+		})
+	}
+	s.server.RegisterService(&desc, s)
+	reflection.Register(s.server)
+	return s, nil
+}
+
+// Serve 在监听器上提供服务，直到 Shutdown
+func (s *GRPCServer) Serve(lis net.Listener) error {
+	return s.server.Serve(lis)
+}
+
+// Shutdown 关闭执行事件流，等待进行中的请求完成，ctx 结束时强制关闭连接
+func (s *GRPCServer) Shutdown(ctx context.Context) {
+	close(s.closing)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+}
+
+// unaryHandler 一元方法的处理函数，与 grpc.MethodDesc.Handler 的类型相同
+type unaryHandler = func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error)
+
+// unary 一元方法的处理函数
+func (s *GRPCServer) unary(md protoreflect.MethodDescriptor, route adminRoute) unaryHandler {
+	fullMethod := fmt.Sprintf("/%s/%s", adminServiceName, md.Name())
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := dynamicpb.NewMessage(md.Input())
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.forward(ctx, route, req.(*dynamicpb.Message), md.Output())
+		}
+		if interceptor == nil {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handler)
+	}
+}
+
+// forward 将gRPC请求转换为REST请求执行，响应数据转换为输出消息
+func (s *GRPCServer) forward(ctx context.Context, route adminRoute, req *dynamicpb.Message, output protoreflect.MessageDescriptor) (*dynamicpb.Message, error) {
+	fields := req.Descriptor().Fields()
+	path := route.path
+	var pathField protoreflect.FieldDescriptor
+	if strings.Contains(path, "{id}") {
+		pathField = fields.ByName("id")
+		id := req.Get(pathField).String()
+		if id == "" {
+			return nil, status.Error(codes.InvalidArgument, "id is required")
+		}
+		path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	}
+
+	query := url.Values{}
+	var body []byte
+	var err error
+	switch route.body {
+	case "*":
+		body, err = grpcMarshal.Marshal(req)
+	case "":
+		req.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			if fd != pathField {
+				query.Set(string(fd.Name()), queryValue(fd, v))
+			}
+			return true
+		})
+	default:
+		body, err = grpcMarshal.Marshal(req.Get(fields.ByName(protoreflect.Name(route.body))).Message().Interface())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	data, err := s.call(ctx, route.method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if route.list != "" && len(data) > 0 {
+		if data, err = renameListField(data, route.list); err != nil {
+			return nil, status.Errorf(codes.Internal, "invalid response: %v", err)
+		}
+	}
+
+	out := dynamicpb.NewMessage(output)
+	if len(data) > 0 && string(data) != "null" {
+		if err := grpcUnmarshal.Unmarshal(data, out); err != nil {
+			return nil, status.Errorf(codes.Internal, "invalid response: %v", err)
+		}
+	}
+	return out, nil
+}
+
+// call 在REST路由上执行请求，返回响应的 data 字段；错误响应按HTTP状态码转换为gRPC状态
+func (s *GRPCServer) call(ctx context.Context, method, path string, body []byte) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to build request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			req.Header.Set("Authorization", values[0])
+		}
+		if values := md.Get("user-agent"); len(values) > 0 {
+			req.Header.Set("User-Agent", values[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+	}
+
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, req)
+
+	var resp struct {
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	err = json.Unmarshal(recorder.Body.Bytes(), &resp)
+	if recorder.Code >= http.StatusBadRequest {
+		message := resp.Message
+		if err != nil || message == "" {
+			message = http.StatusText(recorder.Code)
+		}
+		return nil, status.Error(grpcCode(recorder.Code), message)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid response: %v", err)
+	}
+	return resp.Data, nil
+}
+
+// watchEvents WatchInstanceEvents 的处理函数，推送与 /ws/executions 相同的实时执行事件
+//
+// 令牌通过 /api/v1/auth/me 校验；没有查看执行数据权限的角色收到脱敏的事件。
+func (s *GRPCServer) watchEvents(md protoreflect.MethodDescriptor) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		req := dynamicpb.NewMessage(md.Input())
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+
+		data, err := s.call(stream.Context(), http.MethodGet, "/api/v1/auth/me", nil)
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := json.Unmarshal(data, &user); err != nil {
+			return status.Errorf(codes.Internal, "invalid response: %v", err)
+		}
+
+		fields := req.Descriptor().Fields()
+		filter := &eventFilter{
+			workflowID: req.Get(fields.ByName("workflow_id")).String(),
+			instanceID: req.Get(fields.ByName("instance_id")).String(),
+		}
+		if types := req.Get(fields.ByName("types")).List(); types.Len() > 0 {
+			filter.types = make(map[string]bool, types.Len())
+			for i := 0; i < types.Len(); i++ {
+				filter.types[types.Get(i).String()] = true
+			}
+		}
+		redact := !s.ctx.canViewExecutionData(user.Role)
+
+		events, unsubscribe := s.ctx.Executor.Events().Subscribe()
+		defer unsubscribe()
+
+		s.ctx.Logger.Infof("Execution event stream opened by %s over gRPC", user.Username)
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return nil
+				}
+				if !filter.match(event) {
+					continue
+				}
+				if redact {
+					event = redactEvent(event)
+				}
+				out, err := eventMessage(event, md.Output())
+				if err != nil {
+					s.ctx.Logger.Errorf("Failed to convert %s event of instance %s: %v", event.Type, event.InstanceID, err)
+					continue
+				}
+				if err := stream.SendMsg(out); err != nil {
+					return err
+				}
+			case <-stream.Context().Done():
+				s.ctx.Logger.Infof("Execution event stream closed by %s over gRPC", user.Username)
+				return nil
+			case <-s.closing:
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+		}
+	}
+}
+
+// eventMessage 将执行事件转换为 Event 消息
+func eventMessage(event workflow.Event, desc protoreflect.MessageDescriptor) (*dynamicpb.Message, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	out := dynamicpb.NewMessage(desc)
+	if err := grpcUnmarshal.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// queryValue 请求字段作为查询参数的值，重复字段以逗号连接，包装类型取其中的值
+func queryValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch {
+	case fd.IsList():
+		values := make([]string, v.List().Len())
+		for i := range values {
+			values[i] = fmt.Sprint(v.List().Get(i).Interface())
+		}
+		return strings.Join(values, ",")
+	case fd.Message() != nil:
+		wrapper := v.Message()
+		return fmt.Sprint(wrapper.Get(wrapper.Descriptor().Fields().ByName("value")).Interface())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// renameListField 将分页响应中的列表字段 data 改名为输出消息中的字段名
+func renameListField(data json.RawMessage, name string) (json.RawMessage, error) {
+	var page map[string]json.RawMessage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+	page[name] = page["data"]
+	delete(page, "data")
+	return json.Marshal(page)
+}
+
+// grpcCode 将REST接口的HTTP状态码转换为gRPC状态码
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
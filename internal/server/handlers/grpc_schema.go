@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	// 注册管理接口引用的 google/protobuf 类型
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// adminServiceName gRPC管理接口的服务名
+const adminServiceName = "nsa.admin.v1.AdminService"
+
+// 管理接口引用的 google/protobuf 类型
+const (
+	typeStruct    = ".google.protobuf.Struct"
+	typeValue     = ".google.protobuf.Value"
+	typeTimestamp = ".google.protobuf.Timestamp"
+	typeBoolValue = ".google.protobuf.BoolValue"
+)
+
+// adminService 返回注册到全局描述符中的管理接口服务描述，全局注册后服务端反射可以返回该服务
+var adminService = sync.OnceValues(func() (protoreflect.ServiceDescriptor, error) {
+	file, err := protodesc.NewFile(adminFileDescriptor(), protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, err
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		return nil, err
+	}
+	return file.Services().ByName("AdminService"), nil
+})
+
+// adminFileDescriptor 管理接口的描述符，与 proto/nsa/admin/v1/admin.proto 保持一致，
+// 修改其中之一时 TestAdminFileDescriptorMatchesProto 检查另一个是否同步修改
+func adminFileDescriptor() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("nsa/admin/v1/admin.proto"),
+		Package: proto.String("nsa.admin.v1"),
+		Syntax:  proto.String("proto3"),
+		Dependency: []string{
+			"google/protobuf/struct.proto",
+			"google/protobuf/timestamp.proto",
+			"google/protobuf/wrappers.proto",
+		},
+		Options: &descriptorpb.FileOptions{
+			GoPackage:         proto.String("nsa/admin/v1;adminv1"),
+			JavaPackage:       proto.String("com.nsa.admin.v1"),
+			JavaMultipleFiles: proto.Bool(true),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{
+			pbMessage("Workflow",
+				pbString(1, "id"),
+				pbString(2, "name"),
+				pbString(3, "description"),
+				pbString(4, "topic"),
+				pbString(5, "channel"),
+				pbBool(6, "enabled"),
+				pbMessageField(7, "dag", "Dag"),
+				pbRepeated(pbMessageField(8, "triggers", "Trigger")),
+				pbString(9, "concurrency_key"),
+				pbString(10, "on_restart"),
+				pbMessageField(11, "rate_limit", "RateLimit"),
+				pbMessageField(12, "execution_window", "ExecutionWindow"),
+				pbMessageField(13, "requeue", "RequeuePolicy"),
+				pbMessageField(14, "budget", "ExecutionBudget"),
+				pbMessageField(15, "budget_exceeded", "BudgetExceeded"),
+				pbString(16, "owner"),
+				pbString(17, "project"),
+				pbRepeated(pbString(18, "unmask")),
+				pbMessageField(19, "created_at", typeTimestamp),
+				pbMessageField(20, "updated_at", typeTimestamp),
+			),
+			pbMessage("Dag",
+				pbString(1, "id"),
+				pbString(2, "name"),
+				pbRepeated(pbMessageField(3, "vars", "DagVar")),
+				pbRepeated(pbMessageField(4, "tasks", "Task")),
+			),
+			pbMessage("DagVar",
+				pbString(1, "name"),
+				pbString(2, "description"),
+				pbMessageField(3, "default_value", typeValue),
+				pbString(4, "type"),
+			),
+			pbMessage("Task",
+				pbString(1, "id"),
+				pbString(2, "name"),
+				pbString(3, "action_name"),
+				pbRepeated(pbString(4, "depend_on")),
+				pbMessageField(5, "params", typeStruct),
+				pbMessageField(6, "retry", "RetryConfig"),
+				pbInt32(7, "timeout"),
+				pbString(8, "condition"),
+			),
+			pbMessage("RetryConfig",
+				pbBool(1, "enabled"),
+				pbInt32(2, "max_times"),
+				pbInt32(3, "interval"),
+			),
+			pbMessage("Trigger",
+				pbString(1, "type"),
+				pbMessageField(2, "params", typeStruct),
+			),
+			pbMessage("RateLimit",
+				pbInt32(1, "limit"),
+				pbString(2, "period"),
+			),
+			pbMessage("ExecutionWindow",
+				pbRepeated(pbMessageField(1, "periods", "WindowPeriod")),
+				pbString(2, "timezone"),
+				pbString(3, "policy"),
+			),
+			pbMessage("WindowPeriod",
+				pbRepeated(pbString(1, "days")),
+				pbString(2, "start"),
+				pbString(3, "end"),
+			),
+			pbMessage("RequeuePolicy",
+				pbInt32(1, "delay"),
+				pbRepeated(pbString(2, "permanent_errors")),
+			),
+			pbMessage("ExecutionBudget",
+				pbDouble(1, "limit"),
+				pbString(2, "cost"),
+				pbString(3, "timezone"),
+				pbRepeated(pbString(4, "emails")),
+				pbString(5, "webhook_secret"),
+			),
+			pbMessage("BudgetExceeded",
+				pbString(1, "day"),
+				pbDouble(2, "used"),
+				pbDouble(3, "limit"),
+				pbMessageField(4, "at", typeTimestamp),
+			),
+			pbMessage("Instance",
+				pbString(1, "id"),
+				pbString(2, "workflow_id"),
+				pbString(3, "status"),
+				pbMessageField(4, "start_time", typeTimestamp),
+				pbMessageField(5, "end_time", typeTimestamp),
+				pbMessageField(6, "vars", typeStruct),
+				pbMessageField(7, "results", typeStruct),
+				pbString(8, "concurrency_key"),
+				pbInt32(9, "next_task"),
+				pbMessageField(10, "resume_at", typeTimestamp),
+				pbString(11, "node"),
+				pbString(12, "project"),
+				pbRepeated(pbString(13, "unmask")),
+				pbString(14, "resolution"),
+				pbInt64(15, "duration"),
+				pbRepeated(pbMessageField(16, "tasks", "TaskLog")),
+				pbInt64(17, "version"),
+			),
+			pbMessage("TaskLog",
+				pbString(1, "id"),
+				pbString(2, "workflow_id"),
+				pbString(3, "instance_id"),
+				pbString(4, "task_id"),
+				pbString(5, "status"),
+				pbString(6, "message"),
+				pbMessageField(7, "input", typeValue),
+				pbMessageField(8, "output", typeValue),
+				pbString(9, "error"),
+				pbMessageField(10, "start_time", typeTimestamp),
+				pbMessageField(11, "end_time", typeTimestamp),
+				pbInt64(12, "duration"),
+				pbInt32(13, "attempts"),
+				pbMessageField(14, "metadata", typeStruct),
+				pbRepeated(pbString(15, "console")),
+				pbBool(16, "console_truncated"),
+				pbMessageField(17, "created_at", typeTimestamp),
+			),
+			pbMessage("Event",
+				pbString(1, "type"),
+				pbString(2, "instance_id"),
+				pbString(3, "workflow_id"),
+				pbString(4, "task_id"),
+				pbString(5, "status"),
+				pbString(6, "error"),
+				pbInt64(7, "duration"),
+				pbInt32(8, "attempts"),
+				pbMessageField(9, "data", typeValue),
+				pbMessageField(10, "timestamp", typeTimestamp),
+			),
+			pbMessage("ListWorkflowsRequest",
+				pbInt32(1, "page"),
+				pbInt32(2, "page_size"),
+				pbString(3, "topic"),
+				pbMessageField(4, "enabled", typeBoolValue),
+				pbString(5, "owner"),
+				pbString(6, "project"),
+			),
+			pbMessage("ListWorkflowsResponse",
+				pbInt64(1, "total"),
+				pbInt32(2, "page"),
+				pbInt32(3, "page_size"),
+				pbRepeated(pbMessageField(4, "workflows", "Workflow")),
+			),
+			pbMessage("GetWorkflowRequest",
+				pbString(1, "id"),
+			),
+			pbMessage("CreateWorkflowRequest",
+				pbMessageField(1, "workflow", "Workflow"),
+			),
+			pbMessage("UpdateWorkflowRequest",
+				pbString(1, "id"),
+				pbMessageField(2, "workflow", "Workflow"),
+			),
+			pbMessage("DeleteWorkflowRequest",
+				pbString(1, "id"),
+			),
+			pbMessage("DeleteWorkflowResponse"),
+			pbMessage("TriggerWorkflowRequest",
+				pbString(1, "id"),
+				pbMessageField(2, "data", typeStruct),
+			),
+			pbMessage("TriggerWorkflowResponse",
+				pbString(1, "message_id"),
+				pbString(2, "instance_id"),
+			),
+			pbMessage("ListInstancesRequest",
+				pbInt32(1, "page"),
+				pbInt32(2, "page_size"),
+				pbString(3, "workflow_id"),
+				pbString(4, "status"),
+				pbString(5, "node"),
+				pbString(6, "since"),
+				pbString(7, "view"),
+			),
+			pbMessage("ListInstancesResponse",
+				pbInt64(1, "total"),
+				pbInt32(2, "page"),
+				pbInt32(3, "page_size"),
+				pbRepeated(pbMessageField(4, "instances", "Instance")),
+			),
+			pbMessage("GetInstanceRequest",
+				pbString(1, "id"),
+			),
+			pbMessage("WatchInstanceEventsRequest",
+				pbString(1, "workflow_id"),
+				pbString(2, "instance_id"),
+				pbRepeated(pbString(3, "types")),
+			),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("AdminService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				pbMethod("ListWorkflows", "ListWorkflowsRequest", "ListWorkflowsResponse"),
+				pbMethod("GetWorkflow", "GetWorkflowRequest", "Workflow"),
+				pbMethod("CreateWorkflow", "CreateWorkflowRequest", "Workflow"),
+				pbMethod("UpdateWorkflow", "UpdateWorkflowRequest", "Workflow"),
+				pbMethod("DeleteWorkflow", "DeleteWorkflowRequest", "DeleteWorkflowResponse"),
+				pbMethod("TriggerWorkflow", "TriggerWorkflowRequest", "TriggerWorkflowResponse"),
+				pbMethod("ListInstances", "ListInstancesRequest", "ListInstancesResponse"),
+				pbMethod("GetInstance", "GetInstanceRequest", "Instance"),
+				pbStreamingMethod("WatchInstanceEvents", "WatchInstanceEventsRequest", "Event"),
+			},
+		}},
+	}
+}
+
+// pbMessage 消息描述
+func pbMessage(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+}
+
+// pbField 字段描述，typeName 为消息类型名，以 . 开头的为完整名称，否则为 nsa.admin.v1 中的消息
+func pbField(number int32, name string, kind descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   kind.Enum(),
+	}
+	if typeName != "" {
+		if typeName[0] != '.' {
+			typeName = ".nsa.admin.v1." + typeName
+		}
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func pbString(number int32, name string) *descriptorpb.FieldDescriptorProto {
+	return pbField(number, name, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+}
+
+func pbBool(number int32, name string) *descriptorpb.FieldDescriptorProto {
+	return pbField(number, name, descriptorpb.FieldDescriptorProto_TYPE_BOOL, "")
+}
+
+func pbInt32(number int32, name string) *descriptorpb.FieldDescriptorProto {
+	return pbField(number, name, descriptorpb.FieldDescriptorProto_TYPE_INT32, "")
+}
+
+func pbInt64(number int32, name string) *descriptorpb.FieldDescriptorProto {
+	return pbField(number, name, descriptorpb.FieldDescriptorProto_TYPE_INT64, "")
+}
+
+func pbDouble(number int32, name string) *descriptorpb.FieldDescriptorProto {
+	return pbField(number, name, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, "")
+}
+
+func pbMessageField(number int32, name, typeName string) *descriptorpb.FieldDescriptorProto {
+	return pbField(number, name, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, typeName)
+}
+
+// pbRepeated 将字段改为重复字段
+func pbRepeated(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+	f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return f
+}
+
+// pbMethod 一元方法描述
+func pbMethod(name, input, output string) *descriptorpb.MethodDescriptorProto {
+	return &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String(name),
+		InputType:  proto.String(".nsa.admin.v1." + input),
+		OutputType: proto.String(".nsa.admin.v1." + output),
+	}
+}
+
+// pbStreamingMethod 服务端流方法描述
+func pbStreamingMethod(name, input, output string) *descriptorpb.MethodDescriptorProto {
+	m := pbMethod(name, input, output)
+	m.ServerStreaming = proto.Bool(true)
+	return m
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// TestAdminFileDescriptorMatchesProto 检查手写的描述符与 proto/nsa/admin/v1/admin.proto 一致
+func TestAdminFileDescriptorMatchesProto(t *testing.T) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{"../../../proto"},
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), "nsa/admin/v1/admin.proto")
+	if err != nil {
+		t.Fatalf("failed to compile admin.proto: %v", err)
+	}
+	want := normalizeFileDescriptor(protodesc.ToFileDescriptorProto(files[0]))
+
+	file, err := protodesc.NewFile(adminFileDescriptor(), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("invalid adminFileDescriptor: %v", err)
+	}
+	got := normalizeFileDescriptor(protodesc.ToFileDescriptorProto(file))
+
+	messages := make(map[string]*descriptorpb.DescriptorProto, len(got.MessageType))
+	for _, message := range got.MessageType {
+		messages[message.GetName()] = message
+	}
+	for _, message := range want.MessageType {
+		gotMessage, ok := messages[message.GetName()]
+		if !ok {
+			t.Errorf("message %s is missing from adminFileDescriptor", message.GetName())
+			continue
+		}
+		if !proto.Equal(gotMessage, message) {
+			t.Errorf("message %s differs:\nadminFileDescriptor: %s\nadmin.proto: %s",
+				message.GetName(), prototext.Format(gotMessage), prototext.Format(message))
+		}
+		delete(messages, message.GetName())
+	}
+	for name := range messages {
+		t.Errorf("message %s is not defined in admin.proto", name)
+	}
+
+	got.MessageType, want.MessageType = nil, nil
+	if !proto.Equal(got, want) {
+		t.Errorf("file descriptor differs:\nadminFileDescriptor: %s\nadmin.proto: %s", prototext.Format(got), prototext.Format(want))
+	}
+}
+
+// normalizeFileDescriptor 去掉编译器额外生成的源码位置和 json_name
+func normalizeFileDescriptor(fd *descriptorpb.FileDescriptorProto) *descriptorpb.FileDescriptorProto {
+	fd.SourceCodeInfo = nil
+	for _, message := range fd.MessageType {
+		for _, field := range message.Field {
+			field.JsonName = nil
+		}
+	}
+	return fd
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"nsa/internal/logger"
+	"nsa/internal/trigger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIngestBodySize 事件接入请求体的最大大小
+const maxIngestBodySize = 1024 * 1024
+
+// IngestMonitoringEvent 接收监控系统的Webhook（/ingest/zabbix、/ingest/nagios）
+//
+// 请求体为JSON对象或表单，转换为统一的事件结构后按工作流的 monitoring 触发器路由。
+// 监控系统无法使用会过期的访问令牌，因此使用配置中的 ingest.token 认证，未配置时接口不可用。
+func IngestMonitoringEvent(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := ctx.ingestToken()
+		if token == "" {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Event ingestion is disabled",
+			})
+			return
+		}
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			ctx.securityEvent(c, securityIngestToken, logger.SecurityFailure, map[string]interface{}{"reason": "invalid_token"})
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "Invalid ingest token",
+			})
+			return
+		}
+
+		source := c.Param("source")
+		ctx.securityEvent(c, securityIngestToken, logger.SecuritySuccess, map[string]interface{}{"source": source})
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxIngestBodySize)
+
+		payload := make(map[string]interface{})
+		if strings.HasPrefix(c.ContentType(), "application/json") {
+			if err := json.NewDecoder(c.Request.Body).Decode(&payload); err != nil {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid JSON body",
+				})
+				return
+			}
+		} else {
+			if err := c.Request.ParseForm(); err != nil {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid form body",
+				})
+				return
+			}
+			for key, values := range c.Request.PostForm {
+				payload[key] = values[0]
+			}
+		}
+
+		event, err := trigger.NormalizeMonitoringEvent(source, payload)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		workflows := ctx.Triggers.Dispatch(trigger.TypeMonitoring, event)
+		ctx.Logger.Infof("Ingested %s event %v for host %v (%v, %v), routed to %d workflows",
+			source, event["event_id"], event["host"], event["status"], event["severity"], len(workflows))
+
+		delete(event, "raw")
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Event accepted",
+			Data: map[string]interface{}{
+				"event":     event,
+				"workflows": workflows,
+			},
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nsa/internal/models"
+	"nsa/internal/workflow"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InstanceTimeline 工作流实例及其按执行顺序排列的任务日志
+type InstanceTimeline struct {
+	workflow.WorkflowInstance `bson:",inline"`
+	Duration                  int64                 `bson:"-" json:"duration"` // 实例总耗时(毫秒)，运行中为已耗时
+	Tasks                     []models.ExecutionLog `bson:"tasks" json:"tasks"`
+}
+
+// UnmarshalBSON 实现 bson.Unmarshaler，内嵌的实例和任务日志分别解码，启用存储加密时由各自的 UnmarshalBSON 解密
+func (t *InstanceTimeline) UnmarshalBSON(data []byte) error {
+	var tasks struct {
+		Tasks []models.ExecutionLog `bson:"tasks"`
+	}
+	if err := bson.Unmarshal(data, &tasks); err != nil {
+		return err
+	}
+	*t = InstanceTimeline{Tasks: tasks.Tasks}
+	return bson.Unmarshal(data, &t.WorkflowInstance)
+}
+
+// InitInstances 创建执行日志按实例查询、按工作流统计的索引
+func InitInstances(ctx *Context) error {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := ctx.MongoClient.GetDatabase()
+	if _, err := db.Collection("execution_logs").Indexes().CreateMany(ctxDB, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "instance_id", Value: 1}, {Key: "start_time", Value: 1}}},
+		{Keys: bson.D{{Key: "workflow_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}); err != nil {
+		return err
+	}
+	if _, err := db.Collection("workflow_instances").Indexes().CreateOne(ctxDB, mongo.IndexModel{
+		Keys: bson.D{{Key: "workflow_id", Value: 1}, {Key: "start_time", Value: -1}},
+	}); err != nil {
+		return err
+	}
+	// 历史事件的序号在实例内唯一，多个节点同时追加时冲突的一方重新读取序号
+	_, err := db.Collection(workflow.HistoryCollection).Indexes().CreateOne(ctxDB, mongo.IndexModel{
+		Keys:    bson.D{{Key: "instance_id", Value: 1}, {Key: "seq", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// ListInstances 获取工作流实例列表
+func ListInstances(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PaginationRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid query parameters",
+			})
+			return
+		}
+
+		// 设置默认值
+		if req.Page <= 0 {
+			req.Page = 1
+		}
+		if req.PageSize <= 0 {
+			req.PageSize = 20
+		}
+
+		query, ok := ctx.listQueryFor(c, "instances")
+		if !ok {
+			return
+		}
+		since, err := query.since()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("workflow_instances")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// 构建查询条件
+		filter := bson.M{}
+		if workflowID := query.get("workflow_id"); workflowID != "" {
+			filter["workflow_id"] = workflowID
+		}
+		if status := query.get("status"); status != "" {
+			filter["status"] = status
+		}
+		if node := query.get("node"); node != "" {
+			filter["node"] = node
+		}
+		if !since.IsZero() {
+			filter["start_time"] = bson.M{"$gte": since}
+		}
+
+		// 获取总数
+		total, err := collection.CountDocuments(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to count workflow instances: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to count workflow instances",
+			})
+			return
+		}
+
+		// 查询数据，列表中不返回变量和结果（启用存储加密时在 encrypted 字段中）
+		opts := options.Find()
+		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
+		opts.SetLimit(int64(req.PageSize))
+		opts.SetSort(bson.D{{Key: "start_time", Value: -1}})
+		opts.SetProjection(bson.M{"vars": 0, "results": 0, models.SealedField: 0})
+
+		cursor, err := collection.Find(ctxDB, filter, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find workflow instances: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find workflow instances",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		var instances []workflow.WorkflowInstance
+		if err := cursor.All(ctxDB, &instances); err != nil {
+			ctx.Logger.Errorf("Failed to decode workflow instances: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode workflow instances",
+			})
+			return
+		}
+
+		if !ctx.canViewExecutionData(c.GetString("role")) {
+			for i := range instances {
+				redactInstance(&instances[i])
+			}
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: PaginationResponse{
+				Total:    total,
+				Page:     req.Page,
+				PageSize: req.PageSize,
+				Data:     instances,
+			},
+		})
+	}
+}
+
+// GetInstance 获取工作流实例及其任务时间线
+//
+// 通过一次聚合查询（$lookup）同时返回实例和按开始时间排序的任务执行日志。
+func GetInstance(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		collection := ctx.MongoClient.GetDatabase().Collection("workflow_instances")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"_id": id}}},
+			{{Key: "$lookup", Value: bson.M{
+				"from": "execution_logs",
+				"let":  bson.M{"instance_id": "$_id"},
+				"pipeline": bson.A{
+					bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$instance_id", "$$instance_id"}}}},
+					bson.M{"$sort": bson.D{{Key: "start_time", Value: 1}, {Key: "_id", Value: 1}}},
+				},
+				"as": "tasks",
+			}}},
+		}
+
+		cursor, err := collection.Aggregate(ctxDB, pipeline)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find workflow instance: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find workflow instance",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		if !cursor.Next(ctxDB) {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Workflow instance not found",
+			})
+			return
+		}
+
+		var timeline InstanceTimeline
+		if err := cursor.Decode(&timeline); err != nil {
+			ctx.Logger.Errorf("Failed to decode workflow instance: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode workflow instance",
+			})
+			return
+		}
+
+		if timeline.Tasks == nil {
+			timeline.Tasks = []models.ExecutionLog{}
+		}
+		end := timeline.EndTime
+		if end.IsZero() {
+			end = time.Now()
+		}
+		timeline.Duration = end.Sub(timeline.StartTime).Milliseconds()
+		timeline.RestoreMessageVar()
+		if !ctx.canViewExecutionData(c.GetString("role")) {
+			redactInstance(&timeline.WorkflowInstance)
+			redactExecutionLogs(timeline.Tasks)
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    timeline,
+		})
+	}
+}
+
+// InstanceHistory 实例的历史事件及重放得到的状态
+type InstanceHistory struct {
+	Events []workflow.HistoryEvent `json:"events"`
+	State  *workflow.InstanceState `json:"state"`
+}
+
+// GetInstanceHistory 获取实例按序号排列的生命周期事件，以及依次应用事件重建的实例状态
+func GetInstanceHistory(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		events, err := ctx.Executor.InstanceHistory(ctxDB, id)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find workflow instance history: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find workflow instance history",
+			})
+			return
+		}
+		if len(events) == 0 {
+			// 没有事件的实例可能在记录历史之前创建，或者不存在
+			err := ctx.MongoClient.GetDatabase().Collection("workflow_instances").FindOne(ctxDB, bson.M{"_id": id}).Err()
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, Response{
+					Code:    404,
+					Message: "Workflow instance not found",
+				})
+				return
+			}
+			if err != nil {
+				ctx.Logger.Errorf("Failed to find workflow instance: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to find workflow instance",
+				})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: InstanceHistory{
+				Events: events,
+				State:  workflow.ReplayHistory(events),
+			},
+		})
+	}
+}
+
+// sseHeartbeatInterval SSE心跳间隔，防止代理断开空闲连接
+const sseHeartbeatInterval = 15 * time.Second
+
+// StreamInstanceLogs 通过SSE推送实例的任务执行日志
+//
+// 先回放已有的执行日志，再实时推送后续任务日志；实例结束后发送 end 事件并关闭连接。
+// 事件类型：log（执行日志）、task_started（任务开始）、end（实例结束）。
+func StreamInstanceLogs(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		// 先订阅再查询，避免回放和实时推送之间遗漏日志
+		events, unsubscribe := ctx.Executor.Events().Subscribe()
+		defer unsubscribe()
+
+		db := ctx.MongoClient.GetDatabase()
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var instance workflow.WorkflowInstance
+		if err := db.Collection("workflow_instances").FindOne(ctxDB, bson.M{"_id": id}).Decode(&instance); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, Response{
+					Code:    404,
+					Message: "Workflow instance not found",
+				})
+				return
+			}
+			ctx.Logger.Errorf("Failed to find workflow instance: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find workflow instance",
+			})
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "start_time", Value: 1}, {Key: "_id", Value: 1}})
+		cursor, err := db.Collection("execution_logs").Find(ctxDB, bson.M{"instance_id": id}, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find execution logs: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find execution logs",
+			})
+			return
+		}
+		var logs []models.ExecutionLog
+		if err := cursor.All(ctxDB, &logs); err != nil {
+			ctx.Logger.Errorf("Failed to decode execution logs: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode execution logs",
+			})
+			return
+		}
+
+		redact := !ctx.canViewExecutionData(c.GetString("role"))
+		if redact {
+			redactExecutionLogs(logs)
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		// 回放已有日志
+		sent := make(map[string]bool, len(logs))
+		for _, log := range logs {
+			sent[log.ID.Hex()] = true
+			c.SSEvent("log", log)
+		}
+		if instance.Status != "running" && instance.Status != "delayed" {
+			end := workflow.Event{
+				Type:       workflow.EventInstanceCompleted,
+				InstanceID: instance.ID,
+				WorkflowID: instance.WorkflowID,
+				Status:     instance.Status,
+				Duration:   instance.EndTime.Sub(instance.StartTime).Milliseconds(),
+				Timestamp:  instance.EndTime,
+			}
+			if instance.Status == "failed" || instance.Status == "interrupted" || instance.Status == "cancelled" {
+				end.Type = workflow.EventInstanceFailed
+			}
+			c.SSEvent("end", end)
+			c.Writer.Flush()
+			return
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if event.InstanceID != id {
+					continue
+				}
+				switch event.Type {
+				case workflow.EventTaskStarted:
+					c.SSEvent("task_started", event)
+				case workflow.EventTaskFinished, workflow.EventTaskFailed:
+					log, ok := event.Data.(*models.ExecutionLog)
+					if !ok || sent[log.ID.Hex()] {
+						continue
+					}
+					if redact {
+						c.SSEvent("log", redactExecutionLog(*log))
+					} else {
+						c.SSEvent("log", log)
+					}
+				case workflow.EventInstanceCompleted, workflow.EventInstanceFailed:
+					c.SSEvent("end", event)
+					c.Writer.Flush()
+					return
+				default:
+					continue
+				}
+				c.Writer.Flush()
+			case <-heartbeat.C:
+				// SSE注释行作为心跳
+				if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}
+}
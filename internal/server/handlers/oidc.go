@@ -0,0 +1,672 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// oidcStateCookie 保存登录state的cookie名称
+const oidcStateCookie = "nsa_oidc_state"
+
+// OIDCProvider OIDC身份提供方
+type OIDCProvider struct {
+	config     config.OIDCConfig
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{} // kid -> 公钥
+}
+
+// oidcDiscovery OIDC发现文档
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcStateClaims 登录state（签名后存入cookie）
+type oidcStateClaims struct {
+	State string `json:"state"`
+	Nonce string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// NewOIDCProvider 创建OIDC身份提供方
+func NewOIDCProvider(cfg config.OIDCConfig) *OIDCProvider {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email", "groups"}
+	}
+
+	return &OIDCProvider{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		keys:       make(map[string]interface{}),
+	}
+}
+
+// OIDCLogin 跳转到IdP登录页
+func OIDCLogin(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ctx.OIDC == nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "OIDC login is not enabled",
+			})
+			return
+		}
+
+		discovery, err := ctx.OIDC.getDiscovery()
+		if err != nil {
+			ctx.Logger.Errorf("Failed to load OIDC discovery document: %v", err)
+			c.JSON(http.StatusBadGateway, Response{
+				Code:    502,
+				Message: "Failed to contact identity provider",
+			})
+			return
+		}
+
+		state, nonce := randomHex(16), randomHex(16)
+		stateToken, err := signOIDCState(ctx, state, nonce)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to sign OIDC state: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to start OIDC login",
+			})
+			return
+		}
+		c.SetCookie(oidcStateCookie, stateToken, 600, "/auth/oidc", "", c.Request.TLS != nil, true)
+
+		query := url.Values{}
+		query.Set("response_type", "code")
+		query.Set("client_id", ctx.OIDC.config.ClientID)
+		query.Set("redirect_uri", ctx.OIDC.config.RedirectURL)
+		query.Set("scope", strings.Join(ctx.OIDC.config.Scopes, " "))
+		query.Set("state", state)
+		query.Set("nonce", nonce)
+
+		c.Redirect(http.StatusFound, discovery.AuthorizationEndpoint+"?"+query.Encode())
+	}
+}
+
+// OIDCCallback 处理IdP回调，校验ID Token并签发NSA令牌
+func OIDCCallback(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ctx.OIDC == nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "OIDC login is not enabled",
+			})
+			return
+		}
+
+		if errMsg := c.Query("error"); errMsg != "" {
+			ctx.securityEvent(c, securityLogin, logger.SecurityFailure, map[string]interface{}{
+				"auth_method": "oidc",
+				"reason":      "idp_error",
+				"error":       errMsg,
+			})
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "OIDC login failed: " + errMsg,
+			})
+			return
+		}
+
+		// 校验state，防止CSRF
+		stateToken, err := c.Cookie(oidcStateCookie)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Missing OIDC state",
+			})
+			return
+		}
+		c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", c.Request.TLS != nil, true)
+
+		stateClaims, err := parseOIDCState(ctx, stateToken)
+		if err != nil || stateClaims.State != c.Query("state") {
+			ctx.securityEvent(c, securityLogin, logger.SecurityFailure, map[string]interface{}{
+				"auth_method": "oidc",
+				"reason":      "invalid_state",
+			})
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid OIDC state",
+			})
+			return
+		}
+
+		code := c.Query("code")
+		if code == "" {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Missing authorization code",
+			})
+			return
+		}
+
+		claims, err := ctx.OIDC.exchange(c.Request.Context(), code, stateClaims.Nonce)
+		if err != nil {
+			ctx.Logger.Errorf("OIDC login failed: %v", err)
+			ctx.securityEvent(c, securityLogin, logger.SecurityFailure, map[string]interface{}{
+				"auth_method": "oidc",
+				"reason":      "token_exchange_failed",
+			})
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "OIDC login failed",
+			})
+			return
+		}
+
+		issuer, subject := claimString(claims, "iss"), claimString(claims, "sub")
+		if subject == "" {
+			ctx.securityEvent(c, securityLogin, logger.SecurityFailure, map[string]interface{}{
+				"auth_method": "oidc",
+				"reason":      "token_exchange_failed",
+			})
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "OIDC login failed",
+			})
+			return
+		}
+
+		username := claimString(claims, "preferred_username")
+		if username == "" {
+			username = claimString(claims, "email")
+		}
+		if username == "" {
+			username = subject
+		}
+
+		role := ctx.OIDC.mapRole(claimStrings(claims, ctx.OIDC.config.GroupsClaim))
+		if role == "" {
+			ctx.Logger.Warnf("OIDC user %s has no mapped role", username)
+			ctx.securityEvent(c, securityLogin, logger.SecurityFailure, map[string]interface{}{
+				"username":    username,
+				"auth_method": "oidc",
+				"reason":      "no_mapped_role",
+			})
+			c.JSON(http.StatusForbidden, Response{
+				Code:    403,
+				Message: "User is not authorized to access NSA",
+			})
+			return
+		}
+
+		user, err := upsertOIDCUser(ctx, issuer, subject, username, role)
+		if errors.Is(err, errOIDCAccountConflict) {
+			ctx.Logger.Warnf("OIDC user %s (%s) matches an account not linked to this identity", username, subject)
+			ctx.securityEvent(c, securityLogin, logger.SecurityFailure, map[string]interface{}{
+				"username":    username,
+				"auth_method": "oidc",
+				"reason":      "account_conflict",
+			})
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "An account with the same username exists; an admin must link it to this identity",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to save OIDC user: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to save user",
+			})
+			return
+		}
+		if !user.Enabled {
+			ctx.securityEvent(c, securityLogin, logger.SecurityFailure, map[string]interface{}{
+				"username":    user.Username,
+				"auth_method": "oidc",
+				"reason":      "user_disabled",
+			})
+			c.JSON(http.StatusForbidden, Response{
+				Code:    403,
+				Message: "User is disabled",
+			})
+			return
+		}
+
+		response, err := issueTokens(ctx, c, user, "")
+		if err != nil {
+			ctx.Logger.Errorf("Failed to generate JWT: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to generate token",
+			})
+			return
+		}
+
+		ctx.Logger.Infof("User %s logged in via OIDC with role %s", user.Username, user.Role)
+		ctx.securityEvent(c, securityLogin, logger.SecuritySuccess, map[string]interface{}{
+			"username":    user.Username,
+			"role":        user.Role,
+			"auth_method": "oidc",
+			"session_id":  response.SessionID,
+		})
+
+		// 跳转回前端，令牌放在fragment中避免进入服务端日志
+		if redirect := ctx.OIDC.config.PostLoginRedirect; redirect != "" {
+			fragment := url.Values{}
+			fragment.Set("token", response.Token)
+			fragment.Set("expires_at", fmt.Sprint(response.ExpiresAt))
+			fragment.Set("refresh_token", response.RefreshToken)
+			fragment.Set("refresh_expires_at", fmt.Sprint(response.RefreshExpiresAt))
+			c.Redirect(http.StatusFound, redirect+"#"+fragment.Encode())
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Login successful",
+			Data:    response,
+		})
+	}
+}
+
+// exchange 使用授权码换取令牌并校验ID Token
+func (p *OIDCProvider) exchange(ctx context.Context, code, nonce string) (jwt.MapClaims, error) {
+	discovery, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var tokenResp struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if tokenResp.Error != "" {
+		return nil, fmt.Errorf("token endpoint error: %s %s", tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("token response contains no id_token")
+	}
+
+	// 校验ID Token签名、签发者、受众
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokenResp.IDToken, claims, p.keyFunc,
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %v", err)
+	}
+	if claimString(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("id_token nonce mismatch")
+	}
+
+	// ID Token不含组信息时从userinfo补充
+	if _, ok := claims[p.config.GroupsClaim]; !ok && discovery.UserinfoEndpoint != "" && tokenResp.AccessToken != "" {
+		if userinfo, err := p.userinfo(ctx, discovery.UserinfoEndpoint, tokenResp.AccessToken); err == nil {
+			for key, value := range userinfo {
+				if _, exists := claims[key]; !exists {
+					claims[key] = value
+				}
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+// userinfo 获取用户信息
+func (p *OIDCProvider) userinfo(ctx context.Context, endpoint, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("userinfo request failed with status %d", resp.StatusCode)
+	}
+
+	var info map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// mapRole 根据IdP组映射角色，多个组命中时取权限最高的角色
+func (p *OIDCProvider) mapRole(groups []string) string {
+	rank := map[string]int{models.RoleViewer: 1, models.RoleEditor: 2, models.RoleAdmin: 3}
+
+	role := ""
+	for _, group := range groups {
+		mapped := p.config.RoleMapping[group]
+		if rank[mapped] > rank[role] {
+			role = mapped
+		}
+	}
+	if role == "" && isValidRole(p.config.DefaultRole) {
+		role = p.config.DefaultRole
+	}
+	return role
+}
+
+// getDiscovery 获取（并缓存）发现文档
+func (p *OIDCProvider) getDiscovery() (*oidcDiscovery, error) {
+	p.mu.RLock()
+	discovery := p.discovery
+	p.mu.RUnlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	wellKnown := strings.TrimRight(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := p.httpClient.Get(wellKnown)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery request failed with status %d", resp.StatusCode)
+	}
+
+	discovery = &oidcDiscovery{}
+	if err := json.NewDecoder(resp.Body).Decode(discovery); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.discovery = discovery
+	p.mu.Unlock()
+	return discovery, nil
+}
+
+// keyFunc 根据kid返回ID Token的验签公钥，未知kid时刷新JWKS
+func (p *OIDCProvider) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.RLock()
+	key, exists := p.keys[kid]
+	p.mu.RUnlock()
+	if exists {
+		return key, nil
+	}
+
+	if err := p.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if key, exists := p.keys[kid]; exists {
+		return key, nil
+	}
+	// 只有一个密钥且token未声明kid时直接使用
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("signing key %s not found", kid)
+}
+
+// refreshKeys 拉取JWKS
+func (p *OIDCProvider) refreshKeys() error {
+	discovery, err := p.getDiscovery()
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.httpClient.Get(discovery.JWKSURI)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("failed to decode jwks: %v", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range jwks.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	return nil
+}
+
+// errOIDCAccountConflict 同名用户已存在且未关联该OIDC身份
+var errOIDCAccountConflict = errors.New("account is not linked to this OIDC identity")
+
+// upsertOIDCUser 按issuer和sub查找或创建OIDC用户。OIDC用户的角色以IdP组映射为准，
+// 管理员关联的本地用户保留本地角色；同名的本地用户不会被自动关联
+func upsertOIDCUser(ctx *Context, issuer, subject, username, role string) (*models.User, error) {
+	collection := ctx.MongoClient.GetDatabase().Collection("users")
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	identity := bson.M{"oidc_issuer": issuer, "oidc_subject": subject}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// 组映射的角色变化时吊销已有会话，旧令牌中的角色不再有效
+	var user models.User
+	err := collection.FindOneAndUpdate(ctxDB, bson.M{"oidc_issuer": issuer, "oidc_subject": subject, "source": "oidc"},
+		bson.M{"$set": bson.M{"role": role, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&user)
+	if err == nil {
+		if user.Role != role {
+			if err := revokeUserSessions(ctx, user.Username); err != nil {
+				return nil, err
+			}
+			user.Role = role
+		}
+		user.UpdatedAt = now
+		return &user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err := collection.FindOne(ctxDB, identity).Decode(&user); err == nil {
+		return &user, nil
+	} else if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// 关联此前按用户名创建、尚未记录身份的OIDC用户
+	err = collection.FindOneAndUpdate(ctxDB, bson.M{
+		"username":     username,
+		"source":       "oidc",
+		"oidc_subject": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"oidc_issuer": issuer, "oidc_subject": subject, "role": role, "updated_at": now}}, opts).Decode(&user)
+	if err == nil {
+		if err := revokeUserSessions(ctx, user.Username); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	user = models.User{
+		Username:    username,
+		Role:        role,
+		Source:      "oidc",
+		OIDCIssuer:  issuer,
+		OIDCSubject: subject,
+		Enabled:     true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	result, err := collection.InsertOne(ctxDB, user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errOIDCAccountConflict
+		}
+		return nil, err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return &user, nil
+}
+
+// signOIDCState 签名登录state
+func signOIDCState(ctx *Context, state, nonce string) (string, error) {
+	claims := oidcStateClaims{
+		State: state,
+		Nonce: nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Minute)),
+		},
+	}
+	return signToken(ctx, claims)
+}
+
+// parseOIDCState 校验登录state
+func parseOIDCState(ctx *Context, tokenString string) (*oidcStateClaims, error) {
+	claims := &oidcStateClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, ctx.tokenKey, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// claimString 读取字符串claim
+func claimString(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimStrings 读取字符串数组claim（兼容单个字符串）
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var result []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// randomHex 生成随机十六进制字符串
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxBulkWorkflows 单次批量操作的工作流数量上限
+const maxBulkWorkflows = 500
+
+// TransferRequest 工作流转移请求
+type TransferRequest struct {
+	WorkflowIDs []string `json:"workflow_ids"`
+	// FromProject 转移该项目下的所有工作流，与 WorkflowIDs 二选一
+	FromProject string `json:"from_project"`
+	// Owner 新负责人，为空时不修改
+	Owner string `json:"owner"`
+	// Project 新项目，为nil时不修改，为空字符串时移出项目
+	Project *string `json:"project"`
+}
+
+// TransferResult 工作流转移结果
+type TransferResult struct {
+	Transferred []string `json:"transferred"`
+	NotFound    []string `json:"not_found"`
+}
+
+// TransferWorkflows 批量转移工作流的负责人和项目
+//
+// admin 可以转移任意工作流；editor 只能转移自己负责的工作流，请求中有不属于自己的工作流时整个请求被拒绝。
+// 新负责人必须是已启用的 admin 或 editor 用户。
+func TransferWorkflows(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+		if (len(req.WorkflowIDs) == 0) == (req.FromProject == "") {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Exactly one of workflow_ids and from_project is required",
+			})
+			return
+		}
+		if req.Owner == "" && req.Project == nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Owner or project is required",
+			})
+			return
+		}
+		if len(req.WorkflowIDs) > maxBulkWorkflows {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Too many workflows in one request",
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// 新负责人必须可以修改工作流
+		if req.Owner != "" {
+			count, err := ctx.MongoClient.GetDatabase().Collection("users").CountDocuments(ctxDB, bson.M{
+				"username": req.Owner,
+				"enabled":  true,
+				"role":     bson.M{"$in": bson.A{models.RoleAdmin, models.RoleEditor}},
+			})
+			if err != nil {
+				ctx.Logger.Errorf("Failed to find user: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to find user",
+				})
+				return
+			}
+			if count == 0 {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "New owner must be an enabled admin or editor user",
+				})
+				return
+			}
+		}
+
+		// 查询要转移的工作流
+		result := TransferResult{Transferred: []string{}, NotFound: []string{}}
+		filter := bson.M{"project": req.FromProject}
+		if len(req.WorkflowIDs) > 0 {
+			ids := make([]primitive.ObjectID, 0, len(req.WorkflowIDs))
+			for _, id := range req.WorkflowIDs {
+				objectID, err := primitive.ObjectIDFromHex(id)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, Response{
+						Code:    400,
+						Message: "Invalid workflow ID: " + id,
+					})
+					return
+				}
+				ids = append(ids, objectID)
+			}
+			filter = bson.M{"_id": bson.M{"$in": ids}}
+		}
+
+		collection := ctx.MongoClient.GetCollection()
+		cursor, err := collection.Find(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find workflows: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find workflows",
+			})
+			return
+		}
+		var workflows []models.WorkflowConfig
+		if err := cursor.All(ctxDB, &workflows); err != nil {
+			ctx.Logger.Errorf("Failed to decode workflows: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode workflows",
+			})
+			return
+		}
+		if len(workflows) > maxBulkWorkflows {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Too many workflows in one request",
+			})
+			return
+		}
+
+		found := make(map[string]bool, len(workflows))
+		for _, workflow := range workflows {
+			found[workflow.ID.Hex()] = true
+		}
+		for _, id := range req.WorkflowIDs {
+			if !found[id] {
+				result.NotFound = append(result.NotFound, id)
+			}
+		}
+
+		// 权限检查：editor 只能转移自己负责的工作流
+		username := c.GetString("username")
+		if c.GetString("role") != models.RoleAdmin {
+			var forbidden []string
+			for _, workflow := range workflows {
+				if workflow.Owner != username {
+					forbidden = append(forbidden, workflow.ID.Hex())
+				}
+			}
+			if len(forbidden) > 0 {
+				c.JSON(http.StatusForbidden, Response{
+					Code:    403,
+					Message: "Only the owner or an admin can transfer a workflow",
+					Data:    gin.H{"forbidden": forbidden},
+				})
+				return
+			}
+		}
+
+		// 新项目禁用了工作流使用的动作时不转移
+		if req.Project != nil {
+			blocked := gin.H{}
+			for _, workflow := range workflows {
+				if err := ctx.Executor.CheckActions(*req.Project, workflow.DAG.Tasks); err != nil {
+					blocked[workflow.ID.Hex()] = err.Error()
+				}
+			}
+			if len(blocked) > 0 {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Workflows use actions disabled in the target project",
+					Data:    gin.H{"blocked": blocked},
+				})
+				return
+			}
+		}
+
+		// 逐个更新并记录审计日志
+		for _, workflow := range workflows {
+			before := gin.H{"owner": workflow.Owner, "project": workflow.Project}
+			set := bson.M{"updated_at": time.Now()}
+			if req.Owner != "" {
+				set["owner"] = req.Owner
+				workflow.Owner = req.Owner
+			}
+			if req.Project != nil {
+				set["project"] = *req.Project
+				workflow.Project = *req.Project
+			}
+			if _, err := collection.UpdateOne(ctxDB, bson.M{"_id": workflow.ID}, bson.M{"$set": set}); err != nil {
+				ctx.Logger.Errorf("Failed to transfer workflow %s: %v", workflow.ID.Hex(), err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to transfer workflows",
+					Data:    result,
+				})
+				return
+			}
+			ctx.recordAudit(c, auditUpdate, "workflow", workflow.ID.Hex(), workflow.Name,
+				before, gin.H{"owner": workflow.Owner, "project": workflow.Project})
+			result.Transferred = append(result.Transferred, workflow.ID.Hex())
+		}
+
+		ctx.Logger.Infof("%d workflows transferred by %s", len(result.Transferred), username)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Workflows transferred successfully",
+			Data:    result,
+		})
+	}
+}
+
+// RepointRequest 数据源引用替换请求
+type RepointRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// WorkflowIDs 为空时替换所有工作流中的引用
+	WorkflowIDs []string `json:"workflow_ids"`
+	DryRun      bool     `json:"dry_run"`
+}
+
+// RepointedWorkflow 引用被替换的工作流
+type RepointedWorkflow struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	References int    `json:"references"` // 替换的引用数量
+}
+
+// RepointDataSources 将工作流任务和触发器参数中对数据源 from 的引用批量替换为 to，用于数据源改名或迁移
+func RepointDataSources(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RepointRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+		if req.From == "" || req.To == "" || req.From == req.To {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "From and to are required and must differ",
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// 新数据源必须存在；原数据源仍存在时类型必须相同
+		types, err := ctx.datasourceTypes(ctxDB, []string{req.From, req.To})
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find datasources: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find datasources",
+			})
+			return
+		}
+		toType, ok := types[req.To]
+		if !ok {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Datasource " + req.To + " not found",
+			})
+			return
+		}
+		if fromType, ok := types[req.From]; ok && fromType != toType {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Datasource " + req.From + " is " + fromType + " but " + req.To + " is " + toType,
+			})
+			return
+		}
+
+		filter := bson.M{}
+		if len(req.WorkflowIDs) > 0 {
+			ids := make([]primitive.ObjectID, 0, len(req.WorkflowIDs))
+			for _, id := range req.WorkflowIDs {
+				objectID, err := primitive.ObjectIDFromHex(id)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, Response{
+						Code:    400,
+						Message: "Invalid workflow ID: " + id,
+					})
+					return
+				}
+				ids = append(ids, objectID)
+			}
+			filter["_id"] = bson.M{"$in": ids}
+		}
+
+		collection := ctx.MongoClient.GetCollection()
+		cursor, err := collection.Find(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find workflows: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find workflows",
+			})
+			return
+		}
+		var workflows []models.WorkflowConfig
+		if err := cursor.All(ctxDB, &workflows); err != nil {
+			ctx.Logger.Errorf("Failed to decode workflows: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode workflows",
+			})
+			return
+		}
+
+		updated := []RepointedWorkflow{}
+		reload := false
+		for _, workflow := range workflows {
+			count := 0
+			for _, task := range workflow.DAG.Tasks {
+				count += repointParams(task.Params, req.From, req.To)
+			}
+			for _, trigger := range workflow.Triggers {
+				count += repointParams(trigger.Params, req.From, req.To)
+			}
+			if count == 0 {
+				continue
+			}
+			updated = append(updated, RepointedWorkflow{ID: workflow.ID.Hex(), Name: workflow.Name, References: count})
+			if req.DryRun {
+				continue
+			}
+
+			set := bson.M{"dag.tasks": workflow.DAG.Tasks, "triggers": workflow.Triggers, "updated_at": time.Now()}
+			if _, err := collection.UpdateOne(ctxDB, bson.M{"_id": workflow.ID}, bson.M{"$set": set}); err != nil {
+				ctx.Logger.Errorf("Failed to update workflow %s: %v", workflow.ID.Hex(), err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to update workflows",
+					Data:    updated[:len(updated)-1],
+				})
+				return
+			}
+			ctx.recordAudit(c, auditUpdate, "workflow", workflow.ID.Hex(), workflow.Name,
+				gin.H{"datasource": req.From}, gin.H{"datasource": req.To, "references": count})
+			reload = reload || workflow.Enabled
+		}
+
+		if reload {
+			// 清空工作流配置缓存，重新加载触发器
+			ctx.Watcher.Trigger()
+		}
+
+		message := "Datasource references updated successfully"
+		if req.DryRun {
+			message = "Dry run, no workflows changed"
+		} else {
+			ctx.Logger.Infof("Datasource references %s -> %s updated in %d workflows", req.From, req.To, len(updated))
+		}
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: message,
+			Data:    gin.H{"workflows": updated, "dry_run": req.DryRun},
+		})
+	}
+}
+
+// repointParams 将参数中值为 from 的 datasource 参数（包括嵌套的参数）替换为 to，返回替换的数量
+func repointParams(value interface{}, from, to string) int {
+	count := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if name, ok := item.(string); ok && key == "datasource" && name == from {
+				v[key] = to
+				count++
+				continue
+			}
+			count += repointParams(item, from, to)
+		}
+	case primitive.D:
+		for i, elem := range v {
+			if name, ok := elem.Value.(string); ok && elem.Key == "datasource" && name == from {
+				v[i].Value = to
+				count++
+				continue
+			}
+			count += repointParams(elem.Value, from, to)
+		}
+	case []interface{}:
+		for _, item := range v {
+			count += repointParams(item, from, to)
+		}
+	case primitive.A:
+		for _, item := range v {
+			count += repointParams(item, from, to)
+		}
+	}
+	return count
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 角色集合
+var (
+	allRoles    = []string{models.RoleAdmin, models.RoleEditor, models.RoleViewer}
+	writerRoles = []string{models.RoleAdmin, models.RoleEditor}
+	adminRoles  = []string{models.RoleAdmin}
+)
+
+// permissionRule 资源上的一个操作及允许执行的角色
+type permissionRule struct {
+	resource string
+	verb     string
+	roles    []string
+	// condition 非admin角色执行时的附加限制，为空表示没有限制
+	condition string
+}
+
+// permissionRules 权限矩阵，与 server.go 中的 RequireRole 和 WriteAccessMiddleware 保持一致，修改路由权限时同步修改
+var permissionRules = []permissionRule{
+	{resource: "workflows", verb: "read", roles: allRoles},
+	{resource: "workflows", verb: "create", roles: writerRoles},
+	{resource: "workflows", verb: "update", roles: writerRoles},
+	{resource: "workflows", verb: "delete", roles: writerRoles},
+	{resource: "workflows", verb: "trigger", roles: writerRoles},
+	{resource: "workflows", verb: "transfer", roles: writerRoles, condition: "only workflows owned by the current user"},
+	{resource: "workflows", verb: "unmask", roles: adminRoles},
+
+	{resource: "datasources", verb: "read", roles: allRoles},
+	{resource: "datasources", verb: "create", roles: writerRoles},
+	{resource: "datasources", verb: "update", roles: writerRoles},
+	{resource: "datasources", verb: "delete", roles: writerRoles},
+	{resource: "datasources", verb: "test", roles: writerRoles},
+	{resource: "datasources", verb: "repoint", roles: adminRoles},
+
+	{resource: "datasource_aliases", verb: "read", roles: allRoles},
+	{resource: "datasource_aliases", verb: "create", roles: writerRoles},
+	{resource: "datasource_aliases", verb: "update", roles: writerRoles},
+	{resource: "datasource_aliases", verb: "delete", roles: writerRoles},
+
+	{resource: "secrets", verb: "read", roles: allRoles},
+	{resource: "secrets", verb: "create", roles: writerRoles},
+	{resource: "secrets", verb: "update", roles: writerRoles},
+	{resource: "secrets", verb: "delete", roles: writerRoles},
+
+	{resource: "scripts", verb: "read", roles: allRoles},
+	{resource: "scripts", verb: "create", roles: writerRoles},
+	{resource: "scripts", verb: "update", roles: writerRoles},
+	{resource: "scripts", verb: "delete", roles: writerRoles},
+
+	{resource: "connectors", verb: "read", roles: allRoles},
+	{resource: "dashboard", verb: "read", roles: allRoles},
+	{resource: "instances", verb: "read", roles: allRoles},
+	{resource: "instances", verb: "bulk", roles: writerRoles},
+
+	{resource: "approvals", verb: "read", roles: allRoles},
+	{resource: "approvals", verb: "approve", roles: writerRoles, condition: "only approvals listing the current user as an approver, or with no approvers"},
+
+	{resource: "logs", verb: "read", roles: allRoles},
+
+	{resource: "views", verb: "read", roles: allRoles, condition: "only views created by the current user or shared"},
+	{resource: "views", verb: "create", roles: writerRoles},
+	{resource: "views", verb: "update", roles: writerRoles, condition: "only views created by the current user"},
+	{resource: "views", verb: "delete", roles: writerRoles, condition: "only views created by the current user"},
+
+	{resource: "reports", verb: "read", roles: allRoles},
+	{resource: "reports", verb: "create", roles: writerRoles},
+	{resource: "reports", verb: "update", roles: writerRoles},
+	{resource: "reports", verb: "delete", roles: writerRoles},
+	{resource: "reports", verb: "send", roles: writerRoles},
+
+	{resource: "alerts", verb: "read", roles: allRoles},
+	{resource: "alerts", verb: "create", roles: writerRoles},
+	{resource: "alerts", verb: "update", roles: writerRoles},
+	{resource: "alerts", verb: "delete", roles: writerRoles},
+	{resource: "alerts", verb: "test", roles: writerRoles},
+
+	{resource: "nsq", verb: "read", roles: allRoles},
+	{resource: "nsq", verb: "reload", roles: writerRoles},
+
+	// sessions 为当前用户自己的登录会话，其他用户的会话通过 users 管理
+	{resource: "sessions", verb: "read", roles: allRoles},
+	{resource: "sessions", verb: "revoke", roles: allRoles},
+
+	{resource: "users", verb: "read", roles: adminRoles},
+	{resource: "users", verb: "create", roles: adminRoles},
+	{resource: "users", verb: "update", roles: adminRoles},
+	{resource: "users", verb: "delete", roles: adminRoles},
+	{resource: "users", verb: "revoke_sessions", roles: adminRoles},
+
+	{resource: "audit", verb: "read", roles: adminRoles},
+
+	{resource: "system", verb: "read", roles: allRoles},
+	{resource: "system", verb: "cleanup", roles: adminRoles},
+	{resource: "system", verb: "reload", roles: adminRoles},
+	{resource: "system", verb: "reencrypt", roles: adminRoles},
+
+	{resource: "faults", verb: "read", roles: adminRoles},
+	{resource: "faults", verb: "create", roles: adminRoles},
+	{resource: "faults", verb: "delete", roles: adminRoles},
+
+	{resource: "queue", verb: "read", roles: adminRoles},
+	{resource: "queue", verb: "retry", roles: adminRoles},
+	{resource: "queue", verb: "delete", roles: adminRoles},
+}
+
+// PermissionsResponse 当前用户的有效权限
+type PermissionsResponse struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	// Permissions 资源到操作是否允许的映射，包含所有资源和操作
+	Permissions map[string]map[string]bool `json:"permissions"`
+	// Conditions 允许但有附加限制的操作，键为 资源.操作
+	Conditions map[string]string `json:"conditions"`
+}
+
+// rolePermissions 计算角色的权限矩阵
+func rolePermissions(role string) (map[string]map[string]bool, map[string]string) {
+	permissions := make(map[string]map[string]bool)
+	conditions := make(map[string]string)
+	for _, rule := range permissionRules {
+		if permissions[rule.resource] == nil {
+			permissions[rule.resource] = make(map[string]bool)
+		}
+		allowed := slices.Contains(rule.roles, role)
+		permissions[rule.resource][rule.verb] = allowed
+		if allowed && rule.condition != "" && role != models.RoleAdmin {
+			conditions[rule.resource+"."+rule.verb] = rule.condition
+		}
+	}
+	return permissions, conditions
+}
+
+// GetMyPermissions 获取当前用户的权限矩阵，前端据此隐藏无权执行的操作
+func GetMyPermissions(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		permissions, conditions := rolePermissions(role)
+		// 执行数据（任务输入输出、实例变量和结果）的查看权限由 admin.execution_data_roles 配置，无权时返回隐藏后的数据
+		readData := ctx.canViewExecutionData(role)
+		permissions["instances"]["read_data"] = readData
+		permissions["logs"]["read_data"] = readData
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: PermissionsResponse{
+				Username:    c.GetString("username"),
+				Role:        role,
+				Permissions: permissions,
+				Conditions:  conditions,
+			},
+		})
+	}
+}
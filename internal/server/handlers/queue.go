@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nsa/internal/workqueue"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// workQueueDisabled 未启用工作队列时返回404
+func workQueueDisabled(ctx *Context, c *gin.Context) bool {
+	if ctx.WorkQueue != nil {
+		return false
+	}
+	c.JSON(http.StatusNotFound, Response{
+		Code:    404,
+		Message: "Work queue is disabled (work_queue.enabled)",
+	})
+	return true
+}
+
+// queueFilter 从查询参数构建工作队列过滤条件
+func queueFilter(c *gin.Context) (workqueue.Filter, bool) {
+	filter := workqueue.Filter{Status: c.Query("status"), WorkflowID: c.Query("workflow_id")}
+	switch filter.Status {
+	case "", workqueue.StatusPending, workqueue.StatusProcessing, workqueue.StatusFailed:
+	default:
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "Invalid status, expected pending, processing or failed",
+		})
+		return filter, false
+	}
+	return filter, true
+}
+
+// GetWorkQueueStats 获取工作队列统计
+func GetWorkQueueStats(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if workQueueDisabled(ctx, c) {
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		stats, err := ctx.WorkQueue.Stats(ctxDB)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to get work queue stats: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to get work queue stats",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    stats,
+		})
+	}
+}
+
+// ListWorkQueueItems 分页列出工作队列中的记录，支持按状态和工作流过滤
+func ListWorkQueueItems(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if workQueueDisabled(ctx, c) {
+			return
+		}
+
+		var req PaginationRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid query parameters",
+			})
+			return
+		}
+		if req.Page <= 0 {
+			req.Page = 1
+		}
+		if req.PageSize <= 0 {
+			req.PageSize = 50
+		}
+		filter, ok := queueFilter(c)
+		if !ok {
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		items, total, err := ctx.WorkQueue.List(ctxDB, filter, int64((req.Page-1)*req.PageSize), int64(req.PageSize))
+		if err != nil {
+			ctx.Logger.Errorf("Failed to list work queue items: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to list work queue items",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: PaginationResponse{
+				Total:    total,
+				Page:     req.Page,
+				PageSize: req.PageSize,
+				Data:     items,
+			},
+		})
+	}
+}
+
+// PurgeWorkQueue 删除工作队列中符合条件的记录（不包括执行中的记录）
+func PurgeWorkQueue(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if workQueueDisabled(ctx, c) {
+			return
+		}
+		filter, ok := queueFilter(c)
+		if !ok {
+			return
+		}
+		if filter.Status == workqueue.StatusProcessing {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Processing items cannot be purged",
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		deleted, err := ctx.WorkQueue.Purge(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to purge work queue: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to purge work queue",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "work_queue", filter.WorkflowID, filter.Status, filter, map[string]int64{"deleted": deleted})
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Work queue purged",
+			Data:    map[string]int64{"deleted": deleted},
+		})
+	}
+}
+
+// RetryWorkQueueItem 将失败的记录重新放回工作队列
+func RetryWorkQueueItem(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if workQueueDisabled(ctx, c) {
+			return
+		}
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid item ID",
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := ctx.WorkQueue.Retry(ctxDB, id); err == workqueue.ErrNotFound {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Failed work queue item not found",
+			})
+			return
+		} else if err != nil {
+			ctx.Logger.Errorf("Failed to retry work queue item: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to retry work queue item",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditUpdate, "work_queue", id.Hex(), "retry", nil, nil)
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Work queue item requeued",
+		})
+	}
+}
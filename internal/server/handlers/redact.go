@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"slices"
+
+	"nsa/internal/models"
+	"nsa/internal/workflow"
+)
+
+// redactedValue 无权查看执行数据时替换任务输入输出、实例变量和结果的值
+const redactedValue = "[redacted]"
+
+// canViewExecutionData 判断角色能否查看执行数据，未配置 admin.execution_data_roles 时所有角色都可以查看
+//
+// 执行数据包括任务输入输出、实例变量和结果，可能包含客户数据；执行状态、耗时和错误信息不受限制。
+func (ctx *Context) canViewExecutionData(role string) bool {
+	roles := ctx.adminConfig().ExecutionDataRoles
+	return len(roles) == 0 || slices.Contains(roles, role)
+}
+
+// redactValue 隐藏值，nil保持不变以保留是否有值的信息
+func redactValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return redactedValue
+}
+
+// redactExecutionLog 隐藏执行日志的输入输出和控制台输出，log为副本
+func redactExecutionLog(log models.ExecutionLog) models.ExecutionLog {
+	log.Input = redactValue(log.Input)
+	log.Output = redactValue(log.Output)
+	if len(log.Console) > 0 {
+		log.Console = []string{redactedValue}
+	}
+	return log
+}
+
+// redactExecutionLogs 隐藏执行日志列表的输入输出
+func redactExecutionLogs(logs []models.ExecutionLog) {
+	for i := range logs {
+		logs[i] = redactExecutionLog(logs[i])
+	}
+}
+
+// redactInstance 隐藏实例的变量值、任务结果和由消息数据渲染的并发键，保留变量名和任务ID
+func redactInstance(instance *workflow.WorkflowInstance) {
+	instance.Vars = redactMap(instance.Vars)
+	instance.Results = redactMap(instance.Results)
+	if instance.ConcurrencyKey != "" {
+		instance.ConcurrencyKey = redactedValue
+	}
+}
+
+// redactMap 返回隐藏了所有值的副本
+func redactMap(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		result[key] = redactValue(value)
+	}
+	return result
+}
+
+// redactEvent 隐藏执行事件中的执行日志，事件由所有订阅者共享，不修改原日志
+func redactEvent(event workflow.Event) workflow.Event {
+	if log, ok := event.Data.(*models.ExecutionLog); ok {
+		redacted := redactExecutionLog(*log)
+		event.Data = &redacted
+	}
+	return event
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nsa/internal/models"
+	"nsa/internal/report"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReportPreview 报告预览：汇总数据和发送的文本
+type ReportPreview struct {
+	Subject string          `json:"subject"`
+	Text    string          `json:"text"`
+	Summary *report.Summary `json:"summary"`
+}
+
+// ListReports 获取报告计划列表，支持按 project 过滤
+func ListReports(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		if project := c.Query("project"); project != "" {
+			filter["project"] = project
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(report.Collection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := collection.Find(ctxDB, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find reports: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find reports",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		schedules := []models.ReportSchedule{}
+		if err := cursor.All(ctxDB, &schedules); err != nil {
+			ctx.Logger.Errorf("Failed to decode reports: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode reports",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    schedules,
+		})
+	}
+}
+
+// GetReport 获取单个报告计划
+func GetReport(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schedule, ok := ctx.reportByID(c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    schedule,
+		})
+	}
+}
+
+// reportByID 按路径参数读取报告计划
+func (ctx *Context) reportByID(c *gin.Context) (*models.ReportSchedule, bool) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "Invalid report ID",
+		})
+		return nil, false
+	}
+
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var schedule models.ReportSchedule
+	if err := ctx.MongoClient.GetDatabase().Collection(report.Collection).FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&schedule); err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "Report not found",
+		})
+		return nil, false
+	}
+	return &schedule, true
+}
+
+// validateReport 校验报告计划，Webhook 密钥必须已存在；校验失败时写入400响应
+func (ctx *Context) validateReport(c *gin.Context, schedule *models.ReportSchedule) bool {
+	err := ctx.Reports.Validate(schedule)
+	if err == nil && schedule.WebhookSecret != "" {
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = ctx.Secrets.Get(ctxDB, schedule.WebhookSecret)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// CreateReport 创建报告计划
+func CreateReport(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var schedule models.ReportSchedule
+		if err := c.ShouldBindJSON(&schedule); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+		if !ctx.validateReport(c, &schedule) {
+			return
+		}
+
+		now := time.Now()
+		schedule.ID = primitive.NilObjectID
+		schedule.CreatedBy = c.GetString("username")
+		schedule.NextRunAt = report.NextRun(&schedule, now)
+		schedule.LastRunAt = time.Time{}
+		schedule.LastError = ""
+		schedule.CreatedAt = now
+		schedule.UpdatedAt = now
+
+		collection := ctx.MongoClient.GetDatabase().Collection(report.Collection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := collection.InsertOne(ctxDB, schedule)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Report with same name already exists",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to create report: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to create report",
+			})
+			return
+		}
+
+		schedule.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.recordAudit(c, auditCreate, "report", schedule.ID.Hex(), schedule.Name, nil, schedule)
+
+		ctx.Logger.Infof("Report created: %s (%s)", schedule.Name, schedule.Period)
+		c.JSON(http.StatusCreated, Response{
+			Code:    201,
+			Message: "Report created successfully",
+			Data:    schedule,
+		})
+	}
+}
+
+// UpdateReport 更新报告计划，重新计算下一次发送时间
+func UpdateReport(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		original, ok := ctx.reportByID(c)
+		if !ok {
+			return
+		}
+
+		var req models.ReportSchedule
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		updated := *original
+		updated.Name = req.Name
+		updated.Project = req.Project
+		updated.Period = req.Period
+		updated.Hour = req.Hour
+		updated.Weekday = req.Weekday
+		updated.Timezone = req.Timezone
+		updated.Emails = req.Emails
+		updated.WebhookSecret = req.WebhookSecret
+		updated.Enabled = req.Enabled
+		updated.UpdatedAt = time.Now()
+		if !ctx.validateReport(c, &updated) {
+			return
+		}
+		updated.NextRunAt = report.NextRun(&updated, updated.UpdatedAt)
+
+		collection := ctx.MongoClient.GetDatabase().Collection(report.Collection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		set := bson.M{
+			"name":           updated.Name,
+			"project":        updated.Project,
+			"period":         updated.Period,
+			"hour":           updated.Hour,
+			"weekday":        updated.Weekday,
+			"timezone":       updated.Timezone,
+			"emails":         updated.Emails,
+			"webhook_secret": updated.WebhookSecret,
+			"enabled":        updated.Enabled,
+			"next_run_at":    updated.NextRunAt,
+			"updated_at":     updated.UpdatedAt,
+		}
+		_, err := collection.UpdateOne(ctxDB, bson.M{"_id": original.ID}, bson.M{"$set": set})
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Report with same name already exists",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to update report: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update report",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditUpdate, "report", original.ID.Hex(), updated.Name, original, updated)
+
+		ctx.Logger.Infof("Report updated: %s", updated.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Report updated successfully",
+			Data:    updated,
+		})
+	}
+}
+
+// DeleteReport 删除报告计划
+func DeleteReport(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schedule, ok := ctx.reportByID(c)
+		if !ok {
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(report.Collection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := collection.DeleteOne(ctxDB, bson.M{"_id": schedule.ID}); err != nil {
+			ctx.Logger.Errorf("Failed to delete report: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to delete report",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "report", schedule.ID.Hex(), schedule.Name, schedule, nil)
+
+		ctx.Logger.Infof("Report deleted: %s", schedule.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Report deleted successfully",
+		})
+	}
+}
+
+// PreviewReport 生成截止到当前时间的一个周期的报告，不发送
+func PreviewReport(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schedule, ok := ctx.reportByID(c)
+		if !ok {
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		from, to := report.Window(schedule, time.Now())
+		summary, err := report.Generate(ctxDB, ctx.MongoClient, schedule.Project, from, to)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to generate report %s: %v", schedule.Name, err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to generate report",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: ReportPreview{
+				Subject: summary.Subject(schedule.Period),
+				Text:    summary.Text(report.Location(schedule)),
+				Summary: summary,
+			},
+		})
+	}
+}
+
+// SendReport 立即发送截止到当前时间的一个周期的报告，不影响计划的下一次发送时间
+func SendReport(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schedule, ok := ctx.reportByID(c)
+		if !ok {
+			return
+		}
+
+		from, to := report.Window(schedule, time.Now())
+		summary, err := ctx.Reports.Send(context.Background(), schedule, from, to)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to send report %s: %v", schedule.Name, err)
+			c.JSON(http.StatusBadGateway, Response{
+				Code:    502,
+				Message: "Failed to send report: " + err.Error(),
+			})
+			return
+		}
+		ctx.recordAudit(c, auditSend, "report", schedule.ID.Hex(), schedule.Name, nil, nil)
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Report sent successfully",
+			Data:    summary,
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// scriptCollection 脚本模块集合名称
+const scriptCollection = "scripts"
+
+// maxScriptSize 脚本模块代码的最大长度
+const maxScriptSize = 256 << 10
+
+// scriptNamePattern 脚本模块名称，可以用 / 分组（如 lib/format）
+var scriptNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_./-]{0,127}$`)
+
+// InitScripts 创建脚本模块名称唯一索引
+func InitScripts(ctx *Context) error {
+	collection := ctx.MongoClient.GetDatabase().Collection(scriptCollection)
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctxDB, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// validateScript 检查脚本模块的名称和代码，代码有语法错误时不保存
+func (ctx *Context) validateScript(script *models.Script) error {
+	if !scriptNamePattern.MatchString(script.Name) {
+		return fmt.Errorf("name must be 1-128 letters, digits, '_', '.', '-' or '/'")
+	}
+	if script.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	if len(script.Code) > maxScriptSize {
+		return fmt.Errorf("code must not exceed %d bytes", maxScriptSize)
+	}
+	if err := ctx.Executor.ValidateScript(script.Code); err != nil {
+		return fmt.Errorf("invalid code: %v", err)
+	}
+	return nil
+}
+
+// ListScripts 获取脚本模块列表
+func ListScripts(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collection := ctx.MongoClient.GetDatabase().Collection(scriptCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+		cursor, err := collection.Find(ctxDB, bson.M{}, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find scripts: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find scripts",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		scripts := []models.Script{}
+		if err := cursor.All(ctxDB, &scripts); err != nil {
+			ctx.Logger.Errorf("Failed to decode scripts: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode scripts",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    scripts,
+		})
+	}
+}
+
+// GetScript 获取单个脚本模块
+func GetScript(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid script ID",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(scriptCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var script models.Script
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&script); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Script not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    script,
+		})
+	}
+}
+
+// CreateScript 创建脚本模块
+func CreateScript(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var script models.Script
+		if err := c.ShouldBindJSON(&script); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		if err := ctx.validateScript(&script); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		script.ID = primitive.NilObjectID
+		script.CreatedAt = time.Now()
+		script.UpdatedAt = time.Now()
+
+		collection := ctx.MongoClient.GetDatabase().Collection(scriptCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := collection.InsertOne(ctxDB, script)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Script with same name already exists",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to create script: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to create script",
+			})
+			return
+		}
+
+		script.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.Executor.InvalidateScripts()
+		ctx.recordAudit(c, auditCreate, "script", script.ID.Hex(), script.Name, nil, script)
+
+		ctx.Logger.Infof("Script created: %s", script.Name)
+		c.JSON(http.StatusCreated, Response{
+			Code:    201,
+			Message: "Script created successfully",
+			Data:    script,
+		})
+	}
+}
+
+// UpdateScript 更新脚本模块的描述和代码，名称被工作流引用，不允许修改
+func UpdateScript(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid script ID",
+			})
+			return
+		}
+
+		var req models.Script
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(scriptCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var original models.Script
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&original); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Script not found",
+			})
+			return
+		}
+		if req.Name != "" && req.Name != original.Name {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Script name cannot be changed",
+			})
+			return
+		}
+
+		updated := original
+		updated.Description = req.Description
+		updated.Code = req.Code
+		updated.UpdatedAt = time.Now()
+		if err := ctx.validateScript(&updated); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		set := bson.M{
+			"description": updated.Description,
+			"code":        updated.Code,
+			"updated_at":  updated.UpdatedAt,
+		}
+		if _, err := collection.UpdateOne(ctxDB, bson.M{"_id": objectID}, bson.M{"$set": set}); err != nil {
+			ctx.Logger.Errorf("Failed to update script: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update script",
+			})
+			return
+		}
+
+		ctx.Executor.InvalidateScripts()
+		ctx.recordAudit(c, auditUpdate, "script", original.ID.Hex(), original.Name, original, updated)
+
+		ctx.Logger.Infof("Script updated: %s", original.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Script updated successfully",
+			Data:    updated,
+		})
+	}
+}
+
+// DeleteScript 删除脚本模块
+func DeleteScript(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid script ID",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(scriptCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var script models.Script
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&script); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Script not found",
+			})
+			return
+		}
+
+		result, err := collection.DeleteOne(ctxDB, bson.M{"_id": objectID})
+		if err != nil {
+			ctx.Logger.Errorf("Failed to delete script: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to delete script",
+			})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Script not found",
+			})
+			return
+		}
+
+		ctx.Executor.InvalidateScripts()
+		ctx.recordAudit(c, auditDelete, "script", script.ID.Hex(), script.Name, script, nil)
+
+		ctx.Logger.Infof("Script deleted: %s", script.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Script deleted successfully",
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ListSecrets 获取密钥列表（不返回密钥值）
+func ListSecrets(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collection := ctx.Secrets.Collection()
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+		cursor, err := collection.Find(ctxDB, bson.M{}, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find secrets: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find secrets",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		var secrets []models.Secret
+		if err := cursor.All(ctxDB, &secrets); err != nil {
+			ctx.Logger.Errorf("Failed to decode secrets: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode secrets",
+			})
+			return
+		}
+
+		// 隐藏密钥值
+		for i := range secrets {
+			secrets[i].Value = "****"
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    secrets,
+		})
+	}
+}
+
+// CreateSecret 创建密钥
+func CreateSecret(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var secret models.Secret
+		if err := c.ShouldBindJSON(&secret); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		// 验证必填字段
+		if secret.Name == "" || secret.Value == "" {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Name and value are required",
+			})
+			return
+		}
+
+		secret.ID = primitive.NilObjectID
+		secret.CreatedAt = time.Now()
+		secret.UpdatedAt = time.Now()
+
+		collection := ctx.Secrets.Collection()
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		existingCount, err := collection.CountDocuments(ctxDB, bson.M{"name": secret.Name})
+		if err != nil {
+			ctx.Logger.Errorf("Failed to check existing secret: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to check existing secret",
+			})
+			return
+		}
+
+		if existingCount > 0 {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Secret with same name already exists",
+			})
+			return
+		}
+
+		result, err := collection.InsertOne(ctxDB, secret)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to create secret: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to create secret",
+			})
+			return
+		}
+
+		secret.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.recordAudit(c, auditCreate, "secret", secret.ID.Hex(), secret.Name, nil, secret)
+		secret.Value = "****"
+
+		ctx.Logger.Infof("Secret created: %s", secret.Name)
+		c.JSON(http.StatusCreated, Response{
+			Code:    201,
+			Message: "Secret created successfully",
+			Data:    secret,
+		})
+	}
+}
+
+// UpdateSecret 更新密钥
+func UpdateSecret(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid secret ID",
+			})
+			return
+		}
+
+		var req models.Secret
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		set := bson.M{
+			"description": req.Description,
+			"updated_at":  time.Now(),
+		}
+		// 值为空或****时保持原值
+		if req.Value != "" && req.Value != "****" {
+			set["value"] = req.Value
+		}
+
+		collection := ctx.Secrets.Collection()
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var original models.Secret
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&original); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Secret not found",
+			})
+			return
+		}
+
+		if _, err := collection.UpdateOne(ctxDB, bson.M{"_id": objectID}, bson.M{"$set": set}); err != nil {
+			ctx.Logger.Errorf("Failed to update secret: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update secret",
+			})
+			return
+		}
+
+		updated := original
+		updated.Description = req.Description
+		if value, ok := set["value"].(string); ok {
+			updated.Value = value
+		}
+		ctx.recordAudit(c, auditUpdate, "secret", original.ID.Hex(), original.Name, original, updated)
+
+		ctx.Logger.Infof("Secret updated: %s", c.Param("id"))
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Secret updated successfully",
+		})
+	}
+}
+
+// DeleteSecret 删除密钥
+func DeleteSecret(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid secret ID",
+			})
+			return
+		}
+
+		collection := ctx.Secrets.Collection()
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var secret models.Secret
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&secret); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Secret not found",
+			})
+			return
+		}
+
+		result, err := collection.DeleteOne(ctxDB, bson.M{"_id": objectID})
+		if err != nil {
+			ctx.Logger.Errorf("Failed to delete secret: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to delete secret",
+			})
+			return
+		}
+
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Secret not found",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "secret", secret.ID.Hex(), secret.Name, secret, nil)
+
+		ctx.Logger.Infof("Secret deleted: %s", secret.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Secret deleted successfully",
+		})
+	}
+}
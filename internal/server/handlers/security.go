@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"nsa/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 安全事件
+const (
+	securityLogin         = "auth.login"
+	securityRefresh       = "auth.refresh"
+	securityLogout        = "auth.logout"
+	securityTokenRejected = "auth.token_rejected"
+	securityAccessDenied  = "access.denied"
+	securityIngestToken   = "ingest.token"
+)
+
+// securityEvent 记录安全事件，附带客户端IP、User-Agent、请求方法和路径，已认证的请求附带用户名和角色
+func (ctx *Context) securityEvent(c *gin.Context, event, outcome string, fields map[string]interface{}) {
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	fields["ip"] = c.ClientIP()
+	fields["user_agent"] = c.Request.UserAgent()
+	fields["method"] = c.Request.Method
+	fields["path"] = c.Request.URL.Path
+	if username := c.GetString("username"); username != "" {
+		fields["username"] = username
+		fields["role"] = c.GetString("role")
+	}
+	ctx.Security.Event(event, outcome, fields)
+}
+
+// SecurityEventMiddleware 记录被拒绝访问（403）的请求，包括角色校验和资源所有权校验的拒绝
+func SecurityEventMiddleware(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Writer.Status() == http.StatusForbidden {
+			ctx.securityEvent(c, securityAccessDenied, logger.SecurityFailure, nil)
+		}
+	}
+}
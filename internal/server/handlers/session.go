@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sessionTouchInterval 两次更新会话最后使用时间的最小间隔
+const sessionTouchInterval = time.Minute
+
+// maxDeviceLength 保存的User-Agent最大长度
+const maxDeviceLength = 256
+
+// errSessionRevoked 会话已被吊销
+var errSessionRevoked = errors.New("session revoked")
+
+// Session 登录会话，一次登录签发的访问令牌和刷新令牌，刷新后沿用同一个会话
+type Session struct {
+	ID              string     `bson:"_id" json:"id"`
+	Username        string     `bson:"username" json:"username"`
+	AccessJTI       string     `bson:"access_jti" json:"access_jti"`
+	AccessExpiresAt time.Time  `bson:"access_expires_at" json:"access_expires_at"`
+	RefreshJTI      string     `bson:"refresh_jti" json:"-"`
+	Device          string     `bson:"device" json:"device"` // 登录和最近一次刷新时的User-Agent
+	IP              string     `bson:"ip" json:"ip"`
+	CreatedAt       time.Time  `bson:"created_at" json:"created_at"`
+	LastUsedAt      time.Time  `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt       time.Time  `bson:"expires_at" json:"expires_at"` // 刷新令牌过期时间，过期后由MongoDB TTL自动清理
+	RevokedAt       *time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	Current         bool       `bson:"-" json:"current"`
+}
+
+// SessionStore 会话存储，吊销会话时将会话当前的令牌加入吊销列表
+type SessionStore struct {
+	collection  *mongo.Collection
+	revocations *RevocationList
+
+	mu      sync.Mutex
+	touched map[string]time.Time // 会话ID -> 最近一次写入最后使用时间的时间
+}
+
+// NewSessionStore 创建会话存储
+func NewSessionStore(mongoClient *mongodb.Client, revocations *RevocationList) *SessionStore {
+	return &SessionStore{
+		collection:  mongoClient.GetDatabase().Collection("sessions"),
+		revocations: revocations,
+		touched:     make(map[string]time.Time),
+	}
+}
+
+// EnsureIndexes 创建索引
+func (s *SessionStore) EnsureIndexes() error {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.collection.Indexes().CreateMany(ctxDB, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}, {Key: "last_used_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+// Record 记录会话新签发的令牌，会话已被吊销时返回 errSessionRevoked
+func (s *SessionStore) Record(session *Session) error {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	if len(session.Device) > maxDeviceLength {
+		session.Device = session.Device[:maxDeviceLength]
+	}
+	_, err := s.collection.UpdateOne(ctxDB,
+		bson.M{"_id": session.ID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{
+			"$set": bson.M{
+				"access_jti":        session.AccessJTI,
+				"access_expires_at": session.AccessExpiresAt,
+				"refresh_jti":       session.RefreshJTI,
+				"device":            session.Device,
+				"ip":                session.IP,
+				"last_used_at":      now,
+				"expires_at":        session.ExpiresAt,
+			},
+			"$setOnInsert": bson.M{"username": session.Username, "created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	// 已吊销的会话不匹配过滤条件，插入时与已有的_id冲突
+	if mongo.IsDuplicateKeyError(err) {
+		return errSessionRevoked
+	}
+	return err
+}
+
+// Touch 更新会话最后使用时间，同一会话每分钟最多写入一次
+func (s *SessionStore) Touch(id string) {
+	now := time.Now()
+	s.mu.Lock()
+	if last, ok := s.touched[id]; ok && now.Sub(last) < sessionTouchInterval {
+		s.mu.Unlock()
+		return
+	}
+	for sessionID, last := range s.touched {
+		if now.Sub(last) >= sessionTouchInterval {
+			delete(s.touched, sessionID)
+		}
+	}
+	s.touched[id] = now
+	s.mu.Unlock()
+
+	go func() {
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.collection.UpdateOne(ctxDB, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": now}})
+	}()
+}
+
+// List 列出用户未吊销且未过期的会话，按最后使用时间倒序
+func (s *SessionStore) List(ctxDB context.Context, username string) ([]Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+	cursor, err := s.collection.Find(ctxDB, bson.M{
+		"username":   username,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctxDB)
+
+	sessions := []Session{}
+	if err := cursor.All(ctxDB, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Revoke 吊销用户的会话及其当前的访问令牌和刷新令牌，会话不存在或已吊销时返回 mongo.ErrNoDocuments
+func (s *SessionStore) Revoke(ctxDB context.Context, username, id string) (*Session, error) {
+	var session Session
+	err := s.collection.FindOneAndUpdate(ctxDB,
+		bson.M{"_id": id, "username": username, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.revokeTokens(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RevokeAll 吊销用户的所有会话，返回吊销的会话数量
+func (s *SessionStore) RevokeAll(ctxDB context.Context, username string) (int, error) {
+	sessions, err := s.List(ctxDB, username)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, session := range sessions {
+		if _, err := s.Revoke(ctxDB, username, session.ID); err == mongo.ErrNoDocuments {
+			continue
+		} else if err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// End 登出时结束会话，令牌由调用方吊销
+func (s *SessionStore) End(id string) error {
+	if id == "" {
+		return nil
+	}
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := s.collection.UpdateOne(ctxDB,
+		bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
+
+// revokeTokens 将会话当前的访问令牌和刷新令牌加入吊销列表
+func (s *SessionStore) revokeTokens(session *Session) error {
+	if err := s.revocations.Revoke(session.AccessJTI, session.Username, session.AccessExpiresAt); err != nil && err != errTokenRevoked {
+		return err
+	}
+	if err := s.revocations.Revoke(session.RefreshJTI, session.Username, session.ExpiresAt); err != nil && err != errTokenRevoked {
+		return err
+	}
+	return nil
+}
+
+// ListMySessions 获取当前用户的登录会话
+func ListMySessions(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listSessions(ctx, c, c.GetString("username"))
+	}
+}
+
+// RevokeMySession 吊销当前用户的一个登录会话
+func RevokeMySession(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revokeSession(ctx, c, c.GetString("username"), c.Param("id"))
+	}
+}
+
+// ListUserSessions 获取指定用户的登录会话（仅 admin）
+func ListUserSessions(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := sessionUser(ctx, c)
+		if !ok {
+			return
+		}
+		listSessions(ctx, c, user.Username)
+	}
+}
+
+// RevokeUserSession 吊销指定用户的一个登录会话（仅 admin）
+func RevokeUserSession(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := sessionUser(ctx, c)
+		if !ok {
+			return
+		}
+		revokeSession(ctx, c, user.Username, c.Param("session_id"))
+	}
+}
+
+// RevokeUserSessions 吊销指定用户的所有登录会话（仅 admin）
+func RevokeUserSessions(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := sessionUser(ctx, c)
+		if !ok {
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		revoked, err := ctx.Sessions.RevokeAll(ctxDB, user.Username)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to revoke sessions of %s: %v", user.Username, err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to revoke sessions",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "session", user.ID.Hex(), user.Username, nil, map[string]int{"revoked": revoked})
+		ctx.Logger.Infof("Revoked %d sessions of user %s", revoked, user.Username)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Sessions revoked successfully",
+			Data:    map[string]int{"revoked": revoked},
+		})
+	}
+}
+
+// sessionUser 按路径参数读取用户
+func sessionUser(ctx *Context, c *gin.Context) (*models.User, bool) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "Invalid user ID",
+		})
+		return nil, false
+	}
+
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var user models.User
+	if err := ctx.MongoClient.GetDatabase().Collection("users").FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "User not found",
+		})
+		return nil, false
+	}
+	return &user, true
+}
+
+// listSessions 返回用户的会话，标记发起请求的会话
+func listSessions(ctx *Context, c *gin.Context, username string) {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessions, err := ctx.Sessions.List(ctxDB, username)
+	if err != nil {
+		ctx.Logger.Errorf("Failed to list sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "Failed to list sessions",
+		})
+		return
+	}
+	current := c.GetString("session_id")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "Success",
+		Data:    sessions,
+	})
+}
+
+// revokeSession 吊销用户的会话
+func revokeSession(ctx *Context, c *gin.Context, username, id string) {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := ctx.Sessions.Revoke(ctxDB, username, id)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "Session not found",
+		})
+		return
+	}
+	if err != nil {
+		ctx.Logger.Errorf("Failed to revoke session %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "Failed to revoke session",
+		})
+		return
+	}
+
+	ctx.recordAudit(c, auditDelete, "session", session.ID, session.Username, session, nil)
+	ctx.Logger.Infof("Session %s of user %s revoked", session.ID, session.Username)
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "Session revoked successfully",
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"nsa/internal/report"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 执行统计的默认时间范围和最长时间范围
+const (
+	defaultStatsRange = 24 * time.Hour
+	maxStatsRange     = 90 * 24 * time.Hour
+)
+
+// GetWorkflowStats 获取工作流在一段时间内的执行统计：实例成功失败数、执行时间百分位、各任务失败率和趋势
+func GetWorkflowStats(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid workflow ID",
+			})
+			return
+		}
+
+		from, to, bucket, err := parseStatsRange(c, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		err = ctx.MongoClient.GetCollection().FindOne(ctxDB, bson.M{"_id": objectID}).Err()
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Workflow not found",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find workflow: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find workflow",
+			})
+			return
+		}
+
+		stats, err := report.GenerateWorkflowStats(ctxDB, ctx.MongoClient, objectID, from, to, bucket)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to generate workflow stats: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to generate workflow stats",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    stats,
+		})
+	}
+}
+
+// parseStatsRange 解析统计的时间范围和趋势分桶
+//
+// from、to 为 RFC3339 时间，默认为最近24小时，最长90天；bucket 为趋势分桶的时长（如 15m、1h、24h），
+// 默认时间范围不超过2天时为1小时，否则为1天，分桶数不超过 report.MaxTrendBuckets。
+func parseStatsRange(c *gin.Context, now time.Time) (time.Time, time.Time, time.Duration, error) {
+	to := now
+	if value := c.Query("to"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid to time, expected RFC3339")
+		}
+		to = t
+	}
+	from := to.Add(-defaultStatsRange)
+	if value := c.Query("from"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid from time, expected RFC3339")
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > maxStatsRange {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("time range must not exceed %d days", int(maxStatsRange/(24*time.Hour)))
+	}
+
+	bucket := time.Hour
+	if to.Sub(from) > 48*time.Hour {
+		bucket = 24 * time.Hour
+	}
+	if value := c.Query("bucket"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < time.Minute {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid bucket %q, expected a duration of at least 1m such as 15m or 1h", value)
+		}
+		bucket = d
+	}
+	if buckets := to.Sub(from) / bucket; buckets >= report.MaxTrendBuckets {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("bucket %s is too small for the time range, at most %d buckets are allowed", bucket, report.MaxTrendBuckets)
+	}
+	return from.UTC(), to.UTC(), bucket, nil
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"nsa/internal/logger"
+	"nsa/internal/mongodb"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// streamTicketTTL 流式接口票据的有效期
+const streamTicketTTL = 30 * time.Second
+
+// StreamTicket 流式接口的一次性票据，浏览器的WebSocket/EventSource无法设置请求头时代替访问令牌放在URL中
+type StreamTicket struct {
+	ID        string    `bson:"_id"` // 票据的SHA-256，数据库中不保存票据本身
+	Username  string    `bson:"username"`
+	Role      string    `bson:"role"`
+	Timezone  string    `bson:"timezone,omitempty"`
+	SessionID string    `bson:"session_id,omitempty"`
+	TokenID   string    `bson:"token_id"` // 签发票据的访问令牌，令牌被吊销后票据失效
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// StreamTicketResponse 签发票据响应
+type StreamTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// TicketStore 流式接口票据存储，集群中任一节点签发的票据可以在其他节点使用
+type TicketStore struct {
+	collection *mongo.Collection
+}
+
+// NewTicketStore 创建票据存储
+func NewTicketStore(mongoClient *mongodb.Client) *TicketStore {
+	return &TicketStore{collection: mongoClient.GetDatabase().Collection("stream_tickets")}
+}
+
+// EnsureIndexes 创建索引，票据过期后由MongoDB TTL自动清理
+func (s *TicketStore) EnsureIndexes() error {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.collection.Indexes().CreateOne(ctxDB, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Issue 为访问令牌签发票据
+func (s *TicketStore) Issue(ctxDB context.Context, claims *JWTClaims) (string, time.Time, error) {
+	ticket := randomHex(32)
+	expiresAt := time.Now().Add(streamTicketTTL)
+	_, err := s.collection.InsertOne(ctxDB, StreamTicket{
+		ID:        ticketHash(ticket),
+		Username:  claims.Username,
+		Role:      claims.Role,
+		Timezone:  claims.Timezone,
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return ticket, expiresAt, nil
+}
+
+// Redeem 使用票据，票据只能使用一次，不存在或已过期时返回 mongo.ErrNoDocuments
+func (s *TicketStore) Redeem(ctxDB context.Context, ticket string) (*StreamTicket, error) {
+	var record StreamTicket
+	err := s.collection.FindOneAndDelete(ctxDB, bson.M{
+		"_id":        ticketHash(ticket),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&record)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ticketHash 票据的SHA-256
+func ticketHash(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueStreamTicket 为当前访问令牌签发流式接口的一次性票据
+func IssueStreamTicket(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := validateJWT(ctx, strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, Response{
+				Code:    401,
+				Message: "Invalid or expired token",
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		ticket, expiresAt, err := ctx.StreamTickets.Issue(ctxDB, claims)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to issue stream ticket: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to issue stream ticket",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: StreamTicketResponse{
+				Ticket:    ticket,
+				ExpiresAt: expiresAt.Unix(),
+			},
+		})
+	}
+}
+
+// redeemStreamTicket 使用请求中的票据认证，成功时将用户信息写入上下文
+func redeemStreamTicket(ctx *Context, c *gin.Context, ticket string) bool {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	record, err := ctx.StreamTickets.Redeem(ctxDB, ticket)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			ctx.Logger.Errorf("Failed to redeem stream ticket: %v", err)
+		}
+		ctx.securityEvent(c, securityTokenRejected, logger.SecurityFailure, map[string]interface{}{"reason": "invalid_ticket"})
+		return false
+	}
+	if ctx.Revocations.IsRevoked(record.TokenID) {
+		ctx.securityEvent(c, securityTokenRejected, logger.SecurityFailure, map[string]interface{}{
+			"username":   record.Username,
+			"session_id": record.SessionID,
+			"token_id":   record.TokenID,
+			"reason":     "revoked_token",
+		})
+		return false
+	}
+
+	c.Set("username", record.Username)
+	c.Set("role", record.Role)
+	if record.Timezone != "" {
+		c.Set("timezone", record.Timezone)
+	}
+	if record.SessionID != "" {
+		c.Set("session_id", record.SessionID)
+		ctx.Sessions.Touch(record.SessionID)
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timezoneHeader 指定本次请求响应时区的请求头，优先级低于 tz 查询参数
+const timezoneHeader = "X-Timezone"
+
+// responseTimeLayout API响应中时间的格式：RFC3339，固定毫秒精度
+const responseTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// locations 已加载的时区，避免每个请求读取时区数据库
+var locations sync.Map
+
+// loadLocation 按IANA名称加载时区，结果缓存
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// validTimezone 判断是否为合法的时区名称，空字符串表示使用默认时区
+func validTimezone(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, err := loadLocation(name)
+	return err == nil
+}
+
+// responseLocation 返回响应使用的时区：tz 查询参数、X-Timezone 请求头、用户设置的时区、admin.timezone，默认UTC
+func (ctx *Context) responseLocation(c *gin.Context) *time.Location {
+	for _, name := range []string{c.Query("tz"), c.GetHeader(timezoneHeader), c.GetString("timezone"), ctx.adminConfig().Timezone} {
+		if name == "" {
+			continue
+		}
+		if loc, err := loadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// TimeFormatMiddleware 将JSON响应中的时间统一为指定时区的RFC3339格式（毫秒精度）
+//
+// JSON响应先写入缓冲，处理器返回后改写其中的时间字符串再发送；SSE、WebSocket 等非JSON响应直接发送，不做处理。
+// 请求指定的时区不合法时返回400。
+func TimeFormatMiddleware(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, name := range []string{c.Query("tz"), c.GetHeader(timezoneHeader)} {
+			if !validTimezone(name) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid timezone: " + name,
+				})
+				return
+			}
+		}
+
+		writer := &timeFormatWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.buffering {
+			body := formatJSONTimes(writer.buf.Bytes(), ctx.responseLocation(c))
+			if _, err := writer.ResponseWriter.Write(body); err != nil {
+				ctx.Logger.Debugf("Failed to write response: %v", err)
+			}
+		}
+	}
+}
+
+// timeFormatWriter 缓冲JSON响应体，其他响应直接写出
+type timeFormatWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	decided   bool
+	buffering bool
+}
+
+// Write 第一次写入时按 Content-Type 决定是否缓冲
+func (w *timeFormatWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.buffering = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+	if w.buffering {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString 同 Write
+func (w *timeFormatWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 缓冲JSON响应时不提前发送
+func (w *timeFormatWriter) Flush() {
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// formatJSONTimes 将JSON中值为RFC3339时间的字符串转换为指定时区，对象的键和其他内容保持不变
+func formatJSONTimes(body []byte, loc *time.Location) []byte {
+	var out bytes.Buffer
+	out.Grow(len(body))
+	for i := 0; i < len(body); i++ {
+		if body[i] != '"' {
+			out.WriteByte(body[i])
+			continue
+		}
+		// 找到字符串结尾，跳过转义字符
+		end := i + 1
+		escaped := false
+		for end < len(body) && body[end] != '"' {
+			if body[end] == '\\' {
+				escaped = true
+				end++
+			}
+			end++
+		}
+		if end >= len(body) {
+			out.Write(body[i:])
+			break
+		}
+		str := body[i+1 : end]
+		if !escaped && !isObjectKey(body, end+1) {
+			if formatted, ok := formatTimeString(string(str), loc); ok {
+				out.WriteByte('"')
+				out.WriteString(formatted)
+				out.WriteByte('"')
+				i = end
+				continue
+			}
+		}
+		out.Write(body[i : end+1])
+		i = end
+	}
+	return out.Bytes()
+}
+
+// isObjectKey 判断字符串之后的第一个非空白字符是否为冒号，即该字符串是对象的键
+func isObjectKey(body []byte, pos int) bool {
+	for ; pos < len(body); pos++ {
+		switch body[pos] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case ':':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// formatTimeString 将RFC3339时间转换为指定时区，零值时间保持UTC
+func formatTimeString(s string, loc *time.Location) (string, bool) {
+	// 快速排除：RFC3339时间至少20个字符，形如 2006-01-02T15:04:05Z
+	if len(s) < 20 || len(s) > 40 || s[4] != '-' || s[10] != 'T' {
+		return "", false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return "", false
+	}
+	if t.IsZero() {
+		return t.UTC().Format(responseTimeLayout), true
+	}
+	return t.In(loc).Format(responseTimeLayout), true
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"nsa/internal/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// revocationCheckTTL 令牌未被吊销的查询结果在内存中缓存的时间，其他节点吊销的令牌最迟在此时间后失效
+const revocationCheckTTL = 30 * time.Second
+
+// errTokenRevoked 令牌此前已被吊销
+var errTokenRevoked = errors.New("token already revoked")
+
+// RevokedToken 已吊销的令牌
+type RevokedToken struct {
+	JTI       string    `bson:"jti" json:"jti"`
+	Username  string    `bson:"username" json:"username"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+	RevokedAt time.Time `bson:"revoked_at" json:"revoked_at"`
+}
+
+// RevocationList 令牌吊销列表，持久化到MongoDB并在内存中缓存
+type RevocationList struct {
+	mu         sync.RWMutex
+	cache      map[string]time.Time // jti -> 过期时间
+	checked    map[string]time.Time // 未被吊销的jti -> 缓存失效时间
+	prunedAt   time.Time
+	collection *mongo.Collection
+}
+
+// NewRevocationList 创建令牌吊销列表
+func NewRevocationList(mongoClient *mongodb.Client) *RevocationList {
+	return &RevocationList{
+		cache:      make(map[string]time.Time),
+		checked:    make(map[string]time.Time),
+		collection: mongoClient.GetDatabase().Collection("revoked_tokens"),
+	}
+}
+
+// EnsureIndexes 创建索引，令牌过期后由MongoDB TTL自动清理
+func (r *RevocationList) EnsureIndexes() error {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.Indexes().CreateMany(ctxDB, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "jti", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+// Revoke 吊销令牌，令牌此前已被吊销时返回 errTokenRevoked。
+// 吊销记录以jti唯一索引插入，并发吊销同一令牌时只有一个调用成功，刷新令牌据此保证只能使用一次
+func (r *RevocationList) Revoke(jti, username string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	r.cache[jti] = expiresAt
+	delete(r.checked, jti)
+	r.pruneLocked()
+	r.mu.Unlock()
+
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctxDB, RevokedToken{
+		JTI:       jti,
+		Username:  username,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return errTokenRevoked
+	}
+	return err
+}
+
+// IsRevoked 判断令牌是否已被吊销，未被吊销的结果缓存 revocationCheckTTL
+func (r *RevocationList) IsRevoked(jti string) bool {
+	if jti == "" {
+		return false
+	}
+
+	now := time.Now()
+	r.mu.RLock()
+	expiresAt, exists := r.cache[jti]
+	checkedUntil, checked := r.checked[jti]
+	r.mu.RUnlock()
+	if exists {
+		return now.Before(expiresAt)
+	}
+	if checked && now.Before(checkedUntil) {
+		return false
+	}
+
+	ctxDB, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var revoked RevokedToken
+	err := r.collection.FindOne(ctxDB, bson.M{"jti": jti}).Decode(&revoked)
+	if err == mongo.ErrNoDocuments {
+		r.mu.Lock()
+		if _, exists := r.cache[jti]; !exists {
+			r.checked[jti] = now.Add(revocationCheckTTL)
+		}
+		r.pruneLocked()
+		r.mu.Unlock()
+		return false
+	}
+	if err != nil {
+		// 无法确认状态时拒绝访问
+		return true
+	}
+
+	r.mu.Lock()
+	r.cache[jti] = revoked.ExpiresAt
+	r.mu.Unlock()
+	return true
+}
+
+// pruneLocked 清理内存中已过期的吊销记录和查询结果，每 revocationCheckTTL 最多清理一次，调用方需持有写锁
+func (r *RevocationList) pruneLocked() {
+	now := time.Now()
+	if now.Sub(r.prunedAt) < revocationCheckTTL {
+		return
+	}
+	r.prunedAt = now
+	for jti, expiresAt := range r.cache {
+		if now.After(expiresAt) {
+			delete(r.cache, jti)
+		}
+	}
+	for jti, checkedUntil := range r.checked {
+		if now.After(checkedUntil) {
+			delete(r.checked, jti)
+		}
+	}
+}
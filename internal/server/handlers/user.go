@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength 密码最小长度
+const minPasswordLength = 8
+
+// UserRequest 创建/更新用户请求
+type UserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	Enabled  *bool  `json:"enabled"`
+	// Timezone API响应中时间的时区，更新时为空字符串表示清除、未设置表示不修改
+	Timezone *string `json:"timezone"`
+	// OIDCSubject 关联的OIDC身份（IdP的sub），关联后该身份通过OIDC登录为此用户；
+	// 更新时为空字符串表示取消关联、未设置表示不修改，需要启用 admin.oidc
+	OIDCSubject *string `json:"oidc_subject"`
+}
+
+// InitUsers 初始化用户集合，用户为空时根据配置创建默认管理员
+func InitUsers(ctx *Context) error {
+	collection := ctx.MongoClient.GetDatabase().Collection("users")
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 用户名唯一索引
+	_, err := collection.Indexes().CreateOne(ctxDB, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// OIDC身份唯一索引
+	_, err = collection.Indexes().CreateOne(ctxDB, mongo.IndexModel{
+		Keys: bson.D{{Key: "oidc_issuer", Value: 1}, {Key: "oidc_subject", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"oidc_subject": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	count, err := collection.CountDocuments(ctxDB, bson.M{})
+	if err != nil {
+		return err
+	}
+	if count > 0 || ctx.Config.Admin.Username == "" {
+		return nil
+	}
+
+	// 配置中的密码可能是明文，也可能已经是bcrypt哈希
+	hash := ctx.Config.Admin.Password
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(hash), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		hash = string(hashed)
+	}
+
+	admin := models.User{
+		Username:  ctx.Config.Admin.Username,
+		Password:  hash,
+		Role:      models.RoleAdmin,
+		Source:    "local",
+		Enabled:   true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if _, err := collection.InsertOne(ctxDB, admin); err != nil {
+		return err
+	}
+
+	ctx.Logger.Infof("Default admin user created: %s", admin.Username)
+	return nil
+}
+
+// ListUsers 获取用户列表
+func ListUsers(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collection := ctx.MongoClient.GetDatabase().Collection("users")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// 构建查询条件
+		filter := bson.M{}
+		if role := c.Query("role"); role != "" {
+			filter["role"] = role
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "username", Value: 1}})
+		cursor, err := collection.Find(ctxDB, filter, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find users: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find users",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		var users []models.User
+		if err := cursor.All(ctxDB, &users); err != nil {
+			ctx.Logger.Errorf("Failed to decode users: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode users",
+			})
+			return
+		}
+
+		// 隐藏密码字段
+		for i := range users {
+			users[i].Password = ""
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    users,
+		})
+	}
+}
+
+// GetUser 获取单个用户
+func GetUser(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid user ID",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("users")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var user models.User
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "User not found",
+			})
+			return
+		}
+
+		// 隐藏密码字段
+		user.Password = ""
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    user,
+		})
+	}
+}
+
+// CreateUser 创建用户
+func CreateUser(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		// 验证必填字段
+		req.Username = strings.TrimSpace(req.Username)
+		if req.Username == "" || req.Password == "" {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Username and password are required",
+			})
+			return
+		}
+		if len(req.Password) < minPasswordLength {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Password must be at least 8 characters",
+			})
+			return
+		}
+		if req.Role == "" {
+			req.Role = models.RoleViewer
+		}
+		if !isValidRole(req.Role) {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid role",
+			})
+			return
+		}
+		if req.Timezone != nil && !validTimezone(*req.Timezone) {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid timezone",
+			})
+			return
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to hash password: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to create user",
+			})
+			return
+		}
+
+		user := models.User{
+			Username:  req.Username,
+			Password:  string(hash),
+			Role:      req.Role,
+			Source:    "local",
+			Enabled:   true,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if req.Enabled != nil {
+			user.Enabled = *req.Enabled
+		}
+		if req.Timezone != nil {
+			user.Timezone = *req.Timezone
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("users")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := collection.InsertOne(ctxDB, user)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, Response{
+					Code:    409,
+					Message: "User with same username already exists",
+				})
+				return
+			}
+			ctx.Logger.Errorf("Failed to create user: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to create user",
+			})
+			return
+		}
+
+		user.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.recordAudit(c, auditCreate, "user", user.ID.Hex(), user.Username, nil, user)
+		user.Password = ""
+
+		ctx.Logger.Infof("User created: %s (%s)", user.Username, user.Role)
+		c.JSON(http.StatusCreated, Response{
+			Code:    201,
+			Message: "User created successfully",
+			Data:    user,
+		})
+	}
+}
+
+// UpdateUser 更新用户（角色、密码、启用状态、时区、关联的OIDC身份）
+func UpdateUser(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid user ID",
+			})
+			return
+		}
+
+		var req UserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("users")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var user models.User
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "User not found",
+			})
+			return
+		}
+
+		before := user
+		set := bson.M{"updated_at": time.Now()}
+		update := bson.M{"$set": set}
+
+		if req.Role != "" && req.Role != user.Role {
+			if !isValidRole(req.Role) {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid role",
+				})
+				return
+			}
+			set["role"] = req.Role
+		}
+		if req.Enabled != nil {
+			set["enabled"] = *req.Enabled
+		}
+		if req.Timezone != nil {
+			if !validTimezone(*req.Timezone) {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid timezone",
+				})
+				return
+			}
+			set["timezone"] = *req.Timezone
+		}
+		if req.Password != "" {
+			if len(req.Password) < minPasswordLength {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Password must be at least 8 characters",
+				})
+				return
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				ctx.Logger.Errorf("Failed to hash password: %v", err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "Failed to update user",
+				})
+				return
+			}
+			set["password"] = string(hash)
+		}
+		if req.OIDCSubject != nil {
+			if *req.OIDCSubject == "" {
+				update["$unset"] = bson.M{"oidc_issuer": "", "oidc_subject": ""}
+			} else {
+				if ctx.OIDC == nil {
+					c.JSON(http.StatusBadRequest, Response{
+						Code:    400,
+						Message: "OIDC login is not enabled",
+					})
+					return
+				}
+				discovery, err := ctx.OIDC.getDiscovery()
+				if err != nil {
+					ctx.Logger.Errorf("Failed to load OIDC discovery document: %v", err)
+					c.JSON(http.StatusBadGateway, Response{
+						Code:    502,
+						Message: "Failed to contact identity provider",
+					})
+					return
+				}
+				set["oidc_issuer"] = discovery.Issuer
+				set["oidc_subject"] = *req.OIDCSubject
+			}
+		}
+
+		// 不允许移除最后一个启用的管理员
+		demoted := set["role"] != nil && user.Role == models.RoleAdmin
+		disabled := req.Enabled != nil && !*req.Enabled && user.Role == models.RoleAdmin
+		if demoted || disabled {
+			if last, err := isLastAdmin(ctxDB, ctx, objectID); err != nil || last {
+				c.JSON(http.StatusConflict, Response{
+					Code:    409,
+					Message: "Cannot demote or disable the last admin user",
+				})
+				return
+			}
+		}
+
+		if _, err := collection.UpdateOne(ctxDB, bson.M{"_id": objectID}, update); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, Response{
+					Code:    409,
+					Message: "OIDC identity is already linked to another user",
+				})
+				return
+			}
+			ctx.Logger.Errorf("Failed to update user: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update user",
+			})
+			return
+		}
+
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&user); err != nil {
+			ctx.Logger.Errorf("Failed to reload user: %v", err)
+		}
+		ctx.recordAudit(c, auditUpdate, "user", user.ID.Hex(), user.Username, before, user)
+		user.Password = ""
+
+		// 角色、密码、关联的OIDC身份变更或禁用后，已签发的令牌不再有效
+		if set["role"] != nil || set["password"] != nil || req.OIDCSubject != nil || (req.Enabled != nil && !*req.Enabled) {
+			if err := revokeUserSessions(ctx, user.Username); err != nil {
+				ctx.Logger.Errorf("Failed to revoke sessions of %s: %v", user.Username, err)
+				c.JSON(http.StatusInternalServerError, Response{
+					Code:    500,
+					Message: "User updated but failed to revoke sessions",
+				})
+				return
+			}
+		}
+
+		ctx.Logger.Infof("User updated: %s", user.Username)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "User updated successfully",
+			Data:    user,
+		})
+	}
+}
+
+// DeleteUser 删除用户
+func DeleteUser(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid user ID",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection("users")
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var user models.User
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "User not found",
+			})
+			return
+		}
+
+		// 不允许删除自己
+		if username, _ := c.Get("username"); username == user.Username {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Cannot delete the current user",
+			})
+			return
+		}
+
+		if user.Role == models.RoleAdmin {
+			if last, err := isLastAdmin(ctxDB, ctx, objectID); err != nil || last {
+				c.JSON(http.StatusConflict, Response{
+					Code:    409,
+					Message: "Cannot delete the last admin user",
+				})
+				return
+			}
+		}
+
+		if _, err := collection.DeleteOne(ctxDB, bson.M{"_id": objectID}); err != nil {
+			ctx.Logger.Errorf("Failed to delete user: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to delete user",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "user", user.ID.Hex(), user.Username, user, nil)
+
+		if err := revokeUserSessions(ctx, user.Username); err != nil {
+			ctx.Logger.Errorf("Failed to revoke sessions of %s: %v", user.Username, err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "User deleted but failed to revoke sessions",
+			})
+			return
+		}
+
+		ctx.Logger.Infof("User deleted: %s", user.Username)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "User deleted successfully",
+		})
+	}
+}
+
+// findUser 根据用户名查找用户
+func findUser(ctx *Context, username string) (*models.User, error) {
+	collection := ctx.MongoClient.GetDatabase().Collection("users")
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := collection.FindOne(ctxDB, bson.M{"username": username}).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// revokeUserSessions 吊销用户的所有登录会话，令牌中的角色等信息变更后需要重新登录
+func revokeUserSessions(ctx *Context, username string) error {
+	ctxDB, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	revoked, err := ctx.Sessions.RevokeAll(ctxDB, username)
+	if err != nil {
+		return err
+	}
+	if revoked > 0 {
+		ctx.Logger.Infof("Revoked %d sessions of user %s", revoked, username)
+	}
+	return nil
+}
+
+// isLastAdmin 判断除指定用户外是否还有启用的管理员
+func isLastAdmin(ctxDB context.Context, ctx *Context, exclude primitive.ObjectID) (bool, error) {
+	collection := ctx.MongoClient.GetDatabase().Collection("users")
+	count, err := collection.CountDocuments(ctxDB, bson.M{
+		"_id":     bson.M{"$ne": exclude},
+		"role":    models.RoleAdmin,
+		"enabled": true,
+	})
+	if err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// isValidRole 校验角色
+func isValidRole(role string) bool {
+	switch role {
+	case models.RoleAdmin, models.RoleEditor, models.RoleViewer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// viewCollection 保存视图集合名称
+const viewCollection = "saved_views"
+
+// viewFilterKeys 各列表支持保存的过滤参数
+var viewFilterKeys = map[string][]string{
+	"instances": {"workflow_id", "status", "node", "since"},
+	"logs":      {"workflow_id", "instance_id", "status", "since"},
+}
+
+// InitViews 创建保存视图索引，同一用户在同一列表下的视图名称唯一
+func InitViews(ctx *Context) error {
+	collection := ctx.MongoClient.GetDatabase().Collection(viewCollection)
+	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctxDB, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "resource", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// visibleViews 当前用户可见的视图（$or 条件）：自己创建的和共享的
+func visibleViews(c *gin.Context) bson.A {
+	return bson.A{
+		bson.M{"owner": c.GetString("username")},
+		bson.M{"shared": true},
+	}
+}
+
+// validateView 检查视图的名称、列表和过滤参数
+func validateView(view *models.SavedView) error {
+	if strings.TrimSpace(view.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	keys, ok := viewFilterKeys[view.Resource]
+	if !ok {
+		return fmt.Errorf("resource must be one of %s", strings.Join(viewResources(), ", "))
+	}
+	for key, value := range view.Filters {
+		if !slices.Contains(keys, key) {
+			return fmt.Errorf("unsupported filter %s for %s, expected one of %s", key, view.Resource, strings.Join(keys, ", "))
+		}
+		if key == "since" {
+			if _, err := parseSince(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseSince 解析 since 过滤参数：相对当前时间的时长，如 30m、24h
+func parseSince(value string) (time.Duration, error) {
+	since, err := time.ParseDuration(value)
+	if err != nil || since <= 0 {
+		return 0, fmt.Errorf("invalid since %q, expected a positive duration such as 30m or 24h", value)
+	}
+	return since, nil
+}
+
+// listQuery 列表的过滤参数：请求中的参数优先，其次为 view 参数指定的保存视图中的值
+type listQuery struct {
+	c       *gin.Context
+	filters map[string]string
+}
+
+// get 返回过滤参数
+func (q listQuery) get(key string) string {
+	if value, ok := q.c.GetQuery(key); ok {
+		return value
+	}
+	return q.filters[key]
+}
+
+// since 返回 since 参数对应的起始时间，没有设置时返回零值
+func (q listQuery) since() (time.Time, error) {
+	value := q.get("since")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	since, err := parseSince(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-since), nil
+}
+
+// listQueryFor 读取列表的过滤参数，view 参数为保存视图的ID或名称；视图不存在或不适用于该列表时返回404
+func (ctx *Context) listQueryFor(c *gin.Context, resource string) (listQuery, bool) {
+	query := listQuery{c: c}
+	ref := c.Query("view")
+	if ref == "" {
+		return query, true
+	}
+
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	view, err := ctx.findView(ctxDB, c, resource, ref)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "View not found: " + ref,
+		})
+		return query, false
+	}
+	query.filters = view.Filters
+	return query, true
+}
+
+// findView 按ID或名称查找当前用户可见的视图，同名时优先使用自己创建的视图，其次为最早创建的共享视图
+func (ctx *Context) findView(ctxDB context.Context, c *gin.Context, resource, ref string) (*models.SavedView, error) {
+	collection := ctx.MongoClient.GetDatabase().Collection(viewCollection)
+	filter := bson.M{"resource": resource, "name": ref}
+	if objectID, err := primitive.ObjectIDFromHex(ref); err == nil {
+		filter = bson.M{"resource": resource, "_id": objectID}
+	}
+	filter["$or"] = visibleViews(c)
+
+	cursor, err := collection.Find(ctxDB, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctxDB)
+	var views []models.SavedView
+	if err := cursor.All(ctxDB, &views); err != nil {
+		return nil, err
+	}
+	if len(views) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	username := c.GetString("username")
+	for i := range views {
+		if views[i].Owner == username {
+			return &views[i], nil
+		}
+	}
+	return &views[0], nil
+}
+
+// canModifyView 视图只能由创建者或 admin 修改和删除
+func canModifyView(c *gin.Context, view *models.SavedView) bool {
+	return view.Owner == c.GetString("username") || c.GetString("role") == models.RoleAdmin
+}
+
+// ListViews 获取当前用户可见的视图，支持 resource 过滤
+func ListViews(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collection := ctx.MongoClient.GetDatabase().Collection(viewCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{"$or": visibleViews(c)}
+		if resource := c.Query("resource"); resource != "" {
+			filter["resource"] = resource
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "resource", Value: 1}, {Key: "name", Value: 1}})
+		cursor, err := collection.Find(ctxDB, filter, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find views: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find views",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		views := []models.SavedView{}
+		if err := cursor.All(ctxDB, &views); err != nil {
+			ctx.Logger.Errorf("Failed to decode views: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode views",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    views,
+		})
+	}
+}
+
+// GetView 获取单个视图
+func GetView(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		view, ok := ctx.viewByID(c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data:    view,
+		})
+	}
+}
+
+// viewByID 按路径参数读取当前用户可见的视图，admin 可以读取所有视图
+func (ctx *Context) viewByID(c *gin.Context) (*models.SavedView, bool) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "Invalid view ID",
+		})
+		return nil, false
+	}
+
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	filter := bson.M{"_id": objectID}
+	if c.GetString("role") != models.RoleAdmin {
+		filter["$or"] = visibleViews(c)
+	}
+	var view models.SavedView
+	if err := ctx.MongoClient.GetDatabase().Collection(viewCollection).FindOne(ctxDB, filter).Decode(&view); err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "View not found",
+		})
+		return nil, false
+	}
+	return &view, true
+}
+
+// CreateView 创建视图，创建者为当前用户
+func CreateView(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var view models.SavedView
+		if err := c.ShouldBindJSON(&view); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+		if err := validateView(&view); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		view.ID = primitive.NilObjectID
+		view.Owner = c.GetString("username")
+		view.CreatedAt = time.Now()
+		view.UpdatedAt = time.Now()
+		if view.Filters == nil {
+			view.Filters = map[string]string{}
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(viewCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		result, err := collection.InsertOne(ctxDB, view)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "View with same name already exists",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to create view: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to create view",
+			})
+			return
+		}
+
+		view.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.recordAudit(c, auditCreate, "view", view.ID.Hex(), view.Name, nil, view)
+
+		ctx.Logger.Infof("View created: %s/%s by %s", view.Resource, view.Name, view.Owner)
+		c.JSON(http.StatusCreated, Response{
+			Code:    201,
+			Message: "View created successfully",
+			Data:    view,
+		})
+	}
+}
+
+// UpdateView 更新视图的名称、描述、过滤参数和共享状态，列表和创建者不能修改
+func UpdateView(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		original, ok := ctx.viewByID(c)
+		if !ok {
+			return
+		}
+		if !canModifyView(c, original) {
+			c.JSON(http.StatusForbidden, Response{
+				Code:    403,
+				Message: "Only the owner can modify this view",
+			})
+			return
+		}
+
+		var req models.SavedView
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid request format",
+			})
+			return
+		}
+		if req.Resource != "" && req.Resource != original.Resource {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "View resource cannot be changed",
+			})
+			return
+		}
+
+		updated := *original
+		updated.Name = req.Name
+		updated.Description = req.Description
+		updated.Filters = req.Filters
+		updated.Shared = req.Shared
+		updated.UpdatedAt = time.Now()
+		if updated.Filters == nil {
+			updated.Filters = map[string]string{}
+		}
+		if err := validateView(&updated); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(viewCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		set := bson.M{
+			"name":        updated.Name,
+			"description": updated.Description,
+			"filters":     updated.Filters,
+			"shared":      updated.Shared,
+			"updated_at":  updated.UpdatedAt,
+		}
+		_, err := collection.UpdateOne(ctxDB, bson.M{"_id": original.ID}, bson.M{"$set": set})
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "View with same name already exists",
+			})
+			return
+		}
+		if err != nil {
+			ctx.Logger.Errorf("Failed to update view: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to update view",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditUpdate, "view", original.ID.Hex(), updated.Name, original, updated)
+
+		ctx.Logger.Infof("View updated: %s/%s", updated.Resource, updated.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "View updated successfully",
+			Data:    updated,
+		})
+	}
+}
+
+// DeleteView 删除视图
+func DeleteView(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		view, ok := ctx.viewByID(c)
+		if !ok {
+			return
+		}
+		if !canModifyView(c, view) {
+			c.JSON(http.StatusForbidden, Response{
+				Code:    403,
+				Message: "Only the owner can delete this view",
+			})
+			return
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(viewCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := collection.DeleteOne(ctxDB, bson.M{"_id": view.ID}); err != nil {
+			ctx.Logger.Errorf("Failed to delete view: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to delete view",
+			})
+			return
+		}
+
+		ctx.recordAudit(c, auditDelete, "view", view.ID.Hex(), view.Name, view, nil)
+
+		ctx.Logger.Infof("View deleted: %s/%s", view.Resource, view.Name)
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "View deleted successfully",
+		})
+	}
+}
+
+// viewResources 支持保存视图的列表，按名称排序
+func viewResources() []string {
+	resources := make([]string, 0, len(viewFilterKeys))
+	for resource := range viewFilterKeys {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
@@ -2,15 +2,20 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"nsa/internal/models"
+	"nsa/internal/trigger"
+	"nsa/internal/workflow"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -46,6 +51,11 @@ func ListWorkflows(ctx *Context) gin.HandlerFunc {
 		if enabled := c.Query("enabled"); enabled != "" {
 			filter["enabled"] = enabled == "true"
 		}
+		for _, field := range []string{"owner", "project"} {
+			if value := c.Query(field); value != "" {
+				filter[field] = value
+			}
+		}
 
 		// 获取总数
 		total, err := collection.CountDocuments(ctxDB, filter)
@@ -62,7 +72,7 @@ func ListWorkflows(ctx *Context) gin.HandlerFunc {
 		opts := options.Find()
 		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
 		opts.SetLimit(int64(req.PageSize))
-		opts.SetSort(bson.D{{"created_at", -1}})
+		opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
 
 		cursor, err := collection.Find(ctxDB, filter, opts)
 		if err != nil {
@@ -156,10 +166,76 @@ func CreateWorkflow(ctx *Context) gin.HandlerFunc {
 			})
 			return
 		}
+		if err := trigger.Validate(workflow.Triggers); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateOnRestart(workflow.OnRestart); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateRateLimit(workflow.RateLimit); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateExecutionWindow(workflow.ExecutionWindow); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateRequeuePolicy(workflow.Requeue); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := ctx.validateExecutionBudget(workflow.Budget); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateTaskConditions(workflow.DAG.Tasks); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		if err := ctx.Executor.CheckActions(workflow.Project, workflow.DAG.Tasks); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		if len(workflow.Unmask) > 0 && c.GetString("role") != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, Response{
+				Code:    403,
+				Message: "Only admin can grant unmask",
+			})
+			return
+		}
 
-		// 设置创建时间
+		// 设置创建时间和负责人
 		workflow.CreatedAt = time.Now()
 		workflow.UpdatedAt = time.Now()
+		workflow.Owner = c.GetString("username")
 
 		// 检查topic和channel组合是否已存在
 		collection := ctx.MongoClient.GetCollection()
@@ -199,10 +275,11 @@ func CreateWorkflow(ctx *Context) gin.HandlerFunc {
 		}
 
 		workflow.ID = result.InsertedID.(primitive.ObjectID)
+		ctx.recordAudit(c, auditCreate, "workflow", workflow.ID.Hex(), workflow.Name, nil, workflow)
 
-		// 如果工作流启用，重新加载NSQ消费者
+		// 如果工作流启用，立即协调NSQ消费者和触发器
 		if workflow.Enabled {
-			go ctx.reloadNSQConsumers()
+			ctx.Watcher.Trigger()
 		}
 
 		ctx.Logger.Infof("Workflow created: %s", workflow.Name)
@@ -235,6 +312,55 @@ func UpdateWorkflow(ctx *Context) gin.HandlerFunc {
 			})
 			return
 		}
+		if err := trigger.Validate(workflow.Triggers); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateOnRestart(workflow.OnRestart); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateRateLimit(workflow.RateLimit); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateExecutionWindow(workflow.ExecutionWindow); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateRequeuePolicy(workflow.Requeue); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := ctx.validateExecutionBudget(workflow.Budget); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := validateTaskConditions(workflow.DAG.Tasks); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
 
 		// 设置更新时间
 		workflow.UpdatedAt = time.Now()
@@ -243,8 +369,50 @@ func UpdateWorkflow(ctx *Context) gin.HandlerFunc {
 		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		// 获取原有工作流
+		var original models.WorkflowConfig
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&original); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Workflow not found",
+			})
+			return
+		}
+
+		// 负责人和项目只能通过转移接口修改
+		workflow.Owner = original.Owner
+		workflow.Project = original.Project
+		if err := ctx.Executor.CheckActions(workflow.Project, workflow.DAG.Tasks); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		// 超过执行预算的记录只由执行器设置，启用工作流时清除
+		workflow.BudgetExceeded = original.BudgetExceeded
+		resetBudget := workflow.Enabled && original.BudgetExceeded != nil
+		if resetBudget {
+			workflow.BudgetExceeded = nil
+		}
+
+		// 请求未包含 unmask 时保持不变，修改需要admin
+		if workflow.Unmask == nil {
+			workflow.Unmask = original.Unmask
+		} else if !slices.Equal(workflow.Unmask, original.Unmask) && c.GetString("role") != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, Response{
+				Code:    403,
+				Message: "Only admin can change unmask",
+			})
+			return
+		}
+
 		// 更新数据库
 		update := bson.M{"$set": workflow}
+		if resetBudget {
+			update["$unset"] = bson.M{"budget_exceeded": ""}
+		}
 		result, err := collection.UpdateOne(ctxDB, bson.M{"_id": objectID}, update)
 		if err != nil {
 			ctx.Logger.Errorf("Failed to update workflow: %v", err)
@@ -263,10 +431,16 @@ func UpdateWorkflow(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
-		// 重新加载NSQ消费者
-		go ctx.reloadNSQConsumers()
+		if resetBudget {
+			ctx.resetBudget(id)
+		}
+
+		// 立即协调NSQ消费者和触发器
+		ctx.Watcher.Trigger()
 
 		workflow.ID = objectID
+		ctx.recordAudit(c, auditUpdate, "workflow", id, workflow.Name, original, workflow)
+
 		ctx.Logger.Infof("Workflow updated: %s", workflow.Name)
 		c.JSON(http.StatusOK, Response{
 			Code:    200,
@@ -293,6 +467,16 @@ func DeleteWorkflow(ctx *Context) gin.HandlerFunc {
 		ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		// 获取工作流信息
+		var workflow models.WorkflowConfig
+		if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&workflow); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Workflow not found",
+			})
+			return
+		}
+
 		// 删除数据库记录
 		result, err := collection.DeleteOne(ctxDB, bson.M{"_id": objectID})
 		if err != nil {
@@ -312,8 +496,10 @@ func DeleteWorkflow(ctx *Context) gin.HandlerFunc {
 			return
 		}
 
-		// 重新加载NSQ消费者
-		go ctx.reloadNSQConsumers()
+		ctx.recordAudit(c, auditDelete, "workflow", id, workflow.Name, workflow, nil)
+
+		// 立即协调NSQ消费者和触发器
+		ctx.Watcher.Trigger()
 
 		ctx.Logger.Infof("Workflow deleted: %s", id)
 		c.JSON(http.StatusOK, Response{
@@ -337,6 +523,203 @@ func DisableWorkflow(ctx *Context) gin.HandlerFunc {
 	}
 }
 
+// TriggerRequest 手动触发工作流的请求
+type TriggerRequest struct {
+	// Data 消息数据，与NSQ消息体相同，任务中通过 nsq 变量读取
+	Data map[string]interface{} `json:"data"`
+}
+
+// TriggerResponse 手动触发工作流的结果
+type TriggerResponse struct {
+	MessageID string `json:"message_id"`
+	// InstanceID 创建的实例ID，执行被跳过（执行窗口外、超过执行预算）时为空
+	InstanceID string `json:"instance_id,omitempty"`
+}
+
+// TriggerWorkflow 以请求中的数据立即执行工作流，不经过NSQ；实例异步执行，返回创建的实例ID
+func TriggerWorkflow(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid workflow ID",
+			})
+			return
+		}
+
+		var req TriggerRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, Response{
+					Code:    400,
+					Message: "Invalid request body",
+				})
+				return
+			}
+		}
+		if req.Data == nil {
+			req.Data = make(map[string]interface{})
+		}
+
+		// standby 部署在提升前不执行工作流
+		if !ctx.Role.Active() {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Deployment is in standby, promote it before triggering workflows",
+			})
+			return
+		}
+
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var workflowConfig models.WorkflowConfig
+		if err := ctx.MongoClient.GetCollection().FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&workflowConfig); err != nil {
+			c.JSON(http.StatusNotFound, Response{
+				Code:    404,
+				Message: "Workflow not found",
+			})
+			return
+		}
+		if !workflowConfig.Enabled {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Message: "Workflow is disabled, enable it before triggering",
+			})
+			return
+		}
+
+		req.Data["trigger"] = "manual"
+		body, _ := json.Marshal(req.Data)
+		message := &models.NSQMessage{
+			Topic:     workflowConfig.Topic,
+			Channel:   workflowConfig.Channel,
+			Body:      body,
+			Timestamp: time.Now(),
+			ID:        primitive.NewObjectID().Hex(),
+			Data:      req.Data,
+		}
+		if err := ctx.Executor.Execute(context.Background(), &workflowConfig, message); err != nil {
+			ctx.Logger.Errorf("Failed to trigger workflow %s: %v", id, err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: fmt.Sprintf("Failed to trigger workflow: %v", err),
+			})
+			return
+		}
+
+		// 实例在 Execute 返回前保存，按触发消息ID查询；执行被跳过时没有实例
+		response := TriggerResponse{MessageID: message.ID}
+		var instance struct {
+			ID string `bson:"_id"`
+		}
+		err = ctx.MongoClient.GetDatabase().Collection("workflow_instances").FindOne(ctxDB,
+			bson.M{"workflow_id": id, "message.id": message.ID},
+			options.FindOne().SetSort(bson.D{{Key: "start_time", Value: -1}}).SetProjection(bson.M{"_id": 1}),
+		).Decode(&instance)
+		if err != nil && err != mongo.ErrNoDocuments {
+			ctx.Logger.Errorf("Failed to find instance of triggered workflow %s: %v", id, err)
+		}
+		response.InstanceID = instance.ID
+
+		ctx.recordAudit(c, auditTrigger, "workflow", id, workflowConfig.Name, nil, response)
+
+		c.JSON(http.StatusAccepted, Response{
+			Code:    202,
+			Message: "Workflow triggered",
+			Data:    response,
+		})
+	}
+}
+
+// ListMissedRuns 获取工作流 cron 触发器错过的触发时间及补偿决定，支持按 decision 过滤
+func ListMissedRuns(ctx *Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := primitive.ObjectIDFromHex(c.Param("id")); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid workflow ID",
+			})
+			return
+		}
+
+		var req PaginationRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "Invalid query parameters",
+			})
+			return
+		}
+
+		// 设置默认值
+		if req.Page <= 0 {
+			req.Page = 1
+		}
+		if req.PageSize <= 0 {
+			req.PageSize = 50
+		}
+
+		collection := ctx.MongoClient.GetDatabase().Collection(trigger.CronMissedCollection)
+		ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{"workflow_id": c.Param("id")}
+		if decision := c.Query("decision"); decision != "" {
+			filter["decision"] = decision
+		}
+
+		total, err := collection.CountDocuments(ctxDB, filter)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to count missed runs: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to count missed runs",
+			})
+			return
+		}
+
+		opts := options.Find()
+		opts.SetSkip(int64((req.Page - 1) * req.PageSize))
+		opts.SetLimit(int64(req.PageSize))
+		opts.SetSort(bson.D{{Key: "scheduled_at", Value: -1}})
+
+		cursor, err := collection.Find(ctxDB, filter, opts)
+		if err != nil {
+			ctx.Logger.Errorf("Failed to find missed runs: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to find missed runs",
+			})
+			return
+		}
+		defer cursor.Close(ctxDB)
+
+		runs := []models.CronMissedRun{}
+		if err := cursor.All(ctxDB, &runs); err != nil {
+			ctx.Logger.Errorf("Failed to decode missed runs: %v", err)
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Message: "Failed to decode missed runs",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Message: "Success",
+			Data: PaginationResponse{
+				Total:    total,
+				Page:     req.Page,
+				PageSize: req.PageSize,
+				Data:     runs,
+			},
+		})
+	}
+}
+
 // updateWorkflowStatus 更新工作流状态
 func (ctx *Context) updateWorkflowStatus(c *gin.Context, enabled bool) {
 	id := c.Param("id")
@@ -353,13 +736,26 @@ func (ctx *Context) updateWorkflowStatus(c *gin.Context, enabled bool) {
 	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 更新状态
+	var workflow models.WorkflowConfig
+	if err := collection.FindOne(ctxDB, bson.M{"_id": objectID}).Decode(&workflow); err != nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "Workflow not found",
+		})
+		return
+	}
+
+	// 更新状态，启用超过执行预算被禁用的工作流时清除记录和当天的用量
 	update := bson.M{
 		"$set": bson.M{
 			"enabled":    enabled,
 			"updated_at": time.Now(),
 		},
 	}
+	resetBudget := enabled && workflow.BudgetExceeded != nil
+	if resetBudget {
+		update["$unset"] = bson.M{"budget_exceeded": ""}
+	}
 
 	result, err := collection.UpdateOne(ctxDB, bson.M{"_id": objectID}, update)
 	if err != nil {
@@ -379,13 +775,22 @@ func (ctx *Context) updateWorkflowStatus(c *gin.Context, enabled bool) {
 		return
 	}
 
-	// 重新加载NSQ消费者
-	go ctx.reloadNSQConsumers()
+	if resetBudget {
+		ctx.resetBudget(id)
+	}
+
+	// 立即协调NSQ消费者和触发器
+	ctx.Watcher.Trigger()
 
 	status := "disabled"
+	action := auditDisable
 	if enabled {
 		status = "enabled"
+		action = auditEnable
 	}
+	ctx.recordAudit(c, action, "workflow", id, workflow.Name,
+		map[string]interface{}{"enabled": workflow.Enabled},
+		map[string]interface{}{"enabled": enabled})
 
 	ctx.Logger.Infof("Workflow %s: %s", status, id)
 	c.JSON(http.StatusOK, Response{
@@ -394,28 +799,60 @@ func (ctx *Context) updateWorkflowStatus(c *gin.Context, enabled bool) {
 	})
 }
 
-// reloadNSQConsumers 重新加载NSQ消费者
-func (ctx *Context) reloadNSQConsumers() {
-	// 获取所有启用的工作流
-	collection := ctx.MongoClient.GetCollection()
-	ctxDB, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// validateOnRestart 校验服务重启时未完成实例的处理方式
+func validateOnRestart(mode string) error {
+	switch mode {
+	case "", "resume", "interrupt":
+		return nil
+	default:
+		return fmt.Errorf("on_restart must be resume or interrupt, got %q", mode)
+	}
+}
 
-	cursor, err := collection.Find(ctxDB, bson.M{"enabled": true})
-	if err != nil {
-		ctx.Logger.Errorf("Failed to find enabled workflows: %v", err)
-		return
+// validateRateLimit 校验执行速率限制
+func validateRateLimit(limit *models.RateLimit) error {
+	if limit == nil {
+		return nil
 	}
-	defer cursor.Close(ctxDB)
+	if limit.Limit <= 0 {
+		return fmt.Errorf("rate_limit.limit must be positive, got %d", limit.Limit)
+	}
+	if limit.Period != "second" && limit.Period != "minute" {
+		return fmt.Errorf("rate_limit.period must be second or minute, got %q", limit.Period)
+	}
+	return nil
+}
 
-	var workflows []*models.WorkflowConfig
-	if err := cursor.All(ctxDB, &workflows); err != nil {
-		ctx.Logger.Errorf("Failed to decode workflows: %v", err)
-		return
+// validateExecutionWindow 校验执行窗口
+func validateExecutionWindow(window *models.ExecutionWindow) error {
+	return workflow.ValidateExecutionWindow(window)
+}
+
+// validateRequeuePolicy 校验失败重新入队策略
+func validateRequeuePolicy(policy *models.RequeuePolicy) error {
+	return workflow.ValidateRequeuePolicy(policy)
+}
+
+// validateExecutionBudget 校验每日执行预算，通知邮箱需要配置SMTP服务器
+func (ctx *Context) validateExecutionBudget(budget *models.ExecutionBudget) error {
+	if err := workflow.ValidateExecutionBudget(budget); err != nil {
+		return err
 	}
+	if budget != nil && len(budget.Emails) > 0 && !ctx.Reports.EmailEnabled() {
+		return fmt.Errorf("budget.emails require reports.smtp to be configured")
+	}
+	return nil
+}
 
-	// 重新加载消费者
-	if err := ctx.NSQManager.ReloadConsumers(workflows); err != nil {
-		ctx.Logger.Errorf("Failed to reload NSQ consumers: %v", err)
+// validateTaskConditions 校验任务执行条件表达式的语法
+func validateTaskConditions(tasks []models.TaskConfig) error {
+	for _, task := range tasks {
+		if task.Condition == "" {
+			continue
+		}
+		if err := workflow.ValidateExpression(task.Condition); err != nil {
+			return fmt.Errorf("task %s: invalid condition: %v", task.ID, err)
+		}
 	}
+	return nil
 }
@@ -3,17 +3,32 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"nsa/internal/alert"
+	"nsa/internal/cluster"
 	"nsa/internal/config"
 	"nsa/internal/datasource"
+	"nsa/internal/encryption"
 	"nsa/internal/logger"
+	"nsa/internal/models"
 	"nsa/internal/mongodb"
 	"nsa/internal/nsq"
+	"nsa/internal/reconcile"
+	"nsa/internal/report"
+	"nsa/internal/retention"
+	"nsa/internal/secrets"
 	"nsa/internal/server/handlers"
+	"nsa/internal/trigger"
 	"nsa/internal/workflow"
+	"nsa/internal/workqueue"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // Server HTTP服务器
@@ -23,9 +38,22 @@ type Server struct {
 	mongoClient   *mongodb.Client
 	nsqManager    *nsq.Manager
 	dataSourceMgr *datasource.Manager
+	secrets       *secrets.Store
 	executor      *workflow.Executor
+	purger        *retention.Purger
+	reports       *report.Scheduler
+	alerts        *alert.Manager
+	triggers      *trigger.Manager
+	watcher       *reconcile.Watcher
+	node          *cluster.Node
+	role          *cluster.Role
+	queue         *workqueue.Queue
+	keyring       *encryption.Keyring
+	stopAliases   chan struct{}
+	handlerCtx    *handlers.Context
 	router        *gin.Engine
 	httpServer    *http.Server
+	grpcServer    *handlers.GRPCServer // 未配置 server.grpc_port 时为nil
 }
 
 // New 创建新的HTTP服务器
@@ -33,47 +61,308 @@ func New(cfg *config.Config, logger logger.Logger, mongoClient *mongodb.Client,
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 
+	// 配置了加密密钥时，消息在读写MongoDB之前设置加密器
+	keyring, err := encryption.NewKeyring(cfg.Encryption)
+	if err != nil {
+		logger.Fatalf("Failed to load encryption keys: %v", err)
+	}
+	if keyring != nil {
+		models.SetPayloadCipher(keyring)
+		logger.Infof("Message encryption enabled with key %s", keyring.ActiveKeyID())
+	}
+
 	// 创建数据源管理器
 	dataSourceMgr := datasource.NewManager()
+	loadDataSources(logger, mongoClient, dataSourceMgr)
+	stopAliases := make(chan struct{})
+	go refreshDataSourceAliases(logger, mongoClient, dataSourceMgr, stopAliases)
+	dataSourceMgr.StartHealthChecks(cfg.DataSourceHealth, logger)
+
+	// 创建密钥存储
+	secretStore := secrets.NewStore(mongoClient)
 
 	// 创建工作流执行器
-	executor := workflow.NewExecutor(logger, mongoClient, dataSourceMgr)
+	executor := workflow.NewExecutor(logger, mongoClient, dataSourceMgr, secretStore, cfg)
 
 	// 设置NSQ管理器的执行器
 	nsqManager.SetExecutor(executor)
 
+	// 集群节点在部署为 active 后启动，接管心跳超时的节点上未结束的实例
+	node := cluster.NewNode(cfg.Cluster, logger, mongoClient)
+	node.OnNodeDown(func(ctx context.Context, nodeID string) {
+		if err := executor.TakeOver(ctx, nodeID); err != nil {
+			logger.Errorf("Failed to take over instances of node %s: %v", nodeID, err)
+		}
+	})
+	if cfg.Cluster.Enabled && cfg.Cluster.PartitionConsumers {
+		// 每个 topic/channel 的消费者只在一个节点上运行，节点加入或离开时重新分配
+		nsqManager.SetPartitioner(func(topic, channel string) bool {
+			return node.Owns(topic + ":" + channel)
+		})
+		node.OnMembersChanged(func() {
+			if err := nsqManager.Rebalance(); err != nil {
+				logger.Errorf("Failed to rebalance NSQ consumers: %v", err)
+			}
+		})
+	}
+
+	// 创建工作队列，消费者加载前设置，消息先写入队列再由队列控制并发执行
+	var queue *workqueue.Queue
+	if cfg.WorkQueue.Enabled {
+		queue = workqueue.New(cfg.WorkQueue, node.ID(), logger, mongoClient, executor)
+		if err := queue.EnsureIndexes(); err != nil {
+			logger.Errorf("Failed to create work queue indexes: %v", err)
+		}
+		nsqManager.SetWorkQueue(queue)
+	}
+
+	// 创建工作流触发器管理器
+	triggers := trigger.NewManager(logger, executor, dataSourceMgr, mongoClient, node)
+	if err := triggers.EnsureIndexes(); err != nil {
+		logger.Errorf("Failed to create trigger indexes: %v", err)
+	}
+
+	// 创建数据保留清理器
+	purger := retention.NewPurger(cfg.Retention, logger, mongoClient)
+	if err := purger.EnsureIndexes(); err != nil {
+		logger.Errorf("Failed to create retention indexes: %v", err)
+	}
+
+	// 创建健康报告调度器
+	reports := report.NewScheduler(cfg.Reports, logger, mongoClient, secretStore, node)
+	if err := reports.EnsureIndexes(); err != nil {
+		logger.Errorf("Failed to create report indexes: %v", err)
+	}
+
+	// 创建告警规则管理器，邮件和 Slack 告警通过报告调度器发送
+	alerts := alert.NewManager(cfg.Alerts, logger, mongoClient, secretStore, node, reports)
+	if err := alerts.EnsureIndexes(); err != nil {
+		logger.Errorf("Failed to create alert indexes: %v", err)
+	}
+
+	// 创建工作流变化监听器，路由初始化后启动
+	watcher := reconcile.NewWatcher(cfg.NSQ, logger, mongoClient, executor, nsqManager, triggers)
+
 	server := &Server{
 		config:        cfg,
 		logger:        logger,
 		mongoClient:   mongoClient,
 		nsqManager:    nsqManager,
 		dataSourceMgr: dataSourceMgr,
+		secrets:       secretStore,
 		executor:      executor,
+		purger:        purger,
+		reports:       reports,
+		alerts:        alerts,
+		triggers:      triggers,
+		watcher:       watcher,
+		node:          node,
+		queue:         queue,
+		keyring:       keyring,
+		stopAliases:   stopAliases,
 	}
 
+	server.role = cluster.NewRole(cfg.Cluster.Role, server.promote)
+
 	// 初始化路由
 	server.setupRoutes()
 
+	if server.role.Active() {
+		if err := node.Start(); err != nil {
+			logger.Errorf("Failed to start cluster node: %v", err)
+		}
+		server.startActive()
+	} else {
+		logger.Warn("Running as standby: NSQ consumers, triggers and schedules are paused until promoted")
+	}
+
 	return server
 }
 
+// startActive 启动只在 active 部署运行的组件：恢复未结束的实例，启动工作队列、被动触发器接收器、数据清理、报告调度，
+// 加载启用的工作流的消费者和触发器，之后随工作流变化协调
+func (s *Server) startActive() {
+	// 恢复服务停止时仍在并发键队列中的实例和其他未结束的实例
+	recoverInstances(s.logger, s.node, s.executor)
+	if s.queue != nil {
+		s.queue.Start()
+	}
+
+	if s.config.SNMPTrap.Enabled {
+		if err := s.triggers.StartSNMPTrapReceiver(s.config.SNMPTrap); err != nil {
+			s.logger.Errorf("Failed to start SNMP trap receiver: %v", err)
+		}
+	}
+	if s.config.Syslog.Enabled {
+		if err := s.triggers.StartSyslogReceiver(s.config.Syslog); err != nil {
+			s.logger.Errorf("Failed to start syslog receiver: %v", err)
+		}
+	}
+
+	s.purger.Start()
+	s.reports.Start()
+	s.alerts.Start()
+	s.watcher.Start()
+}
+
+// promote 将 standby 部署提升为 active
+//
+// 集群节点的心跳写入失败（如本地区的MongoDB尚未成为主节点）时不启动任何组件，可以重试提升。
+func (s *Server) promote() error {
+	if err := s.node.Start(); err != nil {
+		return fmt.Errorf("failed to start cluster node: %v", err)
+	}
+	s.startActive()
+	s.logger.Info("Standby deployment promoted to active")
+	return nil
+}
+
+// loadDataSources 从数据库加载已保存的数据源并建立连接，使重启前创建的数据源在恢复实例和消费消息前可用
+func loadDataSources(logger logger.Logger, mongoClient *mongodb.Client, dataSourceMgr *datasource.Manager) {
+	var dataSources []*models.DataSource
+	err := mongoClient.Retry(context.Background(), 30*time.Second, func(ctx context.Context) error {
+		cursor, err := mongoClient.GetDatabase().Collection("datasources").Find(ctx, bson.M{})
+		if err != nil {
+			return err
+		}
+		dataSources = nil
+		return cursor.All(ctx, &dataSources)
+	})
+	if err != nil {
+		logger.Errorf("Failed to load datasources: %v", err)
+		return
+	}
+
+	if err := handlers.LoadDataSourceAliases(mongoClient, dataSourceMgr); err != nil {
+		logger.Errorf("Failed to load datasource aliases: %v", err)
+	}
+
+	errs := dataSourceMgr.LoadDataSources(dataSources)
+	for name, err := range errs {
+		logger.Errorf("Failed to connect datasource %s, will retry in background: %v", name, err)
+	}
+	logger.Infof("Loaded %d datasources (%d failed to connect)", len(dataSources), len(errs))
+}
+
+// refreshDataSourceAliases 定期重新加载数据源别名，其他节点上的修改最迟30秒后生效
+func refreshDataSourceAliases(logger logger.Logger, mongoClient *mongodb.Client, dataSourceMgr *datasource.Manager, stop chan struct{}) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := handlers.LoadDataSourceAliases(mongoClient, dataSourceMgr); err != nil {
+				logger.Errorf("Failed to reload datasource aliases: %v", err)
+			}
+		}
+	}
+}
+
+// recoverInstances 恢复当前节点在服务停止时未结束的实例
+//
+// 持有当前节点的接管租约，避免与正在接管本节点实例的其他节点重复恢复；未获得租约时由其他节点完成接管。
+func recoverInstances(logger logger.Logger, node *cluster.Node, executor *workflow.Executor) {
+	ctx := context.Background()
+	lease := cluster.TakeoverLease(node.ID())
+	acquired, err := node.Acquire(ctx, lease)
+	if err != nil {
+		logger.Errorf("Failed to acquire lease %s: %v", lease, err)
+		return
+	}
+	if !acquired {
+		logger.Warnf("Instances of node %s are being taken over by another node, skipping recovery", node.ID())
+		return
+	}
+	defer func() {
+		if err := node.Release(ctx, lease); err != nil {
+			logger.Errorf("Failed to release lease %s: %v", lease, err)
+		}
+	}()
+
+	if err := executor.RecoverQueues(ctx); err != nil {
+		logger.Errorf("Failed to recover keyed queues: %v", err)
+	}
+	if err := executor.RecoverInstances(ctx); err != nil {
+		logger.Errorf("Failed to recover workflow instances: %v", err)
+	}
+	if err := executor.RecoverBulkOperations(ctx); err != nil {
+		logger.Errorf("Failed to recover bulk operations: %v", err)
+	}
+}
+
 // setupRoutes 设置路由
 func (s *Server) setupRoutes() {
 	s.router = gin.New()
 
 	// 添加中间件
-	s.router.Use(gin.Logger())
+	s.router.Use(gin.LoggerWithFormatter(accessLogFormatter))
 	s.router.Use(gin.Recovery())
 	s.router.Use(s.corsMiddleware())
 
 	// 创建处理器
+	revocations := handlers.NewRevocationList(s.mongoClient)
 	handlerCtx := &handlers.Context{
 		Config:        s.config,
 		Logger:        s.logger,
+		Security:      logger.NewSecurity(s.config.Logging),
 		MongoClient:   s.mongoClient,
 		NSQManager:    s.nsqManager,
 		DataSourceMgr: s.dataSourceMgr,
+		Secrets:       s.secrets,
 		Executor:      s.executor,
+		Revocations:   revocations,
+		Sessions:      handlers.NewSessionStore(s.mongoClient, revocations),
+		StreamTickets: handlers.NewTicketStore(s.mongoClient),
+		Purger:        s.purger,
+		Reports:       s.reports,
+		Alerts:        s.alerts,
+		Triggers:      s.triggers,
+		Watcher:       s.watcher,
+		Node:          s.node,
+		Role:          s.role,
+		WorkQueue:     s.queue,
+		Keyring:       s.keyring,
+	}
+	s.handlerCtx = handlerCtx
+
+	// JSON响应中的时间统一为请求或用户指定时区的RFC3339格式
+	s.router.Use(handlers.TimeFormatMiddleware(handlerCtx))
+
+	// 初始化用户
+	if err := handlers.InitUsers(handlerCtx); err != nil {
+		s.logger.Errorf("Failed to initialize users: %v", err)
+	}
+	if err := handlers.InitInstances(handlerCtx); err != nil {
+		s.logger.Errorf("Failed to create instance indexes: %v", err)
+	}
+	if err := handlers.InitAuditLogs(handlerCtx); err != nil {
+		s.logger.Errorf("Failed to create audit log indexes: %v", err)
+	}
+	if err := handlers.InitApprovals(handlerCtx); err != nil {
+		s.logger.Errorf("Failed to create approval indexes: %v", err)
+	}
+	if err := handlers.InitScripts(handlerCtx); err != nil {
+		s.logger.Errorf("Failed to create script indexes: %v", err)
+	}
+	if err := handlers.InitViews(handlerCtx); err != nil {
+		s.logger.Errorf("Failed to create view indexes: %v", err)
+	}
+	if err := handlers.InitBudgets(handlerCtx); err != nil {
+		s.logger.Errorf("Failed to create execution budget indexes: %v", err)
+	}
+	if err := handlerCtx.Revocations.EnsureIndexes(); err != nil {
+		s.logger.Errorf("Failed to create revoked token indexes: %v", err)
+	}
+	if err := handlerCtx.Sessions.EnsureIndexes(); err != nil {
+		s.logger.Errorf("Failed to create session indexes: %v", err)
+	}
+	if err := handlerCtx.StreamTickets.EnsureIndexes(); err != nil {
+		s.logger.Errorf("Failed to create stream ticket indexes: %v", err)
+	}
+	if s.config.Admin.OIDC.Enabled {
+		handlerCtx.OIDC = handlers.NewOIDCProvider(s.config.Admin.OIDC)
 	}
 
 	// 健康检查
@@ -82,8 +371,10 @@ func (s *Server) setupRoutes() {
 	// API路由组
 	api := s.router.Group("/api/v1")
 	{
-		// 认证中间件
+		// 认证中间件，被拒绝的请求记录安全事件
+		api.Use(handlers.SecurityEventMiddleware(handlerCtx))
 		api.Use(handlers.AuthMiddleware(handlerCtx))
+		api.Use(handlers.WriteAccessMiddleware())
 
 		// 工作流管理
 		workflows := api.Group("/workflows")
@@ -95,6 +386,12 @@ func (s *Server) setupRoutes() {
 			workflows.DELETE("/:id", handlers.DeleteWorkflow(handlerCtx))
 			workflows.POST("/:id/enable", handlers.EnableWorkflow(handlerCtx))
 			workflows.POST("/:id/disable", handlers.DisableWorkflow(handlerCtx))
+			workflows.POST("/:id/trigger", handlers.TriggerWorkflow(handlerCtx))
+			workflows.GET("/:id/export", handlers.ExportWorkflow(handlerCtx))
+			workflows.GET("/:id/missed-runs", handlers.ListMissedRuns(handlerCtx))
+			workflows.GET("/:id/stats", handlers.GetWorkflowStats(handlerCtx))
+			workflows.POST("/import", handlers.ImportWorkflow(handlerCtx))
+			workflows.POST("/transfer", handlers.TransferWorkflows(handlerCtx))
 		}
 
 		// 数据源管理
@@ -102,10 +399,98 @@ func (s *Server) setupRoutes() {
 		{
 			datasources.GET("", handlers.ListDataSources(handlerCtx))
 			datasources.POST("", handlers.CreateDataSource(handlerCtx))
+			datasources.POST("/repoint", handlers.RequireRole(models.RoleAdmin), handlers.RepointDataSources(handlerCtx))
+			datasources.GET("/aliases", handlers.ListDataSourceAliases(handlerCtx))
+			datasources.POST("/aliases", handlers.CreateDataSourceAlias(handlerCtx))
+			datasources.PUT("/aliases/:id", handlers.UpdateDataSourceAlias(handlerCtx))
+			datasources.DELETE("/aliases/:id", handlers.DeleteDataSourceAlias(handlerCtx))
 			datasources.GET("/:id", handlers.GetDataSource(handlerCtx))
 			datasources.PUT("/:id", handlers.UpdateDataSource(handlerCtx))
 			datasources.DELETE("/:id", handlers.DeleteDataSource(handlerCtx))
 			datasources.POST("/:id/test", handlers.TestDataSource(handlerCtx))
+			datasources.GET("/:id/health", handlers.GetDataSourceHealth(handlerCtx))
+		}
+
+		// 密钥管理
+		secretsAPI := api.Group("/secrets")
+		{
+			secretsAPI.GET("", handlers.ListSecrets(handlerCtx))
+			secretsAPI.POST("", handlers.CreateSecret(handlerCtx))
+			secretsAPI.PUT("/:id", handlers.UpdateSecret(handlerCtx))
+			secretsAPI.DELETE("/:id", handlers.DeleteSecret(handlerCtx))
+		}
+
+		// JS脚本模块
+		scripts := api.Group("/scripts")
+		{
+			scripts.GET("", handlers.ListScripts(handlerCtx))
+			scripts.POST("", handlers.CreateScript(handlerCtx))
+			scripts.GET("/:id", handlers.GetScript(handlerCtx))
+			scripts.PUT("/:id", handlers.UpdateScript(handlerCtx))
+			scripts.DELETE("/:id", handlers.DeleteScript(handlerCtx))
+		}
+
+		// 连接器
+		api.GET("/connectors", handlers.ListConnectors(handlerCtx))
+
+		// 管理首页概况
+		api.GET("/dashboard", handlers.GetDashboard(handlerCtx))
+
+		// 当前用户的权限矩阵
+		api.GET("/me/permissions", handlers.GetMyPermissions(handlerCtx))
+
+		// 工作流实例
+		instances := api.Group("/instances")
+		{
+			instances.GET("", handlers.ListInstances(handlerCtx))
+			instances.POST("/bulk", handlers.CreateBulkOperation(handlerCtx))
+			instances.GET("/bulk", handlers.ListBulkOperations(handlerCtx))
+			instances.GET("/bulk/:id", handlers.GetBulkOperation(handlerCtx))
+			instances.GET("/:id", handlers.GetInstance(handlerCtx))
+			instances.GET("/:id/history", handlers.GetInstanceHistory(handlerCtx))
+		}
+
+		// 人工审批
+		approvals := api.Group("/approvals")
+		{
+			approvals.GET("", handlers.ListApprovals(handlerCtx))
+			approvals.GET("/:id", handlers.GetApproval(handlerCtx))
+			approvals.POST("/:id/approve", handlers.ApproveApproval(handlerCtx))
+			approvals.POST("/:id/reject", handlers.RejectApproval(handlerCtx))
+		}
+
+		// 保存的列表视图
+		views := api.Group("/views")
+		{
+			views.GET("", handlers.ListViews(handlerCtx))
+			views.POST("", handlers.CreateView(handlerCtx))
+			views.GET("/:id", handlers.GetView(handlerCtx))
+			views.PUT("/:id", handlers.UpdateView(handlerCtx))
+			views.DELETE("/:id", handlers.DeleteView(handlerCtx))
+		}
+
+		// 工作流健康报告
+		reports := api.Group("/reports")
+		{
+			reports.GET("", handlers.ListReports(handlerCtx))
+			reports.POST("", handlers.CreateReport(handlerCtx))
+			reports.GET("/:id", handlers.GetReport(handlerCtx))
+			reports.PUT("/:id", handlers.UpdateReport(handlerCtx))
+			reports.DELETE("/:id", handlers.DeleteReport(handlerCtx))
+			reports.GET("/:id/preview", handlers.PreviewReport(handlerCtx))
+			reports.POST("/:id/send", handlers.SendReport(handlerCtx))
+		}
+
+		// 告警规则和告警历史
+		alerts := api.Group("/alerts")
+		{
+			alerts.GET("", handlers.ListAlerts(handlerCtx))
+			alerts.GET("/rules", handlers.ListAlertRules(handlerCtx))
+			alerts.POST("/rules", handlers.CreateAlertRule(handlerCtx))
+			alerts.GET("/rules/:id", handlers.GetAlertRule(handlerCtx))
+			alerts.PUT("/rules/:id", handlers.UpdateAlertRule(handlerCtx))
+			alerts.DELETE("/rules/:id", handlers.DeleteAlertRule(handlerCtx))
+			alerts.POST("/rules/:id/test", handlers.TestAlertRule(handlerCtx))
 		}
 
 		// 执行日志
@@ -120,23 +505,77 @@ func (s *Server) setupRoutes() {
 		{
 			nsqAPI.GET("/consumers", handlers.ListNSQConsumers(handlerCtx))
 			nsqAPI.GET("/stats", handlers.GetNSQStats(handlerCtx))
+			nsqAPI.POST("/consumers/:topic/:channel/pause", handlers.PauseNSQConsumer(handlerCtx))
+			nsqAPI.POST("/consumers/:topic/:channel/resume", handlers.ResumeNSQConsumer(handlerCtx))
 			nsqAPI.POST("/reload", handlers.ReloadNSQConsumers(handlerCtx))
 		}
 
+		// 用户管理
+		users := api.Group("/users", handlers.RequireRole(models.RoleAdmin))
+		{
+			users.GET("", handlers.ListUsers(handlerCtx))
+			users.POST("", handlers.CreateUser(handlerCtx))
+			users.GET("/:id", handlers.GetUser(handlerCtx))
+			users.PUT("/:id", handlers.UpdateUser(handlerCtx))
+			users.DELETE("/:id", handlers.DeleteUser(handlerCtx))
+			users.GET("/:id/sessions", handlers.ListUserSessions(handlerCtx))
+			users.DELETE("/:id/sessions", handlers.RevokeUserSessions(handlerCtx))
+			users.DELETE("/:id/sessions/:session_id", handlers.RevokeUserSession(handlerCtx))
+		}
+
+		// 审计日志
+		api.GET("/audit", handlers.RequireRole(models.RoleAdmin), handlers.ListAuditLogs(handlerCtx))
+
 		// 系统信息
 		system := api.Group("/system")
 		{
 			system.GET("/info", handlers.GetSystemInfo(handlerCtx))
 			system.GET("/metrics", handlers.GetMetrics(handlerCtx))
+			system.GET("/nodes", handlers.ListNodes(handlerCtx))
+			system.GET("/cluster", handlers.GetClusterStatus(handlerCtx))
+			system.POST("/cluster/promote", handlers.RequireRole(models.RoleAdmin), handlers.PromoteDeployment(handlerCtx))
+			system.POST("/cleanup", handlers.RequireRole(models.RoleAdmin), handlers.RunRetentionCleanup(handlerCtx))
+			system.POST("/reload", handlers.RequireRole(models.RoleAdmin), handlers.ReloadConfig(handlerCtx))
+			system.POST("/encryption/reencrypt", handlers.RequireRole(models.RoleAdmin), handlers.ReencryptMessages(handlerCtx))
+
+			// 故障注入（chaos.enabled）
+			faults := system.Group("/faults", handlers.RequireRole(models.RoleAdmin))
+			faults.GET("", handlers.ListFaults(handlerCtx))
+			faults.POST("", handlers.AddFault(handlerCtx))
+			faults.DELETE("", handlers.ClearFaults(handlerCtx))
+			faults.DELETE("/:id", handlers.DeleteFault(handlerCtx))
+		}
+
+		// 工作队列（work_queue.enabled）
+		queue := api.Group("/queue", handlers.RequireRole(models.RoleAdmin))
+		{
+			queue.GET("", handlers.GetWorkQueueStats(handlerCtx))
+			queue.GET("/items", handlers.ListWorkQueueItems(handlerCtx))
+			queue.DELETE("/items", handlers.PurgeWorkQueue(handlerCtx))
+			queue.POST("/items/:id/retry", handlers.RetryWorkQueueItem(handlerCtx))
 		}
 	}
 
+	// 实时执行事件
+	s.router.GET("/ws/executions", handlers.StreamAuthMiddleware(handlerCtx), handlers.StreamExecutionEvents(handlerCtx))
+	s.router.GET("/api/v1/instances/:id/logs/stream", handlers.StreamAuthMiddleware(handlerCtx), handlers.StreamInstanceLogs(handlerCtx))
+
+	// 监控系统事件接入（使用 ingest.token 认证）
+	s.router.POST("/ingest/:source", handlers.IngestMonitoringEvent(handlerCtx))
+
 	// 认证路由
 	auth := s.router.Group("/auth")
 	{
 		auth.POST("/login", handlers.Login(handlerCtx))
+		auth.POST("/refresh", handlers.RefreshToken(handlerCtx))
+		auth.GET("/oidc/login", handlers.OIDCLogin(handlerCtx))
+		auth.GET("/oidc/callback", handlers.OIDCCallback(handlerCtx))
 		auth.POST("/logout", handlers.Logout(handlerCtx))
 		auth.GET("/me", handlers.AuthMiddleware(handlerCtx), handlers.GetCurrentUser(handlerCtx))
+		auth.PUT("/me", handlers.AuthMiddleware(handlerCtx), handlers.UpdateCurrentUser(handlerCtx))
+		auth.GET("/sessions", handlers.AuthMiddleware(handlerCtx), handlers.ListMySessions(handlerCtx))
+		auth.DELETE("/sessions/:id", handlers.AuthMiddleware(handlerCtx), handlers.RevokeMySession(handlerCtx))
+		auth.POST("/stream-ticket", handlers.AuthMiddleware(handlerCtx), handlers.IssueStreamTicket(handlerCtx))
 	}
 
 	// 静态文件服务（如果启用了GUI）
@@ -151,6 +590,46 @@ func (s *Server) setupRoutes() {
 	}
 }
 
+// redactedQueryParams 访问日志中隐去值的查询参数，流式接口通过它们传递令牌和票据
+var redactedQueryParams = []string{"access_token", "ticket"}
+
+// accessLogFormatter 与gin默认格式相同的访问日志，隐去查询参数中的令牌和票据
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	if path, query, ok := strings.Cut(param.Path, "?"); ok {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			query = "REDACTED"
+		} else {
+			for _, name := range redactedQueryParams {
+				if values.Has(name) {
+					values.Set(name, "REDACTED")
+				}
+			}
+			query = values.Encode()
+		}
+		param.Path = path + "?" + query
+	}
+
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		param.Path,
+		param.ErrorMessage,
+	)
+}
+
 // corsMiddleware CORS中间件
 func (s *Server) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -174,20 +653,84 @@ func (s *Server) Start() error {
 		Handler: s.router,
 	}
 
+	if err := s.startGRPC(); err != nil {
+		return err
+	}
+
 	s.logger.Infof("Starting HTTP server on port %d", s.config.Server.Port)
 	return s.httpServer.ListenAndServe()
 }
 
+// startGRPC 配置了 server.grpc_port 时启动gRPC管理接口
+func (s *Server) startGRPC() error {
+	if s.config.Server.GRPCPort == 0 {
+		return nil
+	}
+
+	grpcServer, err := handlers.NewGRPCServer(s.handlerCtx, s.router)
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.config.Server.GRPCPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on gRPC port %d: %v", s.config.Server.GRPCPort, err)
+	}
+	s.grpcServer = grpcServer
+
+	s.logger.Infof("Starting gRPC admin server on port %d", s.config.Server.GRPCPort)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			s.logger.Errorf("gRPC admin server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// ReloadConfig 重新加载配置文件（SIGHUP）
+func (s *Server) ReloadConfig() error {
+	_, err := s.handlerCtx.ReloadConfigFile()
+	return err
+}
+
 // Shutdown 优雅关闭HTTP服务器
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("Shutting down HTTP server...")
 
+	// 停止领取工作队列中的记录，未领取的记录保留在队列中
+	if s.queue != nil {
+		s.queue.Stop()
+	}
+
+	// 停止协调消费者和触发器
+	s.watcher.Stop()
+
 	// 停止工作流执行器
 	s.executor.Stop()
 
+	// 停止工作流触发器
+	s.triggers.Stop()
+
+	// 停止数据保留清理器
+	s.purger.Stop()
+
+	// 停止健康报告调度器
+	s.reports.Stop()
+
+	// 停止告警规则评估
+	s.alerts.Stop()
+
+	// 停止集群心跳，释放租约
+	s.node.Stop()
+
 	// 关闭数据源连接
+	close(s.stopAliases)
 	s.dataSourceMgr.Close()
 
+	// 关闭gRPC管理接口，执行事件流立即结束
+	if s.grpcServer != nil {
+		s.grpcServer.Shutdown(ctx)
+	}
+
 	// 关闭HTTP服务器
 	return s.httpServer.Shutdown(ctx)
 }
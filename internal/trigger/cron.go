@@ -0,0 +1,480 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// cron 触发器的补偿策略，决定服务停止期间错过的触发时间如何处理
+const (
+	CatchUpSkip         = "skip"           // 全部跳过
+	CatchUpRunOnce      = "run_once"       // 只补偿执行最近的一次
+	CatchUpRunAllMissed = "run_all_missed" // 按时间顺序全部补偿执行，最多 max_catch_up 次
+)
+
+// cron 触发器的状态和错过的触发记录
+const (
+	cronStateCollection  = "cron_triggers"
+	CronMissedCollection = "cron_missed_runs"
+)
+
+const (
+	// cronGracePeriod 触发时间之后这段时间内仍按正常触发处理，超过时视为错过
+	cronGracePeriod = time.Minute
+	// cronRetryInterval 读写触发器状态失败后的重试间隔
+	cronRetryInterval = 10 * time.Second
+	// defaultMaxCatchUp run_all_missed 默认最多补偿执行的次数
+	defaultMaxCatchUp = 100
+	// maxCronMissed 一次最多记录的错过触发时间，更早的只计数并写入日志
+	maxCronMissed = 1000
+	// cronMissedTTL 错过触发记录的保留时间
+	cronMissedTTL = 30 * 24 * time.Hour
+	// cronSearchYears 计算下一次触发时间时最多向后查找的年数，超过时认为表达式不会再触发（如2月30日）
+	cronSearchYears = 5
+)
+
+// cronConfig cron 触发器配置
+type cronConfig struct {
+	Schedule   string
+	Timezone   string
+	CatchUp    string
+	MaxCatchUp int
+
+	schedule *cronSchedule
+	location *time.Location
+}
+
+// parseCron 解析 cron 触发器参数
+func parseCron(params map[string]interface{}) (*cronConfig, error) {
+	cfg := &cronConfig{CatchUp: CatchUpSkip, MaxCatchUp: defaultMaxCatchUp, location: time.UTC}
+	cfg.Schedule, _ = params["schedule"].(string)
+	cfg.Timezone, _ = params["timezone"].(string)
+	if catchUp, ok := params["catch_up"].(string); ok && catchUp != "" {
+		cfg.CatchUp = catchUp
+	}
+	if maxCatchUp, ok := params["max_catch_up"].(float64); ok {
+		cfg.MaxCatchUp = int(maxCatchUp)
+	}
+
+	if cfg.Schedule == "" {
+		return nil, fmt.Errorf("schedule parameter is required for cron")
+	}
+	schedule, err := parseCronSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron schedule %q: %v", cfg.Schedule, err)
+	}
+	cfg.schedule = schedule
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone %q is not a valid IANA time zone", cfg.Timezone)
+		}
+		cfg.location = loc
+	}
+	switch cfg.CatchUp {
+	case CatchUpSkip, CatchUpRunOnce, CatchUpRunAllMissed:
+	default:
+		return nil, fmt.Errorf("catch_up must be %s, %s or %s, got %q", CatchUpSkip, CatchUpRunOnce, CatchUpRunAllMissed, cfg.CatchUp)
+	}
+	if cfg.MaxCatchUp < 1 || cfg.MaxCatchUp > maxCronMissed {
+		return nil, fmt.Errorf("max_catch_up must be between 1 and %d, got %d", maxCronMissed, cfg.MaxCatchUp)
+	}
+	if cfg.schedule.next(time.Now().In(cfg.location)).IsZero() {
+		return nil, fmt.Errorf("cron schedule %q never fires", cfg.Schedule)
+	}
+	return cfg, nil
+}
+
+// identity 表达式和时区，变化时不补偿之前的触发时间
+func (cfg *cronConfig) identity() string {
+	return cfg.Schedule + " " + cfg.location.String()
+}
+
+// data 一次触发的消息数据
+func (cfg *cronConfig) data(scheduledAt time.Time, catchUp bool) map[string]interface{} {
+	return map[string]interface{}{
+		"schedule":     cfg.Schedule,
+		"timezone":     cfg.location.String(),
+		"scheduled_at": scheduledAt.In(cfg.location).Format(time.RFC3339),
+		"catch_up":     catchUp,
+	}
+}
+
+// cronTarget cron 触发器所属的工作流，状态按触发器租约名称保存
+type cronTarget struct {
+	lease      string
+	workflowID string
+	workflow   string
+	index      int
+}
+
+// cronState 触发器最近一次处理（执行或跳过）的触发时间
+type cronState struct {
+	ID            string    `bson:"_id"`
+	WorkflowID    string    `bson:"workflow_id"`
+	Schedule      string    `bson:"schedule"`
+	LastScheduled time.Time `bson:"last_scheduled"`
+	UpdatedAt     time.Time `bson:"updated_at"`
+}
+
+// EnsureIndexes 创建错过触发记录的查询索引和过期索引
+func (m *Manager) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.mongoDB.GetDatabase().Collection(CronMissedCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "workflow_id", Value: 1}, {Key: "scheduled_at", Value: -1}}},
+		{Keys: bson.D{{Key: "decided_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(cronMissedTTL.Seconds()))},
+	})
+	return err
+}
+
+// cronRunner 按 cron 表达式触发工作流
+//
+// 最近处理的触发时间保存在 cron_triggers 集合中。启动（包括服务重启和租约切换到其他节点）时，
+// 上次处理之后已经过去的触发时间按 catch_up 策略补偿执行或跳过，每个时间的决定写入 cron_missed_runs；
+// 首次启动或表达式、时区变化时从当前时间开始，不补偿。
+func (m *Manager) cronRunner(cfg *cronConfig, target cronTarget) runner {
+	return func(ctx context.Context, fire func(data map[string]interface{})) {
+		var last time.Time
+		for ctx.Err() == nil {
+			var err error
+			if last, err = m.loadCronState(ctx, cfg, target); err == nil {
+				break
+			}
+			m.logger.Errorf("Failed to load cron trigger state for workflow %s: %v", target.workflow, err)
+			sleepContext(ctx, cronRetryInterval)
+		}
+
+		for ctx.Err() == nil {
+			now := time.Now()
+			due, dropped := cfg.schedule.between(last.In(cfg.location), now, maxCronMissed)
+			if len(due) > 0 {
+				if len(due) == 1 && dropped == 0 && now.Sub(due[0]) <= cronGracePeriod {
+					m.saveCronState(cfg, target, due[0])
+					fire(cfg.data(due[0], false))
+				} else {
+					m.catchUp(cfg, target, due, dropped, fire)
+				}
+				last = due[len(due)-1]
+			}
+
+			next := cfg.schedule.next(now.In(cfg.location))
+			if next.IsZero() {
+				m.logger.Warnf("Cron schedule %q of workflow %s never fires again", cfg.Schedule, target.workflow)
+				<-ctx.Done()
+				return
+			}
+			sleepContext(ctx, time.Until(next))
+		}
+	}
+}
+
+// catchUp 按补偿策略处理错过的触发时间（按时间顺序），先记录决定和状态再执行，进程在补偿期间退出时不会重复执行
+func (m *Manager) catchUp(cfg *cronConfig, target cronTarget, due []time.Time, dropped int, fire func(data map[string]interface{})) {
+	now := time.Now()
+	runFrom := len(due)
+	switch cfg.CatchUp {
+	case CatchUpRunOnce:
+		runFrom = len(due) - 1
+	case CatchUpRunAllMissed:
+		runFrom = max(len(due)-cfg.MaxCatchUp, 0)
+	}
+
+	records := make([]interface{}, 0, len(due))
+	for i, scheduledAt := range due {
+		record := models.CronMissedRun{
+			WorkflowID:  target.workflowID,
+			Workflow:    target.workflow,
+			Trigger:     target.index,
+			Schedule:    cfg.Schedule,
+			ScheduledAt: scheduledAt,
+			Policy:      cfg.CatchUp,
+			Decision:    "run",
+			Node:        m.node.ID(),
+			DecidedAt:   now,
+		}
+		if i < runFrom {
+			record.Decision = "skipped"
+			switch cfg.CatchUp {
+			case CatchUpSkip:
+				record.Reason = "catch_up is skip"
+			case CatchUpRunOnce:
+				record.Reason = "superseded by a later missed run"
+			default:
+				record.Reason = fmt.Sprintf("exceeds max_catch_up (%d)", cfg.MaxCatchUp)
+			}
+		}
+		records = append(records, record)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := m.mongoDB.GetDatabase().Collection(CronMissedCollection).InsertMany(ctx, records); err != nil {
+		m.logger.Errorf("Failed to record missed cron runs for workflow %s: %v", target.workflow, err)
+	}
+	m.saveCronState(cfg, target, due[len(due)-1])
+
+	if dropped > 0 {
+		m.logger.Warnf("Cron trigger of workflow %s skipped %d missed runs older than the latest %d without recording them", target.workflow, dropped, maxCronMissed)
+	}
+	m.logger.Infof("Cron trigger of workflow %s missed %d runs (%s), running %d", target.workflow, len(due)+dropped, cfg.CatchUp, len(due)-runFrom)
+
+	for _, scheduledAt := range due[runFrom:] {
+		fire(cfg.data(scheduledAt, true))
+	}
+}
+
+// loadCronState 读取最近处理的触发时间，没有状态或表达式、时区已变化时以当前时间重新开始
+func (m *Manager) loadCronState(ctx context.Context, cfg *cronConfig, target cronTarget) (time.Time, error) {
+	ctxDB, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var state cronState
+	err := m.mongoDB.GetDatabase().Collection(cronStateCollection).FindOne(ctxDB, bson.M{"_id": target.lease}).Decode(&state)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return time.Time{}, err
+	}
+	if err == nil && state.Schedule == cfg.identity() {
+		return state.LastScheduled, nil
+	}
+
+	now := time.Now()
+	m.saveCronState(cfg, target, now)
+	return now, nil
+}
+
+// saveCronState 保存最近处理的触发时间
+func (m *Manager) saveCronState(cfg *cronConfig, target cronTarget, last time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.mongoDB.GetDatabase().Collection(cronStateCollection).UpdateOne(ctx,
+		bson.M{"_id": target.lease},
+		bson.M{"$set": bson.M{
+			"workflow_id":    target.workflowID,
+			"schedule":       cfg.identity(),
+			"last_scheduled": last,
+			"updated_at":     time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		m.logger.Errorf("Failed to save cron trigger state for workflow %s: %v", target.workflow, err)
+	}
+}
+
+// deleteCronState 删除触发器状态，工作流禁用、删除或触发器修改后重新启用时不补偿期间的触发时间
+func (m *Manager) deleteCronState(lease string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := m.mongoDB.GetDatabase().Collection(cronStateCollection).DeleteOne(ctx, bson.M{"_id": lease}); err != nil {
+		m.logger.Errorf("Failed to delete cron trigger state %s: %v", lease, err)
+	}
+}
+
+// cronSchedule 解析后的5字段 cron 表达式（分 时 日 月 星期），每个字段为取值的位集合
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar、dowStar 日、星期字段以 * 开头；两者都有限制时满足其一即可（与 Vixie cron 相同）
+	domStar, dowStar bool
+}
+
+// cronField 字段的取值范围和名称
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 星期的 7 与 0 都表示周日
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronMacros 预定义的表达式
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCronSchedule 解析 cron 表达式，支持 *、列表、范围、步长、月份和星期名称以及 @daily 等宏
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+
+	s := &cronSchedule{
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	for i, target := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&s.minute, cronMinute},
+		{&s.hour, cronHour},
+		{&s.dom, cronDom},
+		{&s.month, cronMonth},
+		{&s.dow, cronDow},
+	} {
+		if *target.bits, err = parseCronField(fields[i], target.field); err != nil {
+			return nil, err
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseCronField 解析一个字段，返回取值的位集合
+func parseCronField(value string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, field.name)
+			}
+			step = n
+		}
+
+		var low, high int
+		switch {
+		case rangePart == "*":
+			low, high = field.min, field.max
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = field.value(from); err != nil {
+				return 0, err
+			}
+			if high, err = field.value(to); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangePart, field.name)
+			}
+		default:
+			var err error
+			if low, err = field.value(rangePart); err != nil {
+				return 0, err
+			}
+			high = low
+			// a/n 表示从 a 开始到最大值的步长
+			if hasStep {
+				high = field.max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value 解析字段中的数字或名称
+func (f cronField) value(s string) (int, error) {
+	if n, ok := f.names[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", s, f.name)
+	}
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s value %d out of range [%d, %d]", f.name, n, f.min, f.max)
+	}
+	return n, nil
+}
+
+// dayMatches 日期是否满足日和星期字段
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next 返回 t 之后（不含）的下一个触发时间，按 t 的时区计算；cronSearchYears 年内没有触发时间时返回零值
+//
+// 夏令时切换时跳过的本地时间不触发。
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.Year() + cronSearchYears
+
+	for t.Year() <= limit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// between 返回 (after, until] 之间的触发时间，超过 limit 个时只返回最近的 limit 个，dropped 为舍弃的数量
+func (s *cronSchedule) between(after, until time.Time, limit int) (due []time.Time, dropped int) {
+	for t := s.next(after); !t.IsZero() && !t.After(until); t = s.next(t) {
+		if len(due) == limit {
+			due = append(due[1:], t)
+			dropped++
+			continue
+		}
+		due = append(due, t)
+	}
+	return due, dropped
+}
@@ -0,0 +1,100 @@
+package trigger
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// grokPatterns 内置的grok模式，与Logstash常用模式同名
+var grokPatterns = map[string]string{
+	"USERNAME":     `[a-zA-Z0-9._-]+`,
+	"USER":         `%{USERNAME}`,
+	"INT":          `[+-]?[0-9]+`,
+	"POSINT":       `\b[1-9][0-9]*\b`,
+	"NONNEGINT":    `\b[0-9]+\b`,
+	"NUMBER":       `[+-]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)`,
+	"BASE16NUM":    `(?:0[xX])?[0-9A-Fa-f]+`,
+	"WORD":         `\b\w+\b`,
+	"NOTSPACE":     `\S+`,
+	"SPACE":        `\s*`,
+	"DATA":         `.*?`,
+	"GREEDYDATA":   `.*`,
+	"QUOTEDSTRING": `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"UUID":         `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"MAC":          `(?:[A-Fa-f0-9]{2}[:-]){5}[A-Fa-f0-9]{2}|(?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4}`,
+	"IPV4":         `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+	"IPV6":         `(?:[A-Fa-f0-9]{1,4}:){7}[A-Fa-f0-9]{1,4}|(?:[A-Fa-f0-9]{1,4}:){1,7}:|(?:[A-Fa-f0-9]{1,4}:){1,6}:[A-Fa-f0-9]{1,4}|::(?:[A-Fa-f0-9]{1,4}:){0,6}[A-Fa-f0-9]{1,4}`,
+	"IP":           `(?:%{IPV4}|%{IPV6})`,
+	"HOSTNAME":     `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":     `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":     `%{IPORHOST}:%{POSINT}`,
+	"PATH":         `(?:/[^\s/]*)+`,
+	"INTERFACE":    `[A-Za-z][A-Za-z-]*[0-9]+(?:/[0-9]+)*(?:\.[0-9]+)?`,
+	"LOGLEVEL":     `(?i:alert|trace|debug|notice|info|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?)`,
+}
+
+// grokReference 匹配 %{PATTERN} 和 %{PATTERN:field}
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::(\w+))?\}`)
+
+// compileGrok 将grok表达式转换为正则表达式，%{PATTERN:field} 转换为命名分组
+//
+// custom 为额外的模式定义，可以覆盖内置模式。
+func compileGrok(expression string, custom map[string]string) (*regexp.Regexp, error) {
+	patterns := make(map[string]string, len(grokPatterns)+len(custom))
+	for name, pattern := range grokPatterns {
+		patterns[name] = pattern
+	}
+	for name, pattern := range custom {
+		patterns[name] = pattern
+	}
+
+	var expand func(expression string, depth int) (string, error)
+	expand = func(expression string, depth int) (string, error) {
+		if depth > 10 {
+			return "", fmt.Errorf("grok pattern nesting too deep")
+		}
+		var expandErr error
+		result := grokReference.ReplaceAllStringFunc(expression, func(reference string) string {
+			match := grokReference.FindStringSubmatch(reference)
+			pattern, ok := patterns[match[1]]
+			if !ok {
+				expandErr = fmt.Errorf("unknown grok pattern %s", match[1])
+				return reference
+			}
+			expanded, err := expand(pattern, depth+1)
+			if err != nil {
+				expandErr = err
+				return reference
+			}
+			if match[2] != "" {
+				return "(?P<" + match[2] + ">" + expanded + ")"
+			}
+			return "(?:" + expanded + ")"
+		})
+		return result, expandErr
+	}
+
+	expanded, err := expand(expression, 0)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(expanded)
+}
+
+// captureFields 执行正则表达式，返回命名分组的值；不匹配时返回false
+func captureFields(re *regexp.Regexp, text string) (map[string]interface{}, bool) {
+	match := re.FindStringSubmatch(text)
+	if match == nil {
+		return nil, false
+	}
+	fields := map[string]interface{}{}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			// 同名分组保留第一个非空的值
+			if existing, ok := fields[name]; !ok || existing == "" {
+				fields[name] = match[i]
+			}
+		}
+	}
+	return fields, true
+}
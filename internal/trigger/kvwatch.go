@@ -0,0 +1,92 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/datasource"
+)
+
+// kvWatchRetryInterval 数据源不可用或监听出错后的重试间隔
+const kvWatchRetryInterval = 10 * time.Second
+
+// kvWatchConfig kv_watch 触发器配置
+type kvWatchConfig struct {
+	DataSource string
+	Key        string
+	Prefix     bool
+	DecodeJSON bool
+}
+
+// parseKVWatch 解析 kv_watch 触发器参数
+func parseKVWatch(params map[string]interface{}) (*kvWatchConfig, error) {
+	cfg := &kvWatchConfig{}
+	cfg.DataSource, _ = params["datasource"].(string)
+	cfg.Key, _ = params["key"].(string)
+	cfg.Prefix, _ = params["prefix"].(bool)
+	cfg.DecodeJSON, _ = params["decode_json"].(bool)
+
+	if cfg.DataSource == "" {
+		return nil, fmt.Errorf("datasource parameter is required for kv_watch")
+	}
+	if cfg.Key == "" && !cfg.Prefix {
+		return nil, fmt.Errorf("key parameter is required for kv_watch")
+	}
+	return cfg, nil
+}
+
+// kvWatchRunner 监听键值存储中的键，每个变更事件触发一次工作流
+//
+// 数据源在启动时可能尚未加载，获取失败时定期重试；监听出错后从上次的版本恢复，不会重复触发已处理的变更。
+func (m *Manager) kvWatchRunner(cfg *kvWatchConfig) runner {
+	return func(ctx context.Context, fire func(data map[string]interface{})) {
+		var watch datasource.KVWatch
+		defer func() {
+			if watch != nil {
+				watch.Close()
+			}
+		}()
+
+		for ctx.Err() == nil {
+			if watch == nil {
+				store, err := m.dataSourceMgr.GetKVStore(cfg.DataSource)
+				if err != nil {
+					m.logger.Warnf("KV watch on %s waiting for datasource: %v", cfg.Key, err)
+					sleepContext(ctx, kvWatchRetryInterval)
+					continue
+				}
+				watch = store.Watch(cfg.Key, cfg.Prefix)
+			}
+
+			events, err := watch.Next(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					m.logger.Errorf("KV watch on %s/%s failed: %v", cfg.DataSource, cfg.Key, err)
+					sleepContext(ctx, kvWatchRetryInterval)
+				}
+				continue
+			}
+
+			for _, event := range events {
+				m.logger.Infof("KV watch on %s/%s: %s %s (revision %d)", cfg.DataSource, cfg.Key, event.Type, event.Key, event.Revision)
+				fire(map[string]interface{}{
+					"datasource": cfg.DataSource,
+					"watch_key":  cfg.Key,
+					"event":      event.Type,
+					"key":        event.Key,
+					"value":      event.DecodeValue(cfg.DecodeJSON),
+					"revision":   event.Revision,
+				})
+			}
+		}
+	}
+}
+
+// sleepContext 等待指定时间或ctx取消
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
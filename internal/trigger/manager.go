@@ -0,0 +1,315 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"nsa/internal/cluster"
+	"nsa/internal/datasource"
+	"nsa/internal/logger"
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+	"nsa/internal/workflow"
+
+	"github.com/gosnmp/gosnmp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 触发器类型
+const (
+	TypeKVWatch    = "kv_watch"
+	TypeCron       = "cron"
+	TypeMonitoring = "monitoring"
+	TypeSNMPTrap   = "snmp_trap"
+	TypeSyslog     = "syslog"
+)
+
+// Manager 触发器管理器，按工作流配置启动和停止NSQ消息之外的触发器
+//
+// 触发器产生的数据作为消息数据（{{nsq.*}}）传给工作流，执行时按工作流的topic和channel读取最新配置。
+// kv_watch、cron 等主动触发器在后台运行，启用集群时只在持有触发器租约的节点上运行；
+// monitoring、snmp_trap、syslog 等被动触发器只保存路由规则，由接入的事件调用 Dispatch 触发。
+type Manager struct {
+	logger        logger.Logger
+	executor      *workflow.Executor
+	dataSourceMgr *datasource.Manager
+	mongoDB       *mongodb.Client
+	node          *cluster.Node
+
+	mu      sync.Mutex
+	running map[string]*runningTrigger // 工作流ID:序号:触发器配置 -> 运行中的触发器
+	routes  []route                    // 被动触发器的路由规则
+
+	trapListener *gosnmp.TrapListener // SNMP Trap接收，未启用时为nil
+	syslog       *syslogReceiver      // Syslog接收，未启用时为nil
+}
+
+// runningTrigger 运行中的触发器
+type runningTrigger struct {
+	triggerType string
+	lease       string
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// matcher 被动触发器的事件匹配条件，匹配时可以返回从事件中提取的字段（作为消息数据中的 fields）
+type matcher interface {
+	match(event map[string]interface{}) (map[string]interface{}, bool)
+}
+
+// route 启用的工作流上的被动触发器
+type route struct {
+	triggerType string
+	topic       string
+	channel     string
+	name        string
+	matcher     matcher
+}
+
+// runner 触发器的运行函数，阻塞直到ctx取消，每次触发调用fire
+type runner func(ctx context.Context, fire func(data map[string]interface{}))
+
+// NewManager 创建触发器管理器
+func NewManager(logger logger.Logger, executor *workflow.Executor, dataSourceMgr *datasource.Manager, mongoClient *mongodb.Client, node *cluster.Node) *Manager {
+	return &Manager{
+		logger:        logger,
+		executor:      executor,
+		dataSourceMgr: dataSourceMgr,
+		mongoDB:       mongoClient,
+		node:          node,
+		running:       make(map[string]*runningTrigger),
+	}
+}
+
+// Validate 检查触发器配置
+func Validate(triggers []models.TriggerConfig) error {
+	for i, trigger := range triggers {
+		switch trigger.Type {
+		case TypeKVWatch:
+			if _, err := parseKVWatch(trigger.Params); err != nil {
+				return fmt.Errorf("triggers[%d]: %v", i, err)
+			}
+		case TypeCron:
+			if _, err := parseCron(trigger.Params); err != nil {
+				return fmt.Errorf("triggers[%d]: %v", i, err)
+			}
+		case TypeMonitoring, TypeSNMPTrap, TypeSyslog:
+			if _, err := newMatcher(trigger); err != nil {
+				return fmt.Errorf("triggers[%d]: %v", i, err)
+			}
+		default:
+			return fmt.Errorf("triggers[%d]: unsupported trigger type %q", i, trigger.Type)
+		}
+	}
+	return nil
+}
+
+// Reload 根据启用的工作流重新加载触发器，配置未变化的触发器保持运行
+func (m *Manager) Reload(workflowConfigs []*models.WorkflowConfig) {
+	required := make(map[string]*models.WorkflowConfig)
+	triggers := make(map[string]models.TriggerConfig)
+	indexes := make(map[string]int)
+	var routes []route
+	for _, config := range workflowConfigs {
+		if !config.Enabled {
+			continue
+		}
+		for i, trigger := range config.Triggers {
+			if trigger.Type == TypeMonitoring || trigger.Type == TypeSNMPTrap || trigger.Type == TypeSyslog {
+				matcher, err := newMatcher(trigger)
+				if err != nil {
+					m.logger.Errorf("Invalid %s trigger for workflow %s: %v", trigger.Type, config.Name, err)
+					continue
+				}
+				routes = append(routes, route{
+					triggerType: trigger.Type,
+					topic:       config.Topic,
+					channel:     config.Channel,
+					name:        config.Name,
+					matcher:     matcher,
+				})
+				continue
+			}
+
+			params, _ := json.Marshal(trigger.Params)
+			key := fmt.Sprintf("%s:%d:%s:%s", config.ID.Hex(), i, trigger.Type, params)
+			required[key] = config
+			triggers[key] = trigger
+			indexes[key] = i
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.routes = routes
+	changed := false
+
+	// 停止不需要的触发器
+	for key, running := range m.running {
+		if _, ok := required[key]; !ok {
+			running.cancel()
+			<-running.done
+			delete(m.running, key)
+			// 重新启用或修改后的 cron 触发器从当前时间开始，不补偿停止期间的触发时间
+			if running.triggerType == TypeCron {
+				m.deleteCronState(running.lease)
+			}
+			m.logger.Infof("Stopped trigger: %s", key)
+			changed = true
+		}
+	}
+
+	// 启动新的触发器
+	for key, config := range required {
+		if _, exists := m.running[key]; exists {
+			continue
+		}
+		lease := triggerLease(config, indexes[key])
+		run, err := m.newRunner(config, indexes[key], triggers[key])
+		if err != nil {
+			m.logger.Errorf("Failed to start trigger for workflow %s: %v", config.Name, err)
+			continue
+		}
+
+		triggerType := triggers[key].Type
+		ctx, cancel := context.WithCancel(context.Background())
+		running := &runningTrigger{triggerType: triggerType, lease: lease, cancel: cancel, done: make(chan struct{})}
+		m.running[key] = running
+
+		topic, channel, name := config.Topic, config.Channel, config.Name
+		go func() {
+			defer close(running.done)
+			// 多个节点上同一个触发器只运行一个，避免重复触发
+			m.node.Hold(ctx, lease, func(ctx context.Context) {
+				run(ctx, func(data map[string]interface{}) {
+					m.fire(topic, channel, name, triggerType, data)
+				})
+			})
+		}()
+		m.logger.Infof("Started %s trigger for workflow %s", triggerType, name)
+		changed = true
+	}
+
+	// 定期协调时触发器通常没有变化，只在变化时记录日志
+	if !changed {
+		return
+	}
+	m.logger.Infof("Triggers reloaded, active triggers: %d, event routes: %d", len(m.running), len(m.routes))
+}
+
+// Dispatch 将被动触发器的事件路由到该类型下匹配的工作流，返回触发的工作流名称
+func (m *Manager) Dispatch(triggerType string, event map[string]interface{}) []string {
+	m.mu.Lock()
+	routes := m.routes
+	m.mu.Unlock()
+
+	matched := []string{}
+	for _, route := range routes {
+		if route.triggerType != triggerType {
+			continue
+		}
+		fields, ok := route.matcher.match(event)
+		if !ok {
+			continue
+		}
+		// 每个工作流使用独立的数据副本，执行器会写入 trigger 字段
+		data := make(map[string]interface{}, len(event)+2)
+		for key, value := range event {
+			data[key] = value
+		}
+		if fields != nil {
+			data["fields"] = fields
+		}
+		m.fire(route.topic, route.channel, route.name, triggerType, data)
+		matched = append(matched, route.name)
+	}
+	return matched
+}
+
+// Stop 停止所有触发器
+func (m *Manager) Stop() {
+	m.StopSNMPTrapReceiver()
+	m.StopSyslogReceiver()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, running := range m.running {
+		running.cancel()
+		<-running.done
+		delete(m.running, key)
+	}
+	m.logger.Info("Triggers stopped")
+}
+
+// triggerLease 返回工作流第 index 个触发器的租约名称
+func triggerLease(config *models.WorkflowConfig, index int) string {
+	return fmt.Sprintf("trigger:%s:%d", config.ID.Hex(), index)
+}
+
+// newRunner 根据触发器类型创建运行函数，trigger 为工作流的第 index 个触发器
+func (m *Manager) newRunner(config *models.WorkflowConfig, index int, trigger models.TriggerConfig) (runner, error) {
+	switch trigger.Type {
+	case TypeKVWatch:
+		cfg, err := parseKVWatch(trigger.Params)
+		if err != nil {
+			return nil, err
+		}
+		return m.kvWatchRunner(cfg), nil
+	case TypeCron:
+		cfg, err := parseCron(trigger.Params)
+		if err != nil {
+			return nil, err
+		}
+		return m.cronRunner(cfg, cronTarget{
+			lease:      triggerLease(config, index),
+			workflowID: config.ID.Hex(),
+			workflow:   config.Name,
+			index:      index,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported trigger type %q", trigger.Type)
+	}
+}
+
+// newMatcher 根据被动触发器类型解析匹配条件
+func newMatcher(trigger models.TriggerConfig) (matcher, error) {
+	switch trigger.Type {
+	case TypeMonitoring:
+		return parseMonitoring(trigger.Params)
+	case TypeSNMPTrap:
+		return parseSNMPTrap(trigger.Params)
+	case TypeSyslog:
+		return parseSyslog(trigger.Params)
+	default:
+		return nil, fmt.Errorf("unsupported trigger type %q", trigger.Type)
+	}
+}
+
+// fire 以触发器数据执行工作流
+func (m *Manager) fire(topic, channel, name, triggerType string, data map[string]interface{}) {
+	workflowConfig, err := m.executor.GetWorkflowConfig(topic, channel)
+	if err != nil {
+		m.logger.Errorf("Failed to get workflow config for trigger of workflow %s: %v", name, err)
+		return
+	}
+
+	data["trigger"] = triggerType
+	body, _ := json.Marshal(data)
+	message := &models.NSQMessage{
+		Topic:     topic,
+		Channel:   channel,
+		Body:      body,
+		Timestamp: time.Now(),
+		ID:        primitive.NewObjectID().Hex(),
+		Data:      data,
+	}
+
+	if err := m.executor.Execute(context.Background(), workflowConfig, message); err != nil {
+		m.logger.Errorf("Failed to execute workflow %s from %s trigger: %v", name, triggerType, err)
+	}
+}
@@ -0,0 +1,349 @@
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 监控事件来源
+const (
+	SourceZabbix = "zabbix"
+	SourceNagios = "nagios"
+)
+
+// 监控事件状态
+const (
+	StatusProblem      = "problem"
+	StatusResolved     = "resolved"
+	StatusUpdate       = "update"
+	StatusAcknowledged = "acknowledged"
+)
+
+// severityLevels 归一化后的严重级别，从低到高
+var severityLevels = []string{"info", "warning", "average", "high", "critical"}
+
+// zabbixSeverities Zabbix严重级别（名称和0-5的数值）到归一化级别的映射
+var zabbixSeverities = map[string]string{
+	"not classified": "info", "0": "info",
+	"information": "info", "1": "info",
+	"warning": "warning", "2": "warning",
+	"average": "average", "3": "average",
+	"high": "high", "4": "high",
+	"disaster": "critical", "5": "critical",
+}
+
+// nagiosSeverities Nagios主机和服务状态到归一化级别的映射
+var nagiosSeverities = map[string]string{
+	"ok":          "info",
+	"up":          "info",
+	"warning":     "warning",
+	"unknown":     "average",
+	"unreachable": "high",
+	"critical":    "critical",
+	"down":        "critical",
+}
+
+// monitoringConfig monitoring 触发器配置
+type monitoringConfig struct {
+	Source      string
+	HostGroups  []string
+	Hosts       []string
+	MinSeverity string
+	Statuses    []string
+}
+
+// parseMonitoring 解析 monitoring 触发器参数
+func parseMonitoring(params map[string]interface{}) (*monitoringConfig, error) {
+	cfg := &monitoringConfig{
+		HostGroups: paramStrings(params["host_groups"]),
+		Hosts:      paramStrings(params["hosts"]),
+		Statuses:   paramStrings(params["statuses"]),
+	}
+	cfg.Source, _ = params["source"].(string)
+	cfg.MinSeverity, _ = params["min_severity"].(string)
+
+	if cfg.Source != "" && cfg.Source != SourceZabbix && cfg.Source != SourceNagios {
+		return nil, fmt.Errorf("source must be %s or %s", SourceZabbix, SourceNagios)
+	}
+	if cfg.MinSeverity != "" && severityRank(cfg.MinSeverity) < 0 {
+		return nil, fmt.Errorf("min_severity must be one of %s", strings.Join(severityLevels, ", "))
+	}
+	return cfg, nil
+}
+
+// match 判断事件是否路由到该工作流：来源一致、主机和主机组匹配、状态在 statuses 中，
+// 问题事件的严重级别不低于 min_severity（恢复事件的级别通常为OK，不按级别过滤，以便工作流关闭自己创建的工单）
+func (c *monitoringConfig) match(event map[string]interface{}) (map[string]interface{}, bool) {
+	if c.Source != "" && c.Source != event["source"] {
+		return nil, false
+	}
+	if len(c.Hosts) > 0 && !containsFold(c.Hosts, event["host"].(string)) {
+		return nil, false
+	}
+	if len(c.HostGroups) > 0 {
+		matched := false
+		for _, group := range event["host_groups"].([]interface{}) {
+			if containsFold(c.HostGroups, group.(string)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, false
+		}
+	}
+	if c.MinSeverity != "" && event["status"] == StatusProblem && severityRank(event["severity"].(string)) < severityRank(c.MinSeverity) {
+		return nil, false
+	}
+	if len(c.Statuses) > 0 && !containsFold(c.Statuses, event["status"].(string)) {
+		return nil, false
+	}
+	return nil, true
+}
+
+// NormalizeMonitoringEvent 将监控系统的Webhook数据转换为统一的事件结构
+//
+// 输出字段：source、event_id、status（problem、resolved、update、acknowledged）、
+// severity（info、warning、average、high、critical）、original_severity、host、host_groups、
+// name、service、message、tags、timestamp，原始数据保存在 raw 中。
+func NormalizeMonitoringEvent(source string, payload map[string]interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{}, len(payload))
+	for key, value := range payload {
+		fields[normalizeFieldName(key)] = value
+	}
+
+	var event map[string]interface{}
+	switch source {
+	case SourceZabbix:
+		event = normalizeZabbix(fields)
+	case SourceNagios:
+		event = normalizeNagios(fields)
+	default:
+		return nil, fmt.Errorf("unsupported event source: %s", source)
+	}
+	if event["host"] == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+
+	event["source"] = source
+	event["host_groups"] = splitList(field(fields, "hostgroups", "hostgroupnames", "triggerhostgroupname"))
+	event["tags"] = parseTags(fields["tags"], fields["eventtags"], fields["eventtagsjson"])
+	event["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+	if ts := fieldString(fields, "timestamp"); ts != "" {
+		event["timestamp"] = ts
+	}
+	event["raw"] = payload
+	return event, nil
+}
+
+// normalizeZabbix 转换Zabbix Webhook数据，字段对应 {EVENT.ID}、{EVENT.STATUS}、{EVENT.SEVERITY} 等宏
+func normalizeZabbix(fields map[string]interface{}) map[string]interface{} {
+	status := StatusProblem
+	switch strings.ToUpper(fieldString(fields, "status", "eventstatus")) {
+	case "RESOLVED", "OK":
+		status = StatusResolved
+	case "UPDATE":
+		status = StatusUpdate
+	case "":
+		// {EVENT.VALUE}：1为问题，0为恢复
+		if fieldString(fields, "eventvalue", "value") == "0" {
+			status = StatusResolved
+		}
+	}
+
+	severity := fieldString(fields, "severity", "eventseverity", "eventnseverity")
+	normalized, ok := zabbixSeverities[strings.ToLower(severity)]
+	if !ok {
+		normalized = "info"
+	}
+
+	return map[string]interface{}{
+		"event_id":          fieldString(fields, "eventid", "id"),
+		"status":            status,
+		"severity":          normalized,
+		"original_severity": severity,
+		"host":              fieldString(fields, "host", "hostname", "hosthost"),
+		"name":              fieldString(fields, "eventname", "triggername", "name", "subject"),
+		"service":           "",
+		"message":           fieldString(fields, "message", "eventmessage", "opdata", "eventopdata"),
+	}
+}
+
+// normalizeNagios 转换Nagios通知数据，字段对应 $NOTIFICATIONTYPE$、$HOSTNAME$、$SERVICESTATE$ 等宏
+//
+// 包含 servicedesc 时为服务通知，否则为主机通知。
+func normalizeNagios(fields map[string]interface{}) map[string]interface{} {
+	notification := strings.ToUpper(fieldString(fields, "notificationtype", "type"))
+	status := strings.ToLower(notification)
+	switch notification {
+	case "PROBLEM", "":
+		status = StatusProblem
+	case "RECOVERY":
+		status = StatusResolved
+	case "ACKNOWLEDGEMENT":
+		status = StatusAcknowledged
+	}
+
+	host := fieldString(fields, "hostname", "host")
+	service := fieldString(fields, "servicedesc", "service", "servicedisplayname")
+	var state, name, message, eventID string
+	if service != "" {
+		state = fieldString(fields, "servicestate", "state")
+		name = service
+		message = fieldString(fields, "serviceoutput", "output", "longserviceoutput")
+		eventID = fieldString(fields, "serviceproblemid", "problemid")
+	} else {
+		state = fieldString(fields, "hoststate", "state")
+		name = fmt.Sprintf("Host %s is %s", host, strings.ToUpper(state))
+		message = fieldString(fields, "hostoutput", "output", "longhostoutput")
+		eventID = fieldString(fields, "hostproblemid", "problemid")
+	}
+
+	severity, ok := nagiosSeverities[strings.ToLower(state)]
+	if !ok {
+		severity = "info"
+	}
+
+	return map[string]interface{}{
+		"event_id":          eventID,
+		"status":            status,
+		"severity":          severity,
+		"original_severity": state,
+		"host":              host,
+		"name":              name,
+		"service":           service,
+		"message":           message,
+	}
+}
+
+// normalizeFieldName 统一字段名：小写，去掉 nagios_ 前缀、下划线、点和花括号，
+// 使 event_id、EVENT.ID、{EVENT.ID}、NAGIOS_HOSTNAME 等写法都能匹配
+func normalizeFieldName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.TrimPrefix(name, "nagios_")
+	return strings.NewReplacer("_", "", ".", "", "{", "", "}", "", "-", "").Replace(name)
+}
+
+// field 返回第一个存在的字段值
+func field(fields map[string]interface{}, names ...string) interface{} {
+	for _, name := range names {
+		if value, ok := fields[name]; ok && value != nil && value != "" {
+			return value
+		}
+	}
+	return nil
+}
+
+// fieldString 返回第一个存在的字段的字符串值
+func fieldString(fields map[string]interface{}, names ...string) string {
+	switch v := field(fields, names...).(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// splitList 将逗号分隔的字符串或数组转换为字符串数组
+func splitList(value interface{}) []interface{} {
+	result := []interface{}{}
+	switch v := value.(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// parseTags 解析标签，支持 {EVENT.TAGSJSON} 格式的数组（[{"tag": "k", "value": "v"}]）、
+// 对象和 {EVENT.TAGS} 格式的字符串（k:v, k2:v2）
+func parseTags(values ...interface{}) map[string]interface{} {
+	tags := map[string]interface{}{}
+	for _, value := range values {
+		if s, ok := value.(string); ok && strings.HasPrefix(strings.TrimSpace(s), "[") {
+			var decoded interface{}
+			if json.Unmarshal([]byte(s), &decoded) == nil {
+				value = decoded
+			}
+		}
+
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				if tag, ok := item.(map[string]interface{}); ok {
+					if name, _ := tag["tag"].(string); name != "" {
+						tags[name] = tag["value"]
+					}
+				}
+			}
+		case map[string]interface{}:
+			for name, tagValue := range v {
+				tags[name] = tagValue
+			}
+		case string:
+			for _, item := range strings.Split(v, ",") {
+				name, tagValue, _ := strings.Cut(strings.TrimSpace(item), ":")
+				if name != "" {
+					tags[name] = tagValue
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// severityRank 返回严重级别的顺序，未知级别返回-1
+func severityRank(severity string) int {
+	for i, level := range severityLevels {
+		if level == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// paramStrings 将字符串数组参数转换为[]string，从MongoDB读取的数组为 primitive.A
+func paramStrings(value interface{}) []string {
+	var items []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case primitive.A:
+		items = v
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// containsFold 判断列表中是否包含指定值（忽略大小写）
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package trigger
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"nsa/internal/config"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	// oidSysUpTime sysUpTime.0，SNMPv2 Trap的第一个变量绑定
+	oidSysUpTime = "1.3.6.1.2.1.1.3.0"
+	// oidSnmpTrapOID snmpTrapOID.0，SNMPv2 Trap的第二个变量绑定，值为Trap类型
+	oidSnmpTrapOID = "1.3.6.1.6.3.1.1.4.1.0"
+	// oidStandardTraps SNMPv1通用Trap对应的SNMPv2 Trap OID前缀（RFC 3584）
+	oidStandardTraps = "1.3.6.1.6.3.1.1.5"
+)
+
+// builtinOIDs 内置的SNMPv2-MIB、IF-MIB对象名称，配置中的 snmp_trap.oids 可以覆盖或补充
+var builtinOIDs = map[string]string{
+	"1.3.6.1.2.1.1.1":         "sysDescr",
+	"1.3.6.1.2.1.1.3":         "sysUpTime",
+	"1.3.6.1.2.1.1.5":         "sysName",
+	"1.3.6.1.2.1.1.6":         "sysLocation",
+	"1.3.6.1.6.3.1.1.4.1":     "snmpTrapOID",
+	"1.3.6.1.6.3.1.1.4.3":     "snmpTrapEnterprise",
+	"1.3.6.1.6.3.1.1.5.1":     "coldStart",
+	"1.3.6.1.6.3.1.1.5.2":     "warmStart",
+	"1.3.6.1.6.3.1.1.5.3":     "linkDown",
+	"1.3.6.1.6.3.1.1.5.4":     "linkUp",
+	"1.3.6.1.6.3.1.1.5.5":     "authenticationFailure",
+	"1.3.6.1.6.3.18.1.3":      "snmpTrapAddress",
+	"1.3.6.1.6.3.18.1.4":      "snmpTrapCommunity",
+	"1.3.6.1.2.1.2.2.1.1":     "ifIndex",
+	"1.3.6.1.2.1.2.2.1.2":     "ifDescr",
+	"1.3.6.1.2.1.2.2.1.3":     "ifType",
+	"1.3.6.1.2.1.2.2.1.7":     "ifAdminStatus",
+	"1.3.6.1.2.1.2.2.1.8":     "ifOperStatus",
+	"1.3.6.1.2.1.31.1.1.1.1":  "ifName",
+	"1.3.6.1.2.1.31.1.1.1.18": "ifAlias",
+}
+
+// ifStatusValues IF-MIB中 ifAdminStatus、ifOperStatus 的枚举值
+var ifStatusValues = map[int]string{
+	1: "up", 2: "down", 3: "testing", 4: "unknown", 5: "dormant", 6: "notPresent", 7: "lowerLayerDown",
+}
+
+// builtinEnums 内置对象的枚举值
+var builtinEnums = map[string]map[int]string{
+	"ifAdminStatus": ifStatusValues,
+	"ifOperStatus":  ifStatusValues,
+}
+
+// snmpTrapConfig snmp_trap 触发器配置
+type snmpTrapConfig struct {
+	TrapOIDs []string
+	Agents   []*net.IPNet
+}
+
+// parseSNMPTrap 解析 snmp_trap 触发器参数
+func parseSNMPTrap(params map[string]interface{}) (*snmpTrapConfig, error) {
+	cfg := &snmpTrapConfig{}
+	for _, oid := range paramStrings(params["trap_oids"]) {
+		cfg.TrapOIDs = append(cfg.TrapOIDs, strings.TrimPrefix(oid, "."))
+	}
+	for _, agent := range paramStrings(params["agents"]) {
+		cidr := agent
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid agents entry %q: must be an IP address or CIDR", agent)
+		}
+		cfg.Agents = append(cfg.Agents, network)
+	}
+	return cfg, nil
+}
+
+// match 判断Trap是否路由到该工作流：Trap类型在 trap_oids 中（OID或名称），发送方地址在 agents 中
+func (c *snmpTrapConfig) match(event map[string]interface{}) (map[string]interface{}, bool) {
+	if len(c.TrapOIDs) > 0 && !containsFold(c.TrapOIDs, event["trap_oid"].(string)) && !containsFold(c.TrapOIDs, event["trap_name"].(string)) {
+		return nil, false
+	}
+	if len(c.Agents) > 0 {
+		ip := net.ParseIP(event["agent_address"].(string))
+		matched := false
+		for _, network := range c.Agents {
+			if ip != nil && network.Contains(ip) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, false
+		}
+	}
+	return nil, true
+}
+
+// StartSNMPTrapReceiver 启动SNMP Trap接收，每个Trap按 snmp_trap 触发器路由到匹配的工作流
+//
+// 支持SNMPv1、v2c的Trap和Inform；监听失败（如端口被占用）时返回错误。
+func (m *Manager) StartSNMPTrapReceiver(cfg config.SNMPTrapConfig) error {
+	listener := gosnmp.NewTrapListener()
+	listener.Params = &gosnmp.GoSNMP{Version: gosnmp.Version2c, Logger: gosnmp.Default.Logger}
+	listener.OnNewTrap = func(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+		m.handleTrap(cfg, packet, addr)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- listener.Listen(cfg.ListenAddress)
+	}()
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to listen for SNMP traps on %s: %v", cfg.ListenAddress, err)
+	case <-listener.Listening():
+	}
+
+	m.mu.Lock()
+	m.trapListener = listener
+	m.mu.Unlock()
+
+	m.logger.Infof("SNMP trap receiver listening on udp %s", cfg.ListenAddress)
+	return nil
+}
+
+// StopSNMPTrapReceiver 停止SNMP Trap接收
+func (m *Manager) StopSNMPTrapReceiver() {
+	m.mu.Lock()
+	listener := m.trapListener
+	m.trapListener = nil
+	m.mu.Unlock()
+
+	if listener != nil {
+		listener.Close()
+		m.logger.Info("SNMP trap receiver stopped")
+	}
+}
+
+// handleTrap 校验community，解码Trap后分发
+func (m *Manager) handleTrap(cfg config.SNMPTrapConfig, packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+	if len(cfg.Communities) > 0 && !contains(cfg.Communities, packet.Community) {
+		m.logger.Warnf("Dropped SNMP trap from %s: community not allowed", addr.IP)
+		return
+	}
+
+	event := DecodeSNMPTrap(packet, addr.IP.String(), cfg.OIDs)
+	workflows := m.Dispatch(TypeSNMPTrap, event)
+	m.logger.Infof("Received SNMP trap %s (%s) from %s, routed to %d workflows",
+		event["trap_name"], event["trap_oid"], event["agent_address"], len(workflows))
+}
+
+// DecodeSNMPTrap 将Trap转换为触发器数据
+//
+// 输出字段：version、community、source_address、agent_address、trap_oid、trap_name、uptime、
+// varbinds（每项包含 oid、name、index、type、value）和 values（名称到值的映射，同名时保留第一个）；
+// SNMPv1 Trap额外包含 enterprise、generic_trap、specific_trap，trap_oid 按RFC 3584转换。
+// 名称按OID最长前缀查找 oids 和内置定义，未知OID以数字形式作为名称。
+func DecodeSNMPTrap(packet *gosnmp.SnmpPacket, source string, oids map[string]string) map[string]interface{} {
+	mib := newOIDNames(oids)
+
+	event := map[string]interface{}{
+		"source_address": source,
+		"agent_address":  source,
+		"community":      packet.Community,
+		"version":        packet.Version.String(),
+		"uptime":         uint32(0),
+		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+	}
+
+	trapOID := ""
+	if packet.Version == gosnmp.Version1 {
+		enterprise := strings.TrimPrefix(packet.Enterprise, ".")
+		if packet.GenericTrap == 6 {
+			trapOID = fmt.Sprintf("%s.0.%d", enterprise, packet.SpecificTrap)
+		} else {
+			trapOID = fmt.Sprintf("%s.%d", oidStandardTraps, packet.GenericTrap+1)
+		}
+		if packet.AgentAddress != "" && packet.AgentAddress != "0.0.0.0" {
+			event["agent_address"] = packet.AgentAddress
+		}
+		event["enterprise"] = enterprise
+		event["generic_trap"] = packet.GenericTrap
+		event["specific_trap"] = packet.SpecificTrap
+		event["uptime"] = uint32(packet.Timestamp)
+	}
+
+	varbinds := []interface{}{}
+	values := map[string]interface{}{}
+	for _, variable := range packet.Variables {
+		oid := strings.TrimPrefix(variable.Name, ".")
+		switch oid {
+		case oidSysUpTime:
+			if ticks, ok := variable.Value.(uint32); ok {
+				event["uptime"] = ticks
+			}
+			continue
+		case oidSnmpTrapOID:
+			if value, ok := variable.Value.(string); ok {
+				trapOID = strings.TrimPrefix(value, ".")
+			}
+			continue
+		}
+
+		name, index := mib.lookup(oid)
+		value := decodeSNMPValue(variable, mib)
+		if enum, ok := builtinEnums[name]; ok {
+			if n, ok := value.(int); ok && enum[n] != "" {
+				value = enum[n]
+			}
+		}
+		varbinds = append(varbinds, map[string]interface{}{
+			"oid":   oid,
+			"name":  name,
+			"index": index,
+			"type":  variable.Type.String(),
+			"value": value,
+		})
+		if _, exists := values[name]; !exists {
+			values[name] = value
+		}
+	}
+
+	trapName, _ := mib.lookup(trapOID)
+	event["trap_oid"] = trapOID
+	event["trap_name"] = trapName
+	event["varbinds"] = varbinds
+	event["values"] = values
+	return event
+}
+
+// oidNames OID到名称的映射
+type oidNames map[string]string
+
+// newOIDNames 合并内置定义和配置的OID名称
+func newOIDNames(oids map[string]string) oidNames {
+	names := make(oidNames, len(builtinOIDs)+len(oids))
+	for oid, name := range builtinOIDs {
+		names[oid] = name
+	}
+	for oid, name := range oids {
+		names[strings.TrimPrefix(oid, ".")] = name
+	}
+	return names
+}
+
+// lookup 按最长前缀查找OID的名称，返回名称和剩余的实例索引（如 ifIndex.3 返回 ifIndex、3）
+func (n oidNames) lookup(oid string) (string, string) {
+	if oid == "" {
+		return "", ""
+	}
+	for prefix := oid; prefix != ""; {
+		if name, ok := n[prefix]; ok {
+			return name, strings.TrimPrefix(strings.TrimPrefix(oid, prefix), ".")
+		}
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return oid, ""
+}
+
+// decodeSNMPValue 转换变量绑定的值：可打印的字符串按文本返回，其余字节以冒号分隔的十六进制返回，OID值解析为名称
+func decodeSNMPValue(variable gosnmp.SnmpPDU, mib oidNames) interface{} {
+	switch variable.Type {
+	case gosnmp.OctetString:
+		b, _ := variable.Value.([]byte)
+		if isPrintable(b) {
+			return string(b)
+		}
+		parts := make([]string, len(b))
+		for i, c := range b {
+			parts[i] = hex.EncodeToString([]byte{c})
+		}
+		return strings.Join(parts, ":")
+	case gosnmp.ObjectIdentifier:
+		oid, _ := variable.Value.(string)
+		oid = strings.TrimPrefix(oid, ".")
+		if name, index := mib.lookup(oid); name != oid {
+			if index != "" {
+				return name + "." + index
+			}
+			return name
+		}
+		return oid
+	case gosnmp.Null, gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+		return nil
+	default:
+		return variable.Value
+	}
+}
+
+// isPrintable 判断字节是否为可打印的UTF-8文本
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// contains 判断列表中是否包含指定值
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
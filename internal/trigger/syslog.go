@@ -0,0 +1,537 @@
+package trigger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+)
+
+// syslogSeverities Syslog严重级别，序号即级别值（0最严重）
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// syslogSeverityAliases 严重级别的其他写法
+var syslogSeverityAliases = map[string]string{
+	"emergency": "emerg",
+	"panic":     "emerg",
+	"critical":  "crit",
+	"error":     "err",
+	"warn":      "warning",
+}
+
+// syslogFacilities Syslog设施，序号即设施值
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// syslogConfig syslog 触发器配置
+type syslogConfig struct {
+	Hosts       []string
+	AppNames    []string
+	Facilities  []string
+	MinSeverity int
+	Pattern     *regexp.Regexp
+}
+
+// parseSyslog 解析 syslog 触发器参数
+//
+// pattern 为正则表达式，grok 为grok表达式（patterns 为自定义grok模式），二者择一，命名分组作为提取的字段。
+func parseSyslog(params map[string]interface{}) (*syslogConfig, error) {
+	cfg := &syslogConfig{
+		Hosts:       paramStrings(params["hosts"]),
+		AppNames:    paramStrings(params["app_names"]),
+		Facilities:  paramStrings(params["facilities"]),
+		MinSeverity: len(syslogSeverities) - 1,
+	}
+	pattern, _ := params["pattern"].(string)
+	grok, _ := params["grok"].(string)
+	minSeverity, _ := params["min_severity"].(string)
+
+	for _, facility := range cfg.Facilities {
+		if syslogFacility(facility) < 0 {
+			return nil, fmt.Errorf("unknown facility %q", facility)
+		}
+	}
+	if minSeverity != "" {
+		cfg.MinSeverity = syslogSeverity(minSeverity)
+		if cfg.MinSeverity < 0 {
+			return nil, fmt.Errorf("min_severity must be one of %s", strings.Join(syslogSeverities, ", "))
+		}
+	}
+
+	switch {
+	case pattern != "" && grok != "":
+		return nil, fmt.Errorf("pattern and grok parameters are mutually exclusive")
+	case pattern != "":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %v", err)
+		}
+		cfg.Pattern = re
+	case grok != "":
+		custom := map[string]string{}
+		if patterns, ok := params["patterns"].(map[string]interface{}); ok {
+			for name, value := range patterns {
+				custom[name], _ = value.(string)
+			}
+		}
+		re, err := compileGrok(grok, custom)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grok: %v", err)
+		}
+		cfg.Pattern = re
+	}
+	return cfg, nil
+}
+
+// match 判断消息是否路由到该工作流：主机（主机名或发送方地址）、程序名、设施匹配，严重级别不低于 min_severity，
+// 消息内容匹配 pattern/grok，返回命名分组的值
+func (c *syslogConfig) match(event map[string]interface{}) (map[string]interface{}, bool) {
+	if len(c.Hosts) > 0 && !containsFold(c.Hosts, event["host"].(string)) && !containsFold(c.Hosts, event["source_address"].(string)) {
+		return nil, false
+	}
+	if len(c.AppNames) > 0 && !containsFold(c.AppNames, event["app_name"].(string)) {
+		return nil, false
+	}
+	if len(c.Facilities) > 0 && !containsFold(c.Facilities, event["facility"].(string)) {
+		return nil, false
+	}
+	if event["severity_code"].(int) > c.MinSeverity {
+		return nil, false
+	}
+	if c.Pattern != nil {
+		return captureFields(c.Pattern, event["message"].(string))
+	}
+	return nil, true
+}
+
+// syslogSeverity 返回严重级别的值，未知级别返回-1
+func syslogSeverity(name string) int {
+	name = strings.ToLower(name)
+	if alias, ok := syslogSeverityAliases[name]; ok {
+		name = alias
+	}
+	for i, severity := range syslogSeverities {
+		if severity == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// syslogFacility 返回设施的值，未知设施返回-1
+func syslogFacility(name string) int {
+	for i, facility := range syslogFacilities {
+		if strings.EqualFold(facility, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseSyslogMessage 解析RFC 5424或RFC 3164（BSD）格式的Syslog消息
+//
+// 输出字段：format、facility、facility_code、severity、severity_code、timestamp、host、app_name、
+// proc_id、msg_id、structured_data、message、source_address、raw。主机名缺失时使用发送方地址；
+// 没有PRI的消息按 user.notice 处理。
+func ParseSyslogMessage(raw string, source string) map[string]interface{} {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+	event := map[string]interface{}{
+		"format":          "rfc3164",
+		"timestamp":       time.Now().UTC().Format(time.RFC3339),
+		"host":            "",
+		"app_name":        "",
+		"proc_id":         "",
+		"msg_id":          "",
+		"structured_data": map[string]interface{}{},
+		"source_address":  source,
+		"raw":             raw,
+	}
+
+	priority := 13
+	rest := raw
+	if strings.HasPrefix(rest, "<") {
+		if end := strings.IndexByte(rest, '>'); end > 1 && end <= 4 {
+			if p, err := strconv.Atoi(rest[1:end]); err == nil && p <= 191 {
+				priority = p
+				rest = rest[end+1:]
+			}
+		}
+	}
+	event["facility_code"] = priority / 8
+	event["facility"] = syslogFacilities[priority/8]
+	event["severity_code"] = priority % 8
+	event["severity"] = syslogSeverities[priority%8]
+
+	if strings.HasPrefix(rest, "1 ") {
+		parseRFC5424(rest[2:], event)
+	} else {
+		parseRFC3164(rest, event)
+	}
+	if event["host"] == "" {
+		event["host"] = source
+	}
+	return event
+}
+
+// parseRFC5424 解析 VERSION 之后的部分：TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func parseRFC5424(rest string, event map[string]interface{}) {
+	event["format"] = "rfc5424"
+	fields := []string{"timestamp", "host", "app_name", "proc_id", "msg_id"}
+	for _, name := range fields {
+		var token string
+		token, rest = nextToken(rest)
+		if token == "-" {
+			continue
+		}
+		if name == "timestamp" {
+			if t, err := time.Parse(time.RFC3339Nano, token); err == nil {
+				event[name] = t.UTC().Format(time.RFC3339Nano)
+			}
+			continue
+		}
+		event[name] = token
+	}
+
+	if strings.HasPrefix(rest, "-") {
+		rest = strings.TrimPrefix(rest[1:], " ")
+	} else if strings.HasPrefix(rest, "[") {
+		var sd map[string]interface{}
+		sd, rest = parseStructuredData(rest)
+		event["structured_data"] = sd
+	}
+	// 消息可能以UTF-8 BOM开头
+	event["message"] = strings.TrimPrefix(rest, "\ufeff")
+}
+
+// parseStructuredData 解析结构化数据 [id key="value" ...][id2 ...]，返回剩余的消息
+func parseStructuredData(rest string) (map[string]interface{}, string) {
+	sd := map[string]interface{}{}
+	for strings.HasPrefix(rest, "[") {
+		end := -1
+		inQuote := false
+		for i := 1; i < len(rest); i++ {
+			switch c := rest[i]; {
+			case c == '\\' && inQuote:
+				i++
+			case c == '"':
+				inQuote = !inQuote
+			case c == ']' && !inQuote:
+				end = i
+			}
+			if end >= 0 {
+				break
+			}
+		}
+		if end < 0 {
+			break
+		}
+
+		element := rest[1:end]
+		rest = rest[end+1:]
+		id, params := nextToken(element)
+		values := map[string]interface{}{}
+		for params != "" {
+			eq := strings.Index(params, "=\"")
+			if eq < 0 {
+				break
+			}
+			key := strings.TrimSpace(params[:eq])
+			params = params[eq+2:]
+			var value strings.Builder
+			i := 0
+			for ; i < len(params) && params[i] != '"'; i++ {
+				if params[i] == '\\' && i+1 < len(params) {
+					i++
+				}
+				value.WriteByte(params[i])
+			}
+			values[key] = value.String()
+			if i < len(params) {
+				i++
+			}
+			params = strings.TrimLeft(params[i:], " ")
+		}
+		sd[id] = values
+	}
+	return sd, strings.TrimPrefix(rest, " ")
+}
+
+// rfc3164Timestamp BSD Syslog的时间戳格式，不包含年份
+const rfc3164Timestamp = "Jan _2 15:04:05"
+
+// parseRFC3164 解析 TIMESTAMP HOSTNAME TAG[PID]: MSG
+//
+// 设备实现差异较大：时间戳可能是RFC 3339格式，主机名可能缺失，无法识别的部分都作为消息内容。
+func parseRFC3164(rest string, event map[string]interface{}) {
+	if len(rest) >= len(rfc3164Timestamp) {
+		if t, err := time.ParseInLocation(rfc3164Timestamp, rest[:len(rfc3164Timestamp)], time.Local); err == nil {
+			now := time.Now()
+			t = t.AddDate(now.Year(), 0, 0)
+			// 跨年时（如1月1日收到12月31日的消息）时间戳属于上一年
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			event["timestamp"] = t.UTC().Format(time.RFC3339)
+			rest = strings.TrimLeft(rest[len(rfc3164Timestamp):], " ")
+		}
+	}
+	if token, remaining := nextToken(rest); token != "" {
+		if t, err := time.Parse(time.RFC3339Nano, token); err == nil {
+			event["timestamp"] = t.UTC().Format(time.RFC3339Nano)
+			rest = remaining
+		}
+	}
+
+	// 主机名之后是 TAG: 或 TAG[PID]:；第一个词本身就是TAG时没有主机名
+	if token, remaining := nextToken(rest); token != "" && !isSyslogTag(token) {
+		if next, _ := nextToken(remaining); isSyslogTag(next) {
+			event["host"] = token
+			rest = remaining
+		}
+	}
+	if token, remaining := nextToken(rest); isSyslogTag(token) {
+		tag := strings.TrimSuffix(token, ":")
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			event["proc_id"] = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		event["app_name"] = tag
+		rest = remaining
+	}
+	event["message"] = rest
+}
+
+// isSyslogTag 判断是否为 TAG: 或 TAG[PID]: 形式
+func isSyslogTag(token string) bool {
+	if len(token) < 2 || !strings.HasSuffix(token, ":") {
+		return false
+	}
+	tag := strings.TrimSuffix(token, ":")
+	if open := strings.IndexByte(tag, '['); open >= 0 {
+		return open > 0 && strings.HasSuffix(tag, "]")
+	}
+	return !strings.ContainsAny(tag, "[]:")
+}
+
+// nextToken 返回第一个空格之前的部分和之后的剩余部分
+func nextToken(s string) (string, string) {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// syslogReceiver Syslog的UDP和TCP监听
+type syslogReceiver struct {
+	udp net.PacketConn
+	tcp net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// StartSyslogReceiver 启动Syslog接收，每条消息按 syslog 触发器路由到匹配的工作流
+//
+// TCP支持按换行分隔和RFC 6587的长度前缀（octet counting）两种分帧方式。
+func (m *Manager) StartSyslogReceiver(cfg config.SyslogConfig) error {
+	receiver := &syslogReceiver{conns: make(map[net.Conn]struct{})}
+	for _, protocol := range cfg.Protocols {
+		switch protocol {
+		case "udp":
+			conn, err := net.ListenPacket("udp", cfg.ListenAddress)
+			if err != nil {
+				receiver.close()
+				return fmt.Errorf("failed to listen for syslog on udp %s: %v", cfg.ListenAddress, err)
+			}
+			receiver.udp = conn
+		case "tcp":
+			listener, err := net.Listen("tcp", cfg.ListenAddress)
+			if err != nil {
+				receiver.close()
+				return fmt.Errorf("failed to listen for syslog on tcp %s: %v", cfg.ListenAddress, err)
+			}
+			receiver.tcp = listener
+		}
+	}
+
+	handle := func(raw, source, protocol string) {
+		event := ParseSyslogMessage(raw, source)
+		event["protocol"] = protocol
+		if workflows := m.Dispatch(TypeSyslog, event); len(workflows) > 0 {
+			m.logger.Infof("Received syslog message from %s (%s.%s), routed to %d workflows",
+				event["host"], event["facility"], event["severity"], len(workflows))
+		}
+	}
+
+	if receiver.udp != nil {
+		receiver.wg.Add(1)
+		go func() {
+			defer receiver.wg.Done()
+			buf := make([]byte, cfg.MaxMessageSize)
+			for {
+				n, addr, err := receiver.udp.ReadFrom(buf)
+				if err != nil {
+					if errors.Is(err, net.ErrClosed) {
+						return
+					}
+					m.logger.Errorf("Syslog udp read failed: %v", err)
+					continue
+				}
+				host, _, _ := net.SplitHostPort(addr.String())
+				handle(string(buf[:n]), host, "udp")
+			}
+		}()
+	}
+	if receiver.tcp != nil {
+		receiver.wg.Add(1)
+		go func() {
+			defer receiver.wg.Done()
+			for {
+				conn, err := receiver.tcp.Accept()
+				if err != nil {
+					if errors.Is(err, net.ErrClosed) {
+						return
+					}
+					m.logger.Errorf("Syslog tcp accept failed: %v", err)
+					continue
+				}
+				receiver.track(conn, true)
+				receiver.wg.Add(1)
+				go func() {
+					defer receiver.wg.Done()
+					defer receiver.track(conn, false)
+					host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+					err := readSyslogFrames(conn, cfg.MaxMessageSize, func(raw string) {
+						handle(raw, host, "tcp")
+					})
+					if err != nil && !errors.Is(err, net.ErrClosed) {
+						m.logger.Warnf("Syslog tcp connection from %s closed: %v", host, err)
+					}
+				}()
+			}
+		}()
+	}
+
+	m.mu.Lock()
+	m.syslog = receiver
+	m.mu.Unlock()
+
+	m.logger.Infof("Syslog receiver listening on %s %s", strings.Join(cfg.Protocols, "/"), cfg.ListenAddress)
+	return nil
+}
+
+// StopSyslogReceiver 停止Syslog接收，关闭所有连接
+func (m *Manager) StopSyslogReceiver() {
+	m.mu.Lock()
+	receiver := m.syslog
+	m.syslog = nil
+	m.mu.Unlock()
+
+	if receiver != nil {
+		receiver.close()
+		receiver.wg.Wait()
+		m.logger.Info("Syslog receiver stopped")
+	}
+}
+
+// track 记录或移除TCP连接，停止时关闭所有连接
+func (r *syslogReceiver) track(conn net.Conn, add bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if add {
+		r.conns[conn] = struct{}{}
+	} else {
+		delete(r.conns, conn)
+		conn.Close()
+	}
+}
+
+// close 关闭监听和所有TCP连接
+func (r *syslogReceiver) close() {
+	if r.udp != nil {
+		r.udp.Close()
+	}
+	if r.tcp != nil {
+		r.tcp.Close()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for conn := range r.conns {
+		conn.Close()
+	}
+}
+
+// readSyslogFrames 从TCP连接中读取消息，每帧调用一次handle
+//
+// 以数字开头的帧为RFC 6587长度前缀格式（"LEN MSG"），否则按换行分隔；超过 maxSize 的消息被截断。
+func readSyslogFrames(conn io.Reader, maxSize int, handle func(string)) error {
+	reader := bufio.NewReaderSize(conn, 4096)
+	for {
+		first, err := reader.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if first[0] >= '0' && first[0] <= '9' {
+			prefix, err := reader.ReadString(' ')
+			if err != nil {
+				return err
+			}
+			length, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+			if err != nil || length <= 0 {
+				return fmt.Errorf("invalid octet count %q", prefix)
+			}
+			frame := make([]byte, min(length, maxSize))
+			if _, err := io.ReadFull(reader, frame); err != nil {
+				return err
+			}
+			if length > maxSize {
+				if _, err := reader.Discard(length - maxSize); err != nil {
+					return err
+				}
+			}
+			handle(string(frame))
+			continue
+		}
+
+		line, err := readLine(reader, maxSize)
+		if line != "" {
+			handle(line)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// readLine 读取一行（不含换行符），超过 maxSize 的部分被丢弃
+func readLine(reader *bufio.Reader, maxSize int) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if len(line) < maxSize {
+			line = append(line, chunk[:min(len(chunk), maxSize-len(line))]...)
+		}
+		if err != nil || !isPrefix {
+			return string(line), err
+		}
+	}
+}
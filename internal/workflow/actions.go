@@ -6,25 +6,38 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"nsa/internal/datasource"
 	"nsa/internal/logger"
 	"nsa/internal/models"
+	"nsa/internal/secrets"
 
 	"github.com/buke/quickjs-go"
 )
 
 // ActionContext 动作执行上下文
 type ActionContext struct {
-	Logger         logger.Logger
-	DataSourceMgr  *datasource.Manager
-	NSQMessage     *models.NSQMessage
-	WorkflowVars   map[string]interface{}
-	PreviousOutput map[string]interface{}
+	Logger        logger.Logger
+	DataSourceMgr *datasource.Manager
+	Secrets       *secrets.Store
+	// HTTPTransports HTTP节点共享的传输层
+	HTTPTransports *httpTransportPool
+	// JSPool JS Function 节点共享的运行时池
+	JSPool *jsPool
+	// Scripts JS Function 节点 require 的脚本模块
+	Scripts *scriptStore
+}
+
+// getSecret 从密钥存储读取密钥
+func (c *ActionContext) getSecret(ctx context.Context, name string) (string, error) {
+	if c.Secrets == nil {
+		return "", fmt.Errorf("secret store is not available")
+	}
+	return c.Secrets.Get(ctx, name)
 }
 
 // HTTPClientAction HTTP客户端动作
@@ -44,8 +57,17 @@ func (a *HTTPClientAction) Name() string {
 
 // TaskContext 任务上下文
 type TaskContext struct {
-	params map[string]interface{}
-	output interface{}
+	taskID   string
+	params   map[string]interface{}
+	output   interface{}
+	message  *models.NSQMessage
+	vars     map[string]interface{}
+	results  map[string]interface{}
+	metadata map[string]interface{}
+	instance *WorkflowInstance
+	// console 脚本的控制台输出，写入执行日志
+	console          []string
+	consoleTruncated bool
 }
 
 // GetParams 获取参数
@@ -53,6 +75,38 @@ func (tc *TaskContext) GetParams() map[string]interface{} {
 	return tc.params
 }
 
+// GetMessage 获取触发工作流的NSQ消息
+func (tc *TaskContext) GetMessage() *models.NSQMessage {
+	return tc.message
+}
+
+// IdempotencyKey 返回任务的幂等键，由实例ID和任务ID组成，重试和服务重启后重新执行时保持不变
+func (tc *TaskContext) IdempotencyKey() string {
+	if tc.instance == nil {
+		return tc.taskID
+	}
+	return tc.instance.ID + "/" + tc.taskID
+}
+
+// SetMetadata 设置附加信息（写入执行日志）
+func (tc *TaskContext) SetMetadata(key string, value interface{}) {
+	if tc.metadata == nil {
+		tc.metadata = make(map[string]interface{})
+	}
+	tc.metadata[key] = value
+}
+
+// GetMetadata 获取附加信息
+func (tc *TaskContext) GetMetadata() map[string]interface{} {
+	return tc.metadata
+}
+
+// SetConsole 设置控制台输出（写入执行日志）
+func (tc *TaskContext) SetConsole(lines []string, truncated bool) {
+	tc.console = lines
+	tc.consoleTruncated = truncated
+}
+
 // SetOutput 设置输出
 func (tc *TaskContext) SetOutput(output interface{}) {
 	tc.output = output
@@ -72,6 +126,7 @@ func (a *HTTPClientAction) Run(ctx context.Context, taskCtx *TaskContext) error
 	method, _ := params["method"].(string)
 	headers, _ := params["headers"].(map[string]interface{})
 	body, _ := params["body"]
+	bodyType, _ := params["body_type"].(string) // json, form, multipart, raw
 	timeout, _ := params["timeout"].(float64)
 
 	if url == "" {
@@ -85,54 +140,75 @@ func (a *HTTPClientAction) Run(ctx context.Context, taskCtx *TaskContext) error
 	}
 
 	// 替换模板变量
-	url = a.replaceTemplateVars(url)
-
-	// 准备请求体
-	var reqBody io.Reader
-	if body != nil {
-		bodyBytes, err := json.Marshal(body)
-		if err != nil {
-			return fmt.Errorf("failed to marshal request body: %v", err)
+	url = renderTemplate(url, taskCtx)
+	if query, ok := resolveValue(params["query"], taskCtx).(map[string]interface{}); ok {
+		var err error
+		if url, err = withQuery(url, query); err != nil {
+			return err
 		}
-		reqBody = bytes.NewReader(bodyBytes)
 	}
 
-	// 创建HTTP客户端
-	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
+	// 准备请求体，重试时重复使用；表单、multipart和raw请求体中的模板在此渲染
+	if bodyType != "" && bodyType != "json" {
+		body = resolveValue(body, taskCtx)
+	}
+	files, err := parseHTTPFiles(resolveValue(params["files"], taskCtx))
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 && bodyType != "multipart" {
+		return fmt.Errorf("files parameter requires body_type multipart")
+	}
+	reqBody, contentType, err := encodeHTTPBody(bodyType, body, files)
+	if err != nil {
+		return err
+	}
+	retry, err := parseHTTPRetry(params["retry"])
+	if err != nil {
+		return err
+	}
+	expectStatus, err := parseExpectStatus(params["expect_status"])
+	if err != nil {
+		return err
+	}
+	assertions, err := parseHTTPAssertions(resolveValue(params["assertions"], taskCtx))
+	if err != nil {
+		return err
 	}
 
-	// 创建请求
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	// 创建HTTP客户端
+	client, err := a.httpClient(ctx, params, time.Duration(timeout)*time.Second)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return err
 	}
 
 	// 设置请求头
+	header := http.Header{}
 	for key, value := range headers {
 		if strValue, ok := value.(string); ok {
-			req.Header.Set(key, a.replaceTemplateVars(strValue))
+			header.Set(key, renderTemplate(strValue, taskCtx))
 		}
 	}
+	if err := a.setAuth(ctx, params, header); err != nil {
+		return err
+	}
 
-	// 设置默认Content-Type
-	if body != nil && req.Header.Get("Content-Type") == "" {
-		req.Header.Set("Content-Type", "application/json")
+	// 设置默认Content-Type，multipart的boundary由请求体决定
+	if contentType != "" && (header.Get("Content-Type") == "" || bodyType == "multipart") {
+		header.Set("Content-Type", contentType)
+	}
+
+	// 非只读请求带上幂等键，实例恢复后重新执行时服务端可以识别重复请求
+	if upper := strings.ToUpper(method); upper != http.MethodGet && upper != http.MethodHead && header.Get("Idempotency-Key") == "" {
+		header.Set("Idempotency-Key", taskCtx.IdempotencyKey())
 	}
 
 	a.ctx.Logger.Infof("Executing HTTP request: %s %s", method, url)
 
 	// 执行请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
+	resp, respBody, attempts, err := doHTTPWithRetry(ctx, client, method, url, header, reqBody, retry, a.ctx.Logger)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %v", err)
+		return err
 	}
 
 	// 解析响应
@@ -147,10 +223,25 @@ func (a *HTTPClientAction) Run(ctx context.Context, taskCtx *TaskContext) error
 	// 添加响应元数据
 	result["status_code"] = resp.StatusCode
 	result["headers"] = resp.Header
+	result["attempts"] = attempts
+
+	// 检查HTTP状态码，配置了 expect_status 时以其为准
+	if expectStatus != nil {
+		if !statusExpected(expectStatus, resp.StatusCode) {
+			if resp.StatusCode >= 400 {
+				return &restError{StatusCode: resp.StatusCode, Body: string(respBody)}
+			}
+			return &httpAssertionError{message: fmt.Sprintf("unexpected status %d, expected %s: %s", resp.StatusCode, formatStatusRanges(expectStatus), tailString(string(respBody), maxErrorBodySize))}
+		}
+	} else if resp.StatusCode >= 400 {
+		return &restError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
 
-	// 检查HTTP状态码
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, string(respBody))
+	// 检查响应体断言
+	if len(assertions) > 0 {
+		if failures := checkHTTPAssertions(assertions, respBody); len(failures) > 0 {
+			return &httpAssertionError{message: fmt.Sprintf("%d of %d response assertions failed: %s", len(failures), len(assertions), strings.Join(failures, "; "))}
+		}
 	}
 
 	// 保存结果
@@ -176,51 +267,73 @@ func (a *DBClientAction) Name() string {
 }
 
 // Run 执行数据库操作
+//
+// operation 为 query、exec、transaction 或 call。sql 的参数可以是按位置的 params，或按名称绑定的 named_params
+// （SQL中写作 :name，值中的模板会被渲染）；transaction 在一个事务中按顺序执行 statements，任一语句失败时回滚；
+// call 调用存储过程 procedure，arguments 为按顺序的IN/OUT参数，OUT参数的值按名称写入输出的 out。
 func (a *DBClientAction) Run(ctx context.Context, taskCtx *TaskContext) error {
 	params := taskCtx.GetParams()
 
 	// 解析参数
 	dataSourceName, _ := params["datasource"].(string)
-	sqlQuery, _ := params["sql"].(string)
-	queryParams, _ := params["params"].([]interface{})
-	operationType, _ := params["operation"].(string) // query, exec
+	operationType, _ := params["operation"].(string) // query, exec, transaction, call
 
 	if dataSourceName == "" {
 		return fmt.Errorf("datasource parameter is required")
 	}
-	if sqlQuery == "" {
-		return fmt.Errorf("sql parameter is required")
-	}
 	if operationType == "" {
 		operationType = "query"
 	}
 
-	// 替换模板变量
-	sqlQuery = a.replaceTemplateVars(sqlQuery)
-
 	// 获取数据库连接
 	db, err := a.ctx.DataSourceMgr.GetSQLDB(dataSourceName)
 	if err != nil {
 		return fmt.Errorf("failed to get database connection: %v", err)
 	}
-
-	a.ctx.Logger.Infof("Executing SQL %s: %s", operationType, sqlQuery)
+	dbType, err := a.ctx.DataSourceMgr.GetDataSourceType(dataSourceName)
+	if err != nil {
+		return err
+	}
+	masked, err := a.maskedColumns(dataSourceName, taskCtx)
+	if err != nil {
+		return err
+	}
 
 	var result interface{}
 
 	switch operationType {
-	case "query":
-		result, err = a.executeQuery(db, sqlQuery, queryParams)
-	case "exec":
-		result, err = a.executeExec(db, sqlQuery, queryParams)
+	case "query", "exec":
+		stmt, err := a.parseStatement(params, operationType, dbType, taskCtx)
+		if err != nil {
+			return err
+		}
+		stmt.masked = masked
+		if operationType == "query" && params["page_size"] != nil {
+			result, err = a.executePagedQuery(ctx, db, dbType, stmt, params, taskCtx)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		a.ctx.Logger.Infof("Executing SQL %s: %s", operationType, stmt.query)
+		result, err = a.runStatement(ctx, db, stmt)
+		if err != nil {
+			return err
+		}
+	case "transaction":
+		result, err = a.executeTransaction(ctx, db, dbType, params, masked, taskCtx)
+		if err != nil {
+			return err
+		}
+	case "call":
+		result, err = a.executeCall(ctx, db, dbType, params, masked, taskCtx)
+		if err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unsupported operation type: %s", operationType)
 	}
 
-	if err != nil {
-		return err
-	}
-
 	// 保存结果
 	taskCtx.SetOutput(result)
 	a.ctx.Logger.Infof("SQL %s completed successfully", operationType)
@@ -228,24 +341,228 @@ func (a *DBClientAction) Run(ctx context.Context, taskCtx *TaskContext) error {
 	return nil
 }
 
+// parseStatement 解析单条语句的 sql、params、named_params 和 operation 参数
+func (a *DBClientAction) parseStatement(spec map[string]interface{}, operation, dbType string, taskCtx *TaskContext) (*sqlStatement, error) {
+	stmt := &sqlStatement{operation: operation}
+	stmt.name, _ = spec["name"].(string)
+	stmt.query, _ = spec["sql"].(string)
+	if op, _ := spec["operation"].(string); op != "" {
+		stmt.operation = op
+	}
+	if stmt.query == "" {
+		return nil, fmt.Errorf("sql parameter is required")
+	}
+	if stmt.operation != "query" && stmt.operation != "exec" {
+		return nil, fmt.Errorf("unsupported statement operation: %s", stmt.operation)
+	}
+
+	var err error
+	if stmt.maxRows, err = sqlRowLimit(spec["max_rows"], "max_rows", defaultMaxQueryRows); err != nil {
+		return nil, err
+	}
+
+	// 替换模板变量
+	stmt.query = renderTemplate(stmt.query, taskCtx)
+
+	stmt.args, _ = spec["params"].([]interface{})
+	if raw, ok := spec["named_params"]; ok {
+		if stmt.args != nil {
+			return nil, fmt.Errorf("params and named_params are mutually exclusive")
+		}
+		named, ok := resolveValue(raw, taskCtx).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("named_params must be an object")
+		}
+		if stmt.query, stmt.args, err = bindNamedParams(stmt.query, dbType, named); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// runStatement 执行单条语句
+func (a *DBClientAction) runStatement(ctx context.Context, runner sqlRunner, stmt *sqlStatement) (map[string]interface{}, error) {
+	if stmt.operation == "exec" {
+		return a.executeExec(ctx, runner, stmt.query, stmt.args)
+	}
+	return a.executeQuery(ctx, runner, stmt.query, stmt.args, stmt.maxRows, stmt.masked)
+}
+
+// executePagedQuery 按 page_size 分页执行查询，cursor 为上一页输出的 next_cursor
+//
+// 输出的 next_cursor 为空表示没有下一页；分页查询不受 max_rows 限制。
+func (a *DBClientAction) executePagedQuery(ctx context.Context, db *sql.DB, dbType string, stmt *sqlStatement, params map[string]interface{}, taskCtx *TaskContext) (map[string]interface{}, error) {
+	pageSize, err := sqlRowLimit(params["page_size"], "page_size", 0)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := parseQueryCursor(resolveValue(params["cursor"], taskCtx))
+	if err != nil {
+		return nil, err
+	}
+
+	query := pagedQuery(stmt.query, dbType, pageSize, offset)
+	a.ctx.Logger.Infof("Executing SQL query page at offset %d: %s", offset, query)
+	result, err := a.executeQuery(ctx, db, query, stmt.args, pageSize+1, stmt.masked)
+	if err != nil {
+		return nil, err
+	}
+
+	// 多读取的一行只用于判断是否还有下一页
+	rows, _ := result["rows"].([]map[string]interface{})
+	nextCursor := ""
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		nextCursor = strconv.Itoa(offset + pageSize)
+	}
+	return map[string]interface{}{
+		"rows":        rows,
+		"count":       len(rows),
+		"has_more":    nextCursor != "",
+		"next_cursor": nextCursor,
+	}, nil
+}
+
+// executeTransaction 在一个事务中按顺序执行 statements，返回每条语句的结果
+//
+// 每条语句包含 sql、params 或 named_params、operation（默认exec）和可选的 name；isolation 为事务隔离级别。
+// 所有语句在开始事务前解析，任一语句失败时回滚整个事务。
+func (a *DBClientAction) executeTransaction(ctx context.Context, db *sql.DB, dbType string, params map[string]interface{}, masked map[string]bool, taskCtx *TaskContext) (interface{}, error) {
+	items, _ := params["statements"].([]interface{})
+	if len(items) == 0 {
+		return nil, fmt.Errorf("statements parameter is required for transaction")
+	}
+	opts := &sql.TxOptions{}
+	if isolation, _ := params["isolation"].(string); isolation != "" {
+		level, ok := sqlIsolationLevels[isolation]
+		if !ok {
+			return nil, fmt.Errorf("unsupported isolation level: %s", isolation)
+		}
+		opts.Isolation = level
+	}
+
+	statements := make([]*sqlStatement, 0, len(items))
+	for i, item := range items {
+		spec, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("statement %d must be an object", i)
+		}
+		stmt, err := a.parseStatement(spec, "exec", dbType, taskCtx)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %v", i, err)
+		}
+		stmt.masked = masked
+		statements = append(statements, stmt)
+	}
+
+	a.ctx.Logger.Infof("Executing SQL transaction with %d statements", len(statements))
+
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	results := make([]interface{}, 0, len(statements))
+	for i, stmt := range statements {
+		result, err := a.runStatement(ctx, tx, stmt)
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				a.ctx.Logger.Errorf("Failed to roll back transaction: %v", rollbackErr)
+			}
+			if stmt.name != "" {
+				return nil, fmt.Errorf("statement %d (%s) failed, transaction rolled back: %v", i, stmt.name, err)
+			}
+			return nil, fmt.Errorf("statement %d failed, transaction rolled back: %v", i, err)
+		}
+		if stmt.name != "" {
+			result["name"] = stmt.name
+		}
+		results = append(results, result)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	}, nil
+}
+
+// maskedColumns 返回数据源声明的脱敏列（小写列名），工作流被授权查看该数据源的原值时返回nil
+func (a *DBClientAction) maskedColumns(dataSourceName string, taskCtx *TaskContext) (map[string]bool, error) {
+	resolved, columns, err := a.ctx.DataSourceMgr.GetMaskedColumns(dataSourceName)
+	if err != nil || len(columns) == 0 {
+		return nil, err
+	}
+	if instance := taskCtx.instance; instance != nil {
+		for _, name := range instance.Unmask {
+			if name == "*" || name == resolved || name == dataSourceName {
+				return nil, nil
+			}
+		}
+	}
+	masked := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		masked[strings.ToLower(col)] = true
+	}
+	return masked, nil
+}
+
+// executeCall 调用存储过程，输出 out 为OUT和INOUT参数的值
+func (a *DBClientAction) executeCall(ctx context.Context, db *sql.DB, dbType string, params map[string]interface{}, masked map[string]bool, taskCtx *TaskContext) (interface{}, error) {
+	procedure, _ := params["procedure"].(string)
+	if procedure == "" {
+		return nil, fmt.Errorf("procedure parameter is required for call")
+	}
+	items, _ := params["arguments"].([]interface{})
+	procParams, err := parseProcParams(items, dbType, taskCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.ctx.Logger.Infof("Calling procedure %s with %d arguments", procedure, len(procParams))
+	out, err := callProcedure(ctx, db, dbType, procedure, procParams)
+	if err != nil {
+		return nil, err
+	}
+	maskRow(out, masked)
+	return map[string]interface{}{
+		"procedure": procedure,
+		"out":       out,
+	}, nil
+}
+
 // executeQuery 执行查询操作
-func (a *DBClientAction) executeQuery(db *sql.DB, query string, params []interface{}) (interface{}, error) {
-	rows, err := db.Query(query, params...)
+//
+// 逐行读取结果，最多读取 maxRows 行，还有剩余行时停止读取并在结果中设置 truncated，避免大结果集占满内存。
+// masked 中的列（小写列名）的值被替换，执行日志记录的也是替换后的结果。
+func (a *DBClientAction) executeQuery(ctx context.Context, runner sqlRunner, query string, params []interface{}, maxRows int, masked map[string]bool) (map[string]interface{}, error) {
+	rows, err := runner.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %v", err)
 	}
 	defer rows.Close()
 
-	// 获取列名
+	// 获取列名和类型
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %v", err)
 	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %v", err)
+	}
 
 	// 准备结果
 	var results []map[string]interface{}
+	truncated := false
 
 	for rows.Next() {
+		if len(results) >= maxRows {
+			truncated = true
+			break
+		}
+
 		// 创建扫描目标
 		values := make([]interface{}, len(columns))
 		valuePtrs := make([]interface{}, len(columns))
@@ -261,12 +578,9 @@ func (a *DBClientAction) executeQuery(db *sql.DB, query string, params []interfa
 		// 构建结果映射
 		row := make(map[string]interface{})
 		for i, col := range columns {
-			if values[i] != nil {
-				row[col] = values[i]
-			} else {
-				row[col] = nil
-			}
+			row[col] = columnValue(columnTypes[i].DatabaseTypeName(), values[i])
 		}
+		maskRow(row, masked)
 		results = append(results, row)
 	}
 
@@ -274,15 +588,54 @@ func (a *DBClientAction) executeQuery(db *sql.DB, query string, params []interfa
 		return nil, fmt.Errorf("error iterating rows: %v", err)
 	}
 
+	if truncated {
+		a.ctx.Logger.Warnf("Query result truncated to %d rows", maxRows)
+	}
+
 	return map[string]interface{}{
-		"rows":  results,
-		"count": len(results),
+		"rows":      results,
+		"count":     len(results),
+		"truncated": truncated,
 	}, nil
 }
 
+// columnValue 按列类型转换扫描到的值
+//
+// 驱动以[]byte返回的文本列转换为字符串（二进制列除外），整数、小数列转换为数字，日期时间列转换为time.Time，
+// JSON列解析为JSON值；无法解析时保留字符串。
+func columnValue(dbType string, value interface{}) interface{} {
+	data, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	upper := strings.TrimPrefix(strings.ToUpper(dbType), "UNSIGNED ")
+	switch {
+	case strings.Contains(upper, "BLOB"), strings.Contains(upper, "BINARY"), upper == "BYTEA", upper == "RAW", upper == "IMAGE":
+		return data
+	case upper == "JSON", upper == "JSONB":
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err == nil {
+			return decoded
+		}
+	case sqlIntegerTypes[upper]:
+		if n, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+			return n
+		}
+	case sqlDecimalTypes[upper]:
+		if f, err := strconv.ParseFloat(string(data), 64); err == nil {
+			return f
+		}
+	case sqlTimeTypes[upper]:
+		if t, ok := parseSQLTime(string(data)); ok {
+			return t
+		}
+	}
+	return string(data)
+}
+
 // executeExec 执行写入操作
-func (a *DBClientAction) executeExec(db *sql.DB, query string, params []interface{}) (interface{}, error) {
-	result, err := db.Exec(query, params...)
+func (a *DBClientAction) executeExec(ctx context.Context, runner sqlRunner, query string, params []interface{}) (map[string]interface{}, error) {
+	result, err := runner.ExecContext(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute statement: %v", err)
 	}
@@ -328,36 +681,45 @@ func (a *JSFunctionAction) Run(ctx context.Context, taskCtx *TaskContext) error
 
 	a.ctx.Logger.Infof("Executing JavaScript function")
 
-	// 创建QuickJS运行时
-	rt := quickjs.NewRuntime()
-	defer rt.Close()
-
-	ctxJS := rt.NewContext()
-	defer ctxJS.Close()
-
-	// 设置全局变量
-	if err := a.setGlobalVariables(ctxJS); err != nil {
-		return fmt.Errorf("failed to set global variables: %v", err)
-	}
+	// 在池中的运行时上执行，超时或任务取消时中断
+	var output interface{}
+	console := &jsConsole{}
+	err := a.ctx.JSPool.run(ctx, time.Duration(timeout*float64(time.Second)), func(ctxJS *quickjs.Context) error {
+		// 设置全局变量
+		if err := a.setGlobalVariables(ctxJS, taskCtx); err != nil {
+			return fmt.Errorf("failed to set global variables: %v", err)
+		}
+		if err := installRequire(ctx, ctxJS, a.ctx.Scripts); err != nil {
+			return fmt.Errorf("failed to install require: %v", err)
+		}
+		if err := a.ctx.installStdlib(ctx, ctxJS, console); err != nil {
+			return fmt.Errorf("failed to install standard library: %v", err)
+		}
 
-	// 执行JavaScript代码
-	result, err := ctxJS.Eval(jsCode)
+		// 执行JavaScript代码
+		result, err := ctxJS.Eval(jsCode)
+		if err != nil {
+			return err
+		}
+		defer result.Free()
+
+		// 获取结果
+		if result.IsObject() {
+			// 转换为Go对象
+			jsonStr := result.JSONStringify()
+			if err := json.Unmarshal([]byte(jsonStr), &output); err != nil {
+				output = jsonStr
+			}
+		} else {
+			output = result.String()
+		}
+		return nil
+	})
+	// 控制台输出写入执行日志，执行失败时用于排查中断前的进度
+	taskCtx.SetConsole(console.lines, console.truncated)
 	if err != nil {
 		return fmt.Errorf("failed to execute JavaScript: %v", err)
 	}
-	defer result.Free()
-
-	// 获取结果
-	var output interface{}
-	if result.IsObject() {
-		// 转换为Go对象
-		jsonStr := result.JSONStringify()
-		if err := json.Unmarshal([]byte(jsonStr), &output); err != nil {
-			output = jsonStr
-		}
-	} else {
-		output = result.String()
-	}
 
 	// 保存结果
 	taskCtx.SetOutput(output)
@@ -366,103 +728,66 @@ func (a *JSFunctionAction) Run(ctx context.Context, taskCtx *TaskContext) error
 	return nil
 }
 
-// setGlobalVariables 设置JavaScript全局变量
-func (a *JSFunctionAction) setGlobalVariables(ctx *quickjs.Context) error {
+// setGlobalVariables 设置JavaScript全局变量：nsq_message、workflow_vars、previous_output
+func (a *JSFunctionAction) setGlobalVariables(ctx *quickjs.Context, taskCtx *TaskContext) error {
 	// 设置NSQ消息
-	if a.ctx.NSQMessage != nil {
-		msgJSON, _ := json.Marshal(a.ctx.NSQMessage)
+	if taskCtx.message != nil {
+		msgJSON, err := encodeMessage(taskCtx.message)
+		if err != nil {
+			return err
+		}
 		msgValue := ctx.ParseJSON(string(msgJSON))
 		ctx.Globals().Set("nsq_message", msgValue)
-		msgValue.Free()
 	}
 
-	// 设置工作流变量
-	if a.ctx.WorkflowVars != nil {
-		varsJSON, _ := json.Marshal(a.ctx.WorkflowVars)
-		varsValue := ctx.ParseJSON(string(varsJSON))
-		ctx.Globals().Set("workflow_vars", varsValue)
-		varsValue.Free()
+	// 设置工作流变量，消息已通过 nsq_message 传入，不再重复序列化
+	vars := make(map[string]interface{}, len(taskCtx.vars))
+	for name, value := range taskCtx.vars {
+		if name != "nsq_message" {
+			vars[name] = value
+		}
 	}
+	varsJSON, _ := json.Marshal(vars)
+	varsValue := ctx.ParseJSON(string(varsJSON))
+	ctx.Globals().Set("workflow_vars", varsValue)
 
 	// 设置前置节点输出
-	if a.ctx.PreviousOutput != nil {
-		outputJSON, _ := json.Marshal(a.ctx.PreviousOutput)
-		outputValue := ctx.ParseJSON(string(outputJSON))
-		ctx.Globals().Set("previous_output", outputValue)
-		outputValue.Free()
+	results := taskCtx.results
+	if results == nil {
+		results = map[string]interface{}{}
 	}
-
-	// 添加工具函数
-	consoleLog := ctx.Function(func(ctx *quickjs.Context, this quickjs.Value, args []quickjs.Value) quickjs.Value {
-		if len(args) > 0 {
-			a.ctx.Logger.Info("JS Console:", args[0].String())
-		}
-		return ctx.Null()
-	})
-	ctx.Globals().Set("console_log", consoleLog)
-	consoleLog.Free()
+	outputJSON, _ := json.Marshal(results)
+	outputValue := ctx.ParseJSON(string(outputJSON))
+	ctx.Globals().Set("previous_output", outputValue)
 
 	return nil
 }
 
-// replaceTemplateVars 替换模板变量
-func (a *HTTPClientAction) replaceTemplateVars(template string) string {
-	// 替换NSQ消息变量
-	if a.ctx.NSQMessage != nil {
-		for key, value := range a.ctx.NSQMessage.Data {
-			placeholder := fmt.Sprintf("{{nsq.%s}}", key)
-			if strValue, ok := value.(string); ok {
-				template = strings.ReplaceAll(template, placeholder, strValue)
-			}
+// encodeMessage 将NSQ消息编码为JSON
+//
+// 消息体为合法的JSON对象时 data 直接使用原始消息体，不再序列化解析后的map。
+func encodeMessage(message *models.NSQMessage) ([]byte, error) {
+	body := bytes.TrimSpace(message.Body)
+	if len(body) > 0 && body[0] == '{' && json.Valid(body) {
+		type encodedMessage struct {
+			Topic     string          `json:"topic"`
+			Channel   string          `json:"channel"`
+			Body      []byte          `json:"body"`
+			Timestamp time.Time       `json:"timestamp"`
+			Attempts  uint16          `json:"attempts"`
+			ID        string          `json:"id"`
+			Data      json.RawMessage `json:"data"`
 		}
-	}
-
-	// 替换工作流变量
-	for key, value := range a.ctx.WorkflowVars {
-		placeholder := fmt.Sprintf("{{%s}}", key)
-		if strValue, ok := value.(string); ok {
-			template = strings.ReplaceAll(template, placeholder, strValue)
-		}
-	}
-
-	// 替换前置节点输出
-	for key, value := range a.ctx.PreviousOutput {
-		placeholder := fmt.Sprintf("{{output.%s}}", key)
-		if strValue, ok := value.(string); ok {
-			template = strings.ReplaceAll(template, placeholder, strValue)
-		}
-	}
-
-	return template
-}
-
-// replaceTemplateVars 替换模板变量 (DBClientAction)
-func (a *DBClientAction) replaceTemplateVars(template string) string {
-	// 替换NSQ消息变量
-	if a.ctx.NSQMessage != nil {
-		for key, value := range a.ctx.NSQMessage.Data {
-			placeholder := fmt.Sprintf("{{nsq.%s}}", key)
-			if strValue, ok := value.(string); ok {
-				template = strings.ReplaceAll(template, placeholder, strValue)
-			}
-		}
-	}
-
-	// 替换工作流变量
-	for key, value := range a.ctx.WorkflowVars {
-		placeholder := fmt.Sprintf("{{%s}}", key)
-		if strValue, ok := value.(string); ok {
-			template = strings.ReplaceAll(template, placeholder, strValue)
-		}
-	}
-
-	// 替换前置节点输出
-	for key, value := range a.ctx.PreviousOutput {
-		placeholder := fmt.Sprintf("{{output.%s}}", key)
-		if strValue, ok := value.(string); ok {
-			template = strings.ReplaceAll(template, placeholder, strValue)
-		}
-	}
-
-	return template
+		return json.Marshal(encodedMessage{
+			Topic:     message.Topic,
+			Channel:   message.Channel,
+			Body:      message.Body,
+			Timestamp: message.Timestamp,
+			Attempts:  message.Attempts,
+			ID:        message.ID,
+			Data:      body,
+		})
+	}
+	// 消息体不是合法JSON对象时按解析后的数据序列化，data 为 {"raw": ...}
+	return json.Marshal(message)
 }
@@ -0,0 +1,175 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// defaultApprovalTimeout 默认审批超时时间
+	defaultApprovalTimeout = 24 * time.Hour
+	// maxApprovalTimeout 最长审批超时时间
+	maxApprovalTimeout = 30 * 24 * time.Hour
+	// approvalPollInterval 检查审批结果的间隔，审批接口可能由其他服务实例处理，因此轮询数据库
+	approvalPollInterval = 2 * time.Second
+)
+
+// WaitForApprovalAction 人工审批动作，创建审批请求并暂停工作流实例，直到审批人批准、拒绝或超时
+type WaitForApprovalAction struct {
+	ctx      *ActionContext
+	executor *Executor
+}
+
+// NewWaitForApprovalAction 创建人工审批动作
+func NewWaitForApprovalAction(ctx *ActionContext, executor *Executor) *WaitForApprovalAction {
+	return &WaitForApprovalAction{ctx: ctx, executor: executor}
+}
+
+// Name 返回动作名称
+func (a *WaitForApprovalAction) Name() string {
+	return "WaitForApprovalAction"
+}
+
+// Run 创建审批请求并等待审批结果
+//
+// 批准时输出审批人和意见，后续任务继续执行；拒绝或超时（timeout 秒，默认24小时）时任务失败，后续任务不再执行。
+// approvers 限定可以审批的用户名，data 为展示给审批人的上下文数据。
+func (a *WaitForApprovalAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params, _ := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	title, _ := params["title"].(string)
+	description, _ := params["description"].(string)
+	data, _ := params["data"].(map[string]interface{})
+	timeoutSeconds, _ := params["timeout"].(float64)
+
+	approvers := toStringSlice(params["approvers"])
+	if title == "" {
+		title = fmt.Sprintf("Approval required: %s", taskCtx.taskID)
+	}
+	timeout := defaultApprovalTimeout
+	if timeoutSeconds > 0 {
+		timeout = time.Duration(timeoutSeconds) * time.Second
+	}
+	if timeout > maxApprovalTimeout {
+		return fmt.Errorf("timeout exceeds maximum of %d seconds", int(maxApprovalTimeout.Seconds()))
+	}
+
+	now := time.Now()
+	approval := &models.Approval{
+		ID:          primitive.NewObjectID(),
+		TaskID:      taskCtx.taskID,
+		Title:       title,
+		Description: description,
+		Data:        data,
+		Approvers:   approvers,
+		Status:      models.ApprovalPending,
+		ExpiresAt:   now.Add(timeout),
+		CreatedAt:   now,
+	}
+	if taskCtx.instance != nil {
+		approval.WorkflowID = taskCtx.instance.WorkflowID
+		approval.InstanceID = taskCtx.instance.ID
+	}
+
+	collection := a.executor.mongoDB.GetDatabase().Collection("approvals")
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	_, err := collection.InsertOne(insertCtx, approval)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to create approval: %v", err)
+	}
+	a.ctx.Logger.Infof("Waiting for approval %s: %s", approval.ID.Hex(), title)
+
+	decided, err := a.wait(ctx, approval)
+	if err != nil {
+		return err
+	}
+
+	taskCtx.SetOutput(map[string]interface{}{
+		"approval_id": decided.ID.Hex(),
+		"status":      decided.Status,
+		"comment":     decided.Comment,
+		"decided_by":  decided.DecidedBy,
+		"decided_at":  decided.DecidedAt,
+	})
+
+	switch decided.Status {
+	case models.ApprovalApproved:
+	case models.ApprovalRejected:
+		if decided.Comment != "" {
+			return fmt.Errorf("approval %s rejected by %s: %s", decided.ID.Hex(), decided.DecidedBy, decided.Comment)
+		}
+		return fmt.Errorf("approval %s rejected by %s", decided.ID.Hex(), decided.DecidedBy)
+	default:
+		return fmt.Errorf("approval %s %s", decided.ID.Hex(), decided.Status)
+	}
+	a.ctx.Logger.Infof("Approval %s approved by %s", decided.ID.Hex(), decided.DecidedBy)
+	return nil
+}
+
+// wait 轮询审批状态直到审批完成；超时或工作流取消时将审批标记为 expired 或 cancelled 并返回错误
+func (a *WaitForApprovalAction) wait(ctx context.Context, approval *models.Approval) (*models.Approval, error) {
+	collection := a.executor.mongoDB.GetDatabase().Collection("approvals")
+	deadline := time.NewTimer(time.Until(approval.ExpiresAt))
+	defer deadline.Stop()
+	ticker := time.NewTicker(approvalPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.close(approval, models.ApprovalCancelled)
+			return nil, fmt.Errorf("approval %s cancelled: %v", approval.ID.Hex(), ctx.Err())
+		case <-deadline.C:
+			// 截止时刻可能刚好有人审批，以数据库中的结果为准
+			if decided, ok := a.close(approval, models.ApprovalExpired); ok {
+				return decided, nil
+			}
+			return nil, fmt.Errorf("approval %s expired after %s", approval.ID.Hex(), approval.ExpiresAt.Sub(approval.CreatedAt))
+		case <-ticker.C:
+			var current models.Approval
+			findCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err := collection.FindOne(findCtx, bson.M{"_id": approval.ID}).Decode(&current)
+			cancel()
+			if err != nil {
+				// 数据库暂时不可用时继续等待
+				a.ctx.Logger.Warnf("Failed to check approval %s: %v", approval.ID.Hex(), err)
+				continue
+			}
+			if current.Status != models.ApprovalPending {
+				return &current, nil
+			}
+		}
+	}
+}
+
+// close 将仍在等待的审批标记为指定状态；审批已经完成时返回审批结果和 true
+func (a *WaitForApprovalAction) close(approval *models.Approval, status string) (*models.Approval, bool) {
+	collection := a.executor.mongoDB.GetDatabase().Collection("approvals")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": approval.ID, "status": models.ApprovalPending},
+		bson.M{"$set": bson.M{"status": status, "decided_at": time.Now()}},
+	)
+	if err != nil {
+		a.ctx.Logger.Errorf("Failed to mark approval %s as %s: %v", approval.ID.Hex(), status, err)
+		return nil, false
+	}
+	if result.MatchedCount > 0 {
+		return nil, false
+	}
+
+	var current models.Approval
+	if err := collection.FindOne(ctx, bson.M{"_id": approval.ID}).Decode(&current); err != nil {
+		return nil, false
+	}
+	return &current, current.Status == models.ApprovalApproved || current.Status == models.ApprovalRejected
+}
@@ -0,0 +1,596 @@
+package workflow
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"nsa/internal/config"
+)
+
+const (
+	// defaultArchiveMaxSize 解压内容的默认总大小上限(100MB)
+	defaultArchiveMaxSize = 100 << 20
+	// defaultArchiveMaxEntries 解压条目的默认数量上限
+	defaultArchiveMaxEntries = 10000
+)
+
+// ArchiveAction 压缩/解压动作，读写的文件限制在配置的根目录（files.base_dir）下
+type ArchiveAction struct {
+	ctx *ActionContext
+	cfg config.FilesConfig
+}
+
+// NewArchiveAction 创建压缩/解压动作
+func NewArchiveAction(ctx *ActionContext, cfg config.FilesConfig) *ArchiveAction {
+	return &ArchiveAction{ctx: ctx, cfg: cfg}
+}
+
+// Name 返回动作名称
+func (a *ArchiveAction) Name() string {
+	return "ArchiveAction"
+}
+
+// archiveLimits 解压限制
+type archiveLimits struct {
+	maxSize    int64
+	maxEntries int
+	written    int64
+	entries    int
+}
+
+// Run 执行压缩或解压
+func (a *ArchiveAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := taskCtx.GetParams()
+
+	// 解析参数
+	operation, _ := params["operation"].(string) // compress, extract
+	format, _ := params["format"].(string)       // gzip, zip, tar, tar.gz
+	maxSize, _ := params["max_size"].(float64)
+	maxEntries, _ := params["max_entries"].(float64)
+
+	if format == "" {
+		return fmt.Errorf("format parameter is required")
+	}
+	if maxSize <= 0 {
+		maxSize = defaultArchiveMaxSize
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultArchiveMaxEntries
+	}
+
+	limits := &archiveLimits{
+		maxSize:    int64(maxSize),
+		maxEntries: int(maxEntries),
+	}
+
+	a.ctx.Logger.Infof("Executing archive %s with format %s", operation, format)
+
+	var (
+		result map[string]interface{}
+		err    error
+	)
+
+	switch operation {
+	case "compress":
+		result, err = a.compress(params, format, limits)
+	case "extract":
+		result, err = a.extract(params, format, limits)
+	default:
+		return fmt.Errorf("unsupported archive operation: %s", operation)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Archive %s completed successfully", operation)
+
+	return nil
+}
+
+// resolvePath 将文件路径解析为根目录下的绝对路径，未配置根目录时不允许读写文件
+func (a *ArchiveAction) resolvePath(path string) (string, error) {
+	if a.cfg.BaseDir == "" {
+		return "", fmt.Errorf("archive file paths are disabled, set files.base_dir in config.json to enable them")
+	}
+	return resolveBasePath(a.cfg.BaseDir, path)
+}
+
+// entryPath 归档条目在目标目录下的路径，拒绝绝对路径、.. 和通过已存在的符号链接逃逸
+func (a *ArchiveAction) entryPath(dest, name string) (string, error) {
+	target, err := safeJoin(dest, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := a.resolvePath(target); err != nil {
+		return "", fmt.Errorf("illegal path traversal in archive: %s", name)
+	}
+	return target, nil
+}
+
+// compress 将文件列表或内容压缩为归档
+func (a *ArchiveAction) compress(params map[string]interface{}, format string, limits *archiveLimits) (map[string]interface{}, error) {
+	files, _ := params["files"].([]interface{})
+	output, _ := params["output"].(string)
+	content, _ := params["content"].(string)
+	name, _ := params["name"].(string)
+
+	var sources []string
+	for _, f := range files {
+		if path, ok := f.(string); ok && path != "" {
+			source, err := a.resolvePath(path)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 && content == "" {
+		return nil, fmt.Errorf("files or content parameter is required")
+	}
+	if output != "" {
+		var err error
+		if output, err = a.resolvePath(output); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+
+	switch format {
+	case "gzip":
+		err = a.writeGzip(&buf, sources, content, name, limits)
+	case "zip":
+		err = a.writeZip(&buf, sources, content, name, limits)
+	case "tar":
+		err = a.writeTar(&buf, sources, content, name, limits)
+	case "tar.gz", "tgz":
+		gz := gzip.NewWriter(&buf)
+		if err = a.writeTar(gz, sources, content, name, limits); err == nil {
+			err = gz.Close()
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"format":  format,
+		"size":    buf.Len(),
+		"entries": limits.entries,
+	}
+
+	// 没有指定输出路径时以base64返回归档内容
+	if output == "" {
+		result["content"] = base64.StdEncoding.EncodeToString(buf.Bytes())
+		return result, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %v", err)
+	}
+	result["output"] = output
+
+	return result, nil
+}
+
+// writeGzip 写入gzip(仅支持单个文件或内容)
+func (a *ArchiveAction) writeGzip(w io.Writer, sources []string, content, name string, limits *archiveLimits) error {
+	if len(sources) > 1 {
+		return fmt.Errorf("gzip format supports a single file only, use tar.gz for multiple files")
+	}
+
+	data, entryName, err := a.loadSingleSource(sources, content, name)
+	if err != nil {
+		return err
+	}
+	if err := limits.add(int64(len(data))); err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	gz.Name = entryName
+	if _, err := gz.Write(data); err != nil {
+		return fmt.Errorf("failed to write gzip data: %v", err)
+	}
+	return gz.Close()
+}
+
+// writeZip 写入zip归档
+func (a *ArchiveAction) writeZip(w io.Writer, sources []string, content, name string, limits *archiveLimits) error {
+	zw := zip.NewWriter(w)
+
+	err := a.walkSources(sources, content, name, limits, func(entryName string, data []byte, info os.FileInfo) error {
+		header := &zip.FileHeader{
+			Name:   entryName,
+			Method: zip.Deflate,
+		}
+		if info != nil {
+			header.Modified = info.ModTime()
+			header.SetMode(info.Mode())
+		}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to create zip entry %s: %v", entryName, err)
+		}
+		_, err = fw.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeTar 写入tar归档
+func (a *ArchiveAction) writeTar(w io.Writer, sources []string, content, name string, limits *archiveLimits) error {
+	tw := tar.NewWriter(w)
+
+	err := a.walkSources(sources, content, name, limits, func(entryName string, data []byte, info os.FileInfo) error {
+		header := &tar.Header{
+			Name:     entryName,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}
+		if info != nil {
+			header.Mode = int64(info.Mode().Perm())
+			header.ModTime = info.ModTime()
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write tar header %s: %v", entryName, err)
+		}
+		_, err := tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// loadSingleSource 读取单个文件或base64内容
+func (a *ArchiveAction) loadSingleSource(sources []string, content, name string) ([]byte, string, error) {
+	if len(sources) == 1 {
+		data, err := os.ReadFile(sources[0])
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read file %s: %v", sources[0], err)
+		}
+		return data, filepath.Base(sources[0]), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode content: %v", err)
+	}
+	if name == "" {
+		name = "content"
+	}
+	return data, name, nil
+}
+
+// walkSources 遍历待压缩的文件（目录递归展开）
+func (a *ArchiveAction) walkSources(sources []string, content, name string, limits *archiveLimits,
+	fn func(entryName string, data []byte, info os.FileInfo) error) error {
+	if content != "" {
+		data, entryName, err := a.loadSingleSource(nil, content, name)
+		if err != nil {
+			return err
+		}
+		if err := limits.add(int64(len(data))); err != nil {
+			return err
+		}
+		if err := fn(entryName, data, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, source := range sources {
+		root := filepath.Dir(filepath.Clean(source))
+		err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			if err := limits.add(info.Size()); err != nil {
+				return err
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read file %s: %v", path, err)
+			}
+
+			entryName, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			return fn(filepath.ToSlash(entryName), data, info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extract 解压归档到目标目录
+func (a *ArchiveAction) extract(params map[string]interface{}, format string, limits *archiveLimits) (map[string]interface{}, error) {
+	source, _ := params["source"].(string)
+	content, _ := params["content"].(string)
+	dest, _ := params["dest"].(string)
+
+	if dest == "" {
+		return nil, fmt.Errorf("dest parameter is required")
+	}
+	if source == "" && content == "" {
+		return nil, fmt.Errorf("source or content parameter is required")
+	}
+
+	dest, err := a.resolvePath(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	// 读取归档数据
+	var data []byte
+	if source != "" {
+		if source, err = a.resolvePath(source); err != nil {
+			return nil, err
+		}
+		data, err = os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive %s: %v", source, err)
+		}
+	} else {
+		data, err = base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode content: %v", err)
+		}
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dest directory: %v", err)
+	}
+
+	var files []string
+	switch format {
+	case "gzip":
+		name, _ := params["name"].(string)
+		files, err = a.extractGzip(data, dest, name, source, limits)
+	case "zip":
+		files, err = a.extractZip(data, dest, limits)
+	case "tar":
+		files, err = a.extractTar(bytes.NewReader(data), dest, limits)
+	case "tar.gz", "tgz":
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %v", err)
+		}
+		files, err = a.extractTar(gz, dest, limits)
+		gz.Close()
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"format":  format,
+		"dest":    dest,
+		"files":   files,
+		"entries": limits.entries,
+		"size":    limits.written,
+	}, nil
+}
+
+// extractGzip 解压gzip单文件
+func (a *ArchiveAction) extractGzip(data []byte, dest, name, source string, limits *archiveLimits) ([]string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip stream: %v", err)
+	}
+	defer gz.Close()
+
+	// 文件名优先级: 参数 > gzip头 > 源文件名去掉.gz
+	if name == "" {
+		name = gz.Name
+	}
+	if name == "" && source != "" {
+		name = strings.TrimSuffix(filepath.Base(source), ".gz")
+	}
+	if name == "" {
+		name = "content"
+	}
+
+	target, err := a.entryPath(dest, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := limits.addEntry(); err != nil {
+		return nil, err
+	}
+	if err := writeLimitedFile(target, gz, 0644, limits); err != nil {
+		return nil, err
+	}
+
+	return []string{target}, nil
+}
+
+// extractZip 解压zip归档
+func (a *ArchiveAction) extractZip(data []byte, dest string, limits *archiveLimits) ([]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %v", err)
+	}
+
+	var files []string
+	for _, file := range zr.File {
+		if err := limits.addEntry(); err != nil {
+			return files, err
+		}
+
+		target, err := a.entryPath(dest, file.Name)
+		if err != nil {
+			return files, err
+		}
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, fmt.Errorf("failed to create directory %s: %v", target, err)
+			}
+			continue
+		}
+		// 跳过符号链接等非普通文件，防止链接逃逸
+		if !file.Mode().IsRegular() {
+			a.ctx.Logger.Warnf("Skipping non-regular zip entry: %s", file.Name)
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return files, fmt.Errorf("failed to open zip entry %s: %v", file.Name, err)
+		}
+		err = writeLimitedFile(target, rc, file.Mode().Perm(), limits)
+		rc.Close()
+		if err != nil {
+			return files, err
+		}
+		files = append(files, target)
+	}
+
+	return files, nil
+}
+
+// extractTar 解压tar归档
+func (a *ArchiveAction) extractTar(r io.Reader, dest string, limits *archiveLimits) ([]string, error) {
+	tr := tar.NewReader(r)
+
+	var files []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, fmt.Errorf("failed to read tar entry: %v", err)
+		}
+
+		if err := limits.addEntry(); err != nil {
+			return files, err
+		}
+
+		target, err := a.entryPath(dest, header.Name)
+		if err != nil {
+			return files, err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, fmt.Errorf("failed to create directory %s: %v", target, err)
+			}
+		case tar.TypeReg:
+			if err := writeLimitedFile(target, tr, os.FileMode(header.Mode).Perm(), limits); err != nil {
+				return files, err
+			}
+			files = append(files, target)
+		default:
+			// 跳过符号链接、设备文件等，防止链接逃逸
+			a.ctx.Logger.Warnf("Skipping unsupported tar entry: %s", header.Name)
+		}
+	}
+
+	return files, nil
+}
+
+// add 累加写入大小并检查上限
+func (l *archiveLimits) add(size int64) error {
+	l.entries++
+	if l.entries > l.maxEntries {
+		return fmt.Errorf("archive exceeds max entries limit %d", l.maxEntries)
+	}
+	l.written += size
+	if l.written > l.maxSize {
+		return fmt.Errorf("archive exceeds max size limit %d bytes", l.maxSize)
+	}
+	return nil
+}
+
+// addEntry 累加条目数并检查上限
+func (l *archiveLimits) addEntry() error {
+	l.entries++
+	if l.entries > l.maxEntries {
+		return fmt.Errorf("archive exceeds max entries limit %d", l.maxEntries)
+	}
+	return nil
+}
+
+// safeJoin 拼接路径并防止目录穿越
+func safeJoin(base, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("illegal absolute path in archive: %s", name)
+	}
+
+	target := filepath.Join(base, name)
+	rel, err := filepath.Rel(base, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal path traversal in archive: %s", name)
+	}
+
+	return target, nil
+}
+
+// writeLimitedFile 写入文件并限制总大小（防止解压炸弹）
+func writeLimitedFile(target string, r io.Reader, mode os.FileMode, limits *archiveLimits) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %v", target, err)
+	}
+	if mode == 0 {
+		mode = 0644
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %v", target, err)
+	}
+	defer file.Close()
+
+	remaining := limits.maxSize - limits.written
+	n, err := io.Copy(file, io.LimitReader(r, remaining+1))
+	limits.written += n
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %v", target, err)
+	}
+	if n > remaining {
+		file.Close()
+		os.Remove(target)
+		return fmt.Errorf("archive exceeds max size limit %d bytes", limits.maxSize)
+	}
+
+	return nil
+}
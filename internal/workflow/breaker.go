@@ -0,0 +1,228 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+)
+
+// 熔断器状态
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// targetURLParams 动作参数中表示HTTP目标地址的参数，按顺序取第一个
+var targetURLParams = []string{"url", "base_url", "events_url", "instance_url"}
+
+// BreakerState 熔断器状态
+type BreakerState struct {
+	Target    string    `json:"target"` // datasource:<名称> 或 http:<主机>
+	State     string    `json:"state"`  // closed、open、half_open
+	Failures  int       `json:"failures"`
+	OpenedAt  time.Time `json:"opened_at,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// breaker 单个目标的熔断器
+type breaker struct {
+	state     string
+	failures  int // 连续失败次数
+	openedAt  time.Time
+	probes    int // 半开状态正在执行的探测请求数
+	lastError string
+}
+
+// circuitBreakers 按数据源和HTTP主机统计连续失败，达到阈值后熔断，熔断期间任务直接失败
+//
+// 熔断 open_timeout 秒后进入半开状态，放行最多 half_open_probes 个探测请求：探测成功则恢复，失败则重新熔断。
+type circuitBreakers struct {
+	mu       sync.Mutex
+	cfg      config.CircuitBreakerConfig
+	breakers map[string]*breaker
+}
+
+// newCircuitBreakers 创建熔断器集合
+func newCircuitBreakers(cfg config.CircuitBreakerConfig) *circuitBreakers {
+	return &circuitBreakers{cfg: cfg, breakers: make(map[string]*breaker)}
+}
+
+// errCircuitOpen 目标已熔断
+type errCircuitOpen struct {
+	target   string
+	retryAt  time.Time
+	lastErr  string
+	failures int
+}
+
+// Error 实现error接口
+func (e *errCircuitOpen) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open after %d consecutive failures (last error: %s), retry after %s",
+		e.target, e.failures, e.lastErr, e.retryAt.Format(time.RFC3339))
+}
+
+// allow 判断是否放行对目标的请求，半开状态放行的请求占用一个探测名额
+func (c *circuitBreakers) allow(target string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[target]
+	if !ok {
+		return false, nil
+	}
+	switch b.state {
+	case breakerOpen:
+		retryAt := b.openedAt.Add(time.Duration(c.cfg.OpenTimeout) * time.Second)
+		if time.Now().Before(retryAt) {
+			return false, &errCircuitOpen{target: target, retryAt: retryAt, lastErr: b.lastError, failures: b.failures}
+		}
+		b.state = breakerHalfOpen
+		b.probes = 0
+		fallthrough
+	case breakerHalfOpen:
+		if b.probes >= c.cfg.HalfOpenProbes {
+			retryAt := time.Now().Add(time.Second)
+			return false, &errCircuitOpen{target: target, retryAt: retryAt, lastErr: b.lastError, failures: b.failures}
+		}
+		b.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+// record 记录请求结果，probe 表示请求是半开状态的探测请求；返回本次失败是否导致熔断
+func (c *circuitBreakers) record(target string, probe bool, err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[target]
+	if err == nil {
+		if ok {
+			// 探测成功或关闭状态下成功，清除连续失败
+			delete(c.breakers, target)
+		}
+		return false
+	}
+
+	if !ok {
+		b = &breaker{state: breakerClosed}
+		c.breakers[target] = b
+	}
+	b.failures++
+	b.lastError = tailString(err.Error(), 200)
+	if probe {
+		b.probes--
+	}
+	if probe || (b.state == breakerClosed && b.failures >= c.cfg.FailureThreshold) {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// states 返回非关闭状态或有连续失败的熔断器
+func (c *circuitBreakers) states() []BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states := make([]BreakerState, 0, len(c.breakers))
+	for target, b := range c.breakers {
+		state := b.state
+		if state == breakerOpen && time.Since(b.openedAt) >= time.Duration(c.cfg.OpenTimeout)*time.Second {
+			state = breakerHalfOpen
+		}
+		states = append(states, BreakerState{
+			Target:    target,
+			State:     state,
+			Failures:  b.failures,
+			OpenedAt:  b.openedAt,
+			LastError: b.lastError,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Target < states[j].Target })
+	return states
+}
+
+// breakerTarget 返回任务访问的目标：datasource 参数对应数据源，url 等参数对应HTTP主机；没有目标时返回空字符串
+func breakerTarget(taskCtx *TaskContext) string {
+	params := taskCtx.GetParams()
+	if name, ok := params["datasource"].(string); ok && name != "" {
+		return "datasource:" + renderTemplate(name, taskCtx)
+	}
+	for _, param := range targetURLParams {
+		raw, ok := params[param].(string)
+		if !ok || raw == "" {
+			continue
+		}
+		u, err := url.Parse(renderTemplate(raw, taskCtx))
+		if err != nil || u.Host == "" {
+			return ""
+		}
+		return "http:" + u.Host
+	}
+	return ""
+}
+
+// countsAsFailure 判断错误是否说明目标不可用：客户端错误（4xx）、响应断言失败和取消不计入
+func countsAsFailure(err error) bool {
+	if err == nil || isSuspended(err) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *restError
+	if errors.As(err, &statusErr) && statusErr.StatusCode < 500 && statusErr.StatusCode != 429 {
+		return false
+	}
+	var assertionErr *httpAssertionError
+	if errors.As(err, &assertionErr) {
+		return false
+	}
+	return true
+}
+
+// runWithBreaker 经过目标的熔断器执行动作
+func (e *Executor) runWithBreaker(ctx context.Context, taskCtx *TaskContext, run func() error) error {
+	if e.breakers == nil {
+		return run()
+	}
+	target := breakerTarget(taskCtx)
+	if target == "" {
+		return run()
+	}
+
+	probe, err := e.breakers.allow(target)
+	if err != nil {
+		return err
+	}
+	if probe {
+		e.logger.Infof("Circuit breaker for %s is half-open, probing", target)
+	}
+
+	err = run()
+	if !countsAsFailure(err) {
+		if probe {
+			e.logger.Infof("Circuit breaker for %s closed", target)
+		}
+		e.breakers.record(target, probe, nil)
+		return err
+	}
+	if e.breakers.record(target, probe, err) {
+		e.logger.Warnf("Circuit breaker for %s opened: %v", target, err)
+	}
+	return err
+}
+
+// CircuitBreakers 返回有连续失败或已熔断的目标，未启用熔断时返回nil
+func (e *Executor) CircuitBreakers() []BreakerState {
+	if e.breakers == nil {
+		return nil
+	}
+	return e.breakers.states()
+}
@@ -0,0 +1,174 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"nsa/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// budgetCollection 每个工作流每天的执行预算用量
+	budgetCollection = "workflow_budgets"
+	// budgetUsageTTL 用量记录的保留时间，覆盖所有时区的一天
+	budgetUsageTTL = 48 * time.Hour
+)
+
+// BudgetHandler 工作流超过执行预算被自动禁用后的处理函数
+type BudgetHandler func(workflowConfig *models.WorkflowConfig, exceeded *models.BudgetExceeded)
+
+// ValidateExecutionBudget 校验每日执行预算
+func ValidateExecutionBudget(budget *models.ExecutionBudget) error {
+	if budget == nil {
+		return nil
+	}
+	if budget.Limit <= 0 {
+		return fmt.Errorf("budget.limit must be positive, got %g", budget.Limit)
+	}
+	if budget.Cost != "" {
+		if err := ValidateExpression(budget.Cost); err != nil {
+			return fmt.Errorf("budget.cost: invalid expression: %v", err)
+		}
+	}
+	if budget.Timezone != "" {
+		if _, err := time.LoadLocation(budget.Timezone); err != nil {
+			return fmt.Errorf("budget.timezone %q is not a valid IANA time zone", budget.Timezone)
+		}
+	}
+	for _, email := range budget.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return fmt.Errorf("budget.emails: invalid email address %q", email)
+		}
+	}
+	return nil
+}
+
+// OnBudgetExceeded 设置工作流超过执行预算被自动禁用后的处理函数，需要在消费消息之前设置
+func (e *Executor) OnBudgetExceeded(fn BudgetHandler) {
+	e.onBudgetExceeded = fn
+}
+
+// EnsureBudgetIndexes 创建执行预算用量的过期索引
+func (e *Executor) EnsureBudgetIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := e.mongoDB.GetDatabase().Collection(budgetCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "workflow_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// ResetBudget 清除工作流的执行预算用量，超过预算被禁用的工作流重新启用时调用
+func (e *Executor) ResetBudget(ctx context.Context, workflowID string) error {
+	_, err := e.mongoDB.GetDatabase().Collection(budgetCollection).DeleteMany(ctx, bson.M{"workflow_id": workflowID})
+	return err
+}
+
+// BudgetSkipped 返回因超过执行预算而跳过的执行次数
+func (e *Executor) BudgetSkipped() int64 {
+	return e.budgetSkipped.Load()
+}
+
+// budgetDay 返回预算时区的当天日期，时区无效时使用 UTC
+func budgetDay(budget *models.ExecutionBudget, now time.Time) string {
+	location := time.UTC
+	if budget.Timezone != "" {
+		if loc, err := time.LoadLocation(budget.Timezone); err == nil {
+			location = loc
+		}
+	}
+	return now.In(location).Format("2006-01-02")
+}
+
+// executionCost 计算单次执行的成本，未配置成本表达式时为1；表达式计算失败或结果不是数值时按1计算
+func (e *Executor) executionCost(workflowConfig *models.WorkflowConfig, instance *WorkflowInstance) float64 {
+	if workflowConfig.Budget.Cost == "" {
+		return 1
+	}
+	taskCtx := &TaskContext{
+		message: instance.Message,
+		vars:    instance.Vars,
+		results: instance.Results,
+	}
+	value, err := evalExpr(workflowConfig.Budget.Cost, taskCtx)
+	var cost float64
+	if err == nil {
+		cost, err = exprToNumber(value)
+	}
+	if err != nil {
+		e.logger.Warnf("Failed to compute execution cost of workflow %s, counting as 1: %v", workflowConfig.Name, err)
+		return 1
+	}
+	return cost
+}
+
+// overBudget 将本次执行计入工作流当天的用量，超过预算时自动禁用工作流并返回 true
+//
+// 用量保存在MongoDB中，集群中的所有节点共同计数；读写用量失败时不限制执行。
+func (e *Executor) overBudget(workflowConfig *models.WorkflowConfig, instance *WorkflowInstance) bool {
+	budget := workflowConfig.Budget
+	if budget == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	day := budgetDay(budget, now)
+	workflowID := workflowConfig.ID.Hex()
+	var usage struct {
+		Used float64 `bson:"used"`
+	}
+	err := e.mongoDB.GetDatabase().Collection(budgetCollection).FindOneAndUpdate(ctx,
+		bson.M{"_id": workflowID + ":" + day},
+		bson.M{
+			"$inc":         bson.M{"used": e.executionCost(workflowConfig, instance)},
+			"$setOnInsert": bson.M{"workflow_id": workflowID, "day": day, "expires_at": now.Add(budgetUsageTTL)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&usage)
+	if err != nil {
+		e.logger.Errorf("Failed to update execution budget of workflow %s, not limiting execution: %v", workflowConfig.Name, err)
+		return false
+	}
+	if usage.Used <= budget.Limit {
+		return false
+	}
+
+	e.budgetSkipped.Add(1)
+	e.pauseForBudget(ctx, workflowConfig, &models.BudgetExceeded{Day: day, Used: usage.Used, Limit: budget.Limit, At: now})
+	return true
+}
+
+// pauseForBudget 禁用超过预算的工作流
+//
+// 只有实际禁用工作流的执行调用处理函数，集群中多个节点同时超过预算时只通知一次。
+func (e *Executor) pauseForBudget(ctx context.Context, workflowConfig *models.WorkflowConfig, exceeded *models.BudgetExceeded) {
+	result, err := e.mongoDB.GetCollection().UpdateOne(ctx,
+		bson.M{"_id": workflowConfig.ID, "enabled": true},
+		bson.M{"$set": bson.M{"enabled": false, "budget_exceeded": exceeded, "updated_at": exceeded.At}},
+	)
+	if err != nil {
+		e.logger.Errorf("Failed to disable workflow %s after exceeding its execution budget: %v", workflowConfig.Name, err)
+		return
+	}
+	if result.ModifiedCount == 0 {
+		return
+	}
+
+	e.InvalidateWorkflowConfigs()
+	e.logger.Warnf("Workflow %s exceeded its daily execution budget (%g of %g on %s) and was disabled",
+		workflowConfig.Name, exceeded.Used, exceeded.Limit, exceeded.Day)
+	if fn := e.onBudgetExceeded; fn != nil {
+		go fn(workflowConfig, exceeded)
+	}
+}
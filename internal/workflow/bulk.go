@@ -0,0 +1,468 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 批量操作
+const (
+	BulkRetry   = "retry"   // 从失败的任务重新执行
+	BulkCancel  = "cancel"  // 取消执行中和延迟的实例
+	BulkResolve = "resolve" // 将失败的实例标记为已人工处理，不再重试
+)
+
+const (
+	// bulkMaxInstances 单个批量操作最多处理的实例数
+	bulkMaxInstances = 10000
+	// bulkMaxErrors 批量操作记录的失败明细上限
+	bulkMaxErrors = 100
+	// bulkDefaultConcurrency 重试时默认同时执行的实例数
+	bulkDefaultConcurrency = 10
+	// bulkMaxConcurrency 重试时同时执行的实例数上限
+	bulkMaxConcurrency = 100
+	// bulkProgressInterval 批量操作进度的保存间隔
+	bulkProgressInterval = 2 * time.Second
+)
+
+// bulkCollection 批量操作集合名称
+const bulkCollection = "bulk_operations"
+
+// bulkEligibleStatuses 各操作可以处理的实例状态
+var bulkEligibleStatuses = map[string][]string{
+	BulkRetry:   {"failed", "interrupted", "cancelled"},
+	BulkCancel:  {"running", "delayed"},
+	BulkResolve: {"failed", "interrupted"},
+}
+
+// errInstanceCancelled 实例被取消，作为执行上下文的取消原因
+var errInstanceCancelled = errors.New("workflow instance cancelled")
+
+// ErrBulkTooManyInstances 批量操作匹配的实例超过上限
+var ErrBulkTooManyInstances = fmt.Errorf("filter matches more than %d instances, narrow the filter", bulkMaxInstances)
+
+// errInstanceStatusChanged 实例状态在处理前已经改变（如已被其他请求重试或取消）
+var errInstanceStatusChanged = errors.New("instance status changed, skipped")
+
+// BulkFilter 批量操作选择实例的条件，多个条件同时满足
+type BulkFilter struct {
+	WorkflowID string `bson:"workflow_id,omitempty" json:"workflow_id,omitempty"`
+	// Status 实例状态，为空时使用操作可以处理的所有状态
+	Status []string `bson:"status,omitempty" json:"status,omitempty"`
+	Node   string   `bson:"node,omitempty" json:"node,omitempty"`
+	// StartAfter、StartBefore 实例开始时间的范围
+	StartAfter  time.Time `bson:"start_after,omitempty" json:"start_after,omitempty"`
+	StartBefore time.Time `bson:"start_before,omitempty" json:"start_before,omitempty"`
+	// InstanceIDs 只处理这些实例
+	InstanceIDs []string `bson:"instance_ids,omitempty" json:"instance_ids,omitempty"`
+}
+
+// BulkError 批量操作中处理失败的实例
+type BulkError struct {
+	InstanceID string `bson:"instance_id" json:"instance_id"`
+	Error      string `bson:"error" json:"error"`
+}
+
+// BulkOperation 按条件批量重试、取消或处理实例的后台任务，进度保存在 bulk_operations 集合
+type BulkOperation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Operation string             `bson:"operation" json:"operation"`
+	Filter    BulkFilter         `bson:"filter" json:"filter"`
+	// Reason 取消或处理的原因，写入实例的 resolution
+	Reason string `bson:"reason,omitempty" json:"reason,omitempty"`
+	// Concurrency 重试时同时执行的实例数
+	Concurrency int `bson:"concurrency,omitempty" json:"concurrency,omitempty"`
+	// Status running、completed、failed 或 interrupted（执行的服务停止）
+	Status    string      `bson:"status" json:"status"`
+	Total     int         `bson:"total" json:"total"`
+	Processed int         `bson:"processed" json:"processed"`
+	Succeeded int         `bson:"succeeded" json:"succeeded"`
+	Skipped   int         `bson:"skipped" json:"skipped"`
+	Failed    int         `bson:"failed" json:"failed"`
+	Errors    []BulkError `bson:"errors,omitempty" json:"errors,omitempty"`
+	Error     string      `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedBy string      `bson:"created_by" json:"created_by"`
+	Node      string      `bson:"node,omitempty" json:"node,omitempty"`
+	CreatedAt time.Time   `bson:"created_at" json:"created_at"`
+	EndedAt   time.Time   `bson:"ended_at,omitempty" json:"ended_at,omitempty"`
+}
+
+// Validate 检查操作和条件，设置默认值
+func (op *BulkOperation) Validate() error {
+	eligible, ok := bulkEligibleStatuses[op.Operation]
+	if !ok {
+		return fmt.Errorf("operation must be retry, cancel or resolve")
+	}
+	for _, status := range op.Filter.Status {
+		if !slices.Contains(eligible, status) {
+			return fmt.Errorf("%s does not apply to %s instances, expected status in %v", op.Operation, status, eligible)
+		}
+	}
+	if !op.Filter.StartAfter.IsZero() && !op.Filter.StartBefore.IsZero() && !op.Filter.StartAfter.Before(op.Filter.StartBefore) {
+		return fmt.Errorf("start_after must be before start_before")
+	}
+	if len(op.Filter.InstanceIDs) > bulkMaxInstances {
+		return fmt.Errorf("instance_ids must not exceed %d", bulkMaxInstances)
+	}
+	if op.Concurrency == 0 {
+		op.Concurrency = bulkDefaultConcurrency
+	}
+	if op.Concurrency < 1 || op.Concurrency > bulkMaxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", bulkMaxConcurrency)
+	}
+	return nil
+}
+
+// query 返回选择实例的查询条件，状态限制为操作可以处理的状态
+func (op *BulkOperation) query() bson.M {
+	statuses := op.Filter.Status
+	if len(statuses) == 0 {
+		statuses = bulkEligibleStatuses[op.Operation]
+	}
+	filter := bson.M{"status": bson.M{"$in": statuses}}
+	if op.Filter.WorkflowID != "" {
+		filter["workflow_id"] = op.Filter.WorkflowID
+	}
+	if op.Filter.Node != "" {
+		filter["node"] = op.Filter.Node
+	}
+	if len(op.Filter.InstanceIDs) > 0 {
+		filter["_id"] = bson.M{"$in": op.Filter.InstanceIDs}
+	}
+	startTime := bson.M{}
+	if !op.Filter.StartAfter.IsZero() {
+		startTime["$gte"] = op.Filter.StartAfter
+	}
+	if !op.Filter.StartBefore.IsZero() {
+		startTime["$lt"] = op.Filter.StartBefore
+	}
+	if len(startTime) > 0 {
+		filter["start_time"] = startTime
+	}
+	return filter
+}
+
+// CountBulkInstances 返回批量操作会处理的实例数
+func (e *Executor) CountBulkInstances(ctx context.Context, op *BulkOperation) (int64, error) {
+	return e.mongoDB.GetDatabase().Collection("workflow_instances").CountDocuments(ctx, op.query())
+}
+
+// StartBulkOperation 保存批量操作并在后台执行，op 更新为保存时的状态；匹配的实例超过上限时返回 ErrBulkTooManyInstances
+func (e *Executor) StartBulkOperation(ctx context.Context, op *BulkOperation) error {
+	count, err := e.CountBulkInstances(ctx, op)
+	if err != nil {
+		return err
+	}
+	if count > bulkMaxInstances {
+		return fmt.Errorf("%w (matched %d)", ErrBulkTooManyInstances, count)
+	}
+
+	op.ID = primitive.NewObjectID()
+	op.Status = "running"
+	op.Total = int(count)
+	op.Node = e.cfg.Cluster.NodeID
+	op.CreatedAt = time.Now()
+	if _, err := e.mongoDB.GetDatabase().Collection(bulkCollection).InsertOne(ctx, op); err != nil {
+		return err
+	}
+
+	job := *op
+	go e.runBulkOperation(&job)
+	return nil
+}
+
+// runBulkOperation 按开始时间顺序逐个处理实例，定期保存进度
+func (e *Executor) runBulkOperation(op *BulkOperation) {
+	ctx := context.Background()
+	e.logger.Infof("Bulk %s %s started by %s: %d instances", op.Operation, op.ID.Hex(), op.CreatedBy, op.Total)
+
+	ids, err := e.bulkInstanceIDs(ctx, op)
+	if err != nil {
+		op.Status = "failed"
+		op.Error = err.Error()
+		op.EndedAt = time.Now()
+		e.saveBulkOperation(op)
+		e.logger.Errorf("Bulk %s %s failed: %v", op.Operation, op.ID.Hex(), err)
+		return
+	}
+	op.Total = len(ids)
+
+	// 重试的实例在后台执行，限制同时执行的数量
+	slots := make(chan struct{}, op.Concurrency)
+	lastSaved := time.Now()
+	for _, id := range ids {
+		var err error
+		switch op.Operation {
+		case BulkRetry:
+			slots <- struct{}{}
+			err = e.retryInstance(ctx, id, func() { <-slots })
+		case BulkCancel:
+			err = e.cancelInstance(ctx, id, op.resolution())
+		case BulkResolve:
+			err = e.resolveInstance(ctx, id, op.resolution())
+		}
+
+		op.Processed++
+		switch {
+		case err == nil:
+			op.Succeeded++
+		case errors.Is(err, errInstanceStatusChanged):
+			op.Skipped++
+		default:
+			op.Failed++
+			if len(op.Errors) < bulkMaxErrors {
+				op.Errors = append(op.Errors, BulkError{InstanceID: id, Error: err.Error()})
+			}
+		}
+		if time.Since(lastSaved) >= bulkProgressInterval {
+			e.saveBulkOperation(op)
+			lastSaved = time.Now()
+		}
+	}
+
+	op.Status = "completed"
+	op.EndedAt = time.Now()
+	e.saveBulkOperation(op)
+	e.logger.Infof("Bulk %s %s completed: %d succeeded, %d skipped, %d failed", op.Operation, op.ID.Hex(), op.Succeeded, op.Skipped, op.Failed)
+}
+
+// resolution 写入实例的处理说明
+func (op *BulkOperation) resolution() string {
+	note := fmt.Sprintf("%s by %s (bulk operation %s)", op.Operation, op.CreatedBy, op.ID.Hex())
+	if op.Reason != "" {
+		note = op.Reason + "; " + note
+	}
+	return note
+}
+
+// bulkInstanceIDs 返回批量操作要处理的实例ID，按开始时间排序
+func (e *Executor) bulkInstanceIDs(ctx context.Context, op *BulkOperation) ([]string, error) {
+	ctxDB, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.D{{Key: "start_time", Value: 1}}).
+		SetLimit(bulkMaxInstances)
+	cursor, err := e.mongoDB.GetDatabase().Collection("workflow_instances").Find(ctxDB, op.query(), opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctxDB, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids, nil
+}
+
+// saveBulkOperation 保存批量操作的进度
+func (e *Executor) saveBulkOperation(op *BulkOperation) {
+	collection := e.mongoDB.GetDatabase().Collection(bulkCollection)
+	err := e.mongoDB.Retry(context.Background(), 5*time.Second, func(ctx context.Context) error {
+		_, err := collection.ReplaceOne(ctx, bson.M{"_id": op.ID}, op)
+		return err
+	})
+	if err != nil {
+		e.logger.Errorf("Failed to save bulk operation %s: %v", op.ID.Hex(), err)
+	}
+}
+
+// RecoverBulkOperations 将服务停止时当前节点上未完成的批量操作标记为 interrupted，已处理的实例不回滚
+func (e *Executor) RecoverBulkOperations(ctx context.Context) error {
+	return e.interruptBulkOperations(ctx, e.cfg.Cluster.NodeID)
+}
+
+// interruptBulkOperations 将指定节点上未完成的批量操作标记为 interrupted
+func (e *Executor) interruptBulkOperations(ctx context.Context, nodeID string) error {
+	filter := e.nodeFilter(nodeID)
+	filter["status"] = "running"
+	update := bson.M{"$set": bson.M{
+		"status":   "interrupted",
+		"error":    "interrupted by service restart",
+		"ended_at": time.Now(),
+	}}
+	result, err := e.mongoDB.GetDatabase().Collection(bulkCollection).UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		e.logger.Warnf("Marked %d unfinished bulk operations of node %s as interrupted", result.ModifiedCount, nodeID)
+	}
+	return nil
+}
+
+// loadInstance 读取实例
+func (e *Executor) loadInstance(ctx context.Context, id string) (*WorkflowInstance, error) {
+	var instance WorkflowInstance
+	err := e.mongoDB.Retry(ctx, 5*time.Second, func(ctx context.Context) error {
+		return e.mongoDB.GetDatabase().Collection("workflow_instances").FindOne(ctx, bson.M{"_id": id}).Decode(&instance)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &instance, nil
+}
+
+// transitionInstance 实例状态仍为 from 中的一个时更新实例并将版本加1，状态已改变时返回 errInstanceStatusChanged
+//
+// 更新后 instance 的版本为存储的版本；执行中的实例（如被取消）在其他节点上的下一次保存因版本不同被拒绝。
+func (e *Executor) transitionInstance(ctx context.Context, instance *WorkflowInstance, from []string, set bson.M) error {
+	collection := e.mongoDB.GetDatabase().Collection("workflow_instances")
+	filter := bson.M{"_id": instance.ID, "status": bson.M{"$in": from}}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"version": 1})
+	var updated struct {
+		Version int64 `bson:"version"`
+	}
+	err := e.mongoDB.Retry(ctx, 5*time.Second, func(ctx context.Context) error {
+		return collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	})
+	if err == mongo.ErrNoDocuments {
+		return errInstanceStatusChanged
+	}
+	if err != nil {
+		return err
+	}
+	instance.Version = updated.Version
+	return nil
+}
+
+// instanceStatus 读取实例当前的状态，读取失败时返回空字符串
+func (e *Executor) instanceStatus(id string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var doc struct {
+		Status string `bson:"status"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"status": 1})
+	if err := e.mongoDB.GetDatabase().Collection("workflow_instances").FindOne(ctx, bson.M{"_id": id}, opts).Decode(&doc); err != nil {
+		return ""
+	}
+	return doc.Status
+}
+
+// instanceCancelled 判断执行中的实例是否已被取消：在当前节点取消时执行上下文带有取消原因；
+// 启用集群时实例可能在其他节点上被取消，需要读取实例状态
+func (e *Executor) instanceCancelled(ctx context.Context, id string) bool {
+	if errors.Is(context.Cause(ctx), errInstanceCancelled) {
+		return true
+	}
+	return e.cfg.Cluster.Enabled && e.instanceStatus(id) == "cancelled"
+}
+
+// retryInstance 从失败（或中断、取消时）的任务重新执行实例，执行goroutine返回时调用 done
+func (e *Executor) retryInstance(ctx context.Context, id string, done func()) error {
+	instance, err := e.loadInstance(ctx, id)
+	if err != nil {
+		done()
+		return err
+	}
+	workflowConfig := e.findWorkflow(ctx, instance.WorkflowID)
+	if workflowConfig == nil {
+		done()
+		return fmt.Errorf("workflow %s not found", instance.WorkflowID)
+	}
+
+	instance.Status = "running"
+	instance.EndTime = time.Time{}
+	instance.Resolution = ""
+	instance.Node = e.cfg.Cluster.NodeID
+	if instance.Results == nil {
+		instance.Results = make(map[string]interface{})
+	}
+	err = e.transitionInstance(ctx, instance, bulkEligibleStatuses[BulkRetry], bson.M{
+		"status":     instance.Status,
+		"end_time":   instance.EndTime,
+		"resolution": "",
+		"node":       instance.Node,
+	})
+	if err != nil {
+		done()
+		return err
+	}
+
+	e.recordHistory(instance, HistoryEvent{Type: HistoryRetried})
+	e.logger.Infof("Retrying workflow instance %s from task %d", instance.ID, instance.NextTask)
+	e.events.Publish(Event{
+		Type:       EventInstanceStarted,
+		InstanceID: instance.ID,
+		WorkflowID: instance.WorkflowID,
+		Status:     instance.Status,
+	})
+
+	tasks := e.buildTasks(workflowConfig)
+	if instance.ConcurrencyKey != "" {
+		e.enqueue(context.Background(), instance, tasks, instance.Message)
+		done()
+		return nil
+	}
+	go func() {
+		defer done()
+		e.executeTasks(context.Background(), instance, tasks, instance.Message, nil)
+	}()
+	return nil
+}
+
+// cancelInstance 取消执行中或延迟的实例
+//
+// 实例在当前节点执行时立即中断正在执行的任务；在其他节点执行时，当前任务结束后不再执行后续任务。
+// 延迟的实例不再恢复执行，占用的并发键在原定的恢复时间释放。
+func (e *Executor) cancelInstance(ctx context.Context, id, resolution string) error {
+	instance, err := e.loadInstance(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	instance.Status = "cancelled"
+	instance.EndTime = time.Now()
+	instance.Resolution = resolution
+	err = e.transitionInstance(ctx, instance, bulkEligibleStatuses[BulkCancel], bson.M{
+		"status":     instance.Status,
+		"end_time":   instance.EndTime,
+		"resolution": resolution,
+	})
+	if err != nil {
+		return err
+	}
+
+	if cancel, ok := e.running.Load(id); ok {
+		cancel.(context.CancelCauseFunc)(errInstanceCancelled)
+	}
+	e.logger.Infof("Workflow instance %s cancelled: %s", id, resolution)
+	e.publishInstanceEnd(instance, errInstanceCancelled)
+	return nil
+}
+
+// resolveInstance 将失败或中断的实例标记为已人工处理
+func (e *Executor) resolveInstance(ctx context.Context, id, resolution string) error {
+	instance, err := e.loadInstance(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	instance.Status = "resolved"
+	instance.Resolution = resolution
+	err = e.transitionInstance(ctx, instance, bulkEligibleStatuses[BulkResolve], bson.M{
+		"status":     instance.Status,
+		"resolution": resolution,
+	})
+	if err != nil {
+		return err
+	}
+	e.recordHistory(instance, HistoryEvent{Type: HistoryResolved, Resolution: resolution})
+	return nil
+}
@@ -0,0 +1,523 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 日历服务地址
+const (
+	defaultGoogleCalendarURL = "https://www.googleapis.com/calendar/v3"
+	defaultGraphURL          = "https://graph.microsoft.com/v1.0"
+	googleCalendarScope      = "https://www.googleapis.com/auth/calendar"
+	graphDateTimeLayout      = "2006-01-02T15:04:05.9999999"
+)
+
+// calendarRequest 日历操作的通用参数
+type calendarRequest struct {
+	calendar    string // Google日历ID / Exchange邮箱
+	summary     string
+	description string
+	location    string
+	attendees   []string
+	start       time.Time
+	end         time.Time
+	params      map[string]interface{}
+}
+
+// busyPeriod 忙碌时间段
+type busyPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// CalendarAction 日历动作（Google Calendar / Exchange）
+//
+// 支持创建日程和查询参与人的空闲状态，用于审批、维护窗口等需要协调人员时间的工作流。
+type CalendarAction struct {
+	ctx *ActionContext
+}
+
+// NewCalendarAction 创建日历动作
+func NewCalendarAction(ctx *ActionContext) *CalendarAction {
+	return &CalendarAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *CalendarAction) Name() string {
+	return "CalendarAction"
+}
+
+// Run 执行日历操作
+func (a *CalendarAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	provider, _ := params["provider"].(string)
+	operation, _ := params["operation"].(string)
+	baseURL, _ := params["base_url"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if operation != "create_event" && operation != "check_availability" {
+		return fmt.Errorf("unsupported calendar operation: %s", operation)
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	req := &calendarRequest{params: params}
+	req.calendar, _ = params["calendar"].(string)
+	req.summary, _ = params["summary"].(string)
+	req.description, _ = params["description"].(string)
+	req.location, _ = params["location"].(string)
+	if attendees, ok := params["attendees"].([]interface{}); ok {
+		for _, attendee := range attendees {
+			if email := stringifyValue(attendee); email != "" {
+				req.attendees = append(req.attendees, email)
+			}
+		}
+	}
+
+	var err error
+	if req.start, err = parseCalendarTime(params, "start"); err != nil {
+		return err
+	}
+	if req.end, err = parseCalendarTime(params, "end"); err != nil {
+		return err
+	}
+	if !req.end.After(req.start) {
+		return fmt.Errorf("end must be after start")
+	}
+	if operation == "create_event" && req.summary == "" {
+		return fmt.Errorf("summary parameter is required for create_event")
+	}
+	if operation == "check_availability" && len(req.attendees) == 0 {
+		return fmt.Errorf("attendees parameter is required for check_availability")
+	}
+
+	client := &calendarClient{timeout: time.Duration(timeout) * time.Second}
+	a.ctx.Logger.Infof("Executing %s calendar operation %s", provider, operation)
+
+	var result map[string]interface{}
+	switch provider {
+	case "google":
+		if baseURL == "" {
+			baseURL = defaultGoogleCalendarURL
+		}
+		if client.token, err = a.googleToken(ctx, params, client); err != nil {
+			return err
+		}
+		if operation == "create_event" {
+			result, err = client.googleCreateEvent(ctx, baseURL, req)
+		} else {
+			result, err = client.googleFreeBusy(ctx, baseURL, req)
+		}
+
+	case "exchange":
+		if baseURL == "" {
+			baseURL = defaultGraphURL
+		}
+		if req.calendar == "" {
+			return fmt.Errorf("calendar parameter (mailbox) is required for exchange")
+		}
+		if client.token, err = a.graphToken(ctx, params, client); err != nil {
+			return err
+		}
+		if operation == "create_event" {
+			result, err = client.graphCreateEvent(ctx, baseURL, req)
+		} else {
+			result, err = client.graphSchedule(ctx, baseURL, req)
+		}
+
+	default:
+		return fmt.Errorf("unsupported calendar provider: %s", provider)
+	}
+	if err != nil {
+		return fmt.Errorf("%s calendar %s failed: %v", provider, operation, err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Calendar operation %s completed successfully", operation)
+
+	return nil
+}
+
+// googleToken 获取Google访问令牌
+//
+// token_secret 直接使用访问令牌；service_account_secret 为服务账号JSON密钥，
+// 通过JWT断言换取令牌，subject 用于域范围委派时模拟的用户。
+func (a *CalendarAction) googleToken(ctx context.Context, params map[string]interface{}, client *calendarClient) (string, error) {
+	if tokenSecret, _ := params["token_secret"].(string); tokenSecret != "" {
+		return a.ctx.getSecret(ctx, tokenSecret)
+	}
+
+	accountSecret, _ := params["service_account_secret"].(string)
+	if accountSecret == "" {
+		return "", fmt.Errorf("token_secret or service_account_secret parameter is required for google")
+	}
+	raw, err := a.ctx.getSecret(ctx, accountSecret)
+	if err != nil {
+		return "", err
+	}
+
+	var account struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal([]byte(raw), &account); err != nil {
+		return "", fmt.Errorf("invalid service account key: %v", err)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid service account private key: %v", err)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   account.ClientEmail,
+		"scope": googleCalendarScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	if subject, _ := params["subject"].(string); subject != "" {
+		claims["sub"] = subject
+	}
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign service account assertion: %v", err)
+	}
+
+	return client.fetchToken(ctx, account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// graphToken 获取Microsoft Graph访问令牌
+//
+// token_secret 直接使用访问令牌；否则使用 tenant_id、client_id 和 client_secret_secret 的客户端凭据流程。
+func (a *CalendarAction) graphToken(ctx context.Context, params map[string]interface{}, client *calendarClient) (string, error) {
+	if tokenSecret, _ := params["token_secret"].(string); tokenSecret != "" {
+		return a.ctx.getSecret(ctx, tokenSecret)
+	}
+
+	tenantID, _ := params["tenant_id"].(string)
+	clientID, _ := params["client_id"].(string)
+	clientSecretName, _ := params["client_secret_secret"].(string)
+	if tenantID == "" || clientID == "" || clientSecretName == "" {
+		return "", fmt.Errorf("token_secret or tenant_id, client_id and client_secret_secret parameters are required for exchange")
+	}
+	clientSecret, err := a.ctx.getSecret(ctx, clientSecretName)
+	if err != nil {
+		return "", err
+	}
+
+	return client.fetchToken(ctx, "https://login.microsoftonline.com/"+url.PathEscape(tenantID)+"/oauth2/v2.0/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"scope":         {"https://graph.microsoft.com/.default"},
+	})
+}
+
+// calendarClient 日历API客户端
+type calendarClient struct {
+	token   string
+	timeout time.Duration
+}
+
+// call 发送带访问令牌的JSON请求
+func (c *calendarClient) call(ctx context.Context, method, endpoint string, body, out interface{}) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+	return callJSON(ctx, method, endpoint, header, body, out, c.timeout)
+}
+
+// fetchToken 通过OAuth2令牌端点换取访问令牌
+func (c *calendarClient) fetchToken(ctx context.Context, tokenURL string, form url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := (&http.Client{Timeout: c.timeout}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request access token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, tailString(string(data), maxErrorBodySize))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid token response")
+	}
+	return token.AccessToken, nil
+}
+
+// googleCreateEvent 创建Google日程
+func (c *calendarClient) googleCreateEvent(ctx context.Context, baseURL string, req *calendarRequest) (map[string]interface{}, error) {
+	calendarID := req.calendar
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	event := map[string]interface{}{
+		"summary": req.summary,
+		"start":   map[string]interface{}{"dateTime": req.start.Format(time.RFC3339)},
+		"end":     map[string]interface{}{"dateTime": req.end.Format(time.RFC3339)},
+	}
+	if req.description != "" {
+		event["description"] = req.description
+	}
+	if req.location != "" {
+		event["location"] = req.location
+	}
+	if len(req.attendees) > 0 {
+		var attendees []interface{}
+		for _, email := range req.attendees {
+			attendees = append(attendees, map[string]interface{}{"email": email})
+		}
+		event["attendees"] = attendees
+	}
+
+	// 默认向参与人发送邀请
+	sendUpdates, _ := req.params["send_updates"].(string)
+	if sendUpdates == "" {
+		sendUpdates = "all"
+	}
+	endpoint := strings.TrimRight(baseURL, "/") + "/calendars/" + url.PathEscape(calendarID) + "/events?sendUpdates=" + url.QueryEscape(sendUpdates)
+
+	var created struct {
+		ID       string `json:"id"`
+		HTMLLink string `json:"htmlLink"`
+		Status   string `json:"status"`
+	}
+	if err := c.call(ctx, http.MethodPost, endpoint, event, &created); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"event_id": created.ID,
+		"url":      created.HTMLLink,
+		"status":   created.Status,
+	}, nil
+}
+
+// googleFreeBusy 查询Google日历忙碌时间
+func (c *calendarClient) googleFreeBusy(ctx context.Context, baseURL string, req *calendarRequest) (map[string]interface{}, error) {
+	var items []interface{}
+	for _, email := range req.attendees {
+		items = append(items, map[string]interface{}{"id": email})
+	}
+	body := map[string]interface{}{
+		"timeMin": req.start.Format(time.RFC3339),
+		"timeMax": req.end.Format(time.RFC3339),
+		"items":   items,
+	}
+
+	var resp struct {
+		Calendars map[string]struct {
+			Busy   []busyPeriod `json:"busy"`
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"calendars"`
+	}
+	if err := c.call(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/freeBusy", body, &resp); err != nil {
+		return nil, err
+	}
+
+	busy := make(map[string][]busyPeriod)
+	errors := make(map[string]string)
+	for _, email := range req.attendees {
+		calendar, ok := resp.Calendars[email]
+		if !ok {
+			errors[email] = "notFound"
+			continue
+		}
+		if len(calendar.Errors) > 0 {
+			errors[email] = calendar.Errors[0].Reason
+			continue
+		}
+		busy[email] = calendar.Busy
+	}
+
+	return availabilityResult(req.attendees, busy, errors), nil
+}
+
+// graphCreateEvent 创建Exchange日程
+func (c *calendarClient) graphCreateEvent(ctx context.Context, baseURL string, req *calendarRequest) (map[string]interface{}, error) {
+	event := map[string]interface{}{
+		"subject": req.summary,
+		"start":   graphDateTime(req.start),
+		"end":     graphDateTime(req.end),
+	}
+	if req.description != "" {
+		event["body"] = map[string]interface{}{"contentType": "text", "content": req.description}
+	}
+	if req.location != "" {
+		event["location"] = map[string]interface{}{"displayName": req.location}
+	}
+	if len(req.attendees) > 0 {
+		var attendees []interface{}
+		for _, email := range req.attendees {
+			attendees = append(attendees, map[string]interface{}{
+				"emailAddress": map[string]interface{}{"address": email},
+				"type":         "required",
+			})
+		}
+		event["attendees"] = attendees
+	}
+	if online, ok := req.params["online_meeting"].(bool); ok && online {
+		event["isOnlineMeeting"] = true
+	}
+
+	endpoint := strings.TrimRight(baseURL, "/") + "/users/" + url.PathEscape(req.calendar) + "/events"
+
+	var created struct {
+		ID            string `json:"id"`
+		WebLink       string `json:"webLink"`
+		OnlineMeeting *struct {
+			JoinURL string `json:"joinUrl"`
+		} `json:"onlineMeeting"`
+	}
+	if err := c.call(ctx, http.MethodPost, endpoint, event, &created); err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"event_id": created.ID,
+		"url":      created.WebLink,
+	}
+	if created.OnlineMeeting != nil {
+		result["join_url"] = created.OnlineMeeting.JoinURL
+	}
+	return result, nil
+}
+
+// graphSchedule 查询Exchange参与人忙碌时间
+func (c *calendarClient) graphSchedule(ctx context.Context, baseURL string, req *calendarRequest) (map[string]interface{}, error) {
+	body := map[string]interface{}{
+		"schedules": req.attendees,
+		"startTime": graphDateTime(req.start),
+		"endTime":   graphDateTime(req.end),
+	}
+
+	var resp struct {
+		Value []struct {
+			ScheduleID    string `json:"scheduleId"`
+			ScheduleItems []struct {
+				Status string `json:"status"`
+				Start  struct {
+					DateTime string `json:"dateTime"`
+				} `json:"start"`
+				End struct {
+					DateTime string `json:"dateTime"`
+				} `json:"end"`
+			} `json:"scheduleItems"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"value"`
+	}
+	endpoint := strings.TrimRight(baseURL, "/") + "/users/" + url.PathEscape(req.calendar) + "/calendar/getSchedule"
+	if err := c.call(ctx, http.MethodPost, endpoint, body, &resp); err != nil {
+		return nil, err
+	}
+
+	busy := make(map[string][]busyPeriod)
+	errors := make(map[string]string)
+	for _, schedule := range resp.Value {
+		if schedule.Error != nil {
+			errors[schedule.ScheduleID] = schedule.Error.Message
+			continue
+		}
+		periods := []busyPeriod{}
+		for _, item := range schedule.ScheduleItems {
+			if item.Status == "free" {
+				continue
+			}
+			// 未指定 Prefer 时区时，Graph 返回 UTC 时间
+			start, err1 := time.Parse(graphDateTimeLayout, item.Start.DateTime)
+			end, err2 := time.Parse(graphDateTimeLayout, item.End.DateTime)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			periods = append(periods, busyPeriod{Start: start, End: end})
+		}
+		busy[schedule.ScheduleID] = periods
+	}
+	for _, email := range req.attendees {
+		if _, ok := busy[email]; !ok {
+			if _, failed := errors[email]; !failed {
+				errors[email] = "notFound"
+			}
+		}
+	}
+
+	return availabilityResult(req.attendees, busy, errors), nil
+}
+
+// availabilityResult 汇总空闲查询结果
+//
+// 所有参与人均可查询且没有忙碌时间段时 available 为 true，free 列出空闲的参与人。
+func availabilityResult(attendees []string, busy map[string][]busyPeriod, errors map[string]string) map[string]interface{} {
+	free := []string{}
+	for _, email := range attendees {
+		if periods, ok := busy[email]; ok && len(periods) == 0 {
+			free = append(free, email)
+		}
+	}
+
+	return map[string]interface{}{
+		"available": len(free) == len(attendees),
+		"free":      free,
+		"busy":      busy,
+		"errors":    errors,
+	}
+}
+
+// graphDateTime 转换为Graph的dateTimeTimeZone格式（UTC）
+func graphDateTime(t time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"dateTime": t.UTC().Format(graphDateTimeLayout),
+		"timeZone": "UTC",
+	}
+}
+
+// parseCalendarTime 解析RFC3339格式的时间参数
+func parseCalendarTime(params map[string]interface{}, key string) (time.Time, error) {
+	value, _ := params[key].(string)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%s parameter is required", key)
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s time %q, expected RFC3339: %v", key, value, err)
+	}
+	return t, nil
+}
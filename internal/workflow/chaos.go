@@ -0,0 +1,210 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxFaultDelay 注入延迟的最大值
+const maxFaultDelay = 5 * time.Minute
+
+// ErrChaosDisabled 未启用故障注入（chaos.enabled）
+var ErrChaosDisabled = errors.New("fault injection is disabled")
+
+// Fault 故障注入规则，按概率在动作执行前注入延迟或错误
+//
+// 注入的错误与动作本身的错误一样触发重试、失败处理和告警，用于在非生产环境验证这些机制。
+type Fault struct {
+	ID string `json:"id"`
+	// Action 动作名称，如 HTTPClientAction，* 匹配所有动作
+	Action string `json:"action"`
+	// WorkflowID 为空时匹配所有工作流
+	WorkflowID string `json:"workflow_id,omitempty"`
+	// TaskID 为空时匹配所有任务
+	TaskID string `json:"task_id,omitempty"`
+	// Percentage 注入概率(0-100]，每次执行（包括重试）单独计算
+	Percentage float64 `json:"percentage"`
+	// Delay 动作执行前等待的时间(毫秒)
+	Delay int `json:"delay,omitempty"`
+	// Error 注入的错误消息，为空时只注入延迟，动作正常执行
+	Error string `json:"error,omitempty"`
+	// Duration 规则有效期(秒)，为0时一直有效直到删除
+	Duration  int       `json:"duration,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Injected 已注入的次数
+	Injected int64 `json:"injected"`
+}
+
+// matches 判断规则是否适用于任务
+func (f *Fault) matches(task *Task, instance *WorkflowInstance, now time.Time) bool {
+	if !f.ExpiresAt.IsZero() && now.After(f.ExpiresAt) {
+		return false
+	}
+	if f.Action != "*" && f.Action != task.ActionName {
+		return false
+	}
+	if f.WorkflowID != "" && f.WorkflowID != instance.WorkflowID {
+		return false
+	}
+	return f.TaskID == "" || f.TaskID == task.ID
+}
+
+// faultInjector 故障注入规则，只保存在当前服务进程内存中，重启后清空
+type faultInjector struct {
+	mu     sync.Mutex
+	faults []*Fault
+	rand   *rand.Rand
+}
+
+// newFaultInjector 创建故障注入器
+func newFaultInjector() *faultInjector {
+	return &faultInjector{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// pick 返回本次执行命中的规则，多条规则命中时使用最先添加的
+func (f *faultInjector) pick(task *Task, instance *WorkflowInstance) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for _, fault := range f.faults {
+		if !fault.matches(task, instance, now) {
+			continue
+		}
+		if f.rand.Float64()*100 >= fault.Percentage {
+			continue
+		}
+		fault.Injected++
+		hit := *fault
+		return &hit
+	}
+	return nil
+}
+
+// Faults 返回有效的故障注入规则，过期的规则被移除
+func (e *Executor) Faults() ([]Fault, error) {
+	if e.faults == nil {
+		return nil, ErrChaosDisabled
+	}
+	e.faults.mu.Lock()
+	defer e.faults.mu.Unlock()
+
+	now := time.Now()
+	active := e.faults.faults[:0]
+	result := []Fault{}
+	for _, fault := range e.faults.faults {
+		if !fault.ExpiresAt.IsZero() && now.After(fault.ExpiresAt) {
+			continue
+		}
+		active = append(active, fault)
+		result = append(result, *fault)
+	}
+	for i := len(active); i < len(e.faults.faults); i++ {
+		e.faults.faults[i] = nil
+	}
+	e.faults.faults = active
+	return result, nil
+}
+
+// AddFault 校验并添加故障注入规则，返回添加的规则
+func (e *Executor) AddFault(fault Fault) (Fault, error) {
+	if e.faults == nil {
+		return Fault{}, ErrChaosDisabled
+	}
+	if fault.Action == "" {
+		return Fault{}, fmt.Errorf("action is required")
+	}
+	if _, exists := e.actions[fault.Action]; !exists && fault.Action != "*" {
+		return Fault{}, fmt.Errorf("action %s not found", fault.Action)
+	}
+	if fault.Percentage <= 0 || fault.Percentage > 100 {
+		return Fault{}, fmt.Errorf("percentage must be in (0, 100], got %v", fault.Percentage)
+	}
+	if fault.Delay < 0 || time.Duration(fault.Delay)*time.Millisecond > maxFaultDelay {
+		return Fault{}, fmt.Errorf("delay must be between 0 and %d milliseconds", maxFaultDelay.Milliseconds())
+	}
+	if fault.Delay == 0 && fault.Error == "" {
+		return Fault{}, fmt.Errorf("delay or error is required")
+	}
+	if fault.Duration < 0 {
+		return Fault{}, fmt.Errorf("duration must not be negative")
+	}
+
+	fault.ID = primitive.NewObjectID().Hex()
+	fault.CreatedAt = time.Now()
+	fault.ExpiresAt = time.Time{}
+	if fault.Duration > 0 {
+		fault.ExpiresAt = fault.CreatedAt.Add(time.Duration(fault.Duration) * time.Second)
+	}
+	fault.Injected = 0
+
+	e.faults.mu.Lock()
+	e.faults.faults = append(e.faults.faults, &fault)
+	e.faults.mu.Unlock()
+
+	e.logger.Warnf("Fault injection rule %s added: action=%s workflow=%s task=%s percentage=%v delay=%dms error=%q",
+		fault.ID, fault.Action, fault.WorkflowID, fault.TaskID, fault.Percentage, fault.Delay, fault.Error)
+	return fault, nil
+}
+
+// RemoveFault 删除故障注入规则，规则不存在时返回false
+func (e *Executor) RemoveFault(id string) (bool, error) {
+	if e.faults == nil {
+		return false, ErrChaosDisabled
+	}
+	e.faults.mu.Lock()
+	defer e.faults.mu.Unlock()
+
+	for i, fault := range e.faults.faults {
+		if fault.ID == id {
+			e.faults.faults = append(e.faults.faults[:i], e.faults.faults[i+1:]...)
+			e.logger.Infof("Fault injection rule %s removed", id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ClearFaults 删除所有故障注入规则
+func (e *Executor) ClearFaults() error {
+	if e.faults == nil {
+		return ErrChaosDisabled
+	}
+	e.faults.mu.Lock()
+	e.faults.faults = nil
+	e.faults.mu.Unlock()
+
+	e.logger.Infof("Fault injection rules cleared")
+	return nil
+}
+
+// injectFault 启用故障注入时按规则注入延迟或错误，返回注入的错误
+func (e *Executor) injectFault(ctx context.Context, task *Task, instance *WorkflowInstance) error {
+	if e.faults == nil {
+		return nil
+	}
+	fault := e.faults.pick(task, instance)
+	if fault == nil {
+		return nil
+	}
+
+	e.logger.Warnf("Injecting fault %s into task %s of instance %s", fault.ID, task.ID, instance.ID)
+	if fault.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(fault.Delay) * time.Millisecond):
+		}
+	}
+	if fault.Error != "" {
+		return fmt.Errorf("injected fault %s: %s", fault.ID, fault.Error)
+	}
+	return nil
+}
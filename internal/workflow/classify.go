@@ -0,0 +1,202 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ClassifyAction 基于规则的分类动作（关键字/正则加权打分）
+//
+// 作为LLM分类的确定性替代或降级方案：相同输入总是得到相同结果。
+type ClassifyAction struct {
+	ctx *ActionContext
+}
+
+// NewClassifyAction 创建规则分类动作
+func NewClassifyAction(ctx *ActionContext) *ClassifyAction {
+	return &ClassifyAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *ClassifyAction) Name() string {
+	return "ClassifyAction"
+}
+
+// classifyRule 分类规则
+type classifyRule struct {
+	keywords []string
+	patterns []*regexp.Regexp
+	weight   float64
+}
+
+// classifyScore 类别得分
+type classifyScore struct {
+	Label   string   `json:"label"`
+	Score   float64  `json:"score"`
+	Matches []string `json:"matches"`
+}
+
+// Run 执行分类
+func (a *ClassifyAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := taskCtx.GetParams()
+
+	// 解析参数
+	input, _ := params["input"].(string)
+	rawCategories, _ := params["categories"].(map[string]interface{})
+	defaultLabel, _ := params["default"].(string)
+	threshold, _ := params["threshold"].(float64)
+	caseSensitive, _ := params["case_sensitive"].(bool)
+
+	if input == "" {
+		return fmt.Errorf("input parameter is required")
+	}
+	if len(rawCategories) == 0 {
+		return fmt.Errorf("categories parameter is required")
+	}
+
+	text := renderTemplate(input, taskCtx)
+	if !caseSensitive {
+		text = strings.ToLower(text)
+	}
+
+	// 按类别打分
+	scores := make([]classifyScore, 0, len(rawCategories))
+	for label, raw := range rawCategories {
+		rules, err := parseClassifyRules(label, raw, caseSensitive)
+		if err != nil {
+			return err
+		}
+
+		score := classifyScore{Label: label, Matches: []string{}}
+		for _, rule := range rules {
+			for _, keyword := range rule.keywords {
+				if count := strings.Count(text, keyword); count > 0 {
+					score.Score += rule.weight * float64(count)
+					score.Matches = append(score.Matches, keyword)
+				}
+			}
+			for _, pattern := range rule.patterns {
+				if count := len(pattern.FindAllStringIndex(text, -1)); count > 0 {
+					score.Score += rule.weight * float64(count)
+					score.Matches = append(score.Matches, pattern.String())
+				}
+			}
+		}
+		scores = append(scores, score)
+	}
+
+	// 得分降序，同分按类别名排序保证结果确定
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].Label < scores[j].Label
+	})
+
+	label := defaultLabel
+	var best classifyScore
+	if len(scores) > 0 {
+		best = scores[0]
+	}
+	matched := best.Score > 0 && best.Score >= threshold
+	if matched {
+		label = best.Label
+	}
+
+	// 置信度：最高分占总分的比例
+	var total float64
+	for _, score := range scores {
+		total += score.Score
+	}
+	confidence := 0.0
+	if matched && total > 0 {
+		confidence = best.Score / total
+	}
+
+	result := map[string]interface{}{
+		"label":      label,
+		"score":      best.Score,
+		"confidence": confidence,
+		"matched":    matched,
+		"scores":     scores,
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Classification completed with label %s (score %.2f)", label, best.Score)
+
+	return nil
+}
+
+// parseClassifyRules 解析类别规则
+//
+// 支持两种写法：
+//   - 简写：关键字数组，权重为1
+//   - 完整：规则数组，每条包含 keywords/patterns/weight
+func parseClassifyRules(label string, raw interface{}, caseSensitive bool) ([]classifyRule, error) {
+	items, ok := raw.([]interface{})
+	if !ok {
+		if single, ok := raw.(map[string]interface{}); ok {
+			items = []interface{}{single}
+		} else {
+			return nil, fmt.Errorf("invalid rules for category %s", label)
+		}
+	}
+
+	var rules []classifyRule
+	simple := classifyRule{weight: 1}
+
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			simple.keywords = append(simple.keywords, normalizeKeyword(v, caseSensitive))
+		case map[string]interface{}:
+			rule := classifyRule{weight: 1}
+			if weight, ok := v["weight"].(float64); ok {
+				rule.weight = weight
+			}
+			if keywords, ok := v["keywords"].([]interface{}); ok {
+				for _, keyword := range keywords {
+					if s, ok := keyword.(string); ok && s != "" {
+						rule.keywords = append(rule.keywords, normalizeKeyword(s, caseSensitive))
+					}
+				}
+			}
+			if patterns, ok := v["patterns"].([]interface{}); ok {
+				for _, pattern := range patterns {
+					s, ok := pattern.(string)
+					if !ok || s == "" {
+						continue
+					}
+					if !caseSensitive {
+						s = "(?i)" + s
+					}
+					re, err := regexp.Compile(s)
+					if err != nil {
+						return nil, fmt.Errorf("invalid pattern %s for category %s: %v", s, label, err)
+					}
+					rule.patterns = append(rule.patterns, re)
+				}
+			}
+			rules = append(rules, rule)
+		default:
+			return nil, fmt.Errorf("invalid rule for category %s: %v", label, item)
+		}
+	}
+
+	if len(simple.keywords) > 0 {
+		rules = append(rules, simple)
+	}
+	return rules, nil
+}
+
+// normalizeKeyword 规范化关键字
+func normalizeKeyword(keyword string, caseSensitive bool) string {
+	if caseSensitive {
+		return keyword
+	}
+	return strings.ToLower(keyword)
+}
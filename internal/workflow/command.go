@@ -0,0 +1,200 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"nsa/internal/config"
+)
+
+// CommandAction 命令动作，执行白名单（command.allowed_commands）中的程序
+//
+// 程序直接执行而不经过shell，参数逐个传递，模板变量中的空格、引号和管道符不会被解释；
+// 但参数仍可能被程序解析为选项，白名单中应只放行参数语义可控的程序或脚本。
+type CommandAction struct {
+	ctx *ActionContext
+	cfg config.CommandConfig
+}
+
+// NewCommandAction 创建命令动作
+func NewCommandAction(ctx *ActionContext, cfg config.CommandConfig) *CommandAction {
+	return &CommandAction{ctx: ctx, cfg: cfg}
+}
+
+// Name 返回动作名称
+func (a *CommandAction) Name() string {
+	return "CommandAction"
+}
+
+// Run 执行命令
+func (a *CommandAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	if !a.cfg.Enabled {
+		return fmt.Errorf("command action is disabled, set command.enabled in config.json to enable it")
+	}
+
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	command, _ := params["command"].(string)
+	args, _ := params["args"].([]interface{})
+	env, _ := params["env"].(map[string]interface{})
+	timeout, _ := params["timeout"].(float64)
+	failOnError := true
+	if v, ok := params["fail_on_error"].(bool); ok {
+		failOnError = v
+	}
+
+	if command == "" {
+		return fmt.Errorf("command parameter is required")
+	}
+	if timeout == 0 {
+		timeout = 60
+	}
+
+	path, err := a.resolveCommand(command)
+	if err != nil {
+		return err
+	}
+
+	argv := make([]string, len(args))
+	for i, arg := range args {
+		argv[i] = stringifyValue(arg)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmdEnv, err := commandEnv(env, a.cfg.AllowedEnv)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, path, argv...)
+	cmd.Dir = a.cfg.WorkDir
+	cmd.Env = cmdEnv
+	// 超时终止进程后，子进程的后代可能仍持有输出管道，最多再等待一段时间
+	cmd.WaitDelay = 5 * time.Second
+	if _, ok := params["stdin"]; ok {
+		cmd.Stdin = strings.NewReader(stringifyValue(params["stdin"]))
+	}
+	stdout := &cappedBuffer{limit: a.cfg.MaxOutputSize}
+	stderr := &cappedBuffer{limit: a.cfg.MaxOutputSize}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	a.ctx.Logger.Infof("Executing command %s with %d args", path, len(argv))
+
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return fmt.Errorf("failed to run command %s: %v", command, err)
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("command %s timed out after %ds", command, int(timeout))
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(map[string]interface{}{
+		"command":          path,
+		"exit_code":        exitCode,
+		"stdout":           stdout.String(),
+		"stderr":           stderr.String(),
+		"stdout_truncated": stdout.truncated,
+		"stderr_truncated": stderr.truncated,
+		"duration_ms":      duration.Milliseconds(),
+	})
+
+	if exitCode != 0 && failOnError {
+		return fmt.Errorf("command %s exited with code %d: %s", command, exitCode, tailString(stderr.String(), maxErrorBodySize))
+	}
+	a.ctx.Logger.Infof("Command %s finished with exit code %d in %v", command, exitCode, duration)
+
+	return nil
+}
+
+// resolveCommand 检查命令是否在白名单中，并解析为可执行文件的路径
+//
+// command 必须与白名单中的某一项完全一致；不含路径分隔符的项在PATH中查找。
+func (a *CommandAction) resolveCommand(command string) (string, error) {
+	for _, allowed := range a.cfg.AllowedCommands {
+		if allowed != command {
+			continue
+		}
+		path, err := exec.LookPath(command)
+		if err != nil {
+			return "", fmt.Errorf("command %s not found: %v", command, err)
+		}
+		return path, nil
+	}
+	return "", fmt.Errorf("command %s is not in command.allowed_commands", command)
+}
+
+// commandEnv 构造子进程的环境变量
+//
+// 不继承服务进程的环境变量，避免 NSA_* 配置（如JWT密钥、数据库DSN）泄露给子进程，只保留PATH等基础变量。
+// 节点设置的变量必须在 command.allowed_env 中，且不能是 config.DeniedCommandEnv 中的变量。
+func commandEnv(extra map[string]interface{}, allowed []string) ([]string, error) {
+	env := make([]string, 0, len(extra)+3)
+	for _, key := range []string{"PATH", "HOME", "LANG"} {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	for key, value := range extra {
+		if err := checkCommandEnv(key, allowed); err != nil {
+			return nil, err
+		}
+		env = append(env, key+"="+stringifyValue(value))
+	}
+	return env, nil
+}
+
+// checkCommandEnv 检查节点设置的环境变量名
+func checkCommandEnv(name string, allowed []string) error {
+	if name == "" || strings.ContainsAny(name, "=\x00") {
+		return fmt.Errorf("invalid env name %q", name)
+	}
+	if config.DeniedCommandEnv(name) {
+		return fmt.Errorf("env %s is not allowed, it controls how programs are found or loaded", name)
+	}
+	for _, a := range allowed {
+		if a == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("env %s is not in command.allowed_env", name)
+}
+
+// cappedBuffer 只保留前limit字节的输出缓冲区，超出部分丢弃
+type cappedBuffer struct {
+	strings.Builder
+	limit     int
+	truncated bool
+}
+
+// Write 写入数据；超出上限时仍返回完整长度，避免子进程因写入失败而退出
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.Len()
+	if remaining <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.Builder.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.Builder.Write(p)
+}
@@ -0,0 +1,74 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin:/bin")
+
+	tests := []struct {
+		name    string
+		extra   map[string]interface{}
+		allowed []string
+		want    string
+		wantErr string
+	}{
+		{name: "allowed", extra: map[string]interface{}{"TZ": "UTC"}, allowed: []string{"TZ"}, want: "TZ=UTC"},
+		{name: "number value", extra: map[string]interface{}{"RETRIES": 3.0}, allowed: []string{"RETRIES"}, want: "RETRIES=3"},
+		{name: "not allowed", extra: map[string]interface{}{"TZ": "UTC"}, wantErr: "env TZ is not in command.allowed_env"},
+		{name: "LD_PRELOAD", extra: map[string]interface{}{"LD_PRELOAD": "/tmp/x.so"}, wantErr: "env LD_PRELOAD is not allowed"},
+		{name: "LD_PRELOAD in allow-list", extra: map[string]interface{}{"LD_PRELOAD": "/tmp/x.so"}, allowed: []string{"LD_PRELOAD"}, wantErr: "env LD_PRELOAD is not allowed"},
+		{name: "LD_LIBRARY_PATH", extra: map[string]interface{}{"LD_LIBRARY_PATH": "/tmp"}, allowed: []string{"LD_LIBRARY_PATH"}, wantErr: "env LD_LIBRARY_PATH is not allowed"},
+		{name: "DYLD_INSERT_LIBRARIES", extra: map[string]interface{}{"DYLD_INSERT_LIBRARIES": "/tmp/x.dylib"}, wantErr: "env DYLD_INSERT_LIBRARIES is not allowed"},
+		{name: "PATH", extra: map[string]interface{}{"PATH": "/tmp"}, wantErr: "env PATH is not allowed"},
+		{name: "PATH in allow-list", extra: map[string]interface{}{"PATH": "/tmp"}, allowed: []string{"PATH"}, wantErr: "env PATH is not allowed"},
+		{name: "lower case path", extra: map[string]interface{}{"path": "/tmp"}, allowed: []string{"path"}, wantErr: "env path is not allowed"},
+		{name: "BASH_ENV", extra: map[string]interface{}{"BASH_ENV": "/tmp/x.sh"}, allowed: []string{"BASH_ENV"}, wantErr: "env BASH_ENV is not allowed"},
+		{name: "HOME", extra: map[string]interface{}{"HOME": "/tmp"}, allowed: []string{"HOME"}, wantErr: "env HOME is not allowed"},
+		{name: "name with =", extra: map[string]interface{}{"A=B": "x"}, allowed: []string{"A=B"}, wantErr: "invalid env name"},
+		{name: "empty name", extra: map[string]interface{}{"": "x"}, allowed: []string{""}, wantErr: "invalid env name"},
+	}
+	for _, tt := range tests {
+		env, err := commandEnv(tt.extra, tt.allowed)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: commandEnv error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: commandEnv error: %v", tt.name, err)
+			continue
+		}
+		if env[len(env)-1] != tt.want {
+			t.Errorf("%s: commandEnv = %q, want last entry %q", tt.name, env, tt.want)
+		}
+	}
+}
+
+func TestCommandEnvKeepsServicePath(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin:/bin")
+	t.Setenv("NSA_JWT_SECRET", "secret")
+
+	env, err := commandEnv(nil, nil)
+	if err != nil {
+		t.Fatalf("commandEnv error: %v", err)
+	}
+	paths := 0
+	for _, entry := range env {
+		if strings.HasPrefix(entry, "PATH=") {
+			paths++
+			if entry != "PATH=/usr/bin:/bin" {
+				t.Errorf("PATH = %q, want the service PATH", entry)
+			}
+		}
+		if strings.HasPrefix(entry, "NSA_") {
+			t.Errorf("service env %q leaked to the command", entry)
+		}
+	}
+	if paths != 1 {
+		t.Errorf("PATH appears %d times, want 1", paths)
+	}
+}
@@ -0,0 +1,54 @@
+package workflow
+
+import (
+	"sync"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// workflowConfigTTL 工作流配置缓存的有效期，其他服务副本修改工作流后最迟在有效期后生效
+const workflowConfigTTL = 30 * time.Second
+
+// cachedWorkflowConfig 缓存的工作流配置，config 为nil表示没有匹配的启用工作流
+type cachedWorkflowConfig struct {
+	config    *models.WorkflowConfig
+	expiresAt time.Time
+}
+
+// workflowConfigCache 按 topic/channel 缓存工作流配置，避免每条消息都查询MongoDB
+type workflowConfigCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]cachedWorkflowConfig
+}
+
+// newWorkflowConfigCache 创建工作流配置缓存
+func newWorkflowConfigCache(ttl time.Duration) *workflowConfigCache {
+	return &workflowConfigCache{ttl: ttl, entries: make(map[string]cachedWorkflowConfig)}
+}
+
+// get 返回未过期的缓存，缓存的配置为nil表示没有匹配的工作流
+func (c *workflowConfigCache) get(key string) (*models.WorkflowConfig, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.config, true
+}
+
+// set 缓存查询结果，config 为nil表示没有匹配的工作流
+func (c *workflowConfigCache) set(key string, config *models.WorkflowConfig) {
+	c.mu.Lock()
+	c.entries[key] = cachedWorkflowConfig{config: config, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+}
+
+// clear 清空缓存
+func (c *workflowConfigCache) clear() {
+	c.mu.Lock()
+	c.entries = make(map[string]cachedWorkflowConfig)
+	c.mu.Unlock()
+}
@@ -0,0 +1,293 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nsa/internal/logger"
+)
+
+// 连接器参数类型
+const (
+	ParamString  = "string"
+	ParamInteger = "integer"
+	ParamNumber  = "number"
+	ParamBoolean = "boolean"
+	ParamObject  = "object"
+	ParamArray   = "array"
+)
+
+// ConnectorField 连接器的连接配置项或操作参数定义
+type ConnectorField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Secret      bool   `json:"secret,omitempty"` // 连接配置项的值为密钥名称，执行时从密钥存储读取
+	Description string `json:"description,omitempty"`
+}
+
+// ConnectorOperation 连接器操作
+type ConnectorOperation struct {
+	Name        string                                                              `json:"name"`
+	Description string                                                              `json:"description"`
+	Params      []ConnectorField                                                    `json:"params"`
+	Run         func(ctx context.Context, call *ConnectorCall) (interface{}, error) `json:"-"`
+}
+
+// Connector 连接器接口
+//
+// 连接器声明连接配置（认证、服务地址等）和带类型参数的操作，
+// 由 ConnectorAction 统一完成参数校验、类型转换和密钥读取。
+type Connector interface {
+	Name() string
+	Description() string
+	Connection() []ConnectorField
+	Operations() []ConnectorOperation
+}
+
+// ConnectorCall 连接器操作的调用参数
+type ConnectorCall struct {
+	Connection map[string]string      // 连接配置，密钥项已替换为密钥值
+	Args       map[string]interface{} // 已校验和转换类型的操作参数
+	Client     *http.Client
+	Logger     logger.Logger
+	// IdempotencyKey 任务的幂等键，重试和服务重启后重新执行时保持不变
+	IdempotencyKey string
+}
+
+// String 读取字符串参数
+func (c *ConnectorCall) String(name string) string {
+	value, _ := c.Args[name].(string)
+	return value
+}
+
+// ConnectorInfo 连接器描述（用于接口展示）
+type ConnectorInfo struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Connection  []ConnectorField     `json:"connection"`
+	Operations  []ConnectorOperation `json:"operations"`
+}
+
+// ConnectorRegistry 连接器注册表
+type ConnectorRegistry struct {
+	mu         sync.RWMutex
+	connectors map[string]Connector
+}
+
+// NewConnectorRegistry 创建连接器注册表
+func NewConnectorRegistry() *ConnectorRegistry {
+	return &ConnectorRegistry{connectors: make(map[string]Connector)}
+}
+
+// Register 注册连接器，同名连接器会被替换
+func (r *ConnectorRegistry) Register(connector Connector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connectors[connector.Name()] = connector
+}
+
+// Get 获取连接器
+func (r *ConnectorRegistry) Get(name string) (Connector, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	connector, ok := r.connectors[name]
+	return connector, ok
+}
+
+// List 返回按名称排序的连接器描述
+func (r *ConnectorRegistry) List() []ConnectorInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]ConnectorInfo, 0, len(r.connectors))
+	for _, connector := range r.connectors {
+		infos = append(infos, ConnectorInfo{
+			Name:        connector.Name(),
+			Description: connector.Description(),
+			Connection:  connector.Connection(),
+			Operations:  connector.Operations(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// ConnectorAction 连接器动作，执行已注册连接器的类型化操作
+type ConnectorAction struct {
+	ctx      *ActionContext
+	registry *ConnectorRegistry
+}
+
+// NewConnectorAction 创建连接器动作
+func NewConnectorAction(ctx *ActionContext, registry *ConnectorRegistry) *ConnectorAction {
+	return &ConnectorAction{ctx: ctx, registry: registry}
+}
+
+// Name 返回动作名称
+func (a *ConnectorAction) Name() string {
+	return "ConnectorAction"
+}
+
+// Run 执行连接器操作
+func (a *ConnectorAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	connectorName, _ := params["connector"].(string)
+	operationName, _ := params["operation"].(string)
+	connection, _ := params["connection"].(map[string]interface{})
+	args, _ := params["args"].(map[string]interface{})
+	timeout, _ := params["timeout"].(float64)
+
+	connector, ok := a.registry.Get(connectorName)
+	if !ok {
+		return fmt.Errorf("connector %s not found", connectorName)
+	}
+	var operation *ConnectorOperation
+	for _, op := range connector.Operations() {
+		if op.Name == operationName {
+			op := op
+			operation = &op
+			break
+		}
+	}
+	if operation == nil {
+		return fmt.Errorf("connector %s has no operation %s", connectorName, operationName)
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	// 连接配置：密钥项从密钥存储读取
+	resolved := make(map[string]string)
+	for _, field := range connector.Connection() {
+		value := stringifyValue(connection[field.Name])
+		if value == "" {
+			if field.Required {
+				return fmt.Errorf("connection field %s is required for connector %s", field.Name, connectorName)
+			}
+			continue
+		}
+		if field.Secret {
+			secret, err := a.ctx.getSecret(ctx, value)
+			if err != nil {
+				return err
+			}
+			value = secret
+		}
+		resolved[field.Name] = value
+	}
+
+	typedArgs, err := convertConnectorArgs(operation.Params, args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments for %s.%s: %v", connectorName, operationName, err)
+	}
+
+	a.ctx.Logger.Infof("Executing connector operation %s.%s", connectorName, operationName)
+
+	output, err := operation.Run(ctx, &ConnectorCall{
+		Connection:     resolved,
+		Args:           typedArgs,
+		Client:         &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		Logger:         a.ctx.Logger,
+		IdempotencyKey: taskCtx.IdempotencyKey(),
+	})
+	if err != nil {
+		return fmt.Errorf("connector operation %s.%s failed: %v", connectorName, operationName, err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(output)
+	a.ctx.Logger.Infof("Connector operation %s.%s completed successfully", connectorName, operationName)
+
+	return nil
+}
+
+// convertConnectorArgs 按参数定义校验必填项并转换类型，忽略未声明的参数
+func convertConnectorArgs(fields []ConnectorField, args map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		value, exists := args[field.Name]
+		if !exists || value == nil || value == "" {
+			if field.Required {
+				return nil, fmt.Errorf("%s is required", field.Name)
+			}
+			continue
+		}
+
+		converted, err := convertConnectorValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field.Name, err)
+		}
+		result[field.Name] = converted
+	}
+	return result, nil
+}
+
+// convertConnectorValue 转换单个参数值，模板渲染得到的字符串会按声明类型解析
+func convertConnectorValue(fieldType string, value interface{}) (interface{}, error) {
+	switch fieldType {
+	case ParamString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64, int, int64, bool:
+			return stringifyValue(v), nil
+		}
+	case ParamInteger:
+		switch v := value.(type) {
+		case float64:
+			if v == float64(int64(v)) {
+				return int64(v), nil
+			}
+		case int:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case string:
+			if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return n, nil
+			}
+		}
+	case ParamNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case string:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return n, nil
+			}
+		}
+	case ParamBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+	case ParamObject:
+		if v, ok := value.(map[string]interface{}); ok {
+			return v, nil
+		}
+	case ParamArray:
+		if v, ok := value.([]interface{}); ok {
+			return v, nil
+		}
+	default:
+		return value, nil
+	}
+	return nil, fmt.Errorf("expected %s, got %T", fieldType, value)
+}
@@ -0,0 +1,235 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SAPODataConnector SAP OData服务连接器（兼容 OData V2 和 V4 响应格式）
+type SAPODataConnector struct{}
+
+// Name 返回连接器名称
+func (c *SAPODataConnector) Name() string {
+	return "sap_odata"
+}
+
+// Description 返回连接器描述
+func (c *SAPODataConnector) Description() string {
+	return "SAP OData services (S/4HANA, Gateway): query, read, create and update entities"
+}
+
+// Connection 返回连接配置项
+func (c *SAPODataConnector) Connection() []ConnectorField {
+	return []ConnectorField{
+		{Name: "service_url", Type: ParamString, Required: true, Description: "Service root, e.g. https://host/sap/opu/odata/sap/API_BUSINESS_PARTNER"},
+		{Name: "username", Type: ParamString, Required: true},
+		{Name: "password_secret", Type: ParamString, Required: true, Secret: true},
+		{Name: "client", Type: ParamString, Description: "SAP client (sap-client)"},
+	}
+}
+
+// Operations 返回连接器操作
+func (c *SAPODataConnector) Operations() []ConnectorOperation {
+	entitySet := ConnectorField{Name: "entity_set", Type: ParamString, Required: true}
+	key := ConnectorField{Name: "key", Type: ParamString, Required: true, Description: "Entity key, e.g. '1000001' or BusinessPartner='1000001'"}
+
+	return []ConnectorOperation{
+		{
+			Name:        "query",
+			Description: "Query an entity set with $filter, $select, $orderby, $expand, $top and $skip",
+			Params: []ConnectorField{
+				entitySet,
+				{Name: "filter", Type: ParamString},
+				{Name: "select", Type: ParamString},
+				{Name: "orderby", Type: ParamString},
+				{Name: "expand", Type: ParamString},
+				{Name: "top", Type: ParamInteger},
+				{Name: "skip", Type: ParamInteger},
+			},
+			Run: func(ctx context.Context, call *ConnectorCall) (interface{}, error) {
+				query := url.Values{}
+				for _, option := range []string{"filter", "select", "orderby", "expand"} {
+					if value := call.String(option); value != "" {
+						query.Set("$"+option, value)
+					}
+				}
+				for _, option := range []string{"top", "skip"} {
+					if value, ok := call.Args[option].(int64); ok {
+						query.Set("$"+option, strconv.FormatInt(value, 10))
+					}
+				}
+				data, err := sapRequest(ctx, call, http.MethodGet, "/"+url.PathEscape(call.String("entity_set")), query, nil)
+				if err != nil {
+					return nil, err
+				}
+				results := sapResults(data)
+				return map[string]interface{}{"results": results, "count": len(results)}, nil
+			},
+		},
+		{
+			Name:        "get",
+			Description: "Read a single entity by key",
+			Params:      []ConnectorField{entitySet, key, {Name: "expand", Type: ParamString}},
+			Run: func(ctx context.Context, call *ConnectorCall) (interface{}, error) {
+				query := url.Values{}
+				if expand := call.String("expand"); expand != "" {
+					query.Set("$expand", expand)
+				}
+				data, err := sapRequest(ctx, call, http.MethodGet, sapEntityPath(call), query, nil)
+				if err != nil {
+					return nil, err
+				}
+				return sapEntity(data), nil
+			},
+		},
+		{
+			Name:        "create",
+			Description: "Create an entity",
+			Params:      []ConnectorField{entitySet, {Name: "data", Type: ParamObject, Required: true}},
+			Run: func(ctx context.Context, call *ConnectorCall) (interface{}, error) {
+				data, err := sapRequest(ctx, call, http.MethodPost, "/"+url.PathEscape(call.String("entity_set")), nil, call.Args["data"])
+				if err != nil {
+					return nil, err
+				}
+				return sapEntity(data), nil
+			},
+		},
+		{
+			Name:        "update",
+			Description: "Partially update an entity (MERGE/PATCH)",
+			Params:      []ConnectorField{entitySet, key, {Name: "data", Type: ParamObject, Required: true}},
+			Run: func(ctx context.Context, call *ConnectorCall) (interface{}, error) {
+				if _, err := sapRequest(ctx, call, http.MethodPatch, sapEntityPath(call), nil, call.Args["data"]); err != nil {
+					return nil, err
+				}
+				return map[string]interface{}{"updated": true}, nil
+			},
+		},
+	}
+}
+
+// sapEntityPath 构建单个实体的路径，如 /A_BusinessPartner('1000001')
+func sapEntityPath(call *ConnectorCall) string {
+	return "/" + url.PathEscape(call.String("entity_set")) + "(" + url.PathEscape(call.String("key")) + ")"
+}
+
+// sapRequest 发送OData请求
+//
+// 修改类请求先获取CSRF令牌，并携带获取令牌时返回的会话Cookie。
+func sapRequest(ctx context.Context, call *ConnectorCall, method, path string, query url.Values, body interface{}) (interface{}, error) {
+	serviceURL := strings.TrimRight(call.Connection["service_url"], "/")
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("$format", "json")
+	if client := call.Connection["client"]; client != "" {
+		query.Set("sap-client", client)
+	}
+
+	newRequest := func(method, endpoint string, body io.Reader) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.SetBasicAuth(call.Connection["username"], call.Connection["password_secret"])
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	}
+
+	var csrfToken string
+	var cookies []*http.Cookie
+	if method != http.MethodGet {
+		req, err := newRequest(http.MethodHead, serviceURL+"/", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-CSRF-Token", "Fetch")
+		resp, err := call.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch CSRF token: %v", err)
+		}
+		resp.Body.Close()
+		csrfToken = resp.Header.Get("X-CSRF-Token")
+		cookies = resp.Cookies()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := newRequest(method, serviceURL+path+"?"+query.Encode(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if csrfToken != "" {
+		req.Header.Set("X-CSRF-Token", csrfToken)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := call.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &restError{StatusCode: resp.StatusCode, Body: tailString(string(data), maxErrorBodySize)}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %v", err)
+	}
+	return result, nil
+}
+
+// sapResults 提取集合查询结果（V2: d.results，V4: value）
+func sapResults(data interface{}) []interface{} {
+	root, _ := data.(map[string]interface{})
+	if d, ok := root["d"].(map[string]interface{}); ok {
+		if results, ok := d["results"].([]interface{}); ok {
+			return results
+		}
+	}
+	if d, ok := root["d"].([]interface{}); ok {
+		return d
+	}
+	if value, ok := root["value"].([]interface{}); ok {
+		return value
+	}
+	return []interface{}{}
+}
+
+// sapEntity 提取单个实体（V2包裹在d中，V4直接返回）
+func sapEntity(data interface{}) interface{} {
+	if root, ok := data.(map[string]interface{}); ok {
+		if d, ok := root["d"].(map[string]interface{}); ok {
+			return d
+		}
+	}
+	return data
+}
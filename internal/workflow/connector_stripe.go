@@ -0,0 +1,178 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// defaultStripeURL Stripe API地址
+const defaultStripeURL = "https://api.stripe.com"
+
+// StripeConnector Stripe支付连接器
+type StripeConnector struct{}
+
+// Name 返回连接器名称
+func (c *StripeConnector) Name() string {
+	return "stripe"
+}
+
+// Description 返回连接器描述
+func (c *StripeConnector) Description() string {
+	return "Stripe payments: customers, payment intents and refunds"
+}
+
+// Connection 返回连接配置项
+func (c *StripeConnector) Connection() []ConnectorField {
+	return []ConnectorField{
+		{Name: "api_key_secret", Type: ParamString, Required: true, Secret: true, Description: "Secret key (sk_...)"},
+		{Name: "base_url", Type: ParamString, Description: "API base URL, defaults to https://api.stripe.com"},
+	}
+}
+
+// Operations 返回连接器操作
+func (c *StripeConnector) Operations() []ConnectorOperation {
+	return []ConnectorOperation{
+		{
+			Name:        "create_customer",
+			Description: "Create a customer",
+			Params: []ConnectorField{
+				{Name: "email", Type: ParamString},
+				{Name: "name", Type: ParamString},
+				{Name: "description", Type: ParamString},
+				{Name: "metadata", Type: ParamObject},
+				{Name: "idempotency_key", Type: ParamString},
+			},
+			Run: func(ctx context.Context, call *ConnectorCall) (interface{}, error) {
+				return stripeRequest(ctx, call, http.MethodPost, "/v1/customers", "email", "name", "description", "metadata")
+			},
+		},
+		{
+			Name:        "create_payment_intent",
+			Description: "Create a payment intent; amount is in the smallest currency unit",
+			Params: []ConnectorField{
+				{Name: "amount", Type: ParamInteger, Required: true},
+				{Name: "currency", Type: ParamString, Required: true},
+				{Name: "customer", Type: ParamString},
+				{Name: "description", Type: ParamString},
+				{Name: "payment_method", Type: ParamString},
+				{Name: "confirm", Type: ParamBoolean},
+				{Name: "metadata", Type: ParamObject},
+				{Name: "idempotency_key", Type: ParamString},
+			},
+			Run: func(ctx context.Context, call *ConnectorCall) (interface{}, error) {
+				return stripeRequest(ctx, call, http.MethodPost, "/v1/payment_intents",
+					"amount", "currency", "customer", "description", "payment_method", "confirm", "metadata")
+			},
+		},
+		{
+			Name:        "get_payment_intent",
+			Description: "Retrieve a payment intent",
+			Params: []ConnectorField{
+				{Name: "id", Type: ParamString, Required: true},
+			},
+			Run: func(ctx context.Context, call *ConnectorCall) (interface{}, error) {
+				return stripeRequest(ctx, call, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(call.String("id")))
+			},
+		},
+		{
+			Name:        "create_refund",
+			Description: "Refund a payment intent, fully or partially",
+			Params: []ConnectorField{
+				{Name: "payment_intent", Type: ParamString, Required: true},
+				{Name: "amount", Type: ParamInteger},
+				{Name: "reason", Type: ParamString, Description: "duplicate, fraudulent or requested_by_customer"},
+				{Name: "metadata", Type: ParamObject},
+				{Name: "idempotency_key", Type: ParamString},
+			},
+			Run: func(ctx context.Context, call *ConnectorCall) (interface{}, error) {
+				return stripeRequest(ctx, call, http.MethodPost, "/v1/refunds", "payment_intent", "amount", "reason", "metadata")
+			},
+		},
+	}
+}
+
+// stripeRequest 发送Stripe请求，fields中的参数按表单编码作为请求体
+func stripeRequest(ctx context.Context, call *ConnectorCall, method, path string, fields ...string) (interface{}, error) {
+	baseURL := call.Connection["base_url"]
+	if baseURL == "" {
+		baseURL = defaultStripeURL
+	}
+
+	form := url.Values{}
+	for _, field := range fields {
+		if value, ok := call.Args[field]; ok {
+			stripeEncode(form, field, value)
+		}
+	}
+
+	var body io.Reader
+	if method != http.MethodGet {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(baseURL, "/")+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+call.Connection["api_key_secret"])
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	// 幂等键保证重试时不会重复扣款，未指定时使用任务的幂等键
+	if method != http.MethodGet {
+		key := call.String("idempotency_key")
+		if key == "" {
+			key = call.IdempotencyKey
+		}
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	resp, err := call.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		if stripeErr, ok := result["error"].(map[string]interface{}); ok {
+			return nil, fmt.Errorf("stripe error (%d): %v", resp.StatusCode, stripeErr["message"])
+		}
+		return nil, &restError{StatusCode: resp.StatusCode, Body: tailString(string(data), maxErrorBodySize)}
+	}
+	return result, nil
+}
+
+// stripeEncode 按Stripe的表单格式编码参数，对象展开为 key[sub]=value
+func stripeEncode(form url.Values, key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			stripeEncode(form, key+"["+k+"]", v[k])
+		}
+	case []interface{}:
+		for i, item := range v {
+			stripeEncode(form, fmt.Sprintf("%s[%d]", key, i), item)
+		}
+	default:
+		form.Set(key, stringifyValue(v))
+	}
+}
@@ -0,0 +1,276 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"nsa/internal/models"
+)
+
+const (
+	// maxDelay 最长延迟时间
+	maxDelay = 30 * 24 * time.Hour
+	// delayWheelTick 时间轮的刻度，恢复时间精确到秒
+	delayWheelTick = time.Second
+	// delayWheelSlots 时间轮的槽数，一圈为一小时，更长的延迟按圈数计数
+	delayWheelSlots = 3600
+)
+
+// suspendError 任务要求暂停工作流实例直到恢复时间，不表示任务失败
+type suspendError struct {
+	resumeAt time.Time
+}
+
+// Error 实现error接口
+func (e *suspendError) Error() string {
+	return fmt.Sprintf("instance suspended until %s", e.resumeAt.Format(time.RFC3339))
+}
+
+// DelayAction 延迟动作，暂停工作流实例一段时间或直到指定时间后继续执行后续任务
+//
+// 等待期间实例状态为 delayed，恢复时间持久化在实例中，不占用执行goroutine；服务重启后由 RecoverInstances 恢复。
+type DelayAction struct {
+	ctx *ActionContext
+}
+
+// NewDelayAction 创建延迟动作
+func NewDelayAction(ctx *ActionContext) *DelayAction {
+	return &DelayAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *DelayAction) Name() string {
+	return "DelayAction"
+}
+
+// Run 计算恢复时间并暂停实例
+//
+// seconds、minutes 为延迟时长，可以同时配置并相加；until 为恢复时间（RFC3339 字符串或 Unix 时间戳），与时长互斥。
+// 恢复时间已过时不暂停，直接继续执行。
+func (a *DelayAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params, _ := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	now := time.Now()
+	var resumeAt time.Time
+	if until, ok := params["until"]; ok && until != nil {
+		if params["seconds"] != nil || params["minutes"] != nil {
+			return fmt.Errorf("until is mutually exclusive with seconds and minutes")
+		}
+		t, err := parseResumeTime(until)
+		if err != nil {
+			return err
+		}
+		resumeAt = t
+	} else {
+		if params["seconds"] == nil && params["minutes"] == nil {
+			return fmt.Errorf("seconds, minutes or until parameter is required")
+		}
+		var delay time.Duration
+		for name, unit := range map[string]time.Duration{"seconds": time.Second, "minutes": time.Minute} {
+			if params[name] == nil {
+				continue
+			}
+			n, ok := toNumber(params[name])
+			if !ok || n < 0 {
+				return fmt.Errorf("%s must be a non-negative number, got %v", name, params[name])
+			}
+			delay += time.Duration(n * float64(unit))
+		}
+		resumeAt = now.Add(delay)
+	}
+	if resumeAt.Sub(now) > maxDelay {
+		return fmt.Errorf("delay exceeds maximum of %d seconds", int(maxDelay.Seconds()))
+	}
+
+	taskCtx.SetOutput(map[string]interface{}{
+		"resume_at":     resumeAt,
+		"delay_seconds": resumeAt.Sub(now).Seconds(),
+	})
+	if !resumeAt.After(now) {
+		return nil
+	}
+	return &suspendError{resumeAt: resumeAt}
+}
+
+// isSuspended 判断任务是否要求暂停实例
+func isSuspended(err error) bool {
+	var suspended *suspendError
+	return errors.As(err, &suspended)
+}
+
+// parseResumeTime 解析恢复时间：RFC3339 字符串，或 Unix 时间戳（秒，超过 1e12 时按毫秒）
+func parseResumeTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		if _, ok := toNumber(v); !ok {
+			// 模板变量无法解析时保持原样
+			return time.Time{}, fmt.Errorf("until must be an RFC3339 time or Unix timestamp: %q", v)
+		}
+	}
+
+	n, ok := toNumber(value)
+	if !ok {
+		return time.Time{}, fmt.Errorf("until must be an RFC3339 time or Unix timestamp, got %T", value)
+	}
+	if n > 1e12 {
+		return time.UnixMilli(int64(n)), nil
+	}
+	return time.Unix(int64(n), int64((n-float64(int64(n)))*1e9)), nil
+}
+
+// toNumber 将数字或数字字符串转换为float64
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// wheelTimer 时间轮中的定时任务
+type wheelTimer struct {
+	rounds int
+	fn     func()
+}
+
+// timerWheel 哈希时间轮，所有延迟实例共用一个goroutine计时，添加定时任务为O(1)
+type timerWheel struct {
+	mu    sync.Mutex
+	tick  time.Duration
+	slots [][]*wheelTimer
+	pos   int
+	done  chan struct{}
+	once  sync.Once
+}
+
+// newTimerWheel 创建并启动时间轮
+func newTimerWheel(tick time.Duration, slots int) *timerWheel {
+	w := &timerWheel{
+		tick:  tick,
+		slots: make([][]*wheelTimer, slots),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// schedule 在指定时间之后（按刻度向上取整）在新的goroutine中执行函数
+func (w *timerWheel) schedule(at time.Time, fn func()) {
+	ticks := int((time.Until(at) + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slot := (w.pos + ticks) % len(w.slots)
+	w.slots[slot] = append(w.slots[slot], &wheelTimer{rounds: (ticks - 1) / len(w.slots), fn: fn})
+}
+
+// run 每个刻度前进一格，执行到期的定时任务
+func (w *timerWheel) run() {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.advance()
+		}
+	}
+}
+
+// advance 前进一格，到期的定时任务从槽中移除并执行，其余的圈数减一
+func (w *timerWheel) advance() {
+	w.mu.Lock()
+	w.pos = (w.pos + 1) % len(w.slots)
+	var due []*wheelTimer
+	pending := w.slots[w.pos][:0]
+	for _, timer := range w.slots[w.pos] {
+		if timer.rounds > 0 {
+			timer.rounds--
+			pending = append(pending, timer)
+			continue
+		}
+		due = append(due, timer)
+	}
+	for i := len(pending); i < len(w.slots[w.pos]); i++ {
+		w.slots[w.pos][i] = nil
+	}
+	w.slots[w.pos] = pending
+	w.mu.Unlock()
+
+	for _, timer := range due {
+		go timer.fn()
+	}
+}
+
+// len 返回等待中的定时任务数
+func (w *timerWheel) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, slot := range w.slots {
+		n += len(slot)
+	}
+	return n
+}
+
+// stop 停止时间轮，未到期的定时任务不再执行
+func (w *timerWheel) stop() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// delay 在恢复时间到达后继续执行延迟的实例
+func (e *Executor) delay(ctx context.Context, instance *WorkflowInstance, tasks []Task, nsqMessage *models.NSQMessage, onEnd func()) {
+	e.logger.Infof("Workflow instance %s delayed until %s", instance.ID, instance.ResumeAt.Format(time.RFC3339))
+	e.delays.schedule(instance.ResumeAt, func() {
+		if e.instanceStatus(instance.ID) == "cancelled" {
+			e.logger.Infof("Workflow instance %s was cancelled while delayed", instance.ID)
+			if onEnd != nil {
+				onEnd()
+			}
+			return
+		}
+		instance.Status = "running"
+		instance.ResumeAt = time.Time{}
+		if err := e.saveWorkflowInstance(instance); err != nil {
+			if e.instanceConflict(instance, err) {
+				if onEnd != nil {
+					onEnd()
+				}
+				return
+			}
+			e.logger.Errorf("Failed to save resumed workflow instance %s: %v", instance.ID, err)
+		}
+		e.recordHistory(instance, HistoryEvent{Type: HistoryResumed})
+		e.logger.Infof("Workflow instance %s resumed", instance.ID)
+		e.executeTasks(ctx, instance, tasks, nsqMessage, onEnd)
+	})
+}
+
+// DelayedInstances 返回等待恢复的延迟实例数
+func (e *Executor) DelayedInstances() int {
+	return e.delays.len()
+}
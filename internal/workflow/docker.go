@@ -0,0 +1,643 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultDockerHost 默认Docker守护进程地址
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// maxContainerLogSize 容器输出的最大读取大小
+const maxContainerLogSize = 10 * 1024 * 1024
+
+// DockerAction 容器操作动作（拉取镜像、运行一次性容器、查询镜像仓库）
+type DockerAction struct {
+	ctx *ActionContext
+}
+
+// NewDockerAction 创建容器操作动作
+func NewDockerAction(ctx *ActionContext) *DockerAction {
+	return &DockerAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *DockerAction) Name() string {
+	return "DockerAction"
+}
+
+// dockerClient Docker Engine API客户端
+type dockerClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// registryCredentials 镜像仓库凭据
+type registryCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Run 执行容器操作
+func (a *DockerAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := renderValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	host, _ := params["host"].(string)
+	timeout, _ := params["timeout"].(float64)
+	authSecret, _ := params["registry_auth_secret"].(string)
+
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if host == "" {
+		host = defaultDockerHost
+	}
+	if timeout == 0 {
+		timeout = 300
+	}
+
+	// 读取仓库凭据
+	var creds *registryCredentials
+	if authSecret != "" {
+		var err error
+		creds, err = a.loadCredentials(ctx, authSecret)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	a.ctx.Logger.Infof("Executing Docker operation %s", operation)
+
+	var result interface{}
+	var err error
+	switch operation {
+	case "pull":
+		var client *dockerClient
+		if client, err = newDockerClient(host); err == nil {
+			result, err = a.pull(ctx, client, params, creds)
+		}
+	case "run":
+		var client *dockerClient
+		if client, err = newDockerClient(host); err == nil {
+			result, err = a.runContainer(ctx, client, params, creds)
+		}
+	case "list_tags":
+		result, err = a.listTags(ctx, params, creds)
+	case "inspect_manifest":
+		result, err = a.inspectManifest(ctx, params, creds)
+	default:
+		return fmt.Errorf("unsupported docker operation: %s", operation)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Docker operation %s completed successfully", operation)
+
+	return nil
+}
+
+// loadCredentials 从密钥中读取仓库凭据，支持JSON或 username:password 格式
+func (a *DockerAction) loadCredentials(ctx context.Context, name string) (*registryCredentials, error) {
+	if a.ctx.Secrets == nil {
+		return nil, fmt.Errorf("secret store is not available")
+	}
+	value, err := a.ctx.Secrets.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds registryCredentials
+	if err := json.Unmarshal([]byte(value), &creds); err == nil && creds.Username != "" {
+		return &creds, nil
+	}
+	username, password, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid registry credentials in secret %s", name)
+	}
+	return &registryCredentials{Username: username, Password: password}, nil
+}
+
+// newDockerClient 创建Docker客户端，支持 unix:// 和 tcp:// 地址
+func newDockerClient(host string) (*dockerClient, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host: %v", err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &dockerClient{baseURL: "http://docker", httpClient: &http.Client{Transport: transport}}, nil
+	case "tcp", "http":
+		return &dockerClient{baseURL: "http://" + u.Host, httpClient: &http.Client{}}, nil
+	case "https":
+		return &dockerClient{baseURL: "https://" + u.Host, httpClient: &http.Client{}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme: %s", u.Scheme)
+	}
+}
+
+// do 调用Docker Engine API
+func (c *dockerClient) do(ctx context.Context, method, path string, body interface{}, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker request failed: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = string(data)
+		}
+		return nil, fmt.Errorf("docker API returned status %d: %s", resp.StatusCode, apiErr.Message)
+	}
+	return resp, nil
+}
+
+// doJSON 调用Docker Engine API并解析JSON响应
+func (c *dockerClient) doJSON(ctx context.Context, method, path string, body interface{}, result interface{}) error {
+	resp, err := c.do(ctx, method, path, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// pull 拉取镜像
+func (a *DockerAction) pull(ctx context.Context, client *dockerClient, params map[string]interface{}, creds *registryCredentials) (interface{}, error) {
+	image, _ := params["image"].(string)
+	if image == "" {
+		return nil, fmt.Errorf("image parameter is required")
+	}
+	if err := client.pullImage(ctx, image, creds); err != nil {
+		return nil, err
+	}
+
+	var inspect struct {
+		ID          string   `json:"Id"`
+		RepoDigests []string `json:"RepoDigests"`
+		Size        int64    `json:"Size"`
+	}
+	if err := client.doJSON(ctx, http.MethodGet, "/images/"+image+"/json", nil, &inspect); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"image":   image,
+		"id":      inspect.ID,
+		"digests": inspect.RepoDigests,
+		"size":    inspect.Size,
+	}, nil
+}
+
+// pullImage 拉取镜像并等待完成
+func (c *dockerClient) pullImage(ctx context.Context, image string, creds *registryCredentials) error {
+	header := http.Header{}
+	if creds != nil {
+		auth, _ := json.Marshal(map[string]string{
+			"username":      creds.Username,
+			"password":      creds.Password,
+			"serveraddress": registryHost(image),
+		})
+		header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString(auth))
+	}
+
+	// 未指定标签时Docker会拉取全部标签，这里默认使用latest
+	if !strings.Contains(image, "@") && strings.LastIndex(image, ":") <= strings.LastIndex(image, "/") {
+		image += ":latest"
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/images/create?fromImage="+url.QueryEscape(image), nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 拉取进度为JSON流，错误在流中返回
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&event); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read pull progress: %v", err)
+		}
+		if event.Error != "" {
+			return fmt.Errorf("failed to pull image %s: %s", image, event.Error)
+		}
+	}
+}
+
+// runContainer 运行一次性容器，等待退出并收集输出
+//
+// 负载通过环境变量 NSA_PAYLOAD 传入（JSON），容器标准输出若为JSON则解析到 json 字段。
+func (a *DockerAction) runContainer(ctx context.Context, client *dockerClient, params map[string]interface{}, creds *registryCredentials) (interface{}, error) {
+	image, _ := params["image"].(string)
+	pullPolicy, _ := params["pull"].(string) // always, missing(默认), never
+	network, _ := params["network"].(string)
+	memory, _ := params["memory"].(float64) // MB
+	keep, _ := params["keep_container"].(bool)
+
+	if image == "" {
+		return nil, fmt.Errorf("image parameter is required")
+	}
+
+	// 拉取镜像
+	switch pullPolicy {
+	case "always":
+		if err := client.pullImage(ctx, image, creds); err != nil {
+			return nil, err
+		}
+	case "never":
+	default:
+		if err := client.doJSON(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil); err != nil {
+			if err := client.pullImage(ctx, image, creds); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 环境变量
+	var env []string
+	if vars, ok := params["env"].(map[string]interface{}); ok {
+		for key, value := range vars {
+			env = append(env, key+"="+stringifyValue(value))
+		}
+	}
+	if payload, ok := params["payload"]; ok {
+		env = append(env, "NSA_PAYLOAD="+stringifyValue(payload))
+	}
+
+	config := map[string]interface{}{
+		"Image":        image,
+		"Env":          env,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Labels":       map[string]string{"nsa.managed": "true"},
+	}
+	if cmd, ok := params["command"].([]interface{}); ok {
+		config["Cmd"] = cmd
+	}
+	if entrypoint, ok := params["entrypoint"].([]interface{}); ok {
+		config["Entrypoint"] = entrypoint
+	}
+	hostConfig := map[string]interface{}{}
+	if network != "" {
+		hostConfig["NetworkMode"] = network
+	}
+	if memory > 0 {
+		hostConfig["Memory"] = int64(memory) * 1024 * 1024
+	}
+	config["HostConfig"] = hostConfig
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := client.doJSON(ctx, http.MethodPost, "/containers/create", config, &created); err != nil {
+		return nil, err
+	}
+
+	// 清理容器（使用独立的上下文，避免超时后无法删除）
+	if !keep {
+		defer func() {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := client.doJSON(cleanupCtx, http.MethodDelete, "/containers/"+created.ID+"?force=true", nil, nil); err != nil {
+				a.ctx.Logger.Warnf("Failed to remove container %s: %v", created.ID, err)
+			}
+		}()
+	}
+
+	if err := client.doJSON(ctx, http.MethodPost, "/containers/"+created.ID+"/start", nil, nil); err != nil {
+		return nil, err
+	}
+
+	var waited struct {
+		StatusCode int `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	if err := client.doJSON(ctx, http.MethodPost, "/containers/"+created.ID+"/wait", nil, &waited); err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, err := client.containerLogs(ctx, created.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"container_id": created.ID,
+		"exit_code":    waited.StatusCode,
+		"stdout":       stdout,
+		"stderr":       stderr,
+	}
+	var parsed interface{}
+	if json.Unmarshal([]byte(strings.TrimSpace(stdout)), &parsed) == nil {
+		result["json"] = parsed
+	}
+
+	if waited.StatusCode != 0 {
+		return nil, fmt.Errorf("container exited with code %d: %s", waited.StatusCode, tailString(stderr, 1024))
+	}
+	return result, nil
+}
+
+// containerLogs 读取容器输出（非TTY模式下为带8字节头的多路复用流）
+func (c *dockerClient) containerLogs(ctx context.Context, id string) (string, string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+id+"/logs?stdout=1&stderr=1", nil, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	var stdout, stderr bytes.Buffer
+	reader := io.LimitReader(resp.Body, maxContainerLogSize)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return "", "", err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		target := &stdout
+		if header[0] == 2 {
+			target = &stderr
+		}
+		if _, err := io.CopyN(target, reader, size); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", "", err
+		}
+	}
+
+	return stdout.String(), stderr.String(), nil
+}
+
+// listTags 查询镜像仓库中的标签
+func (a *DockerAction) listTags(ctx context.Context, params map[string]interface{}, creds *registryCredentials) (interface{}, error) {
+	image, _ := params["image"].(string)
+	if image == "" {
+		return nil, fmt.Errorf("image parameter is required")
+	}
+
+	host, repository, _ := parseImageReference(image)
+	resp, err := registryRequest(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/%s/tags/list", host, repository), nil, creds)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tags struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("invalid registry response: %v", err)
+	}
+
+	return map[string]interface{}{
+		"registry":   host,
+		"repository": repository,
+		"tags":       tags.Tags,
+	}, nil
+}
+
+// inspectManifest 查询镜像标签对应的digest
+func (a *DockerAction) inspectManifest(ctx context.Context, params map[string]interface{}, creds *registryCredentials) (interface{}, error) {
+	image, _ := params["image"].(string)
+	if image == "" {
+		return nil, fmt.Errorf("image parameter is required")
+	}
+
+	host, repository, reference := parseImageReference(image)
+	header := http.Header{}
+	header.Set("Accept", strings.Join([]string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}, ", "))
+
+	resp, err := registryRequest(ctx, http.MethodHead, fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repository, reference), header, creds)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return map[string]interface{}{
+		"registry":   host,
+		"repository": repository,
+		"reference":  reference,
+		"digest":     resp.Header.Get("Docker-Content-Digest"),
+		"media_type": resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+// registryRequest 调用Registry HTTP API v2，自动处理Bearer令牌认证
+func registryRequest(ctx context.Context, method, rawURL string, header http.Header, creds *registryCredentials) (*http.Response, error) {
+	send := func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return http.DefaultClient.Do(req)
+	}
+
+	resp, err := send("")
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %v", err)
+	}
+
+	// 根据WWW-Authenticate质询获取令牌后重试
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		authorization, err := registryAuthorization(ctx, challenge, creds)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = send(authorization); err != nil {
+			return nil, fmt.Errorf("registry request failed: %v", err)
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry returned status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// registryAuthorization 根据认证质询生成Authorization头
+func registryAuthorization(ctx context.Context, challenge string, creds *registryCredentials) (string, error) {
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds == nil {
+			return "", fmt.Errorf("registry requires credentials")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported registry auth challenge: %s", challenge)
+	}
+
+	// 解析 realm="...",service="...",scope="..."
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			attrs[key] = strings.Trim(value, `"`)
+		}
+	}
+	if attrs["realm"] == "" {
+		return "", fmt.Errorf("invalid registry auth challenge: %s", challenge)
+	}
+
+	query := url.Values{}
+	if attrs["service"] != "" {
+		query.Set("service", attrs["service"])
+	}
+	if attrs["scope"] != "" {
+		query.Set("scope", attrs["scope"])
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, attrs["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if creds != nil {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("registry token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("registry token request returned status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid registry token response: %v", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseImageReference 解析镜像引用为仓库地址、仓库名和标签/digest
+func parseImageReference(image string) (string, string, string) {
+	host := registryHost(image)
+	name := image
+	if first, rest, ok := strings.Cut(image, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		name = rest
+	}
+
+	reference := "latest"
+	if repo, digest, ok := strings.Cut(name, "@"); ok {
+		name, reference = repo, digest
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, reference = name[:i], name[i+1:]
+	}
+
+	// Docker Hub官方镜像位于library命名空间
+	if host == "registry-1.docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	return host, name, reference
+}
+
+// registryHost 返回镜像所在的仓库地址
+func registryHost(image string) string {
+	first, _, ok := strings.Cut(image, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		if first == "docker.io" {
+			return "registry-1.docker.io"
+		}
+		return first
+	}
+	return "registry-1.docker.io"
+}
+
+// tailString 返回字符串末尾最多n个字节
+func tailString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
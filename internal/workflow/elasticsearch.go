@@ -0,0 +1,291 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/datasource"
+)
+
+// maxESFailures 批量写入结果中保留的失败条目数量上限
+const maxESFailures = 100
+
+// ESAction Elasticsearch/OpenSearch动作，连接信息来自 elasticsearch 类型数据源
+type ESAction struct {
+	ctx *ActionContext
+}
+
+// NewESAction 创建Elasticsearch动作
+func NewESAction(ctx *ActionContext) *ESAction {
+	return &ESAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *ESAction) Name() string {
+	return "ESAction"
+}
+
+// Run 执行Elasticsearch操作
+func (a *ESAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	dataSourceName, _ := params["datasource"].(string)
+	operation, _ := params["operation"].(string)
+	indexName, _ := params["index"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if dataSourceName == "" {
+		return fmt.Errorf("datasource parameter is required")
+	}
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if timeout == 0 {
+		timeout = 60
+	}
+
+	client, err := a.ctx.DataSourceMgr.GetESClient(dataSourceName)
+	if err != nil {
+		return fmt.Errorf("failed to get elasticsearch client: %v", err)
+	}
+	// 批量写入的文档可以各自指定索引，此时允许不配置默认索引
+	index, err := client.Index(indexName)
+	if err != nil && operation != "bulk" {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	a.ctx.Logger.Infof("Executing elasticsearch %s on %s", operation, index)
+
+	var result map[string]interface{}
+	switch operation {
+	case "index":
+		result, err = a.index(ctx, client, index, params)
+	case "search":
+		result, err = a.search(ctx, client, index, params)
+	case "bulk":
+		result, err = a.bulk(ctx, client, index, params)
+	case "delete_by_query":
+		result, err = a.deleteByQuery(ctx, client, index, params)
+	default:
+		return fmt.Errorf("unsupported elasticsearch operation: %s", operation)
+	}
+	if err != nil {
+		return fmt.Errorf("elasticsearch %s on %s failed: %v", operation, index, err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Elasticsearch %s on %s completed successfully", operation, index)
+
+	return nil
+}
+
+// index 写入单个文档
+func (a *ESAction) index(ctx context.Context, client *datasource.ESClient, index string, params map[string]interface{}) (map[string]interface{}, error) {
+	document, ok := params["document"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("document parameter is required for index")
+	}
+	opType, _ := params["op_type"].(string)
+	if opType != "" && opType != "index" && opType != "create" {
+		return nil, fmt.Errorf("op_type must be index or create")
+	}
+
+	resp, err := client.IndexDocument(ctx, index, esID(params["id"]), document, opType, esRefresh(params["refresh"]))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"index":   resp["_index"],
+		"id":      resp["_id"],
+		"result":  resp["result"],
+		"version": resp["_version"],
+	}, nil
+}
+
+// search 执行搜索，body为完整的查询DSL，query、size、from、sort、aggs、_source 覆盖body中的同名字段
+func (a *ESAction) search(ctx context.Context, client *datasource.ESClient, index string, params map[string]interface{}) (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	if raw, ok := params["body"].(map[string]interface{}); ok {
+		for key, value := range raw {
+			body[key] = value
+		}
+	}
+	for _, key := range []string{"query", "size", "from", "sort", "aggs", "_source"} {
+		if value, ok := params[key]; ok {
+			body[key] = value
+		}
+	}
+
+	resp, err := client.Search(ctx, index, body)
+	if err != nil {
+		return nil, err
+	}
+
+	hitsObj, _ := resp["hits"].(map[string]interface{})
+	hits, _ := hitsObj["hits"].([]interface{})
+	if hits == nil {
+		hits = []interface{}{}
+	}
+	// ES 7+ 和 OpenSearch 的 total 为 {value, relation}，ES 6 为数字
+	total := hitsObj["total"]
+	if totalObj, ok := total.(map[string]interface{}); ok {
+		total = totalObj["value"]
+	}
+	documents := make([]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		if hitObj, ok := hit.(map[string]interface{}); ok {
+			documents = append(documents, hitObj["_source"])
+		}
+	}
+
+	result := map[string]interface{}{
+		"took":      resp["took"],
+		"total":     total,
+		"max_score": hitsObj["max_score"],
+		"hits":      hits,
+		"documents": documents,
+		"count":     len(hits),
+	}
+	if aggregations, ok := resp["aggregations"]; ok {
+		result["aggregations"] = aggregations
+	}
+	return result, nil
+}
+
+// bulk 批量写入文档
+func (a *ESAction) bulk(ctx context.Context, client *datasource.ESClient, index string, params map[string]interface{}) (map[string]interface{}, error) {
+	documents, ok := params["documents"].([]interface{})
+	if !ok || len(documents) == 0 {
+		return nil, fmt.Errorf("documents parameter is required for bulk")
+	}
+	op, _ := params["op"].(string)
+	switch op {
+	case "":
+		op = "index"
+	case "index", "create", "update", "delete":
+	default:
+		return nil, fmt.Errorf("op must be index, create, update or delete")
+	}
+	idField, _ := params["id_field"].(string)
+	indexField, _ := params["index_field"].(string)
+	ignoreErrors, _ := params["ignore_errors"].(bool)
+
+	items := make([]datasource.ESBulkItem, 0, len(documents))
+	for i, value := range documents {
+		document, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("document %d is not an object", i)
+		}
+		item := datasource.ESBulkItem{Op: op, Document: document}
+		if idField != "" {
+			item.ID = esID(document[idField])
+		}
+		if indexField != "" {
+			item.Index, _ = document[indexField].(string)
+		}
+		if item.Index == "" && index == "" {
+			return nil, fmt.Errorf("index is required for document %d", i)
+		}
+		if item.ID == "" && (op == "update" || op == "delete") {
+			return nil, fmt.Errorf("id_field is required for %s and document %d has no id", op, i)
+		}
+		items = append(items, item)
+	}
+
+	resp, err := client.Bulk(ctx, index, items, esRefresh(params["refresh"]))
+	if err != nil {
+		return nil, err
+	}
+
+	// 批量接口整体返回200，逐条检查失败的文档
+	respItems, _ := resp["items"].([]interface{})
+	failures := []interface{}{}
+	failed := 0
+	for i, respItem := range respItems {
+		itemObj, _ := respItem.(map[string]interface{})
+		for _, detail := range itemObj {
+			detailObj, _ := detail.(map[string]interface{})
+			if detailObj["error"] == nil {
+				continue
+			}
+			failed++
+			if len(failures) < maxESFailures {
+				failures = append(failures, map[string]interface{}{
+					"position": i,
+					"id":       detailObj["_id"],
+					"status":   detailObj["status"],
+					"error":    detailObj["error"],
+				})
+			}
+		}
+	}
+	if failed > 0 && !ignoreErrors {
+		return nil, fmt.Errorf("%d of %d documents failed, first failure: %v", failed, len(items), failures[0])
+	}
+
+	return map[string]interface{}{
+		"took":      resp["took"],
+		"count":     len(items),
+		"succeeded": len(items) - failed,
+		"failed":    failed,
+		"failures":  failures,
+	}, nil
+}
+
+// deleteByQuery 删除匹配查询的文档，query为必填，删除全部文档需显式使用 match_all
+func (a *ESAction) deleteByQuery(ctx context.Context, client *datasource.ESClient, index string, params map[string]interface{}) (map[string]interface{}, error) {
+	query, ok := params["query"].(map[string]interface{})
+	if !ok || len(query) == 0 {
+		return nil, fmt.Errorf("query parameter is required for delete_by_query")
+	}
+	conflicts, _ := params["conflicts"].(string)
+	if conflicts != "" && conflicts != "abort" && conflicts != "proceed" {
+		return nil, fmt.Errorf("conflicts must be abort or proceed")
+	}
+	refresh, _ := params["refresh"].(bool)
+
+	resp, err := client.DeleteByQuery(ctx, index, query, conflicts, refresh)
+	if err != nil {
+		return nil, err
+	}
+	failures, _ := resp["failures"].([]interface{})
+	if failures == nil {
+		failures = []interface{}{}
+	}
+	return map[string]interface{}{
+		"took":              resp["took"],
+		"timed_out":         resp["timed_out"],
+		"total":             resp["total"],
+		"deleted":           resp["deleted"],
+		"version_conflicts": resp["version_conflicts"],
+		"failures":          failures,
+	}, nil
+}
+
+// esID 将文档ID参数转换为字符串，支持数字ID
+func esID(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return stringifyValue(value)
+}
+
+// esRefresh 将refresh参数转换为查询参数：true、false 或 wait_for
+func esRefresh(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case string:
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package workflow
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 执行事件类型
+const (
+	EventInstanceStarted   = "instance_started"
+	EventInstanceCompleted = "instance_completed"
+	EventInstanceFailed    = "instance_failed"
+	EventTaskStarted       = "task_started"
+	EventTaskFinished      = "task_finished"
+	EventTaskFailed        = "task_failed"
+)
+
+// eventBufferSize 每个订阅者的事件缓冲大小
+const eventBufferSize = 256
+
+// Event 工作流执行事件
+type Event struct {
+	Type       string      `json:"type"`
+	InstanceID string      `json:"instance_id"`
+	WorkflowID string      `json:"workflow_id"`
+	TaskID     string      `json:"task_id,omitempty"`
+	Status     string      `json:"status,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Duration   int64       `json:"duration,omitempty"` // 执行时间(毫秒)
+	Attempts   int         `json:"attempts,omitempty"`
+	Data       interface{} `json:"data,omitempty"` // 任务结束事件为执行日志
+	Timestamp  time.Time   `json:"timestamp"`
+}
+
+// EventBus 执行事件总线
+//
+// 发布不阻塞执行器：订阅者缓冲区满时丢弃事件并计数。
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+	dropped     atomic.Uint64
+}
+
+// NewEventBus 创建事件总线
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe 订阅事件，返回事件通道和取消订阅函数
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish 发布事件
+func (b *EventBus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Stats 返回订阅者数量和丢弃的事件数
+func (b *EventBus) Stats() (int, uint64) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers), b.dropped.Load()
+}
@@ -2,15 +2,22 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"nsa/internal/config"
 	"nsa/internal/datasource"
 	"nsa/internal/logger"
 	"nsa/internal/models"
 	"nsa/internal/mongodb"
+	"nsa/internal/secrets"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Task 任务定义
@@ -21,6 +28,7 @@ type Task struct {
 	Params     map[string]interface{} `json:"params"`
 	Timeout    time.Duration          `json:"timeout"`
 	Retry      *RetryConfig           `json:"retry"`
+	Condition  string                 `json:"condition,omitempty"`
 }
 
 // RetryConfig 重试配置
@@ -31,21 +39,59 @@ type RetryConfig struct {
 
 // WorkflowInstance 工作流实例
 type WorkflowInstance struct {
-	ID         string                 `json:"id"`
-	WorkflowID string                 `json:"workflow_id"`
-	Status     string                 `json:"status"`
-	StartTime  time.Time              `json:"start_time"`
-	EndTime    time.Time              `json:"end_time"`
-	Vars       map[string]interface{} `json:"vars"`
-	Results    map[string]interface{} `json:"results"`
+	ID         string                 `bson:"_id" json:"id"`
+	WorkflowID string                 `bson:"workflow_id" json:"workflow_id"`
+	Status     string                 `bson:"status" json:"status"`
+	StartTime  time.Time              `bson:"start_time" json:"start_time"`
+	EndTime    time.Time              `bson:"end_time" json:"end_time"`
+	Vars       map[string]interface{} `bson:"vars" json:"vars"`
+	Results    map[string]interface{} `bson:"results" json:"results"`
+	// ConcurrencyKey 渲染后的并发键，键相同的实例串行执行
+	ConcurrencyKey string `bson:"concurrency_key,omitempty" json:"concurrency_key,omitempty"`
+	// NextTask 检查点，下一个要执行的任务序号，延迟或服务重启后从该任务继续执行
+	NextTask int `bson:"next_task,omitempty" json:"next_task,omitempty"`
+	// ResumeAt 延迟的实例的恢复时间
+	ResumeAt time.Time `bson:"resume_at,omitempty" json:"resume_at,omitempty"`
+	// Node 执行实例的集群节点，节点心跳超时后由其他节点接管
+	Node string `bson:"node,omitempty" json:"node,omitempty"`
+	// Message 触发消息，用于延迟或服务重启后恢复执行
+	Message *models.NSQMessage `bson:"message,omitempty" json:"-"`
+	// Project 工作流所属项目，用于解析数据源别名
+	Project string `bson:"project,omitempty" json:"project,omitempty"`
+	// Unmask 工作流被授权查看脱敏列原值的数据源
+	Unmask []string `bson:"unmask,omitempty" json:"unmask,omitempty"`
+	// Resolution 取消（cancelled）或人工处理（resolved）的说明，由批量操作写入
+	Resolution string `bson:"resolution,omitempty" json:"resolution,omitempty"`
+	// Version 实例文档的版本，每次写入加1，保存时比较版本，见 saveWorkflowInstance
+	Version int64 `bson:"version" json:"version"`
 }
 
 // Executor 工作流执行器
 type Executor struct {
-	logger        logger.Logger
-	dataSourceMgr *datasource.Manager
-	mongoDB       *mongodb.Client
-	actions       map[string]Action
+	logger         logger.Logger
+	dataSourceMgr  *datasource.Manager
+	secrets        *secrets.Store
+	mongoDB        *mongodb.Client
+	cfg            *config.Config
+	actions        map[string]Action
+	connectors     *ConnectorRegistry
+	events         *EventBus
+	serial         *keyedQueue
+	delays         *timerWheel
+	configs        *workflowConfigCache
+	faults         *faultInjector   // 未启用故障注入时为nil
+	breakers       *circuitBreakers // 未启用熔断时为nil
+	gates          *actionGates
+	httpTransports *httpTransportPool
+	exporter       *instanceExporter // 未启用实例摘要导出时为nil
+	jsPool         *jsPool
+	scripts        *scriptStore
+	running        sync.Map     // 当前节点上执行中实例的 context.CancelCauseFunc，键为实例ID，用于取消实例
+	historySeqs    sync.Map     // 当前节点追加的实例历史事件的最后序号，键为实例ID
+	windowSkipped  atomic.Int64 // 因不在执行窗口内而跳过的执行次数
+	budgetSkipped  atomic.Int64 // 因超过执行预算而跳过的执行次数
+	// onBudgetExceeded 工作流超过执行预算被自动禁用后的处理函数
+	onBudgetExceeded BudgetHandler
 }
 
 // Action 动作接口
@@ -55,16 +101,44 @@ type Action interface {
 }
 
 // NewExecutor 创建新的工作流执行器
-func NewExecutor(logger logger.Logger, mongoClient *mongodb.Client, dataSourceMgr *datasource.Manager) *Executor {
+func NewExecutor(logger logger.Logger, mongoClient *mongodb.Client, dataSourceMgr *datasource.Manager, secretStore *secrets.Store, cfg *config.Config) *Executor {
 	executor := &Executor{
-		logger:        logger,
-		mongoDB:       mongoClient,
-		dataSourceMgr: dataSourceMgr,
-		actions:       make(map[string]Action),
+		logger:         logger,
+		mongoDB:        mongoClient,
+		dataSourceMgr:  dataSourceMgr,
+		secrets:        secretStore,
+		cfg:            cfg,
+		actions:        make(map[string]Action),
+		connectors:     NewConnectorRegistry(),
+		events:         NewEventBus(),
+		serial:         newKeyedQueue(),
+		delays:         newTimerWheel(delayWheelTick, delayWheelSlots),
+		configs:        newWorkflowConfigCache(workflowConfigTTL),
+		httpTransports: newHTTPTransportPool(cfg.HTTPClient),
+		jsPool:         newJSPool(cfg.JS, logger),
+		scripts:        newScriptStore(mongoClient, scriptTTL),
+		gates:          newActionGates(cfg.Actions),
+	}
+
+	if cfg.CircuitBreaker.Enabled {
+		executor.breakers = newCircuitBreakers(cfg.CircuitBreaker)
+	}
+	if cfg.InstanceExport.Enabled {
+		exporter, err := newInstanceExporter(cfg, logger)
+		if err != nil {
+			logger.Errorf("Failed to start instance export: %v", err)
+		} else {
+			executor.exporter = exporter
+		}
+	}
+	if cfg.Chaos.Enabled {
+		executor.faults = newFaultInjector()
+		logger.Warnf("Fault injection is enabled, do not use in production")
 	}
 
 	// 注册默认动作
 	executor.registerDefaultActions()
+	executor.warnUnknownActions()
 
 	return executor
 }
@@ -74,13 +148,61 @@ func (e *Executor) registerDefaultActions() {
 	actionCtx := &ActionContext{
 		Logger:         e.logger,
 		DataSourceMgr:  e.dataSourceMgr,
-		WorkflowVars:   make(map[string]interface{}),
-		PreviousOutput: make(map[string]interface{}),
+		Secrets:        e.secrets,
+		HTTPTransports: e.httpTransports,
+		JSPool:         e.jsPool,
+		Scripts:        e.scripts,
 	}
 
 	e.RegisterAction(NewHTTPClientAction(actionCtx))
 	e.RegisterAction(NewDBClientAction(actionCtx))
 	e.RegisterAction(NewJSFunctionAction(actionCtx))
+	e.RegisterAction(NewArchiveAction(actionCtx, e.cfg.Files))
+	e.RegisterAction(NewLLMAction(actionCtx))
+	e.RegisterAction(NewClassifyAction(actionCtx))
+	e.RegisterAction(NewK8sAction(actionCtx))
+	e.RegisterAction(NewDockerAction(actionCtx))
+	e.RegisterAction(NewJiraAction(actionCtx))
+	e.RegisterAction(NewServiceNowAction(actionCtx))
+	e.RegisterAction(NewGitHubAction(actionCtx))
+	e.RegisterAction(NewGitLabAction(actionCtx))
+	e.RegisterAction(NewPagerDutyAction(actionCtx))
+	e.RegisterAction(NewOpsgenieAction(actionCtx))
+	e.RegisterAction(NewCalendarAction(actionCtx))
+	e.RegisterAction(NewSMSAction(actionCtx))
+	e.RegisterAction(NewGRPCClientAction(actionCtx))
+	e.RegisterAction(NewObjectStorageAction(actionCtx))
+	e.RegisterAction(NewWarehouseAction(actionCtx))
+	e.RegisterAction(NewFileAction(actionCtx, e.cfg.Files))
+	e.RegisterAction(NewGrafanaAction(actionCtx))
+	e.RegisterAction(NewCommandAction(actionCtx, e.cfg.Command))
+	e.RegisterAction(NewKVAction(actionCtx))
+	e.RegisterAction(NewESAction(actionCtx))
+	e.RegisterAction(NewSSHAction(actionCtx))
+	e.RegisterAction(NewTransformAction(actionCtx))
+	e.RegisterAction(NewExpressionAction(actionCtx))
+	e.RegisterAction(NewForEachAction(actionCtx, e))
+	e.RegisterAction(NewWaitForApprovalAction(actionCtx, e))
+	e.RegisterAction(NewDelayAction(actionCtx))
+	e.RegisterAction(NewConnectorAction(actionCtx, e.connectors))
+
+	e.RegisterConnector(&StripeConnector{})
+	e.RegisterConnector(&SAPODataConnector{})
+}
+
+// RegisterConnector 注册连接器，供 ConnectorAction 调用
+func (e *Executor) RegisterConnector(connector Connector) {
+	e.connectors.Register(connector)
+}
+
+// Connectors 返回连接器注册表
+func (e *Executor) Connectors() *ConnectorRegistry {
+	return e.connectors
+}
+
+// Events 返回执行事件总线
+func (e *Executor) Events() *EventBus {
+	return e.events
 }
 
 // RegisterAction 注册动作
@@ -90,8 +212,42 @@ func (e *Executor) RegisterAction(action Action) {
 
 // Execute 执行工作流
 func (e *Executor) Execute(ctx context.Context, workflowConfig *models.WorkflowConfig, nsqMessage *models.NSQMessage) error {
+	return e.ExecuteAsync(ctx, workflowConfig, nsqMessage, nil)
+}
+
+// ExecuteAsync 执行工作流，执行goroutine返回时调用 done
+//
+// 实例结束、延迟（等待恢复时间）或进入并发键队列时执行goroutine返回；返回错误时实例未创建，不调用 done。
+// 用于限制同时执行的实例数。
+//
+// 工作流配置了执行窗口时，窗口外的执行按策略创建延迟到下一个窗口开始时的实例，或者跳过（不创建实例）。
+// 消息数据包含 payload_ref 时先读取外部数据，读取失败返回错误。
+func (e *Executor) ExecuteAsync(ctx context.Context, workflowConfig *models.WorkflowConfig, nsqMessage *models.NSQMessage, done func()) error {
+	return e.ExecuteWithOutcome(ctx, workflowConfig, nsqMessage, func(Outcome) {
+		if done != nil {
+			done()
+		}
+	})
+}
+
+// ExecuteWithOutcome 与 ExecuteAsync 相同，执行goroutine返回时以实例的结果调用 done
+//
+// 只有在执行goroutine中结束的实例的失败会报告；延迟、进入并发键队列或被跳过的实例报告为没有错误。
+func (e *Executor) ExecuteWithOutcome(ctx context.Context, workflowConfig *models.WorkflowConfig, nsqMessage *models.NSQMessage, done func(Outcome)) error {
 	e.logger.Infof("Starting workflow execution: %s", workflowConfig.ID)
 
+	resumeAt, skip := e.deferExecution(workflowConfig, time.Now())
+	if skip {
+		e.windowSkipped.Add(1)
+		e.logger.Infof("Skipped execution of workflow %s outside its execution window", workflowConfig.Name)
+		done(Outcome{})
+		return nil
+	}
+
+	if err := e.resolvePayload(ctx, nsqMessage); err != nil {
+		return err
+	}
+
 	// 生成实例ID
 	instanceID := primitive.NewObjectID().Hex()
 
@@ -103,6 +259,20 @@ func (e *Executor) Execute(ctx context.Context, workflowConfig *models.WorkflowC
 		StartTime:  time.Now(),
 		Vars:       e.buildWorkflowVars(workflowConfig, nsqMessage),
 		Results:    make(map[string]interface{}),
+		Message:    nsqMessage,
+		Node:       e.cfg.Cluster.NodeID,
+		Project:    workflowConfig.Project,
+		Unmask:     workflowConfig.Unmask,
+	}
+	if e.overBudget(workflowConfig, instance) {
+		e.logger.Infof("Skipped execution of workflow %s over its daily execution budget", workflowConfig.Name)
+		done(Outcome{})
+		return nil
+	}
+	instance.ConcurrencyKey = e.concurrencyKey(workflowConfig, instance, nsqMessage)
+	if !resumeAt.IsZero() {
+		instance.Status = "delayed"
+		instance.ResumeAt = resumeAt
 	}
 
 	// 保存实例
@@ -110,12 +280,30 @@ func (e *Executor) Execute(ctx context.Context, workflowConfig *models.WorkflowC
 		e.logger.Errorf("Failed to save workflow instance: %v", err)
 		return err
 	}
+	e.recordHistory(instance, HistoryEvent{Type: HistoryCreated, ResumeAt: instance.ResumeAt})
+
+	e.events.Publish(Event{
+		Type:       EventInstanceStarted,
+		InstanceID: instance.ID,
+		WorkflowID: instance.WorkflowID,
+		Status:     instance.Status,
+	})
 
 	// 构建任务列表
 	tasks := e.buildTasks(workflowConfig)
 
 	// 执行任务
-	go e.executeTasks(ctx, instance, tasks, nsqMessage)
+	if instance.ConcurrencyKey == "" {
+		go func() {
+			err := e.executeTasks(ctx, instance, tasks, nsqMessage, nil)
+			done(outcome(workflowConfig, instance.ID, err))
+		}()
+		return nil
+	}
+
+	// 同一工作流中并发键相同的实例按到达顺序串行执行
+	e.enqueue(ctx, instance, tasks, nsqMessage)
+	done(Outcome{InstanceID: instance.ID})
 
 	return nil
 }
@@ -124,104 +312,359 @@ func (e *Executor) Execute(ctx context.Context, workflowConfig *models.WorkflowC
 func (e *Executor) buildTasks(workflowConfig *models.WorkflowConfig) []Task {
 	var tasks []Task
 	for _, taskConfig := range workflowConfig.DAG.Tasks {
-		task := Task{
-			ID:         taskConfig.ID,
-			ActionName: taskConfig.ActionName,
-			DependOn:   taskConfig.DependOn,
-			Params:     taskConfig.Params,
-		}
+		tasks = append(tasks, buildTask(taskConfig))
+	}
 
-		// 添加重试配置
-		if taskConfig.Retry.Enabled {
-			task.Retry = &RetryConfig{
-				MaxTimes: taskConfig.Retry.MaxTimes,
-				Interval: time.Duration(taskConfig.Retry.Interval) * time.Second,
-			}
-		}
+	return tasks
+}
+
+// buildTask 根据任务配置构建任务
+func buildTask(taskConfig models.TaskConfig) Task {
+	task := Task{
+		ID:         taskConfig.ID,
+		ActionName: taskConfig.ActionName,
+		DependOn:   taskConfig.DependOn,
+		Params:     taskConfig.Params,
+		Condition:  taskConfig.Condition,
+	}
 
-		// 添加超时配置
-		if taskConfig.Timeout > 0 {
-			task.Timeout = time.Duration(taskConfig.Timeout) * time.Second
+	// 添加重试配置
+	if taskConfig.Retry.Enabled {
+		task.Retry = &RetryConfig{
+			MaxTimes: taskConfig.Retry.MaxTimes,
+			Interval: time.Duration(taskConfig.Retry.Interval) * time.Second,
 		}
+	}
 
-		tasks = append(tasks, task)
+	// 添加超时配置
+	if taskConfig.Timeout > 0 {
+		task.Timeout = time.Duration(taskConfig.Timeout) * time.Second
 	}
 
-	return tasks
+	return task
 }
 
-// executeTasks 执行任务列表
-func (e *Executor) executeTasks(ctx context.Context, instance *WorkflowInstance, tasks []Task, nsqMessage *models.NSQMessage) {
+// executeTasks 从实例的 NextTask 开始执行任务列表，实例结束（完成或失败）时调用 onEnd
+//
+// 任务要求延迟时保存实例并返回，到达恢复时间后继续执行后续任务，结束时再调用 onEnd。
+// 返回实例失败的错误；实例完成、取消或延迟时返回nil。
+func (e *Executor) executeTasks(ctx context.Context, instance *WorkflowInstance, tasks []Task, nsqMessage *models.NSQMessage, onEnd func()) (failure error) {
+	if instance.Status == "delayed" {
+		e.delay(ctx, instance, tasks, nsqMessage, onEnd)
+		return nil
+	}
+
+	// 取消实例时中断正在执行的任务；延迟后恢复执行使用原来的上下文
+	runCtx, cancel := context.WithCancelCause(ctx)
+	e.running.Store(instance.ID, cancel)
+	ended := true
 	defer func() {
+		e.running.Delete(instance.ID)
+		cancel(nil)
 		if r := recover(); r != nil {
 			e.logger.Errorf("Workflow execution panic: %v", r)
+			failure = fmt.Errorf("panic: %v", r)
 			instance.Status = "failed"
 			instance.EndTime = time.Now()
-			e.saveWorkflowInstance(instance)
+			if err := e.saveWorkflowInstance(instance); !e.instanceConflict(instance, err) {
+				e.publishInstanceEnd(instance, failure)
+			}
+		}
+		if ended && onEnd != nil {
+			onEnd()
 		}
 	}()
 
 	// 简单的顺序执行（可以后续扩展为支持依赖关系的并行执行）
-	for _, task := range tasks {
-		if err := e.executeTask(ctx, &task, instance, nsqMessage); err != nil {
+	for i := instance.NextTask; i < len(tasks); i++ {
+		task := tasks[i]
+		err := e.executeTask(runCtx, &task, instance, nsqMessage)
+		// 已被取消的实例不再保存，状态和结束事件由取消操作写入
+		if e.instanceCancelled(runCtx, instance.ID) {
+			e.logger.Infof("Workflow instance %s cancelled at task %s", instance.ID, task.ID)
+			e.historySeqs.Delete(instance.ID)
+			return nil
+		}
+		var suspended *suspendError
+		if errors.As(err, &suspended) {
+			instance.Status = "delayed"
+			instance.NextTask = i + 1
+			instance.ResumeAt = suspended.resumeAt
+			if err := e.saveWorkflowInstance(instance); err != nil {
+				if e.instanceConflict(instance, err) {
+					return nil
+				}
+				e.logger.Errorf("Failed to save delayed workflow instance %s: %v", instance.ID, err)
+			}
+			e.recordHistory(instance, HistoryEvent{Type: HistoryDelayed, TaskID: task.ID, ResumeAt: instance.ResumeAt})
+			ended = false
+			e.delay(ctx, instance, tasks, nsqMessage, onEnd)
+			return nil
+		}
+		if err != nil {
 			e.logger.Errorf("Task %s failed: %v", task.ID, err)
 			instance.Status = "failed"
 			instance.EndTime = time.Now()
-			e.saveWorkflowInstance(instance)
-			return
+			if saveErr := e.saveWorkflowInstance(instance); e.instanceConflict(instance, saveErr) {
+				return nil
+			}
+			e.publishInstanceEnd(instance, err)
+			return err
+		}
+
+		// 保存检查点，服务重启后从下一个任务继续执行
+		instance.NextTask = i + 1
+		if i+1 < len(tasks) {
+			if err := e.saveWorkflowInstance(instance); err != nil {
+				if e.instanceConflict(instance, err) {
+					return nil
+				}
+				e.logger.Errorf("Failed to save checkpoint of workflow instance %s: %v", instance.ID, err)
+			}
 		}
 	}
 
 	// 所有任务执行成功
 	instance.Status = "completed"
 	instance.EndTime = time.Now()
-	e.saveWorkflowInstance(instance)
+	if err := e.saveWorkflowInstance(instance); e.instanceConflict(instance, err) {
+		return nil
+	}
+	e.publishInstanceEnd(instance, nil)
 	e.logger.Infof("Workflow %s completed successfully", instance.ID)
+	return nil
+}
+
+// resolveDataSourceAlias datasource 参数为别名时返回替换为实例所属项目映射的数据源的参数副本
+//
+// 使用模板的 datasource 参数在动作中渲染后按默认映射解析。
+func (e *Executor) resolveDataSourceAlias(params map[string]interface{}, project string) map[string]interface{} {
+	name, ok := params["datasource"].(string)
+	if !ok || name == "" || e.dataSourceMgr == nil {
+		return params
+	}
+	resolved := e.dataSourceMgr.Resolve(name, project)
+	if resolved == name {
+		return params
+	}
+
+	copied := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		copied[key] = value
+	}
+	copied["datasource"] = resolved
+	return copied
 }
 
 // executeTask 执行单个任务
 func (e *Executor) executeTask(ctx context.Context, task *Task, instance *WorkflowInstance, nsqMessage *models.NSQMessage) error {
+	output, err := e.runTask(ctx, task, instance, nsqMessage, instance.Vars, instance.Results)
+	if err != nil && !isSuspended(err) {
+		return fmt.Errorf("task %s execution failed: %w", task.ID, err)
+	}
+
+	// 保存任务结果
+	instance.Results[task.ID] = output
+	if err != nil {
+		return err
+	}
+	e.logger.Infof("Task %s completed successfully", task.ID)
+
+	return nil
+}
+
+// runTask 以指定的变量和前置输出执行任务，记录执行日志和事件，返回任务输出
+//
+// 工作流任务使用实例的变量和结果；循环中的子任务使用每次迭代独立的副本。
+func (e *Executor) runTask(ctx context.Context, task *Task, instance *WorkflowInstance, nsqMessage *models.NSQMessage, vars, results map[string]interface{}) (interface{}, error) {
 	e.logger.Infof("Executing task: %s", task.ID)
 
 	// 获取动作
 	action, exists := e.actions[task.ActionName]
 	if !exists {
-		return fmt.Errorf("action %s not found", task.ActionName)
+		return nil, &permanentError{fmt.Errorf("action %s not found", task.ActionName)}
+	}
+	// 保存后修改了动作开关配置时，已保存的工作流在执行时被拒绝
+	if err := e.ActionAllowed(instance.Project, task.ActionName); err != nil {
+		return nil, &permanentError{err}
 	}
 
 	// 创建任务上下文
 	taskCtx := &TaskContext{
-		params: task.Params,
+		taskID:   task.ID,
+		params:   e.resolveDataSourceAlias(task.Params, instance.Project),
+		message:  nsqMessage,
+		vars:     vars,
+		results:  results,
+		instance: instance,
 	}
 
+	// 条件为 false 时跳过任务，条件计算出错时任务失败
+	if task.Condition != "" {
+		start := time.Now()
+		run, err := evalCondition(task.Condition, taskCtx)
+		if err != nil {
+			err = &permanentError{fmt.Errorf("condition %q: %v", task.Condition, err)}
+		}
+		if err != nil || !run {
+			e.finishSkippedTask(instance, task, taskCtx, start, err)
+			return nil, err
+		}
+	}
+
+	e.events.Publish(Event{
+		Type:       EventTaskStarted,
+		InstanceID: instance.ID,
+		WorkflowID: instance.WorkflowID,
+		TaskID:     task.ID,
+		Status:     "running",
+	})
+	e.recordHistory(instance, HistoryEvent{Type: HistoryTaskStarted, TaskID: task.ID})
+
 	// 执行任务
+	start := time.Now()
+	attempts := 0
 	var err error
 	if task.Retry != nil {
 		// 带重试的执行
 		for i := 0; i <= task.Retry.MaxTimes; i++ {
-			err = action.Run(ctx, taskCtx)
-			if err == nil {
+			attempts++
+			err = e.runAction(ctx, action, task, taskCtx, instance)
+			if err == nil || isSuspended(err) {
 				break
 			}
 			if i < task.Retry.MaxTimes {
 				e.logger.Warnf("Task %s failed, retrying in %v: %v", task.ID, task.Retry.Interval, err)
+				e.recordHistory(instance, HistoryEvent{Type: HistoryTaskRetried, TaskID: task.ID, Attempt: attempts, Error: err.Error()})
 				time.Sleep(task.Retry.Interval)
 			}
 		}
 	} else {
 		// 普通执行
-		err = action.Run(ctx, taskCtx)
+		attempts++
+		err = e.runAction(ctx, action, task, taskCtx, instance)
 	}
 
+	// 记录执行日志，延迟不是失败
+	logErr := err
+	if isSuspended(err) {
+		logErr = nil
+	}
+	log := e.buildExecutionLog(instance, task, taskCtx, start, attempts, logErr)
+	e.saveExecutionLog(log)
+	e.recordHistory(instance, HistoryEvent{Type: HistoryTaskFinished, TaskID: task.ID, TaskStatus: log.Status, Attempt: attempts, Error: log.Error})
+
+	event := Event{
+		Type:       EventTaskFinished,
+		InstanceID: instance.ID,
+		WorkflowID: instance.WorkflowID,
+		TaskID:     task.ID,
+		Status:     log.Status,
+		Error:      log.Error,
+		Duration:   log.Duration,
+		Attempts:   attempts,
+		Data:       log,
+	}
+	if logErr != nil {
+		event.Type = EventTaskFailed
+	}
+	e.events.Publish(event)
+
+	return taskCtx.GetOutput(), err
+}
+
+// finishSkippedTask 记录因条件不满足而跳过、或条件计算失败的任务的执行日志和事件
+func (e *Executor) finishSkippedTask(instance *WorkflowInstance, task *Task, taskCtx *TaskContext, start time.Time, err error) {
+	log := e.buildExecutionLog(instance, task, taskCtx, start, 0, err)
+	if err == nil {
+		log.Status = "skipped"
+		log.Message = fmt.Sprintf("Task %s skipped: condition is false", task.ID)
+		e.logger.Infof("Task %s skipped: condition %q is false", task.ID, task.Condition)
+	}
+	e.saveExecutionLog(log)
+	e.recordHistory(instance, HistoryEvent{Type: HistoryTaskFinished, TaskID: task.ID, TaskStatus: log.Status, Error: log.Error})
+
+	event := Event{
+		Type:       EventTaskFinished,
+		InstanceID: instance.ID,
+		WorkflowID: instance.WorkflowID,
+		TaskID:     task.ID,
+		Status:     log.Status,
+		Error:      log.Error,
+		Duration:   log.Duration,
+		Data:       log,
+	}
 	if err != nil {
-		return fmt.Errorf("task %s execution failed: %v", task.ID, err)
+		event.Type = EventTaskFailed
 	}
+	e.events.Publish(event)
+}
 
-	// 保存任务结果
-	instance.Results[task.ID] = taskCtx.GetOutput()
-	e.logger.Infof("Task %s completed successfully", task.ID)
+// runAction 执行动作：经过目标的熔断器，启用故障注入时先注入延迟或错误
+func (e *Executor) runAction(ctx context.Context, action Action, task *Task, taskCtx *TaskContext, instance *WorkflowInstance) error {
+	return e.runWithBreaker(ctx, taskCtx, func() error {
+		if err := e.injectFault(ctx, task, instance); err != nil {
+			return err
+		}
+		return action.Run(ctx, taskCtx)
+	})
+}
 
-	return nil
+// publishInstanceEnd 记录实例结束的历史事件并发布实例结束事件，启用实例摘要导出时同时导出摘要
+func (e *Executor) publishInstanceEnd(instance *WorkflowInstance, err error) {
+	history := HistoryEvent{Type: instance.Status, Resolution: instance.Resolution}
+	if err != nil {
+		history.Error = err.Error()
+	}
+	e.recordHistory(instance, history)
+
+	event := Event{
+		Type:       EventInstanceCompleted,
+		InstanceID: instance.ID,
+		WorkflowID: instance.WorkflowID,
+		Status:     instance.Status,
+		Duration:   instance.EndTime.Sub(instance.StartTime).Milliseconds(),
+	}
+	if err != nil {
+		event.Type = EventInstanceFailed
+		event.Error = err.Error()
+	}
+	e.events.Publish(event)
+	if e.exporter != nil {
+		e.exporter.export(instance, err)
+	}
+}
+
+// buildExecutionLog 构建任务执行日志
+func (e *Executor) buildExecutionLog(instance *WorkflowInstance, task *Task, taskCtx *TaskContext, start time.Time, attempts int, err error) *models.ExecutionLog {
+	end := time.Now()
+	workflowID, _ := primitive.ObjectIDFromHex(instance.WorkflowID)
+
+	log := &models.ExecutionLog{
+		WorkflowID: workflowID,
+		InstanceID: instance.ID,
+		TaskID:     task.ID,
+		Status:     "success",
+		Message:    fmt.Sprintf("Task %s completed", task.ID),
+		Input:      task.Params,
+		Output:     taskCtx.GetOutput(),
+		StartTime:  start,
+		EndTime:    end,
+		Duration:   end.Sub(start).Milliseconds(),
+		Attempts:   attempts,
+		Metadata:   taskCtx.GetMetadata(),
+		CreatedAt:  end,
+		// 控制台输出只有JS节点设置
+		Console:          taskCtx.console,
+		ConsoleTruncated: taskCtx.consoleTruncated,
+	}
+	if err != nil {
+		log.Status = "failed"
+		log.Message = fmt.Sprintf("Task %s failed", task.ID)
+		log.Error = err.Error()
+	}
+
+	return log
 }
 
 // buildWorkflowVars 构建工作流变量
@@ -241,39 +684,136 @@ func (e *Executor) buildWorkflowVars(workflowConfig *models.WorkflowConfig, nsqM
 	return vars
 }
 
+// RestoreMessageVar 将从数据库读取的变量 nsq_message 替换为触发消息
+//
+// 变量中的触发消息按存储格式读取，启用存储加密时为密文；Message 字段读取时已解密。
+func (i *WorkflowInstance) RestoreMessageVar() {
+	if _, ok := i.Vars["nsq_message"]; ok && i.Message != nil {
+		i.Vars["nsq_message"] = i.Message
+	}
+}
+
+// instanceSealedFields 实例中加密存储的字段：变量和任务结果
+var instanceSealedFields = []string{"vars", "results"}
+
+// plainInstance 不加密的 WorkflowInstance，避免 MarshalBSON 递归
+type plainInstance WorkflowInstance
+
+// MarshalBSON 实现 bson.Marshaler，启用存储加密时加密变量和任务结果
+func (i *WorkflowInstance) MarshalBSON() ([]byte, error) {
+	doc, err := bson.Marshal((*plainInstance)(i))
+	if err != nil {
+		return nil, err
+	}
+	return models.SealFields(doc, instanceSealedFields...)
+}
+
+// UnmarshalBSON 实现 bson.Unmarshaler，解密加密存储的字段
+func (i *WorkflowInstance) UnmarshalBSON(data []byte) error {
+	doc, err := models.OpenFields(data)
+	if err != nil {
+		return err
+	}
+	*i = WorkflowInstance{}
+	return bson.Unmarshal(doc, (*plainInstance)(i))
+}
+
+// errInstanceConflict 实例在读取后已被其他节点或批量操作修改，保存被拒绝
+var errInstanceConflict = errors.New("workflow instance was modified concurrently")
+
 // saveWorkflowInstance 保存工作流实例
+//
+// 实例文档是实例状态的来源，保存时比较版本后整体替换：存储的版本与内存中的相同时才写入并将版本加1，
+// 实例已被取消、重试或由其他节点接管时返回 errInstanceConflict，不会覆盖其他写入。
+// 版本为0的新实例不存在时插入，升级前写入的没有版本字段的实例按版本0处理。
 func (e *Executor) saveWorkflowInstance(instance *WorkflowInstance) error {
 	collection := e.mongoDB.GetDatabase().Collection("workflow_instances")
 
-	// 尝试更新，如果不存在则插入
-	filter := bson.M{"id": instance.ID}
-
-	_, err := collection.ReplaceOne(context.Background(), filter, instance)
+	filter := bson.M{"_id": instance.ID, "version": instance.Version}
+	if instance.Version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	opts := options.Replace().SetUpsert(instance.Version == 0)
+	instance.Version++
+
+	failed := false
+	err := e.mongoDB.Retry(context.Background(), 5*time.Second, func(ctx context.Context) error {
+		result, err := collection.ReplaceOne(ctx, filter, instance, opts)
+		if mongo.IsDuplicateKeyError(err) {
+			return errInstanceConflict
+		}
+		if err != nil {
+			failed = true
+			return err
+		}
+		if result.MatchedCount == 0 && result.UpsertedCount == 0 {
+			// 之前失败的尝试可能已经写入
+			if failed && e.instanceSaved(ctx, instance) {
+				return nil
+			}
+			return errInstanceConflict
+		}
+		return nil
+	})
 	if err != nil {
-		// 如果更新失败，尝试插入
-		_, err = collection.InsertOne(context.Background(), instance)
+		instance.Version--
 	}
-
 	return err
 }
 
+// instanceSaved 判断存储的实例是否为本次写入的版本：版本、状态、检查点和节点都相同
+func (e *Executor) instanceSaved(ctx context.Context, instance *WorkflowInstance) bool {
+	var stored struct {
+		Version  int64  `bson:"version"`
+		Status   string `bson:"status"`
+		NextTask int    `bson:"next_task"`
+		Node     string `bson:"node"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"version": 1, "status": 1, "next_task": 1, "node": 1})
+	err := e.mongoDB.GetDatabase().Collection("workflow_instances").FindOne(ctx, bson.M{"_id": instance.ID}, opts).Decode(&stored)
+	return err == nil && stored.Version == instance.Version && stored.Status == instance.Status &&
+		stored.NextTask == instance.NextTask && stored.Node == instance.Node
+}
+
+// instanceConflict 保存实例被拒绝时记录日志并返回true，实例已被取消或由其他节点接管，当前节点停止执行
+func (e *Executor) instanceConflict(instance *WorkflowInstance, err error) bool {
+	if !errors.Is(err, errInstanceConflict) {
+		return false
+	}
+	e.logger.Warnf("Workflow instance %s was modified by another node or operation, stopped on this node", instance.ID)
+	e.historySeqs.Delete(instance.ID)
+	return true
+}
+
 // saveExecutionLog 保存执行日志
 func (e *Executor) saveExecutionLog(log *models.ExecutionLog) {
 	collection := e.mongoDB.GetDatabase().Collection("execution_logs")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := collection.InsertOne(ctx, log)
+	result, err := collection.InsertOne(ctx, log)
 	if err != nil {
 		e.logger.Errorf("Failed to save execution log: %v", err)
+		return
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		log.ID = id
 	}
 }
 
 // GetWorkflowConfig 获取工作流配置
+//
+// 查询结果（包括没有匹配的工作流）缓存30秒，修改工作流后通过 InvalidateWorkflowConfigs 清空缓存。
 func (e *Executor) GetWorkflowConfig(topic, channel string) (*models.WorkflowConfig, error) {
+	key := topic + "/" + channel
+	if cached, ok := e.configs.get(key); ok {
+		if cached == nil {
+			return nil, mongo.ErrNoDocuments
+		}
+		return cached, nil
+	}
+
 	collection := e.mongoDB.GetCollection()
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
 
 	filter := bson.M{
 		"topic":   topic,
@@ -282,16 +822,33 @@ func (e *Executor) GetWorkflowConfig(topic, channel string) (*models.WorkflowCon
 	}
 
 	var config models.WorkflowConfig
-	err := collection.FindOne(ctx, filter).Decode(&config)
+	err := e.mongoDB.Retry(context.Background(), 5*time.Second, func(ctx context.Context) error {
+		return collection.FindOne(ctx, filter).Decode(&config)
+	})
+	if err == mongo.ErrNoDocuments {
+		e.configs.set(key, nil)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	e.configs.set(key, &config)
 	return &config, nil
 }
 
+// InvalidateWorkflowConfigs 清空工作流配置缓存，工作流创建、修改、删除、启停后调用
+func (e *Executor) InvalidateWorkflowConfigs() {
+	e.configs.clear()
+}
+
 // Stop 停止执行器
 func (e *Executor) Stop() {
 	e.logger.Info("Stopping workflow executor...")
-	// 这里可以添加清理逻辑
+	// 延迟的实例已持久化，重启后恢复
+	e.delays.stop()
+	e.httpTransports.close()
+	e.jsPool.stop()
+	if e.exporter != nil {
+		e.exporter.stop()
+	}
 }
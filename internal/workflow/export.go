@@ -0,0 +1,214 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/logger"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// exportStopTimeout 停止时等待缓冲中的摘要发送完成的最长时间
+const exportStopTimeout = 10 * time.Second
+
+// InstanceSummary 结束的实例摘要，导出到数据仓库
+type InstanceSummary struct {
+	InstanceID string                 `json:"instance_id"`
+	WorkflowID string                 `json:"workflow_id"`
+	Project    string                 `json:"project,omitempty"`
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	StartTime  time.Time              `json:"start_time"`
+	EndTime    time.Time              `json:"end_time"`
+	Duration   int64                  `json:"duration"` // 执行时间(毫秒)
+	Node       string                 `json:"node,omitempty"`
+	Topic      string                 `json:"topic,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// instanceExporter 将结束的实例摘要异步发送到NSQ topic和HTTP端点
+//
+// 与执行日志存储相互独立：摘要先放入缓冲，由后台goroutine发送，失败时按次数重试，
+// 缓冲满时丢弃并计数，不阻塞执行器。
+type instanceExporter struct {
+	cfg      config.InstanceExportConfig
+	logger   logger.Logger
+	producer *nsq.Producer // 未配置 nsq_topic 时为nil
+	client   *http.Client
+	queue    chan *InstanceSummary
+	dropped  atomic.Uint64
+	mu       sync.RWMutex // 保护 stopped，停止后不再写入 queue
+	stopped  bool
+	done     chan struct{}
+}
+
+// newInstanceExporter 创建实例摘要导出器并启动发送goroutine
+func newInstanceExporter(cfg *config.Config, logger logger.Logger) (*instanceExporter, error) {
+	exportCfg := cfg.InstanceExport
+	exporter := &instanceExporter{
+		cfg:    exportCfg,
+		logger: logger,
+		client: &http.Client{Timeout: time.Duration(exportCfg.Timeout) * time.Second},
+		queue:  make(chan *InstanceSummary, exportCfg.BufferSize),
+		done:   make(chan struct{}),
+	}
+	if exportCfg.NSQTopic != "" {
+		address := exportCfg.NSQDAddress
+		if address == "" {
+			address = cfg.NSQ.NSQDAddresses[0]
+		}
+		nsqConfig, err := cfg.NSQ.ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("invalid NSQ config: %v", err)
+		}
+		producer, err := nsq.NewProducer(address, nsqConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create NSQ producer: %v", err)
+		}
+		producer.SetLogger(nil, nsq.LogLevelError)
+		exporter.producer = producer
+	}
+
+	go exporter.run()
+	return exporter, nil
+}
+
+// summarize 构建实例摘要，关键字段按模板路径从实例的消息、变量和任务输出中读取，不存在的字段不输出
+func (x *instanceExporter) summarize(instance *WorkflowInstance, err error) *InstanceSummary {
+	summary := &InstanceSummary{
+		InstanceID: instance.ID,
+		WorkflowID: instance.WorkflowID,
+		Project:    instance.Project,
+		Status:     instance.Status,
+		StartTime:  instance.StartTime,
+		EndTime:    instance.EndTime,
+		Duration:   instance.EndTime.Sub(instance.StartTime).Milliseconds(),
+		Node:       instance.Node,
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	if instance.Message != nil {
+		summary.Topic = instance.Message.Topic
+	}
+	if len(x.cfg.Fields) > 0 {
+		taskCtx := &TaskContext{
+			message:  instance.Message,
+			vars:     instance.Vars,
+			results:  instance.Results,
+			instance: instance,
+		}
+		summary.Fields = make(map[string]interface{}, len(x.cfg.Fields))
+		for name, path := range x.cfg.Fields {
+			if value, ok := taskCtx.Lookup(path); ok {
+				summary.Fields[name] = value
+			}
+		}
+	}
+	return summary
+}
+
+// export 将实例摘要放入发送缓冲，缓冲满时丢弃
+func (x *instanceExporter) export(instance *WorkflowInstance, err error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if x.stopped {
+		return
+	}
+	select {
+	case x.queue <- x.summarize(instance, err):
+	default:
+		if x.dropped.Add(1)%100 == 1 {
+			x.logger.Warnf("Instance export buffer is full, dropped %d summaries so far", x.dropped.Load())
+		}
+	}
+}
+
+// run 逐条发送缓冲中的摘要，缓冲关闭后退出
+func (x *instanceExporter) run() {
+	defer close(x.done)
+	for summary := range x.queue {
+		body, err := json.Marshal(summary)
+		if err != nil {
+			x.logger.Errorf("Failed to encode summary of instance %s: %v", summary.InstanceID, err)
+			continue
+		}
+		if x.producer != nil {
+			x.send(summary, "NSQ", func() error {
+				return x.producer.Publish(x.cfg.NSQTopic, body)
+			})
+		}
+		if x.cfg.URL != "" {
+			x.send(summary, "HTTP", func() error {
+				return x.post(body)
+			})
+		}
+	}
+}
+
+// send 发送摘要，失败时按 1s、2s、4s... 的间隔重试，达到最大次数后放弃
+func (x *instanceExporter) send(summary *InstanceSummary, target string, fn func() error) {
+	var err error
+	for attempt := 1; attempt <= x.cfg.MaxAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return
+		}
+		if attempt < x.cfg.MaxAttempts {
+			time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+		}
+	}
+	x.logger.Errorf("Failed to export summary of instance %s to %s after %d attempts: %v", summary.InstanceID, target, x.cfg.MaxAttempts, err)
+}
+
+// post 以POST发送摘要到HTTP端点，2xx以外的状态视为失败
+func (x *instanceExporter) post(body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, x.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range x.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := x.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// stop 停止接收新的摘要，等待缓冲中的摘要发送完成，超时后放弃
+func (x *instanceExporter) stop() {
+	x.mu.Lock()
+	if x.stopped {
+		x.mu.Unlock()
+		return
+	}
+	x.stopped = true
+	close(x.queue)
+	x.mu.Unlock()
+
+	select {
+	case <-x.done:
+	case <-time.After(exportStopTimeout):
+		x.logger.Warnf("Timed out waiting for %d instance summaries to be exported", len(x.queue))
+	}
+	if x.producer != nil {
+		x.producer.Stop()
+	}
+}
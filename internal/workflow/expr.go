@@ -0,0 +1,285 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/ext"
+	"github.com/google/cel-go/interpreter"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// 表达式使用 CEL（Common Expression Language，github.com/google/cel-go），用于条件判断和简单的计算，不需要启动JS运行时。
+// 除标准库外启用了字符串扩展（lowerAscii、upperAscii、trim、split、replace、join 等）。
+//
+// 表达式中的变量在编译时声明为 dyn 类型，计算时从任务上下文中查找，因此可以引用任意工作流变量；
+// 语法错误、未知函数和参数个数错误在编译时报告，类型不匹配在计算时报告。
+
+// maxCompiledExprs 编译缓存的最大表达式数，超过时清空重新缓存
+const maxCompiledExprs = 10000
+
+// maxExprDepth 表达式嵌套的最大深度
+const maxExprDepth = 64
+
+var (
+	// compiledExprs 表达式字符串到CEL程序的缓存
+	compiledExprs     sync.Map
+	compiledExprCount int64
+
+	// exprEnv 表达式的基础环境，编译时按表达式引用的变量扩展
+	exprEnv = sync.OnceValues(func() (*cel.Env, error) {
+		return cel.NewEnv(
+			ext.Strings(),
+			cel.CrossTypeNumericComparisons(true),
+			cel.ParserRecursionLimit(maxExprDepth),
+		)
+	})
+)
+
+// compileExpr 解析、检查并编译表达式，结果按表达式字符串缓存
+func compileExpr(source string) (cel.Program, error) {
+	if cached, ok := compiledExprs.Load(source); ok {
+		return cached.(cel.Program), nil
+	}
+
+	env, err := exprEnv()
+	if err != nil {
+		return nil, err
+	}
+	parsed, iss := env.Parse(source)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	names, err := exprIdents(parsed)
+	if err != nil {
+		return nil, err
+	}
+	decls := make([]cel.EnvOption, 0, len(names))
+	for _, name := range names {
+		decls = append(decls, cel.Variable(name, cel.DynType))
+	}
+	if env, err = env.Extend(decls...); err != nil {
+		return nil, err
+	}
+	checked, iss := env.Check(parsed)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	program, err := env.Program(checked, cel.EvalOptions(cel.OptOptimize))
+	if err != nil {
+		return nil, err
+	}
+
+	if atomic.AddInt64(&compiledExprCount, 1) > maxCompiledExprs {
+		compiledExprs.Range(func(key, _ interface{}) bool {
+			compiledExprs.Delete(key)
+			return true
+		})
+		atomic.StoreInt64(&compiledExprCount, 1)
+	}
+	compiledExprs.Store(source, program)
+	return program, nil
+}
+
+// exprIdents 返回表达式中引用的顶层变量名，不包括宏中的循环变量
+func exprIdents(parsed *cel.Ast) ([]string, error) {
+	pe, err := cel.AstToParsedExpr(parsed)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	var walk func(e *exprpb.Expr, scoped map[string]bool)
+	walk = func(e *exprpb.Expr, scoped map[string]bool) {
+		if e == nil {
+			return
+		}
+		switch kind := e.ExprKind.(type) {
+		case *exprpb.Expr_IdentExpr:
+			name := kind.IdentExpr.GetName()
+			if !scoped[name] && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		case *exprpb.Expr_SelectExpr:
+			walk(kind.SelectExpr.GetOperand(), scoped)
+		case *exprpb.Expr_CallExpr:
+			walk(kind.CallExpr.GetTarget(), scoped)
+			for _, arg := range kind.CallExpr.GetArgs() {
+				walk(arg, scoped)
+			}
+		case *exprpb.Expr_ListExpr:
+			for _, element := range kind.ListExpr.GetElements() {
+				walk(element, scoped)
+			}
+		case *exprpb.Expr_StructExpr:
+			for _, entry := range kind.StructExpr.GetEntries() {
+				walk(entry.GetMapKey(), scoped)
+				walk(entry.GetValue(), scoped)
+			}
+		case *exprpb.Expr_ComprehensionExpr:
+			c := kind.ComprehensionExpr
+			walk(c.GetIterRange(), scoped)
+			walk(c.GetAccuInit(), scoped)
+			inner := make(map[string]bool, len(scoped)+2)
+			for name := range scoped {
+				inner[name] = true
+			}
+			inner[c.GetIterVar()] = true
+			inner[c.GetAccuVar()] = true
+			walk(c.GetLoopCondition(), inner)
+			walk(c.GetLoopStep(), inner)
+			walk(c.GetResult(), inner)
+		}
+	}
+	walk(pe.GetExpr(), map[string]bool{})
+	return names, nil
+}
+
+// ValidateExpression 检查表达式的语法，用于保存工作流时校验任务条件
+func ValidateExpression(source string) error {
+	_, err := compileExpr(source)
+	return err
+}
+
+// exprActivation 从任务上下文中查找表达式中的变量
+type exprActivation struct {
+	taskCtx *TaskContext
+}
+
+// ResolveName 实现 interpreter.Activation
+func (a exprActivation) ResolveName(name string) (any, bool) {
+	return a.taskCtx.exprVariable(name)
+}
+
+// Parent 实现 interpreter.Activation
+func (a exprActivation) Parent() interpreter.Activation {
+	return nil
+}
+
+// evalExpr 使用任务上下文计算表达式，变量为 nsq（消息数据）、output（前置任务输出）、vars（工作流变量）、task（任务ID和幂等键），
+// 其他名称为工作流变量；结果转换为Go值（整数为int64，浮点数为float64，列表和对象为 []interface{}、map[string]interface{}）
+func evalExpr(source string, taskCtx *TaskContext) (interface{}, error) {
+	program, err := compileExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := program.Eval(exprActivation{taskCtx: taskCtx})
+	if err != nil {
+		return nil, err
+	}
+	return exprNative(value)
+}
+
+// evalCondition 计算条件表达式，结果必须是布尔值
+func evalCondition(source string, taskCtx *TaskContext) (bool, error) {
+	program, err := compileExpr(source)
+	if err != nil {
+		return false, err
+	}
+	value, _, err := program.Eval(exprActivation{taskCtx: taskCtx})
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("condition must evaluate to a bool, got %s", value.Type().TypeName())
+	}
+	return bool(result), nil
+}
+
+// exprVariable 返回表达式中的顶层变量
+func (tc *TaskContext) exprVariable(name string) (interface{}, bool) {
+	switch name {
+	case "nsq":
+		if tc.message == nil {
+			return nil, true
+		}
+		return tc.message.Data, true
+	case "output":
+		return tc.results, true
+	case "vars":
+		return tc.vars, true
+	case "task":
+		return map[string]interface{}{"id": tc.taskID, "idempotency_key": tc.IdempotencyKey()}, true
+	}
+	// 与模板变量一致，其他名称为工作流变量（如循环中的 item、loop）
+	value, ok := tc.vars[name]
+	return value, ok
+}
+
+// exprNative 将CEL的值转换为Go值，对象的键转换为字符串
+func exprNative(value ref.Val) (interface{}, error) {
+	switch v := value.(type) {
+	case types.Null:
+		return nil, nil
+	case types.Bool:
+		return bool(v), nil
+	case types.Int:
+		return int64(v), nil
+	case types.Uint:
+		return uint64(v), nil
+	case types.Double:
+		return float64(v), nil
+	case types.String:
+		return string(v), nil
+	case types.Bytes:
+		return []byte(v), nil
+	case types.Timestamp:
+		return v.Time, nil
+	case types.Duration:
+		return v.Duration.String(), nil
+	case traits.Mapper:
+		object := map[string]interface{}{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			item, err := exprNative(v.Get(key))
+			if err != nil {
+				return nil, err
+			}
+			name, err := exprNative(key)
+			if err != nil {
+				return nil, err
+			}
+			object[stringifyValue(name)] = item
+		}
+		return object, nil
+	case traits.Lister:
+		list := []interface{}{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			item, err := exprNative(it.Next())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	}
+	return value.Value(), nil
+}
+
+// exprToNumber 将数字或数字字符串转换为浮点数
+func exprToNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to a number", v)
+		}
+		return number, nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to a number", value)
+}
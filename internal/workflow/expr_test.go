@@ -0,0 +1,241 @@
+package workflow
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"nsa/internal/models"
+)
+
+// exprTestContext 表达式测试使用的任务上下文
+func exprTestContext() *TaskContext {
+	return &TaskContext{
+		taskID: "check",
+		message: &models.NSQMessage{Data: map[string]interface{}{
+			"amount": 120.5,
+			"user":   map[string]interface{}{"name": "Alice", "vip": true},
+			"tags":   []interface{}{"a", "b"},
+			"empty":  nil,
+		}},
+		vars: map[string]interface{}{
+			"count": 3,
+			"env":   "prod",
+			"items": []int{1, 2, 3},
+			"none":  nil,
+		},
+		results: map[string]interface{}{
+			"fetch": map[string]interface{}{"status": 200.0},
+		},
+	}
+}
+
+// exprCase 表达式测试用例，wantErr 不为空时期望错误信息包含该字符串
+type exprCase struct {
+	expr    string
+	want    interface{}
+	wantErr string
+}
+
+// runExprCases 计算表达式并检查结果
+func runExprCases(t *testing.T, tests []exprCase) {
+	t.Helper()
+	for _, tt := range tests {
+		got, err := evalExpr(tt.expr, exprTestContext())
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("evalExpr(%q) = %#v, %v, want error %q", tt.expr, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("evalExpr(%q) error: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("evalExpr(%q) = %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvalExprPrecedence(t *testing.T) {
+	runExprCases(t, []exprCase{
+		{expr: "1 + 2 * 3", want: int64(7)},
+		{expr: "(1 + 2) * 3", want: int64(9)},
+		{expr: "10 - 4 - 3", want: int64(3)},
+		{expr: "12 / 3 / 2", want: int64(2)},
+		{expr: "7 % 4 * 2", want: int64(6)},
+		{expr: "-2 * 3", want: int64(-6)},
+		{expr: "- -2", want: int64(2)},
+		{expr: "!true || true", want: true},
+		{expr: "!(true || true)", want: false},
+		{expr: "true || false && false", want: true},
+		{expr: "(true || false) && false", want: false},
+		{expr: "1 + 1 == 2 && 2 < 3", want: true},
+		{expr: "2 in [1, 2] && 'a' + 'b' == 'ab'", want: true},
+		{expr: "false ? 1 : true ? 2 : 3", want: int64(2)},
+		{expr: "1 > 2 ? 'a' : 'b'", want: "b"},
+		{expr: "count * 2 + size(items)", want: int64(9)},
+		{expr: "nsq.amount > 100 && nsq.user.vip", want: true},
+		{expr: "output.fetch.status == 200", want: true},
+		{expr: "vars.env == 'prod' || vars.missing", want: true},
+		{expr: "nsq.tags[1] + nsq.user['name']", want: "bAlice"},
+		{expr: "items.map(i, i * 2)", want: []interface{}{int64(2), int64(4), int64(6)}},
+		{expr: "items.filter(i, i > 1).size() == 2", want: true},
+		{expr: "items.exists_one(i, i == 2) && items.all(i, i > 0)", want: true},
+		{expr: "'Hello'.lowerAscii().startsWith('he')", want: true},
+		{expr: "nsq.tags.join('-')", want: "a-b"},
+		{expr: "{'total': nsq.amount + 0.5}", want: map[string]interface{}{"total": 121.0}},
+	})
+}
+
+func TestEvalExprNumbers(t *testing.T) {
+	runExprCases(t, []exprCase{
+		// 整数和浮点数是不同的类型，与 CEL 一致
+		{expr: "7 / 2", want: int64(3)},
+		{expr: "7.0 / 2.0", want: 3.5},
+		{expr: "int(2.7)", want: int64(2)},
+		{expr: "double(count) / 2.0", want: 1.5},
+		{expr: "nsq.amount * 2.0", want: 241.0},
+		{expr: "nsq.amount * 2", wantErr: "no such overload"},
+		// 不同数字类型之间可以比较
+		{expr: "nsq.amount > 100", want: true},
+		{expr: "count < 3.5", want: true},
+		{expr: "output.fetch.status == 200", want: true},
+		{expr: "9223372036854775807 + 1", wantErr: "integer overflow"},
+		{expr: "-9223372036854775807 - 2", wantErr: "integer overflow"},
+		{expr: "1 / 0", wantErr: "division by zero"},
+		{expr: "1 % 0", wantErr: "modulus by zero"},
+		{expr: "1.0 / 0.0 > 1e308", want: true},
+	})
+}
+
+func TestEvalExprTypeErrors(t *testing.T) {
+	runExprCases(t, []exprCase{
+		// 变量为 dyn 类型，类型不匹配在计算时报错
+		{expr: "count + 'a'", wantErr: "no such overload"},
+		{expr: "env - 'b'", wantErr: "found no matching overload for '_-_' applied to '(dyn, string)'"},
+		{expr: "items + 1", wantErr: "no such overload"},
+		{expr: "count < env", wantErr: "no such overload"},
+		{expr: "!count", wantErr: "no such overload"},
+		{expr: "-env", wantErr: "no such overload"},
+		{expr: "count && true", wantErr: "no such overload"},
+		{expr: "count ? 2 : 3", wantErr: "no such overload"},
+		{expr: "size(count)", wantErr: "no such overload"},
+		{expr: "int(env)", wantErr: "type conversion error from 'string' to 'int'"},
+		{expr: "bool(env)", wantErr: "type conversion error from 'string' to 'bool'"},
+		{expr: "items[3]", wantErr: "index out of bounds: 3"},
+		{expr: "nsq.missing", wantErr: "no such key: missing"},
+		{expr: "unknown", wantErr: "no such attribute"},
+		{expr: "items.all(i, i)", wantErr: "no such overload"},
+		{expr: "env.matches('(')", wantErr: "error parsing regexp"},
+	})
+}
+
+func TestEvalExprNull(t *testing.T) {
+	runExprCases(t, []exprCase{
+		{expr: "null", want: nil},
+		{expr: "none == null", want: true},
+		{expr: "nsq.empty == null", want: true},
+		{expr: "null == null", want: true},
+		{expr: "none != 0", want: true},
+		{expr: "none == false", want: false},
+		{expr: "none == ''", want: false},
+		{expr: "none in [1, null]", want: true},
+		{expr: "has(nsq.empty)", want: true},
+		{expr: "has(nsq.missing)", want: false},
+		{expr: "none.field", wantErr: "no such key: field"},
+		{expr: "none + 1", wantErr: "no such overload"},
+		{expr: "none < 1", wantErr: "no such overload"},
+		{expr: "!none", wantErr: "no such overload"},
+		{expr: "size(none)", wantErr: "no such overload"},
+	})
+}
+
+func TestEvalExprShortCircuit(t *testing.T) {
+	runExprCases(t, []exprCase{
+		// 能决定结果的一侧忽略另一侧的错误，与 CEL 一致
+		{expr: "false && nsq.missing", want: false},
+		{expr: "nsq.missing && false", want: false},
+		{expr: "true || 1 / 0 > 0", want: true},
+		{expr: "1 / 0 > 0 || true", want: true},
+		{expr: "has(nsq.coupon) && nsq.coupon.code == 'X'", want: false},
+		{expr: "!has(nsq.coupon) || nsq.coupon.code == 'X'", want: true},
+		{expr: "false ? nsq.missing : 'else'", want: "else"},
+		{expr: "true ? 'then' : nsq.missing", want: "then"},
+		{expr: "items.exists(i, i == 1 || i / 0 > 0)", want: true},
+		// 不能决定结果时返回错误
+		{expr: "true && nsq.missing", wantErr: "no such key: missing"},
+		{expr: "nsq.missing || false", wantErr: "no such key: missing"},
+		{expr: "true && env", wantErr: "no such overload"},
+	})
+}
+
+func TestEvalCondition(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr string
+	}{
+		{expr: "nsq.amount >= 120.5", want: true},
+		{expr: "env in ['dev', 'test']", want: false},
+		{expr: "'vip' in nsq.user", want: true},
+		{expr: "nsq.user.name.matches('^A')", want: true},
+		{expr: "task.id == 'check'", want: true},
+		{expr: "count", wantErr: "condition must evaluate to a bool, got int"},
+		{expr: "none", wantErr: "condition must evaluate to a bool, got null_type"},
+		{expr: "env", wantErr: "condition must evaluate to a bool, got string"},
+	}
+	for _, tt := range tests {
+		got, err := evalCondition(tt.expr, exprTestContext())
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("evalCondition(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("evalCondition(%q) error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("evalCondition(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestValidateExpression(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: "a && (b || c)"},
+		{expr: "[1, 2,]"},
+		{expr: "{'a': 1}.a"},
+		{expr: "items.map(i, i * 2)"},
+		{expr: "1 +", wantErr: "Syntax error"},
+		{expr: "(1 + 2", wantErr: "Syntax error"},
+		{expr: "1 2", wantErr: "Syntax error"},
+		{expr: "a ? b", wantErr: "Syntax error"},
+		{expr: "'unterminated", wantErr: "Syntax error"},
+		// 字面量的类型、未知函数和参数个数在编译时检查
+		{expr: "1 + 'a'", wantErr: "found no matching overload for '_+_' applied to '(int, string)'"},
+		{expr: "1 < 'a'", wantErr: "found no matching overload"},
+		{expr: "foo(1)", wantErr: "undeclared reference to 'foo'"},
+		{expr: "size(1, 2)", wantErr: "found no matching overload for 'size'"},
+		{expr: "a.startsWith()", wantErr: "found no matching overload for 'startsWith'"},
+		{expr: strings.Repeat("(", maxExprDepth+1) + "1" + strings.Repeat(")", maxExprDepth+1), wantErr: "recursion"},
+	}
+	for _, tt := range tests {
+		err := ValidateExpression(tt.expr)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ValidateExpression(%q) error: %v", tt.expr, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ValidateExpression(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// ExpressionAction 表达式动作，使用 CEL 表达式做简单的布尔、数学和字符串计算
+//
+// 表达式在进程内执行，不启动JS运行时，适合不需要完整脚本的简单逻辑。
+type ExpressionAction struct {
+	ctx *ActionContext
+}
+
+// NewExpressionAction 创建表达式动作
+func NewExpressionAction(ctx *ActionContext) *ExpressionAction {
+	return &ExpressionAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *ExpressionAction) Name() string {
+	return "ExpressionAction"
+}
+
+// Run 计算表达式
+//
+// expression 为单个表达式，输出为其结果；expressions 为输出字段到表达式的映射，输出为对象。
+// 表达式中可以使用 nsq（消息数据）、output（前置节点输出）、vars（工作流变量）和 task。
+func (a *ExpressionAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := taskCtx.GetParams()
+
+	expression, _ := params["expression"].(string)
+	expressions, _ := params["expressions"].(map[string]interface{})
+
+	if expression == "" && expressions == nil {
+		return fmt.Errorf("expression or expressions parameter is required")
+	}
+	if expression != "" && expressions != nil {
+		return fmt.Errorf("expression and expressions parameters are mutually exclusive")
+	}
+
+	if expressions == nil {
+		value, err := evalExpr(expression, taskCtx)
+		if err != nil {
+			return fmt.Errorf("expression %q: %v", expression, err)
+		}
+		taskCtx.SetOutput(value)
+		a.ctx.Logger.Infof("Expression evaluated successfully")
+		return nil
+	}
+
+	// 按字段名顺序计算，使错误信息稳定
+	keys := make([]string, 0, len(expressions))
+	for key := range expressions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	output := make(map[string]interface{}, len(expressions))
+	for _, key := range keys {
+		source, ok := expressions[key].(string)
+		if !ok {
+			return fmt.Errorf("expressions.%s must be a string", key)
+		}
+		value, err := evalExpr(source, taskCtx)
+		if err != nil {
+			return fmt.Errorf("expressions.%s %q: %v", key, source, err)
+		}
+		output[key] = value
+	}
+
+	taskCtx.SetOutput(output)
+	a.ctx.Logger.Infof("Evaluated %d expressions successfully", len(output))
+	return nil
+}
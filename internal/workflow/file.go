@@ -0,0 +1,454 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"nsa/internal/config"
+)
+
+// maxListedFiles list操作返回的最大文件数
+const maxListedFiles = 1000
+
+// FileAction 文件动作，读写配置的根目录（files.base_dir）下的文件
+type FileAction struct {
+	ctx *ActionContext
+	cfg config.FilesConfig
+}
+
+// NewFileAction 创建文件动作
+func NewFileAction(ctx *ActionContext, cfg config.FilesConfig) *FileAction {
+	return &FileAction{ctx: ctx, cfg: cfg}
+}
+
+// Name 返回动作名称
+func (a *FileAction) Name() string {
+	return "FileAction"
+}
+
+// Run 执行文件操作
+func (a *FileAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	if a.cfg.BaseDir == "" {
+		return fmt.Errorf("file action is disabled, set files.base_dir in config.json to enable it")
+	}
+
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	path, _ := params["path"].(string)
+
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if path == "" && operation != "list" {
+		return fmt.Errorf("path parameter is required")
+	}
+
+	target, err := a.resolvePath(path)
+	if err != nil {
+		return err
+	}
+
+	a.ctx.Logger.Infof("Executing file %s on %s", operation, target)
+
+	var result interface{}
+	switch operation {
+	case "read":
+		result, err = a.read(target, params)
+	case "write", "append":
+		var data []byte
+		if data, err = a.encode(target, operation == "append", params); err == nil {
+			result, err = a.write(target, data, operation == "append")
+		}
+	case "template":
+		var data []byte
+		if data, err = a.render(taskCtx, params); err == nil {
+			result, err = a.write(target, data, false)
+		}
+	case "list":
+		result, err = a.list(target, params)
+	default:
+		return fmt.Errorf("unsupported file operation: %s", operation)
+	}
+	if err != nil {
+		return fmt.Errorf("file %s failed: %v", operation, err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("File %s completed successfully", operation)
+
+	return nil
+}
+
+// resolvePath 将路径解析为根目录下的绝对路径，拒绝通过 .. 或符号链接逃逸到根目录之外
+func (a *FileAction) resolvePath(path string) (string, error) {
+	return resolveBasePath(a.cfg.BaseDir, path)
+}
+
+// resolveBasePath 将路径解析为 baseDir 下的绝对路径，相对路径相对于 baseDir；
+// 解析已存在部分的符号链接，拒绝通过 .. 或符号链接逃逸到 baseDir 之外
+func resolveBasePath(baseDir, path string) (string, error) {
+	base, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid files.base_dir: %v", err)
+	}
+	if base, err = filepath.EvalSymlinks(base); err != nil {
+		return "", fmt.Errorf("invalid files.base_dir: %v", err)
+	}
+
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(base, target)
+	}
+	target = filepath.Clean(target)
+
+	// 解析已存在部分的符号链接，不存在的部分原样拼接
+	existing, rest := target, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(existing); err == nil {
+			target = filepath.Join(resolved, rest)
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+
+	if target != base && !strings.HasPrefix(target, base+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside of the allowed base directory", path)
+	}
+	return target, nil
+}
+
+// relativePath 返回相对于根目录的路径，用于输出
+func (a *FileAction) relativePath(target string) string {
+	base, _ := filepath.Abs(a.cfg.BaseDir)
+	base, _ = filepath.EvalSymlinks(base)
+	if rel, err := filepath.Rel(base, target); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return target
+}
+
+// read 读取文件，format为text（默认）、json或csv
+func (a *FileAction) read(target string, params map[string]interface{}) (interface{}, error) {
+	format, _ := params["format"].(string)
+	moveTo, _ := params["move_to"].(string)
+
+	file, err := os.Open(target)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(file, a.cfg.MaxReadSize+1))
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > a.cfg.MaxReadSize {
+		return nil, fmt.Errorf("file exceeds max read size of %d bytes", a.cfg.MaxReadSize)
+	}
+
+	result := map[string]interface{}{
+		"path": a.relativePath(target),
+		"size": len(data),
+	}
+	switch format {
+	case "", "text":
+		result["content"] = string(data)
+	case "json":
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		result["data"] = v
+	case "csv":
+		columns, rows, err := readCSV(data)
+		if err != nil {
+			return nil, err
+		}
+		result["columns"] = columns
+		result["rows"] = rows
+		result["count"] = len(rows)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	// 读取后移动文件，避免批处理文件被重复处理
+	if moveTo != "" {
+		dest, err := a.resolvePath(moveTo)
+		if err != nil {
+			return nil, err
+		}
+		// 以 / 结尾或已存在的目录表示移动到该目录下
+		if info, err := os.Stat(dest); strings.HasSuffix(moveTo, "/") || (err == nil && info.IsDir()) {
+			dest = filepath.Join(dest, filepath.Base(target))
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(target, dest); err != nil {
+			return nil, err
+		}
+		result["moved_to"] = a.relativePath(dest)
+	}
+	return result, nil
+}
+
+// encode 按format编码写入内容：text写入content，json和csv序列化data
+func (a *FileAction) encode(target string, appending bool, params map[string]interface{}) ([]byte, error) {
+	format, _ := params["format"].(string)
+
+	switch format {
+	case "", "text":
+		return []byte(stringifyValue(params["content"])), nil
+	case "json":
+		data, err := json.MarshalIndent(params["data"], "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode JSON: %v", err)
+		}
+		if appending {
+			// 追加时按JSON Lines写入
+			data, _ = json.Marshal(params["data"])
+		}
+		return append(data, '\n'), nil
+	case "csv":
+		var rows []interface{}
+		switch v := params["data"].(type) {
+		case []interface{}:
+			rows = v
+		case []map[string]interface{}:
+			// 数据库、数据仓库节点输出的行
+			for _, row := range v {
+				rows = append(rows, row)
+			}
+		default:
+			return nil, fmt.Errorf("csv data must be an array of objects")
+		}
+		columns := toStringSlice(params["columns"])
+
+		// 追加到非空文件时不重复写表头
+		header := true
+		if appending {
+			if info, err := os.Stat(target); err == nil && info.Size() > 0 {
+				header = false
+			}
+		}
+		return writeCSV(rows, columns, header)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// render 渲染模板，模板来自 template 参数或根目录下的 template_file
+func (a *FileAction) render(taskCtx *TaskContext, params map[string]interface{}) ([]byte, error) {
+	templateFile, _ := params["template_file"].(string)
+	if templateFile == "" {
+		// 内联模板已在解析参数时渲染
+		content := stringifyValue(params["template"])
+		if content == "" {
+			return nil, fmt.Errorf("template or template_file parameter is required")
+		}
+		return []byte(content), nil
+	}
+
+	source, err := a.resolvePath(templateFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(renderTemplate(string(data), taskCtx)), nil
+}
+
+// write 写入或追加文件，覆盖写入时先写临时文件再重命名，避免读取方读到不完整的文件
+func (a *FileAction) write(target string, data []byte, appending bool) (interface{}, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+
+	if appending {
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := file.Close(); err != nil {
+			return nil, err
+		}
+	} else {
+		tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp*")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+		if err := tmp.Close(); err != nil {
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+		if err := os.Chmod(tmp.Name(), 0644); err != nil {
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+		if err := os.Rename(tmp.Name(), target); err != nil {
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"path":    a.relativePath(target),
+		"written": len(data),
+		"size":    info.Size(),
+	}, nil
+}
+
+// list 列出目录下匹配pattern的普通文件，按名称排序
+func (a *FileAction) list(target string, params map[string]interface{}) (interface{}, error) {
+	pattern, _ := params["pattern"].(string)
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]interface{}, 0)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if matched, err := filepath.Match(pattern, entry.Name()); err != nil {
+			return nil, fmt.Errorf("invalid pattern: %v", err)
+		} else if !matched {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, map[string]interface{}{
+			"name":     entry.Name(),
+			"path":     a.relativePath(filepath.Join(target, entry.Name())),
+			"size":     info.Size(),
+			"modified": info.ModTime().UTC().Format("2006-01-02T15:04:05Z"),
+		})
+		if len(files) >= maxListedFiles {
+			break
+		}
+	}
+
+	return map[string]interface{}{
+		"files": files,
+		"count": len(files),
+	}, nil
+}
+
+// readCSV 解析带表头的CSV
+func readCSV(data []byte) ([]interface{}, []map[string]interface{}, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+
+	columns := make([]interface{}, 0)
+	rows := make([]map[string]interface{}, 0)
+	if len(records) == 0 {
+		return columns, rows, nil
+	}
+	header := records[0]
+	for _, name := range header {
+		columns = append(columns, name)
+	}
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			} else {
+				row[name] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+// writeCSV 将对象数组编码为CSV，未指定列时使用所有行中出现的字段（按名称排序）
+func writeCSV(rows []interface{}, columns []string, header bool) ([]byte, error) {
+	if len(columns) == 0 {
+		seen := make(map[string]bool)
+		for _, item := range rows {
+			row, _ := item.(map[string]interface{})
+			for key := range row {
+				if !seen[key] {
+					seen[key] = true
+					columns = append(columns, key)
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if header {
+		if err := writer.Write(columns); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range rows {
+		row, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("csv data must be an array of objects")
+		}
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = stringifyValue(row[column])
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// toStringSlice 将参数数组转换为字符串切片
+func toStringSlice(value interface{}) []string {
+	items, _ := toItems(value)
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		result = append(result, stringifyValue(item))
+	}
+	return result
+}
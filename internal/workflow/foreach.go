@@ -0,0 +1,250 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"nsa/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// maxForEachConcurrency 循环的最大并发数
+	maxForEachConcurrency = 50
+	// maxForEachItems 单个循环的最大元素数
+	maxForEachItems = 10000
+)
+
+// ForEachAction 循环动作，对数组中的每个元素执行子任务（或按顺序执行的一组子任务），收集每次迭代的结果
+type ForEachAction struct {
+	ctx      *ActionContext
+	executor *Executor
+}
+
+// NewForEachAction 创建循环动作
+func NewForEachAction(ctx *ActionContext, executor *Executor) *ForEachAction {
+	return &ForEachAction{ctx: ctx, executor: executor}
+}
+
+// Name 返回动作名称
+func (a *ForEachAction) Name() string {
+	return "ForEachAction"
+}
+
+// Run 执行循环
+//
+// items 为要遍历的数组（通常为 "{{output.task_id.list}}" 或 "{{nsq.items}}"）；task 为子任务，tasks 为按顺序执行的子任务列表。
+// 子任务参数中的 {{item}}（名称由 item_var 指定）为当前元素，{{loop.index}}、{{loop.count}} 为序号和总数，
+// {{output.sub_task_id}} 为本次迭代中前面子任务的输出。concurrency 为并发数（默认1）；
+// 默认任一迭代失败时停止启动新的迭代并使任务失败，continue_on_error 为 true 时执行所有迭代并在输出中记录错误。
+func (a *ForEachAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := taskCtx.GetParams()
+
+	// 只解析循环自身的参数，子任务参数在每次迭代时解析
+	items, err := toItems(resolveValue(params["items"], taskCtx))
+	if err != nil {
+		return err
+	}
+	concurrency, _ := resolveValue(params["concurrency"], taskCtx).(float64)
+	itemVar, _ := params["item_var"].(string)
+	continueOnError, _ := params["continue_on_error"].(bool)
+
+	if len(items) > maxForEachItems {
+		return fmt.Errorf("items has %d elements, exceeds maximum of %d", len(items), maxForEachItems)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > maxForEachConcurrency {
+		concurrency = maxForEachConcurrency
+	}
+	if itemVar == "" {
+		itemVar = "item"
+	}
+
+	tasks, single, err := a.parseSubTasks(params)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		failed   = []interface{}{}
+		started  int
+		sem      = make(chan struct{}, int(concurrency))
+		results  = make([]interface{}, len(items))
+	)
+	for i, item := range items {
+		sem <- struct{}{}
+		// 失败后不再启动新的迭代
+		if runCtx.Err() != nil {
+			<-sem
+			break
+		}
+		started++
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			output, err := a.runIteration(runCtx, taskCtx, tasks, single, itemVar, i, item, len(items))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, map[string]interface{}{"index": i, "error": err.Error()})
+				if firstErr == nil {
+					firstErr = fmt.Errorf("iteration %d failed: %v", i, err)
+				}
+				if !continueOnError {
+					cancel()
+				}
+				return
+			}
+			results[i] = output
+		}(i, item)
+	}
+	wg.Wait()
+
+	taskCtx.SetOutput(map[string]interface{}{
+		"count":     len(items),
+		"succeeded": started - len(failed),
+		"failed":    len(failed),
+		"skipped":   len(items) - started,
+		"results":   results,
+		"errors":    failed,
+	})
+
+	if firstErr != nil && !continueOnError {
+		return firstErr
+	}
+	a.ctx.Logger.Infof("ForEach completed: %d items, %d failed", len(items), len(failed))
+	return nil
+}
+
+// runIteration 执行一次迭代，变量和前置输出为副本，迭代之间互不影响
+//
+// 单个子任务时返回其输出，多个子任务时返回子任务ID到输出的映射。
+func (a *ForEachAction) runIteration(ctx context.Context, taskCtx *TaskContext, tasks []Task, single bool, itemVar string, index int, item interface{}, count int) (interface{}, error) {
+	vars := make(map[string]interface{}, len(taskCtx.vars)+2)
+	for key, value := range taskCtx.vars {
+		vars[key] = value
+	}
+	vars[itemVar] = item
+	vars["loop"] = map[string]interface{}{"index": index, "count": count, "item": item}
+
+	results := make(map[string]interface{}, len(taskCtx.results)+len(tasks))
+	for key, value := range taskCtx.results {
+		results[key] = value
+	}
+
+	outputs := make(map[string]interface{}, len(tasks))
+	for _, task := range tasks {
+		// 执行日志和事件中的任务ID包含循环任务ID和序号，如 notify_hosts[3].send
+		sub := task
+		sub.ID = fmt.Sprintf("%s[%d].%s", taskCtx.taskID, index, task.ID)
+		output, err := a.executor.runTask(ctx, &sub, taskCtx.instance, taskCtx.message, vars, results)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", task.ID, err)
+		}
+		results[task.ID] = output
+		outputs[task.ID] = output
+	}
+
+	if single {
+		return outputs[tasks[0].ID], nil
+	}
+	return outputs, nil
+}
+
+// parseSubTasks 解析 task 或 tasks 参数，检查动作是否存在
+func (a *ForEachAction) parseSubTasks(params map[string]interface{}) ([]Task, bool, error) {
+	var raw []interface{}
+	single := false
+	switch {
+	case params["task"] != nil && params["tasks"] != nil:
+		return nil, false, fmt.Errorf("task and tasks parameters are mutually exclusive")
+	case params["task"] != nil:
+		raw = []interface{}{params["task"]}
+		single = true
+	case params["tasks"] != nil:
+		items, err := toItems(params["tasks"])
+		if err != nil {
+			return nil, false, fmt.Errorf("tasks parameter must be an array")
+		}
+		raw = items
+	default:
+		return nil, false, fmt.Errorf("task or tasks parameter is required")
+	}
+	if len(raw) == 0 {
+		return nil, false, fmt.Errorf("tasks parameter must not be empty")
+	}
+
+	tasks := make([]Task, 0, len(raw))
+	seen := make(map[string]bool, len(raw))
+	for i, item := range raw {
+		// 与工作流任务使用相同的配置结构
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid sub-task %d: %v", i, err)
+		}
+		var taskConfig models.TaskConfig
+		if err := json.Unmarshal(data, &taskConfig); err != nil {
+			return nil, false, fmt.Errorf("invalid sub-task %d: %v", i, err)
+		}
+		if taskConfig.ID == "" {
+			if !single {
+				return nil, false, fmt.Errorf("sub-task %d: id is required", i)
+			}
+			taskConfig.ID = "task"
+		}
+		if seen[taskConfig.ID] {
+			return nil, false, fmt.Errorf("duplicate sub-task id: %s", taskConfig.ID)
+		}
+		seen[taskConfig.ID] = true
+		if _, ok := a.executor.actions[taskConfig.ActionName]; !ok {
+			return nil, false, fmt.Errorf("sub-task %s: action %s not found", taskConfig.ID, taskConfig.ActionName)
+		}
+		// 延迟会暂停整个实例，不能用于单次迭代
+		if taskConfig.ActionName == "DelayAction" {
+			return nil, false, fmt.Errorf("sub-task %s: DelayAction cannot be used inside ForEachAction", taskConfig.ID)
+		}
+		if taskConfig.Condition != "" {
+			if err := ValidateExpression(taskConfig.Condition); err != nil {
+				return nil, false, fmt.Errorf("sub-task %s: invalid condition: %v", taskConfig.ID, err)
+			}
+		}
+		tasks = append(tasks, buildTask(taskConfig))
+	}
+	return tasks, single, nil
+}
+
+// toItems 将数组参数转换为[]interface{}，从MongoDB读取的数组为 primitive.A
+func toItems(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case primitive.A:
+		return v, nil
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items, nil
+	case nil:
+		return nil, fmt.Errorf("items parameter is required")
+	case string:
+		// 模板变量无法解析时保持原样
+		return nil, fmt.Errorf("items must resolve to an array: %q", v)
+	default:
+		return nil, fmt.Errorf("items must resolve to an array, got %T", value)
+	}
+}
@@ -0,0 +1,135 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"nsa/internal/config"
+	"nsa/internal/models"
+)
+
+// actionRules 允许或禁止的动作类型
+type actionRules struct {
+	allowed map[string]bool // 为nil时允许所有动作
+	denied  map[string]bool
+}
+
+// newActionRules 创建动作类型规则
+func newActionRules(allowed, denied []string) actionRules {
+	rules := actionRules{denied: make(map[string]bool, len(denied))}
+	if len(allowed) > 0 {
+		rules.allowed = make(map[string]bool, len(allowed))
+		for _, name := range allowed {
+			rules.allowed[name] = true
+		}
+	}
+	for _, name := range denied {
+		rules.denied[name] = true
+	}
+	return rules
+}
+
+// permits 规则是否允许动作类型
+func (r actionRules) permits(name string) bool {
+	if r.denied[name] {
+		return false
+	}
+	return r.allowed == nil || r.allowed[name]
+}
+
+// names 返回规则中出现的动作类型
+func (r actionRules) names() []string {
+	var names []string
+	for name := range r.allowed {
+		names = append(names, name)
+	}
+	for name := range r.denied {
+		names = append(names, name)
+	}
+	return names
+}
+
+// actionGates 全局和按项目的动作类型开关
+type actionGates struct {
+	global   actionRules
+	projects map[string]actionRules
+}
+
+// newActionGates 按配置创建动作类型开关
+func newActionGates(cfg config.ActionsConfig) *actionGates {
+	gates := &actionGates{
+		global:   newActionRules(cfg.Allowed, cfg.Denied),
+		projects: make(map[string]actionRules, len(cfg.Projects)),
+	}
+	for _, project := range cfg.Projects {
+		gates.projects[project.Project] = newActionRules(project.Allowed, project.Denied)
+	}
+	return gates
+}
+
+// warnUnknownActions 配置中的动作类型未注册时记录警告，通常是名称拼写错误
+func (e *Executor) warnUnknownActions() {
+	rules := []actionRules{e.gates.global}
+	for _, project := range e.gates.projects {
+		rules = append(rules, project)
+	}
+	for _, r := range rules {
+		for _, name := range r.names() {
+			if _, exists := e.actions[name]; !exists {
+				e.logger.Warnf("Unknown action %s in actions config", name)
+			}
+		}
+	}
+}
+
+// ActionAllowed 检查动作类型在项目中是否可用
+func (e *Executor) ActionAllowed(project, actionName string) error {
+	if !e.gates.global.permits(actionName) {
+		return fmt.Errorf("action %s is disabled in this deployment", actionName)
+	}
+	if rules, ok := e.gates.projects[project]; ok && !rules.permits(actionName) {
+		return fmt.Errorf("action %s is disabled in project %s", actionName, project)
+	}
+	return nil
+}
+
+// CheckActions 检查任务（包括循环节点的子任务）使用的动作类型在项目中是否都可用，保存工作流时调用
+func (e *Executor) CheckActions(project string, tasks []models.TaskConfig) error {
+	for _, task := range tasks {
+		if err := e.ActionAllowed(project, task.ActionName); err != nil {
+			return fmt.Errorf("task %s: %v", task.ID, err)
+		}
+		if task.ActionName != "ForEachAction" {
+			continue
+		}
+		if err := e.CheckActions(project, subTaskConfigs(task.Params)); err != nil {
+			return fmt.Errorf("task %s: %v", task.ID, err)
+		}
+	}
+	return nil
+}
+
+// subTaskConfigs 返回循环节点参数中的子任务配置，格式错误的子任务在执行时报告
+func subTaskConfigs(params map[string]interface{}) []models.TaskConfig {
+	var raw []interface{}
+	if task, ok := params["task"]; ok {
+		raw = append(raw, task)
+	}
+	if tasks, ok := params["tasks"].([]interface{}); ok {
+		raw = append(raw, tasks...)
+	}
+
+	var configs []models.TaskConfig
+	for _, item := range raw {
+		data, err := json.Marshal(item)
+		if err != nil {
+			continue
+		}
+		var taskConfig models.TaskConfig
+		if err := json.Unmarshal(data, &taskConfig); err != nil {
+			continue
+		}
+		configs = append(configs, taskConfig)
+	}
+	return configs
+}
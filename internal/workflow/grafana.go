@@ -0,0 +1,245 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GrafanaAction Grafana动作（添加/更新注释、创建仪表盘快照）
+type GrafanaAction struct {
+	ctx *ActionContext
+}
+
+// NewGrafanaAction 创建Grafana动作
+func NewGrafanaAction(ctx *ActionContext) *GrafanaAction {
+	return &GrafanaAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *GrafanaAction) Name() string {
+	return "GrafanaAction"
+}
+
+// Run 执行Grafana操作
+func (a *GrafanaAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	baseURL, _ := params["url"].(string)
+	apiKeySecret, _ := params["api_key_secret"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if baseURL == "" {
+		return fmt.Errorf("url parameter is required")
+	}
+	if apiKeySecret == "" {
+		return fmt.Errorf("api_key_secret parameter is required")
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	// API Key 或服务账号令牌
+	apiKey, err := a.ctx.getSecret(ctx, apiKeySecret)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+apiKey)
+	if orgID := paramString(params, "org_id"); orgID != "" {
+		header.Set("X-Grafana-Org-Id", orgID)
+	}
+	client := &grafanaClient{baseURL: baseURL, header: header, timeout: time.Duration(timeout) * time.Second}
+
+	var result map[string]interface{}
+	switch operation {
+	case "annotate":
+		result, err = a.annotate(ctx, client, params)
+	case "update_annotation":
+		result, err = a.updateAnnotation(ctx, client, params)
+	case "snapshot":
+		result, err = a.snapshot(ctx, client, params)
+	default:
+		return fmt.Errorf("unsupported grafana operation: %s", operation)
+	}
+	if err != nil {
+		return fmt.Errorf("grafana %s failed: %v", operation, err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Grafana %s completed successfully", operation)
+
+	return nil
+}
+
+// grafanaClient Grafana HTTP API客户端
+type grafanaClient struct {
+	baseURL string
+	header  http.Header
+	timeout time.Duration
+}
+
+// call 发送JSON请求
+func (c *grafanaClient) call(ctx context.Context, method, path string, body, out interface{}) error {
+	return callJSON(ctx, method, c.baseURL+path, c.header, body, out, c.timeout)
+}
+
+// annotate 添加注释；未指定 dashboard_uid 时为组织级注释，指定 time_end 时为区间注释
+func (a *GrafanaAction) annotate(ctx context.Context, client *grafanaClient, params map[string]interface{}) (map[string]interface{}, error) {
+	text, _ := params["text"].(string)
+	if text == "" {
+		return nil, fmt.Errorf("text parameter is required for annotate")
+	}
+
+	start := time.Now()
+	if _, ok := params["time"]; ok {
+		t, err := parseGrafanaTime(params, "time")
+		if err != nil {
+			return nil, err
+		}
+		start = t
+	}
+
+	body := map[string]interface{}{
+		"text": text,
+		"time": start.UnixMilli(),
+		"tags": toStringSlice(params["tags"]),
+	}
+	if _, ok := params["time_end"]; ok {
+		end, err := parseGrafanaTime(params, "time_end")
+		if err != nil {
+			return nil, err
+		}
+		body["timeEnd"] = end.UnixMilli()
+	}
+	if uid, _ := params["dashboard_uid"].(string); uid != "" {
+		body["dashboardUID"] = uid
+	}
+	if panelID, ok := params["panel_id"].(float64); ok {
+		body["panelId"] = int64(panelID)
+	}
+
+	var resp struct {
+		ID      int64  `json:"id"`
+		Message string `json:"message"`
+	}
+	if err := client.call(ctx, http.MethodPost, "/api/annotations", body, &resp); err != nil {
+		return nil, err
+	}
+	a.ctx.Logger.Infof("Grafana annotation %d created", resp.ID)
+
+	return map[string]interface{}{
+		"id":   resp.ID,
+		"time": body["time"],
+	}, nil
+}
+
+// updateAnnotation 更新注释，常用于在事件结束时补充结束时间
+func (a *GrafanaAction) updateAnnotation(ctx context.Context, client *grafanaClient, params map[string]interface{}) (map[string]interface{}, error) {
+	id := paramString(params, "annotation_id")
+	if id == "" {
+		return nil, fmt.Errorf("annotation_id parameter is required for update_annotation")
+	}
+
+	body := map[string]interface{}{}
+	copyParams(params, body, "text")
+	if tags, ok := params["tags"]; ok {
+		body["tags"] = toStringSlice(tags)
+	}
+	for key, field := range map[string]string{"time": "time", "time_end": "timeEnd"} {
+		if _, ok := params[key]; !ok {
+			continue
+		}
+		t, err := parseGrafanaTime(params, key)
+		if err != nil {
+			return nil, err
+		}
+		body[field] = t.UnixMilli()
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("nothing to update, set text, tags, time or time_end")
+	}
+
+	if err := client.call(ctx, http.MethodPatch, "/api/annotations/"+url.PathEscape(id), body, nil); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": id, "updated": true}, nil
+}
+
+// snapshot 创建仪表盘快照
+//
+// 快照保存仪表盘当前的定义和时间范围，便于事后复盘；面板数据需要Grafana能够按该时间范围查询。
+func (a *GrafanaAction) snapshot(ctx context.Context, client *grafanaClient, params map[string]interface{}) (map[string]interface{}, error) {
+	uid, _ := params["dashboard_uid"].(string)
+	name, _ := params["name"].(string)
+	expires, _ := params["expires"].(float64)
+	if uid == "" {
+		return nil, fmt.Errorf("dashboard_uid parameter is required for snapshot")
+	}
+
+	var dashboard struct {
+		Dashboard map[string]interface{} `json:"dashboard"`
+	}
+	if err := client.call(ctx, http.MethodGet, "/api/dashboards/uid/"+url.PathEscape(uid), nil, &dashboard); err != nil {
+		return nil, err
+	}
+	if dashboard.Dashboard == nil {
+		return nil, fmt.Errorf("dashboard %s not found", uid)
+	}
+
+	// 覆盖快照的时间范围，如事件发生前后一小时
+	if from, _ := params["from"].(string); from != "" {
+		to, _ := params["to"].(string)
+		if to == "" {
+			to = "now"
+		}
+		dashboard.Dashboard["time"] = map[string]interface{}{"from": from, "to": to}
+	}
+	if name == "" {
+		title, _ := dashboard.Dashboard["title"].(string)
+		name = fmt.Sprintf("%s %s", title, time.Now().UTC().Format(time.RFC3339))
+	}
+
+	body := map[string]interface{}{
+		"dashboard": dashboard.Dashboard,
+		"name":      name,
+	}
+	if expires > 0 {
+		body["expires"] = int64(expires)
+	}
+
+	var resp struct {
+		Key       string `json:"key"`
+		DeleteKey string `json:"deleteKey"`
+		URL       string `json:"url"`
+		DeleteURL string `json:"deleteUrl"`
+		ID        int64  `json:"id"`
+	}
+	if err := client.call(ctx, http.MethodPost, "/api/snapshots", body, &resp); err != nil {
+		return nil, err
+	}
+	a.ctx.Logger.Infof("Grafana snapshot %s created for dashboard %s", resp.Key, uid)
+
+	return map[string]interface{}{
+		"id":         resp.ID,
+		"key":        resp.Key,
+		"url":        resp.URL,
+		"delete_key": resp.DeleteKey,
+		"delete_url": resp.DeleteURL,
+	}, nil
+}
+
+// parseGrafanaTime 解析时间参数，支持RFC3339字符串和毫秒时间戳
+func parseGrafanaTime(params map[string]interface{}, key string) (time.Time, error) {
+	if ms, ok := params[key].(float64); ok {
+		return time.UnixMilli(int64(ms)), nil
+	}
+	return parseCalendarTime(params, key)
+}
@@ -0,0 +1,311 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GRPCClientAction gRPC客户端动作，通过服务端反射或protoset描述文件发起一元调用
+type GRPCClientAction struct {
+	ctx *ActionContext
+}
+
+// NewGRPCClientAction 创建gRPC客户端动作
+func NewGRPCClientAction(ctx *ActionContext) *GRPCClientAction {
+	return &GRPCClientAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *GRPCClientAction) Name() string {
+	return "GRPCClientAction"
+}
+
+// Run 执行gRPC调用
+func (a *GRPCClientAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	target, _ := params["target"].(string)
+	method, _ := params["method"].(string)
+	protoset, _ := params["protoset"].(string)
+	headers, _ := params["metadata"].(map[string]interface{})
+	tokenSecret, _ := params["token_secret"].(string)
+	deadline, _ := params["deadline"].(float64)
+
+	if target == "" || method == "" {
+		return fmt.Errorf("target and method parameters are required")
+	}
+	serviceName, methodName, err := parseGRPCMethod(method)
+	if err != nil {
+		return err
+	}
+	if deadline == 0 {
+		deadline = 30
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(deadline)*time.Second)
+	defer cancel()
+
+	// 建立连接
+	creds, err := a.transportCredentials(ctx, params)
+	if err != nil {
+		return err
+	}
+	conn, err := grpc.DialContext(ctx, target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", target, err)
+	}
+	defer conn.Close()
+
+	// 解析方法描述
+	var files *protoregistry.Files
+	if protoset != "" {
+		files, err = loadProtoset(protoset)
+	} else {
+		files, err = reflectServiceFiles(ctx, conn, serviceName)
+	}
+	if err != nil {
+		return err
+	}
+	desc, err := findGRPCMethod(files, serviceName, methodName)
+	if err != nil {
+		return err
+	}
+
+	// 构建请求消息
+	request := dynamicpb.NewMessage(desc.Input())
+	if body := params["request"]; body != nil {
+		data, ok := body.(string)
+		if !ok {
+			encoded, err := json.Marshal(body)
+			if err != nil {
+				return fmt.Errorf("failed to marshal request: %v", err)
+			}
+			data = string(encoded)
+		}
+		if strings.TrimSpace(data) != "" {
+			if err := protojson.Unmarshal([]byte(data), request); err != nil {
+				return fmt.Errorf("invalid request for %s: %v", desc.Input().FullName(), err)
+			}
+		}
+	}
+
+	// 请求元数据
+	md := metadata.MD{}
+	for key, value := range headers {
+		md.Append(strings.ToLower(key), stringifyValue(value))
+	}
+	if tokenSecret != "" {
+		token, err := a.ctx.getSecret(ctx, tokenSecret)
+		if err != nil {
+			return err
+		}
+		md.Set("authorization", "Bearer "+token)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	a.ctx.Logger.Infof("Calling gRPC method /%s/%s on %s", serviceName, methodName, target)
+
+	response := dynamicpb.NewMessage(desc.Output())
+	var header, trailer metadata.MD
+	fullMethod := "/" + serviceName + "/" + methodName
+	if err := conn.Invoke(ctx, fullMethod, request, response, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
+		st := status.Convert(err)
+		return fmt.Errorf("gRPC call %s failed: code=%s message=%s", fullMethod, st.Code(), st.Message())
+	}
+
+	data, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %v", err)
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(map[string]interface{}{
+		"response": result,
+		"headers":  metadataMap(header),
+		"trailers": metadataMap(trailer),
+	})
+	a.ctx.Logger.Infof("gRPC method %s completed successfully", fullMethod)
+
+	return nil
+}
+
+// transportCredentials 根据tls相关参数构建传输层凭据
+func (a *GRPCClientAction) transportCredentials(ctx context.Context, params map[string]interface{}) (credentials.TransportCredentials, error) {
+	useTLS, _ := params["tls"].(bool)
+	if !useTLS {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig, err := a.ctx.clientTLSConfig(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// parseGRPCMethod 解析方法名，支持 pkg.Service/Method、/pkg.Service/Method 和 pkg.Service.Method
+func parseGRPCMethod(method string) (string, string, error) {
+	method = strings.TrimPrefix(method, "/")
+	index := strings.LastIndex(method, "/")
+	if index < 0 {
+		index = strings.LastIndex(method, ".")
+	}
+	if index <= 0 || index == len(method)-1 {
+		return "", "", fmt.Errorf("invalid gRPC method %s, expected package.Service/Method", method)
+	}
+	return method[:index], method[index+1:], nil
+}
+
+// findGRPCMethod 查找一元方法描述
+func findGRPCMethod(files *protoregistry.Files, serviceName, methodName string) (protoreflect.MethodDescriptor, error) {
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found: %v", serviceName, err)
+	}
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", serviceName)
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("method %s not found in service %s", methodName, serviceName)
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, fmt.Errorf("method %s/%s is streaming, only unary calls are supported", serviceName, methodName)
+	}
+	return method, nil
+}
+
+// loadProtoset 读取 protoc --descriptor_set_out 生成的描述文件
+func loadProtoset(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protoset: %v", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid protoset %s: %v", path, err)
+	}
+	return buildFiles(set.File, nil)
+}
+
+// reflectServiceFiles 通过服务端反射获取服务及其依赖的描述
+func reflectServiceFiles(ctx context.Context, conn *grpc.ClientConn, serviceName string) (*protoregistry.Files, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("server reflection is not available: %v", err)
+	}
+	defer stream.CloseSend()
+
+	request := func(req *rpb.ServerReflectionRequest) ([]*descriptorpb.FileDescriptorProto, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, fmt.Errorf("server reflection request failed: %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("server reflection request failed: %v", err)
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, fmt.Errorf("server reflection error: %s", e.GetErrorMessage())
+		}
+		var files []*descriptorpb.FileDescriptorProto
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, file); err != nil {
+				return nil, fmt.Errorf("invalid file descriptor from server: %v", err)
+			}
+			files = append(files, file)
+		}
+		return files, nil
+	}
+
+	files, err := request(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: serviceName},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 服务端可能只返回部分依赖，按文件名补齐
+	fetch := func(name string) (*descriptorpb.FileDescriptorProto, error) {
+		result, err := request(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range result {
+			if file.GetName() == name {
+				return file, nil
+			}
+		}
+		return nil, fmt.Errorf("server reflection did not return %s", name)
+	}
+	return buildFiles(files, fetch)
+}
+
+// buildFiles 构建描述注册表，缺失的依赖依次从fetch和内置的标准类型中查找
+func buildFiles(files []*descriptorpb.FileDescriptorProto, fetch func(name string) (*descriptorpb.FileDescriptorProto, error)) (*protoregistry.Files, error) {
+	known := make(map[string]bool, len(files))
+	for _, file := range files {
+		known[file.GetName()] = true
+	}
+	for i := 0; i < len(files); i++ {
+		for _, dep := range files[i].GetDependency() {
+			if known[dep] {
+				continue
+			}
+			known[dep] = true
+			if fd, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				files = append(files, protodesc.ToFileDescriptorProto(fd))
+				continue
+			}
+			if fetch == nil {
+				return nil, fmt.Errorf("missing proto dependency %s, build the protoset with --include_imports", dep)
+			}
+			file, err := fetch(dep)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+		}
+	}
+
+	registry, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: files})
+	if err != nil {
+		return nil, fmt.Errorf("invalid proto descriptors: %v", err)
+	}
+	return registry, nil
+}
+
+// metadataMap 将gRPC元数据转换为输出格式，多值以逗号连接
+func metadataMap(md metadata.MD) map[string]string {
+	result := make(map[string]string, len(md))
+	for key, values := range md {
+		result[key] = strings.Join(values, ",")
+	}
+	return result
+}
@@ -0,0 +1,234 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HistoryCollection 实例生命周期事件的集合，只追加不修改
+const HistoryCollection = "instance_history"
+
+// 实例历史事件类型
+const (
+	HistoryCreated      = "created"
+	HistoryTaskStarted  = "task_started"
+	HistoryTaskRetried  = "task_retried"
+	HistoryTaskFinished = "task_finished"
+	HistoryDelayed      = "delayed"
+	HistoryResumed      = "resumed"
+	HistoryRecovered    = "recovered"
+	HistoryRetried      = "retried"
+	HistoryCompleted    = "completed"
+	HistoryFailed       = "failed"
+	HistoryCancelled    = "cancelled"
+	HistoryInterrupted  = "interrupted"
+	HistoryResolved     = "resolved"
+)
+
+// maxHistoryAppendAttempts 序号冲突（其他节点同时追加）时追加事件的最大尝试次数
+const maxHistoryAppendAttempts = 5
+
+// HistoryEvent 实例生命周期中的一个状态转换
+//
+// 实例文档是按最新状态覆盖的视图，历史事件只追加不修改，(instance_id, seq) 唯一：
+// 多个节点同时追加同一实例的事件时序号冲突的一方重新读取序号后追加，不会覆盖其他节点的事件。
+type HistoryEvent struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	InstanceID string             `bson:"instance_id" json:"instance_id"`
+	WorkflowID string             `bson:"workflow_id" json:"workflow_id"`
+	// Seq 实例内从1开始递增的序号
+	Seq  int64  `bson:"seq" json:"seq"`
+	Type string `bson:"type" json:"type"`
+	// Status 事件发生后实例的状态
+	Status string `bson:"status" json:"status"`
+	// NextTask 事件发生时实例的检查点
+	NextTask int    `bson:"next_task" json:"next_task"`
+	TaskID   string `bson:"task_id,omitempty" json:"task_id,omitempty"`
+	// TaskStatus task_finished 事件中任务的结果：success、failed、skipped
+	TaskStatus string `bson:"task_status,omitempty" json:"task_status,omitempty"`
+	// Attempt task_retried 事件中失败的执行次数，task_finished 事件中的总执行次数
+	Attempt  int       `bson:"attempt,omitempty" json:"attempt,omitempty"`
+	ResumeAt time.Time `bson:"resume_at,omitempty" json:"resume_at,omitempty"`
+	Error    string    `bson:"error,omitempty" json:"error,omitempty"`
+	// Resolution 取消或人工处理的说明
+	Resolution string    `bson:"resolution,omitempty" json:"resolution,omitempty"`
+	Node       string    `bson:"node,omitempty" json:"node,omitempty"`
+	Time       time.Time `bson:"time" json:"time"`
+}
+
+// recordHistory 追加实例的历史事件，未设置的状态和检查点取实例当前的值；写入失败时记录日志，不影响实例执行
+func (e *Executor) recordHistory(instance *WorkflowInstance, event HistoryEvent) {
+	event.InstanceID = instance.ID
+	event.WorkflowID = instance.WorkflowID
+	if event.Status == "" {
+		event.Status = instance.Status
+	}
+	event.NextTask = instance.NextTask
+	event.Node = e.cfg.Cluster.NodeID
+	event.Time = time.Now()
+
+	if err := e.appendHistory(event); err != nil {
+		e.logger.Errorf("Failed to record %s history of workflow instance %s: %v", event.Type, instance.ID, err)
+	}
+	switch event.Type {
+	case HistoryCompleted, HistoryFailed, HistoryCancelled, HistoryInterrupted, HistoryResolved:
+		e.historySeqs.Delete(instance.ID)
+	}
+}
+
+// appendHistory 以下一个序号插入事件，序号已被其他节点占用时重新读取最后的序号后重试
+//
+// 当前节点追加过的实例使用内存中的序号，其他实例（恢复、接管、重试后）读取最大序号。
+func (e *Executor) appendHistory(event HistoryEvent) error {
+	collection := e.mongoDB.GetDatabase().Collection(HistoryCollection)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt < maxHistoryAppendAttempts; attempt++ {
+		var last int64
+		if cached, ok := e.historySeqs.Load(event.InstanceID); ok {
+			last = cached.(int64)
+		} else if last, err = e.lastHistorySeq(ctx, event.InstanceID); err != nil {
+			return err
+		}
+		event.ID = primitive.NewObjectID()
+		event.Seq = last + 1
+		if _, err = collection.InsertOne(ctx, event); err == nil {
+			e.historySeqs.Store(event.InstanceID, event.Seq)
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		e.historySeqs.Delete(event.InstanceID)
+	}
+	return err
+}
+
+// lastHistorySeq 读取实例最后的事件序号，没有事件时为0
+func (e *Executor) lastHistorySeq(ctx context.Context, instanceID string) (int64, error) {
+	var last struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}}).SetProjection(bson.M{"seq": 1})
+	err := e.mongoDB.GetDatabase().Collection(HistoryCollection).FindOne(ctx, bson.M{"instance_id": instanceID}, opts).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+	return last.Seq, nil
+}
+
+// InstanceHistory 按序号读取实例的历史事件
+func (e *Executor) InstanceHistory(ctx context.Context, instanceID string) ([]HistoryEvent, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}})
+	cursor, err := e.mongoDB.GetDatabase().Collection(HistoryCollection).Find(ctx, bson.M{"instance_id": instanceID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := []HistoryEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// TaskState 重放历史事件得到的任务状态
+type TaskState struct {
+	TaskID string `json:"task_id"`
+	// Status running、success、failed、skipped
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// InstanceState 重放历史事件得到的实例状态
+type InstanceState struct {
+	Status     string     `json:"status"`
+	NextTask   int        `json:"next_task"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	ResumeAt   *time.Time `json:"resume_at,omitempty"`
+	Node       string     `json:"node,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+	// Tasks 按第一次开始的顺序排列，重试实例时重新执行的任务覆盖之前的状态
+	Tasks []*TaskState `json:"tasks"`
+	// Version 最后一个事件的序号
+	Version int64 `json:"version"`
+	// Gaps 缺失的序号（追加失败或按保留策略删除），不为空时重放的状态可能不完整
+	Gaps []int64 `json:"gaps,omitempty"`
+}
+
+// ReplayHistory 按序号依次应用历史事件，重建实例的状态
+func ReplayHistory(events []HistoryEvent) *InstanceState {
+	state := &InstanceState{Tasks: []*TaskState{}}
+	tasks := make(map[string]*TaskState)
+	task := func(id string) *TaskState {
+		t, ok := tasks[id]
+		if !ok {
+			t = &TaskState{TaskID: id}
+			tasks[id] = t
+			state.Tasks = append(state.Tasks, t)
+		}
+		return t
+	}
+
+	for _, event := range events {
+		for seq := state.Version + 1; seq < event.Seq; seq++ {
+			state.Gaps = append(state.Gaps, seq)
+		}
+		state.Version = event.Seq
+		state.Status = event.Status
+		state.NextTask = event.NextTask
+		if event.Node != "" {
+			state.Node = event.Node
+		}
+		at := event.Time
+
+		switch event.Type {
+		case HistoryCreated:
+			state.CreatedAt = &at
+			if !event.ResumeAt.IsZero() {
+				resumeAt := event.ResumeAt
+				state.ResumeAt = &resumeAt
+			}
+		case HistoryTaskStarted:
+			t := task(event.TaskID)
+			*t = TaskState{TaskID: event.TaskID, Status: "running", StartedAt: &at}
+		case HistoryTaskRetried:
+			t := task(event.TaskID)
+			t.Attempts = event.Attempt
+			t.Error = event.Error
+		case HistoryTaskFinished:
+			t := task(event.TaskID)
+			t.Status = event.TaskStatus
+			t.Attempts = event.Attempt
+			t.Error = event.Error
+			t.FinishedAt = &at
+		case HistoryDelayed:
+			resumeAt := event.ResumeAt
+			state.ResumeAt = &resumeAt
+		case HistoryResumed, HistoryRecovered, HistoryRetried:
+			state.ResumeAt = nil
+			state.EndedAt = nil
+			state.Error = ""
+			state.Resolution = ""
+		case HistoryCompleted, HistoryFailed, HistoryCancelled, HistoryInterrupted:
+			state.EndedAt = &at
+			state.ResumeAt = nil
+			state.Error = event.Error
+			state.Resolution = event.Resolution
+		case HistoryResolved:
+			state.Resolution = event.Resolution
+		}
+	}
+	return state
+}
@@ -0,0 +1,728 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaesslerAG/jsonpath"
+
+	"nsa/internal/logger"
+)
+
+// defaultHTTPRetryStatus 未配置 status_codes 时重试的状态码
+var defaultHTTPRetryStatus = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// quoteEscaper 转义 Content-Disposition 中的引号和反斜杠
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// httpRetryPolicy HTTP请求级重试策略，与任务级重试相互独立
+type httpRetryPolicy struct {
+	maxTimes    int
+	interval    time.Duration
+	maxInterval time.Duration
+	statusCodes map[int]bool
+}
+
+// httpFile multipart请求中的文件
+type httpFile struct {
+	field       string
+	filename    string
+	contentType string
+	content     []byte
+}
+
+// tlsMaterial TLS参数及从密钥读取的证书内容
+type tlsMaterial struct {
+	serverName string
+	skipVerify bool
+	caSecret   string
+	ca         string
+	cert       string
+	key        string
+}
+
+// loadTLSMaterial 读取 server_name、insecure_skip_verify、ca_cert_secret、client_cert_secret、client_key_secret 参数及引用的密钥
+func (c *ActionContext) loadTLSMaterial(ctx context.Context, params map[string]interface{}) (*tlsMaterial, error) {
+	material := &tlsMaterial{}
+	material.serverName, _ = params["server_name"].(string)
+	material.skipVerify, _ = params["insecure_skip_verify"].(bool)
+	material.caSecret, _ = params["ca_cert_secret"].(string)
+	certSecret, _ := params["client_cert_secret"].(string)
+	keySecret, _ := params["client_key_secret"].(string)
+
+	var err error
+	if material.caSecret != "" {
+		if material.ca, err = c.getSecret(ctx, material.caSecret); err != nil {
+			return nil, err
+		}
+	}
+	if certSecret != "" || keySecret != "" {
+		if certSecret == "" || keySecret == "" {
+			return nil, fmt.Errorf("client_cert_secret and client_key_secret must be set together")
+		}
+		if material.cert, err = c.getSecret(ctx, certSecret); err != nil {
+			return nil, err
+		}
+		if material.key, err = c.getSecret(ctx, keySecret); err != nil {
+			return nil, err
+		}
+	}
+	return material, nil
+}
+
+// config 构建TLS配置
+func (m *tlsMaterial) config() (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: m.serverName, InsecureSkipVerify: m.skipVerify}
+	if m.ca != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(m.ca)) {
+			return nil, fmt.Errorf("no valid certificates in secret %s", m.caSecret)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if m.cert != "" {
+		pair, err := tls.X509KeyPair([]byte(m.cert), []byte(m.key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+	return tlsConfig, nil
+}
+
+// clientTLSConfig 根据 server_name、insecure_skip_verify、ca_cert_secret、client_cert_secret、client_key_secret 参数构建TLS配置
+func (c *ActionContext) clientTLSConfig(ctx context.Context, params map[string]interface{}) (*tls.Config, error) {
+	material, err := c.loadTLSMaterial(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return material.config()
+}
+
+// httpClient 返回使用共享传输层的客户端，配置了代理或TLS参数时按参数内容复用对应的传输层
+func (a *HTTPClientAction) httpClient(ctx context.Context, params map[string]interface{}, timeout time.Duration) (*http.Client, error) {
+	proxy, _ := params["proxy"].(string)
+	customTLS := false
+	for _, key := range []string{"server_name", "insecure_skip_verify", "ca_cert_secret", "client_cert_secret", "client_key_secret"} {
+		if _, ok := params[key]; ok {
+			customTLS = true
+		}
+	}
+
+	var proxyURL *url.URL
+	if proxy != "" {
+		var err error
+		proxyURL, err = url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %s", proxy)
+		}
+		// 代理密码从密钥读取，不写在工作流配置中
+		if passwordSecret, _ := params["proxy_password_secret"].(string); passwordSecret != "" {
+			password, err := a.ctx.getSecret(ctx, passwordSecret)
+			if err != nil {
+				return nil, err
+			}
+			username, _ := params["proxy_username"].(string)
+			proxyURL.User = url.UserPassword(username, password)
+		}
+	}
+	var material *tlsMaterial
+	if customTLS {
+		var err error
+		if material, err = a.ctx.loadTLSMaterial(ctx, params); err != nil {
+			return nil, err
+		}
+	}
+
+	transport, err := a.ctx.HTTPTransports.get(transportKey(proxyURL, material), func(transport *http.Transport) error {
+		if proxyURL != nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+		if material != nil {
+			tlsConfig, err := material.config()
+			if err != nil {
+				return err
+			}
+			transport.TLSClientConfig = tlsConfig
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// transportKey 根据代理和TLS参数计算传输层的缓存键，都未配置时为空
+func transportKey(proxyURL *url.URL, material *tlsMaterial) string {
+	if proxyURL == nil && material == nil {
+		return ""
+	}
+	hash := sha256.New()
+	if proxyURL != nil {
+		hash.Write([]byte(proxyURL.String()))
+	}
+	if material != nil {
+		fmt.Fprintf(hash, "\x00%s\x00%t\x00%s\x00%s\x00%s", material.serverName, material.skipVerify, material.ca, material.cert, material.key)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// setAuth 设置认证头：username + password_secret（Basic）或 token_secret（Bearer）
+func (a *HTTPClientAction) setAuth(ctx context.Context, params map[string]interface{}, header http.Header) error {
+	username, _ := params["username"].(string)
+	passwordSecret, _ := params["password_secret"].(string)
+	tokenSecret, _ := params["token_secret"].(string)
+
+	switch {
+	case username != "" && passwordSecret != "":
+		password, err := a.ctx.getSecret(ctx, passwordSecret)
+		if err != nil {
+			return err
+		}
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	case tokenSecret != "":
+		token, err := a.ctx.getSecret(ctx, tokenSecret)
+		if err != nil {
+			return err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	case username != "" || passwordSecret != "":
+		return fmt.Errorf("username and password_secret must be set together")
+	}
+	return nil
+}
+
+// withQuery 将查询参数追加到URL，数组值展开为同名的多个参数
+func withQuery(rawURL string, query map[string]interface{}) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %s: %v", rawURL, err)
+	}
+	values := u.Query()
+	for key, value := range query {
+		addFormValue(values, key, value)
+	}
+	u.RawQuery = values.Encode()
+	return u.String(), nil
+}
+
+// addFormValue 添加表单或查询参数，nil值忽略
+func addFormValue(values url.Values, key string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range v {
+			values.Add(key, stringifyValue(item))
+		}
+	default:
+		values.Add(key, stringifyValue(v))
+	}
+}
+
+// encodeHTTPBody 按 body_type 编码请求体，返回请求体和Content-Type（为空时不设置）
+//
+// json为默认格式；form编码为 application/x-www-form-urlencoded；multipart的body为普通字段，files为文件；raw原样发送字符串。
+func encodeHTTPBody(bodyType string, body interface{}, files []httpFile) ([]byte, string, error) {
+	switch bodyType {
+	case "", "json":
+		if body == nil {
+			return nil, "", nil
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to marshal request body: %v", err)
+		}
+		return data, "application/json", nil
+	case "form":
+		fields, ok := body.(map[string]interface{})
+		if !ok && body != nil {
+			return nil, "", fmt.Errorf("body must be an object for form requests")
+		}
+		values := url.Values{}
+		for key, value := range fields {
+			addFormValue(values, key, value)
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	case "multipart":
+		fields, ok := body.(map[string]interface{})
+		if !ok && body != nil {
+			return nil, "", fmt.Errorf("body must be an object for multipart requests")
+		}
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		for key, value := range fields {
+			values := url.Values{}
+			addFormValue(values, key, value)
+			for _, item := range values[key] {
+				if err := writer.WriteField(key, item); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+		for _, file := range files {
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(file.field), quoteEscaper.Replace(file.filename)))
+			header.Set("Content-Type", file.contentType)
+			part, err := writer.CreatePart(header)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write(file.content); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), writer.FormDataContentType(), nil
+	case "raw":
+		if body == nil {
+			return nil, "", nil
+		}
+		return []byte(stringifyValue(body)), "text/plain; charset=utf-8", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported body_type %s, expected json, form, multipart or raw", bodyType)
+	}
+}
+
+// parseHTTPFiles 解析multipart文件参数，content为文本内容，content_base64为二进制内容
+func parseHTTPFiles(value interface{}) ([]httpFile, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("files must be an array")
+	}
+
+	files := make([]httpFile, 0, len(items))
+	for i, item := range items {
+		spec, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("file %d must be an object", i)
+		}
+		file := httpFile{}
+		file.field, _ = spec["field"].(string)
+		file.filename, _ = spec["filename"].(string)
+		file.contentType, _ = spec["content_type"].(string)
+		if file.field == "" {
+			file.field = "file"
+		}
+		if file.filename == "" {
+			return nil, fmt.Errorf("filename is required for file %d", i)
+		}
+		if file.contentType == "" {
+			file.contentType = "application/octet-stream"
+		}
+		if encoded, _ := spec["content_base64"].(string); encoded != "" {
+			content, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid content_base64 for file %d: %v", i, err)
+			}
+			file.content = content
+		} else {
+			file.content = []byte(stringifyValue(spec["content"]))
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// parseHTTPRetry 解析 retry 参数：max_times 最大重试次数，interval 首次等待秒数（默认1，之后翻倍），
+// max_interval 最长等待秒数（默认30），status_codes 需要重试的状态码（默认429、502、503、504）
+func parseHTTPRetry(value interface{}) (*httpRetryPolicy, error) {
+	if value == nil {
+		return nil, nil
+	}
+	spec, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("retry must be an object")
+	}
+
+	maxTimes, _ := spec["max_times"].(float64)
+	interval, _ := spec["interval"].(float64)
+	maxInterval, _ := spec["max_interval"].(float64)
+	if maxTimes <= 0 {
+		return nil, nil
+	}
+	if interval <= 0 {
+		interval = 1
+	}
+	if maxInterval <= 0 {
+		maxInterval = 30
+	}
+
+	policy := &httpRetryPolicy{
+		maxTimes:    int(maxTimes),
+		interval:    time.Duration(interval * float64(time.Second)),
+		maxInterval: time.Duration(maxInterval * float64(time.Second)),
+		statusCodes: make(map[int]bool),
+	}
+	if codes, ok := spec["status_codes"].([]interface{}); ok {
+		for _, code := range codes {
+			n, ok := code.(float64)
+			if !ok {
+				return nil, fmt.Errorf("retry status_codes must be numbers")
+			}
+			policy.statusCodes[int(n)] = true
+		}
+	} else {
+		for _, code := range defaultHTTPRetryStatus {
+			policy.statusCodes[code] = true
+		}
+	}
+	return policy, nil
+}
+
+// wait 返回第attempt次重试前的等待时间，优先使用响应的 Retry-After，均不超过 max_interval
+func (p *httpRetryPolicy) wait(attempt int, resp *http.Response) time.Duration {
+	wait := p.interval
+	for i := 1; i < attempt && wait < p.maxInterval; i++ {
+		wait *= 2
+	}
+	if resp != nil {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+				wait = time.Duration(seconds) * time.Second
+			} else if at, err := http.ParseTime(retryAfter); err == nil {
+				wait = time.Until(at)
+			}
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > p.maxInterval {
+		wait = p.maxInterval
+	}
+	return wait
+}
+
+// doHTTPWithRetry 发送请求并读取响应体，按重试策略重试网络错误和指定状态码，返回响应、响应体和请求次数
+func doHTTPWithRetry(ctx context.Context, client *http.Client, method, target string, header http.Header, body []byte, policy *httpRetryPolicy, log logger.Logger) (*http.Response, []byte, int, error) {
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, reader)
+		if err != nil {
+			return nil, nil, attempt, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header = header.Clone()
+
+		var respBody []byte
+		resp, err := client.Do(req)
+		if err == nil {
+			respBody, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				err = fmt.Errorf("failed to read response: %v", err)
+			}
+		} else {
+			err = fmt.Errorf("failed to execute request: %v", err)
+		}
+
+		retryable := err != nil || policy != nil && policy.statusCodes[resp.StatusCode]
+		if !retryable || policy == nil || attempt > policy.maxTimes || ctx.Err() != nil {
+			return resp, respBody, attempt, err
+		}
+
+		wait := policy.wait(attempt, resp)
+		if err != nil {
+			log.Warnf("HTTP request %s %s failed, retrying in %v: %v", method, target, wait, err)
+		} else {
+			log.Warnf("HTTP request %s %s returned status %d, retrying in %v", method, target, resp.StatusCode, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, respBody, attempt, err
+		case <-timer.C:
+		}
+	}
+}
+
+// httpStatusRange 期望的状态码范围（闭区间）
+type httpStatusRange struct {
+	min, max int
+}
+
+// httpAssertion 响应体断言，path为JSONPath表达式，checks为操作符到期望值的映射
+type httpAssertion struct {
+	path    string
+	message string
+	checks  map[string]interface{}
+	pattern *regexp.Regexp
+}
+
+// httpAssertionOperators 支持的断言操作符，按此顺序检查
+var httpAssertionOperators = []string{"exists", "equals", "not_equals", "contains", "matches", "gt", "gte", "lt", "lte", "length"}
+
+// httpAssertionError 响应不符合 expect_status 或 assertions，目标服务可用，不计入熔断
+type httpAssertionError struct {
+	message string
+}
+
+// Error 实现error接口
+func (e *httpAssertionError) Error() string {
+	return e.message
+}
+
+// parseExpectStatus 解析 expect_status 参数：数字、"2xx" 形式的类别、"200-299" 形式的范围，或它们的数组
+func parseExpectStatus(value interface{}) ([]httpStatusRange, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+
+	ranges := make([]httpStatusRange, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case float64:
+			ranges = append(ranges, httpStatusRange{int(v), int(v)})
+		case string:
+			spec := strings.ToLower(strings.TrimSpace(v))
+			if len(spec) == 3 && strings.HasSuffix(spec, "xx") && spec[0] >= '1' && spec[0] <= '5' {
+				base := int(spec[0]-'0') * 100
+				ranges = append(ranges, httpStatusRange{base, base + 99})
+				continue
+			}
+			low, high, isRange := strings.Cut(spec, "-")
+			from, err := strconv.Atoi(strings.TrimSpace(low))
+			if err != nil {
+				return nil, fmt.Errorf("invalid expect_status %q", v)
+			}
+			to := from
+			if isRange {
+				if to, err = strconv.Atoi(strings.TrimSpace(high)); err != nil || to < from {
+					return nil, fmt.Errorf("invalid expect_status %q", v)
+				}
+			}
+			ranges = append(ranges, httpStatusRange{from, to})
+		default:
+			return nil, fmt.Errorf("expect_status must be a status code, class like 2xx or range like 200-299")
+		}
+	}
+	return ranges, nil
+}
+
+// statusExpected 判断状态码是否在期望范围内
+func statusExpected(ranges []httpStatusRange, statusCode int) bool {
+	for _, r := range ranges {
+		if statusCode >= r.min && statusCode <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// formatStatusRanges 将期望范围格式化为错误信息
+func formatStatusRanges(ranges []httpStatusRange) string {
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r.min == r.max {
+			parts = append(parts, strconv.Itoa(r.min))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.min, r.max))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseHTTPAssertions 解析 assertions 参数，每个断言包含 path 和一个或多个操作符，没有操作符时检查路径存在
+func parseHTTPAssertions(value interface{}) ([]httpAssertion, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("assertions must be an array")
+	}
+
+	assertions := make([]httpAssertion, 0, len(items))
+	for i, item := range items {
+		spec, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("assertion %d must be an object", i)
+		}
+		assertion := httpAssertion{checks: make(map[string]interface{})}
+		assertion.path, _ = spec["path"].(string)
+		assertion.message, _ = spec["message"].(string)
+		if assertion.path == "" {
+			return nil, fmt.Errorf("path is required for assertion %d", i)
+		}
+		for _, op := range httpAssertionOperators {
+			if expected, ok := spec[op]; ok {
+				assertion.checks[op] = expected
+			}
+		}
+		if len(assertion.checks) == 0 {
+			assertion.checks["exists"] = true
+		}
+		if exists, ok := assertion.checks["exists"]; ok {
+			if _, ok := exists.(bool); !ok {
+				return nil, fmt.Errorf("exists must be a boolean in assertion %d", i)
+			}
+		}
+		if pattern, ok := assertion.checks["matches"]; ok {
+			re, err := regexp.Compile(stringifyValue(pattern))
+			if err != nil {
+				return nil, fmt.Errorf("invalid matches pattern in assertion %d: %v", i, err)
+			}
+			assertion.pattern = re
+		}
+		for _, op := range []string{"gt", "gte", "lt", "lte", "length"} {
+			if expected, ok := assertion.checks[op]; ok {
+				if _, ok := toNumber(expected); !ok {
+					return nil, fmt.Errorf("%s must be a number in assertion %d", op, i)
+				}
+			}
+		}
+		assertions = append(assertions, assertion)
+	}
+	return assertions, nil
+}
+
+// checkHTTPAssertions 对JSON响应体执行断言，返回所有不满足的断言说明
+func checkHTTPAssertions(assertions []httpAssertion, respBody []byte) []string {
+	var data interface{}
+	if err := json.Unmarshal(respBody, &data); err != nil {
+		return []string{"response body is not valid JSON"}
+	}
+
+	var failures []string
+	for i, assertion := range assertions {
+		actual, err := jsonpath.Get(assertion.path, data)
+		exists := err == nil
+		if err != nil && !isJSONPathMissing(err) {
+			failures = append(failures, fmt.Sprintf("assertion %d: %s: %v", i, assertion.path, err))
+			continue
+		}
+		for _, op := range httpAssertionOperators {
+			expected, ok := assertion.checks[op]
+			if !ok {
+				continue
+			}
+			if op != "exists" && !exists {
+				failures = append(failures, assertionFailure(i, assertion, fmt.Sprintf("%s does not exist", assertion.path)))
+				break
+			}
+			if !assertionHolds(op, assertion, actual, exists, expected) {
+				failures = append(failures, assertionFailure(i, assertion, fmt.Sprintf("expected %s %s %s, got %s", assertion.path, op, formatAssertionValue(expected), formatAssertionValue(actual))))
+			}
+		}
+	}
+	return failures
+}
+
+// assertionHolds 检查单个操作符是否满足
+func assertionHolds(op string, assertion httpAssertion, actual interface{}, exists bool, expected interface{}) bool {
+	switch op {
+	case "exists":
+		return exists == expected.(bool)
+	case "equals":
+		return assertionEqual(actual, expected)
+	case "not_equals":
+		return !assertionEqual(actual, expected)
+	case "contains":
+		switch v := actual.(type) {
+		case string:
+			return strings.Contains(v, stringifyValue(expected))
+		case []interface{}:
+			for _, item := range v {
+				if assertionEqual(item, expected) {
+					return true
+				}
+			}
+		case map[string]interface{}:
+			_, ok := v[stringifyValue(expected)]
+			return ok
+		}
+		return false
+	case "matches":
+		return actual != nil && assertion.pattern.MatchString(stringifyValue(actual))
+	case "length":
+		want, _ := toNumber(expected)
+		switch v := actual.(type) {
+		case string:
+			return float64(len([]rune(v))) == want
+		case []interface{}:
+			return float64(len(v)) == want
+		case map[string]interface{}:
+			return float64(len(v)) == want
+		}
+		return false
+	default:
+		got, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		want, _ := toNumber(expected)
+		switch op {
+		case "gt":
+			return got > want
+		case "gte":
+			return got >= want
+		case "lt":
+			return got < want
+		default:
+			return got <= want
+		}
+	}
+}
+
+// assertionEqual 比较响应值和期望值，数字与数字字符串按数值比较（期望值可能来自模板渲染）
+func assertionEqual(actual, expected interface{}) bool {
+	if got, ok := actual.(float64); ok {
+		want, ok := toNumber(expected)
+		return ok && got == want
+	}
+	normalized, err := normalizeJSON(expected)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(actual, normalized)
+}
+
+// assertionFailure 生成断言失败说明，配置了 message 时附加在前面
+func assertionFailure(index int, assertion httpAssertion, detail string) string {
+	if assertion.message != "" {
+		return fmt.Sprintf("assertion %d (%s): %s", index, assertion.message, detail)
+	}
+	return fmt.Sprintf("assertion %d: %s", index, detail)
+}
+
+// formatAssertionValue 将值格式化为JSON，用于错误信息
+func formatAssertionValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	if len(data) > 200 {
+		return string(data[:200]) + "..."
+	}
+	return string(data)
+}
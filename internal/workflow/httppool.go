@@ -0,0 +1,114 @@
+package workflow
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+)
+
+// httpTransportPool HTTP节点共享的传输层，所有实例的请求复用连接
+//
+// 未配置代理和TLS参数的请求共用默认传输层；配置了的按参数内容（包括密钥值）区分，
+// 密钥轮换后自动使用新的传输层。缓存的传输层超过 max_transports 时关闭最久未使用的。
+type httpTransportPool struct {
+	cfg        config.HTTPClientConfig
+	base       *http.Transport
+	mu         sync.Mutex
+	transports map[string]*pooledTransport
+}
+
+// pooledTransport 缓存的传输层
+type pooledTransport struct {
+	transport *http.Transport
+	lastUsed  time.Time
+}
+
+// newHTTPTransportPool 创建传输层池
+func newHTTPTransportPool(cfg config.HTTPClientConfig) *httpTransportPool {
+	pool := &httpTransportPool{
+		cfg:        cfg,
+		transports: make(map[string]*pooledTransport),
+	}
+	pool.base = pool.newTransport()
+	return pool
+}
+
+// newTransport 按连接池配置创建传输层
+func (p *httpTransportPool) newTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: time.Duration(p.cfg.KeepAlive) * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     !p.cfg.DisableHTTP2,
+		MaxIdleConns:          p.cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   p.cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       p.cfg.MaxConnsPerHost,
+		IdleConnTimeout:       time.Duration(p.cfg.IdleConnTimeout) * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if p.cfg.DisableHTTP2 {
+		// TLSNextProto 为非nil的空映射时不协商HTTP/2
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return transport
+}
+
+// get 返回key对应的传输层，不存在时创建并由configure设置代理和TLS；key为空时返回默认传输层
+func (p *httpTransportPool) get(key string, configure func(*http.Transport) error) (*http.Transport, error) {
+	if key == "" {
+		return p.base, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pooled, ok := p.transports[key]; ok {
+		pooled.lastUsed = time.Now()
+		return pooled.transport, nil
+	}
+
+	transport := p.newTransport()
+	if err := configure(transport); err != nil {
+		return nil, err
+	}
+	if p.cfg.MaxTransports > 0 && len(p.transports) >= p.cfg.MaxTransports {
+		p.evictOldest()
+	}
+	p.transports[key] = &pooledTransport{transport: transport, lastUsed: time.Now()}
+	return transport, nil
+}
+
+// evictOldest 关闭并移除最久未使用的传输层，正在进行的请求不受影响
+func (p *httpTransportPool) evictOldest() {
+	var oldestKey string
+	var oldest *pooledTransport
+	for key, pooled := range p.transports {
+		if oldest == nil || pooled.lastUsed.Before(oldest.lastUsed) {
+			oldestKey, oldest = key, pooled
+		}
+	}
+	if oldest != nil {
+		oldest.transport.CloseIdleConnections()
+		delete(p.transports, oldestKey)
+	}
+}
+
+// close 关闭所有空闲连接
+func (p *httpTransportPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.base.CloseIdleConnections()
+	for key, pooled := range p.transports {
+		pooled.transport.CloseIdleConnections()
+		delete(p.transports, key)
+	}
+}
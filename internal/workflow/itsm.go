@@ -0,0 +1,446 @@
+package workflow
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// JiraAction Jira工单动作（创建、更新、流转、评论）
+type JiraAction struct {
+	ctx *ActionContext
+}
+
+// NewJiraAction 创建Jira工单动作
+func NewJiraAction(ctx *ActionContext) *JiraAction {
+	return &JiraAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *JiraAction) Name() string {
+	return "JiraAction"
+}
+
+// Run 执行Jira操作
+func (a *JiraAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := taskCtx.GetParams()
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	baseURL, _ := params["base_url"].(string)
+	issueKey, _ := params["issue_key"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if baseURL == "" {
+		return fmt.Errorf("base_url parameter is required")
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+	baseURL = strings.TrimRight(baseURL, "/") + "/rest/api/2"
+	issueKey = renderTemplate(issueKey, taskCtx)
+
+	header, err := a.authHeader(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	client := &itsmClient{header: header, timeout: time.Duration(timeout) * time.Second}
+	a.ctx.Logger.Infof("Executing Jira operation %s", operation)
+
+	var result map[string]interface{}
+	switch operation {
+	case "create":
+		result, err = a.create(ctx, client, baseURL, params, taskCtx)
+	case "update":
+		result, err = a.update(ctx, client, baseURL, issueKey, params, taskCtx)
+	case "transition":
+		result, err = a.transition(ctx, client, baseURL, issueKey, params, taskCtx)
+	case "comment":
+		result, err = a.comment(ctx, client, baseURL, issueKey, params, taskCtx)
+	default:
+		return fmt.Errorf("unsupported jira operation: %s", operation)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Jira operation %s completed successfully", operation)
+
+	return nil
+}
+
+// authHeader 构建认证头：email + api_token_secret（Jira Cloud）或 token_secret（Server/DC个人访问令牌）
+func (a *JiraAction) authHeader(ctx context.Context, params map[string]interface{}) (http.Header, error) {
+	email, _ := params["email"].(string)
+	apiTokenSecret, _ := params["api_token_secret"].(string)
+	tokenSecret, _ := params["token_secret"].(string)
+
+	header := http.Header{}
+	switch {
+	case email != "" && apiTokenSecret != "":
+		token, err := a.ctx.getSecret(ctx, apiTokenSecret)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(email+":"+token)))
+	case tokenSecret != "":
+		token, err := a.ctx.getSecret(ctx, tokenSecret)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	default:
+		return nil, fmt.Errorf("email and api_token_secret, or token_secret parameter is required")
+	}
+	return header, nil
+}
+
+// issueFields 合并常用字段与自定义字段映射
+func (a *JiraAction) issueFields(params map[string]interface{}, taskCtx *TaskContext) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if custom, ok := resolveValue(params["fields"], taskCtx).(map[string]interface{}); ok {
+		for key, value := range custom {
+			fields[key] = value
+		}
+	}
+
+	if project, _ := params["project"].(string); project != "" {
+		fields["project"] = map[string]interface{}{"key": renderTemplate(project, taskCtx)}
+	}
+	if issueType, _ := params["issue_type"].(string); issueType != "" {
+		fields["issuetype"] = map[string]interface{}{"name": renderTemplate(issueType, taskCtx)}
+	}
+	if summary, _ := params["summary"].(string); summary != "" {
+		fields["summary"] = renderTemplate(summary, taskCtx)
+	}
+	if description, _ := params["description"].(string); description != "" {
+		fields["description"] = renderTemplate(description, taskCtx)
+	}
+	if priority, _ := params["priority"].(string); priority != "" {
+		fields["priority"] = map[string]interface{}{"name": renderTemplate(priority, taskCtx)}
+	}
+	if labels, ok := params["labels"].([]interface{}); ok {
+		fields["labels"] = renderValue(labels, taskCtx)
+	}
+	return fields
+}
+
+// create 创建工单
+func (a *JiraAction) create(ctx context.Context, client *itsmClient, baseURL string, params map[string]interface{}, taskCtx *TaskContext) (map[string]interface{}, error) {
+	fields := a.issueFields(params, taskCtx)
+	if fields["project"] == nil || fields["summary"] == nil {
+		return nil, fmt.Errorf("project and summary parameters are required for create")
+	}
+	if fields["issuetype"] == nil {
+		fields["issuetype"] = map[string]interface{}{"name": "Task"}
+	}
+
+	var created struct {
+		ID   string `json:"id"`
+		Key  string `json:"key"`
+		Self string `json:"self"`
+	}
+	if err := client.call(ctx, http.MethodPost, baseURL+"/issue", map[string]interface{}{"fields": fields}, &created); err != nil {
+		return nil, fmt.Errorf("failed to create jira issue: %v", err)
+	}
+
+	return map[string]interface{}{
+		"id":        created.ID,
+		"issue_key": created.Key,
+		"url":       strings.TrimSuffix(baseURL, "/rest/api/2") + "/browse/" + created.Key,
+	}, nil
+}
+
+// update 更新工单字段
+func (a *JiraAction) update(ctx context.Context, client *itsmClient, baseURL, issueKey string, params map[string]interface{}, taskCtx *TaskContext) (map[string]interface{}, error) {
+	if issueKey == "" {
+		return nil, fmt.Errorf("issue_key parameter is required for update")
+	}
+	fields := a.issueFields(params, taskCtx)
+	delete(fields, "project")
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	if err := client.call(ctx, http.MethodPut, baseURL+"/issue/"+url.PathEscape(issueKey), map[string]interface{}{"fields": fields}, nil); err != nil {
+		return nil, fmt.Errorf("failed to update jira issue %s: %v", issueKey, err)
+	}
+
+	return map[string]interface{}{"issue_key": issueKey, "updated": true}, nil
+}
+
+// transition 按名称或ID流转工单状态
+func (a *JiraAction) transition(ctx context.Context, client *itsmClient, baseURL, issueKey string, params map[string]interface{}, taskCtx *TaskContext) (map[string]interface{}, error) {
+	target, _ := params["transition"].(string)
+	target = renderTemplate(target, taskCtx)
+	if issueKey == "" || target == "" {
+		return nil, fmt.Errorf("issue_key and transition parameters are required for transition")
+	}
+
+	issuePath := baseURL + "/issue/" + url.PathEscape(issueKey) + "/transitions"
+
+	// 查询可用的流转
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := client.call(ctx, http.MethodGet, issuePath, nil, &available); err != nil {
+		return nil, fmt.Errorf("failed to get jira transitions: %v", err)
+	}
+
+	transitionID, status := "", ""
+	for _, t := range available.Transitions {
+		if t.ID == target || strings.EqualFold(t.Name, target) || strings.EqualFold(t.To.Name, target) {
+			transitionID, status = t.ID, t.To.Name
+			break
+		}
+	}
+	if transitionID == "" {
+		var names []string
+		for _, t := range available.Transitions {
+			names = append(names, t.Name)
+		}
+		return nil, fmt.Errorf("transition %s not available for %s (available: %s)", target, issueKey, strings.Join(names, ", "))
+	}
+
+	body := map[string]interface{}{"transition": map[string]interface{}{"id": transitionID}}
+	if fields, ok := resolveValue(params["fields"], taskCtx).(map[string]interface{}); ok && len(fields) > 0 {
+		body["fields"] = fields
+	}
+	if comment, _ := params["comment"].(string); comment != "" {
+		body["update"] = map[string]interface{}{
+			"comment": []interface{}{map[string]interface{}{"add": map[string]interface{}{"body": renderTemplate(comment, taskCtx)}}},
+		}
+	}
+
+	if err := client.call(ctx, http.MethodPost, issuePath, body, nil); err != nil {
+		return nil, fmt.Errorf("failed to transition jira issue %s: %v", issueKey, err)
+	}
+
+	return map[string]interface{}{
+		"issue_key":     issueKey,
+		"transition_id": transitionID,
+		"status":        status,
+	}, nil
+}
+
+// comment 添加评论
+func (a *JiraAction) comment(ctx context.Context, client *itsmClient, baseURL, issueKey string, params map[string]interface{}, taskCtx *TaskContext) (map[string]interface{}, error) {
+	comment, _ := params["comment"].(string)
+	if issueKey == "" || comment == "" {
+		return nil, fmt.Errorf("issue_key and comment parameters are required for comment")
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	body := map[string]interface{}{"body": renderTemplate(comment, taskCtx)}
+	if err := client.call(ctx, http.MethodPost, baseURL+"/issue/"+url.PathEscape(issueKey)+"/comment", body, &created); err != nil {
+		return nil, fmt.Errorf("failed to comment on jira issue %s: %v", issueKey, err)
+	}
+
+	return map[string]interface{}{"issue_key": issueKey, "comment_id": created.ID}, nil
+}
+
+// ServiceNowAction ServiceNow工单动作（创建、更新、流转）
+type ServiceNowAction struct {
+	ctx *ActionContext
+}
+
+// NewServiceNowAction 创建ServiceNow工单动作
+func NewServiceNowAction(ctx *ActionContext) *ServiceNowAction {
+	return &ServiceNowAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *ServiceNowAction) Name() string {
+	return "ServiceNowAction"
+}
+
+// Run 执行ServiceNow操作
+func (a *ServiceNowAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := taskCtx.GetParams()
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	instanceURL, _ := params["instance_url"].(string)
+	table, _ := params["table"].(string)
+	sysID, _ := params["sys_id"].(string)
+	number, _ := params["number"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if instanceURL == "" {
+		return fmt.Errorf("instance_url parameter is required")
+	}
+	if table == "" {
+		table = "incident"
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+	tableURL := strings.TrimRight(instanceURL, "/") + "/api/now/table/" + url.PathEscape(table)
+
+	header, err := a.authHeader(ctx, params)
+	if err != nil {
+		return err
+	}
+	client := &itsmClient{header: header, timeout: time.Duration(timeout) * time.Second}
+
+	fields, _ := resolveValue(params["fields"], taskCtx).(map[string]interface{})
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	for _, key := range []string{"short_description", "description", "urgency", "impact", "assignment_group", "caller_id", "category"} {
+		if value, ok := params[key]; ok {
+			fields[key] = resolveValue(value, taskCtx)
+		}
+	}
+
+	a.ctx.Logger.Infof("Executing ServiceNow operation %s on table %s", operation, table)
+
+	var record map[string]interface{}
+	switch operation {
+	case "create":
+		if len(fields) == 0 {
+			return fmt.Errorf("fields parameter is required for create")
+		}
+		record, err = client.snowRecord(ctx, http.MethodPost, tableURL, fields)
+	case "update", "transition":
+		sysID, err = a.resolveSysID(ctx, client, tableURL, renderTemplate(sysID, taskCtx), renderTemplate(number, taskCtx))
+		if err != nil {
+			return err
+		}
+		// transition 通过 state 及关闭字段流转状态
+		if operation == "transition" {
+			state, ok := params["state"]
+			if !ok {
+				return fmt.Errorf("state parameter is required for transition")
+			}
+			fields["state"] = resolveValue(state, taskCtx)
+			if closeCode, _ := params["close_code"].(string); closeCode != "" {
+				fields["close_code"] = renderTemplate(closeCode, taskCtx)
+			}
+			if closeNotes, _ := params["close_notes"].(string); closeNotes != "" {
+				fields["close_notes"] = renderTemplate(closeNotes, taskCtx)
+			}
+		}
+		if workNotes, _ := params["work_notes"].(string); workNotes != "" {
+			fields["work_notes"] = renderTemplate(workNotes, taskCtx)
+		}
+		if len(fields) == 0 {
+			return fmt.Errorf("no fields to update")
+		}
+		record, err = client.snowRecord(ctx, http.MethodPatch, tableURL+"/"+url.PathEscape(sysID), fields)
+	default:
+		return fmt.Errorf("unsupported servicenow operation: %s", operation)
+	}
+	if err != nil {
+		return fmt.Errorf("servicenow %s failed: %v", operation, err)
+	}
+
+	result := map[string]interface{}{
+		"sys_id": record["sys_id"],
+		"number": record["number"],
+		"state":  record["state"],
+		"record": record,
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("ServiceNow operation %s completed successfully", operation)
+
+	return nil
+}
+
+// authHeader 构建认证头：username + password_secret（Basic）或 token_secret（OAuth Bearer）
+func (a *ServiceNowAction) authHeader(ctx context.Context, params map[string]interface{}) (http.Header, error) {
+	username, _ := params["username"].(string)
+	passwordSecret, _ := params["password_secret"].(string)
+	tokenSecret, _ := params["token_secret"].(string)
+
+	header := http.Header{}
+	switch {
+	case username != "" && passwordSecret != "":
+		password, err := a.ctx.getSecret(ctx, passwordSecret)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	case tokenSecret != "":
+		token, err := a.ctx.getSecret(ctx, tokenSecret)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	default:
+		return nil, fmt.Errorf("username and password_secret, or token_secret parameter is required")
+	}
+	return header, nil
+}
+
+// resolveSysID 根据sys_id或工单号确定记录
+func (a *ServiceNowAction) resolveSysID(ctx context.Context, client *itsmClient, tableURL, sysID, number string) (string, error) {
+	if sysID != "" {
+		return sysID, nil
+	}
+	if number == "" {
+		return "", fmt.Errorf("sys_id or number parameter is required")
+	}
+
+	var found struct {
+		Result []struct {
+			SysID string `json:"sys_id"`
+		} `json:"result"`
+	}
+	query := url.Values{}
+	query.Set("sysparm_query", "number="+number)
+	query.Set("sysparm_fields", "sys_id")
+	query.Set("sysparm_limit", "1")
+	if err := client.call(ctx, http.MethodGet, tableURL+"?"+query.Encode(), nil, &found); err != nil {
+		return "", fmt.Errorf("failed to find servicenow record %s: %v", number, err)
+	}
+	if len(found.Result) == 0 {
+		return "", fmt.Errorf("servicenow record %s not found", number)
+	}
+	return found.Result[0].SysID, nil
+}
+
+// itsmClient 带认证头的JSON客户端
+type itsmClient struct {
+	header  http.Header
+	timeout time.Duration
+}
+
+// call 发送请求
+func (c *itsmClient) call(ctx context.Context, method, url string, body, out interface{}) error {
+	return callJSON(ctx, method, url, c.header, body, out, c.timeout)
+}
+
+// snowRecord 写入ServiceNow记录并返回结果
+func (c *itsmClient) snowRecord(ctx context.Context, method, url string, fields map[string]interface{}) (map[string]interface{}, error) {
+	var response struct {
+		Result map[string]interface{} `json:"result"`
+	}
+	if err := c.call(ctx, method, url, fields, &response); err != nil {
+		return nil, err
+	}
+	return response.Result, nil
+}
@@ -0,0 +1,467 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buke/quickjs-go"
+)
+
+// jsFetchMaxBody fetch 读取的响应体上限
+const jsFetchMaxBody = 10 << 20
+
+// jsFetchTimeout fetch 请求的默认超时
+const jsFetchTimeout = 30 * time.Second
+
+// 控制台输出的保留上限，超过后丢弃并标记截断
+const (
+	jsConsoleMaxLines = 200
+	jsConsoleMaxBytes = 64 << 10
+)
+
+// jsStdlibPrelude 在宿主函数之上定义 console、fetch、crypto、base64 和 datetime
+//
+// 宿主函数只接收和返回字符串，参数的类型转换在JS中完成；宿主函数对象定义后从全局删除，脚本无法直接调用。
+const jsStdlibPrelude = `(function () {
+	var host = globalThis.__nsa_host;
+	delete globalThis.__nsa_host;
+
+	function str(value) {
+		return value === undefined || value === null ? "" : String(value);
+	}
+
+	function print(level) {
+		return function () {
+			var parts = Array.prototype.map.call(arguments, function (arg) {
+				var text = typeof arg === "string" ? arg : JSON.stringify(arg);
+				return text === undefined ? String(arg) : text;
+			});
+			host.console(level, parts.join(" "));
+		};
+	}
+	globalThis.console = {
+		log: print("info"),
+		info: print("info"),
+		debug: print("debug"),
+		warn: print("warn"),
+		error: print("error")
+	};
+	globalThis.console_log = console.log;
+
+	globalThis.fetch = function (url, options) {
+		options = options || {};
+		var init = { method: str(options.method), headers: options.headers || {}, timeout: Number(options.timeout) || 0 };
+		if (options.body !== undefined && options.body !== null) {
+			init.json = typeof options.body !== "string";
+			init.body = init.json ? JSON.stringify(options.body) : options.body;
+		}
+		var res = JSON.parse(host.fetch(str(url), JSON.stringify(init)));
+		res.text = function () { return this.body; };
+		res.json = function () { return JSON.parse(this.body); };
+		return res;
+	};
+
+	function digest(alg) {
+		return function (data, encoding) { return host.hash(alg, str(data), str(encoding)); };
+	}
+	globalThis.crypto = {
+		hash: function (alg, data, encoding) { return host.hash(str(alg), str(data), str(encoding)); },
+		hmac: function (alg, key, data, encoding) { return host.hmac(str(alg), str(key), str(data), str(encoding)); },
+		md5: digest("md5"),
+		sha1: digest("sha1"),
+		sha256: digest("sha256"),
+		sha512: digest("sha512"),
+		randomUUID: function () { return host.uuid(); }
+	};
+
+	globalThis.base64 = {
+		encode: function (data, urlSafe) { return host.base64("encode", str(data), urlSafe ? "url" : ""); },
+		decode: function (data, urlSafe) { return host.base64("decode", str(data), urlSafe ? "url" : ""); }
+	};
+
+	globalThis.datetime = {
+		format: function (value, pattern, tz) {
+			if (value instanceof Date) {
+				value = value.getTime();
+			}
+			return host.formatDate(str(value), str(pattern), str(tz));
+		}
+	};
+})();`
+
+// jsHostFunc 宿主函数，参数和返回值都是字符串，返回错误时在JS中抛出异常
+type jsHostFunc func(args []string) (string, error)
+
+// jsConsole 收集脚本的控制台输出，执行失败（包括超时和取消）时已输出的内容同样写入执行日志
+type jsConsole struct {
+	lines     []string
+	size      int
+	truncated bool
+}
+
+// add 记录一行输出，超过上限时丢弃
+func (c *jsConsole) add(level, message string) {
+	line := level + ": " + message
+	if len(c.lines) >= jsConsoleMaxLines || c.size+len(line) > jsConsoleMaxBytes {
+		c.truncated = true
+		return
+	}
+	c.lines = append(c.lines, line)
+	c.size += len(line)
+}
+
+// installStdlib 在JS上下文中定义 console、fetch、crypto、base64 和 datetime
+func (c *ActionContext) installStdlib(ctx context.Context, jsCtx *quickjs.Context, console *jsConsole) error {
+	hostFuncs := map[string]jsHostFunc{
+		"console":    c.jsConsoleOutput(console),
+		"fetch":      c.jsFetch(ctx),
+		"hash":       jsHash,
+		"hmac":       jsHMAC,
+		"uuid":       jsUUID,
+		"base64":     jsBase64,
+		"formatDate": jsFormatDate,
+	}
+
+	host := jsCtx.Object()
+	for name, fn := range hostFuncs {
+		fn := fn
+		host.Set(name, jsCtx.Function(func(jsCtx *quickjs.Context, this quickjs.Value, args []quickjs.Value) quickjs.Value {
+			values := make([]string, len(args))
+			for i, arg := range args {
+				values[i] = arg.String()
+			}
+			result, err := fn(values)
+			if err != nil {
+				return jsCtx.ThrowError(err)
+			}
+			return jsCtx.String(result)
+		}))
+	}
+	jsCtx.Globals().Set("__nsa_host", host)
+
+	result, err := jsCtx.Eval(jsStdlibPrelude)
+	if err != nil {
+		return err
+	}
+	result.Free()
+	return nil
+}
+
+// jsArg 返回第i个参数，不存在时返回空字符串
+func jsArg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// jsConsoleOutput 输出到服务日志并记录到 console：console(级别, 内容)
+func (c *ActionContext) jsConsoleOutput(console *jsConsole) jsHostFunc {
+	return func(args []string) (string, error) {
+		level, message := jsArg(args, 0), jsArg(args, 1)
+		switch level {
+		case "warn", "error":
+			c.Logger.Warnf("JS Console [%s]: %s", level, message)
+		case "debug":
+			c.Logger.Debugf("JS Console: %s", message)
+		default:
+			c.Logger.Infof("JS Console: %s", message)
+		}
+		console.add(level, message)
+		return "", nil
+	}
+}
+
+// jsFetchInit fetch 的请求参数
+type jsFetchInit struct {
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    *string           `json:"body"`
+	JSON    bool              `json:"json"`    // body 由对象编码，未设置 Content-Type 时使用 application/json
+	Timeout float64           `json:"timeout"` // 超时(秒)，默认30
+}
+
+// jsFetchResponse fetch 返回给JS的响应
+type jsFetchResponse struct {
+	Status     int               `json:"status"`
+	StatusText string            `json:"statusText"`
+	OK         bool              `json:"ok"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
+// jsFetch 同步执行HTTP请求，使用HTTP节点共享的连接池；只支持http和https，响应体最多读取10MB
+func (c *ActionContext) jsFetch(ctx context.Context) jsHostFunc {
+	return func(args []string) (string, error) {
+		target := jsArg(args, 0)
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return "", fmt.Errorf("fetch: url must start with http:// or https://")
+		}
+		var init jsFetchInit
+		if err := json.Unmarshal([]byte(jsArg(args, 1)), &init); err != nil {
+			return "", fmt.Errorf("fetch: invalid options: %v", err)
+		}
+		if init.Method == "" {
+			init.Method = http.MethodGet
+		}
+		timeout := jsFetchTimeout
+		if init.Timeout > 0 {
+			timeout = time.Duration(init.Timeout * float64(time.Second))
+		}
+
+		var body io.Reader
+		if init.Body != nil {
+			body = strings.NewReader(*init.Body)
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(reqCtx, strings.ToUpper(init.Method), target, body)
+		if err != nil {
+			return "", fmt.Errorf("fetch: %v", err)
+		}
+		for key, value := range init.Headers {
+			req.Header.Set(key, value)
+		}
+		if init.JSON && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		transport, err := c.HTTPTransports.get("", nil)
+		if err != nil {
+			return "", fmt.Errorf("fetch: %v", err)
+		}
+		resp, err := (&http.Client{Transport: transport}).Do(req)
+		if err != nil {
+			return "", fmt.Errorf("fetch: %v", err)
+		}
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, jsFetchMaxBody+1))
+		if err != nil {
+			return "", fmt.Errorf("fetch: failed to read response: %v", err)
+		}
+		if len(respBody) > jsFetchMaxBody {
+			return "", fmt.Errorf("fetch: response body exceeds %d bytes", jsFetchMaxBody)
+		}
+
+		result := jsFetchResponse{
+			Status:     resp.StatusCode,
+			StatusText: http.StatusText(resp.StatusCode),
+			OK:         resp.StatusCode >= 200 && resp.StatusCode < 300,
+			URL:        resp.Request.URL.String(),
+			Headers:    make(map[string]string, len(resp.Header)),
+			Body:       string(respBody),
+		}
+		for key, values := range resp.Header {
+			result.Headers[strings.ToLower(key)] = strings.Join(values, ", ")
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return "", fmt.Errorf("fetch: %v", err)
+		}
+		return string(data), nil
+	}
+}
+
+// jsHashes crypto.hash 和 crypto.hmac 支持的算法
+var jsHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// jsHash 计算摘要：hash(算法, 数据, 编码)
+func jsHash(args []string) (string, error) {
+	newHash, ok := jsHashes[strings.ToLower(jsArg(args, 0))]
+	if !ok {
+		return "", fmt.Errorf("crypto: unsupported hash algorithm %q", jsArg(args, 0))
+	}
+	h := newHash()
+	h.Write([]byte(jsArg(args, 1)))
+	return encodeDigest(h.Sum(nil), jsArg(args, 2))
+}
+
+// jsHMAC 计算HMAC：hmac(算法, 密钥, 数据, 编码)
+func jsHMAC(args []string) (string, error) {
+	newHash, ok := jsHashes[strings.ToLower(jsArg(args, 0))]
+	if !ok {
+		return "", fmt.Errorf("crypto: unsupported hash algorithm %q", jsArg(args, 0))
+	}
+	mac := hmac.New(newHash, []byte(jsArg(args, 1)))
+	mac.Write([]byte(jsArg(args, 2)))
+	return encodeDigest(mac.Sum(nil), jsArg(args, 3))
+}
+
+// encodeDigest 按编码输出摘要，默认hex
+func encodeDigest(sum []byte, encoding string) (string, error) {
+	switch encoding {
+	case "", "hex":
+		return hex.EncodeToString(sum), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(sum), nil
+	default:
+		return "", fmt.Errorf("crypto: unsupported encoding %q, expected hex or base64", encoding)
+	}
+}
+
+// jsUUID 生成随机的UUID（版本4）
+func jsUUID(args []string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("crypto: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// jsBase64 base64编解码：base64(encode|decode, 数据, url)，url 表示URL安全的无填充编码
+func jsBase64(args []string) (string, error) {
+	encoding := base64.StdEncoding
+	if jsArg(args, 2) == "url" {
+		encoding = base64.RawURLEncoding
+	}
+	data := jsArg(args, 1)
+	if jsArg(args, 0) == "encode" {
+		return encoding.EncodeToString([]byte(data)), nil
+	}
+	decoded, err := encoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("base64: %v", err)
+	}
+	return string(decoded), nil
+}
+
+// jsDateTokens datetime.format 支持的格式符号，较长的符号在前
+var jsDateTokens = []string{
+	"YYYY", "YY", "MMMM", "MMM", "MM", "M", "DD", "D", "dddd", "ddd",
+	"HH", "H", "hh", "h", "mm", "m", "ss", "s", "SSS", "A", "a", "ZZ", "Z", "X", "x",
+}
+
+// jsFormatDate 格式化时间：formatDate(时间, 格式, 时区)
+//
+// 时间为毫秒时间戳或RFC3339等格式的字符串，为空时使用当前时间；格式为空时输出RFC3339；时区默认UTC。
+func jsFormatDate(args []string) (string, error) {
+	t := time.Now()
+	if value := jsArg(args, 0); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil {
+			t = time.UnixMilli(int64(ms))
+		} else if parsed, ok := parseSQLTime(value); ok {
+			t = parsed
+		} else {
+			return "", fmt.Errorf("datetime: invalid time %q", value)
+		}
+	}
+	loc := time.UTC
+	if tz := jsArg(args, 2); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return "", fmt.Errorf("datetime: invalid timezone %q", tz)
+		}
+	}
+	t = t.In(loc)
+
+	pattern := jsArg(args, 1)
+	if pattern == "" {
+		return t.Format(time.RFC3339), nil
+	}
+	var b bytes.Buffer
+	for i := 0; i < len(pattern); {
+		// [] 中的内容原样输出
+		if pattern[i] == '[' {
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				b.WriteString(pattern[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+		matched := false
+		for _, token := range jsDateTokens {
+			if strings.HasPrefix(pattern[i:], token) {
+				b.WriteString(formatDateToken(t, token))
+				i += len(token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(pattern[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// formatDateToken 输出一个格式符号对应的内容
+func formatDateToken(t time.Time, token string) string {
+	switch token {
+	case "YYYY":
+		return t.Format("2006")
+	case "YY":
+		return t.Format("06")
+	case "MMMM":
+		return t.Format("January")
+	case "MMM":
+		return t.Format("Jan")
+	case "MM":
+		return t.Format("01")
+	case "M":
+		return strconv.Itoa(int(t.Month()))
+	case "DD":
+		return t.Format("02")
+	case "D":
+		return strconv.Itoa(t.Day())
+	case "dddd":
+		return t.Format("Monday")
+	case "ddd":
+		return t.Format("Mon")
+	case "HH":
+		return t.Format("15")
+	case "H":
+		return strconv.Itoa(t.Hour())
+	case "hh":
+		return t.Format("03")
+	case "h":
+		return t.Format("3")
+	case "mm":
+		return t.Format("04")
+	case "m":
+		return strconv.Itoa(t.Minute())
+	case "ss":
+		return t.Format("05")
+	case "s":
+		return strconv.Itoa(t.Second())
+	case "SSS":
+		return fmt.Sprintf("%03d", t.Nanosecond()/int(time.Millisecond))
+	case "A":
+		return t.Format("PM")
+	case "a":
+		return t.Format("pm")
+	case "ZZ":
+		return t.Format("-0700")
+	case "Z":
+		return t.Format("-07:00")
+	case "X":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "x":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return token
+}
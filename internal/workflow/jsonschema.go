@@ -0,0 +1,166 @@
+package workflow
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// validateJSONSchema 按JSON Schema子集校验数据
+//
+// 支持的关键字：type、properties、required、additionalProperties、
+// items、enum、minimum、maximum、minLength、maxLength、pattern、minItems、maxItems。
+func validateJSONSchema(schema map[string]interface{}, value interface{}) error {
+	return validateSchemaAt("$", schema, value)
+}
+
+// validateSchemaAt 校验指定路径上的值
+func validateSchemaAt(path string, schema map[string]interface{}, value interface{}) error {
+	if schema == nil {
+		return nil
+	}
+
+	// 类型校验
+	if schemaType, ok := schema["type"]; ok {
+		if !matchesSchemaType(schemaType, value) {
+			return fmt.Errorf("%s: expected type %v, got %s", path, schemaType, jsonTypeOf(value))
+		}
+	}
+
+	// 枚举校验
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		matched := false
+		for _, item := range enum {
+			if fmt.Sprint(item) == fmt.Sprint(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return validateSchemaObject(path, schema, v)
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: expected at least %v items", path, min)
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: expected at most %v items", path, max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchemaAt(fmt.Sprintf("%s[%d]", path, i), items, item); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if min, ok := schema["minLength"].(float64); ok && float64(len([]rune(v))) < min {
+			return fmt.Errorf("%s: expected length >= %v", path, min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && float64(len([]rune(v))) > max {
+			return fmt.Errorf("%s: expected length <= %v", path, max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern %s: %v", path, pattern, err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s: value does not match pattern %s", path, pattern)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			return fmt.Errorf("%s: value %v is less than minimum %v", path, v, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			return fmt.Errorf("%s: value %v is greater than maximum %v", path, v, max)
+		}
+	}
+
+	return nil
+}
+
+// validateSchemaObject 校验对象属性
+func validateSchemaObject(path string, schema map[string]interface{}, obj map[string]interface{}) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, exists := obj[name]; !exists {
+				return fmt.Errorf("%s: missing required property %s", path, name)
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name, propValue := range obj {
+		propSchema, declared := properties[name].(map[string]interface{})
+		if !declared {
+			if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				return fmt.Errorf("%s: unexpected property %s", path, name)
+			}
+			continue
+		}
+		if err := validateSchemaAt(path+"."+name, propSchema, propValue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchesSchemaType 判断值是否符合schema类型（支持类型数组）
+func matchesSchemaType(schemaType interface{}, value interface{}) bool {
+	switch t := schemaType.(type) {
+	case string:
+		return matchesSingleType(t, value)
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); ok && matchesSingleType(name, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// matchesSingleType 判断值是否符合单个类型
+func matchesSingleType(schemaType string, value interface{}) bool {
+	actual := jsonTypeOf(value)
+	switch schemaType {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		return actual == "number"
+	default:
+		return strings.EqualFold(schemaType, actual)
+	}
+}
+
+// jsonTypeOf 返回值对应的JSON类型名
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,172 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/logger"
+
+	"github.com/buke/quickjs-go"
+)
+
+// errJSPoolStopped 运行时池已关闭
+var errJSPoolStopped = errors.New("JavaScript runtime pool is stopped")
+
+// jsJob 在池中运行时上执行的任务
+type jsJob struct {
+	ctx     context.Context
+	timeout time.Duration
+	fn      func(*quickjs.Context) error
+	done    chan error
+}
+
+// jsPool QuickJS 运行时池
+//
+// QuickJS 运行时只能在创建它的线程上使用，因此每个运行时由一个锁定线程的工作goroutine持有，
+// 任务通过channel交给空闲的工作goroutine执行。每次执行使用新的 Context，互不共享全局变量；
+// 运行时按配置限制内存，执行出错或达到执行次数上限后重建。
+type jsPool struct {
+	cfg      config.JSConfig
+	logger   logger.Logger
+	jobs     chan *jsJob
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// newJSPool 创建运行时池并启动工作goroutine，运行时在第一次执行时创建
+func newJSPool(cfg config.JSConfig, logger logger.Logger) *jsPool {
+	pool := &jsPool{
+		cfg:    cfg,
+		logger: logger,
+		jobs:   make(chan *jsJob),
+		stopCh: make(chan struct{}),
+	}
+	for i := 0; i < cfg.PoolSize; i++ {
+		pool.wg.Add(1)
+		go pool.worker()
+	}
+	return pool
+}
+
+// run 在空闲的运行时上执行 fn，超过 timeout 或 ctx 取消时中断JS执行
+func (p *jsPool) run(ctx context.Context, timeout time.Duration, fn func(*quickjs.Context) error) error {
+	job := &jsJob{ctx: ctx, timeout: timeout, fn: fn, done: make(chan error, 1)}
+	select {
+	case p.jobs <- job:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.stopCh:
+		return errJSPoolStopped
+	}
+	return <-job.done
+}
+
+// stop 停止工作goroutine，等待执行中的任务完成
+func (p *jsPool) stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+	})
+	p.wg.Wait()
+}
+
+// jsWorker 持有一个运行时的工作goroutine
+type jsWorker struct {
+	pool       *jsPool
+	rt         *quickjs.Runtime
+	executions int
+	deadline   atomic.Int64 // 当前任务的截止时间(UnixNano)，0表示空闲
+	cancelled  atomic.Bool
+}
+
+// worker 逐个执行任务，运行时与当前线程绑定，goroutine退出时线程随之销毁
+func (p *jsPool) worker() {
+	defer p.wg.Done()
+	runtime.LockOSThread()
+
+	w := &jsWorker{pool: p}
+	defer w.close()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case job := <-p.jobs:
+			job.done <- w.execute(job)
+		}
+	}
+}
+
+// execute 在新的 Context 中执行任务，JS代码的panic转换为错误
+func (w *jsWorker) execute(job *jsJob) (err error) {
+	if err := job.ctx.Err(); err != nil {
+		return err
+	}
+	if w.rt == nil {
+		w.newRuntime()
+	}
+
+	w.cancelled.Store(false)
+	w.deadline.Store(time.Now().Add(job.timeout).UnixNano())
+	stopWatch := context.AfterFunc(job.ctx, func() {
+		w.cancelled.Store(true)
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("JavaScript execution panicked: %v", r)
+		}
+		stopWatch()
+		w.deadline.Store(0)
+		w.executions++
+		// 出错（包括超时、取消和内存超限）后运行时的状态不可靠，与达到执行次数上限时一样重建
+		if err != nil || w.executions >= w.pool.cfg.MaxExecutions {
+			w.close()
+		}
+	}()
+
+	jsCtx := w.rt.NewContext()
+	defer jsCtx.Close()
+
+	err = job.fn(jsCtx)
+	if err != nil {
+		if w.cancelled.Load() {
+			return fmt.Errorf("JavaScript execution cancelled: %v", context.Cause(job.ctx))
+		}
+		if time.Now().UnixNano() > w.deadline.Load() {
+			return fmt.Errorf("JavaScript execution timed out after %v", job.timeout)
+		}
+	}
+	return err
+}
+
+// newRuntime 创建运行时，设置内存上限和中断检查
+func (w *jsWorker) newRuntime() {
+	rt := quickjs.NewRuntime(quickjs.WithMemoryLimit(uint64(w.pool.cfg.MemoryLimit) << 20))
+	w.rt = &rt
+	w.rt.SetInterruptHandler(w.interrupted)
+	w.executions = 0
+}
+
+// interrupted QuickJS 定期调用的中断检查，任务超时或取消时返回非0
+func (w *jsWorker) interrupted() int {
+	if w.cancelled.Load() {
+		return 1
+	}
+	if deadline := w.deadline.Load(); deadline > 0 && time.Now().UnixNano() > deadline {
+		return 1
+	}
+	return 0
+}
+
+// close 释放运行时
+func (w *jsWorker) close() {
+	if w.rt != nil {
+		w.rt.Close()
+		w.rt = nil
+	}
+}
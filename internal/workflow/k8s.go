@@ -0,0 +1,665 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 集群内ServiceAccount凭据路径
+const (
+	inClusterTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	inClusterCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// K8sAction Kubernetes操作动作（创建Job、扩缩容、滚动重启、查询Pod状态）
+type K8sAction struct {
+	ctx *ActionContext
+}
+
+// NewK8sAction 创建Kubernetes操作动作
+func NewK8sAction(ctx *ActionContext) *K8sAction {
+	return &K8sAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *K8sAction) Name() string {
+	return "K8sAction"
+}
+
+// k8sClient 基于REST接口的Kubernetes客户端
+type k8sClient struct {
+	server     string
+	token      string
+	httpClient *http.Client
+}
+
+// kubeconfig kubeconfig文件中用到的字段
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// Run 执行Kubernetes操作
+func (a *K8sAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := renderValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	namespace, _ := params["namespace"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	client, defaultNamespace, err := a.newClient(ctx, params, time.Duration(timeout)*time.Second)
+	if err != nil {
+		return err
+	}
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	a.ctx.Logger.Infof("Executing Kubernetes operation %s in namespace %s", operation, namespace)
+
+	var result interface{}
+	switch operation {
+	case "create_job":
+		result, err = a.createJob(ctx, client, namespace, params)
+	case "job_status":
+		result, err = a.jobStatus(ctx, client, namespace, params)
+	case "scale":
+		result, err = a.scale(ctx, client, namespace, params)
+	case "rollout_restart":
+		result, err = a.rolloutRestart(ctx, client, namespace, params)
+	case "pod_status":
+		result, err = a.podStatus(ctx, client, namespace, params)
+	default:
+		return fmt.Errorf("unsupported kubernetes operation: %s", operation)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Kubernetes operation %s completed successfully", operation)
+
+	return nil
+}
+
+// newClient 根据参数创建客户端
+//
+// 凭据优先级：kubeconfig_secret（密钥中保存的kubeconfig内容）> kubeconfig（文件路径）> 集群内ServiceAccount。
+func (a *K8sAction) newClient(ctx context.Context, params map[string]interface{}, timeout time.Duration) (*k8sClient, string, error) {
+	kubeconfigPath, _ := params["kubeconfig"].(string)
+	kubeconfigSecret, _ := params["kubeconfig_secret"].(string)
+	contextName, _ := params["context"].(string)
+
+	var data []byte
+	switch {
+	case kubeconfigSecret != "":
+		if a.ctx.Secrets == nil {
+			return nil, "", fmt.Errorf("secret store is not available")
+		}
+		content, err := a.ctx.Secrets.Get(ctx, kubeconfigSecret)
+		if err != nil {
+			return nil, "", err
+		}
+		data = []byte(content)
+	case kubeconfigPath != "":
+		content, err := os.ReadFile(kubeconfigPath)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read kubeconfig: %v", err)
+		}
+		data = content
+	default:
+		client, err := newInClusterClient(timeout)
+		return client, "", err
+	}
+
+	return newKubeconfigClient(data, contextName, filepath.Dir(kubeconfigPath), timeout)
+}
+
+// newInClusterClient 使用集群内ServiceAccount创建客户端
+func newInClusterClient(timeout time.Duration) (*k8sClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a kubernetes cluster and no kubeconfig provided")
+	}
+
+	token, err := os.ReadFile(inClusterTokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %v", err)
+	}
+
+	tlsConfig := &tls.Config{}
+	if ca, err := os.ReadFile(inClusterCAFile); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		tlsConfig.RootCAs = pool
+	}
+
+	return &k8sClient{
+		server: "https://" + strings.Trim(host, "[]") + ":" + port,
+		token:  strings.TrimSpace(string(token)),
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// newKubeconfigClient 解析kubeconfig创建客户端，返回上下文中的默认命名空间
+func newKubeconfigClient(data []byte, contextName, baseDir string, timeout time.Duration) (*k8sClient, string, error) {
+	var cfg kubeconfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, "", fmt.Errorf("failed to parse kubeconfig: %v", err)
+	}
+
+	if contextName == "" {
+		contextName = cfg.CurrentContext
+	}
+
+	var clusterName, userName, namespace string
+	for _, c := range cfg.Contexts {
+		if c.Name == contextName {
+			clusterName, userName, namespace = c.Context.Cluster, c.Context.User, c.Context.Namespace
+			break
+		}
+	}
+	if clusterName == "" {
+		return nil, "", fmt.Errorf("context %s not found in kubeconfig", contextName)
+	}
+
+	// readFileOrData 读取内联base64数据或文件（相对路径基于kubeconfig所在目录）
+	readFileOrData := func(encoded, path string) ([]byte, error) {
+		if encoded != "" {
+			return base64.StdEncoding.DecodeString(encoded)
+		}
+		if path == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(path) && baseDir != "" {
+			path = filepath.Join(baseDir, path)
+		}
+		return os.ReadFile(path)
+	}
+
+	client := &k8sClient{}
+	tlsConfig := &tls.Config{}
+
+	found := false
+	for _, c := range cfg.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		client.server = strings.TrimRight(c.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		ca, err := readFileOrData(c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load cluster CA: %v", err)
+		}
+		if len(ca) > 0 {
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(ca)
+			tlsConfig.RootCAs = pool
+		}
+	}
+	if !found {
+		return nil, "", fmt.Errorf("cluster %s not found in kubeconfig", clusterName)
+	}
+
+	for _, u := range cfg.Users {
+		if u.Name != userName {
+			continue
+		}
+		client.token = u.User.Token
+		if client.token == "" && u.User.TokenFile != "" {
+			token, err := readFileOrData("", u.User.TokenFile)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to read token file: %v", err)
+			}
+			client.token = strings.TrimSpace(string(token))
+		}
+
+		cert, err := readFileOrData(u.User.ClientCertificateData, u.User.ClientCertificate)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		key, err := readFileOrData(u.User.ClientKeyData, u.User.ClientKey)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load client key: %v", err)
+		}
+		if len(cert) > 0 && len(key) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid client certificate: %v", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+
+	client.httpClient = &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	return client, namespace, nil
+}
+
+// do 发送请求并解析JSON响应
+func (c *k8sClient) do(ctx context.Context, method, path, contentType string, body interface{}) (map[string]interface{}, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("invalid kubernetes response: %v", err)
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		message, _ := result["message"].(string)
+		if message == "" {
+			message = string(data)
+		}
+		return nil, fmt.Errorf("kubernetes API returned status %d: %s", resp.StatusCode, message)
+	}
+
+	return result, nil
+}
+
+// createJob 创建Job，可选等待完成
+func (a *K8sAction) createJob(ctx context.Context, client *k8sClient, namespace string, params map[string]interface{}) (interface{}, error) {
+	job, _ := params["manifest"].(map[string]interface{})
+	if job == nil {
+		var err error
+		job, err = buildJobManifest(params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	job["apiVersion"] = "batch/v1"
+	job["kind"] = "Job"
+
+	created, err := client.do(ctx, http.MethodPost, fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", url.PathEscape(namespace)), "application/json", job)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := lookupPath(created, []string{"metadata", "name"})
+	jobName, _ := name.(string)
+
+	wait, _ := params["wait"].(bool)
+	if !wait {
+		return summarizeJob(created), nil
+	}
+
+	waitTimeout, _ := params["wait_timeout"].(float64)
+	if waitTimeout == 0 {
+		waitTimeout = 600
+	}
+	return a.waitJob(ctx, client, namespace, jobName, time.Duration(waitTimeout)*time.Second)
+}
+
+// buildJobManifest 根据简化参数构建Job
+func buildJobManifest(params map[string]interface{}) (map[string]interface{}, error) {
+	image, _ := params["image"].(string)
+	name, _ := params["name"].(string)
+	if image == "" {
+		return nil, fmt.Errorf("image or manifest parameter is required for create_job")
+	}
+
+	container := map[string]interface{}{
+		"name":  "main",
+		"image": image,
+	}
+	if command, ok := params["command"].([]interface{}); ok {
+		container["command"] = command
+	}
+	if args, ok := params["args"].([]interface{}); ok {
+		container["args"] = args
+	}
+	if env, ok := params["env"].(map[string]interface{}); ok {
+		var envVars []interface{}
+		for key, value := range env {
+			envVars = append(envVars, map[string]interface{}{"name": key, "value": stringifyValue(value)})
+		}
+		container["env"] = envVars
+	}
+
+	metadata := map[string]interface{}{}
+	if name != "" {
+		metadata["name"] = name
+	} else {
+		metadata["generateName"] = "nsa-job-"
+	}
+	if labels, ok := params["labels"].(map[string]interface{}); ok {
+		metadata["labels"] = labels
+	}
+
+	spec := map[string]interface{}{
+		"template": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"restartPolicy": "Never",
+				"containers":    []interface{}{container},
+			},
+		},
+	}
+	if backoffLimit, ok := params["backoff_limit"].(float64); ok {
+		spec["backoffLimit"] = int(backoffLimit)
+	}
+	if ttl, ok := params["ttl_seconds_after_finished"].(float64); ok {
+		spec["ttlSecondsAfterFinished"] = int(ttl)
+	}
+
+	return map[string]interface{}{
+		"metadata": metadata,
+		"spec":     spec,
+	}, nil
+}
+
+// waitJob 轮询Job直到成功或失败
+func (a *K8sAction) waitJob(ctx context.Context, client *k8sClient, namespace, name string, timeout time.Duration) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	path := fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs/%s", url.PathEscape(namespace), url.PathEscape(name))
+
+	for {
+		job, err := client.do(ctx, http.MethodGet, path, "", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		summary := summarizeJob(job)
+		switch summary["state"] {
+		case "succeeded":
+			return summary, nil
+		case "failed":
+			return nil, fmt.Errorf("job %s failed: %v", name, summary["message"])
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for job %s", name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// jobStatus 查询Job状态
+func (a *K8sAction) jobStatus(ctx context.Context, client *k8sClient, namespace string, params map[string]interface{}) (interface{}, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("name parameter is required for job_status")
+	}
+
+	job, err := client.do(ctx, http.MethodGet, fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs/%s", url.PathEscape(namespace), url.PathEscape(name)), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return summarizeJob(job), nil
+}
+
+// summarizeJob 提取Job的关键状态
+func summarizeJob(job map[string]interface{}) map[string]interface{} {
+	name, _ := lookupPath(job, []string{"metadata", "name"})
+	namespace, _ := lookupPath(job, []string{"metadata", "namespace"})
+	active, _ := lookupPath(job, []string{"status", "active"})
+	succeeded, _ := lookupPath(job, []string{"status", "succeeded"})
+	failed, _ := lookupPath(job, []string{"status", "failed"})
+
+	state := "running"
+	message := ""
+	if conditions, ok := lookupPath(job, []string{"status", "conditions"}); ok {
+		items, _ := conditions.([]interface{})
+		for _, item := range items {
+			condition, _ := item.(map[string]interface{})
+			if condition["status"] != "True" {
+				continue
+			}
+			switch condition["type"] {
+			case "Complete":
+				state = "succeeded"
+			case "Failed":
+				state = "failed"
+				message, _ = condition["message"].(string)
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"name":      name,
+		"namespace": namespace,
+		"state":     state,
+		"active":    active,
+		"succeeded": succeeded,
+		"failed":    failed,
+		"message":   message,
+	}
+}
+
+// deploymentPath 返回工作负载路径，支持deployment/statefulset
+func deploymentPath(namespace string, params map[string]interface{}) (string, string, error) {
+	name, _ := params["name"].(string)
+	kind, _ := params["kind"].(string)
+	if name == "" {
+		return "", "", fmt.Errorf("name parameter is required")
+	}
+
+	resource := "deployments"
+	switch strings.ToLower(kind) {
+	case "", "deployment":
+	case "statefulset":
+		resource = "statefulsets"
+	default:
+		return "", "", fmt.Errorf("unsupported workload kind: %s", kind)
+	}
+
+	return fmt.Sprintf("/apis/apps/v1/namespaces/%s/%s/%s", url.PathEscape(namespace), resource, url.PathEscape(name)), name, nil
+}
+
+// scale 调整副本数
+func (a *K8sAction) scale(ctx context.Context, client *k8sClient, namespace string, params map[string]interface{}) (interface{}, error) {
+	path, name, err := deploymentPath(namespace, params)
+	if err != nil {
+		return nil, err
+	}
+	replicas, ok := params["replicas"].(float64)
+	if !ok || replicas < 0 {
+		return nil, fmt.Errorf("replicas parameter is required for scale")
+	}
+
+	patch := map[string]interface{}{"spec": map[string]interface{}{"replicas": int(replicas)}}
+	result, err := client.do(ctx, http.MethodPatch, path+"/scale", "application/merge-patch+json", patch)
+	if err != nil {
+		return nil, err
+	}
+
+	current, _ := lookupPath(result, []string{"status", "replicas"})
+	return map[string]interface{}{
+		"name":             name,
+		"namespace":        namespace,
+		"replicas":         int(replicas),
+		"current_replicas": current,
+	}, nil
+}
+
+// rolloutRestart 滚动重启（与kubectl rollout restart相同，更新Pod模板注解）
+func (a *K8sAction) rolloutRestart(ctx context.Context, client *k8sClient, namespace string, params map[string]interface{}) (interface{}, error) {
+	path, name, err := deploymentPath(namespace, params)
+	if err != nil {
+		return nil, err
+	}
+
+	restartedAt := time.Now().Format(time.RFC3339)
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						"kubectl.kubernetes.io/restartedAt": restartedAt,
+					},
+				},
+			},
+		},
+	}
+	if _, err := client.do(ctx, http.MethodPatch, path, "application/strategic-merge-patch+json", patch); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"name":         name,
+		"namespace":    namespace,
+		"restarted_at": restartedAt,
+	}, nil
+}
+
+// podStatus 查询Pod状态，按名称或标签选择器
+func (a *K8sAction) podStatus(ctx context.Context, client *k8sClient, namespace string, params map[string]interface{}) (interface{}, error) {
+	name, _ := params["name"].(string)
+	selector, _ := params["label_selector"].(string)
+
+	basePath := fmt.Sprintf("/api/v1/namespaces/%s/pods", url.PathEscape(namespace))
+	var pods []interface{}
+	if name != "" {
+		pod, err := client.do(ctx, http.MethodGet, basePath+"/"+url.PathEscape(name), "", nil)
+		if err != nil {
+			return nil, err
+		}
+		pods = []interface{}{pod}
+	} else {
+		path := basePath
+		if selector != "" {
+			path += "?labelSelector=" + url.QueryEscape(selector)
+		}
+		list, err := client.do(ctx, http.MethodGet, path, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		pods, _ = list["items"].([]interface{})
+	}
+
+	phases := make(map[string]int)
+	summaries := make([]map[string]interface{}, 0, len(pods))
+	for _, item := range pods {
+		pod, _ := item.(map[string]interface{})
+		podName, _ := lookupPath(pod, []string{"metadata", "name"})
+		phase, _ := lookupPath(pod, []string{"status", "phase"})
+		node, _ := lookupPath(pod, []string{"spec", "nodeName"})
+
+		ready := true
+		restarts := 0.0
+		if statuses, ok := lookupPath(pod, []string{"status", "containerStatuses"}); ok {
+			items, _ := statuses.([]interface{})
+			for _, s := range items {
+				status, _ := s.(map[string]interface{})
+				if isReady, _ := status["ready"].(bool); !isReady {
+					ready = false
+				}
+				count, _ := status["restartCount"].(float64)
+				restarts += count
+			}
+		} else {
+			ready = false
+		}
+
+		phaseName, _ := phase.(string)
+		phases[phaseName]++
+		summaries = append(summaries, map[string]interface{}{
+			"name":     podName,
+			"phase":    phase,
+			"ready":    ready,
+			"restarts": int(restarts),
+			"node":     node,
+		})
+	}
+
+	return map[string]interface{}{
+		"namespace": namespace,
+		"count":     len(summaries),
+		"phases":    phases,
+		"pods":      summaries,
+	}, nil
+}
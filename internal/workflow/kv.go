@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/datasource"
+)
+
+// KVAction 键值存储动作（Consul、etcd），连接信息来自 consul、etcd 类型数据源
+type KVAction struct {
+	ctx *ActionContext
+}
+
+// NewKVAction 创建键值存储动作
+func NewKVAction(ctx *ActionContext) *KVAction {
+	return &KVAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *KVAction) Name() string {
+	return "KVAction"
+}
+
+// Run 执行键值存储操作
+func (a *KVAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	dataSourceName, _ := params["datasource"].(string)
+	operation, _ := params["operation"].(string)
+	key, _ := params["key"].(string)
+	prefix, _ := params["prefix"].(bool)
+	decodeJSON, _ := params["decode_json"].(bool)
+	timeout, _ := params["timeout"].(float64)
+
+	if dataSourceName == "" {
+		return fmt.Errorf("datasource parameter is required")
+	}
+	if key == "" && operation != "list" {
+		return fmt.Errorf("key parameter is required")
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	store, err := a.ctx.DataSourceMgr.GetKVStore(dataSourceName)
+	if err != nil {
+		return fmt.Errorf("failed to get kv store: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var result map[string]interface{}
+	switch operation {
+	case "get":
+		var pair *datasource.KVPair
+		pair, err = store.Get(ctx, key)
+		if err == nil {
+			result = map[string]interface{}{"key": key, "exists": pair != nil}
+			if pair != nil {
+				result["value"] = pair.DecodeValue(decodeJSON)
+				result["revision"] = pair.Revision
+			}
+		}
+	case "put":
+		if _, ok := params["value"]; !ok {
+			return fmt.Errorf("value parameter is required for put")
+		}
+		var revision uint64
+		revision, err = store.Put(ctx, key, []byte(stringifyValue(params["value"])))
+		result = map[string]interface{}{"key": key, "revision": revision}
+	case "delete":
+		err = store.Delete(ctx, key, prefix)
+		result = map[string]interface{}{"key": key, "prefix": prefix, "deleted": true}
+	case "list":
+		var pairs []datasource.KVPair
+		pairs, err = store.List(ctx, key)
+		items := make([]interface{}, 0, len(pairs))
+		for _, pair := range pairs {
+			items = append(items, kvPairOutput(pair, decodeJSON))
+		}
+		result = map[string]interface{}{"prefix": key, "items": items, "count": len(items)}
+	default:
+		return fmt.Errorf("unsupported kv operation: %s", operation)
+	}
+	if err != nil {
+		return fmt.Errorf("kv %s %s failed: %v", operation, key, err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("KV %s %s on %s completed successfully", operation, key, dataSourceName)
+
+	return nil
+}
+
+// kvPairOutput 转换为任务输出
+func kvPairOutput(pair datasource.KVPair, decodeJSON bool) map[string]interface{} {
+	return map[string]interface{}{
+		"key":      pair.Key,
+		"value":    pair.DecodeValue(decodeJSON),
+		"revision": pair.Revision,
+	}
+}
@@ -0,0 +1,390 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultLLMBaseURL 默认的OpenAI兼容接口地址
+const defaultLLMBaseURL = "https://api.openai.com/v1"
+
+// LLMAction 大模型推理动作（OpenAI兼容接口）
+type LLMAction struct {
+	ctx *ActionContext
+}
+
+// NewLLMAction 创建大模型推理动作
+func NewLLMAction(ctx *ActionContext) *LLMAction {
+	return &LLMAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *LLMAction) Name() string {
+	return "LLMAction"
+}
+
+// llmMessage 对话消息
+type llmMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// llmUsage token用量
+type llmUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// llmResponseFormat 输出格式
+type llmResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// llmRequest chat completions请求
+type llmRequest struct {
+	Model          string             `json:"model"`
+	Messages       []llmMessage       `json:"messages"`
+	Temperature    *float64           `json:"temperature,omitempty"`
+	MaxTokens      int                `json:"max_tokens,omitempty"`
+	ResponseFormat *llmResponseFormat `json:"response_format,omitempty"`
+}
+
+// llmResponse chat completions响应
+type llmResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      llmMessage `json:"message"`
+		FinishReason string     `json:"finish_reason"`
+	} `json:"choices"`
+	Usage llmUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// Run 调用大模型
+func (a *LLMAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := taskCtx.GetParams()
+
+	// 解析参数
+	baseURL, _ := params["base_url"].(string)
+	model, _ := params["model"].(string)
+	apiKeySecret, _ := params["api_key_secret"].(string)
+	timeout, _ := params["timeout"].(float64)
+	maxTokens, _ := params["max_tokens"].(float64)
+	responseFormat, _ := params["response_format"].(string) // text, json
+	jsonSchema, _ := params["json_schema"].(map[string]interface{})
+
+	if model == "" {
+		return fmt.Errorf("model parameter is required")
+	}
+	if baseURL == "" {
+		baseURL = defaultLLMBaseURL
+	}
+	if timeout == 0 {
+		timeout = 60
+	}
+
+	if jsonSchema != nil {
+		responseFormat = "json"
+	}
+
+	messages, err := a.buildMessages(ctx, params, taskCtx)
+	if err != nil {
+		return err
+	}
+
+	request := llmRequest{
+		Model:     model,
+		Messages:  messages,
+		MaxTokens: int(maxTokens),
+	}
+	if temperature, ok := params["temperature"].(float64); ok {
+		request.Temperature = &temperature
+	}
+	if responseFormat == "json" {
+		request.ResponseFormat = &llmResponseFormat{Type: "json_object"}
+	}
+
+	// 从密钥存储中读取API Key
+	var apiKey string
+	if apiKeySecret != "" {
+		if a.ctx.Secrets == nil {
+			return fmt.Errorf("secret store is not available")
+		}
+		apiKey, err = a.ctx.Secrets.Get(ctx, apiKeySecret)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.ctx.Logger.Infof("Executing LLM request: model %s, messages %d", model, len(messages))
+
+	response, err := a.chatCompletion(ctx, baseURL, apiKey, time.Duration(timeout)*time.Second, &request)
+	if err != nil {
+		return err
+	}
+
+	// 记录token用量
+	taskCtx.SetMetadata("token_usage", map[string]interface{}{
+		"model":             response.Model,
+		"prompt_tokens":     response.Usage.PromptTokens,
+		"completion_tokens": response.Usage.CompletionTokens,
+		"total_tokens":      response.Usage.TotalTokens,
+	})
+
+	content := response.Choices[0].Message.Content
+	result := map[string]interface{}{
+		"id":            response.ID,
+		"model":         response.Model,
+		"content":       content,
+		"finish_reason": response.Choices[0].FinishReason,
+		"usage":         response.Usage,
+	}
+
+	// JSON模式下解析并校验输出
+	if responseFormat == "json" {
+		parsed, err := parseLLMJSON(content)
+		if err != nil {
+			return err
+		}
+		if err := validateJSONSchema(jsonSchema, parsed); err != nil {
+			return fmt.Errorf("LLM output does not match json_schema: %v", err)
+		}
+		result["json"] = parsed
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("LLM request completed successfully, total tokens %d", response.Usage.TotalTokens)
+
+	return nil
+}
+
+// buildMessages 根据参数构建对话消息并渲染模板
+func (a *LLMAction) buildMessages(ctx context.Context, params map[string]interface{}, taskCtx *TaskContext) ([]llmMessage, error) {
+	var messages []llmMessage
+
+	if systemPrompt, _ := params["system_prompt"].(string); systemPrompt != "" {
+		messages = append(messages, llmMessage{
+			Role:    "system",
+			Content: renderTemplate(systemPrompt, taskCtx),
+		})
+	}
+
+	// few-shot示例
+	if examples, ok := params["examples"].(map[string]interface{}); ok {
+		exampleMessages, err := a.buildExamples(ctx, examples, taskCtx)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, exampleMessages...)
+	}
+
+	// 自定义多轮消息
+	if rawMessages, ok := params["messages"].([]interface{}); ok {
+		for _, raw := range rawMessages {
+			item, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid message item: %v", raw)
+			}
+			role, _ := item["role"].(string)
+			content, _ := item["content"].(string)
+			if role == "" {
+				role = "user"
+			}
+			messages = append(messages, llmMessage{
+				Role:    role,
+				Content: renderTemplate(content, taskCtx),
+			})
+		}
+	}
+
+	if prompt, _ := params["prompt"].(string); prompt != "" {
+		messages = append(messages, llmMessage{
+			Role:    "user",
+			Content: renderTemplate(prompt, taskCtx),
+		})
+	}
+
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("prompt or messages parameter is required")
+	}
+
+	return messages, nil
+}
+
+// buildExamples 从数据库查询结果或前置任务输出构建few-shot示例消息
+//
+// 每行数据生成一对 user/assistant 消息，input/output 指定对应的列名，
+// 也可以用 input_template/output_template 以行数据为变量渲染内容。
+func (a *LLMAction) buildExamples(ctx context.Context, examples map[string]interface{}, taskCtx *TaskContext) ([]llmMessage, error) {
+	inputColumn, _ := examples["input"].(string)
+	outputColumn, _ := examples["output"].(string)
+	inputTemplate, _ := examples["input_template"].(string)
+	outputTemplate, _ := examples["output_template"].(string)
+	limit, _ := examples["limit"].(float64)
+
+	if (inputColumn == "" && inputTemplate == "") || (outputColumn == "" && outputTemplate == "") {
+		return nil, fmt.Errorf("examples require input/output columns or templates")
+	}
+
+	rows, err := a.loadExampleRows(ctx, examples, taskCtx)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(rows) > int(limit) {
+		rows = rows[:int(limit)]
+	}
+
+	var messages []llmMessage
+	for _, row := range rows {
+		rowCtx := &TaskContext{vars: row}
+		input := stringifyValue(row[inputColumn])
+		if inputTemplate != "" {
+			input = renderTemplate(inputTemplate, rowCtx)
+		}
+		output := stringifyValue(row[outputColumn])
+		if outputTemplate != "" {
+			output = renderTemplate(outputTemplate, rowCtx)
+		}
+
+		messages = append(messages,
+			llmMessage{Role: "user", Content: input},
+			llmMessage{Role: "assistant", Content: output},
+		)
+	}
+
+	return messages, nil
+}
+
+// loadExampleRows 加载示例数据行
+func (a *LLMAction) loadExampleRows(ctx context.Context, examples map[string]interface{}, taskCtx *TaskContext) ([]map[string]interface{}, error) {
+	// 引用前置任务输出，例如 output.load_examples.rows
+	if from, _ := examples["from"].(string); from != "" {
+		value, ok := taskCtx.Lookup(from)
+		if !ok {
+			return nil, fmt.Errorf("examples source %s not found", from)
+		}
+		return toRows(value)
+	}
+
+	dataSourceName, _ := examples["datasource"].(string)
+	sqlQuery, _ := examples["sql"].(string)
+	queryParams, _ := examples["params"].([]interface{})
+	if dataSourceName == "" || sqlQuery == "" {
+		return nil, fmt.Errorf("examples require from or datasource and sql")
+	}
+
+	db, err := a.ctx.DataSourceMgr.GetSQLDB(dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	queryParams, _ = renderValue(queryParams, taskCtx).([]interface{})
+	dbAction := &DBClientAction{ctx: a.ctx}
+	masked, err := dbAction.maskedColumns(dataSourceName, taskCtx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := dbAction.executeQuery(ctx, db, sqlQuery, queryParams, defaultMaxQueryRows, masked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load examples: %v", err)
+	}
+
+	return toRows(result["rows"])
+}
+
+// toRows 将查询结果转换为行列表
+func toRows(value interface{}) ([]map[string]interface{}, error) {
+	switch rows := value.(type) {
+	case []map[string]interface{}:
+		return rows, nil
+	case []interface{}:
+		result := make([]map[string]interface{}, 0, len(rows))
+		for _, item := range rows {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid row: %v", item)
+			}
+			result = append(result, row)
+		}
+		return result, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("expected rows array, got %T", value)
+	}
+}
+
+// parseLLMJSON 解析模型输出的JSON（兼容```json代码块包裹）
+func parseLLMJSON(content string) (interface{}, error) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(content, "```")
+		content = strings.TrimSpace(content)
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+		return nil, fmt.Errorf("LLM output is not valid JSON: %v", err)
+	}
+	return parsed, nil
+}
+
+// chatCompletion 调用chat completions接口
+func (a *LLMAction) chatCompletion(ctx context.Context, baseURL, apiKey string, timeout time.Duration, request *llmRequest) (*llmResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %v", err)
+	}
+
+	url := strings.TrimRight(baseURL, "/") + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var response llmResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("LLM request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("LLM request failed with status %d: %s", resp.StatusCode, response.Error.Message)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("LLM request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("LLM response contains no choices")
+	}
+
+	return &response, nil
+}
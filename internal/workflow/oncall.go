@@ -0,0 +1,227 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultPagerDutyEventsURL PagerDuty Events API v2 地址
+const defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// defaultOpsgenieURL Opsgenie API地址（EU区域为 https://api.eu.opsgenie.com）
+const defaultOpsgenieURL = "https://api.opsgenie.com"
+
+// PagerDutyAction PagerDuty事件动作（触发、确认、解决）
+type PagerDutyAction struct {
+	ctx *ActionContext
+}
+
+// NewPagerDutyAction 创建PagerDuty事件动作
+func NewPagerDutyAction(ctx *ActionContext) *PagerDutyAction {
+	return &PagerDutyAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *PagerDutyAction) Name() string {
+	return "PagerDutyAction"
+}
+
+// Run 执行PagerDuty事件操作
+func (a *PagerDutyAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	eventsURL, _ := params["events_url"].(string)
+	routingKeySecret, _ := params["routing_key_secret"].(string)
+	dedupKey := paramString(params, "dedup_key")
+	timeout, _ := params["timeout"].(float64)
+
+	if routingKeySecret == "" {
+		return fmt.Errorf("routing_key_secret parameter is required")
+	}
+	if eventsURL == "" {
+		eventsURL = defaultPagerDutyEventsURL
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	body := map[string]interface{}{"event_action": operation}
+	switch operation {
+	case "trigger":
+		summary, _ := params["summary"].(string)
+		source, _ := params["source"].(string)
+		severity, _ := params["severity"].(string) // critical, error, warning, info
+		if summary == "" {
+			return fmt.Errorf("summary parameter is required for trigger")
+		}
+		if source == "" {
+			source = "nsa"
+		}
+		if severity == "" {
+			severity = "error"
+		}
+		payload := map[string]interface{}{
+			"summary":  summary,
+			"source":   source,
+			"severity": severity,
+		}
+		copyParams(params, payload, "component", "group", "class", "custom_details")
+		body["payload"] = payload
+		copyParams(params, body, "links", "images")
+		if dedupKey != "" {
+			body["dedup_key"] = dedupKey
+		}
+
+	case "acknowledge", "resolve":
+		if dedupKey == "" {
+			return fmt.Errorf("dedup_key parameter is required for %s", operation)
+		}
+		body["dedup_key"] = dedupKey
+
+	case "":
+		return fmt.Errorf("operation parameter is required")
+
+	default:
+		return fmt.Errorf("unsupported pagerduty operation: %s", operation)
+	}
+
+	routingKey, err := a.ctx.getSecret(ctx, routingKeySecret)
+	if err != nil {
+		return err
+	}
+	body["routing_key"] = routingKey
+
+	a.ctx.Logger.Infof("Sending PagerDuty %s event", operation)
+
+	var resp struct {
+		Status   string `json:"status"`
+		Message  string `json:"message"`
+		DedupKey string `json:"dedup_key"`
+	}
+	if err := callJSON(ctx, http.MethodPost, eventsURL, nil, body, &resp, time.Duration(timeout)*time.Second); err != nil {
+		return fmt.Errorf("failed to send pagerduty %s event: %v", operation, err)
+	}
+
+	// 保存结果，dedup_key 用于后续确认和解决
+	taskCtx.SetOutput(map[string]interface{}{
+		"status":    resp.Status,
+		"message":   resp.Message,
+		"dedup_key": resp.DedupKey,
+	})
+	a.ctx.Logger.Infof("PagerDuty %s event accepted, dedup_key: %s", operation, resp.DedupKey)
+
+	return nil
+}
+
+// OpsgenieAction Opsgenie告警动作（创建、确认、关闭）
+type OpsgenieAction struct {
+	ctx *ActionContext
+}
+
+// NewOpsgenieAction 创建Opsgenie告警动作
+func NewOpsgenieAction(ctx *ActionContext) *OpsgenieAction {
+	return &OpsgenieAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *OpsgenieAction) Name() string {
+	return "OpsgenieAction"
+}
+
+// Run 执行Opsgenie告警操作
+func (a *OpsgenieAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	baseURL, _ := params["base_url"].(string)
+	apiKeySecret, _ := params["api_key_secret"].(string)
+	alias := paramString(params, "alias")
+	alertID := paramString(params, "alert_id")
+	timeout, _ := params["timeout"].(float64)
+
+	if apiKeySecret == "" {
+		return fmt.Errorf("api_key_secret parameter is required")
+	}
+	if baseURL == "" {
+		baseURL = defaultOpsgenieURL
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	alertsURL := strings.TrimRight(baseURL, "/") + "/v2/alerts"
+	var endpoint string
+	var body map[string]interface{}
+	switch operation {
+	case "trigger":
+		message, _ := params["message"].(string)
+		if message == "" {
+			return fmt.Errorf("message parameter is required for trigger")
+		}
+		body = map[string]interface{}{"message": message, "source": "nsa"}
+		copyParams(params, body, "description", "priority", "responders", "visible_to", "actions", "tags", "details", "entity", "source", "user", "note")
+		if alias != "" {
+			body["alias"] = alias
+		}
+		endpoint = alertsURL
+
+	case "acknowledge", "resolve":
+		// 优先按告警ID定位，否则按别名（与触发时的 alias 一致）
+		identifier, identifierType := alertID, "id"
+		if identifier == "" {
+			identifier, identifierType = alias, "alias"
+		}
+		if identifier == "" {
+			return fmt.Errorf("alert_id or alias parameter is required for %s", operation)
+		}
+		verb := "acknowledge"
+		if operation == "resolve" {
+			verb = "close"
+		}
+		body = map[string]interface{}{"source": "nsa"}
+		copyParams(params, body, "source", "user", "note")
+		endpoint = alertsURL + "/" + url.PathEscape(identifier) + "/" + verb + "?identifierType=" + identifierType
+
+	case "":
+		return fmt.Errorf("operation parameter is required")
+
+	default:
+		return fmt.Errorf("unsupported opsgenie operation: %s", operation)
+	}
+
+	apiKey, err := a.ctx.getSecret(ctx, apiKeySecret)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Authorization", "GenieKey "+apiKey)
+
+	a.ctx.Logger.Infof("Sending Opsgenie %s request", operation)
+
+	// Opsgenie 异步处理请求，返回 requestId 可用于查询处理状态
+	var resp struct {
+		Result    string  `json:"result"`
+		RequestID string  `json:"requestId"`
+		Took      float64 `json:"took"`
+	}
+	if err := callJSON(ctx, http.MethodPost, endpoint, header, body, &resp, time.Duration(timeout)*time.Second); err != nil {
+		return fmt.Errorf("failed to send opsgenie %s request: %v", operation, err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(map[string]interface{}{
+		"result":     resp.Result,
+		"request_id": resp.RequestID,
+		"alias":      alias,
+	})
+	a.ctx.Logger.Infof("Opsgenie %s request accepted, request_id: %s", operation, resp.RequestID)
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// payloadRefField 消息数据中指向外部数据的字段
+const payloadRefField = "payload_ref"
+
+// payloadFetchTimeout 读取外部消息数据的超时时间
+const payloadFetchTimeout = 60 * time.Second
+
+// resolvePayload 读取消息数据中 payload_ref 指向的外部数据（HTTP(S) 地址或对象存储中的对象）
+//
+// 外部数据为JSON对象时合并到消息数据中，消息中已有的字段优先；其他JSON值或非JSON内容保存在 payload 字段中。
+// payload_ref 保留在消息数据中，便于追溯数据来源。
+func (e *Executor) resolvePayload(ctx context.Context, message *models.NSQMessage) error {
+	if message == nil || message.Data == nil {
+		return nil
+	}
+	ref, ok := message.Data[payloadRefField].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, payloadFetchTimeout)
+	defer cancel()
+
+	maxSize := int64(e.cfg.NSQ.MaxPayloadSize)
+	var body []byte
+	var err error
+	if url, _ := ref["url"].(string); url != "" {
+		body, err = e.fetchPayloadURL(ctx, url, maxSize)
+	} else if dataSource, _ := ref["datasource"].(string); dataSource != "" {
+		body, err = e.fetchPayloadObject(ctx, dataSource, ref, maxSize)
+	} else {
+		err = fmt.Errorf("url or datasource is required")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %v", payloadRefField, err)
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		payload = string(body)
+	}
+	if fields, ok := payload.(map[string]interface{}); ok {
+		for key, value := range fields {
+			if _, exists := message.Data[key]; !exists {
+				message.Data[key] = value
+			}
+		}
+	} else {
+		message.Data["payload"] = payload
+	}
+	e.logger.Infof("Fetched %d bytes of message payload for topic %s", len(body), message.Topic)
+	return nil
+}
+
+// fetchPayloadURL 通过HTTP GET读取外部数据
+func (e *Executor) fetchPayloadURL(ctx context.Context, url string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := (&http.Client{Transport: e.httpTransports.base}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("payload is %d bytes, exceeds nsq.max_payload_size of %d bytes", resp.ContentLength, maxSize)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("payload exceeds nsq.max_payload_size of %d bytes", maxSize)
+	}
+	return body, nil
+}
+
+// fetchPayloadObject 从对象存储数据源读取外部数据，bucket 为空时使用数据源的默认存储桶
+func (e *Executor) fetchPayloadObject(ctx context.Context, dataSource string, ref map[string]interface{}, maxSize int64) ([]byte, error) {
+	bucketName, _ := ref["bucket"].(string)
+	key, _ := ref["key"].(string)
+	if key == "" {
+		return nil, fmt.Errorf("key is required with datasource")
+	}
+
+	client, err := e.dataSourceMgr.GetS3Client(dataSource)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	object, err := client.GetObject(ctx, bucket, key, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	return object.Body, nil
+}
@@ -0,0 +1,128 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RecoverInstances 恢复服务停止时未结束的实例，应在开始消费消息之前调用
+//
+// 延迟的实例到达恢复时间后继续执行；执行中的实例按工作流的 on_restart 处理：resume（默认）从检查点
+// （最后完成的任务之后）继续执行，中断时正在执行的任务使用相同的幂等键重新执行，interrupt 标记为 interrupted。
+// 配置了并发键的实例由 RecoverQueues 按队列顺序恢复；工作流已删除的实例标记为失败。
+// 启用集群时只恢复当前节点的实例，其他节点的实例在其心跳超时后由 TakeOver 接管。
+func (e *Executor) RecoverInstances(ctx context.Context) error {
+	return e.recoverInstances(ctx, e.cfg.Cluster.NodeID)
+}
+
+// TakeOver 接管心跳超时的节点上未结束的实例，按 RecoverQueues、RecoverInstances 的规则在当前节点继续执行；
+// 该节点上未完成的批量操作标记为 interrupted
+func (e *Executor) TakeOver(ctx context.Context, nodeID string) error {
+	if err := e.interruptBulkOperations(ctx, nodeID); err != nil {
+		e.logger.Errorf("Failed to interrupt bulk operations of node %s: %v", nodeID, err)
+	}
+	if err := e.recoverQueues(ctx, nodeID); err != nil {
+		return err
+	}
+	return e.recoverInstances(ctx, nodeID)
+}
+
+// nodeFilter 返回属于指定节点的记录的查询条件，未启用集群时不按节点过滤
+func (e *Executor) nodeFilter(nodeID string) bson.M {
+	if !e.cfg.Cluster.Enabled {
+		return bson.M{}
+	}
+	if nodeID == e.cfg.Cluster.NodeID {
+		// 包括启用集群之前创建的记录
+		return bson.M{"node": bson.M{"$in": bson.A{nodeID, nil, ""}}}
+	}
+	return bson.M{"node": nodeID}
+}
+
+// recoverInstances 恢复指定节点上未结束且没有并发键的实例
+func (e *Executor) recoverInstances(ctx context.Context, nodeID string) error {
+	collection := e.mongoDB.GetDatabase().Collection("workflow_instances")
+
+	ctxDB, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	filter := e.nodeFilter(nodeID)
+	filter["status"] = bson.M{"$in": bson.A{"running", "delayed"}}
+	filter["concurrency_key"] = bson.M{"$in": bson.A{nil, ""}}
+	cursor, err := collection.Find(ctxDB, filter)
+	if err != nil {
+		return err
+	}
+	var instances []*WorkflowInstance
+	if err := cursor.All(ctxDB, &instances); err != nil {
+		return err
+	}
+
+	workflows := make(map[string]*models.WorkflowConfig)
+	resumed, interrupted := 0, 0
+	for _, instance := range instances {
+		workflowConfig, ok := workflows[instance.WorkflowID]
+		if !ok {
+			workflowConfig = e.findWorkflow(ctxDB, instance.WorkflowID)
+			workflows[instance.WorkflowID] = workflowConfig
+		}
+		if !e.recoverInstance(ctx, instance, workflowConfig, nil) {
+			interrupted++
+			continue
+		}
+		resumed++
+	}
+
+	if resumed > 0 || interrupted > 0 {
+		e.logger.Infof("Recovered workflow instances: %d resumed, %d interrupted", resumed, interrupted)
+	}
+	return nil
+}
+
+// recoverInstance 继续执行服务停止时未结束的实例，实例结束时调用 onEnd；返回false表示实例已被中断或由其他节点接管，不再执行
+func (e *Executor) recoverInstance(ctx context.Context, instance *WorkflowInstance, workflowConfig *models.WorkflowConfig, onEnd func()) bool {
+	if workflowConfig == nil {
+		e.abandonInstance(instance, "failed", fmt.Errorf("workflow %s not found", instance.WorkflowID))
+		return false
+	}
+	if instance.Status == "running" && workflowConfig.OnRestart == "interrupt" {
+		e.abandonInstance(instance, "interrupted", fmt.Errorf("interrupted by service restart at task %d", instance.NextTask))
+		return false
+	}
+	if instance.Results == nil {
+		instance.Results = make(map[string]interface{})
+	}
+	if instance.Node != e.cfg.Cluster.NodeID {
+		instance.Node = e.cfg.Cluster.NodeID
+		if err := e.saveWorkflowInstance(instance); err != nil {
+			if e.instanceConflict(instance, err) {
+				return false
+			}
+			e.logger.Errorf("Failed to save workflow instance %s: %v", instance.ID, err)
+		}
+	}
+
+	e.recordHistory(instance, HistoryEvent{Type: HistoryRecovered})
+	e.logger.Infof("Resuming workflow instance %s from task %d", instance.ID, instance.NextTask)
+	go e.executeTasks(ctx, instance, e.buildTasks(workflowConfig), instance.Message, onEnd)
+	return true
+}
+
+// abandonInstance 将无法继续执行的实例标记为结束状态
+func (e *Executor) abandonInstance(instance *WorkflowInstance, status string, err error) {
+	e.logger.Warnf("Workflow instance %s %s: %v", instance.ID, status, err)
+	instance.Status = status
+	instance.EndTime = time.Now()
+	if saveErr := e.saveWorkflowInstance(instance); saveErr != nil {
+		if e.instanceConflict(instance, saveErr) {
+			return
+		}
+		e.logger.Errorf("Failed to save workflow instance %s: %v", instance.ID, saveErr)
+	}
+	e.publishInstanceEnd(instance, err)
+}
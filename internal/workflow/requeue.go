@@ -0,0 +1,86 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"nsa/internal/models"
+)
+
+const (
+	// defaultRequeueDelay 第一次重新入队的默认延迟
+	defaultRequeueDelay = 10 * time.Second
+	// maxRequeueDelay 重新入队的最长延迟
+	maxRequeueDelay = 10 * time.Minute
+)
+
+// Outcome 执行goroutine返回时实例的结果
+type Outcome struct {
+	InstanceID string
+	// Err 实例失败的错误；实例完成、取消、延迟或进入并发键队列时为nil
+	Err error
+	// Permanent 失败不能通过重试恢复（动作不存在、条件表达式错误或匹配工作流的 requeue.permanent_errors）
+	Permanent bool
+}
+
+// permanentError 重试不能恢复的任务失败
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// outcome 按工作流的重新入队策略判断实例失败是否为永久失败
+func outcome(workflowConfig *models.WorkflowConfig, instanceID string, err error) Outcome {
+	result := Outcome{InstanceID: instanceID, Err: err}
+	if err == nil {
+		return result
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		result.Permanent = true
+		return result
+	}
+	if policy := workflowConfig.Requeue; policy != nil {
+		for _, pattern := range policy.PermanentErrors {
+			// 保存时已校验，无效的表达式忽略
+			if re, reErr := regexp.Compile(pattern); reErr == nil && re.MatchString(err.Error()) {
+				result.Permanent = true
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// ValidateRequeuePolicy 校验重新入队策略
+func ValidateRequeuePolicy(policy *models.RequeuePolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.Delay < 0 {
+		return fmt.Errorf("requeue.delay must not be negative, got %d", policy.Delay)
+	}
+	for i, pattern := range policy.PermanentErrors {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("requeue.permanent_errors[%d]: invalid regular expression: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// RequeueDelay 返回第 attempts 次投递失败后重新入队的延迟：从 requeue.delay（默认10秒）开始每次翻倍，最长10分钟
+func RequeueDelay(policy *models.RequeuePolicy, attempts uint16) time.Duration {
+	delay := defaultRequeueDelay
+	if policy != nil && policy.Delay > 0 {
+		delay = time.Duration(policy.Delay) * time.Second
+	}
+	for i := uint16(1); i < attempts && delay < maxRequeueDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRequeueDelay)
+}
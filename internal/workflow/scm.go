@@ -0,0 +1,328 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultGitHubAPI GitHub API地址
+const defaultGitHubAPI = "https://api.github.com"
+
+// defaultGitLabURL GitLab地址
+const defaultGitLabURL = "https://gitlab.com"
+
+// GitHubAction GitHub操作动作（Issue、评论、提交状态、触发Actions工作流）
+type GitHubAction struct {
+	ctx *ActionContext
+}
+
+// NewGitHubAction 创建GitHub操作动作
+func NewGitHubAction(ctx *ActionContext) *GitHubAction {
+	return &GitHubAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *GitHubAction) Name() string {
+	return "GitHubAction"
+}
+
+// Run 执行GitHub操作
+func (a *GitHubAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	baseURL, _ := params["base_url"].(string)
+	repo, _ := params["repo"].(string) // owner/name
+	tokenSecret, _ := params["token_secret"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if repo == "" || !strings.Contains(repo, "/") {
+		return fmt.Errorf("repo parameter is required in owner/name format")
+	}
+	if tokenSecret == "" {
+		return fmt.Errorf("token_secret parameter is required")
+	}
+	if baseURL == "" {
+		baseURL = defaultGitHubAPI
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	token, err := a.ctx.getSecret(ctx, tokenSecret)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	header.Set("Accept", "application/vnd.github+json")
+	header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	repoURL := strings.TrimRight(baseURL, "/") + "/repos/" + repo
+	call := func(method, path string, body, out interface{}) error {
+		return callJSON(ctx, method, repoURL+path, header, body, out, time.Duration(timeout)*time.Second)
+	}
+
+	a.ctx.Logger.Infof("Executing GitHub operation %s on %s", operation, repo)
+
+	var result map[string]interface{}
+	switch operation {
+	case "create_issue":
+		title, _ := params["title"].(string)
+		if title == "" {
+			return fmt.Errorf("title parameter is required for create_issue")
+		}
+		body := map[string]interface{}{"title": title}
+		copyParams(params, body, "body", "labels", "assignees", "milestone")
+
+		var issue struct {
+			Number  int    `json:"number"`
+			HTMLURL string `json:"html_url"`
+		}
+		if err := call(http.MethodPost, "/issues", body, &issue); err != nil {
+			return fmt.Errorf("failed to create github issue: %v", err)
+		}
+		result = map[string]interface{}{"number": issue.Number, "url": issue.HTMLURL}
+
+	case "comment":
+		number := paramString(params, "number")
+		body, _ := params["body"].(string)
+		if number == "" || body == "" {
+			return fmt.Errorf("number and body parameters are required for comment")
+		}
+
+		var comment struct {
+			ID      int64  `json:"id"`
+			HTMLURL string `json:"html_url"`
+		}
+		if err := call(http.MethodPost, "/issues/"+number+"/comments", map[string]interface{}{"body": body}, &comment); err != nil {
+			return fmt.Errorf("failed to comment on github issue %s: %v", number, err)
+		}
+		result = map[string]interface{}{"comment_id": comment.ID, "url": comment.HTMLURL}
+
+	case "set_status":
+		sha, _ := params["sha"].(string)
+		state, _ := params["state"].(string) // error, failure, pending, success
+		if sha == "" || state == "" {
+			return fmt.Errorf("sha and state parameters are required for set_status")
+		}
+		body := map[string]interface{}{"state": state, "context": "nsa"}
+		copyParams(params, body, "context", "description", "target_url")
+
+		if err := call(http.MethodPost, "/statuses/"+url.PathEscape(sha), body, nil); err != nil {
+			return fmt.Errorf("failed to set github commit status: %v", err)
+		}
+		result = map[string]interface{}{"sha": sha, "state": state, "context": body["context"]}
+
+	case "dispatch_workflow":
+		workflow := paramString(params, "workflow") // 文件名或ID
+		ref, _ := params["ref"].(string)
+		if workflow == "" || ref == "" {
+			return fmt.Errorf("workflow and ref parameters are required for dispatch_workflow")
+		}
+		body := map[string]interface{}{"ref": ref}
+		if inputs, ok := params["inputs"].(map[string]interface{}); ok {
+			// workflow_dispatch 的输入只接受字符串
+			stringInputs := make(map[string]interface{}, len(inputs))
+			for key, value := range inputs {
+				stringInputs[key] = stringifyValue(value)
+			}
+			body["inputs"] = stringInputs
+		}
+
+		if err := call(http.MethodPost, "/actions/workflows/"+url.PathEscape(workflow)+"/dispatches", body, nil); err != nil {
+			return fmt.Errorf("failed to dispatch github workflow %s: %v", workflow, err)
+		}
+		result = map[string]interface{}{"workflow": workflow, "ref": ref, "dispatched": true}
+
+	default:
+		return fmt.Errorf("unsupported github operation: %s", operation)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("GitHub operation %s completed successfully", operation)
+
+	return nil
+}
+
+// GitLabAction GitLab操作动作（Issue、评论、提交状态、触发流水线）
+type GitLabAction struct {
+	ctx *ActionContext
+}
+
+// NewGitLabAction 创建GitLab操作动作
+func NewGitLabAction(ctx *ActionContext) *GitLabAction {
+	return &GitLabAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *GitLabAction) Name() string {
+	return "GitLabAction"
+}
+
+// Run 执行GitLab操作
+func (a *GitLabAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	baseURL, _ := params["base_url"].(string)
+	project := paramString(params, "project") // ID或 group/name
+	tokenSecret, _ := params["token_secret"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if project == "" {
+		return fmt.Errorf("project parameter is required")
+	}
+	if tokenSecret == "" {
+		return fmt.Errorf("token_secret parameter is required")
+	}
+	if baseURL == "" {
+		baseURL = defaultGitLabURL
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	token, err := a.ctx.getSecret(ctx, tokenSecret)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("PRIVATE-TOKEN", token)
+
+	projectURL := strings.TrimRight(baseURL, "/") + "/api/v4/projects/" + url.PathEscape(project)
+	call := func(method, path string, body, out interface{}) error {
+		return callJSON(ctx, method, projectURL+path, header, body, out, time.Duration(timeout)*time.Second)
+	}
+
+	a.ctx.Logger.Infof("Executing GitLab operation %s on %s", operation, project)
+
+	var result map[string]interface{}
+	switch operation {
+	case "create_issue":
+		title, _ := params["title"].(string)
+		if title == "" {
+			return fmt.Errorf("title parameter is required for create_issue")
+		}
+		body := map[string]interface{}{"title": title}
+		copyParams(params, body, "description", "confidential", "assignee_ids")
+		if labels, ok := params["labels"].([]interface{}); ok {
+			var names []string
+			for _, label := range labels {
+				names = append(names, stringifyValue(label))
+			}
+			body["labels"] = strings.Join(names, ",")
+		}
+
+		var issue struct {
+			IID    int    `json:"iid"`
+			WebURL string `json:"web_url"`
+		}
+		if err := call(http.MethodPost, "/issues", body, &issue); err != nil {
+			return fmt.Errorf("failed to create gitlab issue: %v", err)
+		}
+		result = map[string]interface{}{"iid": issue.IID, "url": issue.WebURL}
+
+	case "comment":
+		iid := paramString(params, "iid")
+		body, _ := params["body"].(string)
+		target, _ := params["target"].(string) // issue(默认) 或 merge_request
+		if iid == "" || body == "" {
+			return fmt.Errorf("iid and body parameters are required for comment")
+		}
+		resource := "/issues/"
+		if target == "merge_request" {
+			resource = "/merge_requests/"
+		}
+
+		var note struct {
+			ID int64 `json:"id"`
+		}
+		if err := call(http.MethodPost, resource+iid+"/notes", map[string]interface{}{"body": body}, &note); err != nil {
+			return fmt.Errorf("failed to comment on gitlab %s %s: %v", strings.Trim(resource, "/"), iid, err)
+		}
+		result = map[string]interface{}{"note_id": note.ID}
+
+	case "set_status":
+		sha, _ := params["sha"].(string)
+		state, _ := params["state"].(string) // pending, running, success, failed, canceled
+		if sha == "" || state == "" {
+			return fmt.Errorf("sha and state parameters are required for set_status")
+		}
+		body := map[string]interface{}{"state": state, "name": "nsa"}
+		copyParams(params, body, "name", "ref", "description", "target_url")
+
+		if err := call(http.MethodPost, "/statuses/"+url.PathEscape(sha), body, nil); err != nil {
+			return fmt.Errorf("failed to set gitlab commit status: %v", err)
+		}
+		result = map[string]interface{}{"sha": sha, "state": state, "name": body["name"]}
+
+	case "trigger_pipeline":
+		ref, _ := params["ref"].(string)
+		if ref == "" {
+			return fmt.Errorf("ref parameter is required for trigger_pipeline")
+		}
+		body := map[string]interface{}{"ref": ref}
+		if variables, ok := params["variables"].(map[string]interface{}); ok {
+			var items []interface{}
+			for key, value := range variables {
+				items = append(items, map[string]interface{}{"key": key, "value": stringifyValue(value)})
+			}
+			body["variables"] = items
+		}
+
+		var pipeline struct {
+			ID     int64  `json:"id"`
+			Status string `json:"status"`
+			WebURL string `json:"web_url"`
+		}
+		if err := call(http.MethodPost, "/pipeline", body, &pipeline); err != nil {
+			return fmt.Errorf("failed to trigger gitlab pipeline: %v", err)
+		}
+		result = map[string]interface{}{"pipeline_id": pipeline.ID, "status": pipeline.Status, "url": pipeline.WebURL}
+
+	default:
+		return fmt.Errorf("unsupported gitlab operation: %s", operation)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("GitLab operation %s completed successfully", operation)
+
+	return nil
+}
+
+// copyParams 将存在的参数复制到请求体
+func copyParams(params, body map[string]interface{}, keys ...string) {
+	for _, key := range keys {
+		if value, ok := params[key]; ok && value != nil && value != "" {
+			body[key] = value
+		}
+	}
+}
+
+// paramString 读取字符串或数字参数（如Issue编号、项目ID）
+func paramString(params map[string]interface{}, key string) string {
+	switch v := params[key].(type) {
+	case string:
+		return v
+	case float64, int, int64, int32:
+		return stringifyValue(v)
+	}
+	return ""
+}
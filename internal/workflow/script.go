@@ -0,0 +1,138 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+
+	"github.com/buke/quickjs-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// scriptTTL 脚本模块缓存的有效期，其他服务副本修改脚本后最迟在有效期后生效
+const scriptTTL = 30 * time.Second
+
+// scriptValidateTimeout 校验脚本语法的超时
+const scriptValidateTimeout = 5 * time.Second
+
+// requirePrelude 定义 require(name)：按名称加载脚本模块，以 CommonJS 方式执行并返回 module.exports；
+// 同一次执行中模块只执行一次，循环引用时返回未完成的 exports
+const requirePrelude = `(function () {
+	var load = globalThis.__nsa_script_source;
+	delete globalThis.__nsa_script_source;
+	var cache = {};
+	globalThis.require = function (name) {
+		if (Object.prototype.hasOwnProperty.call(cache, name)) {
+			return cache[name].exports;
+		}
+		var module = { exports: {} };
+		cache[name] = module;
+		var fn = new Function("module", "exports", "require", load(name));
+		fn.call(module.exports, module, module.exports, globalThis.require);
+		return module.exports;
+	};
+})();`
+
+// cachedScript 缓存的脚本代码
+type cachedScript struct {
+	code      string
+	expiresAt time.Time
+}
+
+// scriptStore 从MongoDB的 scripts 集合读取脚本模块，按名称缓存
+type scriptStore struct {
+	mongoDB *mongodb.Client
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]cachedScript
+}
+
+// newScriptStore 创建脚本模块存储
+func newScriptStore(mongoDB *mongodb.Client, ttl time.Duration) *scriptStore {
+	return &scriptStore{mongoDB: mongoDB, ttl: ttl, entries: make(map[string]cachedScript)}
+}
+
+// get 返回脚本模块的代码
+func (s *scriptStore) get(ctx context.Context, name string) (string, error) {
+	s.mu.RLock()
+	entry, ok := s.entries[name]
+	s.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.code, nil
+	}
+	if s.mongoDB == nil {
+		return "", fmt.Errorf("script modules are not available")
+	}
+
+	var script models.Script
+	collection := s.mongoDB.GetDatabase().Collection("scripts")
+	err := s.mongoDB.Retry(ctx, 5*time.Second, func(ctx context.Context) error {
+		return collection.FindOne(ctx, bson.M{"name": name}).Decode(&script)
+	})
+	if err == mongo.ErrNoDocuments {
+		return "", fmt.Errorf("script module %s not found", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load script module %s: %v", name, err)
+	}
+
+	s.mu.Lock()
+	s.entries[name] = cachedScript{code: script.Code, expiresAt: time.Now().Add(s.ttl)}
+	s.mu.Unlock()
+	return script.Code, nil
+}
+
+// clear 清空缓存
+func (s *scriptStore) clear() {
+	s.mu.Lock()
+	s.entries = make(map[string]cachedScript)
+	s.mu.Unlock()
+}
+
+// installRequire 在JS上下文中定义 require 函数，模块代码从脚本存储读取
+func installRequire(ctx context.Context, jsCtx *quickjs.Context, scripts *scriptStore) error {
+	load := jsCtx.Function(func(jsCtx *quickjs.Context, this quickjs.Value, args []quickjs.Value) quickjs.Value {
+		if len(args) == 0 || !args[0].IsString() {
+			return jsCtx.ThrowTypeError("require: module name must be a string")
+		}
+		if scripts == nil {
+			return jsCtx.ThrowError(fmt.Errorf("script modules are not available"))
+		}
+		code, err := scripts.get(ctx, args[0].String())
+		if err != nil {
+			return jsCtx.ThrowError(err)
+		}
+		return jsCtx.String(code)
+	})
+	jsCtx.Globals().Set("__nsa_script_source", load)
+
+	result, err := jsCtx.Eval(requirePrelude)
+	if err != nil {
+		return err
+	}
+	result.Free()
+	return nil
+}
+
+// ValidateScript 检查脚本模块的语法，语法错误时返回错误
+func (e *Executor) ValidateScript(code string) error {
+	return e.jsPool.run(context.Background(), scriptValidateTimeout, func(jsCtx *quickjs.Context) error {
+		jsCtx.Globals().Set("__nsa_source", jsCtx.String(code))
+		result, err := jsCtx.Eval(`new Function("module", "exports", "require", __nsa_source)`)
+		if err != nil {
+			return err
+		}
+		result.Free()
+		return nil
+	})
+}
+
+// InvalidateScripts 清空脚本模块缓存，脚本创建、修改、删除后调用
+func (e *Executor) InvalidateScripts() {
+	e.scripts.clear()
+}
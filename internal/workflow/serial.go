@@ -0,0 +1,322 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nsa/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxQueueStatsKeys 指标中列出的并发键数量上限（按延迟从大到小）
+const maxQueueStatsKeys = 100
+
+// keyedEntry 并发键队列中的一个函数及其入队时间
+type keyedEntry struct {
+	fn         func(done func())
+	enqueuedAt time.Time
+}
+
+// keyedQueue 按键串行执行函数：同一个键的函数按提交顺序依次执行，不同键之间并行
+//
+// 函数调用 done 之后才开始执行同一个键上的下一个函数，done 可以在函数返回之后调用（如实例延迟执行时）。
+type keyedQueue struct {
+	mu     sync.Mutex
+	queues map[string][]keyedEntry
+}
+
+// newKeyedQueue 创建按键串行的执行队列
+func newKeyedQueue() *keyedQueue {
+	return &keyedQueue{queues: make(map[string][]keyedEntry)}
+}
+
+// submit 提交函数，返回是否需要等待同一个键上正在执行的函数
+func (q *keyedQueue) submit(key string, enqueuedAt time.Time, fn func(done func())) bool {
+	q.mu.Lock()
+	queue, busy := q.queues[key]
+	q.queues[key] = append(queue, keyedEntry{fn: fn, enqueuedAt: enqueuedAt})
+	q.mu.Unlock()
+
+	if !busy {
+		go q.run(key)
+	}
+	return busy
+}
+
+// run 执行键上的第一个函数
+func (q *keyedQueue) run(key string) {
+	q.mu.Lock()
+	entry := q.queues[key][0]
+	q.mu.Unlock()
+
+	var once sync.Once
+	entry.fn(func() { once.Do(func() { q.next(key) }) })
+}
+
+// next 移除已完成的函数并执行键上的下一个函数，队列为空时删除该键
+func (q *keyedQueue) next(key string) {
+	q.mu.Lock()
+	queue := q.queues[key][1:]
+	if len(queue) == 0 {
+		delete(q.queues, key)
+		q.mu.Unlock()
+		return
+	}
+	q.queues[key] = queue
+	q.mu.Unlock()
+
+	go q.run(key)
+}
+
+// KeyQueueStats 单个并发键的队列状态
+type KeyQueueStats struct {
+	WorkflowID string    `json:"workflow_id"`
+	Key        string    `json:"key"`
+	Pending    int       `json:"pending"`     // 等待执行的实例数（不含正在执行的实例）
+	LagSeconds float64   `json:"lag_seconds"` // 最早的等待实例已等待的时间
+	Oldest     time.Time `json:"oldest"`      // 最早的等待实例的入队时间
+}
+
+// QueueStats 并发键队列的汇总状态
+type QueueStats struct {
+	Keys          int             `json:"keys"`            // 有实例正在执行或等待的键数
+	Pending       int             `json:"pending"`         // 所有键上等待执行的实例数
+	MaxLagSeconds float64         `json:"max_lag_seconds"` // 所有键中最大的等待时间
+	Lagging       []KeyQueueStats `json:"lagging"`         // 有等待实例的键，按等待时间从大到小，最多100个
+}
+
+// stats 统计各键的等待实例数和等待时间
+func (q *keyedQueue) stats(now time.Time) QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := QueueStats{Keys: len(q.queues), Lagging: []KeyQueueStats{}}
+	for key, queue := range q.queues {
+		// 第一个为正在执行的实例
+		if len(queue) < 2 {
+			continue
+		}
+		workflowID, concurrencyKey, _ := strings.Cut(key, "/")
+		lag := now.Sub(queue[1].enqueuedAt).Seconds()
+		stats.Pending += len(queue) - 1
+		if lag > stats.MaxLagSeconds {
+			stats.MaxLagSeconds = lag
+		}
+		stats.Lagging = append(stats.Lagging, KeyQueueStats{
+			WorkflowID: workflowID,
+			Key:        concurrencyKey,
+			Pending:    len(queue) - 1,
+			LagSeconds: lag,
+			Oldest:     queue[1].enqueuedAt,
+		})
+	}
+
+	sort.Slice(stats.Lagging, func(i, j int) bool {
+		return stats.Lagging[i].LagSeconds > stats.Lagging[j].LagSeconds
+	})
+	if len(stats.Lagging) > maxQueueStatsKeys {
+		stats.Lagging = stats.Lagging[:maxQueueStatsKeys]
+	}
+	return stats
+}
+
+// queuedInstance 并发键队列中持久化的实例，实例结束后删除，服务重启时按入队顺序恢复
+type queuedInstance struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	WorkflowID string             `bson:"workflow_id"`
+	Key        string             `bson:"key"`
+	InstanceID string             `bson:"instance_id"`
+	Message    *models.NSQMessage `bson:"message"`
+	EnqueuedAt time.Time          `bson:"enqueued_at"`
+	Node       string             `bson:"node,omitempty"`
+}
+
+// QueueStats 返回并发键队列的状态
+func (e *Executor) QueueStats() QueueStats {
+	return e.serial.stats(time.Now())
+}
+
+// enqueue 将实例加入并发键队列，同一工作流中并发键相同的实例按入队顺序串行执行
+//
+// 入队记录持久化到 keyed_queue 集合，实例结束后删除；写入失败时仍在内存中排队，但重启后不会恢复。
+func (e *Executor) enqueue(ctx context.Context, instance *WorkflowInstance, tasks []Task, nsqMessage *models.NSQMessage) {
+	entry := &queuedInstance{
+		ID:         primitive.NewObjectID(),
+		WorkflowID: instance.WorkflowID,
+		Key:        instance.ConcurrencyKey,
+		InstanceID: instance.ID,
+		Message:    nsqMessage,
+		EnqueuedAt: time.Now(),
+		Node:       instance.Node,
+	}
+	collection := e.mongoDB.GetDatabase().Collection("keyed_queue")
+	ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := collection.InsertOne(ctxDB, entry)
+	cancel()
+	if err != nil {
+		e.logger.Errorf("Failed to persist queued instance %s: %v", instance.ID, err)
+	}
+
+	e.submit(ctx, entry, instance, tasks)
+}
+
+// submit 将已持久化的入队记录提交到内存队列
+func (e *Executor) submit(ctx context.Context, entry *queuedInstance, instance *WorkflowInstance, tasks []Task) {
+	key := entry.WorkflowID + "/" + entry.Key
+	busy := e.serial.submit(key, entry.EnqueuedAt, func(done func()) {
+		onEnd := func() {
+			collection := e.mongoDB.GetDatabase().Collection("keyed_queue")
+			ctxDB, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := collection.DeleteOne(ctxDB, bson.M{"_id": entry.ID}); err != nil {
+				e.logger.Errorf("Failed to remove queued instance %s: %v", instance.ID, err)
+			}
+			done()
+		}
+		// 排队期间被取消的实例不再执行
+		if e.instanceStatus(instance.ID) == "cancelled" {
+			e.logger.Infof("Workflow instance %s was cancelled while queued", instance.ID)
+			onEnd()
+			return
+		}
+		// 延迟的实例继续占用并发键，恢复执行并结束后才执行下一个实例
+		e.executeTasks(ctx, instance, tasks, entry.Message, onEnd)
+	})
+	if busy {
+		e.logger.Infof("Workflow instance %s queued behind concurrency key %s", instance.ID, entry.Key)
+	}
+}
+
+// RecoverQueues 恢复服务停止时仍在并发键队列中的实例，应在开始消费消息之前调用
+//
+// 按入队顺序重新提交：每个键上第一个实例可能已经开始执行，按工作流的 on_restart 从检查点继续执行或标记为
+// interrupted（见 RecoverInstances），其余的实例尚未开始，继续排队；已结束的实例和已删除的工作流的记录直接移除。
+//
+// 启用集群时只恢复当前节点的队列，其他节点的队列在其心跳超时后由 TakeOver 接管。
+func (e *Executor) RecoverQueues(ctx context.Context) error {
+	return e.recoverQueues(ctx, e.cfg.Cluster.NodeID)
+}
+
+// recoverQueues 恢复指定节点的并发键队列
+func (e *Executor) recoverQueues(ctx context.Context, nodeID string) error {
+	db := e.mongoDB.GetDatabase()
+	collection := db.Collection("keyed_queue")
+
+	ctxDB, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "enqueued_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := collection.Find(ctxDB, e.nodeFilter(nodeID), opts)
+	if err != nil {
+		return err
+	}
+	var entries []*queuedInstance
+	if err := cursor.All(ctxDB, &entries); err != nil {
+		return err
+	}
+
+	workflows := make(map[string]*models.WorkflowConfig)
+	heads := make(map[string]bool)
+	recovered := 0
+	for _, entry := range entries {
+		workflowConfig, ok := workflows[entry.WorkflowID]
+		if !ok {
+			workflowConfig = e.findWorkflow(ctxDB, entry.WorkflowID)
+			workflows[entry.WorkflowID] = workflowConfig
+		}
+
+		var instance WorkflowInstance
+		err := db.Collection("workflow_instances").FindOne(ctxDB, bson.M{"_id": entry.InstanceID}).Decode(&instance)
+		if workflowConfig == nil || err != nil || (instance.Status != "running" && instance.Status != "delayed") {
+			if _, err := collection.DeleteOne(ctxDB, bson.M{"_id": entry.ID}); err != nil {
+				e.logger.Errorf("Failed to remove queued instance %s: %v", entry.InstanceID, err)
+			}
+			continue
+		}
+
+		// 只有键上的第一个实例可能在服务停止时正在执行
+		key := entry.WorkflowID + "/" + entry.Key
+		if !heads[key] {
+			heads[key] = true
+			if instance.Status == "running" && workflowConfig.OnRestart == "interrupt" {
+				e.abandonInstance(&instance, "interrupted", fmt.Errorf("interrupted by service restart at task %d", instance.NextTask))
+				if _, err := collection.DeleteOne(ctxDB, bson.M{"_id": entry.ID}); err != nil {
+					e.logger.Errorf("Failed to remove queued instance %s: %v", entry.InstanceID, err)
+				}
+				heads[key] = false
+				continue
+			}
+		}
+		if instance.Results == nil {
+			instance.Results = make(map[string]interface{})
+		}
+		if entry.Node != e.cfg.Cluster.NodeID {
+			// 先接管实例，实例已被其他节点接管时不修改队列记录
+			instance.Node = e.cfg.Cluster.NodeID
+			if err := e.saveWorkflowInstance(&instance); err != nil {
+				if e.instanceConflict(&instance, err) {
+					continue
+				}
+				e.logger.Errorf("Failed to save workflow instance %s: %v", instance.ID, err)
+			}
+			entry.Node = instance.Node
+			if _, err := collection.UpdateOne(ctxDB, bson.M{"_id": entry.ID}, bson.M{"$set": bson.M{"node": entry.Node}}); err != nil {
+				e.logger.Errorf("Failed to reassign queued instance %s: %v", entry.InstanceID, err)
+			}
+		}
+		e.submit(ctx, entry, &instance, e.buildTasks(workflowConfig))
+		recovered++
+	}
+
+	if recovered > 0 {
+		e.logger.Infof("Recovered %d queued workflow instances", recovered)
+	}
+	return nil
+}
+
+// findWorkflow 按ID查找工作流，不存在时返回nil
+func (e *Executor) findWorkflow(ctx context.Context, workflowID string) *models.WorkflowConfig {
+	objectID, err := primitive.ObjectIDFromHex(workflowID)
+	if err != nil {
+		return nil
+	}
+	var workflowConfig models.WorkflowConfig
+	err = e.mongoDB.Retry(ctx, 5*time.Second, func(ctx context.Context) error {
+		return e.mongoDB.GetCollection().FindOne(ctx, bson.M{"_id": objectID}).Decode(&workflowConfig)
+	})
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			e.logger.Errorf("Failed to find workflow %s: %v", workflowID, err)
+		}
+		return nil
+	}
+	return &workflowConfig
+}
+
+// concurrencyKey 使用消息数据和工作流变量渲染并发键模板
+//
+// 未配置并发键或模板变量无法解析时返回空字符串，实例不串行执行。
+func (e *Executor) concurrencyKey(workflowConfig *models.WorkflowConfig, instance *WorkflowInstance, nsqMessage *models.NSQMessage) string {
+	if workflowConfig.ConcurrencyKey == "" {
+		return ""
+	}
+	taskCtx := &TaskContext{
+		message: nsqMessage,
+		vars:    instance.Vars,
+		results: instance.Results,
+	}
+	key := strings.TrimSpace(renderTemplate(workflowConfig.ConcurrencyKey, taskCtx))
+	if key == "" || templatePattern.MatchString(key) {
+		e.logger.Warnf("Workflow %s concurrency key %q could not be resolved, running without serialization", workflowConfig.ID.Hex(), workflowConfig.ConcurrencyKey)
+		return ""
+	}
+	return key
+}
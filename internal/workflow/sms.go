@@ -0,0 +1,511 @@
+package workflow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 短信网关地址
+const (
+	defaultTwilioURL  = "https://api.twilio.com"
+	defaultAliyunURL  = "https://dysmsapi.aliyuncs.com"
+	defaultTencentURL = "https://sms.tencentcloudapi.com"
+)
+
+// SMSAction 短信/语音通知动作
+//
+// 支持 Twilio 兼容接口（短信和语音呼叫）以及阿里云、腾讯云短信，
+// 按号码限制发送频率，避免告警风暴时重复通知。
+type SMSAction struct {
+	ctx     *ActionContext
+	limiter *smsRateLimiter
+}
+
+// NewSMSAction 创建短信通知动作
+func NewSMSAction(ctx *ActionContext) *SMSAction {
+	return &SMSAction{
+		ctx:     ctx,
+		limiter: &smsRateLimiter{sent: make(map[string][]time.Time)},
+	}
+}
+
+// Name 返回动作名称
+func (a *SMSAction) Name() string {
+	return "SMSAction"
+}
+
+// smsMessage 待发送的短信
+type smsMessage struct {
+	to     []string
+	params map[string]interface{}
+}
+
+// Run 执行短信发送
+func (a *SMSAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	provider, _ := params["provider"].(string)
+	timeout, _ := params["timeout"].(float64)
+	if provider != "twilio" && provider != "aliyun" && provider != "tencent" {
+		return fmt.Errorf("unsupported sms provider: %s", provider)
+	}
+	if timeout == 0 {
+		timeout = 30
+	}
+
+	var destinations []string
+	switch to := params["to"].(type) {
+	case string:
+		for _, number := range strings.Split(to, ",") {
+			if number = strings.TrimSpace(number); number != "" {
+				destinations = append(destinations, number)
+			}
+		}
+	case []interface{}:
+		for _, number := range to {
+			if s := strings.TrimSpace(stringifyValue(number)); s != "" {
+				destinations = append(destinations, s)
+			}
+		}
+	}
+	if len(destinations) == 0 {
+		return fmt.Errorf("to parameter is required")
+	}
+
+	// 按号码限流：rate_limit.max 条 / rate_limit.window 秒
+	allowed, limited := destinations, []string{}
+	if rateLimit, ok := params["rate_limit"].(map[string]interface{}); ok {
+		max, _ := rateLimit["max"].(float64)
+		window, _ := rateLimit["window"].(float64)
+		if max > 0 && window > 0 {
+			allowed = nil
+			for _, number := range destinations {
+				if a.limiter.allow(provider+":"+number, int(max), time.Duration(window)*time.Second) {
+					allowed = append(allowed, number)
+				} else {
+					limited = append(limited, number)
+				}
+			}
+		}
+	}
+	if len(limited) > 0 {
+		a.ctx.Logger.Warnf("SMS rate limit reached for %s", strings.Join(limited, ", "))
+	}
+
+	result := map[string]interface{}{
+		"provider":     provider,
+		"sent":         []interface{}{},
+		"rate_limited": limited,
+	}
+	if len(allowed) == 0 {
+		taskCtx.SetOutput(result)
+		return nil
+	}
+
+	msg := &smsMessage{to: allowed, params: params}
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	a.ctx.Logger.Infof("Sending %s notification to %d destination(s)", provider, len(allowed))
+
+	var sent []interface{}
+	var err error
+	switch provider {
+	case "twilio":
+		sent, err = a.sendTwilio(ctx, client, msg)
+	case "aliyun":
+		sent, err = a.sendAliyun(ctx, client, msg)
+	case "tencent":
+		sent, err = a.sendTencent(ctx, client, msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 保存结果
+	result["sent"] = sent
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("%s notification sent to %d destination(s)", provider, len(sent))
+
+	return nil
+}
+
+// sendTwilio 通过Twilio兼容接口发送短信或语音呼叫
+func (a *SMSAction) sendTwilio(ctx context.Context, client *http.Client, msg *smsMessage) ([]interface{}, error) {
+	baseURL, _ := msg.params["base_url"].(string)
+	accountSID, _ := msg.params["account_sid"].(string)
+	tokenSecret, _ := msg.params["auth_token_secret"].(string)
+	from, _ := msg.params["from"].(string)
+	messagingService, _ := msg.params["messaging_service_sid"].(string)
+	body, _ := msg.params["body"].(string)
+	channel, _ := msg.params["channel"].(string) // sms(默认) 或 voice
+
+	if accountSID == "" || tokenSecret == "" {
+		return nil, fmt.Errorf("account_sid and auth_token_secret parameters are required for twilio")
+	}
+	if from == "" && messagingService == "" {
+		return nil, fmt.Errorf("from or messaging_service_sid parameter is required for twilio")
+	}
+	if body == "" {
+		return nil, fmt.Errorf("body parameter is required for twilio")
+	}
+	if baseURL == "" {
+		baseURL = defaultTwilioURL
+	}
+
+	authToken, err := a.ctx.getSecret(ctx, tokenSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := "Messages.json"
+	if channel == "voice" {
+		resource = "Calls.json"
+		if from == "" {
+			return nil, fmt.Errorf("from parameter is required for twilio voice calls")
+		}
+	}
+	endpoint := strings.TrimRight(baseURL, "/") + "/2010-04-01/Accounts/" + url.PathEscape(accountSID) + "/" + resource
+
+	var sent []interface{}
+	for _, to := range msg.to {
+		form := url.Values{"To": {to}}
+		if from != "" {
+			form.Set("From", from)
+		}
+		if channel == "voice" {
+			form.Set("Twiml", twimlSay(body, msg.params))
+		} else {
+			if messagingService != "" {
+				form.Set("MessagingServiceSid", messagingService)
+			}
+			form.Set("Body", body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return sent, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.SetBasicAuth(accountSID, authToken)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var resp struct {
+			SID    string `json:"sid"`
+			Status string `json:"status"`
+		}
+		if err := doSMSRequest(client, req, &resp); err != nil {
+			return sent, fmt.Errorf("failed to send twilio %s to %s: %v", resource, to, err)
+		}
+		sent = append(sent, map[string]interface{}{"to": to, "id": resp.SID, "status": resp.Status})
+	}
+	return sent, nil
+}
+
+// twimlSay 生成语音播报的TwiML
+func twimlSay(text string, params map[string]interface{}) string {
+	escape := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	attrs := ""
+	if voice, _ := params["voice"].(string); voice != "" {
+		attrs += fmt.Sprintf(` voice="%s"`, escape(voice))
+	}
+	if language, _ := params["language"].(string); language != "" {
+		attrs += fmt.Sprintf(` language="%s"`, escape(language))
+	}
+	loop := 1
+	if repeat, ok := params["repeat"].(float64); ok && repeat > 0 {
+		loop = int(repeat)
+	}
+	return fmt.Sprintf(`<Response><Say%s loop="%d">%s</Say></Response>`, attrs, loop, escape(text))
+}
+
+// sendAliyun 通过阿里云短信服务发送模板短信
+func (a *SMSAction) sendAliyun(ctx context.Context, client *http.Client, msg *smsMessage) ([]interface{}, error) {
+	baseURL, _ := msg.params["base_url"].(string)
+	accessKeyID, _ := msg.params["access_key_id"].(string)
+	keySecretName, _ := msg.params["access_key_secret"].(string)
+	signName, _ := msg.params["sign_name"].(string)
+	templateCode, _ := msg.params["template_code"].(string)
+
+	if accessKeyID == "" || keySecretName == "" {
+		return nil, fmt.Errorf("access_key_id and access_key_secret parameters are required for aliyun")
+	}
+	if signName == "" || templateCode == "" {
+		return nil, fmt.Errorf("sign_name and template_code parameters are required for aliyun")
+	}
+	if baseURL == "" {
+		baseURL = defaultAliyunURL
+	}
+
+	accessKeySecret, err := a.ctx.getSecret(ctx, keySecretName)
+	if err != nil {
+		return nil, err
+	}
+
+	query := map[string]string{
+		"AccessKeyId":      accessKeyID,
+		"Action":           "SendSms",
+		"Format":           "JSON",
+		"RegionId":         "cn-hangzhou",
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   primitive.NewObjectID().Hex(),
+		"SignatureVersion": "1.0",
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Version":          "2017-05-25",
+		"PhoneNumbers":     strings.Join(msg.to, ","),
+		"SignName":         signName,
+		"TemplateCode":     templateCode,
+	}
+	if templateParams, ok := msg.params["template_params"].(map[string]interface{}); ok {
+		// 阿里云模板变量只接受字符串
+		values := make(map[string]string, len(templateParams))
+		for key, value := range templateParams {
+			values[key] = stringifyValue(value)
+		}
+		data, _ := json.Marshal(values)
+		query["TemplateParam"] = string(data)
+	}
+
+	// RPC签名：按参数名排序后规范化，HMAC-SHA1
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var canonical []string
+	for _, key := range keys {
+		canonical = append(canonical, aliyunEncode(key)+"="+aliyunEncode(query[key]))
+	}
+	canonicalQuery := strings.Join(canonical, "&")
+	stringToSign := "GET&" + aliyunEncode("/") + "&" + aliyunEncode(canonicalQuery)
+	mac := hmac.New(sha1.New, []byte(accessKeySecret+"&"))
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	endpoint := strings.TrimRight(baseURL, "/") + "/?Signature=" + aliyunEncode(signature) + "&" + canonicalQuery
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	var resp struct {
+		Code      string `json:"Code"`
+		Message   string `json:"Message"`
+		BizID     string `json:"BizId"`
+		RequestID string `json:"RequestId"`
+	}
+	if err := doSMSRequest(client, req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to send aliyun sms: %v", err)
+	}
+	if resp.Code != "OK" {
+		return nil, fmt.Errorf("aliyun sms rejected: %s %s", resp.Code, resp.Message)
+	}
+
+	var sent []interface{}
+	for _, to := range msg.to {
+		sent = append(sent, map[string]interface{}{"to": to, "id": resp.BizID, "status": resp.Code})
+	}
+	return sent, nil
+}
+
+// aliyunEncode 阿里云签名使用的百分号编码（RFC3986）
+func aliyunEncode(s string) string {
+	encoded := url.QueryEscape(s)
+	encoded = strings.ReplaceAll(encoded, "+", "%20")
+	encoded = strings.ReplaceAll(encoded, "*", "%2A")
+	return strings.ReplaceAll(encoded, "%7E", "~")
+}
+
+// sendTencent 通过腾讯云短信服务发送模板短信
+func (a *SMSAction) sendTencent(ctx context.Context, client *http.Client, msg *smsMessage) ([]interface{}, error) {
+	baseURL, _ := msg.params["base_url"].(string)
+	secretID, _ := msg.params["secret_id"].(string)
+	keySecretName, _ := msg.params["secret_key_secret"].(string)
+	appID := paramString(msg.params, "sdk_app_id")
+	signName, _ := msg.params["sign_name"].(string)
+	templateID := paramString(msg.params, "template_id")
+	region, _ := msg.params["region"].(string)
+
+	if secretID == "" || keySecretName == "" {
+		return nil, fmt.Errorf("secret_id and secret_key_secret parameters are required for tencent")
+	}
+	if appID == "" || signName == "" || templateID == "" {
+		return nil, fmt.Errorf("sdk_app_id, sign_name and template_id parameters are required for tencent")
+	}
+	if baseURL == "" {
+		baseURL = defaultTencentURL
+	}
+	if region == "" {
+		region = "ap-guangzhou"
+	}
+
+	secretKey, err := a.ctx.getSecret(ctx, keySecretName)
+	if err != nil {
+		return nil, err
+	}
+
+	templateParams := []string{}
+	if values, ok := msg.params["template_params"].([]interface{}); ok {
+		for _, value := range values {
+			templateParams = append(templateParams, stringifyValue(value))
+		}
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"PhoneNumberSet":   msg.to,
+		"SmsSdkAppId":      appID,
+		"SignName":         signName,
+		"TemplateId":       templateID,
+		"TemplateParamSet": templateParams,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %v", err)
+	}
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base_url: %v", err)
+	}
+	now := time.Now().UTC()
+	authorization := tencentAuthorization(secretID, secretKey, parsed.Host, payload, now)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/", strings.NewReader(string(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Action", "SendSms")
+	req.Header.Set("X-TC-Version", "2021-01-11")
+	req.Header.Set("X-TC-Region", region)
+	req.Header.Set("X-TC-Timestamp", fmt.Sprintf("%d", now.Unix()))
+
+	var resp struct {
+		Response struct {
+			SendStatusSet []struct {
+				SerialNo    string `json:"SerialNo"`
+				PhoneNumber string `json:"PhoneNumber"`
+				Code        string `json:"Code"`
+				Message     string `json:"Message"`
+			} `json:"SendStatusSet"`
+			Error *struct {
+				Code    string `json:"Code"`
+				Message string `json:"Message"`
+			} `json:"Error"`
+		} `json:"Response"`
+	}
+	if err := doSMSRequest(client, req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to send tencent sms: %v", err)
+	}
+	if resp.Response.Error != nil {
+		return nil, fmt.Errorf("tencent sms rejected: %s %s", resp.Response.Error.Code, resp.Response.Error.Message)
+	}
+
+	var sent, failed []interface{}
+	for _, status := range resp.Response.SendStatusSet {
+		item := map[string]interface{}{"to": status.PhoneNumber, "id": status.SerialNo, "status": status.Code}
+		if status.Code != "Ok" {
+			item["error"] = status.Message
+			failed = append(failed, item)
+			continue
+		}
+		sent = append(sent, item)
+	}
+	if len(sent) == 0 && len(failed) > 0 {
+		return nil, fmt.Errorf("tencent sms failed for all destinations: %v", failed)
+	}
+	return append(sent, failed...), nil
+}
+
+// tencentAuthorization 计算腾讯云 TC3-HMAC-SHA256 签名
+func tencentAuthorization(secretID, secretKey, host string, payload []byte, now time.Time) string {
+	const service = "sms"
+	date := now.Format("2006-01-02")
+
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := "POST\n/\n\n" +
+		"content-type:application/json; charset=utf-8\nhost:" + host + "\n\n" +
+		"content-type;host\n" + hex.EncodeToString(payloadHash[:])
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + service + "/tc3_request"
+	stringToSign := fmt.Sprintf("TC3-HMAC-SHA256\n%d\n%s\n%s", now.Unix(), scope, hex.EncodeToString(requestHash[:]))
+
+	sign := func(key []byte, data string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return mac.Sum(nil)
+	}
+	signingKey := sign(sign(sign([]byte("TC3"+secretKey), date), service), "tc3_request")
+	signature := hex.EncodeToString(sign(signingKey, stringToSign))
+
+	return fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host, Signature=%s", secretID, scope, signature)
+}
+
+// doSMSRequest 执行请求并解析JSON响应
+func doSMSRequest(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		return &restError{StatusCode: resp.StatusCode, Body: tailString(string(data), maxErrorBodySize)}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid JSON response: %v", err)
+	}
+	return nil
+}
+
+// smsRateLimiter 按号码的滑动窗口限流器
+type smsRateLimiter struct {
+	mu   sync.Mutex
+	sent map[string][]time.Time
+}
+
+// allow 判断窗口内发送次数是否未超过上限，允许时记录本次发送
+func (l *smsRateLimiter) allow(key string, max int, window time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-window)
+	history := l.sent[key][:0]
+	for _, t := range l.sent[key] {
+		if t.After(cutoff) {
+			history = append(history, t)
+		}
+	}
+
+	if len(history) >= max {
+		l.sent[key] = history
+		return false
+	}
+	l.sent[key] = append(history, now)
+	return true
+}
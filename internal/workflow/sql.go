@@ -0,0 +1,252 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxQueryRows 查询最多读取的行数，超过时截断结果
+const defaultMaxQueryRows = 10000
+
+// maskedValue 脱敏列的值被替换为的内容
+const maskedValue = "[masked]"
+
+// sqlRunner *sql.DB 和 *sql.Tx 的公共方法，事务内外的语句使用同一套执行逻辑
+type sqlRunner interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// sqlIsolationLevels transaction 操作支持的隔离级别
+var sqlIsolationLevels = map[string]sql.IsolationLevel{
+	"read_uncommitted": sql.LevelReadUncommitted,
+	"read_committed":   sql.LevelReadCommitted,
+	"repeatable_read":  sql.LevelRepeatableRead,
+	"serializable":     sql.LevelSerializable,
+}
+
+// sqlStatement 待执行的SQL语句
+type sqlStatement struct {
+	name      string
+	operation string
+	query     string
+	args      []interface{}
+	maxRows   int
+	masked    map[string]bool
+}
+
+// 驱动以文本返回时需要转换的列类型，键为大写的 DatabaseTypeName（去掉 UNSIGNED 前缀）
+var (
+	sqlIntegerTypes = map[string]bool{
+		"TINYINT": true, "SMALLINT": true, "MEDIUMINT": true, "INT": true, "INTEGER": true, "BIGINT": true,
+		"INT2": true, "INT4": true, "INT8": true, "YEAR": true,
+	}
+	sqlDecimalTypes = map[string]bool{
+		"DECIMAL": true, "NUMERIC": true, "FLOAT": true, "DOUBLE": true, "REAL": true,
+		"FLOAT4": true, "FLOAT8": true, "NUMBER": true,
+	}
+	sqlTimeTypes = map[string]bool{
+		"DATE": true, "DATETIME": true, "DATETIME2": true, "TIMESTAMP": true, "TIMESTAMPTZ": true,
+	}
+)
+
+// sqlTimeLayouts 日期时间列的文本格式
+var sqlTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// maskRow 替换行中脱敏列的值，masked 的键为小写列名；NULL值保留
+func maskRow(row map[string]interface{}, masked map[string]bool) {
+	if len(masked) == 0 {
+		return
+	}
+	for col, value := range row {
+		if value != nil && masked[strings.ToLower(col)] {
+			row[col] = maskedValue
+		}
+	}
+}
+
+// parseSQLTime 解析日期时间列的文本值
+func parseSQLTime(value string) (time.Time, bool) {
+	for _, layout := range sqlTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// pagedQuery 按数据源类型为查询加上分页，多读取一行用于判断是否还有下一页
+//
+// SQL Server 和 Oracle 使用 OFFSET ... FETCH 追加在原查询之后，原查询必须以 ORDER BY 结尾；
+// 其他数据库将原查询作为子查询并使用 LIMIT ... OFFSET。
+func pagedQuery(query, dbType string, pageSize, offset int) string {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	switch dbType {
+	case "sqlserver", "oracle":
+		return fmt.Sprintf("%s OFFSET %d ROWS FETCH NEXT %d ROWS ONLY", query, offset, pageSize+1)
+	default:
+		return fmt.Sprintf("SELECT * FROM (%s) nsa_page LIMIT %d OFFSET %d", query, pageSize+1, offset)
+	}
+}
+
+// sqlRowLimit 解析 max_rows、page_size 等行数参数，未设置时返回默认值
+func sqlRowLimit(value interface{}, name string, def int) (int, error) {
+	if value == nil {
+		return def, nil
+	}
+	n, ok := toNumber(value)
+	if !ok || n < 1 || n != float64(int(n)) {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return int(n), nil
+}
+
+// parseQueryCursor 解析分页游标，游标为上一页输出的 next_cursor，为空表示第一页
+func parseQueryCursor(value interface{}) (int, error) {
+	var cursor string
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		cursor = v
+	default:
+		cursor = fmt.Sprintf("%v", v)
+	}
+	if cursor == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(cursor)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	return offset, nil
+}
+
+// bindNamedParams 将SQL中的 :name 命名参数替换为数据源类型对应的占位符，返回替换后的SQL和按顺序排列的参数
+//
+// 字符串、带引号的标识符、注释和PostgreSQL的 :: 类型转换中的冒号不处理；同名参数出现多次时重复绑定；
+// 数组参数展开为逗号分隔的多个占位符，用于 IN 列表；对象参数编码为JSON字符串。
+// ClickHouse使用服务端参数，SQL中以 {name:Type} 引用，SQL不替换，参数按名称传递，数组和对象编码为JSON字符串。
+func bindNamedParams(query, dbType string, named map[string]interface{}) (string, []interface{}, error) {
+	if dbType == "clickhouse" {
+		args := make([]interface{}, 0, len(named))
+		for name, value := range named {
+			args = append(args, sql.Named(name, sqlValue(value)))
+		}
+		return query, args, nil
+	}
+
+	var buf strings.Builder
+	var args []interface{}
+	bind := func(value interface{}) {
+		args = append(args, value)
+		buf.WriteString(sqlPlaceholder(dbType, len(args)))
+	}
+
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := strings.IndexByte(query[i+1:], ch)
+			if end < 0 {
+				buf.WriteString(query[i:])
+				return buf.String(), args, nil
+			}
+			buf.WriteString(query[i : i+end+2])
+			i += end + 1
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			buf.WriteString(query[i : i+end])
+			i += end - 1
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				buf.WriteString(query[i:])
+				return buf.String(), args, nil
+			}
+			buf.WriteString(query[i : i+end+4])
+			i += end + 3
+		case ch == ':' && i+1 < len(query) && query[i+1] == ':':
+			buf.WriteString("::")
+			i++
+		case ch == ':' && i+1 < len(query) && isParamStart(query[i+1]):
+			end := i + 1
+			for end < len(query) && isParamChar(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+			value, ok := named[name]
+			if !ok {
+				return "", nil, fmt.Errorf("named parameter :%s is not set", name)
+			}
+			switch v := value.(type) {
+			case []interface{}:
+				if len(v) == 0 {
+					return "", nil, fmt.Errorf("named parameter :%s is an empty array", name)
+				}
+				for j, item := range v {
+					if j > 0 {
+						buf.WriteString(", ")
+					}
+					bind(sqlValue(item))
+				}
+			default:
+				bind(sqlValue(v))
+			}
+			i = end - 1
+		default:
+			buf.WriteByte(ch)
+		}
+	}
+	return buf.String(), args, nil
+}
+
+// sqlPlaceholder 返回第n个参数的占位符
+func sqlPlaceholder(dbType string, n int) string {
+	switch dbType {
+	case "postgresql":
+		return "$" + strconv.Itoa(n)
+	case "sqlserver":
+		return "@p" + strconv.Itoa(n)
+	case "oracle":
+		return ":" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
+
+// sqlValue 将参数值转换为驱动支持的类型，对象和数组编码为JSON字符串
+func sqlValue(value interface{}) interface{} {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+		return string(data)
+	}
+	return value
+}
+
+// isParamStart 判断字符能否作为命名参数的首字符
+func isParamStart(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+// isParamChar 判断字符能否出现在命名参数中
+func isParamChar(ch byte) bool {
+	return isParamStart(ch) || ch >= '0' && ch <= '9'
+}
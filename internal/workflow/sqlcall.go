@@ -0,0 +1,362 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlProcedurePattern 存储过程名称，可以带 schema 和包名前缀（schema.package.procedure）
+var sqlProcedurePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$#]*(\.[A-Za-z_][A-Za-z0-9_$#]*){0,2}$`)
+
+// 存储过程参数方向
+const (
+	sqlParamIn    = "in"
+	sqlParamOut   = "out"
+	sqlParamInOut = "inout"
+)
+
+// sqlOutTypes OUT参数支持的类型，SQL Server 和 Oracle 按类型准备接收变量
+var sqlOutTypes = map[string]bool{"string": true, "int": true, "float": true, "bool": true, "time": true}
+
+// sqlProcParam 存储过程参数
+type sqlProcParam struct {
+	name  string
+	mode  string
+	typ   string
+	value interface{}
+}
+
+// isOut 是否为OUT或INOUT参数
+func (p *sqlProcParam) isOut() bool {
+	return p.mode != sqlParamIn
+}
+
+// parseProcParams 解析 call 操作的 arguments 参数，值中的模板会被渲染
+func parseProcParams(items []interface{}, dbType string, taskCtx *TaskContext) ([]*sqlProcParam, error) {
+	params := make([]*sqlProcParam, 0, len(items))
+	for i, item := range items {
+		spec, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("argument %d must be an object", i)
+		}
+		param := &sqlProcParam{mode: sqlParamIn, typ: "string"}
+		param.name, _ = spec["name"].(string)
+		if mode, _ := spec["mode"].(string); mode != "" {
+			param.mode = strings.ToLower(mode)
+		}
+		if typ, _ := spec["type"].(string); typ != "" {
+			param.typ = typ
+		}
+		param.value = sqlValue(resolveValue(spec["value"], taskCtx))
+
+		switch param.mode {
+		case sqlParamIn, sqlParamOut, sqlParamInOut:
+		default:
+			return nil, fmt.Errorf("argument %d: unsupported mode %q, expected in, out or inout", i, param.mode)
+		}
+		if !sqlOutTypes[param.typ] {
+			return nil, fmt.Errorf("argument %d: unsupported type %q", i, param.typ)
+		}
+		if param.name != "" && !isIdentifier(param.name) {
+			return nil, fmt.Errorf("argument %d: invalid name %q", i, param.name)
+		}
+		// OUT参数按名称写入输出；SQL Server 按名称绑定所有参数
+		if param.name == "" && (param.isOut() || dbType == "sqlserver") {
+			return nil, fmt.Errorf("argument %d: name is required", i)
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// isIdentifier 判断是否为合法的参数名
+func isIdentifier(name string) bool {
+	if name == "" || !isParamStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isParamChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// callProcedure 调用存储过程，返回OUT和INOUT参数的值
+//
+// 各数据库的调用方式不同：MySQL 通过会话变量接收OUT参数，PostgreSQL 的 CALL 以结果行返回OUT参数，
+// SQL Server 按名称以RPC方式调用，Oracle 使用匿名PL/SQL块。
+func callProcedure(ctx context.Context, db *sql.DB, dbType, procedure string, params []*sqlProcParam) (map[string]interface{}, error) {
+	if !sqlProcedurePattern.MatchString(procedure) {
+		return nil, fmt.Errorf("invalid procedure name: %s", procedure)
+	}
+	switch dbType {
+	case "mysql":
+		return callMySQLProcedure(ctx, db, procedure, params)
+	case "postgresql":
+		return callPostgresProcedure(ctx, db, procedure, params)
+	case "sqlserver", "oracle":
+		return callWithOutParams(ctx, db, dbType, procedure, params)
+	default:
+		return nil, fmt.Errorf("call operation is not supported for %s datasource", dbType)
+	}
+}
+
+// callMySQLProcedure 调用MySQL存储过程，OUT参数绑定到会话变量，调用后在同一连接上读取
+func callMySQLProcedure(ctx context.Context, db *sql.DB, procedure string, params []*sqlProcParam) (map[string]interface{}, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	placeholders := make([]string, len(params))
+	var args []interface{}
+	var selects []string
+	for i, param := range params {
+		if !param.isOut() {
+			placeholders[i] = "?"
+			args = append(args, param.value)
+			continue
+		}
+		variable := "@nsa_out_" + strconv.Itoa(i)
+		if param.mode == sqlParamInOut {
+			if _, err := conn.ExecContext(ctx, "SET "+variable+" = ?", param.value); err != nil {
+				return nil, fmt.Errorf("failed to set inout parameter %s: %v", param.name, err)
+			}
+		} else {
+			// 连接会被复用，清除上次调用留下的值
+			if _, err := conn.ExecContext(ctx, "SET "+variable+" = NULL"); err != nil {
+				return nil, fmt.Errorf("failed to reset out parameter %s: %v", param.name, err)
+			}
+		}
+		placeholders[i] = variable
+		selects = append(selects, variable)
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CALL %s(%s)", procedure, strings.Join(placeholders, ", ")), args...); err != nil {
+		return nil, fmt.Errorf("failed to call procedure: %v", err)
+	}
+	if len(selects) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT "+strings.Join(selects, ", "))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read out parameters: %v", err)
+	}
+	defer rows.Close()
+	values, err := scanSingleRow(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{})
+	j := 0
+	for _, param := range params {
+		if param.isOut() {
+			out[param.name] = values[j]
+			j++
+		}
+	}
+	return out, nil
+}
+
+// callPostgresProcedure 调用PostgreSQL存储过程，OUT参数传NULL，CALL返回的结果行按列名作为OUT参数的值
+func callPostgresProcedure(ctx context.Context, db *sql.DB, procedure string, params []*sqlProcParam) (map[string]interface{}, error) {
+	placeholders := make([]string, len(params))
+	var args []interface{}
+	for i, param := range params {
+		if param.mode == sqlParamOut {
+			placeholders[i] = "NULL"
+			continue
+		}
+		args = append(args, param.value)
+		placeholders[i] = sqlPlaceholder("postgresql", len(args))
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("CALL %s(%s)", procedure, strings.Join(placeholders, ", ")), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call procedure: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %v", err)
+	}
+	out := make(map[string]interface{})
+	if len(columns) == 0 {
+		return out, nil
+	}
+	values, err := scanSingleRow(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, col := range columns {
+		out[col] = values[i]
+	}
+	return out, nil
+}
+
+// scanSingleRow 读取结果的第一行，值按列类型转换
+func scanSingleRow(rows *sql.Rows) ([]interface{}, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %v", err)
+	}
+	values := make([]interface{}, len(columnTypes))
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read out parameters: %v", err)
+		}
+		return values, nil
+	}
+	valuePtrs := make([]interface{}, len(values))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, fmt.Errorf("failed to scan out parameters: %v", err)
+	}
+	for i := range values {
+		values[i] = columnValue(columnTypes[i].DatabaseTypeName(), values[i])
+	}
+	return values, nil
+}
+
+// callWithOutParams 使用 sql.Out 调用 SQL Server 或 Oracle 存储过程
+//
+// SQL Server 以过程名作为语句时驱动按RPC调用，参数按名称绑定；Oracle 使用 BEGIN ... END 块，
+// 设置了名称的参数使用 name => :n 的命名写法。
+func callWithOutParams(ctx context.Context, db *sql.DB, dbType, procedure string, params []*sqlProcParam) (map[string]interface{}, error) {
+	args := make([]interface{}, len(params))
+	dests := make([]interface{}, len(params))
+	placeholders := make([]string, len(params))
+	for i, param := range params {
+		arg := param.value
+		if param.isOut() {
+			dest, err := sqlOutDest(dbType, param)
+			if err != nil {
+				return nil, fmt.Errorf("argument %s: %v", param.name, err)
+			}
+			dests[i] = dest
+			arg = sql.Out{Dest: dest, In: param.mode == sqlParamInOut}
+		}
+		if dbType == "sqlserver" {
+			args[i] = sql.Named(param.name, arg)
+			continue
+		}
+		args[i] = arg
+		placeholders[i] = ":" + strconv.Itoa(i+1)
+		if param.name != "" {
+			placeholders[i] = param.name + " => " + placeholders[i]
+		}
+	}
+
+	query := procedure
+	if dbType == "oracle" {
+		query = fmt.Sprintf("BEGIN %s(%s); END;", procedure, strings.Join(placeholders, ", "))
+	}
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to call procedure: %v", err)
+	}
+
+	out := make(map[string]interface{})
+	for i, param := range params {
+		if param.isOut() {
+			out[param.name] = sqlOutValue(dests[i])
+		}
+	}
+	return out, nil
+}
+
+// sqlOutDest 按参数类型创建OUT参数的接收变量，INOUT参数以输入值初始化
+//
+// Oracle 驱动的字符串和布尔OUT参数不支持 sql.NullString、sql.NullBool，NULL时为零值。
+func sqlOutDest(dbType string, param *sqlProcParam) (interface{}, error) {
+	in := param.mode == sqlParamInOut && param.value != nil
+	switch param.typ {
+	case "int":
+		dest := &sql.NullInt64{}
+		if in {
+			n, ok := toNumber(param.value)
+			if !ok {
+				return nil, fmt.Errorf("value %v is not a number", param.value)
+			}
+			dest.Int64, dest.Valid = int64(n), true
+		}
+		return dest, nil
+	case "float":
+		dest := &sql.NullFloat64{}
+		if in {
+			n, ok := toNumber(param.value)
+			if !ok {
+				return nil, fmt.Errorf("value %v is not a number", param.value)
+			}
+			dest.Float64, dest.Valid = n, true
+		}
+		return dest, nil
+	case "time":
+		dest := &sql.NullTime{}
+		if in {
+			t, err := time.Parse(time.RFC3339, stringifyValue(param.value))
+			if err != nil {
+				return nil, fmt.Errorf("value %v is not an RFC3339 time", param.value)
+			}
+			dest.Time, dest.Valid = t, true
+		}
+		return dest, nil
+	case "bool":
+		value, _ := param.value.(bool)
+		if dbType == "oracle" {
+			return &value, nil
+		}
+		return &sql.NullBool{Bool: value, Valid: in}, nil
+	default:
+		value := ""
+		if in {
+			value = stringifyValue(param.value)
+		}
+		if dbType == "oracle" {
+			return &value, nil
+		}
+		return &sql.NullString{String: value, Valid: in}, nil
+	}
+}
+
+// sqlOutValue 读取OUT参数接收变量的值，NULL返回nil
+func sqlOutValue(dest interface{}) interface{} {
+	switch v := dest.(type) {
+	case *sql.NullInt64:
+		if v.Valid {
+			return v.Int64
+		}
+	case *sql.NullFloat64:
+		if v.Valid {
+			return v.Float64
+		}
+	case *sql.NullTime:
+		if v.Valid {
+			return v.Time
+		}
+	case *sql.NullBool:
+		if v.Valid {
+			return v.Bool
+		}
+	case *sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+	case *bool:
+		return *v
+	case *string:
+		return *v
+	}
+	return nil
+}
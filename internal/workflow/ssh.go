@@ -0,0 +1,370 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// maxSSHOutputSize 远程命令stdout和stderr各自保留的最大字节数
+	maxSSHOutputSize = 1024 * 1024
+	// maxSFTPFileSize 下载文件的最大大小
+	maxSFTPFileSize = 10 * 1024 * 1024
+)
+
+// SSHAction SSH动作，在远程主机上执行命令或通过SFTP上传、下载文件
+type SSHAction struct {
+	ctx *ActionContext
+}
+
+// NewSSHAction 创建SSH动作
+func NewSSHAction(ctx *ActionContext) *SSHAction {
+	return &SSHAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *SSHAction) Name() string {
+	return "SSHAction"
+}
+
+// Run 执行SSH操作
+func (a *SSHAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	operation, _ := params["operation"].(string)
+	host, _ := params["host"].(string)
+	port, _ := params["port"].(float64)
+	timeout, _ := params["timeout"].(float64)
+
+	if host == "" {
+		return fmt.Errorf("host parameter is required")
+	}
+	if operation == "" {
+		operation = "exec"
+	}
+	if port == 0 {
+		port = 22
+	}
+	if timeout == 0 {
+		timeout = 60
+	}
+
+	clientConfig, err := a.clientConfig(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	address := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	client, err := dialSSH(ctx, address, clientConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", address, err)
+	}
+	defer client.Close()
+
+	// 超时后关闭连接，中断正在执行的命令或传输
+	go func() {
+		<-ctx.Done()
+		client.Close()
+	}()
+
+	var result map[string]interface{}
+	switch operation {
+	case "exec":
+		result, err = a.exec(ctx, client, params)
+	case "upload":
+		result, err = a.upload(client, params)
+	case "download":
+		result, err = a.download(client, params)
+	default:
+		return fmt.Errorf("unsupported ssh operation: %s", operation)
+	}
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %ds: %v", int(timeout), err)
+	}
+	if result != nil {
+		result["host"] = host
+		taskCtx.SetOutput(result)
+	}
+	if err != nil {
+		return fmt.Errorf("ssh %s on %s failed: %v", operation, host, err)
+	}
+
+	a.ctx.Logger.Infof("SSH %s on %s completed successfully", operation, host)
+	return nil
+}
+
+// clientConfig 构造SSH客户端配置
+//
+// 支持 password_secret 和 private_key_secret（可选 passphrase_secret）认证；
+// 主机密钥通过 host_key（authorized_keys格式的公钥）或 host_key_fingerprint（SHA256:...）校验，
+// 只有显式设置 insecure_ignore_host_key 时才跳过校验。
+func (a *SSHAction) clientConfig(ctx context.Context, params map[string]interface{}) (*ssh.ClientConfig, error) {
+	username, _ := params["username"].(string)
+	passwordSecret, _ := params["password_secret"].(string)
+	keySecret, _ := params["private_key_secret"].(string)
+	passphraseSecret, _ := params["passphrase_secret"].(string)
+	hostKey, _ := params["host_key"].(string)
+	fingerprint, _ := params["host_key_fingerprint"].(string)
+	insecure, _ := params["insecure_ignore_host_key"].(bool)
+
+	if username == "" {
+		return nil, fmt.Errorf("username parameter is required")
+	}
+	if passwordSecret == "" && keySecret == "" {
+		return nil, fmt.Errorf("password_secret or private_key_secret parameter is required")
+	}
+
+	var auth []ssh.AuthMethod
+	if keySecret != "" {
+		key, err := a.ctx.getSecret(ctx, keySecret)
+		if err != nil {
+			return nil, err
+		}
+		var signer ssh.Signer
+		if passphraseSecret != "" {
+			passphrase, err := a.ctx.getSecret(ctx, passphraseSecret)
+			if err != nil {
+				return nil, err
+			}
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key: %v", err)
+			}
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(key))
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key: %v", err)
+			}
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if passwordSecret != "" {
+		password, err := a.ctx.getSecret(ctx, passwordSecret)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.Password(password))
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case hostKey != "":
+		expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid host_key: %v", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(expected)
+	case fingerprint != "":
+		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if actual := ssh.FingerprintSHA256(key); actual != fingerprint {
+				return fmt.Errorf("host key fingerprint mismatch: got %s", actual)
+			}
+			return nil
+		}
+	case insecure:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("host_key or host_key_fingerprint parameter is required (or set insecure_ignore_host_key)")
+	}
+
+	return &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+// dialSSH 建立SSH连接，连接和握手受ctx控制
+func dialSSH(ctx context.Context, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// 握手完成后取消截止时间，命令执行的超时由ctx控制
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// exec 执行远程命令
+//
+// command 由远程shell解析；args中的每一项会用单引号转义后追加到命令之后，模板变量应放在args中。
+func (a *SSHAction) exec(ctx context.Context, client *ssh.Client, params map[string]interface{}) (map[string]interface{}, error) {
+	command, _ := params["command"].(string)
+	args, _ := params["args"].([]interface{})
+	failOnError := true
+	if v, ok := params["fail_on_error"].(bool); ok {
+		failOnError = v
+	}
+	if command == "" {
+		return nil, fmt.Errorf("command parameter is required for exec")
+	}
+	for _, arg := range args {
+		command += " " + shellQuote(stringifyValue(arg))
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session: %v", err)
+	}
+	defer session.Close()
+
+	if _, ok := params["stdin"]; ok {
+		session.Stdin = strings.NewReader(stringifyValue(params["stdin"]))
+	}
+	stdout := &cappedBuffer{limit: maxSSHOutputSize}
+	stderr := &cappedBuffer{limit: maxSSHOutputSize}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	start := time.Now()
+	err = session.Run(command)
+	duration := time.Since(start)
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) || ctx.Err() != nil {
+			return nil, err
+		}
+		exitCode = exitErr.ExitStatus()
+	}
+
+	result := map[string]interface{}{
+		"exit_code":        exitCode,
+		"stdout":           stdout.String(),
+		"stderr":           stderr.String(),
+		"stdout_truncated": stdout.truncated,
+		"stderr_truncated": stderr.truncated,
+		"duration_ms":      duration.Milliseconds(),
+	}
+	if exitCode != 0 && failOnError {
+		return result, fmt.Errorf("command exited with code %d: %s", exitCode, tailString(stderr.String(), maxErrorBodySize))
+	}
+	return result, nil
+}
+
+// upload 通过SFTP上传文件，content为文本内容，content_base64为二进制内容
+func (a *SSHAction) upload(client *ssh.Client, params map[string]interface{}) (map[string]interface{}, error) {
+	remotePath, _ := params["remote_path"].(string)
+	encoded, _ := params["content_base64"].(string)
+	mode, _ := params["mode"].(string)
+	if remotePath == "" {
+		return nil, fmt.Errorf("remote_path parameter is required for upload")
+	}
+
+	var body []byte
+	if encoded != "" {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content_base64: %v", err)
+		}
+		body = data
+	} else {
+		body = []byte(stringifyValue(params["content"]))
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sftp: %v", err)
+	}
+	defer sftpClient.Close()
+
+	file, err := sftpClient.Create(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", remotePath, err)
+	}
+	if _, err := io.Copy(file, bytes.NewReader(body)); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write %s: %v", remotePath, err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", remotePath, err)
+	}
+
+	// mode为八进制权限，如 0755
+	if mode != "" {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode %q: %v", mode, err)
+		}
+		if err := sftpClient.Chmod(remotePath, os.FileMode(perm)); err != nil {
+			return nil, fmt.Errorf("failed to chmod %s: %v", remotePath, err)
+		}
+	}
+
+	return map[string]interface{}{
+		"remote_path": remotePath,
+		"size":        len(body),
+	}, nil
+}
+
+// download 通过SFTP下载文件，非UTF-8内容以base64返回
+func (a *SSHAction) download(client *ssh.Client, params map[string]interface{}) (map[string]interface{}, error) {
+	remotePath, _ := params["remote_path"].(string)
+	if remotePath == "" {
+		return nil, fmt.Errorf("remote_path parameter is required for download")
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sftp: %v", err)
+	}
+	defer sftpClient.Close()
+
+	file, err := sftpClient.Open(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", remotePath, err)
+	}
+	defer file.Close()
+
+	body, err := io.ReadAll(io.LimitReader(file, maxSFTPFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", remotePath, err)
+	}
+	if len(body) > maxSFTPFileSize {
+		return nil, fmt.Errorf("file %s exceeds %d bytes", remotePath, maxSFTPFileSize)
+	}
+
+	result := map[string]interface{}{
+		"remote_path": remotePath,
+		"size":        len(body),
+	}
+	if asBase64, _ := params["base64"].(bool); asBase64 || !utf8.Valid(body) {
+		result["content_base64"] = base64.StdEncoding.EncodeToString(body)
+	} else {
+		result["content"] = string(body)
+	}
+	return result, nil
+}
+
+// shellQuote 用单引号转义参数，使其在POSIX shell中作为一个整体传递
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,224 @@
+package workflow
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"nsa/internal/datasource"
+)
+
+// maxObjectSize 下载对象的最大大小
+const maxObjectSize = 10 * 1024 * 1024
+
+// ObjectStorageAction 对象存储动作（S3兼容：AWS S3、MinIO），凭据来自 s3 类型数据源
+type ObjectStorageAction struct {
+	ctx *ActionContext
+}
+
+// NewObjectStorageAction 创建对象存储动作
+func NewObjectStorageAction(ctx *ActionContext) *ObjectStorageAction {
+	return &ObjectStorageAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *ObjectStorageAction) Name() string {
+	return "ObjectStorageAction"
+}
+
+// Run 执行对象存储操作
+func (a *ObjectStorageAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	dataSourceName, _ := params["datasource"].(string)
+	operation, _ := params["operation"].(string)
+	bucketName, _ := params["bucket"].(string)
+	key, _ := params["key"].(string)
+	timeout, _ := params["timeout"].(float64)
+
+	if dataSourceName == "" {
+		return fmt.Errorf("datasource parameter is required")
+	}
+	if operation == "" {
+		return fmt.Errorf("operation parameter is required")
+	}
+	if timeout == 0 {
+		timeout = 60
+	}
+
+	client, err := a.ctx.DataSourceMgr.GetS3Client(dataSourceName)
+	if err != nil {
+		return fmt.Errorf("failed to get object storage client: %v", err)
+	}
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	if key == "" && operation != "list" {
+		return fmt.Errorf("key parameter is required for %s", operation)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	a.ctx.Logger.Infof("Executing object storage %s on %s/%s", operation, bucket, key)
+
+	var result interface{}
+	switch operation {
+	case "put":
+		result, err = a.put(ctx, client, bucket, key, params)
+	case "get":
+		result, err = a.get(ctx, client, bucket, key, params)
+	case "delete":
+		err = client.DeleteObject(ctx, bucket, key)
+		result = map[string]interface{}{"bucket": bucket, "key": key, "deleted": true}
+	case "list":
+		result, err = a.list(ctx, client, bucket, params)
+	case "presign":
+		result, err = a.presign(client, bucket, key, params)
+	default:
+		return fmt.Errorf("unsupported object storage operation: %s", operation)
+	}
+	if err != nil {
+		return fmt.Errorf("object storage %s failed: %v", operation, err)
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(result)
+	a.ctx.Logger.Infof("Object storage %s completed successfully", operation)
+
+	return nil
+}
+
+// put 上传对象，content为文本内容，content_base64为二进制内容
+func (a *ObjectStorageAction) put(ctx context.Context, client *datasource.S3Client, bucket, key string, params map[string]interface{}) (interface{}, error) {
+	contentType, _ := params["content_type"].(string)
+	encoded, _ := params["content_base64"].(string)
+	metadata, _ := params["metadata"].(map[string]interface{})
+
+	var body []byte
+	if encoded != "" {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content_base64: %v", err)
+		}
+		body = data
+	} else {
+		body = []byte(stringifyValue(params["content"]))
+	}
+
+	meta := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		meta[k] = stringifyValue(v)
+	}
+
+	etag, err := client.PutObject(ctx, bucket, key, body, contentType, meta)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"bucket": bucket,
+		"key":    key,
+		"etag":   etag,
+		"size":   len(body),
+	}, nil
+}
+
+// get 下载对象，非UTF-8内容以base64返回
+func (a *ObjectStorageAction) get(ctx context.Context, client *datasource.S3Client, bucket, key string, params map[string]interface{}) (interface{}, error) {
+	object, err := client.GetObject(ctx, bucket, key, maxObjectSize)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]interface{}, len(object.Metadata))
+	for k, v := range object.Metadata {
+		metadata[k] = v
+	}
+
+	result := map[string]interface{}{
+		"bucket":        bucket,
+		"key":           key,
+		"size":          len(object.Body),
+		"etag":          object.ETag,
+		"content_type":  object.ContentType,
+		"last_modified": object.LastModified,
+		"metadata":      metadata,
+	}
+	if asBase64, _ := params["base64"].(bool); asBase64 || !utf8.Valid(object.Body) {
+		result["content_base64"] = base64.StdEncoding.EncodeToString(object.Body)
+	} else {
+		result["content"] = string(object.Body)
+	}
+	return result, nil
+}
+
+// list 列举对象，通过continuation_token分页
+func (a *ObjectStorageAction) list(ctx context.Context, client *datasource.S3Client, bucket string, params map[string]interface{}) (interface{}, error) {
+	prefix, _ := params["prefix"].(string)
+	delimiter, _ := params["delimiter"].(string)
+	token, _ := params["continuation_token"].(string)
+	maxKeys, _ := params["max_keys"].(float64)
+
+	result, err := client.ListObjects(ctx, bucket, prefix, delimiter, token, int(maxKeys))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]interface{}, 0, len(result.Objects))
+	for _, object := range result.Objects {
+		objects = append(objects, map[string]interface{}{
+			"key":           object.Key,
+			"size":          object.Size,
+			"etag":          object.ETag,
+			"last_modified": object.LastModified.Format(time.RFC3339),
+			"storage_class": object.StorageClass,
+		})
+	}
+	prefixes := make([]interface{}, 0, len(result.Prefixes))
+	for _, p := range result.Prefixes {
+		prefixes = append(prefixes, p)
+	}
+	return map[string]interface{}{
+		"bucket":                  bucket,
+		"objects":                 objects,
+		"prefixes":                prefixes,
+		"count":                   len(objects),
+		"is_truncated":            result.IsTruncated,
+		"next_continuation_token": result.NextContinuationToken,
+	}, nil
+}
+
+// presign 生成预签名URL，method为GET（下载）或PUT（上传）
+func (a *ObjectStorageAction) presign(client *datasource.S3Client, bucket, key string, params map[string]interface{}) (interface{}, error) {
+	method, _ := params["method"].(string)
+	expires, _ := params["expires"].(float64)
+
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPut {
+		return nil, fmt.Errorf("presign method must be GET or PUT")
+	}
+	if expires == 0 {
+		expires = 3600
+	}
+
+	url, err := client.PresignURL(method, bucket, key, time.Duration(expires)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"bucket":     bucket,
+		"key":        key,
+		"method":     method,
+		"url":        url,
+		"expires_at": time.Now().Add(time.Duration(expires) * time.Second).UTC().Format(time.RFC3339),
+	}, nil
+}
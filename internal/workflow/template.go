@@ -0,0 +1,238 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// templatePattern 匹配 {{path}} 形式的模板变量
+var templatePattern = regexp.MustCompile(`{{\s*([^{}]+?)\s*}}`)
+
+// maxCompiledTemplates 编译缓存的最大模板数，超过时清空重新缓存
+//
+// 模板来自工作流配置，数量有限；上限防止渲染结果等动态字符串作为模板时缓存无限增长。
+const maxCompiledTemplates = 10000
+
+var (
+	// compiledTemplates 模板字符串到编译结果的缓存，工作流配置修改后新的模板字符串重新编译
+	compiledTemplates     sync.Map
+	compiledTemplateCount int64
+	// renderBuffers 渲染模板时复用的缓冲区
+	renderBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+)
+
+// templatePart 编译后的模板片段：path 为 nil 时是原样输出的文本，否则是拆分后的变量路径
+type templatePart struct {
+	text string
+	path []string
+}
+
+// compiledTemplate 编译后的模板，渲染时不再匹配正则和拆分路径
+type compiledTemplate struct {
+	parts []templatePart
+}
+
+// compileTemplate 编译模板，结果按模板字符串缓存
+func compileTemplate(template string) *compiledTemplate {
+	if cached, ok := compiledTemplates.Load(template); ok {
+		return cached.(*compiledTemplate)
+	}
+
+	compiled := &compiledTemplate{}
+	last := 0
+	for _, match := range templatePattern.FindAllStringSubmatchIndex(template, -1) {
+		if match[0] > last {
+			compiled.parts = append(compiled.parts, templatePart{text: template[last:match[0]]})
+		}
+		compiled.parts = append(compiled.parts, templatePart{
+			text: template[match[0]:match[1]],
+			path: strings.Split(template[match[2]:match[3]], "."),
+		})
+		last = match[1]
+	}
+	if last < len(template) {
+		compiled.parts = append(compiled.parts, templatePart{text: template[last:]})
+	}
+
+	if atomic.AddInt64(&compiledTemplateCount, 1) > maxCompiledTemplates {
+		compiledTemplates.Range(func(key, _ interface{}) bool {
+			compiledTemplates.Delete(key)
+			return true
+		})
+		atomic.StoreInt64(&compiledTemplateCount, 1)
+	}
+	compiledTemplates.Store(template, compiled)
+	return compiled
+}
+
+// render 使用任务上下文渲染编译后的模板，无法解析的变量保持原样
+func (t *compiledTemplate) render(taskCtx *TaskContext) string {
+	buf := renderBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer renderBuffers.Put(buf)
+
+	for _, part := range t.parts {
+		if part.path == nil {
+			buf.WriteString(part.text)
+			continue
+		}
+		value, ok := taskCtx.lookupSegments(part.path)
+		if !ok {
+			buf.WriteString(part.text)
+			continue
+		}
+		buf.WriteString(stringifyValue(value))
+	}
+	return buf.String()
+}
+
+// renderTemplate 使用任务上下文渲染模板
+//
+// 支持的变量：
+//   - {{nsq.field.sub}}      NSQ消息数据
+//   - {{output.task_id.key}} 前置任务输出
+//   - {{name.key}}           工作流变量
+//
+// 无法解析的变量保持原样；非字符串值以JSON格式输出。
+func renderTemplate(template string, taskCtx *TaskContext) string {
+	if !strings.Contains(template, "{{") {
+		return template
+	}
+	return compileTemplate(template).render(taskCtx)
+}
+
+// renderValue 递归渲染参数中的字符串模板
+func renderValue(value interface{}, taskCtx *TaskContext) interface{} {
+	switch v := value.(type) {
+	case string:
+		return renderTemplate(v, taskCtx)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = renderValue(item, taskCtx)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = renderValue(item, taskCtx)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// resolveValue 递归渲染参数，整个字符串只有一个模板变量时保留原始类型
+//
+// 用于字段映射等需要保持数字、布尔、对象类型的场景，例如 "{{nsq.priority}}" 得到数字而不是字符串。
+func resolveValue(value interface{}, taskCtx *TaskContext) interface{} {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v
+		}
+		compiled := compileTemplate(v)
+		if len(compiled.parts) == 1 && compiled.parts[0].path != nil {
+			if resolved, ok := taskCtx.lookupSegments(compiled.parts[0].path); ok {
+				return resolved
+			}
+		}
+		return compiled.render(taskCtx)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = resolveValue(item, taskCtx)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = resolveValue(item, taskCtx)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// Lookup 按路径查找上下文中的值
+func (tc *TaskContext) Lookup(path string) (interface{}, bool) {
+	return tc.lookupSegments(strings.Split(path, "."))
+}
+
+// lookupSegments 按拆分后的路径查找上下文中的值
+func (tc *TaskContext) lookupSegments(segments []string) (interface{}, bool) {
+	switch segments[0] {
+	case "nsq":
+		if tc.message == nil {
+			return nil, false
+		}
+		return lookupPath(tc.message.Data, segments[1:])
+	case "output":
+		return lookupPath(tc.results, segments[1:])
+	case "task":
+		task := map[string]interface{}{"id": tc.taskID, "idempotency_key": tc.IdempotencyKey()}
+		return lookupPath(task, segments[1:])
+	default:
+		return lookupPath(tc.vars, segments)
+	}
+}
+
+// lookupPath 在嵌套的map/数组中按路径查找值
+func lookupPath(root interface{}, segments []string) (interface{}, bool) {
+	current := root
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, exists := node[segment]
+			if !exists {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		case []map[string]interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// stringifyValue 将值转换为模板输出字符串
+func stringifyValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool, int, int64:
+		return fmt.Sprint(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
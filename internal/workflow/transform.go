@@ -0,0 +1,179 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/jmespath/go-jmespath"
+)
+
+// 表达式语言
+const (
+	transformJMESPath = "jmespath"
+	transformJSONPath = "jsonpath"
+)
+
+// TransformAction 数据转换动作，使用JMESPath或JSONPath表达式从前置节点输出、消息数据中提取和重组字段
+//
+// 用于简单的字段映射，不需要编写JS函数节点。
+type TransformAction struct {
+	ctx *ActionContext
+}
+
+// NewTransformAction 创建数据转换动作
+func NewTransformAction(ctx *ActionContext) *TransformAction {
+	return &TransformAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *TransformAction) Name() string {
+	return "TransformAction"
+}
+
+// Run 执行数据转换
+//
+// input 为转换的数据，默认为 {"nsq": 消息数据, "output": 前置节点输出, "vars": 工作流变量}；
+// mapping 为输出字段到表达式的映射（可嵌套），输出为对象；expression 为单个表达式，输出为其结果。
+// strict 为 true 时表达式没有匹配到值则报错，否则该字段为null。
+func (a *TransformAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := taskCtx.GetParams()
+
+	language, _ := params["language"].(string)
+	expression, _ := params["expression"].(string)
+	mapping, _ := params["mapping"].(map[string]interface{})
+	strict, _ := params["strict"].(bool)
+
+	if language == "" {
+		language = transformJMESPath
+	}
+	if language != transformJMESPath && language != transformJSONPath {
+		return fmt.Errorf("unsupported language: %s (must be %s or %s)", language, transformJMESPath, transformJSONPath)
+	}
+	if expression == "" && mapping == nil {
+		return fmt.Errorf("expression or mapping parameter is required")
+	}
+	if expression != "" && mapping != nil {
+		return fmt.Errorf("expression and mapping parameters are mutually exclusive")
+	}
+
+	var input interface{}
+	if raw, ok := params["input"]; ok {
+		input = resolveValue(raw, taskCtx)
+	} else {
+		input = map[string]interface{}{
+			"nsq":    nil,
+			"output": taskCtx.results,
+			"vars":   taskCtx.vars,
+		}
+		if message := taskCtx.GetMessage(); message != nil {
+			input.(map[string]interface{})["nsq"] = message.Data
+		}
+	}
+	// 统一转换为JSON类型（map[string]interface{}、[]interface{}、float64），表达式库不支持其他Go类型
+	input, err := normalizeJSON(input)
+	if err != nil {
+		return fmt.Errorf("failed to convert input: %v", err)
+	}
+
+	t := &transformer{language: language, strict: strict, input: input}
+	var output interface{}
+	if mapping != nil {
+		output, err = t.mapping(mapping, "")
+	} else {
+		output, err = t.evaluate(expression, "expression")
+	}
+	if err != nil {
+		return err
+	}
+
+	taskCtx.SetOutput(output)
+	a.ctx.Logger.Infof("Transform (%s) completed successfully", language)
+	return nil
+}
+
+// transformer 对同一输入计算多个表达式
+type transformer struct {
+	language string
+	strict   bool
+	input    interface{}
+}
+
+// mapping 递归计算映射：字符串为表达式，对象为嵌套映射，其他值原样输出
+func (t *transformer) mapping(mapping map[string]interface{}, prefix string) (map[string]interface{}, error) {
+	// 按字段名顺序计算，使错误信息稳定
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make(map[string]interface{}, len(mapping))
+	for _, key := range keys {
+		field := prefix + key
+		switch v := mapping[key].(type) {
+		case string:
+			value, err := t.evaluate(v, "mapping."+field)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		case map[string]interface{}:
+			value, err := t.mapping(v, field+".")
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		default:
+			result[key] = v
+		}
+	}
+	return result, nil
+}
+
+// evaluate 计算单个表达式，name 用于错误信息
+func (t *transformer) evaluate(expression, name string) (interface{}, error) {
+	var value interface{}
+	var err error
+	switch t.language {
+	case transformJSONPath:
+		value, err = jsonpath.Get(expression, t.input)
+		// 键或索引不存在时 jsonpath 返回错误，非严格模式下视为null
+		if err != nil && !t.strict && isJSONPathMissing(err) {
+			value, err = nil, nil
+		}
+	default:
+		value, err = jmespath.Search(expression, t.input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %v", name, expression, err)
+	}
+	if value == nil && t.strict {
+		return nil, fmt.Errorf("%s %q matched no value", name, expression)
+	}
+	return value, nil
+}
+
+// isJSONPathMissing 判断是否为键、索引不存在或路径经过null的错误（表达式语法错误仍然返回）
+func isJSONPathMissing(err error) bool {
+	message := err.Error()
+	return strings.HasPrefix(message, "unknown key") ||
+		strings.HasSuffix(message, "out of bounds") ||
+		strings.HasPrefix(message, "unsupported value type <nil>")
+}
+
+// normalizeJSON 通过JSON序列化将值转换为标准JSON类型
+func normalizeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
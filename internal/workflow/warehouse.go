@@ -0,0 +1,130 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nsa/internal/datasource"
+)
+
+// defaultWarehouseMaxRows 读取全部分页时的默认行数上限
+const defaultWarehouseMaxRows = 10000
+
+// WarehouseAction 数据仓库查询动作（Snowflake、BigQuery），查询以异步方式执行并分页读取结果
+type WarehouseAction struct {
+	ctx *ActionContext
+}
+
+// NewWarehouseAction 创建数据仓库查询动作
+func NewWarehouseAction(ctx *ActionContext) *WarehouseAction {
+	return &WarehouseAction{ctx: ctx}
+}
+
+// Name 返回动作名称
+func (a *WarehouseAction) Name() string {
+	return "WarehouseAction"
+}
+
+// Run 执行数据仓库操作
+//
+// query 提交查询并等待完成；submit 只提交查询并返回 query_id；fetch 按 query_id 和 page_token 读取结果。
+func (a *WarehouseAction) Run(ctx context.Context, taskCtx *TaskContext) error {
+	params := resolveValue(taskCtx.GetParams(), taskCtx).(map[string]interface{})
+
+	// 解析参数
+	dataSourceName, _ := params["datasource"].(string)
+	operation, _ := params["operation"].(string)
+	sqlQuery, _ := params["sql"].(string)
+	queryParams, _ := params["params"].([]interface{})
+	queryID, _ := params["query_id"].(string)
+	pageToken, _ := params["page_token"].(string)
+	pageSize, _ := params["page_size"].(float64)
+	allPages, _ := params["all_pages"].(bool)
+	maxRows, _ := params["max_rows"].(float64)
+	timeout, _ := params["timeout"].(float64)
+	pollInterval, _ := params["poll_interval"].(float64)
+
+	if dataSourceName == "" {
+		return fmt.Errorf("datasource parameter is required")
+	}
+	if operation == "" {
+		operation = "query"
+	}
+	if maxRows == 0 {
+		maxRows = defaultWarehouseMaxRows
+	}
+	if timeout == 0 {
+		timeout = 300
+	}
+	if pollInterval == 0 {
+		pollInterval = 2
+	}
+
+	client, err := a.ctx.DataSourceMgr.GetWarehouse(dataSourceName)
+	if err != nil {
+		return fmt.Errorf("failed to get warehouse connection: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var page *datasource.WarehousePage
+	switch operation {
+	case "query", "submit":
+		if sqlQuery == "" {
+			return fmt.Errorf("sql parameter is required")
+		}
+		a.ctx.Logger.Infof("Submitting warehouse query on %s: %s", dataSourceName, sqlQuery)
+		queryID, err = client.Submit(ctx, sqlQuery, queryParams)
+		if err != nil {
+			return fmt.Errorf("failed to submit query: %v", err)
+		}
+		if operation == "submit" {
+			taskCtx.SetOutput(map[string]interface{}{"query_id": queryID, "status": datasource.QueryRunning})
+			a.ctx.Logger.Infof("Warehouse query submitted: %s", queryID)
+			return nil
+		}
+		page, err = datasource.WaitQuery(ctx, client, queryID, int(pageSize), time.Duration(pollInterval*float64(time.Second)))
+	case "fetch":
+		if queryID == "" {
+			return fmt.Errorf("query_id parameter is required")
+		}
+		page, err = client.Fetch(ctx, queryID, pageToken, int(pageSize))
+	default:
+		return fmt.Errorf("unsupported warehouse operation: %s", operation)
+	}
+	if err != nil {
+		return fmt.Errorf("warehouse query %s failed: %v", queryID, err)
+	}
+
+	// 按需继续读取后续分页，达到 max_rows 后停止，剩余结果可用 next_page_token 继续读取
+	for allPages && page.Status == datasource.QueryDone && page.NextPageToken != "" && page.Count < int(maxRows) {
+		next, err := client.Fetch(ctx, queryID, page.NextPageToken, int(pageSize))
+		if err != nil {
+			return fmt.Errorf("warehouse query %s failed: %v", queryID, err)
+		}
+		page.Rows = append(page.Rows, next.Rows...)
+		page.Count = len(page.Rows)
+		page.NextPageToken = next.NextPageToken
+	}
+
+	columns := make([]interface{}, len(page.Columns))
+	for i, column := range page.Columns {
+		columns[i] = column
+	}
+
+	// 保存结果
+	taskCtx.SetOutput(map[string]interface{}{
+		"query_id":        page.QueryID,
+		"status":          page.Status,
+		"columns":         columns,
+		"rows":            page.Rows,
+		"count":           page.Count,
+		"total_rows":      page.TotalRows,
+		"next_page_token": page.NextPageToken,
+	})
+	a.ctx.Logger.Infof("Warehouse query %s returned %d rows (status %s)", queryID, page.Count, page.Status)
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"nsa/internal/models"
+)
+
+// 执行窗口外的执行策略
+const (
+	WindowDefer = "defer"
+	WindowSkip  = "skip"
+)
+
+// windowDays 星期名称
+var windowDays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// executionWindow 解析后的执行窗口
+type executionWindow struct {
+	location *time.Location
+	periods  []windowPeriod
+	skip     bool
+}
+
+// windowPeriod 解析后的时间段，start、end 为一天中的分钟数
+type windowPeriod struct {
+	days       [7]bool
+	start, end int
+}
+
+// ValidateExecutionWindow 校验执行窗口配置
+func ValidateExecutionWindow(window *models.ExecutionWindow) error {
+	if window == nil {
+		return nil
+	}
+	_, err := parseExecutionWindow(window)
+	return err
+}
+
+// parseExecutionWindow 解析执行窗口配置
+func parseExecutionWindow(window *models.ExecutionWindow) (*executionWindow, error) {
+	w := &executionWindow{location: time.UTC}
+	switch window.Policy {
+	case "", WindowDefer:
+	case WindowSkip:
+		w.skip = true
+	default:
+		return nil, fmt.Errorf("execution_window.policy must be %s or %s, got %q", WindowDefer, WindowSkip, window.Policy)
+	}
+	if window.Timezone != "" {
+		loc, err := time.LoadLocation(window.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("execution_window.timezone %q is not a valid IANA time zone", window.Timezone)
+		}
+		w.location = loc
+	}
+	if len(window.Periods) == 0 {
+		return nil, fmt.Errorf("execution_window.periods is required")
+	}
+
+	for i, period := range window.Periods {
+		var p windowPeriod
+		var err error
+		if p.start, err = parseClock(period.Start); err != nil {
+			return nil, fmt.Errorf("execution_window.periods[%d].start: %v", i, err)
+		}
+		if p.end, err = parseClock(period.End); err != nil {
+			return nil, fmt.Errorf("execution_window.periods[%d].end: %v", i, err)
+		}
+		if len(period.Days) == 0 {
+			p.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		for _, day := range period.Days {
+			if err := p.addDays(day); err != nil {
+				return nil, fmt.Errorf("execution_window.periods[%d].days: %v", i, err)
+			}
+		}
+		w.periods = append(w.periods, p)
+	}
+	return w, nil
+}
+
+// parseClock 解析 HH:MM，返回一天中的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// addDays 添加星期名称或范围（如 mon-fri、fri-mon）
+func (p *windowPeriod) addDays(s string) error {
+	from, to, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "-")
+	start, ok := windowDays[from]
+	if !ok {
+		return fmt.Errorf("invalid day %q", s)
+	}
+	end := start
+	if isRange {
+		if end, ok = windowDays[to]; !ok {
+			return fmt.Errorf("invalid day %q", s)
+		}
+	}
+	for day := start; ; day = (day + 1) % 7 {
+		p.days[day] = true
+		if day == end {
+			return nil
+		}
+	}
+}
+
+// contains t 是否在执行窗口内
+func (w *executionWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+	for _, p := range w.periods {
+		if p.start < p.end {
+			if p.days[today] && minute >= p.start && minute < p.end {
+				return true
+			}
+			continue
+		}
+		// 跨越午夜：开始日的 start 之后，或前一天开始的时间段在今天 end 之前
+		if (p.days[today] && minute >= p.start) || (p.days[yesterday] && minute < p.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// next 返回 t 之后最近的窗口开始时间
+func (w *executionWindow) next(t time.Time) time.Time {
+	t = t.In(w.location)
+	var next time.Time
+	for d := 0; d <= 7 && next.IsZero(); d++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+d, 0, 0, 0, 0, w.location)
+		for _, p := range w.periods {
+			if !p.days[day.Weekday()] {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), p.start/60, p.start%60, 0, 0, w.location)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// deferExecution 检查工作流的执行窗口，返回窗口外的执行应延迟到的时间；skip 为 true 时跳过执行
+//
+// 配置无效的窗口（如修改后未通过校验的旧数据）不限制执行。
+func (e *Executor) deferExecution(workflowConfig *models.WorkflowConfig, now time.Time) (resumeAt time.Time, skip bool) {
+	if workflowConfig.ExecutionWindow == nil {
+		return time.Time{}, false
+	}
+	window, err := parseExecutionWindow(workflowConfig.ExecutionWindow)
+	if err != nil {
+		e.logger.Errorf("Ignoring invalid execution window of workflow %s: %v", workflowConfig.Name, err)
+		return time.Time{}, false
+	}
+	if window.contains(now) {
+		return time.Time{}, false
+	}
+	if window.skip {
+		return time.Time{}, true
+	}
+	return window.next(now), false
+}
+
+// WindowSkipped 返回因不在执行窗口内而跳过的执行次数
+func (e *Executor) WindowSkipped() int64 {
+	return e.windowSkipped.Load()
+}
@@ -0,0 +1,393 @@
+package workqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"nsa/internal/config"
+	"nsa/internal/logger"
+	"nsa/internal/models"
+	"nsa/internal/mongodb"
+	"nsa/internal/workflow"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// collectionName 工作队列集合
+	collectionName = "work_queue"
+	// claimLockTimeout 领取的记录在该时间内未完成时视为领取的节点已停止，可以被重新领取
+	claimLockTimeout = 2 * time.Minute
+	// pollInterval 队列为空时检查新记录（其他节点写入、重试时间到达）的间隔
+	pollInterval = time.Second
+	// maxRetryDelay 两次重试之间的最长等待时间
+	maxRetryDelay = 10 * time.Minute
+)
+
+// 队列记录状态
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusFailed     = "failed"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("work queue item not found")
+
+// Item 工作队列中等待执行的消息
+type Item struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	WorkflowID  string             `bson:"workflow_id" json:"workflow_id"`
+	Topic       string             `bson:"topic" json:"topic"`
+	Channel     string             `bson:"channel" json:"channel"`
+	Message     *models.NSQMessage `bson:"message" json:"message"`
+	Status      string             `bson:"status" json:"status"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	EnqueuedAt  time.Time          `bson:"enqueued_at" json:"enqueued_at"`
+	AvailableAt time.Time          `bson:"available_at" json:"available_at"` // 最早可以执行的时间
+	LockedUntil time.Time          `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	Node        string             `bson:"node,omitempty" json:"node,omitempty"` // 领取记录的节点
+	LastError   string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+}
+
+// Stats 队列统计
+type Stats struct {
+	Pending    int64      `json:"pending"`
+	Processing int64      `json:"processing"`
+	Failed     int64      `json:"failed"`
+	Oldest     *time.Time `json:"oldest,omitempty"` // 最早的待执行记录的入队时间
+	LagSeconds float64    `json:"lag_seconds"`
+	Running    int        `json:"running"` // 当前节点从队列启动、尚未返回的执行数
+	Workers    int        `json:"workers"`
+}
+
+// Filter 查询和清理记录的条件
+type Filter struct {
+	Status     string `json:"status,omitempty"`
+	WorkflowID string `json:"workflow_id,omitempty"`
+}
+
+// Queue MongoDB持久化的工作队列，位于NSQ消息处理器和执行器之间
+//
+// 消息写入队列后立即确认NSQ消息，突发流量在队列中积压而不是同时启动大量实例；每个节点最多同时执行
+// workers 个从队列启动的实例。启动执行失败时按指数退避重试，与NSQ的重新投递无关。
+type Queue struct {
+	cfg      config.WorkQueueConfig
+	nodeID   string
+	logger   logger.Logger
+	mongoDB  *mongodb.Client
+	executor *workflow.Executor
+
+	slots  chan struct{}
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// New 创建工作队列
+func New(cfg config.WorkQueueConfig, nodeID string, logger logger.Logger, mongoClient *mongodb.Client, executor *workflow.Executor) *Queue {
+	return &Queue{
+		cfg:      cfg,
+		nodeID:   nodeID,
+		logger:   logger,
+		mongoDB:  mongoClient,
+		executor: executor,
+		slots:    make(chan struct{}, cfg.Workers),
+		notify:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// collection 返回工作队列集合
+func (q *Queue) collection() *mongo.Collection {
+	return q.mongoDB.GetDatabase().Collection(collectionName)
+}
+
+// EnsureIndexes 创建领取记录和按工作流查询使用的索引
+func (q *Queue) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := q.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}}},
+		{Keys: bson.D{{Key: "workflow_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	return err
+}
+
+// Enqueue 将消息写入队列，返回后即可确认NSQ消息
+func (q *Queue) Enqueue(ctx context.Context, workflowConfig *models.WorkflowConfig, message *models.NSQMessage) error {
+	now := time.Now()
+	item := &Item{
+		ID:          primitive.NewObjectID(),
+		WorkflowID:  workflowConfig.ID.Hex(),
+		Topic:       workflowConfig.Topic,
+		Channel:     workflowConfig.Channel,
+		Message:     message,
+		Status:      StatusPending,
+		EnqueuedAt:  now,
+		AvailableAt: now,
+	}
+	err := q.mongoDB.Retry(ctx, 5*time.Second, func(ctx context.Context) error {
+		_, err := q.collection().InsertOne(ctx, item)
+		if mongo.IsDuplicateKeyError(err) {
+			// 前一次插入已成功，只是响应丢失
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue message: %v", err)
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start 启动分发goroutine
+func (q *Queue) Start() {
+	q.done = make(chan struct{})
+	go q.run()
+	q.logger.Infof("Work queue started with %d workers", q.cfg.Workers)
+}
+
+// Stop 停止领取新记录，已启动的执行不受影响；已领取未完成的记录在锁超时后由其他节点或重启后重新领取
+func (q *Queue) Stop() {
+	q.once.Do(func() {
+		close(q.stop)
+		if q.done != nil {
+			<-q.done
+		}
+	})
+}
+
+// run 有空闲名额时领取记录并启动执行
+func (q *Queue) run() {
+	defer close(q.done)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case q.slots <- struct{}{}:
+		}
+
+		item, err := q.claim()
+		if err != nil {
+			q.logger.Errorf("Failed to claim work queue item: %v", err)
+		}
+		if item != nil {
+			go q.dispatch(item)
+			continue
+		}
+
+		// 队列为空或领取失败，释放名额，等待新记录
+		<-q.slots
+		select {
+		case <-q.stop:
+			return
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// release 释放一个执行名额
+func (q *Queue) release() {
+	<-q.slots
+}
+
+// claim 领取一条可以执行的记录：到达执行时间的待执行记录，或锁已超时的执行中记录
+func (q *Queue) claim() (*Item, error) {
+	now := time.Now()
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"status": StatusPending, "available_at": bson.M{"$lte": now}},
+			bson.M{"status": StatusProcessing, "locked_until": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{"status": StatusProcessing, "node": q.nodeID, "locked_until": now.Add(claimLockTimeout)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "available_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var item Item
+	err := q.collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// dispatch 启动记录的执行，实例创建后删除记录；执行goroutine返回时释放名额
+func (q *Queue) dispatch(item *Item) {
+	workflowConfig, err := q.executor.GetWorkflowConfig(item.Topic, item.Channel)
+	if err == mongo.ErrNoDocuments {
+		q.release()
+		q.fail(item, fmt.Errorf("no enabled workflow for topic %s channel %s", item.Topic, item.Channel), false)
+		return
+	}
+	if err != nil {
+		q.release()
+		q.fail(item, err, true)
+		return
+	}
+
+	if err := q.executor.ExecuteAsync(context.Background(), workflowConfig, item.Message, q.release); err != nil {
+		q.release()
+		q.fail(item, err, true)
+		return
+	}
+
+	// 实例已持久化，由实例恢复机制负责后续执行
+	err = q.mongoDB.Retry(context.Background(), 5*time.Second, func(ctx context.Context) error {
+		_, err := q.collection().DeleteOne(ctx, bson.M{"_id": item.ID})
+		return err
+	})
+	if err != nil {
+		q.logger.Errorf("Failed to remove work queue item %s: %v", item.ID.Hex(), err)
+	}
+}
+
+// fail 记录启动执行失败：可以重试且未超过最大尝试次数时按指数退避重新等待，否则标记为 failed
+func (q *Queue) fail(item *Item, cause error, retryable bool) {
+	set := bson.M{"last_error": cause.Error(), "locked_until": time.Time{}}
+	if retryable && item.Attempts < q.cfg.MaxAttempts {
+		delay := time.Duration(q.cfg.RetryDelay) * time.Second << (item.Attempts - 1)
+		if delay > maxRetryDelay || delay < 0 {
+			delay = maxRetryDelay
+		}
+		set["status"] = StatusPending
+		set["available_at"] = time.Now().Add(delay)
+		q.logger.Warnf("Work queue item %s failed (attempt %d/%d), retrying in %v: %v", item.ID.Hex(), item.Attempts, q.cfg.MaxAttempts, delay, cause)
+	} else {
+		set["status"] = StatusFailed
+		q.logger.Errorf("Work queue item %s failed after %d attempts: %v", item.ID.Hex(), item.Attempts, cause)
+	}
+
+	err := q.mongoDB.Retry(context.Background(), 5*time.Second, func(ctx context.Context) error {
+		_, err := q.collection().UpdateOne(ctx, bson.M{"_id": item.ID}, bson.M{"$set": set})
+		return err
+	})
+	if err != nil {
+		q.logger.Errorf("Failed to update work queue item %s: %v", item.ID.Hex(), err)
+	}
+}
+
+// filter 构建查询条件
+func (f Filter) filter() bson.M {
+	filter := bson.M{}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	if f.WorkflowID != "" {
+		filter["workflow_id"] = f.WorkflowID
+	}
+	return filter
+}
+
+// Stats 返回队列统计，Running 和 Workers 为当前节点的值
+func (q *Queue) Stats(ctx context.Context) (*Stats, error) {
+	stats := &Stats{Running: len(q.slots), Workers: q.cfg.Workers}
+	for status, count := range map[string]*int64{
+		StatusPending:    &stats.Pending,
+		StatusProcessing: &stats.Processing,
+		StatusFailed:     &stats.Failed,
+	} {
+		n, err := q.collection().CountDocuments(ctx, bson.M{"status": status})
+		if err != nil {
+			return nil, err
+		}
+		*count = n
+	}
+
+	var oldest Item
+	opts := options.FindOne().SetSort(bson.D{{Key: "enqueued_at", Value: 1}}).SetProjection(bson.M{"enqueued_at": 1})
+	err := q.collection().FindOne(ctx, bson.M{"status": bson.M{"$ne": StatusFailed}}, opts).Decode(&oldest)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err == nil {
+		stats.Oldest = &oldest.EnqueuedAt
+		stats.LagSeconds = time.Since(oldest.EnqueuedAt).Seconds()
+	}
+	return stats, nil
+}
+
+// List 按入队顺序列出记录
+func (q *Queue) List(ctx context.Context, filter Filter, skip, limit int64) ([]Item, int64, error) {
+	total, err := q.collection().CountDocuments(ctx, filter.filter())
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "enqueued_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := q.collection().Find(ctx, filter.filter(), opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	items := []Item{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// Purge 删除符合条件的记录，不删除执行中的记录，返回删除数量
+func (q *Queue) Purge(ctx context.Context, filter Filter) (int64, error) {
+	if filter.Status == StatusProcessing {
+		return 0, fmt.Errorf("processing items cannot be purged")
+	}
+	query := filter.filter()
+	if filter.Status == "" {
+		query["status"] = bson.M{"$ne": StatusProcessing}
+	}
+	result, err := q.collection().DeleteMany(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// Retry 将失败的记录重新放回队列，重置尝试次数
+func (q *Queue) Retry(ctx context.Context, id primitive.ObjectID) error {
+	update := bson.M{
+		"$set":   bson.M{"status": StatusPending, "available_at": time.Now(), "attempts": 0},
+		"$unset": bson.M{"last_error": "", "locked_until": "", "node": ""},
+	}
+	result, err := q.collection().UpdateOne(ctx, bson.M{"_id": id, "status": StatusFailed}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // 内置时区数据库，容器镜像没有 /usr/share/zoneinfo 时 admin.timezone 等时区设置仍然可用
 
 	"nsa/internal/config"
 	"nsa/internal/logger"
@@ -18,6 +19,12 @@ import (
 
 // main 程序入口点
 func main() {
+	// nsa bench 压测模式
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
+
 	// 加载配置
 	cfg, err := config.Load("config.json")
 	if err != nil {
@@ -49,6 +56,18 @@ func main() {
 		}
 	}()
 
+	// SIGHUP 重新加载配置
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("Received SIGHUP, reloading configuration...")
+			if err := httpServer.ReloadConfig(); err != nil {
+				logger.Errorf("Failed to reload configuration: %v", err)
+			}
+		}
+	}()
+
 	// 等待中断信号
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)